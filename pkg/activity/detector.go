@@ -0,0 +1,230 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type State int
+
+const (
+	StateUnknown State = iota
+	StateActive
+	StateDTX
+	StateMuted
+)
+
+func (s State) String() string {
+	switch s {
+	case StateUnknown:
+		return "UNKNOWN"
+	case StateActive:
+		return "ACTIVE"
+	case StateDTX:
+		return "DTX"
+	case StateMuted:
+		return "MUTED"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type DetectorParams struct {
+	// packets with payload not larger than this are treated as discontinuous transmission (comfort noise) packets
+	DTXPayloadSize int
+	// stream is in DTX when only DTX packets have been received for this long
+	DTXThreshold time.Duration
+	// stream is muted when no packets have been received for this long
+	MuteThreshold time.Duration
+	// interval at which silence thresholds are evaluated when started
+	CheckInterval time.Duration
+}
+
+var DetectorParamsDefault = DetectorParams{
+	DTXPayloadSize: 3,
+	DTXThreshold:   500 * time.Millisecond,
+	MuteThreshold:  2 * time.Second,
+	CheckInterval:  250 * time.Millisecond,
+}
+
+type Event struct {
+	SSRC      uint32
+	State     State
+	PrevState State
+	At        time.Time
+}
+
+type streamState struct {
+	state            State
+	lastPacketAt     time.Time
+	lastNonDTXPacket time.Time
+}
+
+// Detector tracks packet flow per SSRC and signals when a sender stops and starts sending,
+// distinguishing a muted sender (no packets) from one in discontinuous transmission (comfort noise only).
+type Detector struct {
+	params DetectorParams
+
+	lock          sync.Mutex
+	streams       map[uint32]*streamState
+	onStateChange func(event Event)
+	isStopped     bool
+
+	close chan struct{}
+}
+
+func NewDetector(params DetectorParams) *Detector {
+	if params.CheckInterval <= 0 {
+		params.CheckInterval = DetectorParamsDefault.CheckInterval
+	}
+	return &Detector{
+		params:  params,
+		streams: make(map[uint32]*streamState),
+		close:   make(chan struct{}),
+	}
+}
+
+func (d *Detector) OnStateChange(f func(event Event)) {
+	d.lock.Lock()
+	d.onStateChange = f
+	d.lock.Unlock()
+}
+
+func (d *Detector) Start() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.isStopped {
+		return
+	}
+	go d.worker()
+}
+
+func (d *Detector) Stop() {
+	d.lock.Lock()
+	if d.isStopped {
+		d.lock.Unlock()
+		return
+	}
+
+	close(d.close)
+	d.isStopped = true
+	d.lock.Unlock()
+}
+
+// Observe records a packet of the given payload size for the SSRC.
+func (d *Detector) Observe(ssrc uint32, payloadSize int, at time.Time) {
+	d.lock.Lock()
+	s, ok := d.streams[ssrc]
+	if !ok {
+		s = &streamState{
+			lastNonDTXPacket: at,
+		}
+		d.streams[ssrc] = s
+	}
+	s.lastPacketAt = at
+	if payloadSize > d.params.DTXPayloadSize {
+		s.lastNonDTXPacket = at
+	}
+	event, changed := d.updateLocked(ssrc, s, at)
+	onStateChange := d.onStateChange
+	d.lock.Unlock()
+
+	if changed && onStateChange != nil {
+		onStateChange(event)
+	}
+}
+
+// Check evaluates silence thresholds of all streams at the given time.
+func (d *Detector) Check(now time.Time) {
+	var events []Event
+	d.lock.Lock()
+	for ssrc, s := range d.streams {
+		if event, changed := d.updateLocked(ssrc, s, now); changed {
+			events = append(events, event)
+		}
+	}
+	onStateChange := d.onStateChange
+	d.lock.Unlock()
+
+	if onStateChange != nil {
+		for _, event := range events {
+			onStateChange(event)
+		}
+	}
+}
+
+func (d *Detector) State(ssrc uint32) State {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if s, ok := d.streams[ssrc]; ok {
+		return s.state
+	}
+	return StateUnknown
+}
+
+func (d *Detector) Remove(ssrc uint32) {
+	d.lock.Lock()
+	delete(d.streams, ssrc)
+	d.lock.Unlock()
+}
+
+func (d *Detector) updateLocked(ssrc uint32, s *streamState, now time.Time) (Event, bool) {
+	state := StateActive
+	switch {
+	case now.Sub(s.lastPacketAt) >= d.params.MuteThreshold:
+		state = StateMuted
+	case now.Sub(s.lastPacketAt) >= d.params.DTXThreshold:
+		// no packets at all for a while, could be the start of a mute or a gap between comfort noise packets,
+		// hold the current state until it is clear
+		if s.state == StateUnknown {
+			return Event{}, false
+		}
+		state = s.state
+	case now.Sub(s.lastNonDTXPacket) >= d.params.DTXThreshold:
+		// only comfort noise packets are still arriving
+		state = StateDTX
+	}
+
+	if state == s.state {
+		return Event{}, false
+	}
+
+	event := Event{
+		SSRC:      ssrc,
+		State:     state,
+		PrevState: s.state,
+		At:        now,
+	}
+	s.state = state
+	return event, true
+}
+
+func (d *Detector) worker() {
+	ticker := time.NewTicker(d.params.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.Check(time.Now())
+		case <-d.close:
+			return
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package activity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetector(t *testing.T) {
+	d := NewDetector(DetectorParamsDefault)
+
+	var events []Event
+	d.OnStateChange(func(event Event) {
+		events = append(events, event)
+	})
+
+	now := time.Now()
+	d.Observe(1, 100, now)
+	require.Equal(t, StateActive, d.State(1))
+	require.Len(t, events, 1)
+	require.Equal(t, StateUnknown, events[0].PrevState)
+
+	// comfort noise only, should go to DTX, but not muted
+	for i := 1; i <= 5; i++ {
+		d.Observe(1, 1, now.Add(time.Duration(i)*200*time.Millisecond))
+	}
+	require.Equal(t, StateDTX, d.State(1))
+	require.Len(t, events, 2)
+
+	// no packets, should be muted
+	d.Check(now.Add(4 * time.Second))
+	require.Equal(t, StateMuted, d.State(1))
+	require.Len(t, events, 3)
+	require.Equal(t, Event{SSRC: 1, State: StateMuted, PrevState: StateDTX, At: now.Add(4 * time.Second)}, events[2])
+
+	// checking again should not emit duplicate events
+	d.Check(now.Add(5 * time.Second))
+	require.Len(t, events, 3)
+
+	// resumes sending
+	d.Observe(1, 100, now.Add(6*time.Second))
+	require.Equal(t, StateActive, d.State(1))
+	require.Len(t, events, 4)
+
+	d.Remove(1)
+	require.Equal(t, StateUnknown, d.State(1))
+}
+
+func TestDetectorMuteWithoutComfortNoise(t *testing.T) {
+	d := NewDetector(DetectorParamsDefault)
+
+	var events []Event
+	d.OnStateChange(func(event Event) {
+		events = append(events, event)
+	})
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		d.Observe(1, 100, now.Add(time.Duration(i)*20*time.Millisecond))
+	}
+	require.Equal(t, StateActive, d.State(1))
+
+	// sender stops, should not pass through DTX
+	for i := 1; i <= 10; i++ {
+		d.Check(now.Add(time.Duration(i) * 250 * time.Millisecond))
+	}
+	require.Equal(t, StateMuted, d.State(1))
+	require.Len(t, events, 2)
+	require.Equal(t, StateActive, events[1].PrevState)
+	require.Equal(t, StateMuted, events[1].State)
+}
+
+func TestDetectorStartStop(t *testing.T) {
+	d := NewDetector(DetectorParams{MuteThreshold: time.Second})
+	d.Start()
+	d.Stop()
+	require.NotPanics(t, d.Stop)
+	d.Start()
+}
@@ -0,0 +1,183 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrOverloaded = errors.New("node is overloaded")
+)
+
+type Reason int
+
+const (
+	ReasonCPU Reason = iota
+	ReasonBandwidth
+	ReasonFileDescriptors
+
+	numReasons
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonCPU:
+		return "CPU"
+	case ReasonBandwidth:
+		return "BANDWIDTH"
+	case ReasonFileDescriptors:
+		return "FILE_DESCRIPTORS"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type Request struct {
+	// bitrate the connection is expected to add, in bps, 0 if not known
+	Bitrate int
+}
+
+// Controller is consulted before allocating the resources of a new connection, e.g. its mux connections or pacer,
+// and returns an error wrapping ErrOverloaded if the node should reject it.
+// It must be safe for concurrent use.
+type Controller interface {
+	Admit(req Request) error
+}
+
+type ControllerFunc func(req Request) error
+
+func (f ControllerFunc) Admit(req Request) error {
+	return f(req)
+}
+
+// ------------------------------------------------
+
+type LoadControllerParams struct {
+	// CPU utilisation of the node, between 0 and 1, at or above which connections are rejected
+	MaxCPU float64
+	// aggregate bitrate of the node, in bps, new connections must fit under, 0 means unlimited
+	MaxBitrate int64
+	// file descriptors left for new connections below which they are rejected
+	MinFDHeadroom int
+}
+
+var LoadControllerParamsDefault = LoadControllerParams{
+	MaxCPU:        0.85,
+	MinFDHeadroom: 64,
+}
+
+type LoadControllerStats struct {
+	NumAdmitted int
+	// rejected connections, indexed by Reason
+	NumRejected [numReasons]int
+}
+
+// LoadController is the default Controller, admitting connections while the CPU, bandwidth and file descriptor
+// headroom of the node, as reported by a LoadSource, are within limits. Unknown load figures are not checked.
+//
+// Typical use is
+//
+//	controller := admission.NewLoadController(admission.NewNodeLoad(admission.NodeLoadParams{Stats: source, FDs: tracker}), admission.LoadControllerParamsDefault)
+//	rtcConf.AdmissionController = controller
+//	pacerFactory := pacer.NewPacerFactory(pacer.LeakyBucketPacer, pacer.WithAdmissionController(controller))
+type LoadController struct {
+	params LoadControllerParams
+	source LoadSource
+
+	lock  sync.Mutex
+	stats LoadControllerStats
+}
+
+func NewLoadController(source LoadSource, params LoadControllerParams) *LoadController {
+	if params.MaxCPU <= 0 || params.MaxCPU > 1 {
+		params.MaxCPU = LoadControllerParamsDefault.MaxCPU
+	}
+	if params.MinFDHeadroom < 0 {
+		params.MinFDHeadroom = LoadControllerParamsDefault.MinFDHeadroom
+	}
+	return &LoadController{
+		params: params,
+		source: source,
+	}
+}
+
+func (c *LoadController) Admit(req Request) error {
+	err := c.check(c.source.Load(), req)
+
+	c.lock.Lock()
+	if err != nil {
+		var rejected *rejectedError
+		if errors.As(err, &rejected) {
+			c.stats.NumRejected[rejected.reason]++
+		}
+	} else {
+		c.stats.NumAdmitted++
+	}
+	c.lock.Unlock()
+	return err
+}
+
+func (c *LoadController) Stats() LoadControllerStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats
+}
+
+func (c *LoadController) check(load Load, req Request) error {
+	if load.CPU >= 0 && load.CPU >= c.params.MaxCPU {
+		return &rejectedError{reason: ReasonCPU, detail: fmt.Sprintf("cpu %.2f, max %.2f", load.CPU, c.params.MaxCPU)}
+	}
+	if c.params.MaxBitrate > 0 && load.Bitrate >= 0 && load.Bitrate+int64(req.Bitrate) > c.params.MaxBitrate {
+		return &rejectedError{
+			reason: ReasonBandwidth,
+			detail: fmt.Sprintf("bitrate %d + %d, max %d", load.Bitrate, req.Bitrate, c.params.MaxBitrate),
+		}
+	}
+	if load.FDHeadroom >= 0 && load.FDHeadroom < c.params.MinFDHeadroom {
+		return &rejectedError{
+			reason: ReasonFileDescriptors,
+			detail: fmt.Sprintf("fd headroom %d, min %d", load.FDHeadroom, c.params.MinFDHeadroom),
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------
+
+type rejectedError struct {
+	reason Reason
+	detail string
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrOverloaded, e.detail)
+}
+
+func (e *rejectedError) Unwrap() error {
+	return ErrOverloaded
+}
+
+// ReasonOf returns the reason a connection was rejected, false if err is not a rejection.
+func ReasonOf(err error) (Reason, bool) {
+	var rejected *rejectedError
+	if errors.As(err, &rejected) {
+		return rejected.reason, true
+	}
+	return 0, false
+}
@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/statsstream"
+)
+
+func TestLoadController(t *testing.T) {
+	load := Load{CPU: 0.5, Bitrate: 800, FDHeadroom: 100}
+	c := NewLoadController(LoadSourceFunc(func() Load { return load }), LoadControllerParams{
+		MaxCPU:        0.9,
+		MaxBitrate:    1000,
+		MinFDHeadroom: 10,
+	})
+
+	require.NoError(t, c.Admit(Request{}))
+	require.NoError(t, c.Admit(Request{Bitrate: 200}))
+
+	err := c.Admit(Request{Bitrate: 300})
+	require.ErrorIs(t, err, ErrOverloaded)
+	reason, ok := ReasonOf(err)
+	require.True(t, ok)
+	require.Equal(t, ReasonBandwidth, reason)
+
+	load.CPU = 0.95
+	reason, _ = ReasonOf(c.Admit(Request{}))
+	require.Equal(t, ReasonCPU, reason)
+
+	load = Load{CPU: -1, Bitrate: -1, FDHeadroom: 5}
+	reason, _ = ReasonOf(c.Admit(Request{Bitrate: 5000}))
+	require.Equal(t, ReasonFileDescriptors, reason)
+
+	// nothing known
+	load.FDHeadroom = -1
+	require.NoError(t, c.Admit(Request{Bitrate: 5000}))
+
+	stats := c.Stats()
+	require.Equal(t, 3, stats.NumAdmitted)
+	require.Equal(t, [numReasons]int{1, 1, 1}, stats.NumRejected)
+
+	_, ok = ReasonOf(errors.New("other"))
+	require.False(t, ok)
+}
+
+func TestNodeLoad(t *testing.T) {
+	bytes := uint64(0)
+	n := NewNodeLoad(NodeLoadParams{
+		Stats: statsstream.SourceFunc(func() (*statsstream.NodeStats, []*statsstream.ConnectionStats) {
+			return &statsstream.NodeStats{BytesSent: bytes, BytesReceived: bytes}, nil
+		}),
+	})
+
+	load := n.Load()
+	require.Equal(t, int64(-1), load.Bitrate)
+	require.Equal(t, -1, load.FDHeadroom)
+
+	start := n.last.at
+	bytes = 500
+	n.lock.Lock()
+	n.updateLocked(start.Add(2 * time.Second))
+	n.lock.Unlock()
+	require.Equal(t, int64(4000), n.load.Bitrate)
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/fdtrack"
+	"github.com/livekit/mediatransportutil/pkg/statsstream"
+)
+
+// Load of the node, figures that are not known are negative.
+type Load struct {
+	// CPU utilisation, between 0 and 1
+	CPU float64
+	// bitrate sent and received, in bps
+	Bitrate int64
+	// file descriptors that can still be opened
+	FDHeadroom int
+}
+
+// LoadSource provides the load of the node, it must be safe for concurrent use.
+type LoadSource interface {
+	Load() Load
+}
+
+type LoadSourceFunc func() Load
+
+func (f LoadSourceFunc) Load() Load {
+	return f()
+}
+
+// ------------------------------------------------
+
+type NodeLoadParams struct {
+	// byte counters of the node the bitrate is computed from, bitrate is not known if nil
+	Stats statsstream.Source
+	// descriptors of the node, headroom is not known if nil
+	FDs *fdtrack.Tracker
+	// CPU utilisation and bitrate are averaged over at least this long
+	SampleInterval time.Duration
+}
+
+var NodeLoadParamsDefault = NodeLoadParams{
+	SampleInterval: time.Second,
+}
+
+type loadSample struct {
+	at          time.Time
+	bytes       uint64
+	hasBytes    bool
+	cpuBusy     uint64
+	cpuTotal    uint64
+	hasCPUTimes bool
+}
+
+// NodeLoad is a LoadSource sampling the CPU times of the host, the byte counters of the node stats and
+// the file descriptor headroom. CPU utilisation and bitrate are averaged between samples taken at least
+// SampleInterval apart, neither is known until a second sample has been taken.
+type NodeLoad struct {
+	params NodeLoadParams
+
+	lock sync.Mutex
+	last loadSample
+	load Load
+}
+
+func NewNodeLoad(params NodeLoadParams) *NodeLoad {
+	if params.SampleInterval <= 0 {
+		params.SampleInterval = NodeLoadParamsDefault.SampleInterval
+	}
+	n := &NodeLoad{
+		params: params,
+		load:   Load{CPU: -1, Bitrate: -1, FDHeadroom: -1},
+	}
+	n.last = n.sample(time.Now())
+	return n
+}
+
+func (n *NodeLoad) Load() Load {
+	fdHeadroom := -1
+	if n.params.FDs != nil {
+		fdHeadroom = n.params.FDs.Stats().Headroom
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.updateLocked(time.Now())
+	load := n.load
+	load.FDHeadroom = fdHeadroom
+	return load
+}
+
+func (n *NodeLoad) updateLocked(now time.Time) {
+	elapsed := now.Sub(n.last.at)
+	if elapsed < n.params.SampleInterval {
+		return
+	}
+
+	s := n.sample(now)
+	n.load.CPU, n.load.Bitrate = -1, -1
+	if s.hasCPUTimes && n.last.hasCPUTimes && s.cpuTotal > n.last.cpuTotal {
+		n.load.CPU = float64(s.cpuBusy-n.last.cpuBusy) / float64(s.cpuTotal-n.last.cpuTotal)
+	}
+	if s.hasBytes && n.last.hasBytes && s.bytes >= n.last.bytes {
+		n.load.Bitrate = int64(float64(s.bytes-n.last.bytes) * 8 / elapsed.Seconds())
+	}
+	n.last = s
+}
+
+func (n *NodeLoad) sample(now time.Time) loadSample {
+	s := loadSample{at: now}
+	if busy, total, err := cpuTimes(); err == nil {
+		s.cpuBusy, s.cpuTotal, s.hasCPUTimes = busy, total, true
+	}
+	if n.params.Stats != nil {
+		if node, _ := n.params.Stats.Snapshot(); node != nil {
+			s.bytes, s.hasBytes = node.GetBytesSent()+node.GetBytesReceived(), true
+		}
+	}
+	return s
+}
@@ -0,0 +1,305 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocation
+
+import (
+	"fmt"
+	"sync"
+)
+
+type TrackKind int
+
+const (
+	TrackKindAudio TrackKind = iota
+	TrackKindCamera
+	TrackKindScreenShare
+)
+
+func (k TrackKind) String() string {
+	switch k {
+	case TrackKindAudio:
+		return "AUDIO"
+	case TrackKindCamera:
+		return "CAMERA"
+	case TrackKindScreenShare:
+		return "SCREEN_SHARE"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+type TrackParams struct {
+	ID   string
+	Kind TrackKind
+	// bitrate of the lowest layer, a video track that cannot get this much is paused
+	MinBitrate int
+	// bitrate of the highest layer, a track is never allocated more, 0 for no limit
+	MaxBitrate int
+}
+
+type AllocatorParams struct {
+	// bitrate reserved for each audio track, unless the track has a lower MaxBitrate
+	AudioBitrate int
+	// share of a screen share track relative to a camera track
+	ScreenShareWeight int
+}
+
+var AllocatorParamsDefault = AllocatorParams{
+	AudioBitrate:      64_000,
+	ScreenShareWeight: 3,
+}
+
+type Allocation struct {
+	TrackID string
+	// zero when the track is paused
+	Bitrate    int
+	IsOverride bool
+}
+
+type track struct {
+	params     TrackParams
+	override   int
+	bitrate    int
+	isNotified bool
+}
+
+// Allocator splits the congestion controlled bitrate of a connection among its tracks:
+// audio tracks get a fixed bitrate, screen share tracks a weighted share of what remains,
+// camera tracks the remainder. Overrides pin the bitrate of a track ahead of the policy.
+// Allocations are reported to the layer selector of each track through OnAllocation.
+type Allocator struct {
+	params AllocatorParams
+
+	lock         sync.Mutex
+	budget       int
+	isAudioOnly  bool
+	tracks       []*track
+	onAllocation func(allocation Allocation)
+}
+
+func NewAllocator(params AllocatorParams) *Allocator {
+	if params.ScreenShareWeight <= 0 {
+		params.ScreenShareWeight = 1
+	}
+	return &Allocator{
+		params: params,
+	}
+}
+
+// OnAllocation sets a callback invoked for every track whose allocation changes.
+func (a *Allocator) OnAllocation(f func(allocation Allocation)) {
+	a.lock.Lock()
+	a.onAllocation = f
+	a.lock.Unlock()
+}
+
+// SetBudget sets the bitrate available to the connection, typically the congestion controller estimate.
+func (a *Allocator) SetBudget(budget int) {
+	a.lock.Lock()
+	a.budget = budget
+	changed := a.updateLocked()
+	onAllocation := a.onAllocation
+	a.lock.Unlock()
+
+	notify(onAllocation, changed)
+}
+
+// SetAudioOnly pauses all video tracks, including overridden ones, see AudioOnlyPolicy.
+func (a *Allocator) SetAudioOnly(isAudioOnly bool) {
+	a.lock.Lock()
+	a.isAudioOnly = isAudioOnly
+	changed := a.updateLocked()
+	onAllocation := a.onAllocation
+	a.lock.Unlock()
+
+	notify(onAllocation, changed)
+}
+
+// AddTrack adds a track, or updates the parameters of an existing track.
+func (a *Allocator) AddTrack(params TrackParams) {
+	a.lock.Lock()
+	if t := a.getTrackLocked(params.ID); t != nil {
+		t.params = params
+	} else {
+		a.tracks = append(a.tracks, &track{params: params})
+	}
+	changed := a.updateLocked()
+	onAllocation := a.onAllocation
+	a.lock.Unlock()
+
+	notify(onAllocation, changed)
+}
+
+func (a *Allocator) RemoveTrack(trackID string) {
+	a.lock.Lock()
+	for i, t := range a.tracks {
+		if t.params.ID == trackID {
+			a.tracks = append(a.tracks[:i], a.tracks[i+1:]...)
+			break
+		}
+	}
+	changed := a.updateLocked()
+	onAllocation := a.onAllocation
+	a.lock.Unlock()
+
+	notify(onAllocation, changed)
+}
+
+// SetOverride pins the bitrate of a track, allocated before the policy applies to other tracks.
+func (a *Allocator) SetOverride(trackID string, bitrate int) {
+	a.lock.Lock()
+	if t := a.getTrackLocked(trackID); t != nil {
+		t.override = bitrate
+	}
+	changed := a.updateLocked()
+	onAllocation := a.onAllocation
+	a.lock.Unlock()
+
+	notify(onAllocation, changed)
+}
+
+func (a *Allocator) ClearOverride(trackID string) {
+	a.SetOverride(trackID, 0)
+}
+
+// Allocation returns the current allocation of the track, 0 if unknown or paused.
+func (a *Allocator) Allocation(trackID string) int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if t := a.getTrackLocked(trackID); t != nil {
+		return t.bitrate
+	}
+	return 0
+}
+
+func (a *Allocator) getTrackLocked(trackID string) *track {
+	for _, t := range a.tracks {
+		if t.params.ID == trackID {
+			return t
+		}
+	}
+	return nil
+}
+
+// updateLocked re-allocates and returns the allocations that changed
+func (a *Allocator) updateLocked() []Allocation {
+	bitrates := a.allocateLocked()
+
+	var changed []Allocation
+	for i, t := range a.tracks {
+		if t.isNotified && t.bitrate == bitrates[i] {
+			continue
+		}
+		t.bitrate = bitrates[i]
+		t.isNotified = true
+		changed = append(changed, Allocation{
+			TrackID:    t.params.ID,
+			Bitrate:    t.bitrate,
+			IsOverride: t.override > 0,
+		})
+	}
+	return changed
+}
+
+func (a *Allocator) allocateLocked() []int {
+	bitrates := make([]int, len(a.tracks))
+	remaining := a.budget
+
+	take := func(i int, bitrate int) {
+		if bitrate > remaining {
+			bitrate = remaining
+		}
+		if bitrate < 0 {
+			bitrate = 0
+		}
+		bitrates[i] = bitrate
+		remaining -= bitrate
+	}
+
+	// overrides first, then audio
+	for i, t := range a.tracks {
+		if a.isAudioOnly && t.params.Kind != TrackKindAudio {
+			continue
+		}
+		if t.override > 0 {
+			take(i, t.override)
+		}
+	}
+	for i, t := range a.tracks {
+		if t.override == 0 && t.params.Kind == TrackKindAudio {
+			bitrate := a.params.AudioBitrate
+			if t.params.MaxBitrate > 0 && t.params.MaxBitrate < bitrate {
+				bitrate = t.params.MaxBitrate
+			}
+			take(i, bitrate)
+		}
+	}
+
+	// video, weighted max-min fair share, screen shares weighted higher. Video tracks that cannot get their
+	// lowest layer are paused one at a time, largest shortfall first, and their share goes to the others
+	var video []int
+	for i, t := range a.tracks {
+		if !a.isAudioOnly && t.override == 0 && t.params.Kind != TrackKindAudio {
+			video = append(video, i)
+		}
+	}
+	for len(video) != 0 && remaining > 0 {
+		demands := make([]Demand, 0, len(video))
+		for _, i := range video {
+			desired := a.tracks[i].params.MaxBitrate
+			if desired <= 0 {
+				// uncapped, can use all that remains
+				desired = remaining
+			}
+			demands = append(demands, Demand{Weight: a.weight(a.tracks[i]), Desired: desired})
+		}
+		allocated := MaxMinFair(remaining, demands)
+
+		paused := -1
+		maxShortfall := 0
+		for j, i := range video {
+			if shortfall := a.tracks[i].params.MinBitrate - allocated[j]; shortfall > maxShortfall {
+				paused, maxShortfall = j, shortfall
+			}
+		}
+		if paused < 0 {
+			for j, i := range video {
+				bitrates[i] = allocated[j]
+			}
+			break
+		}
+		video = append(video[:paused], video[paused+1:]...)
+	}
+	return bitrates
+}
+
+func (a *Allocator) weight(t *track) int {
+	if t.params.Kind == TrackKindScreenShare {
+		return a.params.ScreenShareWeight
+	}
+	return 1
+}
+
+// ------------------------------------------------
+
+func notify(onAllocation func(allocation Allocation), changed []Allocation) {
+	if onAllocation == nil {
+		return
+	}
+	for _, allocation := range changed {
+		onAllocation(allocation)
+	}
+}
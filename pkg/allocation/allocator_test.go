@@ -0,0 +1,173 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocator(t *testing.T) {
+	a := NewAllocator(AllocatorParams{AudioBitrate: 50_000, ScreenShareWeight: 3})
+
+	allocations := make(map[string]int)
+	a.OnAllocation(func(allocation Allocation) {
+		allocations[allocation.TrackID] = allocation.Bitrate
+	})
+
+	a.AddTrack(TrackParams{ID: "audio", Kind: TrackKindAudio})
+	a.AddTrack(TrackParams{ID: "camera", Kind: TrackKindCamera, MinBitrate: 100_000, MaxBitrate: 2_000_000})
+	a.AddTrack(TrackParams{ID: "screen", Kind: TrackKindScreenShare, MinBitrate: 200_000, MaxBitrate: 2_500_000})
+
+	// nothing to allocate yet
+	require.Equal(t, map[string]int{"audio": 0, "camera": 0, "screen": 0}, allocations)
+
+	// audio fixed, screen share gets three times the camera share
+	a.SetBudget(850_000)
+	require.Equal(t, map[string]int{"audio": 50_000, "camera": 200_000, "screen": 600_000}, allocations)
+
+	// screen share capped at its maximum, camera gets the remainder
+	a.SetBudget(3_050_000)
+	require.Equal(t, 2_250_000, a.Allocation("screen"))
+	require.Equal(t, 750_000, a.Allocation("camera"))
+	a.SetBudget(5_000_000)
+	require.Equal(t, 2_500_000, a.Allocation("screen"))
+	require.Equal(t, 2_000_000, a.Allocation("camera"))
+
+	// camera paused when it cannot get its lowest layer, its share goes to the screen share
+	a.SetBudget(400_000)
+	require.Equal(t, 50_000, a.Allocation("audio"))
+	require.Equal(t, 0, a.Allocation("camera"))
+	require.Equal(t, 350_000, a.Allocation("screen"))
+
+	// override pins the camera ahead of the policy
+	a.SetOverride("camera", 300_000)
+	require.Equal(t, 300_000, a.Allocation("camera"))
+	require.Equal(t, 50_000, a.Allocation("audio"))
+	require.Equal(t, 0, a.Allocation("screen"))
+
+	a.ClearOverride("camera")
+	a.RemoveTrack("screen")
+	require.Equal(t, 350_000, a.Allocation("camera"))
+	require.Equal(t, 0, a.Allocation("screen"))
+
+	a.SetAudioOnly(true)
+	require.Equal(t, 50_000, a.Allocation("audio"))
+	require.Equal(t, 0, a.Allocation("camera"))
+
+	a.SetAudioOnly(false)
+	require.Equal(t, 350_000, a.Allocation("camera"))
+}
+
+func TestAllocatorUncapped(t *testing.T) {
+	a := NewAllocator(AllocatorParams{AudioBitrate: 50_000, ScreenShareWeight: 3})
+
+	// video without a maximum takes what remains, audio without a maximum gets its fixed bitrate
+	a.AddTrack(TrackParams{ID: "audio", Kind: TrackKindAudio})
+	a.AddTrack(TrackParams{ID: "camera", Kind: TrackKindCamera, MinBitrate: 100_000})
+	a.SetBudget(1_000_000)
+	require.Equal(t, 50_000, a.Allocation("audio"))
+	require.Equal(t, 950_000, a.Allocation("camera"))
+
+	a.AddTrack(TrackParams{ID: "screen", Kind: TrackKindScreenShare, MinBitrate: 200_000, MaxBitrate: 500_000})
+	require.Equal(t, 450_000, a.Allocation("camera"))
+	require.Equal(t, 500_000, a.Allocation("screen"))
+}
+
+func TestMaxMinFair(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxBitrate int
+		demands    []Demand
+		expected   []int
+	}{
+		{
+			name:       "unlimited",
+			maxBitrate: 0,
+			demands:    []Demand{{Weight: 1, Desired: 100}},
+			expected:   []int{100},
+		},
+		{
+			name:       "fits",
+			maxBitrate: 100,
+			demands:    []Demand{{Weight: 1, Desired: 30}, {Weight: 4, Desired: 30}},
+			expected:   []int{30, 30},
+		},
+		{
+			name:       "shared by weight",
+			maxBitrate: 100,
+			demands:    []Demand{{Weight: 1, Desired: 100}, {Weight: 4, Desired: 100}},
+			expected:   []int{20, 80},
+		},
+		{
+			name:       "unused share redistributed",
+			maxBitrate: 100,
+			demands:    []Demand{{Weight: 1, Desired: 100}, {Weight: 2, Desired: 100}, {Weight: 4, Desired: 10}},
+			expected:   []int{30, 60, 10},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, MaxMinFair(tc.maxBitrate, tc.demands))
+		})
+	}
+}
+
+func TestAudioOnlyPolicy(t *testing.T) {
+	p := NewAudioOnlyPolicy(AudioOnlyParams{
+		EnterBitrate: 100_000,
+		EnterAfter:   5 * time.Second,
+		Hysteresis:   0.5,
+		ExitAfter:    10 * time.Second,
+	})
+
+	var events []AudioOnlyEvent
+	p.OnEvent(func(event AudioOnlyEvent) {
+		events = append(events, event)
+	})
+	numKeyFrameRequests := 0
+	p.OnRequestKeyFrame(func() {
+		numKeyFrameRequests++
+	})
+
+	start := time.Now()
+	p.Update(80_000, start)
+	p.Update(80_000, start.Add(4*time.Second))
+	// short recovery resets
+	p.Update(120_000, start.Add(4500*time.Millisecond))
+	p.Update(80_000, start.Add(5*time.Second))
+	p.Update(80_000, start.Add(9*time.Second))
+	require.Empty(t, events)
+
+	p.Update(80_000, start.Add(10*time.Second))
+	require.Len(t, events, 1)
+	require.True(t, events[0].IsAudioOnly)
+	require.True(t, p.IsAudioOnly())
+	require.Zero(t, numKeyFrameRequests)
+
+	// above enter threshold, but within hysteresis
+	p.Update(140_000, start.Add(11*time.Second))
+	p.Update(140_000, start.Add(30*time.Second))
+	require.Len(t, events, 1)
+
+	p.Update(160_000, start.Add(31*time.Second))
+	p.Update(160_000, start.Add(41*time.Second))
+	require.Len(t, events, 2)
+	require.False(t, events[1].IsAudioOnly)
+	require.Equal(t, 1, numKeyFrameRequests)
+}
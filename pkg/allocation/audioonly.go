@@ -0,0 +1,131 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocation
+
+import (
+	"sync"
+	"time"
+)
+
+type AudioOnlyParams struct {
+	// congestion is severe when the estimate is below this
+	EnterBitrate int
+	// ... for at least this long
+	EnterAfter time.Duration
+	// video is restored when the estimate is above EnterBitrate * (1 + Hysteresis)
+	Hysteresis float64
+	// ... for at least this long
+	ExitAfter time.Duration
+}
+
+var AudioOnlyParamsDefault = AudioOnlyParams{
+	EnterBitrate: 100_000,
+	EnterAfter:   5 * time.Second,
+	Hysteresis:   0.5,
+	ExitAfter:    10 * time.Second,
+}
+
+type AudioOnlyEvent struct {
+	// true when video forwarding should stop, false when it can resume
+	IsAudioOnly bool
+	Estimate    int
+	At          time.Time
+}
+
+// AudioOnlyPolicy decides when a connection should fall back to audio only under sustained severe congestion,
+// and when video can be restored. On restore, OnRequestKeyFrame is invoked so that
+// forwarding can resume on a key frame.
+type AudioOnlyPolicy struct {
+	params AudioOnlyParams
+
+	lock              sync.Mutex
+	isAudioOnly       bool
+	conditionSince    time.Time
+	onEvent           func(event AudioOnlyEvent)
+	onRequestKeyFrame func()
+}
+
+func NewAudioOnlyPolicy(params AudioOnlyParams) *AudioOnlyPolicy {
+	return &AudioOnlyPolicy{
+		params: params,
+	}
+}
+
+func (p *AudioOnlyPolicy) OnEvent(f func(event AudioOnlyEvent)) {
+	p.lock.Lock()
+	p.onEvent = f
+	p.lock.Unlock()
+}
+
+func (p *AudioOnlyPolicy) OnRequestKeyFrame(f func()) {
+	p.lock.Lock()
+	p.onRequestKeyFrame = f
+	p.lock.Unlock()
+}
+
+func (p *AudioOnlyPolicy) IsAudioOnly() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.isAudioOnly
+}
+
+// Update feeds the current bandwidth estimate of the connection.
+func (p *AudioOnlyPolicy) Update(estimate int, at time.Time) {
+	p.lock.Lock()
+	var condition bool
+	var after time.Duration
+	if p.isAudioOnly {
+		condition = float64(estimate) > float64(p.params.EnterBitrate)*(1+p.params.Hysteresis)
+		after = p.params.ExitAfter
+	} else {
+		condition = estimate < p.params.EnterBitrate
+		after = p.params.EnterAfter
+	}
+
+	if !condition {
+		p.conditionSince = time.Time{}
+		p.lock.Unlock()
+		return
+	}
+	if p.conditionSince.IsZero() {
+		p.conditionSince = at
+	}
+	if at.Sub(p.conditionSince) < after {
+		p.lock.Unlock()
+		return
+	}
+
+	p.isAudioOnly = !p.isAudioOnly
+	p.conditionSince = time.Time{}
+	event := AudioOnlyEvent{
+		IsAudioOnly: p.isAudioOnly,
+		Estimate:    estimate,
+		At:          at,
+	}
+	onEvent := p.onEvent
+	var onRequestKeyFrame func()
+	if !p.isAudioOnly {
+		onRequestKeyFrame = p.onRequestKeyFrame
+	}
+	p.lock.Unlock()
+
+	if onEvent != nil {
+		onEvent(event)
+	}
+	if onRequestKeyFrame != nil {
+		onRequestKeyFrame()
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allocation
+
+// Demand is the bitrate a consumer of a shared budget asks for, see MaxMinFair.
+type Demand struct {
+	Weight int
+	// bitrate beyond which the consumer has no use for more
+	Desired int
+}
+
+// MaxMinFair does weighted max-min fair sharing of maxBitrate among demands, maxBitrate <= 0 means unlimited.
+// Demands that fit in their weighted share are satisfied, what they leave is shared again among the rest.
+func MaxMinFair(maxBitrate int, demands []Demand) []int {
+	allocated := make([]int, len(demands))
+	totalDesired := 0
+	for i, d := range demands {
+		allocated[i] = d.Desired
+		totalDesired += d.Desired
+	}
+	if maxBitrate <= 0 || totalDesired <= maxBitrate {
+		return allocated
+	}
+
+	for i := range allocated {
+		allocated[i] = 0
+	}
+	unsatisfied := make([]int, 0, len(demands))
+	for i := range demands {
+		unsatisfied = append(unsatisfied, i)
+	}
+
+	remaining := maxBitrate
+	for len(unsatisfied) != 0 && remaining > 0 {
+		totalWeight := 0
+		for _, i := range unsatisfied {
+			totalWeight += demands[i].Weight
+		}
+
+		// demands whose remaining desire fits in their share are capped, the rest is shared again
+		next := unsatisfied[:0]
+		distributed := 0
+		for _, i := range unsatisfied {
+			share := remaining * demands[i].Weight / totalWeight
+			if need := demands[i].Desired - allocated[i]; need <= share {
+				allocated[i] += need
+				distributed += need
+			} else {
+				next = append(next, i)
+			}
+		}
+
+		if len(next) == len(unsatisfied) {
+			// nobody capped, split what remains by weight
+			for _, i := range next {
+				allocated[i] += remaining * demands[i].Weight / totalWeight
+			}
+			break
+		}
+		remaining -= distributed
+		unsatisfied = next
+	}
+	return allocated
+}
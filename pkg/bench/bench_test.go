@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	l := NewLoad(LoadParams{NumStreams: 4, Bitrate: 960_000, LossRate: 0.1})
+	require.Equal(t, 10*time.Millisecond, l.PacketInterval())
+
+	var packets []Packet
+	numLost := 0
+	for i := 0; i < 4000; i++ {
+		p := l.Next()
+		require.Len(t, p.Data, 1200)
+		if p.Lost {
+			numLost++
+		}
+		p.Data = nil
+		packets = append(packets, p)
+	}
+	require.InDelta(t, 400, numLost, 60)
+
+	require.Equal(t, 2, packets[6].Stream)
+	require.Equal(t, uint16(1), packets[6].Header.SequenceNumber)
+	require.Equal(t, uint16(6), packets[6].TransportSequenceNumber)
+	require.Equal(t, 15*time.Millisecond, packets[6].SendTime)
+	require.Equal(t, uint32(1350), packets[6].Header.Timestamp)
+
+	// same losses after reset
+	l.Reset()
+	for _, expected := range packets {
+		p := l.Next()
+		p.Data = nil
+		require.Equal(t, expected, p)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	results := MeasurePrimitives(LoadParamsDefault, 1000)
+	require.Len(t, results, len(Primitives()))
+
+	baseline := Baseline{}
+	for _, r := range results {
+		require.Equal(t, 1000, r.NumPackets)
+		baseline.Add(r)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, baseline.Write(&buf))
+	read, err := ReadBaseline(&buf)
+	require.NoError(t, err)
+	require.Equal(t, baseline, read)
+
+	r := Result{Name: "bucket", NsPerPacket: 100, AllocsPerPacket: 0.1}
+	baseline = Baseline{"bucket": r}
+	require.NoError(t, baseline.Compare(Result{Name: "bucket", NsPerPacket: 109, AllocsPerPacket: 0.2}, 0.1))
+	require.ErrorIs(t, baseline.Compare(Result{Name: "bucket", NsPerPacket: 111}, 0.1), ErrRegression)
+	require.ErrorIs(t, baseline.Compare(Result{Name: "bucket", NsPerPacket: 100, AllocsPerPacket: 1}, 0.1), ErrRegression)
+	require.ErrorIs(t, baseline.Compare(Result{Name: "nack_queue"}, 0.1), ErrNoBaseline)
+}
+
+func BenchmarkPrimitives(b *testing.B) {
+	RunPrimitives(b, LoadParamsDefault)
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	baseSSRC    = 0x10000000
+	payloadType = 96
+	clockRate   = 90000
+)
+
+type LoadParams struct {
+	NumStreams int
+	// per stream
+	Bitrate int
+	// RTP header included
+	PacketSize int
+	// probability of a packet being lost, independently of other packets
+	LossRate float64
+	// losses are the same for the same seed
+	Seed int64
+}
+
+var LoadParamsDefault = LoadParams{
+	NumStreams: 10,
+	Bitrate:    1_000_000,
+	PacketSize: 1200,
+	LossRate:   0.02,
+	Seed:       1,
+}
+
+func (p LoadParams) withDefaults() LoadParams {
+	if p.NumStreams == 0 {
+		p.NumStreams = LoadParamsDefault.NumStreams
+	}
+	if p.Bitrate == 0 {
+		p.Bitrate = LoadParamsDefault.Bitrate
+	}
+	if p.PacketSize == 0 {
+		p.PacketSize = LoadParamsDefault.PacketSize
+	}
+	return p
+}
+
+type Packet struct {
+	Stream int
+	Header rtp.Header
+	// across all streams, as in the transport wide congestion control extension
+	TransportSequenceNumber uint16
+	// time since the start of the load, packets are generated in send time order
+	SendTime time.Duration
+	Lost     bool
+	// RTP packet of PacketSize bytes, valid until the next call to Next
+	Data []byte
+}
+
+// Load generates RTP packets of streams at a constant bitrate with random loss, on a virtual clock so that
+// benchmarks measure the code under test only. Streams are interleaved evenly over a packet interval.
+type Load struct {
+	params   LoadParams
+	interval time.Duration
+	rand     *rand.Rand
+	buf      []byte
+	numSent  int
+}
+
+func NewLoad(params LoadParams) *Load {
+	params = params.withDefaults()
+	l := &Load{
+		params:   params,
+		interval: time.Duration(int64(params.PacketSize) * 8 * int64(time.Second) / int64(params.Bitrate)),
+		buf:      make([]byte, params.PacketSize),
+	}
+	l.Reset()
+	return l
+}
+
+func (l *Load) Params() LoadParams {
+	return l.params
+}
+
+// PacketInterval returns the time between packets of a stream.
+func (l *Load) PacketInterval() time.Duration {
+	return l.interval
+}
+
+// Reset restarts the load, generating the same packets again.
+func (l *Load) Reset() {
+	l.rand = rand.New(rand.NewSource(l.params.Seed))
+	l.numSent = 0
+}
+
+func (l *Load) Next() Packet {
+	stream := l.numSent % l.params.NumStreams
+	index := l.numSent / l.params.NumStreams
+	sendTime := time.Duration(index)*l.interval + time.Duration(stream)*l.interval/time.Duration(l.params.NumStreams)
+
+	p := Packet{
+		Stream: stream,
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    payloadType,
+			SequenceNumber: uint16(index),
+			Timestamp:      uint32(int64(sendTime) * clockRate / int64(time.Second)),
+			SSRC:           baseSSRC + uint32(stream),
+		},
+		TransportSequenceNumber: uint16(l.numSent),
+		SendTime:                sendTime,
+		Lost:                    l.params.LossRate > 0 && l.rand.Float64() < l.params.LossRate,
+	}
+	// header size is the same for every packet, the payload stays zeroed
+	_, _ = p.Header.MarshalTo(l.buf)
+	p.Data = l.buf
+
+	l.numSent++
+	return p
+}
@@ -0,0 +1,137 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	ErrRegression = errors.New("performance regression")
+	ErrNoBaseline = errors.New("no baseline")
+)
+
+type Result struct {
+	Name            string        `json:"name"`
+	NumPackets      int           `json:"numPackets"`
+	Elapsed         time.Duration `json:"elapsed"`
+	NsPerPacket     float64       `json:"nsPerPacket"`
+	AllocsPerPacket float64       `json:"allocsPerPacket"`
+	BytesPerPacket  float64       `json:"bytesPerPacket"`
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("%s: %d packets, %.1f ns/packet, %.2f allocs/packet, %.1f B/packet",
+		r.Name, r.NumPackets, r.NsPerPacket, r.AllocsPerPacket, r.BytesPerPacket)
+}
+
+// Run feeds b.N packets of the load to fn, reporting allocations and the RTP throughput.
+func Run(b *testing.B, load *Load, fn func(p Packet)) {
+	b.ReportAllocs()
+	b.SetBytes(int64(load.Params().PacketSize))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn(load.Next())
+	}
+}
+
+// Measure feeds numPackets packets of the load to fn, for regression checks outside of go test.
+// Allocations are process wide, other goroutines allocating make the result noisy.
+func Measure(name string, load *Load, numPackets int, fn func(p Packet)) Result {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < numPackets; i++ {
+		fn(load.Next())
+	}
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	r := Result{
+		Name:       name,
+		NumPackets: numPackets,
+		Elapsed:    elapsed,
+	}
+	if numPackets != 0 {
+		r.NsPerPacket = float64(elapsed.Nanoseconds()) / float64(numPackets)
+		r.AllocsPerPacket = float64(after.Mallocs-before.Mallocs) / float64(numPackets)
+		r.BytesPerPacket = float64(after.TotalAlloc-before.TotalAlloc) / float64(numPackets)
+	}
+	return r
+}
+
+// ------------------------------------------------
+
+// Baseline holds results by name to compare new results against, stored as JSON.
+type Baseline map[string]Result
+
+func ReadBaseline(r io.Reader) (Baseline, error) {
+	var results []Result
+	if err := json.NewDecoder(r).Decode(&results); err != nil {
+		return nil, err
+	}
+	b := make(Baseline, len(results))
+	for _, result := range results {
+		b[result.Name] = result
+	}
+	return b, nil
+}
+
+func (b Baseline) Write(w io.Writer) error {
+	results := make([]Result, 0, len(b))
+	for _, name := range b.names() {
+		results = append(results, b[name])
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(results)
+}
+
+func (b Baseline) Add(r Result) {
+	b[r.Name] = r
+}
+
+// Compare returns ErrRegression if the result is more than tolerance (e.g. 0.1 for 10%) slower than the baseline,
+// or allocates more per packet, rounded to whole allocations.
+func (b Baseline) Compare(r Result, tolerance float64) error {
+	baseline, ok := b[r.Name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoBaseline, r.Name)
+	}
+	if r.NsPerPacket > baseline.NsPerPacket*(1+tolerance) {
+		return fmt.Errorf("%w: %s: %.1f ns/packet, baseline %.1f ns/packet", ErrRegression, r.Name, r.NsPerPacket, baseline.NsPerPacket)
+	}
+	if int(r.AllocsPerPacket+0.5) > int(baseline.AllocsPerPacket+0.5) {
+		return fmt.Errorf("%w: %s: %.2f allocs/packet, baseline %.2f allocs/packet", ErrRegression, r.Name, r.AllocsPerPacket, baseline.AllocsPerPacket)
+	}
+	return nil
+}
+
+func (b Baseline) names() []string {
+	names := make([]string, 0, len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+	"github.com/livekit/mediatransportutil/pkg/fec"
+	"github.com/livekit/mediatransportutil/pkg/nack"
+	"github.com/livekit/mediatransportutil/pkg/ratelimit"
+	"github.com/livekit/mediatransportutil/pkg/twcc"
+)
+
+const (
+	bucketCapacity = 500
+	// NACKs of a stream are sent every this many packets
+	nackPairsInterval = 10
+)
+
+// Primitive is a standardized benchmark of a primitive of this module.
+type Primitive struct {
+	Name string
+	// New returns a handler of the packets of a load with fresh state, called once per run with defaults applied
+	// to the params
+	New func(params LoadParams) func(p Packet)
+}
+
+// Primitives returns the standard suite, run by RunPrimitives and MeasurePrimitives.
+func Primitives() []Primitive {
+	return []Primitive{
+		{Name: "bucket", New: newBucketHandler},
+		{Name: "nack_queue", New: newNackQueueHandler},
+		{Name: "twcc_responder", New: newTWCCResponderHandler},
+		{Name: "loss_pattern", New: newLossPatternHandler},
+		{Name: "token_bucket", New: newTokenBucketHandler},
+	}
+}
+
+// RunPrimitives runs the standard suite as sub-benchmarks, e.g. from a benchmark of a downstream fork:
+//
+//	func BenchmarkPrimitives(b *testing.B) {
+//		bench.RunPrimitives(b, bench.LoadParamsDefault)
+//	}
+func RunPrimitives(b *testing.B, params LoadParams) {
+	params = params.withDefaults()
+	for _, p := range Primitives() {
+		p := p
+		b.Run(p.Name, func(b *testing.B) {
+			Run(b, NewLoad(params), p.New(params))
+		})
+	}
+}
+
+// MeasurePrimitives measures the standard suite, to compare to a Baseline.
+func MeasurePrimitives(params LoadParams, numPackets int) []Result {
+	params = params.withDefaults()
+	var results []Result
+	for _, p := range Primitives() {
+		results = append(results, Measure(p.Name, NewLoad(params), numPackets, p.New(params)))
+	}
+	return results
+}
+
+// ------------------------------------------------
+
+func newBucketHandler(params LoadParams) func(p Packet) {
+	buckets := make([]*bucket.Bucket[uint16], params.NumStreams)
+	for i := range buckets {
+		buckets[i] = bucket.NewBucket[uint16](bucketCapacity)
+	}
+	return func(p Packet) {
+		if !p.Lost {
+			_, _ = buckets[p.Stream].AddPacket(p.Data)
+		}
+	}
+}
+
+func newNackQueueHandler(params LoadParams) func(p Packet) {
+	queues := make([]*nack.NackQueue, params.NumStreams)
+	for i := range queues {
+		queues[i] = nack.NewNACKQueue(nack.NackQueueParamsDefault)
+	}
+	return func(p Packet) {
+		q := queues[p.Stream]
+		if p.Lost {
+			q.Push(p.Header.SequenceNumber)
+		}
+		if p.Header.SequenceNumber%nackPairsInterval == 0 {
+			q.Pairs()
+			q.PopGivenUp()
+		}
+	}
+}
+
+func newTWCCResponderHandler(_ LoadParams) func(p Packet) {
+	r := twcc.NewTransportWideCCResponder()
+	r.OnFeedback(func(_ []rtcp.Packet) {})
+	return func(p Packet) {
+		if !p.Lost {
+			r.Push(p.Header.SSRC, p.TransportSequenceNumber, p.SendTime.Nanoseconds(), p.Header.Marker)
+		}
+	}
+}
+
+func newLossPatternHandler(_ LoadParams) func(p Packet) {
+	a := fec.NewLossPatternAnalyzer(fec.LossPatternAnalyzerParams{})
+	return func(p Packet) {
+		a.OnPacket(!p.Lost)
+	}
+}
+
+func newTokenBucketHandler(params LoadParams) func(p Packet) {
+	buckets := make([]ratelimit.TokenBucket, params.NumStreams)
+	for i := range buckets {
+		// bytes at the stream bitrate with a 100ms burst
+		buckets[i] = ratelimit.NewTokenBucket(float64(params.Bitrate)/8, float64(params.Bitrate)/80)
+	}
+	start := time.Now()
+	return func(p Packet) {
+		buckets[p.Stream].AllowAt(start.Add(p.SendTime), float64(len(p.Data)))
+	}
+}
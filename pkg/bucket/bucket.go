@@ -70,6 +70,24 @@ func (b *Bucket[T]) Grow() int {
 	return b.maxSteps
 }
 
+// Shrink releases one growth step of slots, retaining the most recent packets.
+// Capacity does not go below the initial capacity.
+func (b *Bucket[T]) Shrink() int {
+	newMaxSteps := b.maxSteps - b.initCapacity
+	if newMaxSteps < b.initCapacity {
+		return b.maxSteps
+	}
+
+	shrunkSlots := createSlots(newMaxSteps)
+	for i := 0; i < newMaxSteps; i++ {
+		copy(shrunkSlots[i], b.slots[b.wrap(b.step-newMaxSteps+i)])
+	}
+	b.slots = shrunkSlots
+	b.maxSteps = newMaxSteps
+	b.step = 0
+	return b.maxSteps
+}
+
 func (b *Bucket[T]) ResyncOnNextPacket() {
 	b.resyncOnNextPacket = true
 }
@@ -82,6 +100,11 @@ func (b *Bucket[T]) Capacity() int {
 	return b.maxSteps
 }
 
+// MemoryUsage returns the number of bytes held by packet slots.
+func (b *Bucket[T]) MemoryUsage() int {
+	return b.maxSteps * (MaxPktSize + pktSizeHeader)
+}
+
 func (b *Bucket[T]) addPacket(pkt []byte, sn T) ([]byte, error) {
 	if len(pkt) > MaxPktSize-pktSizeHeader {
 		return nil, ErrPacketTooLarge
@@ -629,3 +629,53 @@ func TestQueueGrow(t *testing.T) {
 	_, err = q.GetPacket(buf, 127)
 	require.NoError(t, err)
 }
+
+func TestQueueShrink(t *testing.T) {
+	q := NewBucket[uint16](4)
+	require.Equal(t, 4, q.Shrink())
+	require.Equal(t, 8, q.Grow())
+	require.Equal(t, 8*(MaxPktSize+pktSizeHeader), q.MemoryUsage())
+
+	for sn := uint16(1); sn <= 7; sn++ {
+		np := rtp.Packet{
+			Header: rtp.Header{
+				SequenceNumber: sn,
+			},
+			Payload: []byte{byte(sn)},
+		}
+		pbuf, err := np.Marshal()
+		require.NoError(t, err)
+		_, err = q.AddPacket(pbuf)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, 4, q.Shrink())
+	require.Equal(t, 4*(MaxPktSize+pktSizeHeader), q.MemoryUsage())
+
+	// most recent packets should be retained
+	buf := make([]byte, MaxPktSize)
+	for _, sn := range []uint16{7, 6, 5, 4} {
+		i, err := q.GetPacket(buf, sn)
+		require.NoError(t, err)
+		require.Equal(t, sn, uint16(buf[i-1]))
+	}
+	_, err := q.GetPacket(buf, 3)
+	require.ErrorIs(t, err, ErrPacketTooOld)
+
+	// adding after shrink continues from head
+	np := rtp.Packet{
+		Header: rtp.Header{
+			SequenceNumber: 8,
+		},
+		Payload: []byte{8},
+	}
+	pbuf, err := np.Marshal()
+	require.NoError(t, err)
+	_, err = q.AddPacket(pbuf)
+	require.NoError(t, err)
+	for _, sn := range []uint16{8, 7, 6, 5} {
+		i, err := q.GetPacket(buf, sn)
+		require.NoError(t, err)
+		require.Equal(t, sn, uint16(buf[i-1]))
+	}
+}
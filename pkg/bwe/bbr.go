@@ -0,0 +1,680 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+	"github.com/livekit/mediatransportutil/pkg/rtcpfb"
+)
+
+const (
+	// 2/ln(2), smallest gain which doubles the delivery rate every round
+	bbrStartupGain = 2.885
+	bbrDrainGain   = 1 / bbrStartupGain
+	// rate based senders have no congestion window to shrink to four packets, pace at half the
+	// bottleneck bandwidth instead to drain the queue
+	bbrProbeRTTGain = 0.5
+
+	// delivery rate growth expected every round until the pipe is full
+	bbrFullBandwidthGrowth = 1.25
+	bbrFullBandwidthRounds = 3
+
+	// bytes in flight beyond this multiple of the inflight target stop probing for more
+	bbrInflightCapGain = 2
+
+	// packets are app limited when sent below this fraction of the target rate
+	bbrAppLimitedFraction = 0.8
+	bbrSendRateWindow     = 100 * time.Millisecond
+
+	// longest wait for the queue to drain before the probe RTT duration starts
+	bbrProbeRTTMaxDrain = time.Second
+
+	// sent packets without feedback are forgotten after this long
+	bbrHistoryTimeout = 10 * time.Second
+)
+
+var bbrProbeBWGains = []float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type BBRState int
+
+const (
+	BBRStateStartup BBRState = iota
+	BBRStateDrain
+	BBRStateProbeBW
+	BBRStateProbeRTT
+)
+
+func (s BBRState) String() string {
+	switch s {
+	case BBRStateStartup:
+		return "STARTUP"
+	case BBRStateDrain:
+		return "DRAIN"
+	case BBRStateProbeBW:
+		return "PROBE_BW"
+	case BBRStateProbeRTT:
+		return "PROBE_RTT"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type BBRParams struct {
+	InitialBitrate int
+	MinBitrate     int
+	MaxBitrate     int
+
+	// rounds the bottleneck bandwidth max filter spans
+	BandwidthWindowRounds int
+	// min RTT is re-probed when not refreshed for this long
+	MinRTTWindow     time.Duration
+	ProbeRTTDuration time.Duration
+}
+
+var BBRParamsDefault = BBRParams{
+	InitialBitrate:        300_000,
+	MinBitrate:            30_000,
+	MaxBitrate:            50_000_000,
+	BandwidthWindowRounds: 10,
+	MinRTTWindow:          10 * time.Second,
+	ProbeRTTDuration:      200 * time.Millisecond,
+}
+
+type BBRStats struct {
+	State      BBRState
+	PacingGain float64
+	// bottleneck bandwidth estimate in bits per second
+	BottleneckBandwidth int
+	MinRTT              time.Duration
+	TargetBitrate       int
+	PacingBitrate       int
+	BytesInFlight       int
+	Round               int64
+	NumSamples          int
+	NumLost             int
+	NumReceived         int
+}
+
+type bbrSentPacket struct {
+	size     int
+	sendTime time.Time
+
+	// delivery state when the packet was sent (draft-cheng-iccrg-delivery-rate-estimation)
+	delivered             int64
+	deliveredArrival      time.Duration
+	deliveredArrivalValid bool
+	firstSendTime         time.Time
+	isAppLimited          bool
+}
+
+type bandwidthSample struct {
+	round int64
+	bps   float64
+}
+
+// BBR is a rate based congestion controller modeled on BBR, estimating the bottleneck bandwidth
+// from delivery rate samples and the propagation delay from min RTT, driven by transport wide
+// congestion control feedback. Delivery is timed with the remote arrival times carried in the
+// feedback, which are not distorted by the feedback interval.
+type BBR struct {
+	params BBRParams
+
+	lock sync.Mutex
+
+	state      BBRState
+	pacingGain float64
+
+	history       map[int64]*bbrSentPacket
+	oldestSeq     int64
+	highestSeq    int64
+	hasSent       bool
+	bytesInFlight int
+
+	delivered             int64
+	deliveredArrival      time.Duration
+	deliveredArrivalValid bool
+	firstSendTime         time.Time
+
+	// send rate of the last window, to detect an encoder not using the target rate
+	sendWindowStart time.Time
+	sendWindowBytes int
+	// lowest target bitrate of the window, a rising target is not used until the encoder catches up
+	sendWindowTarget int
+	sendRate         float64
+	isAppLimited     bool
+
+	round              int64
+	nextRoundDelivered int64
+
+	bandwidthSamples ring.Deque[bandwidthSample]
+	// most recent delivery rate, what a congestion window would limit sending to
+	deliveryRate float64
+
+	minRTT      time.Duration
+	minRTTStamp time.Time
+
+	lastFeedback time.Time
+	// smoothed time between feedback packets, packets arrived but not yet reported are still in flight
+	feedbackInterval time.Duration
+
+	fullBandwidth      float64
+	fullBandwidthCount int
+	isPipeFull         bool
+
+	cycleIndex int
+	cycleStamp time.Time
+
+	probeRTTStart time.Time
+	probeRTTDone  time.Time
+
+	targetBitrate int
+	pacingBitrate int
+	onRateChange  func(targetBitrate int, pacingBitrate int)
+
+	numSamples  int
+	numLost     int
+	numReceived int
+}
+
+var _ CongestionControl = (*BBR)(nil)
+
+func NewBBR(params BBRParams) *BBR {
+	if params.InitialBitrate == 0 {
+		params.InitialBitrate = BBRParamsDefault.InitialBitrate
+	}
+	if params.MinBitrate == 0 {
+		params.MinBitrate = BBRParamsDefault.MinBitrate
+	}
+	if params.MaxBitrate == 0 {
+		params.MaxBitrate = BBRParamsDefault.MaxBitrate
+	}
+	if params.BandwidthWindowRounds == 0 {
+		params.BandwidthWindowRounds = BBRParamsDefault.BandwidthWindowRounds
+	}
+	if params.MinRTTWindow == 0 {
+		params.MinRTTWindow = BBRParamsDefault.MinRTTWindow
+	}
+	if params.ProbeRTTDuration == 0 {
+		params.ProbeRTTDuration = BBRParamsDefault.ProbeRTTDuration
+	}
+
+	b := &BBR{
+		params:     params,
+		state:      BBRStateStartup,
+		pacingGain: bbrStartupGain,
+		history:    make(map[int64]*bbrSentPacket),
+	}
+	b.updateRatesLocked()
+	return b
+}
+
+// OnRateChange sets the callback called with the new target and pacing bitrates after feedback changes them.
+func (b *BBR) OnRateChange(f func(targetBitrate int, pacingBitrate int)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.onRateChange = f
+}
+
+// OnPacketSent records a packet leaving the pacer with its transport wide sequence number and size in bytes.
+func (b *BBR) OnPacketSent(sn uint16, size int) {
+	b.onPacketSent(sn, size, time.Now())
+}
+
+func (b *BBR) onPacketSent(sn uint16, size int, now time.Time) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	seq := int64(sn)
+	if b.hasSent {
+		seq = b.unwrapLocked(sn)
+		if seq <= b.highestSeq {
+			// retransmissions get a new sequence number, anything else is a duplicate
+			return
+		}
+	} else {
+		b.oldestSeq = seq
+		b.hasSent = true
+	}
+	b.highestSeq = seq
+
+	b.updateSendRateLocked(size, now)
+
+	if b.bytesInFlight == 0 {
+		// a new flight after idle, the delivery clock restarts
+		b.firstSendTime = now
+		b.deliveredArrivalValid = false
+	}
+	b.history[seq] = &bbrSentPacket{
+		size:                  size,
+		sendTime:              now,
+		delivered:             b.delivered,
+		deliveredArrival:      b.deliveredArrival,
+		deliveredArrivalValid: b.deliveredArrivalValid,
+		firstSendTime:         b.firstSendTime,
+		isAppLimited:          b.isAppLimited,
+	}
+	b.bytesInFlight += size
+
+	b.pruneHistoryLocked(now)
+}
+
+// OnFeedback updates the estimate with transport wide congestion control feedback.
+func (b *BBR) OnFeedback(twcc *rtcp.TransportLayerCC) error {
+	packets, err := rtcpfb.ParseTWCC(twcc)
+	if err != nil {
+		return err
+	}
+	b.onFeedback(packets, time.Now())
+	return nil
+}
+
+func (b *BBR) onFeedback(packets []rtcpfb.TWCCPacketStatus, now time.Time) {
+	b.lock.Lock()
+
+	if !b.hasSent {
+		b.lock.Unlock()
+		return
+	}
+
+	b.updateFeedbackIntervalLocked(now)
+
+	// feedback is sent after the last reported arrival, the time packets waited for it is not path delay
+	var lastArrival time.Duration
+	for _, p := range packets {
+		if p.Received && p.Arrival > lastArrival {
+			lastArrival = p.Arrival
+		}
+	}
+
+	var sample *bbrSentPacket
+	var sampleArrival time.Duration
+	isRoundStart := false
+	rtt := time.Duration(math.MaxInt64)
+	for _, p := range packets {
+		seq := b.unwrapLocked(p.SequenceNumber)
+		sent, ok := b.history[seq]
+		if !ok {
+			continue
+		}
+		delete(b.history, seq)
+		b.bytesInFlight -= sent.size
+
+		if !p.Received {
+			b.numLost++
+			continue
+		}
+		b.numReceived++
+
+		b.delivered += int64(sent.size)
+		if !b.deliveredArrivalValid || p.Arrival > b.deliveredArrival {
+			b.deliveredArrival = p.Arrival
+			b.deliveredArrivalValid = true
+		}
+
+		if sample == nil || sent.delivered >= sample.delivered {
+			sample = sent
+			sampleArrival = p.Arrival
+			b.firstSendTime = sent.sendTime
+		}
+
+		if sent.delivered >= b.nextRoundDelivered {
+			b.nextRoundDelivered = b.delivered
+			b.round++
+			isRoundStart = true
+		}
+
+		if packetRTT := now.Sub(sent.sendTime) - (lastArrival - p.Arrival); packetRTT > 0 && packetRTT < rtt {
+			rtt = packetRTT
+		}
+	}
+
+	isMinRTTExpired := !b.minRTTStamp.IsZero() && now.Sub(b.minRTTStamp) > b.params.MinRTTWindow
+	if rtt != time.Duration(math.MaxInt64) {
+		b.updateMinRTTLocked(rtt, now, isMinRTTExpired)
+	}
+
+	if sample != nil {
+		b.updateBandwidthLocked(sample, sampleArrival)
+	}
+	b.updateStateLocked(now, isRoundStart, sample != nil && sample.isAppLimited, isMinRTTExpired)
+
+	changed := b.updateRatesLocked()
+	onRateChange, targetBitrate, pacingBitrate := b.onRateChange, b.targetBitrate, b.pacingBitrate
+	b.lock.Unlock()
+
+	if changed && onRateChange != nil {
+		onRateChange(targetBitrate, pacingBitrate)
+	}
+}
+
+// OnRTT adds an RTT measured outside of feedback, for example from RTCP receiver reports.
+func (b *BBR) OnRTT(rtt time.Duration) {
+	b.onRTT(rtt, time.Now())
+}
+
+func (b *BBR) onRTT(rtt time.Duration, now time.Time) {
+	if rtt <= 0 {
+		return
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.updateMinRTTLocked(rtt, now, !b.minRTTStamp.IsZero() && now.Sub(b.minRTTStamp) > b.params.MinRTTWindow)
+}
+
+func (b *BBR) TargetBitrate() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.targetBitrate
+}
+
+func (b *BBR) PacingBitrate() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.pacingBitrate
+}
+
+func (b *BBR) State() BBRState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+func (b *BBR) Stats() BBRStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return BBRStats{
+		State:               b.state,
+		PacingGain:          b.pacingGain,
+		BottleneckBandwidth: int(b.bandwidthLocked()),
+		MinRTT:              b.minRTT,
+		TargetBitrate:       b.targetBitrate,
+		PacingBitrate:       b.pacingBitrate,
+		BytesInFlight:       b.bytesInFlight,
+		Round:               b.round,
+		NumSamples:          b.numSamples,
+		NumLost:             b.numLost,
+		NumReceived:         b.numReceived,
+	}
+}
+
+// ExportState returns the Stats as CongestionControl state.
+func (b *BBR) ExportState() map[string]interface{} {
+	stats := b.Stats()
+	return map[string]interface{}{
+		"state":               stats.State.String(),
+		"pacingGain":          stats.PacingGain,
+		"bottleneckBandwidth": stats.BottleneckBandwidth,
+		"minRTT":              float64(stats.MinRTT.Microseconds()) / 1000.0,
+		"targetBitrate":       stats.TargetBitrate,
+		"pacingBitrate":       stats.PacingBitrate,
+		"bytesInFlight":       stats.BytesInFlight,
+		"round":               stats.Round,
+		"numLost":             stats.NumLost,
+		"numReceived":         stats.NumReceived,
+	}
+}
+
+func (b *BBR) unwrapLocked(sn uint16) int64 {
+	return b.highestSeq + int64(int16(sn-uint16(b.highestSeq)))
+}
+
+func (b *BBR) updateSendRateLocked(size int, now time.Time) {
+	if b.sendWindowStart.IsZero() {
+		b.sendWindowStart = now
+		b.sendWindowTarget = b.targetBitrate
+	}
+	b.sendWindowBytes += size
+	if b.targetBitrate < b.sendWindowTarget {
+		b.sendWindowTarget = b.targetBitrate
+	}
+
+	elapsed := now.Sub(b.sendWindowStart)
+	if elapsed < bbrSendRateWindow {
+		return
+	}
+	b.sendRate = float64(b.sendWindowBytes*8) / elapsed.Seconds()
+	b.isAppLimited = b.sendRate < bbrAppLimitedFraction*float64(b.sendWindowTarget)
+	b.sendWindowStart = now
+	b.sendWindowBytes = 0
+	b.sendWindowTarget = b.targetBitrate
+}
+
+func (b *BBR) pruneHistoryLocked(now time.Time) {
+	for ; b.oldestSeq < b.highestSeq; b.oldestSeq++ {
+		sent, ok := b.history[b.oldestSeq]
+		if !ok {
+			continue
+		}
+		if now.Sub(sent.sendTime) < bbrHistoryTimeout {
+			return
+		}
+		// feedback lost, count as neither delivered nor in flight
+		delete(b.history, b.oldestSeq)
+		b.bytesInFlight -= sent.size
+	}
+}
+
+func (b *BBR) updateFeedbackIntervalLocked(now time.Time) {
+	if !b.lastFeedback.IsZero() {
+		interval := now.Sub(b.lastFeedback)
+		if b.feedbackInterval == 0 {
+			b.feedbackInterval = interval
+		} else {
+			b.feedbackInterval += (interval - b.feedbackInterval) / 8
+		}
+	}
+	b.lastFeedback = now
+}
+
+func (b *BBR) updateMinRTTLocked(rtt time.Duration, now time.Time, isExpired bool) {
+	if b.minRTT == 0 || rtt <= b.minRTT || isExpired {
+		b.minRTT = rtt
+		b.minRTTStamp = now
+	}
+}
+
+func (b *BBR) updateBandwidthLocked(sample *bbrSentPacket, sampleArrival time.Duration) {
+	if !sample.deliveredArrivalValid {
+		return
+	}
+
+	// the slower of the send and ack rates, a burst of feedback or of sends does not inflate it
+	sendElapsed := sample.sendTime.Sub(sample.firstSendTime)
+	ackElapsed := sampleArrival - sample.deliveredArrival
+	interval := sendElapsed
+	if ackElapsed > interval {
+		interval = ackElapsed
+	}
+	if interval <= 0 {
+		return
+	}
+
+	bps := float64((b.delivered-sample.delivered)*8) / interval.Seconds()
+	if sample.isAppLimited && bps < b.bandwidthLocked() {
+		return
+	}
+	b.numSamples++
+	b.deliveryRate = bps
+
+	// windowed max filter, samples kept in decreasing order of rate
+	for b.bandwidthSamples.Len() != 0 && b.bandwidthSamples.Front().round <= b.round-int64(b.params.BandwidthWindowRounds) {
+		b.bandwidthSamples.PopFront()
+	}
+	for b.bandwidthSamples.Len() != 0 && b.bandwidthSamples.Back().bps <= bps {
+		b.bandwidthSamples.PopBack()
+	}
+	b.bandwidthSamples.PushBack(bandwidthSample{round: b.round, bps: bps})
+}
+
+// bandwidthLocked returns the bottleneck bandwidth estimate, the initial bitrate until measured
+func (b *BBR) bandwidthLocked() float64 {
+	if b.bandwidthSamples.Len() == 0 {
+		return float64(b.params.InitialBitrate)
+	}
+	return b.bandwidthSamples.Front().bps
+}
+
+// deliveryBandwidthLocked returns the bottleneck bandwidth, or the latest delivery rate if lower,
+// the rate which drains queues after the path slowed down and before the max filter forgets it
+func (b *BBR) deliveryBandwidthLocked() float64 {
+	bandwidth := b.bandwidthLocked()
+	if b.deliveryRate != 0 && b.deliveryRate < bandwidth {
+		return b.deliveryRate
+	}
+	return bandwidth
+}
+
+// inflightTargetLocked returns the bandwidth delay product in bytes, including the
+// packets which are delivered but wait for the next feedback
+func (b *BBR) inflightTargetLocked() int {
+	return int(b.deliveryBandwidthLocked() / 8 * (b.minRTT + b.feedbackInterval).Seconds())
+}
+
+func (b *BBR) updateStateLocked(now time.Time, isRoundStart bool, isAppLimited bool, isMinRTTExpired bool) {
+	if isRoundStart && !isAppLimited && !b.isPipeFull && b.bandwidthSamples.Len() != 0 {
+		bandwidth := b.bandwidthLocked()
+		if bandwidth >= b.fullBandwidth*bbrFullBandwidthGrowth {
+			b.fullBandwidth = bandwidth
+			b.fullBandwidthCount = 0
+		} else {
+			b.fullBandwidthCount++
+			b.isPipeFull = b.fullBandwidthCount >= bbrFullBandwidthRounds
+		}
+	}
+
+	switch b.state {
+	case BBRStateStartup:
+		if b.isPipeFull {
+			b.setStateLocked(BBRStateDrain, bbrDrainGain)
+		}
+
+	case BBRStateDrain:
+		if b.bytesInFlight <= b.inflightTargetLocked() {
+			b.enterProbeBWLocked(now)
+		}
+
+	case BBRStateProbeBW:
+		elapsed := now.Sub(b.cycleStamp)
+		switch {
+		case elapsed > b.minRTT:
+			b.advanceCycleLocked(now)
+		case b.pacingGain < 1 && b.bytesInFlight <= b.inflightTargetLocked():
+			// queue drained before the phase ended
+			b.advanceCycleLocked(now)
+		}
+
+	case BBRStateProbeRTT:
+		if b.probeRTTDone.IsZero() {
+			// the probe lasts ProbeRTTDuration once the queue drained
+			if b.bytesInFlight <= b.inflightTargetLocked() || now.Sub(b.probeRTTStart) >= bbrProbeRTTMaxDrain {
+				b.probeRTTDone = now.Add(b.params.ProbeRTTDuration)
+			}
+		} else if !now.Before(b.probeRTTDone) {
+			b.minRTTStamp = now
+			if b.isPipeFull {
+				b.enterProbeBWLocked(now)
+			} else {
+				b.setStateLocked(BBRStateStartup, bbrStartupGain)
+			}
+		}
+	}
+
+	if isMinRTTExpired && b.state != BBRStateProbeRTT {
+		b.setStateLocked(BBRStateProbeRTT, bbrProbeRTTGain)
+		b.probeRTTStart = now
+		b.probeRTTDone = time.Time{}
+	}
+}
+
+func (b *BBR) setStateLocked(state BBRState, pacingGain float64) {
+	b.state = state
+	b.pacingGain = pacingGain
+}
+
+func (b *BBR) enterProbeBWLocked(now time.Time) {
+	// random phase other than the drain phase, so flows sharing a bottleneck do not probe in sync
+	b.cycleIndex = rand.Intn(len(bbrProbeBWGains) - 1)
+	if b.cycleIndex >= 1 {
+		b.cycleIndex++
+	}
+	b.cycleStamp = now
+	b.setStateLocked(BBRStateProbeBW, bbrProbeBWGains[b.cycleIndex])
+}
+
+func (b *BBR) advanceCycleLocked(now time.Time) {
+	b.cycleIndex = (b.cycleIndex + 1) % len(bbrProbeBWGains)
+	b.cycleStamp = now
+	b.pacingGain = bbrProbeBWGains[b.cycleIndex]
+}
+
+// updateRatesLocked returns true if the target or pacing bitrate changed
+func (b *BBR) updateRatesLocked() bool {
+	bandwidth := b.bandwidthLocked()
+
+	// the encoder is the only source of data, it has to produce the higher rate while the bandwidth
+	// is probed in startup and stay under it while queues drain, the pacer follows the gain cycle
+	pacingGain := b.pacingGain
+	if pacingGain < 1 {
+		bandwidth = b.deliveryBandwidthLocked()
+	}
+	if b.minRTT != 0 && b.bytesInFlight >= bbrInflightCapGain*b.inflightTargetLocked() {
+		// without a congestion window the queue is bounded by sending no faster than packets are
+		// delivered while it is long
+		bandwidth = b.deliveryBandwidthLocked()
+		if pacingGain > 1 {
+			pacingGain = 1
+		}
+	}
+	target := bandwidth
+	switch b.state {
+	case BBRStateStartup, BBRStateDrain, BBRStateProbeRTT:
+		target = pacingGain * bandwidth
+	}
+
+	targetBitrate := b.clampBitrate(target)
+	pacingBitrate := b.clampBitrate(pacingGain * bandwidth)
+	if targetBitrate == b.targetBitrate && pacingBitrate == b.pacingBitrate {
+		return false
+	}
+	b.targetBitrate = targetBitrate
+	b.pacingBitrate = pacingBitrate
+	return true
+}
+
+func (b *BBR) clampBitrate(bitrate float64) int {
+	switch {
+	case bitrate < float64(b.params.MinBitrate):
+		return b.params.MinBitrate
+	case bitrate > float64(b.params.MaxBitrate):
+		return b.params.MaxBitrate
+	default:
+		return int(bitrate)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcpfb"
+)
+
+const testPacketSize = 1200
+
+type testArrival struct {
+	sn      uint16
+	arrival time.Time
+}
+
+// testLink is a bottleneck of fixed capacity and one way delay with an unbounded queue,
+// the sender sends at the target bitrate and gets feedback every feedbackInterval
+type testLink struct {
+	capacity         int
+	delay            time.Duration
+	feedbackInterval time.Duration
+
+	start        time.Time
+	now          time.Time
+	sn           uint16
+	budget       float64
+	linkFree     time.Time
+	inFlight     []testArrival
+	pending      []testArrival
+	lastFeedback time.Time
+}
+
+func newTestLink(capacity int, delay time.Duration) *testLink {
+	start := time.Now()
+	return &testLink{
+		capacity:         capacity,
+		delay:            delay,
+		feedbackInterval: 50 * time.Millisecond,
+		start:            start,
+		now:              start,
+		linkFree:         start,
+		lastFeedback:     start,
+	}
+}
+
+func (l *testLink) run(t *testing.T, b *BBR, duration time.Duration) {
+	const step = 5 * time.Millisecond
+	for end := l.now.Add(duration); l.now.Before(end); l.now = l.now.Add(step) {
+		l.budget += float64(b.TargetBitrate()) / 8 * step.Seconds()
+		for l.budget >= testPacketSize {
+			l.budget -= testPacketSize
+			b.onPacketSent(l.sn, testPacketSize, l.now)
+
+			departure := l.now.Add(l.delay)
+			if l.linkFree.After(departure) {
+				departure = l.linkFree
+			}
+			l.linkFree = departure.Add(time.Duration(float64(testPacketSize*8) / float64(l.capacity) * float64(time.Second)))
+			l.inFlight = append(l.inFlight, testArrival{sn: l.sn, arrival: l.linkFree})
+			l.sn++
+		}
+
+		// packets arrived at the receiver
+		for len(l.inFlight) != 0 && !l.inFlight[0].arrival.After(l.now) {
+			l.pending = append(l.pending, l.inFlight[0])
+			l.inFlight = l.inFlight[1:]
+		}
+
+		if l.now.Sub(l.lastFeedback) >= l.feedbackInterval && len(l.pending) != 0 {
+			l.lastFeedback = l.now
+			var packets []rtcpfb.TWCCPacketStatus
+			for _, a := range l.pending {
+				packets = append(packets, rtcpfb.TWCCPacketStatus{
+					SequenceNumber: a.sn,
+					Received:       true,
+					Arrival:        a.arrival.Sub(l.start),
+				})
+			}
+			l.pending = nil
+			// feedback takes the one way delay back, delivered instantly here with the delay added to the clock
+			b.onFeedback(packets, l.now.Add(l.delay))
+		}
+	}
+}
+
+func TestBBRConverges(t *testing.T) {
+	b := NewBBR(BBRParams{})
+	var numRateChanges int
+	b.OnRateChange(func(targetBitrate int, pacingBitrate int) {
+		numRateChanges++
+	})
+
+	link := newTestLink(2_000_000, 50*time.Millisecond)
+	link.run(t, b, 10*time.Second)
+
+	stats := b.Stats()
+	require.Equal(t, BBRStateProbeBW, stats.State)
+	require.InEpsilon(t, 2_000_000, stats.BottleneckBandwidth, 0.15)
+	require.InEpsilon(t, 2_000_000, stats.TargetBitrate, 0.15)
+	require.GreaterOrEqual(t, stats.MinRTT, 100*time.Millisecond)
+	require.Less(t, stats.MinRTT, 200*time.Millisecond)
+	require.Greater(t, stats.Round, int64(10))
+	require.NotZero(t, numRateChanges)
+
+	// capacity drops, the estimate follows once the max filter window passes
+	link.capacity = 1_000_000
+	link.run(t, b, 10*time.Second)
+	stats = b.Stats()
+	require.InEpsilon(t, 1_000_000, stats.BottleneckBandwidth, 0.2)
+	require.Zero(t, stats.NumLost)
+}
+
+func TestBBRProbeRTT(t *testing.T) {
+	b := NewBBR(BBRParams{})
+	now := time.Now()
+	b.onRTT(100*time.Millisecond, now)
+
+	// higher RTTs do not replace the min until it expires
+	now = now.Add(5 * time.Second)
+	b.onRTT(150*time.Millisecond, now)
+	require.Equal(t, 100*time.Millisecond, b.Stats().MinRTT)
+
+	b.onPacketSent(0, testPacketSize, now)
+	now = now.Add(6 * time.Second)
+	b.onFeedback([]rtcpfb.TWCCPacketStatus{{SequenceNumber: 0, Received: true}}, now)
+	stats := b.Stats()
+	require.Equal(t, BBRStateProbeRTT, stats.State)
+	require.Equal(t, bbrProbeRTTGain, stats.PacingGain)
+
+	// probe duration starts once nothing is queued, the pipe was never full so back to startup after it
+	b.onPacketSent(1, testPacketSize, now)
+	b.onFeedback([]rtcpfb.TWCCPacketStatus{{SequenceNumber: 1}}, now)
+	require.Equal(t, BBRStateProbeRTT, b.State())
+
+	b.onPacketSent(2, testPacketSize, now)
+	now = now.Add(BBRParamsDefault.ProbeRTTDuration)
+	b.onFeedback([]rtcpfb.TWCCPacketStatus{{SequenceNumber: 2, Received: true}}, now)
+	stats = b.Stats()
+	require.Equal(t, BBRStateStartup, stats.State)
+	require.Equal(t, 1, stats.NumLost)
+	require.Zero(t, stats.BytesInFlight)
+}
+
+func TestBBRSequenceWrap(t *testing.T) {
+	b := NewBBR(BBRParams{})
+	now := time.Now()
+	for sn := uint16(65530); sn != 6; sn++ {
+		b.onPacketSent(sn, testPacketSize, now)
+	}
+	// duplicate
+	b.onPacketSent(65535, testPacketSize, now)
+	require.Equal(t, 12*testPacketSize, b.Stats().BytesInFlight)
+
+	var packets []rtcpfb.TWCCPacketStatus
+	for sn := uint16(65530); sn != 6; sn++ {
+		packets = append(packets, rtcpfb.TWCCPacketStatus{SequenceNumber: sn, Received: true, Arrival: time.Millisecond})
+	}
+	b.onFeedback(packets, now.Add(100*time.Millisecond))
+	stats := b.Stats()
+	require.Zero(t, stats.BytesInFlight)
+	require.Equal(t, 12, stats.NumReceived)
+	require.Equal(t, 100*time.Millisecond, stats.MinRTT)
+}
+
+func TestBBRClamp(t *testing.T) {
+	b := NewBBR(BBRParams{InitialBitrate: 1_000_000, MaxBitrate: 2_000_000})
+	require.Equal(t, 2_000_000, b.TargetBitrate())
+	require.Equal(t, 2_000_000, b.PacingBitrate())
+	require.Equal(t, BBRStateStartup, b.State())
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+var (
+	ErrAlgorithmRegistered = errors.New("congestion control algorithm already registered")
+)
+
+// CongestionControl is a send side congestion controller driven by transport wide congestion
+// control feedback. Implementations are run by Estimator behind the pion congestion control
+// interceptor and must be safe for concurrent use.
+type CongestionControl interface {
+	// OnPacketSent is called for each packet leaving the pacer, size is the RTP packet size in bytes
+	OnPacketSent(sn uint16, size int)
+	OnFeedback(twcc *rtcp.TransportLayerCC) error
+	// OnRTT is called with RTTs measured outside of feedback, for example from RTCP receiver reports
+	OnRTT(rtt time.Duration)
+
+	TargetBitrate() int
+	PacingBitrate() int
+	// OnRateChange sets the callback called when the target or pacing bitrate changes
+	OnRateChange(f func(targetBitrate int, pacingBitrate int))
+
+	// ExportState returns a snapshot of the controller internals for stats and logging,
+	// values are numbers, strings or booleans
+	ExportState() map[string]interface{}
+}
+
+type CongestionControlParams struct {
+	InitialBitrate int
+	MinBitrate     int
+	MaxBitrate     int
+}
+
+type CongestionControlFactory func(params CongestionControlParams) (CongestionControl, error)
+
+var (
+	registryLock sync.RWMutex
+	registry     = map[Algorithm]CongestionControlFactory{
+		AlgorithmBBR: func(params CongestionControlParams) (CongestionControl, error) {
+			return NewBBR(BBRParams{
+				InitialBitrate: params.InitialBitrate,
+				MinBitrate:     params.MinBitrate,
+				MaxBitrate:     params.MaxBitrate,
+			}), nil
+		},
+	}
+)
+
+// RegisterCongestionControl makes a controller selectable by name in Config.Algorithm,
+// typically from the init function of the package implementing it.
+func RegisterCongestionControl(algorithm Algorithm, factory CongestionControlFactory) error {
+	if algorithm == "" || algorithm == AlgorithmGCC || factory == nil {
+		return fmt.Errorf("invalid congestion control registration %q", algorithm)
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if _, ok := registry[algorithm]; ok {
+		return fmt.Errorf("%w: %s", ErrAlgorithmRegistered, algorithm)
+	}
+	registry[algorithm] = factory
+	return nil
+}
+
+// RegisteredAlgorithms returns the selectable algorithms, GCC and the registered controllers.
+func RegisteredAlgorithms() []Algorithm {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	algorithms := []Algorithm{AlgorithmGCC}
+	for algorithm := range registry {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms[1:], func(i, j int) bool {
+		return algorithms[i+1] < algorithms[j+1]
+	})
+	return algorithms
+}
+
+func getCongestionControlFactory(algorithm Algorithm) (CongestionControlFactory, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	factory, ok := registry[algorithm]
+	return factory, ok
+}
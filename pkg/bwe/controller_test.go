@@ -0,0 +1,97 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+// fixedRate holds its initial bitrate and counts feedback
+type fixedRate struct {
+	bitrate      int
+	numFeedback  int
+	onRateChange func(targetBitrate int, pacingBitrate int)
+}
+
+func (f *fixedRate) OnPacketSent(sn uint16, size int) {}
+
+func (f *fixedRate) OnFeedback(twcc *rtcp.TransportLayerCC) error {
+	f.numFeedback++
+	f.bitrate /= 2
+	f.onRateChange(f.bitrate, f.bitrate)
+	return nil
+}
+
+func (f *fixedRate) OnRTT(rtt time.Duration) {}
+
+func (f *fixedRate) TargetBitrate() int { return f.bitrate }
+
+func (f *fixedRate) PacingBitrate() int { return f.bitrate }
+
+func (f *fixedRate) OnRateChange(fn func(targetBitrate int, pacingBitrate int)) {
+	f.onRateChange = fn
+}
+
+func (f *fixedRate) ExportState() map[string]interface{} {
+	return map[string]interface{}{"numFeedback": f.numFeedback}
+}
+
+func TestCongestionControlRegistry(t *testing.T) {
+	const algorithm Algorithm = "fixed"
+	t.Cleanup(func() {
+		registryLock.Lock()
+		delete(registry, algorithm)
+		registryLock.Unlock()
+	})
+	require.NoError(t, RegisterCongestionControl(algorithm, func(params CongestionControlParams) (CongestionControl, error) {
+		return &fixedRate{bitrate: params.InitialBitrate}, nil
+	}))
+	require.ErrorIs(t, RegisterCongestionControl(algorithm, func(CongestionControlParams) (CongestionControl, error) {
+		return nil, nil
+	}), ErrAlgorithmRegistered)
+	require.Error(t, RegisterCongestionControl(AlgorithmGCC, func(CongestionControlParams) (CongestionControl, error) {
+		return nil, nil
+	}))
+	require.Equal(t, []Algorithm{AlgorithmGCC, AlgorithmBBR, algorithm}, RegisteredAlgorithms())
+
+	pacer := &testPacer{writers: make(map[uint32]interceptor.RTPWriter)}
+	factory, err := NewEstimatorFactory(Config{
+		Algorithm:      algorithm,
+		InitialBitrate: 1_000_000,
+		NewPacer: func(int) gcc.Pacer {
+			return pacer
+		},
+	})
+	require.NoError(t, err)
+	estimator, err := factory()
+	require.NoError(t, err)
+	require.Equal(t, 1_000_000, pacer.targetBitrate)
+
+	var targetBitrate int
+	estimator.OnTargetBitrateChange(func(bitrate int) {
+		targetBitrate = bitrate
+	})
+	require.NoError(t, estimator.WriteRTCP([]rtcp.Packet{&rtcp.TransportLayerCC{}}, nil))
+	require.Equal(t, 500_000, targetBitrate)
+	require.Equal(t, 500_000, pacer.targetBitrate)
+	require.Equal(t, map[string]interface{}{"numFeedback": 1}, estimator.GetStats())
+	require.NoError(t, estimator.Close())
+}
@@ -0,0 +1,550 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	dumpMagic   = "BWED"
+	dumpVersion = 1
+
+	dumpRecordKey        = 1
+	dumpRecordSample     = 2
+	dumpRecordTransition = 3
+
+	dumpValueInt    = 1
+	dumpValueFloat  = 2
+	dumpValueString = 3
+	dumpValueBool   = 4
+
+	// largest string or map accepted by the decoder
+	dumpMaxLength = 1 << 16
+)
+
+var (
+	ErrInvalidDump = errors.New("invalid bandwidth estimator dump")
+)
+
+type DumpFormat int
+
+const (
+	// one JSON object per line
+	DumpFormatJSON DumpFormat = iota
+	// dictionary encoded keys and varint values, see DumpDecoder
+	DumpFormatBinary
+)
+
+func (f DumpFormat) String() string {
+	switch f {
+	case DumpFormatJSON:
+		return "json"
+	case DumpFormatBinary:
+		return "binary"
+	default:
+		return fmt.Sprintf("%d", int(f))
+	}
+}
+
+type DumpRecordType int
+
+const (
+	DumpRecordSample DumpRecordType = iota
+	DumpRecordTransition
+)
+
+func (t DumpRecordType) String() string {
+	switch t {
+	case DumpRecordSample:
+		return "sample"
+	case DumpRecordTransition:
+		return "transition"
+	default:
+		return fmt.Sprintf("%d", int(t))
+	}
+}
+
+// DumpRecord is a snapshot of estimator state or a change of its state machine state.
+// Integers decode as int64 and durations are dumped in nanoseconds.
+type DumpRecord struct {
+	Type DumpRecordType
+	Time time.Time
+
+	State map[string]interface{}
+
+	From string
+	To   string
+}
+
+type jsonDumpRecord struct {
+	Time  int64                  `json:"t"`
+	Type  string                 `json:"type"`
+	State map[string]interface{} `json:"state,omitempty"`
+	From  string                 `json:"from,omitempty"`
+	To    string                 `json:"to,omitempty"`
+}
+
+// StateSource is the estimator dumped, cc.BandwidthEstimator implementations are.
+type StateSource interface {
+	GetTargetBitrate() int
+	GetStats() map[string]interface{}
+}
+
+type StateDumperParams struct {
+	Format   DumpFormat
+	Interval time.Duration
+	// key of the state machine state in GetStats, a change is dumped as a transition
+	StateKey string
+	// dump from Start, otherwise once enabled with SetEnabled
+	Enabled bool
+}
+
+var StateDumperParamsDefault = StateDumperParams{
+	Format:   DumpFormatJSON,
+	Interval: 100 * time.Millisecond,
+	StateKey: "state",
+}
+
+// StateDumper periodically writes the state of an estimator, with its target bitrate under
+// "targetBitrate", for offline analysis. Create one per connection, it can be toggled while running.
+// Dumping stops at the first write error, returned by Err.
+type StateDumper struct {
+	params StateDumperParams
+	source StateSource
+	w      io.Writer
+
+	lock      sync.Mutex
+	enabled   bool
+	err       error
+	lastState string
+	hasState  bool
+
+	// binary encoding
+	hasHeader bool
+	keys      map[string]uint64
+	lastTime  int64
+	buf       []byte
+
+	isStopped bool
+	close     chan struct{}
+}
+
+func NewStateDumper(source StateSource, w io.Writer, params StateDumperParams) *StateDumper {
+	if params.Interval == 0 {
+		params.Interval = StateDumperParamsDefault.Interval
+	}
+	if params.StateKey == "" {
+		params.StateKey = StateDumperParamsDefault.StateKey
+	}
+	return &StateDumper{
+		params:  params,
+		source:  source,
+		w:       w,
+		enabled: params.Enabled,
+		keys:    make(map[string]uint64),
+		close:   make(chan struct{}),
+	}
+}
+
+func (d *StateDumper) Start() {
+	go d.worker()
+}
+
+func (d *StateDumper) Stop() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.isStopped {
+		return
+	}
+	d.isStopped = true
+	close(d.close)
+}
+
+func (d *StateDumper) SetEnabled(enabled bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.enabled = enabled
+	if !enabled {
+		// the first sample after enabling again does not report a transition from stale state
+		d.hasState = false
+	}
+}
+
+func (d *StateDumper) IsEnabled() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.enabled
+}
+
+func (d *StateDumper) Err() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.err
+}
+
+func (d *StateDumper) worker() {
+	ticker := time.NewTicker(d.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-d.close:
+			return
+		case now := <-ticker.C:
+			d.dump(now)
+		}
+	}
+}
+
+func (d *StateDumper) dump(now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !d.enabled || d.err != nil {
+		return
+	}
+
+	state := d.source.GetStats()
+	sample := make(map[string]interface{}, len(state)+1)
+	for key, value := range state {
+		sample[key] = dumpValue(value)
+	}
+	sample["targetBitrate"] = int64(d.source.GetTargetBitrate())
+
+	if s, ok := sample[d.params.StateKey].(string); ok {
+		if d.hasState && s != d.lastState {
+			if d.err = d.writeLocked(DumpRecord{Type: DumpRecordTransition, Time: now, From: d.lastState, To: s}); d.err != nil {
+				return
+			}
+		}
+		d.lastState = s
+		d.hasState = true
+	}
+	d.err = d.writeLocked(DumpRecord{Type: DumpRecordSample, Time: now, State: sample})
+}
+
+func (d *StateDumper) writeLocked(record DumpRecord) error {
+	var b []byte
+	switch d.params.Format {
+	case DumpFormatBinary:
+		b = d.appendBinaryLocked(d.buf[:0], record)
+		d.buf = b
+
+	default:
+		var err error
+		b, err = json.Marshal(jsonDumpRecord{
+			Time:  record.Time.UnixMicro(),
+			Type:  record.Type.String(),
+			State: record.State,
+			From:  record.From,
+			To:    record.To,
+		})
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+	}
+
+	_, err := d.w.Write(b)
+	return err
+}
+
+func (d *StateDumper) appendBinaryLocked(b []byte, record DumpRecord) []byte {
+	if !d.hasHeader {
+		b = append(b, dumpMagic...)
+		b = append(b, dumpVersion)
+		d.hasHeader = true
+	}
+
+	// keys are defined before the first sample using them, in sorted order for a stable encoding
+	keys := make([]string, 0, len(record.State))
+	for key := range record.State {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := d.keys[key]; !ok {
+			id := uint64(len(d.keys))
+			d.keys[key] = id
+			b = append(b, dumpRecordKey)
+			b = binary.AppendUvarint(b, id)
+			b = appendDumpString(b, key)
+		}
+	}
+
+	// times are deltas to the previous record in microseconds, the first one from the unix epoch
+	t := record.Time.UnixMicro()
+	switch record.Type {
+	case DumpRecordTransition:
+		b = append(b, dumpRecordTransition)
+		b = binary.AppendVarint(b, t-d.lastTime)
+		b = appendDumpString(b, record.From)
+		b = appendDumpString(b, record.To)
+
+	default:
+		b = append(b, dumpRecordSample)
+		b = binary.AppendVarint(b, t-d.lastTime)
+		b = binary.AppendUvarint(b, uint64(len(keys)))
+		for _, key := range keys {
+			b = binary.AppendUvarint(b, d.keys[key])
+			b = appendDumpValue(b, record.State[key])
+		}
+	}
+	d.lastTime = t
+	return b
+}
+
+// dumpValue normalizes a stats value to int64, float64, string or bool
+func dumpValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case time.Duration:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case bool:
+		return v
+	case string:
+		return v
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func appendDumpValue(b []byte, value interface{}) []byte {
+	switch v := value.(type) {
+	case int64:
+		b = append(b, dumpValueInt)
+		return binary.AppendVarint(b, v)
+	case float64:
+		b = append(b, dumpValueFloat)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	case bool:
+		b = append(b, dumpValueBool)
+		if v {
+			return append(b, 1)
+		}
+		return append(b, 0)
+	default:
+		b = append(b, dumpValueString)
+		return appendDumpString(b, v.(string))
+	}
+}
+
+func appendDumpString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// ------------------------------------------------
+
+// DumpDecoder reads records written by a StateDumper in DumpFormatBinary.
+type DumpDecoder struct {
+	r         *bufio.Reader
+	hasHeader bool
+	keys      []string
+	lastTime  int64
+}
+
+func NewDumpDecoder(r io.Reader) *DumpDecoder {
+	return &DumpDecoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+// Decode returns the next record, io.EOF at the end of the dump.
+func (d *DumpDecoder) Decode() (DumpRecord, error) {
+	if !d.hasHeader {
+		header := make([]byte, len(dumpMagic)+1)
+		if _, err := io.ReadFull(d.r, header); err != nil {
+			return DumpRecord{}, err
+		}
+		if string(header[:len(dumpMagic)]) != dumpMagic || header[len(dumpMagic)] != dumpVersion {
+			return DumpRecord{}, ErrInvalidDump
+		}
+		d.hasHeader = true
+	}
+
+	for {
+		typ, err := d.r.ReadByte()
+		if err != nil {
+			return DumpRecord{}, err
+		}
+
+		switch typ {
+		case dumpRecordKey:
+			id, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return DumpRecord{}, unexpectedEOF(err)
+			}
+			key, err := d.readString()
+			if err != nil {
+				return DumpRecord{}, err
+			}
+			if id != uint64(len(d.keys)) {
+				return DumpRecord{}, ErrInvalidDump
+			}
+			d.keys = append(d.keys, key)
+
+		case dumpRecordSample:
+			t, err := d.readTime()
+			if err != nil {
+				return DumpRecord{}, err
+			}
+			n, err := binary.ReadUvarint(d.r)
+			if err != nil {
+				return DumpRecord{}, unexpectedEOF(err)
+			}
+			if n > dumpMaxLength {
+				return DumpRecord{}, ErrInvalidDump
+			}
+			state := make(map[string]interface{}, n)
+			for i := uint64(0); i < n; i++ {
+				id, err := binary.ReadUvarint(d.r)
+				if err != nil {
+					return DumpRecord{}, unexpectedEOF(err)
+				}
+				if id >= uint64(len(d.keys)) {
+					return DumpRecord{}, ErrInvalidDump
+				}
+				value, err := d.readValue()
+				if err != nil {
+					return DumpRecord{}, err
+				}
+				state[d.keys[id]] = value
+			}
+			return DumpRecord{Type: DumpRecordSample, Time: t, State: state}, nil
+
+		case dumpRecordTransition:
+			t, err := d.readTime()
+			if err != nil {
+				return DumpRecord{}, err
+			}
+			from, err := d.readString()
+			if err != nil {
+				return DumpRecord{}, err
+			}
+			to, err := d.readString()
+			if err != nil {
+				return DumpRecord{}, err
+			}
+			return DumpRecord{Type: DumpRecordTransition, Time: t, From: from, To: to}, nil
+
+		default:
+			return DumpRecord{}, ErrInvalidDump
+		}
+	}
+}
+
+func (d *DumpDecoder) readTime() (time.Time, error) {
+	delta, err := binary.ReadVarint(d.r)
+	if err != nil {
+		return time.Time{}, unexpectedEOF(err)
+	}
+	d.lastTime += delta
+	return time.UnixMicro(d.lastTime), nil
+}
+
+func (d *DumpDecoder) readString() (string, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if n > dumpMaxLength {
+		return "", ErrInvalidDump
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(b), nil
+}
+
+func (d *DumpDecoder) readValue() (interface{}, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	switch typ {
+	case dumpValueInt:
+		v, err := binary.ReadVarint(d.r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return v, nil
+	case dumpValueFloat:
+		b := make([]byte, 8)
+		if _, err := io.ReadFull(d.r, b); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case dumpValueString:
+		return d.readString()
+	case dumpValueBool:
+		v, err := d.r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return v != 0, nil
+	default:
+		return nil, ErrInvalidDump
+	}
+}
+
+// unexpectedEOF reports a dump ending inside a record
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
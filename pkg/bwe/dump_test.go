@@ -0,0 +1,191 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testStateSource struct {
+	state         string
+	targetBitrate int
+}
+
+func (s *testStateSource) GetTargetBitrate() int {
+	return s.targetBitrate
+}
+
+func (s *testStateSource) GetStats() map[string]interface{} {
+	return map[string]interface{}{
+		"state":         s.state,
+		"delayEstimate": 1.5,
+		"averageLoss":   0.25,
+		"rtt":           20 * time.Millisecond,
+		"round":         uint32(7),
+		"probing":       true,
+	}
+}
+
+func runTestDump(d *StateDumper, source *testStateSource, start time.Time) {
+	d.dump(start)
+	source.state = "DRAIN"
+	source.targetBitrate = 500_000
+	d.dump(start.Add(100 * time.Millisecond))
+	d.dump(start.Add(200 * time.Millisecond))
+}
+
+func TestStateDumperJSON(t *testing.T) {
+	source := &testStateSource{state: "STARTUP", targetBitrate: 1_000_000}
+	var buf bytes.Buffer
+	d := NewStateDumper(source, &buf, StateDumperParams{Enabled: true})
+
+	start := time.UnixMicro(1_700_000_000_000_000)
+	runTestDump(d, source, start)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+
+	var record jsonDumpRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	require.Equal(t, start.UnixMicro(), record.Time)
+	require.Equal(t, "sample", record.Type)
+	require.Equal(t, map[string]interface{}{
+		"state":         "STARTUP",
+		"delayEstimate": 1.5,
+		"averageLoss":   0.25,
+		"rtt":           float64(20 * time.Millisecond),
+		"round":         float64(7),
+		"probing":       true,
+		"targetBitrate": float64(1_000_000),
+	}, record.State)
+
+	record = jsonDumpRecord{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	require.Equal(t, jsonDumpRecord{Time: start.Add(100 * time.Millisecond).UnixMicro(), Type: "transition", From: "STARTUP", To: "DRAIN"}, record)
+}
+
+func TestStateDumperBinary(t *testing.T) {
+	source := &testStateSource{state: "STARTUP", targetBitrate: 1_000_000}
+	var buf bytes.Buffer
+	d := NewStateDumper(source, &buf, StateDumperParams{Format: DumpFormatBinary, Enabled: true})
+
+	start := time.UnixMicro(1_700_000_000_000_000)
+	runTestDump(d, source, start)
+
+	var jsonBuf bytes.Buffer
+	runTestDump(NewStateDumper(&testStateSource{state: "STARTUP", targetBitrate: 1_000_000}, &jsonBuf, StateDumperParams{Enabled: true}), source, start)
+	require.Less(t, buf.Len(), jsonBuf.Len())
+
+	decoder := NewDumpDecoder(&buf)
+	var records []DumpRecord
+	for {
+		record, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	require.Len(t, records, 4)
+
+	require.Equal(t, DumpRecordSample, records[0].Type)
+	require.True(t, start.Equal(records[0].Time))
+	require.Equal(t, map[string]interface{}{
+		"state":         "STARTUP",
+		"delayEstimate": 1.5,
+		"averageLoss":   0.25,
+		"rtt":           int64(20 * time.Millisecond),
+		"round":         int64(7),
+		"probing":       true,
+		"targetBitrate": int64(1_000_000),
+	}, records[0].State)
+
+	require.Equal(t, DumpRecordTransition, records[1].Type)
+	require.True(t, start.Add(100*time.Millisecond).Equal(records[1].Time))
+	require.Equal(t, "STARTUP", records[1].From)
+	require.Equal(t, "DRAIN", records[1].To)
+
+	require.Equal(t, int64(500_000), records[2].State["targetBitrate"])
+	require.True(t, start.Add(200*time.Millisecond).Equal(records[3].Time))
+
+	_, err := NewDumpDecoder(strings.NewReader("JSON{")).Decode()
+	require.ErrorIs(t, err, ErrInvalidDump)
+	_, err = NewDumpDecoder(bytes.NewReader(append([]byte(dumpMagic), dumpVersion, dumpRecordSample))).Decode()
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+type failingWriter struct {
+	numWrites int
+}
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.numWrites++
+	return 0, errors.New("disk full")
+}
+
+func TestStateDumperToggle(t *testing.T) {
+	source := &testStateSource{state: "STARTUP"}
+	var buf bytes.Buffer
+	d := NewStateDumper(source, &buf, StateDumperParams{})
+	require.False(t, d.IsEnabled())
+
+	now := time.Now()
+	d.dump(now)
+	require.Zero(t, buf.Len())
+
+	d.SetEnabled(true)
+	d.dump(now)
+	d.SetEnabled(false)
+	source.state = "DRAIN"
+	d.dump(now)
+	d.SetEnabled(true)
+	// no transition from the state seen before the dumper was disabled
+	d.dump(now)
+	require.Equal(t, 2, strings.Count(buf.String(), "\n"))
+	require.NotContains(t, buf.String(), "transition")
+
+	w := &failingWriter{}
+	d = NewStateDumper(source, w, StateDumperParams{Enabled: true})
+	d.dump(now)
+	d.dump(now)
+	require.Error(t, d.Err())
+	require.Equal(t, 1, w.numWrites)
+}
+
+func TestStateDumperEstimator(t *testing.T) {
+	var buf bytes.Buffer
+	d := NewStateDumper(NewEstimator(NewBBR(BBRParams{}), &testPacer{}), &buf, StateDumperParams{
+		Enabled:  true,
+		Interval: 10 * time.Millisecond,
+	})
+	d.Start()
+	require.Eventually(t, func() bool {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		return strings.Count(buf.String(), "\n") >= 2
+	}, time.Second, 10*time.Millisecond)
+	d.Stop()
+	d.Stop()
+	require.NoError(t, d.Err())
+	require.Contains(t, buf.String(), `"state":"STARTUP"`)
+}
@@ -0,0 +1,237 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+const (
+	transportCCURI = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown congestion control algorithm")
+	ErrEstimatorClosed  = errors.New("bandwidth estimator closed")
+)
+
+type Algorithm string
+
+const (
+	AlgorithmGCC Algorithm = "gcc"
+	AlgorithmBBR Algorithm = "bbr"
+)
+
+// Config selects the send side bandwidth estimator, GCC or a registered CongestionControl. All share
+// the pacer and are driven by transport wide congestion control feedback.
+type Config struct {
+	// defaults to gcc, see RegisteredAlgorithms
+	Algorithm      Algorithm `yaml:"algorithm,omitempty"`
+	InitialBitrate int       `yaml:"initial_bitrate,omitempty"`
+	MinBitrate     int       `yaml:"min_bitrate,omitempty"`
+	MaxBitrate     int       `yaml:"max_bitrate,omitempty"`
+
+	// creates the pacer of each estimator, a leaky bucket pacer when not set
+	NewPacer func(initialBitrate int) gcc.Pacer `yaml:"-"`
+}
+
+func (c Config) Validate() error {
+	if c.Algorithm != "" && c.Algorithm != AlgorithmGCC {
+		if _, ok := getCongestionControlFactory(c.Algorithm); !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAlgorithm, c.Algorithm)
+		}
+	}
+	if c.MaxBitrate != 0 && c.MinBitrate > c.MaxBitrate {
+		return fmt.Errorf("min bitrate %d above max bitrate %d", c.MinBitrate, c.MaxBitrate)
+	}
+	return nil
+}
+
+// NewEstimatorFactory returns the factory to create the congestion control interceptor with, cc.NewInterceptor.
+// Algorithms other than GCC are looked up in the registry.
+func NewEstimatorFactory(conf Config) (cc.BandwidthEstimatorFactory, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	if conf.Algorithm == "" || conf.Algorithm == AlgorithmGCC {
+		return func() (cc.BandwidthEstimator, error) {
+			var opts []gcc.Option
+			if conf.InitialBitrate != 0 {
+				opts = append(opts, gcc.SendSideBWEInitialBitrate(conf.InitialBitrate))
+			}
+			if conf.MinBitrate != 0 {
+				opts = append(opts, gcc.SendSideBWEMinBitrate(conf.MinBitrate))
+			}
+			if conf.MaxBitrate != 0 {
+				opts = append(opts, gcc.SendSideBWEMaxBitrate(conf.MaxBitrate))
+			}
+			if conf.NewPacer != nil {
+				opts = append(opts, gcc.SendSideBWEPacer(conf.NewPacer(conf.InitialBitrate)))
+			}
+			return gcc.NewSendSideBWE(opts...)
+		}, nil
+	}
+
+	newController, _ := getCongestionControlFactory(conf.Algorithm)
+	return func() (cc.BandwidthEstimator, error) {
+		controller, err := newController(CongestionControlParams{
+			InitialBitrate: conf.InitialBitrate,
+			MinBitrate:     conf.MinBitrate,
+			MaxBitrate:     conf.MaxBitrate,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var pacer gcc.Pacer
+		if conf.NewPacer != nil {
+			pacer = conf.NewPacer(conf.InitialBitrate)
+		}
+		return NewEstimator(controller, pacer), nil
+	}, nil
+}
+
+// ------------------------------------------------
+
+// Estimator runs a CongestionControl behind the pion congestion control interceptor, the pacer
+// is set to the pacing bitrate and the target bitrate is reported to the application.
+type Estimator struct {
+	controller CongestionControl
+	pacer      gcc.Pacer
+
+	lock                  sync.Mutex
+	onTargetBitrateChange func(bitrate int)
+	isClosed              bool
+}
+
+// NewEstimator creates an estimator sending through pacer, a leaky bucket pacer when nil.
+func NewEstimator(controller CongestionControl, pacer gcc.Pacer) *Estimator {
+	if pacer == nil {
+		pacer = gcc.NewLeakyBucketPacer(controller.PacingBitrate())
+	}
+	pacer.SetTargetBitrate(controller.PacingBitrate())
+
+	e := &Estimator{
+		controller: controller,
+		pacer:      pacer,
+	}
+	controller.OnRateChange(e.onRateChange)
+	return e
+}
+
+func (e *Estimator) Controller() CongestionControl {
+	return e.controller
+}
+
+// AddStream adds a new stream to the bandwidth estimator
+func (e *Estimator) AddStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var hdrExtID uint8
+	for _, ext := range info.RTPHeaderExtensions {
+		if ext.URI == transportCCURI {
+			hdrExtID = uint8(ext.ID)
+			break
+		}
+	}
+
+	e.pacer.AddStream(info.SSRC, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if hdrExtID != 0 {
+			if ext := header.GetExtension(hdrExtID); ext != nil {
+				var tcc rtp.TransportCCExtension
+				if err := tcc.Unmarshal(ext); err == nil {
+					e.controller.OnPacketSent(tcc.TransportSequence, header.MarshalSize()+len(payload))
+				}
+			}
+		}
+		return writer.Write(header, payload, attributes)
+	}))
+	return e.pacer
+}
+
+// WriteRTCP adds some RTCP feedback to the bandwidth estimator
+func (e *Estimator) WriteRTCP(pkts []rtcp.Packet, _ interceptor.Attributes) error {
+	e.lock.Lock()
+	isClosed := e.isClosed
+	e.lock.Unlock()
+	if isClosed {
+		return ErrEstimatorClosed
+	}
+
+	for _, pkt := range pkts {
+		if twcc, ok := pkt.(*rtcp.TransportLayerCC); ok {
+			if err := e.controller.OnFeedback(twcc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// OnRTT adds an RTT measured outside of transport wide feedback.
+func (e *Estimator) OnRTT(rtt time.Duration) {
+	e.controller.OnRTT(rtt)
+}
+
+// GetTargetBitrate returns the current target bitrate in bits per second
+func (e *Estimator) GetTargetBitrate() int {
+	return e.controller.TargetBitrate()
+}
+
+// OnTargetBitrateChange sets the callback that is called when the target
+// bitrate in bits per second changes
+func (e *Estimator) OnTargetBitrateChange(f func(bitrate int)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.onTargetBitrateChange = f
+}
+
+// GetStats returns some internal statistics of the bandwidth estimator
+func (e *Estimator) GetStats() map[string]interface{} {
+	return e.controller.ExportState()
+}
+
+// Close stops and closes the bandwidth estimator
+func (e *Estimator) Close() error {
+	e.lock.Lock()
+	if e.isClosed {
+		e.lock.Unlock()
+		return nil
+	}
+	e.isClosed = true
+	e.lock.Unlock()
+
+	return e.pacer.Close()
+}
+
+func (e *Estimator) onRateChange(targetBitrate int, pacingBitrate int) {
+	e.pacer.SetTargetBitrate(pacingBitrate)
+
+	e.lock.Lock()
+	onTargetBitrateChange := e.onTargetBitrateChange
+	e.lock.Unlock()
+
+	if onTargetBitrateChange != nil {
+		onTargetBitrateChange(targetBitrate)
+	}
+}
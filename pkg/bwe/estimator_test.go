@@ -0,0 +1,128 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bwe
+
+import (
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// testPacer writes packets immediately
+type testPacer struct {
+	writers       map[uint32]interceptor.RTPWriter
+	targetBitrate int
+	isClosed      bool
+}
+
+func (p *testPacer) Write(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+	return p.writers[header.SSRC].Write(header, payload, attributes)
+}
+
+func (p *testPacer) AddStream(ssrc uint32, writer interceptor.RTPWriter) {
+	p.writers[ssrc] = writer
+}
+
+func (p *testPacer) SetTargetBitrate(bitrate int) {
+	p.targetBitrate = bitrate
+}
+
+func (p *testPacer) Close() error {
+	p.isClosed = true
+	return nil
+}
+
+func TestEstimator(t *testing.T) {
+	pacer := &testPacer{writers: make(map[uint32]interceptor.RTPWriter)}
+	bbr := NewBBR(BBRParams{})
+	e := NewEstimator(bbr, pacer)
+	require.Equal(t, bbr.PacingBitrate(), pacer.targetBitrate)
+
+	numWritten := 0
+	writer := e.AddStream(&interceptor.StreamInfo{
+		SSRC:                1,
+		RTPHeaderExtensions: []interceptor.RTPHeaderExtension{{URI: transportCCURI, ID: 3}},
+	}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		numWritten++
+		return header.MarshalSize() + len(payload), nil
+	}))
+
+	for sn := uint16(0); sn < 2; sn++ {
+		ext, err := (&rtp.TransportCCExtension{TransportSequence: sn}).Marshal()
+		require.NoError(t, err)
+		header := &rtp.Header{Version: 2, SSRC: 1}
+		require.NoError(t, header.SetExtension(3, ext))
+		_, err = writer.Write(header, make([]byte, 1000), nil)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, numWritten)
+	require.Greater(t, bbr.Stats().BytesInFlight, 2000)
+
+	require.NoError(t, e.WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: 1},
+		&rtcp.TransportLayerCC{
+			MediaSSRC:          1,
+			BaseSequenceNumber: 0,
+			PacketStatusCount:  2,
+			ReferenceTime:      1,
+			PacketChunks: []rtcp.PacketStatusChunk{
+				&rtcp.RunLengthChunk{PacketStatusSymbol: rtcp.TypeTCCPacketReceivedSmallDelta, RunLength: 2},
+			},
+			RecvDeltas: []*rtcp.RecvDelta{
+				{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 1000},
+				{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 1000},
+			},
+		},
+	}, nil))
+	stats := bbr.Stats()
+	require.Zero(t, stats.BytesInFlight)
+	require.Equal(t, 2, stats.NumReceived)
+	require.Equal(t, "STARTUP", e.GetStats()["state"])
+
+	require.NoError(t, e.Close())
+	require.True(t, pacer.isClosed)
+	require.ErrorIs(t, e.WriteRTCP(nil, nil), ErrEstimatorClosed)
+}
+
+func TestEstimatorFactory(t *testing.T) {
+	newPacer := func(int) gcc.Pacer {
+		return &testPacer{writers: make(map[uint32]interceptor.RTPWriter)}
+	}
+
+	factory, err := NewEstimatorFactory(Config{NewPacer: newPacer})
+	require.NoError(t, err)
+	estimator, err := factory()
+	require.NoError(t, err)
+	require.IsType(t, &gcc.SendSideBWE{}, estimator)
+	require.NoError(t, estimator.Close())
+
+	factory, err = NewEstimatorFactory(Config{Algorithm: AlgorithmBBR, InitialBitrate: 500_000, MaxBitrate: 1_000_000, NewPacer: newPacer})
+	require.NoError(t, err)
+	estimator, err = factory()
+	require.NoError(t, err)
+	require.IsType(t, &Estimator{}, estimator)
+	require.IsType(t, &BBR{}, estimator.(*Estimator).Controller())
+	require.Equal(t, 1_000_000, estimator.GetTargetBitrate())
+	require.NoError(t, estimator.Close())
+
+	_, err = NewEstimatorFactory(Config{Algorithm: "cubic"})
+	require.ErrorIs(t, err, ErrUnknownAlgorithm)
+	_, err = NewEstimatorFactory(Config{MinBitrate: 2, MaxBitrate: 1})
+	require.Error(t, err)
+}
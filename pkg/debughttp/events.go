@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debughttp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+type EventLogParams struct {
+	// events kept, the oldest are dropped beyond this
+	MaxEvents int
+}
+
+var EventLogParamsDefault = EventLogParams{
+	MaxEvents: 256,
+}
+
+type Event struct {
+	Time time.Time   `json:"time"`
+	Kind string      `json:"kind"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// EventLog keeps recent events, for example external IP, NAT keepalive and admission events,
+// register its Render method with a Handler.
+type EventLog struct {
+	params EventLogParams
+
+	lock   sync.Mutex
+	events *ring.Buffer[Event]
+}
+
+func NewEventLog(params EventLogParams) *EventLog {
+	if params.MaxEvents <= 0 {
+		params.MaxEvents = EventLogParamsDefault.MaxEvents
+	}
+	return &EventLog{
+		params: params,
+		events: ring.NewBuffer[Event](params.MaxEvents),
+	}
+}
+
+func (e *EventLog) Record(kind string, data interface{}) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.events.Push(Event{
+		Time: time.Now(),
+		Kind: kind,
+		Data: data,
+	})
+}
+
+// Events returns the events kept, oldest first.
+func (e *EventLog) Events() []Event {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.events.AppendTo(make([]Event, 0, e.events.Len()))
+}
+
+func (e *EventLog) Render() interface{} {
+	return e.Events()
+}
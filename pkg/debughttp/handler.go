@@ -0,0 +1,159 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debughttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Source returns the state to render, it must be safe to encode as JSON.
+type Source func() interface{}
+
+// Handler serves registered sources as JSON, GET /<name> renders a source and GET / lists their names.
+// Mount it with http.StripPrefix, for example:
+//
+//	mux.Handle("/debug/transport/", http.StripPrefix("/debug/transport", h))
+type Handler struct {
+	lock    sync.RWMutex
+	sources map[string]Source
+}
+
+func NewHandler() *Handler {
+	return &Handler{
+		sources: make(map[string]Source),
+	}
+}
+
+// Register serves source under name, replacing a source of the same name.
+func (h *Handler) Register(name string, source Source) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.sources[name] = source
+}
+
+func (h *Handler) Unregister(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.sources, name)
+}
+
+// Collection registers a collection of sources under name, for state kept per connection such as pacers and estimators.
+func (h *Handler) Collection(name string) *Collection {
+	c := NewCollection()
+	h.Register(name, c.Render)
+	return c
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(r.URL.Path, "/")
+	if name == "" {
+		writeJSON(w, h.names())
+		return
+	}
+
+	h.lock.RLock()
+	source, ok := h.sources[name]
+	h.lock.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, source())
+}
+
+func (h *Handler) names() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	names := make([]string, 0, len(h.sources))
+	for name := range h.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
+
+// ------------------------------------------------
+
+// Collection renders sources by ID, sources are added and removed as connections come and go.
+type Collection struct {
+	lock    sync.RWMutex
+	sources map[string]Source
+}
+
+func NewCollection() *Collection {
+	return &Collection{
+		sources: make(map[string]Source),
+	}
+}
+
+func (c *Collection) Add(id string, source Source) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sources[id] = source
+}
+
+func (c *Collection) Remove(id string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.sources, id)
+}
+
+func (c *Collection) Len() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return len(c.sources)
+}
+
+// Render returns the state of each source by ID, sources are called outside the lock.
+func (c *Collection) Render() interface{} {
+	c.lock.RLock()
+	sources := make(map[string]Source, len(c.sources))
+	for id, source := range c.sources {
+		sources[id] = source
+	}
+	c.lock.RUnlock()
+
+	rendered := make(map[string]interface{}, len(sources))
+	for id, source := range sources {
+		rendered[id] = source()
+	}
+	return rendered
+}
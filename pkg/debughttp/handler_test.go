@@ -0,0 +1,96 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debughttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+	"github.com/livekit/mediatransportutil/pkg/transport"
+)
+
+func get(t *testing.T, h http.Handler, path string, v interface{}) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	if w.Code == http.StatusOK && v != nil {
+		require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+	return w.Code
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler()
+
+	book := rtcconfig.NewAddressBook(rtcconfig.AddressBookParamsDefault)
+	book.Record([]rtcconfig.AdvertisedAddress{{IP: "203.0.113.1", Source: rtcconfig.AddressSourceSTUN}})
+	h.Register("addresses", AddressBookSource(book))
+
+	registry := transport.NewUfragRegistry()
+	require.NoError(t, registry.Register("ufrag", "session"))
+	h.Register("sessions", UfragRegistrySource(registry))
+
+	events := NewEventLog(EventLogParams{MaxEvents: 2})
+	h.Register("events", events.Render)
+
+	pacers := h.Collection("pacers")
+	pacers.Add("a", func() interface{} { return 1 })
+	pacers.Add("b", func() interface{} { return 2 })
+	pacers.Remove("b")
+
+	var names []string
+	require.Equal(t, http.StatusOK, get(t, h, "/", &names))
+	require.Equal(t, []string{"addresses", "events", "pacers", "sessions"}, names)
+
+	var history []rtcconfig.AddressBookEntry
+	require.Equal(t, http.StatusOK, get(t, h, "/addresses", &history))
+	require.Len(t, history, 1)
+	require.Equal(t, "203.0.113.1", history[0].Addresses[0].IP)
+
+	var sessions []ufragSession
+	require.Equal(t, http.StatusOK, get(t, h, "/sessions/", &sessions))
+	require.Equal(t, []ufragSession{{Ufrag: "ufrag", SessionID: "session"}}, sessions)
+
+	var rendered map[string]int
+	require.Equal(t, http.StatusOK, get(t, h, "/pacers", &rendered))
+	require.Equal(t, map[string]int{"a": 1}, rendered)
+
+	events.Record("first", nil)
+	events.Record("second", map[string]string{"ip": "203.0.113.1"})
+	events.Record("third", nil)
+	var recorded []Event
+	require.Equal(t, http.StatusOK, get(t, h, "/events", &recorded))
+	require.Len(t, recorded, 2)
+	require.Equal(t, "second", recorded[0].Kind)
+	require.Equal(t, "third", recorded[1].Kind)
+
+	require.Equal(t, http.StatusNotFound, get(t, h, "/unknown", nil))
+	h.Unregister("events")
+	require.Equal(t, http.StatusNotFound, get(t, h, "/events", nil))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/pacers", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// mounted under a prefix
+	mux := http.NewServeMux()
+	mux.Handle("/debug/transport/", http.StripPrefix("/debug/transport", h))
+	require.Equal(t, http.StatusOK, get(t, mux, "/debug/transport/pacers", &rendered))
+}
@@ -0,0 +1,109 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debughttp
+
+import (
+	"net"
+
+	"github.com/livekit/mediatransportutil/pkg/bwe"
+	"github.com/livekit/mediatransportutil/pkg/pacer"
+	"github.com/livekit/mediatransportutil/pkg/rtcconfig"
+	"github.com/livekit/mediatransportutil/pkg/transport"
+)
+
+// WebRTCConfigSource renders the mux endpoints and NAT mappings of a WebRTC config.
+func WebRTCConfigSource(c *rtcconfig.WebRTCConfig) Source {
+	return func() interface{} {
+		settings := c.Settings()
+		state := struct {
+			UDPMuxAddresses      []string `json:"udp_mux_addresses,omitempty"`
+			TCPMuxAddress        string   `json:"tcp_mux_address,omitempty"`
+			NAT1To1IPs           []string `json:"nat_1to1_ips,omitempty"`
+			StaticHostCandidates []string `json:"static_host_candidates,omitempty"`
+		}{
+			NAT1To1IPs: settings.NAT1To1IPs,
+		}
+		if c.UDPMux != nil {
+			state.UDPMuxAddresses = addrStrings(c.UDPMux.GetListenAddresses())
+		}
+		if c.TCPMuxListener != nil {
+			state.TCPMuxAddress = c.TCPMuxListener.Addr().String()
+		}
+		for _, candidate := range settings.StaticHostCandidates {
+			state.StaticHostCandidates = append(state.StaticHostCandidates, candidate.String())
+		}
+		return state
+	}
+}
+
+// AddressBookSource renders the history of advertised addresses.
+func AddressBookSource(b *rtcconfig.AddressBook) Source {
+	return func() interface{} {
+		return b.History()
+	}
+}
+
+type ufragSession struct {
+	Ufrag       string   `json:"ufrag"`
+	SessionID   string   `json:"session_id"`
+	RemoteAddrs []string `json:"remote_addrs,omitempty"`
+}
+
+// UfragRegistrySource renders the sessions of a shared mux with the remote addresses mapped to them.
+func UfragRegistrySource(r *transport.UfragRegistry) Source {
+	return func() interface{} {
+		sessions := r.Sessions("")
+		rendered := make([]ufragSession, 0, len(sessions))
+		for _, s := range sessions {
+			rendered = append(rendered, ufragSession{
+				Ufrag:       s.Ufrag,
+				SessionID:   s.SessionID,
+				RemoteAddrs: addrStrings(s.RemoteAddrs),
+			})
+		}
+		return rendered
+	}
+}
+
+// EstimatorSource renders the target bitrate and internal state of a bandwidth estimator.
+func EstimatorSource(e *bwe.Estimator) Source {
+	return func() interface{} {
+		return map[string]interface{}{
+			"targetBitrate": e.GetTargetBitrate(),
+			"state":         e.GetStats(),
+		}
+	}
+}
+
+// PacerSource renders the queue and retransmission cap of a leaky bucket pacer.
+func PacerSource(p *pacer.PacerLeakyBucket) Source {
+	return func() interface{} {
+		return struct {
+			Queue          pacer.LeakyBucketQueueStats      `json:"queue"`
+			Retransmission pacer.RetransmissionLimiterStats `json:"retransmission"`
+		}{
+			Queue:          p.QueueStats(),
+			Retransmission: p.RetransmissionStats(),
+		}
+	}
+}
+
+func addrStrings(addrs []net.Addr) []string {
+	strs := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		strs = append(strs, addr.String())
+	}
+	return strs
+}
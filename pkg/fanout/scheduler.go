@@ -0,0 +1,459 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fanout
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+var (
+	ErrSubscriberExists = errors.New("subscriber already exists")
+	ErrSchedulerClosed  = errors.New("scheduler is closed")
+)
+
+// WriteFunc writes a packet to a subscriber. The packet is shared by all subscribers and must not be modified,
+// clone it to rewrite the header.
+type WriteFunc func(pkt *rtp.Packet) error
+
+type SchedulerParams struct {
+	// concurrent writers, a blocked subscriber write holds one of them for a batch at most
+	NumWorkers int
+	// packets written to a subscriber in a turn before the next subscriber is served
+	BatchSize int
+	// packets queued per subscriber, the oldest are dropped beyond
+	MaxQueue int
+	// writes taking at least this long are counted as slow
+	SlowWriteThreshold time.Duration
+
+	// writes of a subscriber are evaluated over windows of this duration, a subscriber with at least
+	// IsolateSlowRatio of the writes of a window slow is isolated, and restored after a window below RestoreSlowRatio
+	SlowWindow       time.Duration
+	IsolateSlowRatio float64
+	RestoreSlowRatio float64
+	// writes in a window for it to be evaluated
+	MinWindowWrites int
+	// writers serving isolated subscribers, apart from those of the hot path
+	NumIsolatedWorkers int
+}
+
+var SchedulerParamsDefault = SchedulerParams{
+	NumWorkers:         4,
+	BatchSize:          16,
+	MaxQueue:           256,
+	SlowWriteThreshold: 5 * time.Millisecond,
+	SlowWindow:         time.Second,
+	IsolateSlowRatio:   0.5,
+	RestoreSlowRatio:   0.1,
+	MinWindowWrites:    10,
+	NumIsolatedWorkers: 1,
+}
+
+type SubscriberStats struct {
+	NumPackets int
+	// dropped for the queue limit
+	NumDropped    int
+	NumErrors     int
+	NumSlowWrites int
+	QueueLength   int
+	// duration of writes
+	MeanWriteLatency time.Duration
+	MaxWriteLatency  time.Duration
+	// time between Write and the write to the subscriber
+	MaxQueueDelay time.Duration
+	IsIsolated    bool
+	NumIsolations int
+}
+
+// SlowSubscriberEvent reports a subscriber isolated for persistently slow writes, or restored to the hot path.
+// The embedder may degrade an isolated subscriber, e.g. to a lower layer, to reduce what it writes.
+type SlowSubscriberEvent struct {
+	SubscriberID string
+	IsIsolated   bool
+	// of the window that triggered the event
+	SlowRatio        float64
+	MeanWriteLatency time.Duration
+}
+
+type queuedPacket struct {
+	pkt *rtp.Packet
+	at  time.Time
+}
+
+type subscriber struct {
+	id    string
+	write WriteFunc
+
+	// guarded by scheduler lock
+	packets   ring.Deque[queuedPacket]
+	isReady   bool
+	isRemoved bool
+	stats     SubscriberStats
+	// total duration of writes, for the mean
+	writeLatency time.Duration
+
+	windowStart        time.Time
+	windowWrites       int
+	windowSlowWrites   int
+	windowWriteLatency time.Duration
+}
+
+// Scheduler writes the packets of a publisher to its subscribers, fairly: subscribers with queued packets are served
+// in round-robin by a few workers, a batch of packets at a time, so that slow subscriber sockets delay their own
+// packets but not those of the other subscribers. A subscriber falling behind loses its oldest packets.
+//
+// Subscribers whose sockets persistently push back, e.g. over TCP or TURN, are isolated: they are served by separate
+// workers, so that they do not hold the workers of the hot path at all, until their writes are fast again.
+//
+// Typical use is a scheduler per publisher track, with the forwarder calling Write for each packet
+//
+//	s := fanout.NewScheduler(fanout.SchedulerParamsDefault)
+//	_ = s.AddSubscriber(subscriberID, downTrack.WriteRTP)
+//	s.Write(pkt)
+type Scheduler struct {
+	params SchedulerParams
+
+	lock             sync.Mutex
+	subscribers      map[string]*subscriber
+	ready            ring.Deque[*subscriber]
+	isolatedReady    ring.Deque[*subscriber]
+	isClosed         bool
+	onSlowSubscriber func(event SlowSubscriberEvent)
+
+	wake         chan struct{}
+	isolatedWake chan struct{}
+	closed       chan struct{}
+	wg           sync.WaitGroup
+}
+
+func NewScheduler(params SchedulerParams) *Scheduler {
+	if params.NumWorkers <= 0 {
+		params.NumWorkers = SchedulerParamsDefault.NumWorkers
+	}
+	if params.BatchSize <= 0 {
+		params.BatchSize = SchedulerParamsDefault.BatchSize
+	}
+	if params.MaxQueue <= 0 {
+		params.MaxQueue = SchedulerParamsDefault.MaxQueue
+	}
+	if params.SlowWriteThreshold <= 0 {
+		params.SlowWriteThreshold = SchedulerParamsDefault.SlowWriteThreshold
+	}
+	if params.SlowWindow <= 0 {
+		params.SlowWindow = SchedulerParamsDefault.SlowWindow
+	}
+	if params.IsolateSlowRatio <= 0 || params.IsolateSlowRatio > 1 {
+		params.IsolateSlowRatio = SchedulerParamsDefault.IsolateSlowRatio
+	}
+	if params.RestoreSlowRatio <= 0 || params.RestoreSlowRatio > params.IsolateSlowRatio {
+		params.RestoreSlowRatio = params.IsolateSlowRatio * SchedulerParamsDefault.RestoreSlowRatio / SchedulerParamsDefault.IsolateSlowRatio
+	}
+	if params.MinWindowWrites <= 0 {
+		params.MinWindowWrites = SchedulerParamsDefault.MinWindowWrites
+	}
+	if params.NumIsolatedWorkers <= 0 {
+		params.NumIsolatedWorkers = SchedulerParamsDefault.NumIsolatedWorkers
+	}
+	s := &Scheduler{
+		params:       params,
+		subscribers:  make(map[string]*subscriber),
+		wake:         make(chan struct{}, params.NumWorkers),
+		isolatedWake: make(chan struct{}, params.NumIsolatedWorkers),
+		closed:       make(chan struct{}),
+	}
+	s.wg.Add(params.NumWorkers + params.NumIsolatedWorkers)
+	for i := 0; i < params.NumWorkers; i++ {
+		go s.worker(false)
+	}
+	for i := 0; i < params.NumIsolatedWorkers; i++ {
+		go s.worker(true)
+	}
+	return s
+}
+
+// OnSlowSubscriber sets the callback called when a subscriber is isolated or restored.
+func (s *Scheduler) OnSlowSubscriber(f func(event SlowSubscriberEvent)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onSlowSubscriber = f
+}
+
+func (s *Scheduler) AddSubscriber(id string, write WriteFunc) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.isClosed {
+		return ErrSchedulerClosed
+	}
+	if _, ok := s.subscribers[id]; ok {
+		return ErrSubscriberExists
+	}
+	s.subscribers[id] = &subscriber{
+		id:    id,
+		write: write,
+	}
+	return nil
+}
+
+// RemoveSubscriber drops the packets queued for the subscriber, a write in progress completes.
+func (s *Scheduler) RemoveSubscriber(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if sub, ok := s.subscribers[id]; ok {
+		sub.isRemoved = true
+		sub.packets.Clear()
+		delete(s.subscribers, id)
+	}
+}
+
+// Write queues pkt for all subscribers.
+func (s *Scheduler) Write(pkt *rtp.Packet) {
+	s.writeAt(pkt, time.Now())
+}
+
+func (s *Scheduler) writeAt(pkt *rtp.Packet, at time.Time) {
+	s.lock.Lock()
+	if s.isClosed {
+		s.lock.Unlock()
+		return
+	}
+
+	numReady, numIsolatedReady := 0, 0
+	for _, sub := range s.subscribers {
+		if sub.packets.Len() >= s.params.MaxQueue {
+			sub.packets.PopFront()
+			sub.stats.NumDropped++
+		}
+		sub.packets.PushBack(queuedPacket{pkt: pkt, at: at})
+		if !sub.isReady {
+			sub.isReady = true
+			s.readyQueueLocked(sub).PushBack(sub)
+			if sub.stats.IsIsolated {
+				numIsolatedReady++
+			} else {
+				numReady++
+			}
+		}
+	}
+	s.lock.Unlock()
+
+	wakeUp(s.wake, numReady)
+	wakeUp(s.isolatedWake, numIsolatedReady)
+}
+
+func (s *Scheduler) SubscriberStats(id string) (SubscriberStats, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sub, ok := s.subscribers[id]
+	if !ok {
+		return SubscriberStats{}, false
+	}
+	return sub.statsLocked(), true
+}
+
+// Stats returns the stats of all subscribers by ID.
+func (s *Scheduler) Stats() map[string]SubscriberStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := make(map[string]SubscriberStats, len(s.subscribers))
+	for id, sub := range s.subscribers {
+		stats[id] = sub.statsLocked()
+	}
+	return stats
+}
+
+// Close stops the workers, after writes in progress complete. Queued packets are dropped.
+func (s *Scheduler) Close() {
+	s.lock.Lock()
+	if s.isClosed {
+		s.lock.Unlock()
+		return
+	}
+	s.isClosed = true
+	for _, sub := range s.subscribers {
+		sub.packets.Clear()
+	}
+	s.lock.Unlock()
+
+	close(s.closed)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) worker(isolated bool) {
+	defer s.wg.Done()
+
+	wake := s.wake
+	if isolated {
+		wake = s.isolatedWake
+	}
+	for {
+		select {
+		case <-s.closed:
+			return
+		case <-wake:
+		}
+
+		for s.serveNext(isolated) {
+		}
+	}
+}
+
+// serveNext writes a batch to the next ready subscriber of the hot path or isolated, it returns false if none is ready
+func (s *Scheduler) serveNext(isolated bool) bool {
+	ready := &s.ready
+	if isolated {
+		ready = &s.isolatedReady
+	}
+
+	s.lock.Lock()
+	if s.isClosed || ready.Len() == 0 {
+		s.lock.Unlock()
+		return false
+	}
+	sub := ready.PopFront()
+	batch := make([]queuedPacket, 0, s.params.BatchSize)
+	for sub.packets.Len() != 0 && len(batch) < s.params.BatchSize {
+		batch = append(batch, sub.packets.PopFront())
+	}
+	s.lock.Unlock()
+
+	for _, p := range batch {
+		start := time.Now()
+		err := sub.write(p.pkt)
+		end := time.Now()
+
+		s.lock.Lock()
+		sub.recordWriteLocked(start.Sub(p.at), end.Sub(start), err, s.params.SlowWriteThreshold)
+		event := s.evaluateLocked(sub, end)
+		onSlowSubscriber := s.onSlowSubscriber
+		isRemoved := sub.isRemoved || s.isClosed
+		s.lock.Unlock()
+
+		if event != nil && onSlowSubscriber != nil {
+			onSlowSubscriber(*event)
+		}
+		if isRemoved {
+			break
+		}
+	}
+
+	// back of the line, the subscriber stays off the ready queue while being served so that a single worker writes to it
+	s.lock.Lock()
+	if sub.packets.Len() != 0 && !sub.isRemoved && !s.isClosed {
+		// to the other path if isolated or restored meanwhile
+		s.readyQueueLocked(sub).PushBack(sub)
+		if sub.stats.IsIsolated != isolated {
+			if sub.stats.IsIsolated {
+				wakeUp(s.isolatedWake, 1)
+			} else {
+				wakeUp(s.wake, 1)
+			}
+		}
+	} else {
+		sub.isReady = false
+	}
+	s.lock.Unlock()
+	return true
+}
+
+func (s *Scheduler) readyQueueLocked(sub *subscriber) *ring.Deque[*subscriber] {
+	if sub.stats.IsIsolated {
+		return &s.isolatedReady
+	}
+	return &s.ready
+}
+
+// evaluateLocked ends the slow write window of the subscriber if due, returning an event if it is isolated or restored
+func (s *Scheduler) evaluateLocked(sub *subscriber, now time.Time) *SlowSubscriberEvent {
+	if sub.windowStart.IsZero() {
+		sub.windowStart = now
+	}
+	if now.Sub(sub.windowStart) < s.params.SlowWindow {
+		return nil
+	}
+
+	writes, slowWrites, writeLatency := sub.windowWrites, sub.windowSlowWrites, sub.windowWriteLatency
+	sub.windowStart = now
+	sub.windowWrites, sub.windowSlowWrites, sub.windowWriteLatency = 0, 0, 0
+	if writes < s.params.MinWindowWrites {
+		return nil
+	}
+
+	slowRatio := float64(slowWrites) / float64(writes)
+	switch {
+	case !sub.stats.IsIsolated && slowRatio >= s.params.IsolateSlowRatio:
+		sub.stats.IsIsolated = true
+		sub.stats.NumIsolations++
+	case sub.stats.IsIsolated && slowRatio < s.params.RestoreSlowRatio:
+		sub.stats.IsIsolated = false
+	default:
+		return nil
+	}
+	return &SlowSubscriberEvent{
+		SubscriberID:     sub.id,
+		IsIsolated:       sub.stats.IsIsolated,
+		SlowRatio:        slowRatio,
+		MeanWriteLatency: writeLatency / time.Duration(writes),
+	}
+}
+
+func (sub *subscriber) recordWriteLocked(queueDelay time.Duration, latency time.Duration, err error, slowWriteThreshold time.Duration) {
+	sub.stats.NumPackets++
+	if err != nil {
+		sub.stats.NumErrors++
+	}
+	sub.windowWrites++
+	if latency >= slowWriteThreshold {
+		sub.stats.NumSlowWrites++
+		sub.windowSlowWrites++
+	}
+	sub.writeLatency += latency
+	sub.windowWriteLatency += latency
+	if latency > sub.stats.MaxWriteLatency {
+		sub.stats.MaxWriteLatency = latency
+	}
+	if queueDelay > sub.stats.MaxQueueDelay {
+		sub.stats.MaxQueueDelay = queueDelay
+	}
+}
+
+func (sub *subscriber) statsLocked() SubscriberStats {
+	stats := sub.stats
+	stats.QueueLength = sub.packets.Len()
+	if stats.NumPackets != 0 {
+		stats.MeanWriteLatency = sub.writeLatency / time.Duration(stats.NumPackets)
+	}
+	return stats
+}
+
+// ------------------------------------------------
+
+func wakeUp(wake chan struct{}, n int) {
+	for i := 0; i < n && i < cap(wake); i++ {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
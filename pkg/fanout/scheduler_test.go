@@ -0,0 +1,158 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fanout
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerSlowSubscriber(t *testing.T) {
+	s := NewScheduler(SchedulerParams{NumWorkers: 2, BatchSize: 2, MaxQueue: 4})
+	defer s.Close()
+
+	// blocks a worker until released
+	release := make(chan struct{})
+	var slowSNs []uint16
+	require.NoError(t, s.AddSubscriber("slow", func(pkt *rtp.Packet) error {
+		<-release
+		slowSNs = append(slowSNs, pkt.SequenceNumber)
+		return nil
+	}))
+	require.ErrorIs(t, s.AddSubscriber("slow", nil), ErrSubscriberExists)
+
+	var lock sync.Mutex
+	received := make(map[string][]uint16)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("fast%d", i)
+		require.NoError(t, s.AddSubscriber(id, func(pkt *rtp.Packet) error {
+			lock.Lock()
+			received[id] = append(received[id], pkt.SequenceNumber)
+			lock.Unlock()
+			return nil
+		}))
+	}
+
+	for sn := uint16(0); sn < 6; sn++ {
+		s.Write(&rtp.Packet{Header: rtp.Header{SequenceNumber: sn}})
+		time.Sleep(time.Millisecond)
+	}
+
+	// the fast subscribers get everything while the slow one blocks
+	require.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for _, sns := range received {
+			if len(sns) != 6 {
+				return false
+			}
+		}
+		return len(received) == 20
+	}, time.Second, 10*time.Millisecond)
+	for _, sns := range received {
+		require.Equal(t, []uint16{0, 1, 2, 3, 4, 5}, sns)
+	}
+
+	close(release)
+	require.Eventually(t, func() bool {
+		stats, _ := s.SubscriberStats("slow")
+		return stats.QueueLength == 0 && stats.NumPackets+stats.NumDropped == 6
+	}, time.Second, 10*time.Millisecond)
+
+	stats, ok := s.SubscriberStats("slow")
+	require.True(t, ok)
+	require.NotZero(t, stats.NumDropped)
+	require.NotZero(t, stats.NumSlowWrites)
+	require.Equal(t, uint16(5), slowSNs[len(slowSNs)-1])
+	require.GreaterOrEqual(t, stats.MaxWriteLatency, stats.MeanWriteLatency)
+	require.Len(t, s.Stats(), 21)
+
+	s.RemoveSubscriber("slow")
+	_, ok = s.SubscriberStats("slow")
+	require.False(t, ok)
+}
+
+func TestSchedulerIsolation(t *testing.T) {
+	s := NewScheduler(SchedulerParams{
+		NumWorkers:         1,
+		BatchSize:          1,
+		SlowWriteThreshold: time.Millisecond,
+		SlowWindow:         20 * time.Millisecond,
+		MinWindowWrites:    3,
+	})
+	defer s.Close()
+
+	events := make(chan SlowSubscriberEvent, 4)
+	s.OnSlowSubscriber(func(event SlowSubscriberEvent) {
+		events <- event
+	})
+
+	var isSlow atomic.Bool
+	isSlow.Store(true)
+	require.NoError(t, s.AddSubscriber("slow", func(pkt *rtp.Packet) error {
+		if isSlow.Load() {
+			time.Sleep(2 * time.Millisecond)
+		}
+		return nil
+	}))
+	var numFast atomic.Int32
+	require.NoError(t, s.AddSubscriber("fast", func(pkt *rtp.Packet) error {
+		numFast.Add(1)
+		return nil
+	}))
+
+	// writes until the next event
+	var event SlowSubscriberEvent
+	write := func() {
+		for sn := uint16(0); sn < 5000; sn++ {
+			select {
+			case event = <-events:
+				return
+			default:
+			}
+			s.Write(&rtp.Packet{Header: rtp.Header{SequenceNumber: sn}})
+			time.Sleep(time.Millisecond)
+		}
+		require.Fail(t, "no slow subscriber event")
+	}
+
+	write()
+	require.Equal(t, "slow", event.SubscriberID)
+	require.True(t, event.IsIsolated)
+	require.GreaterOrEqual(t, event.MeanWriteLatency, 2*time.Millisecond)
+	stats, _ := s.SubscriberStats("slow")
+	require.True(t, stats.IsIsolated)
+
+	// the single hot path worker is not held by the isolated subscriber
+	before := numFast.Load()
+	s.Write(&rtp.Packet{})
+	require.Eventually(t, func() bool {
+		return numFast.Load() > before
+	}, time.Second, time.Millisecond)
+
+	isSlow.Store(false)
+	write()
+	require.False(t, event.IsIsolated)
+	stats, _ = s.SubscriberStats("slow")
+	require.False(t, stats.IsIsolated)
+	require.Equal(t, 1, stats.NumIsolations)
+}
@@ -15,7 +15,12 @@
 //go:build windows
 // +build windows
 
-package rtcconfig
+package fdtrack
 
-func checkUDPReadBuffer() {
+func Limit() (uint64, uint64, error) {
+	return 0, 0, ErrNotSupported
+}
+
+func numOpen() int {
+	return -1
 }
@@ -0,0 +1,233 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fdtrack
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrNotSupported = errors.New("file descriptor limits are not supported on this platform")
+)
+
+type Kind int
+
+const (
+	// UDP muxes and other long lived shared sockets
+	KindMux Kind = iota
+	// TCP listeners and the connections they accept
+	KindListener
+	// TURN relay allocations
+	KindRelaySession
+	// anything else the embedder wants accounted, e.g. recording files
+	KindOther
+
+	numKinds
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindMux:
+		return "MUX"
+	case KindListener:
+		return "LISTENER"
+	case KindRelaySession:
+		return "RELAY_SESSION"
+	case KindOther:
+		return "OTHER"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+type TrackerParams struct {
+	// maximum number of file descriptors, 0 reads the soft RLIMIT_NOFILE of the process
+	Limit int
+	// new sessions should not be admitted at or above this many tracked descriptors,
+	// 0 uses SoftLimitRatio of Limit
+	SoftLimit      int
+	SoftLimitRatio float64
+	// admission resumes below SoftLimit * (1 - Hysteresis)
+	Hysteresis float64
+}
+
+var TrackerParamsDefault = TrackerParams{
+	SoftLimitRatio: 0.8,
+	Hysteresis:     0.05,
+}
+
+type AdmissionEvent struct {
+	// false when new sessions should be rejected, true when admission resumes
+	IsAdmitting bool
+	Usage       int
+	SoftLimit   int
+	Limit       int
+}
+
+type Stats struct {
+	// tracked descriptors by kind, indexed by Kind
+	ByKind  [numKinds]int
+	Tracked int
+	// descriptors open in the process, including untracked ones, -1 if not available
+	Open      int
+	SoftLimit int
+	Limit     int
+	// descriptors that can still be opened before hitting Limit
+	Headroom    int
+	IsAdmitting bool
+}
+
+// Tracker accounts the file descriptors consumed by muxes, listeners and relay sessions against RLIMIT_NOFILE.
+//
+// Owners call Acquire when opening descriptors and Release when closing them. When the tracked count reaches
+// the soft limit, an admission event asks the embedder to stop admitting new sessions, before the process
+// runs out of descriptors and fails in the middle of a session.
+type Tracker struct {
+	params    TrackerParams
+	softLimit int
+	resumeAt  int
+
+	lock        sync.Mutex
+	usage       [numKinds]int
+	isAdmitting bool
+	onAdmission func(event AdmissionEvent)
+}
+
+func NewTracker(params TrackerParams) (*Tracker, error) {
+	if params.Limit <= 0 {
+		cur, _, err := Limit()
+		if err != nil {
+			return nil, err
+		}
+		params.Limit = int(cur)
+	}
+	if params.SoftLimitRatio <= 0 || params.SoftLimitRatio > 1 {
+		params.SoftLimitRatio = TrackerParamsDefault.SoftLimitRatio
+	}
+	if params.Hysteresis < 0 || params.Hysteresis >= 1 {
+		params.Hysteresis = TrackerParamsDefault.Hysteresis
+	}
+
+	softLimit := params.SoftLimit
+	if softLimit <= 0 || softLimit > params.Limit {
+		softLimit = int(float64(params.Limit) * params.SoftLimitRatio)
+	}
+	return &Tracker{
+		params:      params,
+		softLimit:   softLimit,
+		resumeAt:    int(float64(softLimit) * (1 - params.Hysteresis)),
+		isAdmitting: true,
+	}, nil
+}
+
+func (t *Tracker) OnAdmission(f func(event AdmissionEvent)) {
+	t.lock.Lock()
+	t.onAdmission = f
+	t.lock.Unlock()
+}
+
+// Acquire accounts n descriptors about to be opened. It returns false, without accounting,
+// if they would take the tracked count over Limit.
+func (t *Tracker) Acquire(kind Kind, n int) bool {
+	t.lock.Lock()
+	if t.trackedLocked()+n > t.params.Limit {
+		t.lock.Unlock()
+		return false
+	}
+
+	t.usage[kind] += n
+	event, onAdmission := t.updateLocked()
+	t.lock.Unlock()
+
+	notify(onAdmission, event)
+	return true
+}
+
+func (t *Tracker) Release(kind Kind, n int) {
+	t.lock.Lock()
+	t.usage[kind] -= n
+	if t.usage[kind] < 0 {
+		t.usage[kind] = 0
+	}
+	event, onAdmission := t.updateLocked()
+	t.lock.Unlock()
+
+	notify(onAdmission, event)
+}
+
+// IsAdmitting returns false while the tracked count is above the soft limit.
+func (t *Tracker) IsAdmitting() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.isAdmitting
+}
+
+func (t *Tracker) Stats() Stats {
+	t.lock.Lock()
+	stats := Stats{
+		ByKind:      t.usage,
+		Tracked:     t.trackedLocked(),
+		SoftLimit:   t.softLimit,
+		Limit:       t.params.Limit,
+		IsAdmitting: t.isAdmitting,
+	}
+	t.lock.Unlock()
+
+	stats.Open = numOpen()
+	used := stats.Tracked
+	if stats.Open > used {
+		used = stats.Open
+	}
+	if stats.Headroom = stats.Limit - used; stats.Headroom < 0 {
+		stats.Headroom = 0
+	}
+	return stats
+}
+
+func (t *Tracker) trackedLocked() int {
+	tracked := 0
+	for _, usage := range t.usage {
+		tracked += usage
+	}
+	return tracked
+}
+
+func (t *Tracker) updateLocked() (*AdmissionEvent, func(event AdmissionEvent)) {
+	tracked := t.trackedLocked()
+	switch {
+	case t.isAdmitting && tracked >= t.softLimit:
+		t.isAdmitting = false
+	case !t.isAdmitting && tracked < t.resumeAt:
+		t.isAdmitting = true
+	default:
+		return nil, nil
+	}
+
+	return &AdmissionEvent{
+		IsAdmitting: t.isAdmitting,
+		Usage:       tracked,
+		SoftLimit:   t.softLimit,
+		Limit:       t.params.Limit,
+	}, t.onAdmission
+}
+
+func notify(onAdmission func(event AdmissionEvent), event *AdmissionEvent) {
+	if onAdmission != nil && event != nil {
+		onAdmission(*event)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fdtrack
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrackerAdmission(t *testing.T) {
+	tr, err := NewTracker(TrackerParams{Limit: 100, SoftLimit: 80, Hysteresis: 0.1})
+	require.NoError(t, err)
+
+	var events []AdmissionEvent
+	tr.OnAdmission(func(event AdmissionEvent) {
+		events = append(events, event)
+	})
+
+	require.True(t, tr.Acquire(KindMux, 8))
+	require.True(t, tr.Acquire(KindRelaySession, 71))
+	require.True(t, tr.IsAdmitting())
+	require.Empty(t, events)
+
+	// soft limit reached
+	require.True(t, tr.Acquire(KindListener, 1))
+	require.False(t, tr.IsAdmitting())
+	require.Equal(t, []AdmissionEvent{{IsAdmitting: false, Usage: 80, SoftLimit: 80, Limit: 100}}, events)
+
+	// hard limit
+	require.False(t, tr.Acquire(KindRelaySession, 21))
+	require.True(t, tr.Acquire(KindRelaySession, 20))
+
+	stats := tr.Stats()
+	require.Equal(t, 100, stats.Tracked)
+	require.Equal(t, 91, stats.ByKind[KindRelaySession])
+	require.Zero(t, stats.Headroom)
+	require.False(t, stats.IsAdmitting)
+
+	// resumes below 72
+	tr.Release(KindRelaySession, 28)
+	require.False(t, tr.IsAdmitting())
+	tr.Release(KindRelaySession, 1)
+	require.True(t, tr.IsAdmitting())
+	require.Len(t, events, 2)
+	require.Equal(t, AdmissionEvent{IsAdmitting: true, Usage: 71, SoftLimit: 80, Limit: 100}, events[1])
+
+	// over release does not go negative
+	tr.Release(KindOther, 5)
+	require.Zero(t, tr.Stats().ByKind[KindOther])
+}
+
+func TestTrackerLimit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("RLIMIT_NOFILE is not supported")
+	}
+
+	cur, _, err := Limit()
+	require.NoError(t, err)
+
+	tr, err := NewTracker(TrackerParamsDefault)
+	require.NoError(t, err)
+	stats := tr.Stats()
+	require.Equal(t, int(cur), stats.Limit)
+	require.Equal(t, int(float64(cur)*0.8), stats.SoftLimit)
+	require.Greater(t, stats.Open, 0)
+	require.Equal(t, stats.Limit-stats.Open, stats.Headroom)
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fec
+
+import (
+	"math"
+	"sync"
+)
+
+type FECControllerParams struct {
+	// FEC overhead (FEC bytes per media byte) per unit of loss rate
+	OverheadFactor float64
+	MaxOverhead    float64
+	// RED covers a burst of this many packets at most, each redundant block adds a copy of a payload
+	MaxREDDistance int
+}
+
+var FECControllerParamsDefault = FECControllerParams{
+	OverheadFactor: 2.0,
+	MaxOverhead:    0.5,
+	MaxREDDistance: 3,
+}
+
+type FECSettings struct {
+	// FEC bytes per media byte, 0 disables FEC
+	Overhead float64
+	// number of previous payloads carried in each RED packet, 0 disables RED
+	REDDistance int
+	// protect with masks spreading each FEC packet over non consecutive packets, XOR of consecutive packets
+	// cannot recover more than one of them
+	BurstyMask bool
+}
+
+// FECController tunes FEC and RED to the loss pattern of a link, see LossPatternAnalyzer.
+// Random loss is repaired cheaply with a random mask and RED of the previous payload, bursty loss needs
+// a bursty mask and RED reaching past the typical burst.
+type FECController struct {
+	params FECControllerParams
+
+	lock     sync.Mutex
+	settings FECSettings
+	onChange func(settings FECSettings)
+}
+
+func NewFECController(params FECControllerParams) *FECController {
+	if params.OverheadFactor == 0 {
+		params.OverheadFactor = FECControllerParamsDefault.OverheadFactor
+	}
+	if params.MaxOverhead == 0 {
+		params.MaxOverhead = FECControllerParamsDefault.MaxOverhead
+	}
+	if params.MaxREDDistance == 0 {
+		params.MaxREDDistance = FECControllerParamsDefault.MaxREDDistance
+	}
+	return &FECController{
+		params: params,
+	}
+}
+
+// OnChange sets the callback called when the settings change.
+func (f *FECController) OnChange(fn func(settings FECSettings)) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.onChange = fn
+}
+
+func (f *FECController) Settings() FECSettings {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.settings
+}
+
+// Update tunes the settings for the loss stats of a window, it can be set as LossPatternAnalyzer.OnStats.
+func (f *FECController) Update(stats LossPatternStats) {
+	var settings FECSettings
+	switch stats.Pattern {
+	case LossPatternRandom:
+		settings = FECSettings{
+			Overhead:    f.overhead(stats.LossRate),
+			REDDistance: 1,
+		}
+	case LossPatternBurst:
+		settings = FECSettings{
+			Overhead:    f.overhead(stats.LossRate),
+			REDDistance: f.redDistance(stats.MeanBurstLength),
+			BurstyMask:  true,
+		}
+	}
+
+	f.lock.Lock()
+	changed := settings != f.settings
+	f.settings = settings
+	onChange := f.onChange
+	f.lock.Unlock()
+
+	if changed && onChange != nil {
+		onChange(settings)
+	}
+}
+
+func (f *FECController) overhead(lossRate float64) float64 {
+	return math.Min(lossRate*f.params.OverheadFactor, f.params.MaxOverhead)
+}
+
+func (f *FECController) redDistance(meanBurstLength float64) int {
+	distance := int(math.Ceil(meanBurstLength))
+	if distance < 1 {
+		distance = 1
+	}
+	if distance > f.params.MaxREDDistance {
+		distance = f.params.MaxREDDistance
+	}
+	return distance
+}
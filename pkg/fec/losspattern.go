@@ -0,0 +1,234 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fec
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcpfb"
+)
+
+// LossPattern classifies how losses on a link are distributed.
+type LossPattern int
+
+const (
+	LossPatternNone LossPattern = iota
+	// isolated losses, a loss makes the next one no more likely
+	LossPatternRandom
+	// consecutive packets are lost together, e.g. congested queues overflowing or wireless fades
+	LossPatternBurst
+)
+
+func (l LossPattern) String() string {
+	switch l {
+	case LossPatternNone:
+		return "NONE"
+	case LossPatternRandom:
+		return "RANDOM"
+	case LossPatternBurst:
+		return "BURST"
+	default:
+		return fmt.Sprintf("%d", int(l))
+	}
+}
+
+type LossPatternAnalyzerParams struct {
+	// packets per classification window
+	WindowPackets int
+	// losses in a window below this are classified as no loss
+	MinLosses int
+	// mean burst length at and above which losses are classified as bursty,
+	// random loss at rate p has a mean burst length of 1 / (1 - p)
+	BurstLengthThreshold float64
+}
+
+var LossPatternAnalyzerParamsDefault = LossPatternAnalyzerParams{
+	WindowPackets:        500,
+	MinLosses:            3,
+	BurstLengthThreshold: 2.0,
+}
+
+type LossPatternStats struct {
+	Pattern         LossPattern
+	NumPackets      int
+	NumLost         int
+	LossRate        float64
+	NumBursts       int
+	MeanBurstLength float64
+	MaxBurstLength  int
+	// probability of a loss following a loss, equals LossRate for random loss
+	ConditionalLossRate float64
+}
+
+// LossPatternAnalyzer classifies the loss pattern of a link over windows of packets, from per packet
+// reception in sequence number order, e.g. transport wide congestion control feedback.
+type LossPatternAnalyzer struct {
+	params LossPatternAnalyzerParams
+
+	lock          sync.Mutex
+	window        lossWindow
+	prevLost      bool
+	burstLength   int
+	stats         LossPatternStats
+	numPerPattern map[LossPattern]int
+	onStats       func(stats LossPatternStats)
+}
+
+func NewLossPatternAnalyzer(params LossPatternAnalyzerParams) *LossPatternAnalyzer {
+	if params.WindowPackets == 0 {
+		params.WindowPackets = LossPatternAnalyzerParamsDefault.WindowPackets
+	}
+	if params.MinLosses == 0 {
+		params.MinLosses = LossPatternAnalyzerParamsDefault.MinLosses
+	}
+	if params.BurstLengthThreshold == 0 {
+		params.BurstLengthThreshold = LossPatternAnalyzerParamsDefault.BurstLengthThreshold
+	}
+	return &LossPatternAnalyzer{
+		params:        params,
+		numPerPattern: make(map[LossPattern]int),
+	}
+}
+
+// OnStats sets the callback called with the stats of every completed window.
+func (l *LossPatternAnalyzer) OnStats(f func(stats LossPatternStats)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.onStats = f
+}
+
+// OnPacket records the reception of the next packet in sequence number order.
+func (l *LossPatternAnalyzer) OnPacket(received bool) {
+	l.lock.Lock()
+	completed := l.onPacketLocked(received)
+	stats := l.stats
+	onStats := l.onStats
+	l.lock.Unlock()
+
+	if completed && onStats != nil {
+		onStats(stats)
+	}
+}
+
+// OnTWCC records the packets of transport wide congestion control feedback, see rtcpfb.ParseTWCC.
+func (l *LossPatternAnalyzer) OnTWCC(packets []rtcpfb.TWCCPacketStatus) {
+	l.lock.Lock()
+	var completed []LossPatternStats
+	for _, p := range packets {
+		if l.onPacketLocked(p.Received) {
+			completed = append(completed, l.stats)
+		}
+	}
+	onStats := l.onStats
+	l.lock.Unlock()
+
+	if onStats != nil {
+		for _, stats := range completed {
+			onStats(stats)
+		}
+	}
+}
+
+// Stats returns the stats of the last completed window.
+func (l *LossPatternAnalyzer) Stats() LossPatternStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.stats
+}
+
+// NumWindows returns the number of completed windows classified as the pattern.
+func (l *LossPatternAnalyzer) NumWindows(pattern LossPattern) int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.numPerPattern[pattern]
+}
+
+// onPacketLocked returns true if the packet completed a window
+func (l *LossPatternAnalyzer) onPacketLocked(received bool) bool {
+	w := &l.window
+	w.numPackets++
+	if l.prevLost {
+		w.numAfterLoss++
+		if !received {
+			w.numLossAfterLoss++
+		}
+	}
+
+	if received {
+		l.burstLength = 0
+	} else {
+		w.numLost++
+		if l.burstLength == 0 {
+			w.numBursts++
+		}
+		l.burstLength++
+		if l.burstLength > w.maxBurstLength {
+			w.maxBurstLength = l.burstLength
+		}
+	}
+	l.prevLost = !received
+
+	if w.numPackets < l.params.WindowPackets {
+		return false
+	}
+
+	l.stats = w.stats(l.params)
+	l.numPerPattern[l.stats.Pattern]++
+	l.window = lossWindow{}
+	return true
+}
+
+// ------------------------------------------------
+
+type lossWindow struct {
+	numPackets       int
+	numLost          int
+	numBursts        int
+	maxBurstLength   int
+	numAfterLoss     int
+	numLossAfterLoss int
+}
+
+func (w *lossWindow) stats(params LossPatternAnalyzerParams) LossPatternStats {
+	stats := LossPatternStats{
+		NumPackets:     w.numPackets,
+		NumLost:        w.numLost,
+		NumBursts:      w.numBursts,
+		MaxBurstLength: w.maxBurstLength,
+	}
+	if w.numPackets != 0 {
+		stats.LossRate = float64(w.numLost) / float64(w.numPackets)
+	}
+	if w.numBursts != 0 {
+		stats.MeanBurstLength = float64(w.numLost) / float64(w.numBursts)
+	}
+	if w.numAfterLoss != 0 {
+		stats.ConditionalLossRate = float64(w.numLossAfterLoss) / float64(w.numAfterLoss)
+	}
+
+	switch {
+	case w.numLost < params.MinLosses:
+		stats.Pattern = LossPatternNone
+	case stats.MeanBurstLength >= params.BurstLengthThreshold:
+		stats.Pattern = LossPatternBurst
+	default:
+		stats.Pattern = LossPatternRandom
+	}
+	return stats
+}
@@ -0,0 +1,71 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcpfb"
+)
+
+func TestLossPatternAnalyzer(t *testing.T) {
+	l := NewLossPatternAnalyzer(LossPatternAnalyzerParams{WindowPackets: 100})
+	c := NewFECController(FECControllerParams{})
+	l.OnStats(c.Update)
+	var changes []FECSettings
+	c.OnChange(func(settings FECSettings) {
+		changes = append(changes, settings)
+	})
+
+	// clean window
+	for i := 0; i < 100; i++ {
+		l.OnPacket(true)
+	}
+	require.Equal(t, LossPatternNone, l.Stats().Pattern)
+	require.Empty(t, changes)
+
+	// isolated losses, every 10th packet
+	for i := 0; i < 100; i++ {
+		l.OnPacket(i%10 != 0)
+	}
+	stats := l.Stats()
+	require.Equal(t, LossPatternRandom, stats.Pattern)
+	require.Equal(t, 10, stats.NumLost)
+	require.Equal(t, 10, stats.NumBursts)
+	require.Equal(t, 1, stats.MaxBurstLength)
+	require.Zero(t, stats.ConditionalLossRate)
+	require.Equal(t, FECSettings{Overhead: 0.2, REDDistance: 1}, c.Settings())
+
+	// bursts of 5 packets, through TWCC feedback
+	packets := make([]rtcpfb.TWCCPacketStatus, 0, 100)
+	for i := 0; i < 100; i++ {
+		packets = append(packets, rtcpfb.TWCCPacketStatus{SequenceNumber: uint16(i), Received: i%25 >= 5})
+	}
+	l.OnTWCC(packets)
+	stats = l.Stats()
+	require.Equal(t, LossPatternBurst, stats.Pattern)
+	require.Equal(t, 20, stats.NumLost)
+	require.Equal(t, 4, stats.NumBursts)
+	require.Equal(t, 5.0, stats.MeanBurstLength)
+	require.Equal(t, 0.8, stats.ConditionalLossRate)
+	require.Equal(t, FECSettings{Overhead: 0.4, REDDistance: 3, BurstyMask: true}, c.Settings())
+
+	require.Len(t, changes, 2)
+	require.Equal(t, 1, l.NumWindows(LossPatternNone))
+	require.Equal(t, 1, l.NumWindows(LossPatternRandom))
+	require.Equal(t, 1, l.NumWindows(LossPatternBurst))
+}
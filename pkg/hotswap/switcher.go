@@ -0,0 +1,296 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotswap
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+	"github.com/livekit/mediatransportutil/pkg/munger"
+)
+
+const (
+	// late packets of a new source are checked for this many packets after a switch
+	maxLateCheckPackets = 1024
+)
+
+type State int
+
+const (
+	// no source selected
+	StateIdle State = iota
+	StateForwarding
+	// a switch is pending, the current source is forwarded until the new one sends a key frame
+	StateSwitching
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "IDLE"
+	case StateForwarding:
+		return "FORWARDING"
+	case StateSwitching:
+		return "SWITCHING"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type SwitcherParams struct {
+	// codec of the sources, CodecUnknown for audio, where a switch completes on the first packet of the new source
+	Codec     keyframe.Codec
+	ClockRate uint32
+	// SSRC of the outgoing stream
+	SSRC             uint32
+	MaxTimestampJump time.Duration
+	// key frame requests are repeated at this interval while a switch is pending
+	KeyFrameRequestInterval time.Duration
+}
+
+var SwitcherParamsDefault = SwitcherParams{
+	KeyFrameRequestInterval: 500 * time.Millisecond,
+}
+
+type SwitchEvent struct {
+	From string
+	To   string
+	// time from the call to Switch to the first forwarded packet of the new source
+	Delay time.Duration
+}
+
+// SwitcherStats are of the outgoing stream, they continue across switches.
+type SwitcherStats struct {
+	NumPackets  int
+	NumBytes    int
+	NumSwitches int
+	// packets of the new source dropped waiting for a key frame
+	NumDroppedSwitching int
+	NumKeyFrameRequests int
+	LastSwitchDelay     time.Duration
+}
+
+// Switcher forwards one of several publisher streams to a subscriber track and switches between them,
+// for example on an active speaker change, without a gap or discontinuity visible to the subscriber.
+//
+// A switch is made before break: the current source keeps being forwarded until the new source sends
+// a key frame, then the outgoing stream continues from it with contiguous sequence numbers and timestamps.
+// Packets of every source are passed to Forward, those of sources not forwarded are dropped.
+type Switcher struct {
+	params SwitcherParams
+
+	lock       sync.Mutex
+	munger     *munger.Munger
+	current    string
+	pending    string
+	state      State
+	switchedAt time.Time
+	requestAt  time.Time
+	// packets of the current source sent before its first forwarded one are dropped when they arrive late,
+	// checked for the first packets after a switch
+	startSN       uint16
+	numSinceStart int
+	stats         SwitcherStats
+
+	onKeyFrameRequest func(sourceID string)
+	onSwitched        func(event SwitchEvent)
+}
+
+func NewSwitcher(params SwitcherParams) *Switcher {
+	if params.KeyFrameRequestInterval <= 0 {
+		params.KeyFrameRequestInterval = SwitcherParamsDefault.KeyFrameRequestInterval
+	}
+	return &Switcher{
+		params: params,
+		munger: munger.NewMunger(munger.MungerParams{
+			ClockRate:        params.ClockRate,
+			MaxTimestampJump: params.MaxTimestampJump,
+		}),
+	}
+}
+
+// OnKeyFrameRequest sets a callback invoked when a key frame is needed from a source, the caller sends a PLI
+// to its publisher.
+func (s *Switcher) OnKeyFrameRequest(f func(sourceID string)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onKeyFrameRequest = f
+}
+
+// OnSwitched sets a callback invoked with the first forwarded packet of a new source.
+func (s *Switcher) OnSwitched(f func(event SwitchEvent)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.onSwitched = f
+}
+
+func (s *Switcher) State() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.state
+}
+
+// Current returns the forwarded source, empty if none is.
+func (s *Switcher) Current() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.current
+}
+
+// Pending returns the source being switched to, if a switch is pending.
+func (s *Switcher) Pending() (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.pending, s.state == StateSwitching
+}
+
+func (s *Switcher) Stats() SwitcherStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stats
+}
+
+// Switch starts a switch to sourceID, replacing a pending one. Switching to the current source cancels
+// a pending switch.
+func (s *Switcher) Switch(sourceID string) {
+	s.switchAt(sourceID, time.Now())
+}
+
+func (s *Switcher) switchAt(sourceID string, now time.Time) {
+	s.lock.Lock()
+	if sourceID == s.current && s.state != StateIdle {
+		s.pending = ""
+		s.state = StateForwarding
+		s.lock.Unlock()
+		return
+	}
+	if s.state == StateSwitching && sourceID == s.pending {
+		s.lock.Unlock()
+		return
+	}
+
+	s.pending = sourceID
+	s.state = StateSwitching
+	s.switchedAt = now
+	onKeyFrameRequest := s.requestKeyFrameLocked(now)
+	s.lock.Unlock()
+
+	if onKeyFrameRequest != nil {
+		onKeyFrameRequest(sourceID)
+	}
+}
+
+// Stop stops forwarding, a later switch starts over as the first one did, on a key frame of the new source.
+func (s *Switcher) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.current = ""
+	s.pending = ""
+	s.state = StateIdle
+	s.munger.ResyncOnNextPacket()
+}
+
+// Forward returns the packet to send to the subscriber for a packet of sourceID received at the given time,
+// false if it is not forwarded. The returned packet is a copy with the outgoing header, sharing the payload.
+func (s *Switcher) Forward(sourceID string, pkt *rtp.Packet, at time.Time) (*rtp.Packet, bool) {
+	s.lock.Lock()
+	if s.state == StateSwitching && sourceID == s.pending {
+		if !s.isStart(pkt) {
+			s.stats.NumDroppedSwitching++
+			var onKeyFrameRequest func(sourceID string)
+			if at.Sub(s.requestAt) >= s.params.KeyFrameRequestInterval {
+				onKeyFrameRequest = s.requestKeyFrameLocked(at)
+			}
+			s.lock.Unlock()
+
+			if onKeyFrameRequest != nil {
+				onKeyFrameRequest(sourceID)
+			}
+			return nil, false
+		}
+
+		event := SwitchEvent{From: s.current, To: sourceID, Delay: at.Sub(s.switchedAt)}
+		s.current = sourceID
+		s.pending = ""
+		s.state = StateForwarding
+		s.startSN = pkt.SequenceNumber
+		s.numSinceStart = 0
+		s.munger.ResyncOnNextPacket()
+		s.stats.NumSwitches++
+		s.stats.LastSwitchDelay = event.Delay
+		onSwitched := s.onSwitched
+
+		out := s.forwardLocked(pkt, at)
+		s.lock.Unlock()
+
+		if onSwitched != nil {
+			onSwitched(event)
+		}
+		return out, true
+	}
+
+	if s.state == StateIdle || sourceID != s.current {
+		s.lock.Unlock()
+		return nil, false
+	}
+	if s.numSinceStart < maxLateCheckPackets {
+		if before := s.startSN - pkt.SequenceNumber; before != 0 && before < (1<<15) {
+			// sent before the switch, the outgoing sequence numbers before the switch belong to the previous source
+			s.lock.Unlock()
+			return nil, false
+		}
+		s.numSinceStart++
+	}
+	out := s.forwardLocked(pkt, at)
+	s.lock.Unlock()
+	return out, true
+}
+
+func (s *Switcher) forwardLocked(pkt *rtp.Packet, at time.Time) *rtp.Packet {
+	out := *pkt
+	out.Header.SequenceNumber, out.Header.Timestamp = s.munger.Update(pkt.SequenceNumber, pkt.Timestamp, at)
+	out.Header.SSRC = s.params.SSRC
+
+	s.stats.NumPackets++
+	s.stats.NumBytes += pkt.MarshalSize()
+	return &out
+}
+
+func (s *Switcher) requestKeyFrameLocked(now time.Time) func(sourceID string) {
+	if s.params.Codec == keyframe.CodecUnknown {
+		return nil
+	}
+	s.requestAt = now
+	s.stats.NumKeyFrameRequests++
+	return s.onKeyFrameRequest
+}
+
+func (s *Switcher) isStart(pkt *rtp.Packet) bool {
+	if s.params.Codec == keyframe.CodecUnknown {
+		return true
+	}
+	return keyframe.IsKeyFrameStart(s.params.Codec, pkt.Payload)
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hotswap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+)
+
+var (
+	vp8KeyFrame = []byte{0x10, 0x00}
+	vp8Delta    = []byte{0x10, 0x01}
+)
+
+func packet(sn uint16, ts uint32, payload []byte) *rtp.Packet {
+	return &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: sn, Timestamp: ts, SSRC: 1}, Payload: payload}
+}
+
+func TestSwitcher(t *testing.T) {
+	s := NewSwitcher(SwitcherParams{Codec: keyframe.CodecVP8, ClockRate: 90000, SSRC: 100})
+	var requests []string
+	s.OnKeyFrameRequest(func(sourceID string) {
+		requests = append(requests, sourceID)
+	})
+	var events []SwitchEvent
+	s.OnSwitched(func(event SwitchEvent) {
+		events = append(events, event)
+	})
+	now := time.Now()
+
+	// nothing forwarded before the first switch
+	_, ok := s.Forward("a", packet(10, 1000, vp8KeyFrame), now)
+	require.False(t, ok)
+	require.Equal(t, StateIdle, s.State())
+
+	s.switchAt("a", now)
+	require.Equal(t, []string{"a"}, requests)
+	pending, ok := s.Pending()
+	require.True(t, ok)
+	require.Equal(t, "a", pending)
+
+	_, ok = s.Forward("a", packet(11, 1000, vp8Delta), now)
+	require.False(t, ok)
+	out, ok := s.Forward("a", packet(12, 4000, vp8KeyFrame), now.Add(10*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(12), out.SequenceNumber)
+	require.Equal(t, uint32(4000), out.Timestamp)
+	require.Equal(t, uint32(100), out.SSRC)
+	require.Equal(t, StateForwarding, s.State())
+	require.Len(t, events, 1)
+	require.Equal(t, SwitchEvent{To: "a", Delay: 10 * time.Millisecond}, events[0])
+
+	out, ok = s.Forward("a", packet(13, 7000, vp8Delta), now.Add(43*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(13), out.SequenceNumber)
+
+	// a keeps being forwarded until b sends a key frame
+	s.switchAt("b", now.Add(50*time.Millisecond))
+	require.Equal(t, []string{"a", "b"}, requests)
+	_, ok = s.Forward("b", packet(500, 90000, vp8Delta), now.Add(60*time.Millisecond))
+	require.False(t, ok)
+	out, ok = s.Forward("a", packet(14, 10000, vp8Delta), now.Add(76*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(14), out.SequenceNumber)
+
+	// requests are repeated while the switch is pending
+	_, ok = s.Forward("b", packet(501, 90000, vp8Delta), now.Add(600*time.Millisecond))
+	require.False(t, ok)
+	require.Equal(t, []string{"a", "b", "b"}, requests)
+
+	// the outgoing stream continues from a, its timestamp following the wall clock since its first packet
+	out, ok = s.Forward("b", packet(502, 93000, vp8KeyFrame), now.Add(676*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(15), out.SequenceNumber)
+	require.Equal(t, uint32(4000+666*90), out.Timestamp)
+	require.Equal(t, "b", s.Current())
+	require.Len(t, events, 2)
+	require.Equal(t, "a", events[1].From)
+	require.Equal(t, 626*time.Millisecond, events[1].Delay)
+
+	// late packets of a and of b before its key frame are dropped
+	_, ok = s.Forward("a", packet(15, 13000, vp8Delta), now.Add(680*time.Millisecond))
+	require.False(t, ok)
+	_, ok = s.Forward("b", packet(501, 90000, vp8Delta), now.Add(680*time.Millisecond))
+	require.False(t, ok)
+	out, ok = s.Forward("b", packet(503, 96000, vp8Delta), now.Add(710*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(16), out.SequenceNumber)
+	require.Equal(t, uint32(4000+666*90+3000), out.Timestamp)
+
+	stats := s.Stats()
+	require.Equal(t, 2, stats.NumSwitches)
+	require.Equal(t, 5, stats.NumPackets)
+	require.Equal(t, 3, stats.NumDroppedSwitching)
+	require.Equal(t, 3, stats.NumKeyFrameRequests)
+	require.Equal(t, 626*time.Millisecond, stats.LastSwitchDelay)
+}
+
+func TestSwitcherCancel(t *testing.T) {
+	s := NewSwitcher(SwitcherParams{Codec: keyframe.CodecVP8, ClockRate: 90000})
+	now := time.Now()
+
+	s.switchAt("a", now)
+	_, ok := s.Forward("a", packet(1, 1000, vp8KeyFrame), now)
+	require.True(t, ok)
+
+	s.switchAt("b", now)
+	s.switchAt("a", now)
+	_, ok = s.Pending()
+	require.False(t, ok)
+	_, ok = s.Forward("b", packet(100, 5000, vp8KeyFrame), now)
+	require.False(t, ok)
+	require.Equal(t, "a", s.Current())
+
+	s.Stop()
+	require.Equal(t, StateIdle, s.State())
+	_, ok = s.Forward("a", packet(2, 4000, vp8Delta), now)
+	require.False(t, ok)
+}
+
+func TestSwitcherAudio(t *testing.T) {
+	s := NewSwitcher(SwitcherParams{ClockRate: 48000})
+	numRequests := 0
+	s.OnKeyFrameRequest(func(_ string) {
+		numRequests++
+	})
+	now := time.Now()
+
+	s.switchAt("a", now)
+	out, ok := s.Forward("a", packet(1, 960, []byte{1}), now)
+	require.True(t, ok)
+	require.Equal(t, uint16(1), out.SequenceNumber)
+
+	// any packet of the new source completes the switch
+	s.switchAt("b", now)
+	out, ok = s.Forward("b", packet(300, 50000, []byte{1}), now.Add(20*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(2), out.SequenceNumber)
+	require.Equal(t, uint32(960+960), out.Timestamp)
+	require.Equal(t, 0, numRequests)
+}
@@ -0,0 +1,260 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type GathererParams struct {
+	// gathering is considered complete after this long, 0 waits for the ICE agent
+	Timeout time.Duration
+	// gathering is considered complete once a server reflexive candidate has been gathered
+	// for every IP family that has a host candidate. Relay candidates may still be missing.
+	CompleteAfterFirstSrflx bool
+}
+
+type CompleteReason int
+
+const (
+	// the ICE agent finished gathering
+	CompleteReasonGathered CompleteReason = iota
+	// gathering timed out, candidates gathered later are late
+	CompleteReasonTimeout
+	// a server reflexive candidate was gathered for every IP family, candidates gathered later are late
+	CompleteReasonSrflxPerFamily
+)
+
+func (r CompleteReason) String() string {
+	switch r {
+	case CompleteReasonGathered:
+		return "GATHERED"
+	case CompleteReasonTimeout:
+		return "TIMEOUT"
+	case CompleteReasonSrflxPerFamily:
+		return "SRFLX_PER_FAMILY"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type CompleteEvent struct {
+	Reason        CompleteReason
+	Elapsed       time.Duration
+	NumCandidates int
+	// true when a relay candidate had been gathered at completion,
+	// early completion trades relay candidates for join time
+	HasRelay bool
+}
+
+// Gatherer follows local candidate gathering of a peer connection and declares gathering complete early
+// based on GathererParams, so that signalling does not wait for slow STUN/TURN servers.
+// Candidates gathered after an early completion are reported as late, they can still be trickled.
+//
+// Typical use is pc.OnICECandidate(gatherer.HandleICECandidate) before SetLocalDescription and
+// gatherer.Start() right after.
+type Gatherer struct {
+	params GathererParams
+
+	lock            sync.Mutex
+	startedAt       time.Time
+	timer           *time.Timer
+	numCandidates   int
+	numLate         int
+	hostFamilies    map[bool]bool
+	srflxFamilies   map[bool]bool
+	hasRelay        bool
+	isComplete      bool
+	onComplete      func(event CompleteEvent)
+	onLateCandidate func(candidate *webrtc.ICECandidate)
+
+	done chan struct{}
+}
+
+func NewGatherer(params GathererParams) *Gatherer {
+	return &Gatherer{
+		params:        params,
+		hostFamilies:  make(map[bool]bool),
+		srflxFamilies: make(map[bool]bool),
+		done:          make(chan struct{}),
+	}
+}
+
+func (g *Gatherer) OnComplete(f func(event CompleteEvent)) {
+	g.lock.Lock()
+	g.onComplete = f
+	g.lock.Unlock()
+}
+
+// OnLateCandidate sets a callback invoked for candidates gathered after an early completion.
+func (g *Gatherer) OnLateCandidate(f func(candidate *webrtc.ICECandidate)) {
+	g.lock.Lock()
+	g.onLateCandidate = f
+	g.lock.Unlock()
+}
+
+// Done is closed when gathering is complete.
+func (g *Gatherer) Done() <-chan struct{} {
+	return g.done
+}
+
+func (g *Gatherer) NumLateCandidates() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.numLate
+}
+
+func (g *Gatherer) Start() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if !g.startedAt.IsZero() || g.isComplete {
+		return
+	}
+	g.startedAt = time.Now()
+	if g.params.Timeout > 0 {
+		g.timer = time.AfterFunc(g.params.Timeout, g.handleTimeout)
+	}
+}
+
+// Stop releases the timer, it does not complete gathering.
+func (g *Gatherer) Stop() {
+	g.lock.Lock()
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	g.lock.Unlock()
+}
+
+func (g *Gatherer) HandleICECandidate(candidate *webrtc.ICECandidate) {
+	g.handleCandidate(candidate, time.Now())
+}
+
+func (g *Gatherer) handleCandidate(candidate *webrtc.ICECandidate, now time.Time) {
+	g.lock.Lock()
+	if candidate == nil {
+		// end of gathering
+		event, completed := g.completeLocked(CompleteReasonGathered, now)
+		onComplete := g.onComplete
+		g.lock.Unlock()
+
+		if completed && onComplete != nil {
+			onComplete(event)
+		}
+		return
+	}
+
+	if g.isComplete {
+		g.numLate++
+		onLateCandidate := g.onLateCandidate
+		g.lock.Unlock()
+
+		if onLateCandidate != nil {
+			onLateCandidate(candidate)
+		}
+		return
+	}
+
+	g.numCandidates++
+	isIPv4, ok := candidateFamily(candidate)
+	switch candidate.Typ {
+	case webrtc.ICECandidateTypeHost:
+		if ok {
+			g.hostFamilies[isIPv4] = true
+		}
+	case webrtc.ICECandidateTypeSrflx:
+		if ok {
+			g.srflxFamilies[isIPv4] = true
+		}
+	case webrtc.ICECandidateTypeRelay:
+		g.hasRelay = true
+	}
+
+	var event CompleteEvent
+	var completed bool
+	if g.params.CompleteAfterFirstSrflx && g.hasSrflxPerFamilyLocked() {
+		event, completed = g.completeLocked(CompleteReasonSrflxPerFamily, now)
+	}
+	onComplete := g.onComplete
+	g.lock.Unlock()
+
+	if completed && onComplete != nil {
+		onComplete(event)
+	}
+}
+
+func (g *Gatherer) handleTimeout() {
+	g.lock.Lock()
+	event, completed := g.completeLocked(CompleteReasonTimeout, time.Now())
+	onComplete := g.onComplete
+	g.lock.Unlock()
+
+	if completed && onComplete != nil {
+		onComplete(event)
+	}
+}
+
+func (g *Gatherer) hasSrflxPerFamilyLocked() bool {
+	if len(g.hostFamilies) == 0 {
+		return false
+	}
+	for family := range g.hostFamilies {
+		if !g.srflxFamilies[family] {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Gatherer) completeLocked(reason CompleteReason, now time.Time) (CompleteEvent, bool) {
+	if g.isComplete {
+		return CompleteEvent{}, false
+	}
+
+	g.isComplete = true
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	close(g.done)
+
+	event := CompleteEvent{
+		Reason:        reason,
+		NumCandidates: g.numCandidates,
+		HasRelay:      g.hasRelay,
+	}
+	if !g.startedAt.IsZero() {
+		event.Elapsed = now.Sub(g.startedAt)
+	}
+	return event, true
+}
+
+// ------------------------------------------------
+
+// candidateFamily returns true for IPv4 candidates, false if the family cannot be determined (e.g. mDNS)
+func candidateFamily(candidate *webrtc.ICECandidate) (bool, bool) {
+	ip := net.ParseIP(candidate.Address)
+	if ip == nil {
+		return false, false
+	}
+	return ip.To4() != nil, true
+}
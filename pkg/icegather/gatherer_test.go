@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGathererSrflxPerFamily(t *testing.T) {
+	g := NewGatherer(GathererParams{CompleteAfterFirstSrflx: true})
+
+	var events []CompleteEvent
+	g.OnComplete(func(event CompleteEvent) {
+		events = append(events, event)
+	})
+	var late []*webrtc.ICECandidate
+	g.OnLateCandidate(func(candidate *webrtc.ICECandidate) {
+		late = append(late, candidate)
+	})
+	g.Start()
+	defer g.Stop()
+
+	now := time.Now()
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "10.0.0.1"}, now)
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "fd00::1"}, now)
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeSrflx, Address: "1.2.3.4"}, now)
+	require.Empty(t, events)
+
+	// IPv6 srflx completes
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeSrflx, Address: "2001:db8::1"}, now)
+	require.Len(t, events, 1)
+	require.Equal(t, CompleteReasonSrflxPerFamily, events[0].Reason)
+	require.Equal(t, 4, events[0].NumCandidates)
+	require.False(t, events[0].HasRelay)
+
+	select {
+	case <-g.Done():
+	default:
+		t.Fatal("gathering should be done")
+	}
+
+	// later candidates are late, end of gathering does not complete again
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay, Address: "5.6.7.8"}, now)
+	g.handleCandidate(nil, now)
+	require.Len(t, late, 1)
+	require.Equal(t, 1, g.NumLateCandidates())
+	require.Len(t, events, 1)
+}
+
+func TestGathererTimeout(t *testing.T) {
+	g := NewGatherer(GathererParams{Timeout: 20 * time.Millisecond})
+
+	events := make(chan CompleteEvent, 1)
+	g.OnComplete(func(event CompleteEvent) {
+		events <- event
+	})
+	g.Start()
+
+	select {
+	case event := <-events:
+		require.Equal(t, CompleteReasonTimeout, event.Reason)
+	case <-time.After(time.Second):
+		t.Fatal("gathering should have timed out")
+	}
+}
+
+func TestGathererGathered(t *testing.T) {
+	g := NewGatherer(GathererParams{Timeout: time.Minute, CompleteAfterFirstSrflx: true})
+
+	var events []CompleteEvent
+	g.OnComplete(func(event CompleteEvent) {
+		events = append(events, event)
+	})
+	g.Start()
+
+	now := time.Now()
+	// mDNS host candidate does not determine a family
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "abc.local"}, now)
+	g.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay, Address: "5.6.7.8"}, now)
+	g.handleCandidate(nil, now)
+
+	require.Len(t, events, 1)
+	require.Equal(t, CompleteReasonGathered, events[0].Reason)
+	require.True(t, events[0].HasRelay)
+}
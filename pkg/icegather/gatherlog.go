@@ -0,0 +1,205 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type GatherLogParams struct {
+	// record every session, otherwise only those enabled with Enable
+	AllSessions bool
+	// records are kept for this long after the last candidate of a session
+	Retention time.Duration
+	// sessions kept, the least recently active are forgotten beyond this
+	MaxSessions int
+	// candidates recorded per session, later ones are counted but not recorded
+	MaxCandidates int
+}
+
+var GatherLogParamsDefault = GatherLogParams{
+	Retention:     15 * time.Minute,
+	MaxSessions:   1000,
+	MaxCandidates: 100,
+}
+
+type CandidateRecord struct {
+	At time.Time
+	// gathered locally, otherwise received from the remote peer
+	IsLocal   bool
+	Candidate string
+	// reason the candidate was filtered, empty if it was used
+	FilterReason string
+}
+
+func (c CandidateRecord) IsFiltered() bool {
+	return c.FilterReason != ""
+}
+
+// SessionGatherLog is the record of candidates of a session, in the order they were seen.
+type SessionGatherLog struct {
+	SessionID    string
+	Candidates   []CandidateRecord
+	NumDropped   int
+	LastActivity time.Time
+}
+
+type sessionGatherLog struct {
+	candidates   []CandidateRecord
+	numDropped   int
+	lastActivity time.Time
+}
+
+// GatherLog records the local and remote candidates of sessions, including those filtered and why, for a limited
+// time, so that a failed connection can be looked into by session ID without raising log levels.
+// Recording is opt-in, for all sessions or for those enabled individually, for example for a user reporting issues.
+type GatherLog struct {
+	params GatherLogParams
+
+	lock      sync.Mutex
+	enabled   map[string]struct{}
+	sessions  map[string]*sessionGatherLog
+	lastSweep time.Time
+}
+
+func NewGatherLog(params GatherLogParams) *GatherLog {
+	if params.Retention <= 0 {
+		params.Retention = GatherLogParamsDefault.Retention
+	}
+	if params.MaxSessions <= 0 {
+		params.MaxSessions = GatherLogParamsDefault.MaxSessions
+	}
+	if params.MaxCandidates <= 0 {
+		params.MaxCandidates = GatherLogParamsDefault.MaxCandidates
+	}
+	return &GatherLog{
+		params:   params,
+		enabled:  make(map[string]struct{}),
+		sessions: make(map[string]*sessionGatherLog),
+	}
+}
+
+// Enable records the candidates of a session from now on.
+func (g *GatherLog) Enable(sessionID string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.enabled[sessionID] = struct{}{}
+}
+
+// RecordLocal records a gathered candidate, filtered for the given reason, if not empty.
+func (g *GatherLog) RecordLocal(sessionID string, candidate *webrtc.ICECandidate, filterReason string) {
+	if candidate == nil {
+		return
+	}
+	g.record(sessionID, CandidateRecord{
+		At:           time.Now(),
+		IsLocal:      true,
+		Candidate:    candidate.ToJSON().Candidate,
+		FilterReason: filterReason,
+	})
+}
+
+// RecordRemote records a candidate of the remote peer, filtered for the given reason, if not empty.
+func (g *GatherLog) RecordRemote(sessionID string, candidate webrtc.ICECandidateInit, filterReason string) {
+	g.record(sessionID, CandidateRecord{
+		At:           time.Now(),
+		Candidate:    candidate.Candidate,
+		FilterReason: filterReason,
+	})
+}
+
+// Session returns the record of a session, false if there is none.
+func (g *GatherLog) Session(sessionID string) (SessionGatherLog, bool) {
+	return g.session(sessionID, time.Now())
+}
+
+// Remove forgets a session and stops recording it.
+func (g *GatherLog) Remove(sessionID string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.enabled, sessionID)
+	delete(g.sessions, sessionID)
+}
+
+func (g *GatherLog) record(sessionID string, record CandidateRecord) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.enabled[sessionID]; !ok && !g.params.AllSessions {
+		return
+	}
+
+	s := g.sessions[sessionID]
+	if s == nil {
+		if len(g.sessions) >= g.params.MaxSessions {
+			g.evictLocked(record.At)
+		}
+		s = &sessionGatherLog{}
+		g.sessions[sessionID] = s
+	}
+	s.lastActivity = record.At
+	if len(s.candidates) >= g.params.MaxCandidates {
+		s.numDropped++
+		return
+	}
+	s.candidates = append(s.candidates, record)
+}
+
+func (g *GatherLog) session(sessionID string, now time.Time) (SessionGatherLog, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	s := g.sessions[sessionID]
+	if s == nil || now.Sub(s.lastActivity) > g.params.Retention {
+		return SessionGatherLog{}, false
+	}
+	return SessionGatherLog{
+		SessionID:    sessionID,
+		Candidates:   append([]CandidateRecord{}, s.candidates...),
+		NumDropped:   s.numDropped,
+		LastActivity: s.lastActivity,
+	}, true
+}
+
+// evictLocked makes room for a session. Expired sessions are forgotten, swept at most once per retention period,
+// otherwise the least recently active one.
+func (g *GatherLog) evictLocked(now time.Time) {
+	if now.Sub(g.lastSweep) >= g.params.Retention {
+		g.lastSweep = now
+		for id, s := range g.sessions {
+			if now.Sub(s.lastActivity) > g.params.Retention {
+				delete(g.sessions, id)
+				delete(g.enabled, id)
+			}
+		}
+		if len(g.sessions) < g.params.MaxSessions {
+			return
+		}
+	}
+
+	oldestID := ""
+	var oldest time.Time
+	for id, s := range g.sessions {
+		if oldestID == "" || s.lastActivity.Before(oldest) {
+			oldestID, oldest = id, s.lastActivity
+		}
+	}
+	delete(g.sessions, oldestID)
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatherLog(t *testing.T) {
+	g := NewGatherLog(GatherLogParams{MaxCandidates: 2})
+
+	local := &webrtc.ICECandidate{Foundation: "1", Priority: 1, Address: "192.0.2.1", Protocol: webrtc.ICEProtocolUDP, Port: 1000, Typ: webrtc.ICECandidateTypeHost, Component: 1}
+	remote := webrtc.ICECandidateInit{Candidate: "candidate:2 1 udp 1 198.51.100.1 2000 typ srflx raddr 0.0.0.0 rport 0"}
+
+	// not enabled
+	g.RecordLocal("s1", local, "")
+	_, ok := g.Session("s1")
+	require.False(t, ok)
+
+	g.Enable("s1")
+	g.RecordLocal("s1", local, "")
+	g.RecordRemote("s1", remote, "pinned relay")
+	g.RecordRemote("s1", remote, "")
+
+	s, ok := g.Session("s1")
+	require.True(t, ok)
+	require.Len(t, s.Candidates, 2)
+	require.Equal(t, 1, s.NumDropped)
+	require.True(t, s.Candidates[0].IsLocal)
+	require.Equal(t, local.ToJSON().Candidate, s.Candidates[0].Candidate)
+	require.False(t, s.Candidates[0].IsFiltered())
+	require.False(t, s.Candidates[1].IsLocal)
+	require.Equal(t, "pinned relay", s.Candidates[1].FilterReason)
+
+	// expired
+	_, ok = g.session("s1", s.LastActivity.Add(GatherLogParamsDefault.Retention+time.Second))
+	require.False(t, ok)
+
+	g.Remove("s1")
+	_, ok = g.Session("s1")
+	require.False(t, ok)
+}
+
+func TestGatherLogEviction(t *testing.T) {
+	g := NewGatherLog(GatherLogParams{AllSessions: true, MaxSessions: 2})
+	now := time.Now()
+	for i, id := range []string{"s1", "s2", "s1", "s3"} {
+		g.record(id, CandidateRecord{At: now.Add(time.Duration(i) * time.Second)})
+	}
+
+	// the least recently active is forgotten
+	_, ok := g.Session("s2")
+	require.False(t, ok)
+	for _, id := range []string{"s1", "s3"} {
+		_, ok = g.Session(id)
+		require.True(t, ok)
+	}
+}
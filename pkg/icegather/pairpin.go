@@ -0,0 +1,139 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	// webrtc has no name for the zero candidate type
+	anyCandidateType webrtc.ICECandidateType = 0
+)
+
+var (
+	ErrPairNotAllowed = errors.New("candidate pair not allowed")
+)
+
+// PairPin pins the candidate types of the pair selected by a single session, for example relay on
+// the local side for a compliance recording session, without affecting other sessions of the node.
+//
+// Local relay pinning is enforced by gathering relay candidates only, see Configure. Other types are
+// enforced by filtering candidates exchanged in signalling, with FilterLocal/FilterRemote for trickled
+// candidates and FilterSDP for descriptions. Peer reflexive candidates learned from connectivity checks
+// bypass signalling, CheckPair verifies the selected pair and is the check to rely on for compliance.
+type PairPin struct {
+	// candidate type required on the local side, the zero value allows any
+	Local webrtc.ICECandidateType
+	// candidate type required on the remote side, the zero value allows any
+	Remote webrtc.ICECandidateType
+}
+
+func (p PairPin) IsSet() bool {
+	return p.Local != anyCandidateType || p.Remote != anyCandidateType
+}
+
+func (p PairPin) String() string {
+	return fmt.Sprintf("local: %s, remote: %s", pinnedType(p.Local), pinnedType(p.Remote))
+}
+
+// Configure applies the pin to the Configuration of the session's peer connection.
+func (p PairPin) Configure(conf *webrtc.Configuration) {
+	if p.Local == webrtc.ICECandidateTypeRelay {
+		conf.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+}
+
+// FilterLocal returns false for local candidates that must not be signalled,
+// nil marks the end of gathering and is always allowed.
+func (p PairPin) FilterLocal(candidate *webrtc.ICECandidate) bool {
+	return candidate == nil || allowType(p.Local, candidate.Typ)
+}
+
+// FilterRemote returns false for remote candidates that must not be added to the peer connection.
+func (p PairPin) FilterRemote(candidate webrtc.ICECandidateInit) bool {
+	return p.allowCandidate(p.Remote, candidate.Candidate)
+}
+
+// FilterSDP removes candidates of other types from a local (isLocal) or remote description.
+func (p PairPin) FilterSDP(desc webrtc.SessionDescription, isLocal bool) webrtc.SessionDescription {
+	pinned := p.Remote
+	if isLocal {
+		pinned = p.Local
+	}
+	if pinned == anyCandidateType {
+		return desc
+	}
+
+	lines := strings.SplitAfter(desc.SDP, "\n")
+	filtered := lines[:0]
+	for _, line := range lines {
+		trimmed := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(trimmed, "a=candidate:") && !p.allowCandidate(pinned, strings.TrimPrefix(trimmed, "a=")) {
+			continue
+		}
+		filtered = append(filtered, line)
+	}
+	desc.SDP = strings.Join(filtered, "")
+	return desc
+}
+
+// CheckPair returns ErrPairNotAllowed if the selected pair does not match the pin.
+func (p PairPin) CheckPair(pair *webrtc.ICECandidatePair) error {
+	if pair == nil || pair.Local == nil || pair.Remote == nil {
+		return nil
+	}
+	if !allowType(p.Local, pair.Local.Typ) || !allowType(p.Remote, pair.Remote.Typ) {
+		return fmt.Errorf("%w: %s/%s, pinned %s", ErrPairNotAllowed, pair.Local.Typ, pair.Remote.Typ, p)
+	}
+	return nil
+}
+
+func (p PairPin) allowCandidate(pinned webrtc.ICECandidateType, candidate string) bool {
+	if pinned == anyCandidateType {
+		return true
+	}
+	typ, ok := candidateType(candidate)
+	return ok && typ == pinned
+}
+
+// ------------------------------------------------
+
+func allowType(pinned webrtc.ICECandidateType, typ webrtc.ICECandidateType) bool {
+	return pinned == anyCandidateType || pinned == typ
+}
+
+func pinnedType(typ webrtc.ICECandidateType) string {
+	if typ == anyCandidateType {
+		return "any"
+	}
+	return typ.String()
+}
+
+// candidateType returns the type of an SDP candidate attribute value, "candidate:" prefix optional
+func candidateType(candidate string) (webrtc.ICECandidateType, bool) {
+	fields := strings.Fields(candidate)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "typ" {
+			typ, err := webrtc.NewICECandidateType(fields[i+1])
+			return typ, err == nil
+		}
+	}
+	return anyCandidateType, false
+}
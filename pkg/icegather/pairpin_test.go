@@ -0,0 +1,78 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	hostCandidate  = "candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host"
+	relayCandidate = "candidate:2 1 udp 16777215 5.6.7.8 3478 typ relay raddr 1.2.3.4 rport 51000"
+)
+
+func TestPairPin(t *testing.T) {
+	var none PairPin
+	require.False(t, none.IsSet())
+	require.True(t, none.FilterRemote(webrtc.ICECandidateInit{Candidate: hostCandidate}))
+
+	pin := PairPin{Local: webrtc.ICECandidateTypeRelay, Remote: webrtc.ICECandidateTypeHost}
+	require.True(t, pin.IsSet())
+
+	conf := webrtc.Configuration{}
+	pin.Configure(&conf)
+	require.Equal(t, webrtc.ICETransportPolicyRelay, conf.ICETransportPolicy)
+
+	require.True(t, pin.FilterLocal(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay}))
+	require.False(t, pin.FilterLocal(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost}))
+	require.True(t, pin.FilterLocal(nil))
+
+	require.True(t, pin.FilterRemote(webrtc.ICECandidateInit{Candidate: hostCandidate}))
+	require.False(t, pin.FilterRemote(webrtc.ICECandidateInit{Candidate: relayCandidate}))
+	require.False(t, pin.FilterRemote(webrtc.ICECandidateInit{Candidate: "garbage"}))
+
+	require.NoError(t, pin.CheckPair(&webrtc.ICECandidatePair{
+		Local:  &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay},
+		Remote: &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost},
+	}))
+	// peer reflexive remote bypassed signalling
+	require.ErrorIs(t, pin.CheckPair(&webrtc.ICECandidatePair{
+		Local:  &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay},
+		Remote: &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypePrflx},
+	}), ErrPairNotAllowed)
+}
+
+func TestPairPinFilterSDP(t *testing.T) {
+	sdp := "v=0\r\n" +
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n" +
+		"a=" + hostCandidate + "\r\n" +
+		"a=" + relayCandidate + "\r\n" +
+		"a=end-of-candidates\r\n"
+	desc := webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: sdp}
+
+	pin := PairPin{Remote: webrtc.ICECandidateTypeRelay}
+	filtered := pin.FilterSDP(desc, false)
+	require.Equal(t, "v=0\r\n"+
+		"m=audio 9 UDP/TLS/RTP/SAVPF 111\r\n"+
+		"a="+relayCandidate+"\r\n"+
+		"a=end-of-candidates\r\n", filtered.SDP)
+	require.Equal(t, sdp, desc.SDP)
+
+	// local side not pinned
+	require.Equal(t, sdp, pin.FilterSDP(desc, true).SDP)
+}
@@ -0,0 +1,325 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/logger"
+)
+
+type PrewarmerParams struct {
+	// warm peer connections kept ready
+	PoolSize int
+	// warm peer connections are closed and replaced after this long, releasing their TURN allocations
+	MaxAge time.Duration
+	// delay before creating a peer connection again after a failure
+	RetryInterval time.Duration
+}
+
+var PrewarmerParamsDefault = PrewarmerParams{
+	PoolSize:      1,
+	MaxAge:        5 * time.Minute,
+	RetryInterval: 5 * time.Second,
+}
+
+type PrewarmerStats struct {
+	NumWarm int
+	// warm peer connections in the pool with a relay candidate
+	NumRelayReady int
+	NumTaken      int
+	// Take calls that found the pool empty
+	NumMissed  int
+	NumExpired int
+	NumFailed  int
+}
+
+// Prewarmer keeps a pool of peer connections that started gathering candidates, including TURN
+// relay allocations, before they are needed. Taking one at session setup skips the allocation
+// round trips for clients known to require relays.
+//
+// Gathering is started with an offer set as local description and rolled back right away,
+// so a taken peer connection is in the stable state and can be used as offerer or answerer.
+// Its ICE credentials and candidates are those gathered while warming.
+type Prewarmer struct {
+	params            PrewarmerParams
+	newPeerConnection func() (*webrtc.PeerConnection, error)
+
+	lock      sync.Mutex
+	pool      []*warmPeerConnection
+	stats     PrewarmerStats
+	isStopped bool
+
+	refill chan struct{}
+	close  chan struct{}
+}
+
+// NewPrewarmer creates a Prewarmer, newPeerConnection creates peer connections configured
+// with the ICE servers and transport policy of the sessions they are taken for.
+func NewPrewarmer(params PrewarmerParams, newPeerConnection func() (*webrtc.PeerConnection, error)) *Prewarmer {
+	if params.PoolSize <= 0 {
+		params.PoolSize = PrewarmerParamsDefault.PoolSize
+	}
+	if params.MaxAge <= 0 {
+		params.MaxAge = PrewarmerParamsDefault.MaxAge
+	}
+	if params.RetryInterval <= 0 {
+		params.RetryInterval = PrewarmerParamsDefault.RetryInterval
+	}
+	return &Prewarmer{
+		params:            params,
+		newPeerConnection: newPeerConnection,
+		refill:            make(chan struct{}, 1),
+		close:             make(chan struct{}),
+	}
+}
+
+func (p *Prewarmer) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isStopped {
+		return
+	}
+	go p.worker()
+}
+
+// Stop closes the peer connections in the pool, taken ones are not affected.
+func (p *Prewarmer) Stop() {
+	p.lock.Lock()
+	if p.isStopped {
+		p.lock.Unlock()
+		return
+	}
+
+	close(p.close)
+	p.isStopped = true
+	pool := p.pool
+	p.pool = nil
+	p.lock.Unlock()
+
+	for _, w := range pool {
+		_ = w.pc.Close()
+	}
+}
+
+// Take hands out a warm peer connection, preferring one with a relay candidate, nil if none is ready.
+// Candidates gathered while warming are replayed to onCandidate, followed by later ones and nil
+// at the end of gathering, as with webrtc.PeerConnection.OnICECandidate. onCandidate must not block.
+func (p *Prewarmer) Take(onCandidate func(candidate *webrtc.ICECandidate)) *webrtc.PeerConnection {
+	p.lock.Lock()
+	w := p.takeLocked()
+	p.lock.Unlock()
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+
+	if w == nil {
+		return nil
+	}
+	w.handover(onCandidate)
+	return w.pc
+}
+
+func (p *Prewarmer) Stats() PrewarmerStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := p.stats
+	stats.NumWarm = len(p.pool)
+	for _, w := range p.pool {
+		if w.hasRelayCandidate() {
+			stats.NumRelayReady++
+		}
+	}
+	return stats
+}
+
+func (p *Prewarmer) takeLocked() *warmPeerConnection {
+	if len(p.pool) == 0 {
+		p.stats.NumMissed++
+		return nil
+	}
+
+	idx := 0
+	for i, w := range p.pool {
+		if w.hasRelayCandidate() {
+			idx = i
+			break
+		}
+	}
+	w := p.pool[idx]
+	p.pool = append(p.pool[:idx], p.pool[idx+1:]...)
+	p.stats.NumTaken++
+	return w
+}
+
+func (p *Prewarmer) worker() {
+	ticker := time.NewTicker(p.params.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		p.fill(time.Now())
+
+		select {
+		case <-p.close:
+			return
+		case <-p.refill:
+		case <-ticker.C:
+		}
+	}
+}
+
+// fill replaces expired peer connections and tops up the pool, stopping at the first failure
+func (p *Prewarmer) fill(now time.Time) {
+	p.lock.Lock()
+	expired := p.expireLocked(now)
+	missing := p.params.PoolSize - len(p.pool)
+	p.lock.Unlock()
+
+	for _, w := range expired {
+		_ = w.pc.Close()
+	}
+
+	for i := 0; i < missing; i++ {
+		w, err := p.warm()
+		if err != nil {
+			logger.Warnw("could not prewarm peer connection", err)
+			p.lock.Lock()
+			p.stats.NumFailed++
+			p.lock.Unlock()
+			return
+		}
+
+		p.lock.Lock()
+		if p.isStopped {
+			p.lock.Unlock()
+			_ = w.pc.Close()
+			return
+		}
+		p.pool = append(p.pool, w)
+		p.lock.Unlock()
+	}
+}
+
+func (p *Prewarmer) expireLocked(now time.Time) []*warmPeerConnection {
+	var expired []*warmPeerConnection
+	pool := p.pool[:0]
+	for _, w := range p.pool {
+		if now.Sub(w.createdAt) > p.params.MaxAge {
+			expired = append(expired, w)
+			p.stats.NumExpired++
+		} else {
+			pool = append(pool, w)
+		}
+	}
+	for i := len(pool); i < len(p.pool); i++ {
+		p.pool[i] = nil
+	}
+	p.pool = pool
+	return expired
+}
+
+func (p *Prewarmer) warm() (*warmPeerConnection, error) {
+	pc, err := p.newPeerConnection()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &warmPeerConnection{
+		pc:        pc,
+		createdAt: time.Now(),
+	}
+	pc.OnICECandidate(w.handleCandidate)
+
+	offer, err := pc.CreateOffer(nil)
+	if err == nil {
+		// starts gathering
+		err = pc.SetLocalDescription(offer)
+	}
+	if err == nil {
+		// back to stable, gathering carries on
+		err = pc.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback, SDP: offer.SDP})
+	}
+	if err != nil {
+		_ = pc.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// ------------------------------------------------
+
+type warmPeerConnection struct {
+	pc        *webrtc.PeerConnection
+	createdAt time.Time
+
+	lock        sync.Mutex
+	candidates  []*webrtc.ICECandidate
+	isGathered  bool
+	hasRelay    bool
+	onCandidate func(candidate *webrtc.ICECandidate)
+}
+
+func (w *warmPeerConnection) handleCandidate(candidate *webrtc.ICECandidate) {
+	w.lock.Lock()
+	if w.onCandidate != nil {
+		onCandidate := w.onCandidate
+		w.lock.Unlock()
+
+		onCandidate(candidate)
+		return
+	}
+
+	if candidate == nil {
+		w.isGathered = true
+	} else {
+		w.candidates = append(w.candidates, candidate)
+		if candidate.Typ == webrtc.ICECandidateTypeRelay {
+			w.hasRelay = true
+		}
+	}
+	w.lock.Unlock()
+}
+
+func (w *warmPeerConnection) hasRelayCandidate() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.hasRelay
+}
+
+// handover replays buffered candidates and forwards later ones, candidates gathered
+// during the replay wait on the lock so that order is kept
+func (w *warmPeerConnection) handover(onCandidate func(candidate *webrtc.ICECandidate)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if onCandidate == nil {
+		onCandidate = func(*webrtc.ICECandidate) {}
+	}
+	for _, candidate := range w.candidates {
+		onCandidate(candidate)
+	}
+	if w.isGathered {
+		onCandidate(nil)
+	}
+	w.candidates = nil
+	w.onCandidate = onCandidate
+}
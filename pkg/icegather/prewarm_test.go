@@ -0,0 +1,87 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmPeerConnectionHandover(t *testing.T) {
+	w := &warmPeerConnection{createdAt: time.Now()}
+
+	host := &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "10.0.0.1"}
+	relay := &webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay, Address: "5.6.7.8"}
+	w.handleCandidate(host)
+	require.False(t, w.hasRelayCandidate())
+	w.handleCandidate(relay)
+	require.True(t, w.hasRelayCandidate())
+
+	// buffered candidates are replayed in order, later ones forwarded
+	var received []*webrtc.ICECandidate
+	w.handover(func(candidate *webrtc.ICECandidate) {
+		received = append(received, candidate)
+	})
+	require.Equal(t, []*webrtc.ICECandidate{host, relay}, received)
+
+	w.handleCandidate(nil)
+	require.Equal(t, []*webrtc.ICECandidate{host, relay, nil}, received)
+
+	// end of gathering before handover is replayed too
+	w = &warmPeerConnection{createdAt: time.Now()}
+	w.handleCandidate(host)
+	w.handleCandidate(nil)
+	received = nil
+	w.handover(func(candidate *webrtc.ICECandidate) {
+		received = append(received, candidate)
+	})
+	require.Equal(t, []*webrtc.ICECandidate{host, nil}, received)
+}
+
+func TestPrewarmerPool(t *testing.T) {
+	p := NewPrewarmer(PrewarmerParams{PoolSize: 3, MaxAge: time.Minute}, nil)
+
+	now := time.Now()
+	old := &warmPeerConnection{createdAt: now.Add(-2 * time.Minute)}
+	first := &warmPeerConnection{createdAt: now}
+	withRelay := &warmPeerConnection{createdAt: now}
+	withRelay.handleCandidate(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay, Address: "5.6.7.8"})
+	p.pool = []*warmPeerConnection{old, first, withRelay}
+
+	stats := p.Stats()
+	require.Equal(t, 3, stats.NumWarm)
+	require.Equal(t, 1, stats.NumRelayReady)
+
+	// too old for the pool
+	expired := p.expireLocked(now)
+	require.Equal(t, []*warmPeerConnection{old}, expired)
+	require.Equal(t, []*warmPeerConnection{first, withRelay}, p.pool)
+
+	// relay ready first, then oldest
+	p.lock.Lock()
+	require.Equal(t, withRelay, p.takeLocked())
+	require.Equal(t, first, p.takeLocked())
+	require.Nil(t, p.takeLocked())
+	p.lock.Unlock()
+
+	stats = p.Stats()
+	require.Equal(t, 0, stats.NumWarm)
+	require.Equal(t, 2, stats.NumTaken)
+	require.Equal(t, 1, stats.NumMissed)
+	require.Equal(t, 1, stats.NumExpired)
+}
@@ -0,0 +1,294 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	candidatePrefix = "candidate:"
+	midLinePrefix   = "m="
+
+	// bound on decompressed size, a batch is a handful of candidates
+	maxDecodedBatchSize = 64 * 1024
+)
+
+var (
+	ErrInvalidCandidateBatch = errors.New("invalid candidate batch")
+)
+
+// extension attributes that do not affect connectivity and are dropped when batching
+var droppedCandidateAttributes = map[string]bool{
+	"generation":   true,
+	"network-id":   true,
+	"network-cost": true,
+	"ufrag":        true,
+}
+
+type TrickleBatcherParams struct {
+	// candidates are held for this long after the first candidate of a batch
+	Window time.Duration
+	// a batch is sent right away when it reaches this many candidates, 0 means no limit
+	MaxBatchSize int
+}
+
+var TrickleBatcherParamsDefault = TrickleBatcherParams{
+	Window:       100 * time.Millisecond,
+	MaxBatchSize: 10,
+}
+
+// TrickleBatcher collects locally gathered candidates over a short window and emits them as deduplicated batches,
+// for signalling transports where each message has significant overhead.
+type TrickleBatcher struct {
+	params TrickleBatcherParams
+
+	lock      sync.Mutex
+	pending   []webrtc.ICECandidateInit
+	seen      map[string]struct{}
+	timer     *time.Timer
+	onBatch   func(candidates []webrtc.ICECandidateInit)
+	isStopped bool
+}
+
+func NewTrickleBatcher(params TrickleBatcherParams) *TrickleBatcher {
+	return &TrickleBatcher{
+		params: params,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+func (t *TrickleBatcher) OnBatch(f func(candidates []webrtc.ICECandidateInit)) {
+	t.lock.Lock()
+	t.onBatch = f
+	t.lock.Unlock()
+}
+
+// Add queues a candidate, candidates already sent or queued are dropped.
+func (t *TrickleBatcher) Add(candidate webrtc.ICECandidateInit) {
+	t.lock.Lock()
+	if t.isStopped {
+		t.lock.Unlock()
+		return
+	}
+
+	key := candidateKey(candidate)
+	if _, ok := t.seen[key]; ok {
+		t.lock.Unlock()
+		return
+	}
+	t.seen[key] = struct{}{}
+	t.pending = append(t.pending, candidate)
+
+	var batch []webrtc.ICECandidateInit
+	if t.params.MaxBatchSize > 0 && len(t.pending) >= t.params.MaxBatchSize {
+		batch = t.takeLocked()
+	} else if t.timer == nil {
+		t.timer = time.AfterFunc(t.params.Window, t.Flush)
+	}
+	onBatch := t.onBatch
+	t.lock.Unlock()
+
+	if len(batch) != 0 && onBatch != nil {
+		onBatch(batch)
+	}
+}
+
+// Flush sends pending candidates right away, for example at the end of gathering.
+func (t *TrickleBatcher) Flush() {
+	t.lock.Lock()
+	batch := t.takeLocked()
+	onBatch := t.onBatch
+	t.lock.Unlock()
+
+	if len(batch) != 0 && onBatch != nil {
+		onBatch(batch)
+	}
+}
+
+// Reset forgets sent candidates, used on ICE restart.
+func (t *TrickleBatcher) Reset() {
+	t.lock.Lock()
+	t.takeLocked()
+	t.seen = make(map[string]struct{})
+	t.lock.Unlock()
+}
+
+// Stop drops pending candidates.
+func (t *TrickleBatcher) Stop() {
+	t.lock.Lock()
+	t.takeLocked()
+	t.isStopped = true
+	t.lock.Unlock()
+}
+
+func (t *TrickleBatcher) takeLocked() []webrtc.ICECandidateInit {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	batch := t.pending
+	t.pending = nil
+	return batch
+}
+
+// ------------------------------------------------
+
+// EncodeCandidates encodes candidates compactly, dropping attributes not needed for connectivity and
+// compressing the result. Use DecodeCandidates on the remote side.
+func EncodeCandidates(candidates []webrtc.ICECandidateInit) ([]byte, error) {
+	var text strings.Builder
+	var lastMid string
+	for i, c := range candidates {
+		mid := midLine(c)
+		if i == 0 || mid != lastMid {
+			text.WriteString(mid)
+			text.WriteByte('\n')
+			lastMid = mid
+		}
+		text.WriteString(compactCandidate(c.Candidate))
+		text.WriteByte('\n')
+	}
+
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte(text.String())); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func DecodeCandidates(data []byte) ([]webrtc.ICECandidateInit, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	text, err := io.ReadAll(io.LimitReader(r, maxDecodedBatchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCandidateBatch, err)
+	}
+	if len(text) > maxDecodedBatchSize {
+		return nil, fmt.Errorf("%w: too large", ErrInvalidCandidateBatch)
+	}
+
+	var candidates []webrtc.ICECandidateInit
+	var mid *string
+	var mLineIndex *uint16
+	hasMid := false
+	scanner := bufio.NewScanner(bytes.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, midLinePrefix) {
+			mid, mLineIndex, err = parseMidLine(line)
+			if err != nil {
+				return nil, err
+			}
+			hasMid = true
+			continue
+		}
+		if !hasMid {
+			return nil, fmt.Errorf("%w: missing mid line", ErrInvalidCandidateBatch)
+		}
+		candidates = append(candidates, webrtc.ICECandidateInit{
+			Candidate:     candidatePrefix + line,
+			SDPMid:        mid,
+			SDPMLineIndex: mLineIndex,
+		})
+	}
+	return candidates, nil
+}
+
+// ------------------------------------------------
+
+func candidateKey(c webrtc.ICECandidateInit) string {
+	return midLine(c) + " " + compactCandidate(c.Candidate)
+}
+
+// compactCandidate strips the "candidate:" prefix and attributes not needed for connectivity
+func compactCandidate(candidate string) string {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimPrefix(candidate, "a="), candidatePrefix))
+
+	// foundation, component, transport, priority, address, port, "typ", type are positional,
+	// the rest are name/value pairs
+	const numPositional = 8
+	if len(fields) <= numPositional {
+		return strings.Join(fields, " ")
+	}
+
+	kept := append([]string{}, fields[:numPositional]...)
+	for i := numPositional; i+1 < len(fields); i += 2 {
+		if droppedCandidateAttributes[fields[i]] {
+			continue
+		}
+		kept = append(kept, fields[i], fields[i+1])
+	}
+	return strings.Join(kept, " ")
+}
+
+// mid line is "m=<mLineIndex> <mid>", "-" for missing values
+func midLine(c webrtc.ICECandidateInit) string {
+	index := "-"
+	if c.SDPMLineIndex != nil {
+		index = strconv.Itoa(int(*c.SDPMLineIndex))
+	}
+	mid := "-"
+	if c.SDPMid != nil {
+		mid = *c.SDPMid
+	}
+	return midLinePrefix + index + " " + mid
+}
+
+func parseMidLine(line string) (*string, *uint16, error) {
+	parts := strings.SplitN(strings.TrimPrefix(line, midLinePrefix), " ", 2)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("%w: invalid mid line %s", ErrInvalidCandidateBatch, line)
+	}
+
+	var mLineIndex *uint16
+	if parts[0] != "-" {
+		index, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid m-line index %s", ErrInvalidCandidateBatch, parts[0])
+		}
+		i := uint16(index)
+		mLineIndex = &i
+	}
+
+	var mid *string
+	if parts[1] != "-" {
+		m := parts[1]
+		mid = &m
+	}
+	return mid, mLineIndex, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package icegather
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCandidate(candidate string, mid string) webrtc.ICECandidateInit {
+	mLineIndex := uint16(0)
+	return webrtc.ICECandidateInit{
+		Candidate:     candidate,
+		SDPMid:        &mid,
+		SDPMLineIndex: &mLineIndex,
+	}
+}
+
+func TestTrickleBatcher(t *testing.T) {
+	b := NewTrickleBatcher(TrickleBatcherParams{Window: time.Hour, MaxBatchSize: 3})
+
+	var batches [][]webrtc.ICECandidateInit
+	b.OnBatch(func(candidates []webrtc.ICECandidateInit) {
+		batches = append(batches, candidates)
+	})
+
+	b.Add(newTestCandidate("candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host generation 0", "0"))
+	// same candidate with different extension attributes is a duplicate
+	b.Add(newTestCandidate("candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host generation 1 network-id 2", "0"))
+	b.Add(newTestCandidate("candidate:2 1 udp 1694498815 1.2.3.4 7882 typ srflx raddr 10.0.0.1 rport 7882", "0"))
+	require.Empty(t, batches)
+
+	b.Add(newTestCandidate("candidate:3 1 tcp 1671430143 10.0.0.1 7881 typ host tcptype passive", "0"))
+	require.Len(t, batches, 1)
+	require.Len(t, batches[0], 3)
+
+	b.Add(newTestCandidate("candidate:4 1 udp 16777215 5.6.7.8 3478 typ relay raddr 1.2.3.4 rport 7882", "0"))
+	b.Flush()
+	require.Len(t, batches, 2)
+	require.Len(t, batches[1], 1)
+
+	// nothing pending
+	b.Flush()
+	require.Len(t, batches, 2)
+
+	b.Stop()
+	b.Add(newTestCandidate("candidate:5 1 udp 2130706431 10.0.0.2 7882 typ host", "0"))
+	b.Flush()
+	require.Len(t, batches, 2)
+}
+
+func TestTrickleBatcherWindow(t *testing.T) {
+	b := NewTrickleBatcher(TrickleBatcherParams{Window: 10 * time.Millisecond})
+
+	batches := make(chan []webrtc.ICECandidateInit, 1)
+	b.OnBatch(func(candidates []webrtc.ICECandidateInit) {
+		batches <- candidates
+	})
+
+	b.Add(newTestCandidate("candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host", "0"))
+	b.Add(newTestCandidate("candidate:2 1 udp 2130706431 10.0.0.2 7882 typ host", "0"))
+
+	select {
+	case batch := <-batches:
+		require.Len(t, batch, 2)
+	case <-time.After(time.Second):
+		t.Fatal("batch should have been sent")
+	}
+}
+
+func TestEncodeCandidates(t *testing.T) {
+	candidates := []webrtc.ICECandidateInit{
+		newTestCandidate("candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host generation 0 ufrag abcd network-id 1", "0"),
+		newTestCandidate("candidate:2 1 udp 1694498815 1.2.3.4 7882 typ srflx raddr 10.0.0.1 rport 7882 generation 0", "0"),
+		newTestCandidate("candidate:3 1 tcp 1671430143 10.0.0.1 7881 typ host tcptype passive", "1"),
+		{Candidate: "candidate:4 1 udp 16777215 5.6.7.8 3478 typ relay raddr 1.2.3.4 rport 7882"},
+	}
+
+	data, err := EncodeCandidates(candidates)
+	require.NoError(t, err)
+
+	total := 0
+	for _, c := range candidates {
+		total += len(c.Candidate)
+	}
+	require.Less(t, len(data), total)
+
+	decoded, err := DecodeCandidates(data)
+	require.NoError(t, err)
+	require.Len(t, decoded, len(candidates))
+
+	require.Equal(t, "candidate:1 1 udp 2130706431 10.0.0.1 7882 typ host", decoded[0].Candidate)
+	require.Equal(t, "candidate:2 1 udp 1694498815 1.2.3.4 7882 typ srflx raddr 10.0.0.1 rport 7882", decoded[1].Candidate)
+	require.Equal(t, "0", *decoded[1].SDPMid)
+	require.Equal(t, candidates[2].Candidate, decoded[2].Candidate)
+	require.Equal(t, "1", *decoded[2].SDPMid)
+	require.Equal(t, uint16(0), *decoded[2].SDPMLineIndex)
+	require.Nil(t, decoded[3].SDPMid)
+	require.Nil(t, decoded[3].SDPMLineIndex)
+
+	_, err = DecodeCandidates([]byte("not a batch"))
+	require.ErrorIs(t, err, ErrInvalidCandidateBatch)
+}
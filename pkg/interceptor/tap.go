@@ -0,0 +1,264 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+type TapDirection int
+
+const (
+	// received from the remote peer
+	TapDirectionInbound TapDirection = iota
+	// sent to the remote peer
+	TapDirectionOutbound
+)
+
+func (t TapDirection) String() string {
+	switch t {
+	case TapDirectionInbound:
+		return "INBOUND"
+	case TapDirectionOutbound:
+		return "OUTBOUND"
+	default:
+		return fmt.Sprintf("%d", int(t))
+	}
+}
+
+// TapPacket is an RTP packet or an RTCP compound packet of a tapped stream.
+type TapPacket struct {
+	// ID the interceptor was created with, identifies the peer connection
+	ConnectionID string
+	Direction    TapDirection
+	Time         time.Time
+	// SSRC of the stream, for RTCP the selected SSRC the packets are about
+	SSRC uint32
+
+	// set for RTP, with TapParams.ZeroCopy Payload is only valid during the call
+	Header  *rtp.Header
+	Payload []byte
+
+	// set for RTCP, shared with the forwarding path and must not be modified
+	RTCP []rtcp.Packet
+}
+
+// Tap receives packets of selected streams. It is called on the forwarding path and must not block,
+// hand packets off to a queue for expensive processing.
+type Tap interface {
+	OnPacket(pkt *TapPacket)
+}
+
+type TapParams struct {
+	// deliver every SampleEvery-th RTP packet of a stream, every packet when 0 or 1
+	SampleEvery int
+	// do not copy RTP headers and payloads, they reference packet buffers valid only during Tap.OnPacket
+	ZeroCopy bool
+	// also deliver RTCP packets about selected streams
+	IncludeRTCP bool
+}
+
+// TapFactory creates interceptors delivering packets of selected SSRCs to a Tap. Streams are selected at runtime
+// with Select, so taps can be attached to running connections without touching the forwarding path otherwise.
+type TapFactory struct {
+	tap    Tap
+	params TapParams
+
+	lock  sync.RWMutex
+	ssrcs map[uint32]struct{}
+	all   bool
+}
+
+func NewTapFactory(tap Tap, params TapParams) *TapFactory {
+	if params.SampleEvery <= 0 {
+		params.SampleEvery = 1
+	}
+	return &TapFactory{
+		tap:    tap,
+		params: params,
+		ssrcs:  make(map[uint32]struct{}),
+	}
+}
+
+func (f *TapFactory) Select(ssrc uint32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.ssrcs[ssrc] = struct{}{}
+}
+
+func (f *TapFactory) Deselect(ssrc uint32) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.ssrcs, ssrc)
+}
+
+// SelectAll taps all streams regardless of Select, for example on a test node.
+func (f *TapFactory) SelectAll(all bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.all = all
+}
+
+func (f *TapFactory) isSelected(ssrc uint32) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	if f.all {
+		return true
+	}
+	_, ok := f.ssrcs[ssrc]
+	return ok
+}
+
+func (f *TapFactory) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	return &TapInterceptor{
+		factory: f,
+		id:      id,
+	}, nil
+}
+
+// ------------------------------------------------
+
+type TapInterceptor struct {
+	interceptor.NoOp
+
+	factory *TapFactory
+	id      string
+}
+
+func (t *TapInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	var count atomic.Uint64
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil || !t.sample(info.SSRC, &count) {
+			return n, attr, err
+		}
+
+		var header *rtp.Header
+		if attr != nil {
+			header, err = attr.GetRTPHeader(b[:n])
+		} else {
+			header = &rtp.Header{}
+			_, err = header.Unmarshal(b[:n])
+		}
+		if err != nil {
+			// not for the tap to fail the stream on, the reader after it decides
+			return n, attr, nil
+		}
+		t.deliverRTP(TapDirectionInbound, info.SSRC, header, b[header.MarshalSize():n])
+		return n, attr, nil
+	})
+}
+
+func (t *TapInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var count atomic.Uint64
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if t.sample(info.SSRC, &count) {
+			t.deliverRTP(TapDirectionOutbound, info.SSRC, header, payload)
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func (t *TapInterceptor) BindRTCPReader(reader interceptor.RTCPReader) interceptor.RTCPReader {
+	if !t.factory.params.IncludeRTCP {
+		return reader
+	}
+	return interceptor.RTCPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n, attr, err := reader.Read(b, a)
+		if err != nil {
+			return n, attr, err
+		}
+
+		var pkts []rtcp.Packet
+		var parseErr error
+		if attr != nil {
+			pkts, parseErr = attr.GetRTCPPackets(b[:n])
+		} else {
+			pkts, parseErr = rtcp.Unmarshal(b[:n])
+		}
+		if parseErr == nil {
+			t.deliverRTCP(TapDirectionInbound, pkts)
+		}
+		return n, attr, err
+	})
+}
+
+func (t *TapInterceptor) BindRTCPWriter(writer interceptor.RTCPWriter) interceptor.RTCPWriter {
+	if !t.factory.params.IncludeRTCP {
+		return writer
+	}
+	return interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, attributes interceptor.Attributes) (int, error) {
+		t.deliverRTCP(TapDirectionOutbound, pkts)
+		return writer.Write(pkts, attributes)
+	})
+}
+
+// sample returns true if the packet of ssrc is to be delivered
+func (t *TapInterceptor) sample(ssrc uint32, count *atomic.Uint64) bool {
+	if !t.factory.isSelected(ssrc) {
+		return false
+	}
+	return (count.Add(1)-1)%uint64(t.factory.params.SampleEvery) == 0
+}
+
+func (t *TapInterceptor) deliverRTP(direction TapDirection, ssrc uint32, header *rtp.Header, payload []byte) {
+	if !t.factory.params.ZeroCopy {
+		clone := header.Clone()
+		header = &clone
+		payload = append([]byte(nil), payload...)
+	}
+	t.factory.tap.OnPacket(&TapPacket{
+		ConnectionID: t.id,
+		Direction:    direction,
+		Time:         time.Now(),
+		SSRC:         ssrc,
+		Header:       header,
+		Payload:      payload,
+	})
+}
+
+// deliverRTCP delivers the compound packet once for each selected SSRC it is about
+func (t *TapInterceptor) deliverRTCP(direction TapDirection, pkts []rtcp.Packet) {
+	seen := make(map[uint32]struct{})
+	for _, pkt := range pkts {
+		for _, ssrc := range pkt.DestinationSSRC() {
+			if _, ok := seen[ssrc]; ok {
+				continue
+			}
+			seen[ssrc] = struct{}{}
+			if !t.factory.isSelected(ssrc) {
+				continue
+			}
+			t.factory.tap.OnPacket(&TapPacket{
+				ConnectionID: t.id,
+				Direction:    direction,
+				Time:         time.Now(),
+				SSRC:         ssrc,
+				RTCP:         pkts,
+			})
+		}
+	}
+}
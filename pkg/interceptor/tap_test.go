@@ -0,0 +1,109 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package interceptor
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+type testTap struct {
+	lock sync.Mutex
+	pkts []*TapPacket
+}
+
+func (t *testTap) OnPacket(pkt *TapPacket) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pkts = append(t.pkts, pkt)
+}
+
+func TestTap(t *testing.T) {
+	tap := &testTap{}
+	f := NewTapFactory(tap, TapParams{SampleEvery: 2, IncludeRTCP: true})
+	f.Select(1)
+	i, err := f.NewInterceptor("pc")
+	require.NoError(t, err)
+
+	// inbound
+	raw := make([][]byte, 0, 4)
+	for seq := uint16(0); seq < 4; seq++ {
+		pkt := rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1, SequenceNumber: seq}, Payload: []byte{byte(seq)}}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		raw = append(raw, b)
+	}
+	next := 0
+	reader := i.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n := copy(b, raw[next])
+		next++
+		return n, a, nil
+	}))
+	buf := make([]byte, 1500)
+	for range raw {
+		_, _, err := reader.Read(buf, interceptor.Attributes{})
+		require.NoError(t, err)
+	}
+	require.Len(t, tap.pkts, 2)
+	require.Equal(t, uint16(0), tap.pkts[0].Header.SequenceNumber)
+	require.Equal(t, uint16(2), tap.pkts[1].Header.SequenceNumber)
+	require.Equal(t, []byte{2}, tap.pkts[1].Payload)
+	require.Equal(t, TapDirectionInbound, tap.pkts[1].Direction)
+	require.Equal(t, "pc", tap.pkts[1].ConnectionID)
+
+	// copies are not affected by reuse of the buffer
+	buf[12] = 0xff
+	require.Equal(t, []byte{2}, tap.pkts[1].Payload)
+
+	// outbound, not selected until Select
+	writer := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		return len(payload), nil
+	}))
+	_, err = writer.Write(&rtp.Header{SSRC: 2}, []byte{1}, nil)
+	require.NoError(t, err)
+	require.Len(t, tap.pkts, 2)
+	f.Select(2)
+	_, err = writer.Write(&rtp.Header{SSRC: 2}, []byte{1}, nil)
+	require.NoError(t, err)
+	require.Len(t, tap.pkts, 3)
+	require.Equal(t, TapDirectionOutbound, tap.pkts[2].Direction)
+
+	// RTCP about selected streams
+	rtcpWriter := i.BindRTCPWriter(interceptor.RTCPWriterFunc(func(pkts []rtcp.Packet, _ interceptor.Attributes) (int, error) {
+		return 0, nil
+	}))
+	_, err = rtcpWriter.Write([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1}, &rtcp.PictureLossIndication{MediaSSRC: 3}}, nil)
+	require.NoError(t, err)
+	require.Len(t, tap.pkts, 4)
+	require.Equal(t, uint32(1), tap.pkts[3].SSRC)
+	require.Len(t, tap.pkts[3].RTCP, 2)
+
+	f.Deselect(1)
+	f.Deselect(2)
+	_, err = rtcpWriter.Write([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1}}, nil)
+	require.NoError(t, err)
+	require.Len(t, tap.pkts, 4)
+
+	f.SelectAll(true)
+	_, err = rtcpWriter.Write([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 3}}, nil)
+	require.NoError(t, err)
+	require.Len(t, tap.pkts, 5)
+}
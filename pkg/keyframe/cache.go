@@ -0,0 +1,242 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyframe
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/membudget"
+)
+
+type CacheParams struct {
+	Codec Codec
+	// a cached key frame older than this is not served
+	MaxAge time.Duration
+	// key frames larger than this are not cached
+	MaxBytes int
+}
+
+var CacheParamsDefault = CacheParams{
+	MaxAge:   5 * time.Second,
+	MaxBytes: 512 * 1024,
+}
+
+type KeyFrame struct {
+	// marshalled RTP packets in sequence number order, must not be modified
+	Packets    [][]byte
+	Timestamp  uint32
+	ReceivedAt time.Time
+}
+
+func (k *KeyFrame) size() int {
+	size := 0
+	for _, pkt := range k.Packets {
+		size += len(pkt)
+	}
+	return size
+}
+
+type CacheStats struct {
+	NumCached int
+	// key frames discarded because they were incomplete, had gaps or lacked parameter sets
+	NumInvalid int
+	// key frames discarded because of MaxBytes or the memory budget
+	NumOverBudget int
+}
+
+// Cache keeps the last complete key frame of a video stream, so that a new subscriber can be sent a recent
+// key frame right away, before live packets, instead of waiting for the publisher to answer a key frame request.
+//
+// A key frame is cached only if it is complete: it starts on a codec specific key frame start,
+// has no sequence number gaps, ends with the marker bit, and for H264 carries SPS, PPS and IDR in band.
+// The cached frame is accounted as a cache class buffer of the memory budget, if one is given: it only uses
+// memory that is free, and is dropped when the budget asks it to shrink.
+type Cache struct {
+	params CacheParams
+	budget *membudget.Budget
+	handle *membudget.Handle
+
+	lock     sync.Mutex
+	cached   *KeyFrame
+	building *KeyFrame
+	lastSN   uint16
+	size     int
+	nalus    h264NALUs
+	stats    CacheStats
+}
+
+// NewCache creates a cache, budget is optional.
+func NewCache(params CacheParams, budget *membudget.Budget) *Cache {
+	if params.MaxAge <= 0 {
+		params.MaxAge = CacheParamsDefault.MaxAge
+	}
+	if params.MaxBytes <= 0 {
+		params.MaxBytes = CacheParamsDefault.MaxBytes
+	}
+
+	c := &Cache{
+		params: params,
+		budget: budget,
+	}
+	if budget != nil {
+		c.handle = budget.Register(membudget.ClassCache, 0)
+	}
+	return c
+}
+
+func (c *Cache) AddPacket(pkt *rtp.Packet, at time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.applyShrinkLocked()
+
+	if IsKeyFrameStart(c.params.Codec, pkt.Payload) && (c.building == nil || c.building.Timestamp != pkt.Timestamp) {
+		if c.building != nil {
+			c.stats.NumInvalid++
+		}
+		c.building = &KeyFrame{
+			Timestamp:  pkt.Timestamp,
+			ReceivedAt: at,
+		}
+		c.size = 0
+		c.nalus = 0
+	} else if c.building == nil {
+		return
+	} else if pkt.Timestamp != c.building.Timestamp || pkt.SequenceNumber != c.lastSN+1 {
+		// gap or next frame without the marker, wait for the next key frame
+		c.discardBuildingLocked()
+		c.stats.NumInvalid++
+		return
+	}
+
+	size := pkt.MarshalSize()
+	if c.size+size > c.params.MaxBytes {
+		c.discardBuildingLocked()
+		c.stats.NumOverBudget++
+		return
+	}
+	buf := make([]byte, size)
+	if _, err := pkt.MarshalTo(buf); err != nil {
+		c.discardBuildingLocked()
+		c.stats.NumInvalid++
+		return
+	}
+	c.building.Packets = append(c.building.Packets, buf)
+	c.lastSN = pkt.SequenceNumber
+	c.size += size
+	if c.params.Codec == CodecH264 {
+		c.nalus |= parseH264NALUs(pkt.Payload)
+	}
+
+	if pkt.Marker {
+		c.completeLocked()
+	}
+}
+
+// KeyFrame returns the cached key frame if it is not stale.
+func (c *Cache) KeyFrame(now time.Time) (*KeyFrame, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.applyShrinkLocked()
+
+	if c.cached == nil {
+		return nil, false
+	}
+	if now.Sub(c.cached.ReceivedAt) > c.params.MaxAge {
+		c.dropCachedLocked()
+		return nil, false
+	}
+	return c.cached, true
+}
+
+// Invalidate drops the cached key frame, for example when the publisher changes resolution or codec.
+func (c *Cache) Invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.dropCachedLocked()
+	c.discardBuildingLocked()
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats
+}
+
+// Close drops the cached key frame and unregisters from the memory budget.
+func (c *Cache) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.cached = nil
+	c.discardBuildingLocked()
+	if c.handle != nil {
+		c.handle.Close()
+		c.handle = nil
+	}
+}
+
+func (c *Cache) completeLocked() {
+	building := c.building
+	nalus := c.nalus
+	c.discardBuildingLocked()
+
+	if c.params.Codec == CodecH264 && !nalus.isComplete() {
+		c.stats.NumInvalid++
+		return
+	}
+
+	size := building.size()
+	if c.handle != nil {
+		// release the previous frame first, and only use memory that is free,
+		// a cache never makes other buffers shrink
+		c.dropCachedLocked()
+		if available := c.budget.Available(); (available >= 0 && available < size) || !c.handle.Reserve(size) {
+			c.stats.NumOverBudget++
+			return
+		}
+	}
+	c.cached = building
+	c.stats.NumCached++
+}
+
+func (c *Cache) discardBuildingLocked() {
+	c.building = nil
+	c.size = 0
+	c.nalus = 0
+}
+
+func (c *Cache) dropCachedLocked() {
+	if c.cached == nil {
+		return
+	}
+	if c.handle != nil {
+		c.handle.Release(c.cached.size())
+	}
+	c.cached = nil
+}
+
+// the cache holds a single frame, any shrink request drops it
+func (c *Cache) applyShrinkLocked() {
+	if c.handle != nil && c.handle.PendingShrink() > 0 {
+		c.dropCachedLocked()
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyframe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/membudget"
+)
+
+func packet(sn uint16, ts uint32, marker bool, payload ...byte) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			SequenceNumber: sn,
+			Timestamp:      ts,
+			Marker:         marker,
+		},
+		Payload: payload,
+	}
+}
+
+func TestIsKeyFrameStart(t *testing.T) {
+	// VP8, S bit, no extension, P bit clear
+	require.True(t, IsKeyFrameStart(CodecVP8, []byte{0x10, 0x00}))
+	// VP8 delta frame
+	require.False(t, IsKeyFrameStart(CodecVP8, []byte{0x10, 0x01}))
+	// VP8 continuation packet
+	require.False(t, IsKeyFrameStart(CodecVP8, []byte{0x00, 0x00}))
+	// VP8 with extension, 15 bit picture ID, TL0PICIDX and TID
+	require.True(t, IsKeyFrameStart(CodecVP8, []byte{0x90, 0xe0, 0x81, 0x02, 0x03, 0x40, 0x00}))
+	require.False(t, IsKeyFrameStart(CodecVP8, []byte{0x90, 0xe0, 0x81}))
+
+	// VP9, B set, P clear, non-layered
+	require.True(t, IsKeyFrameStart(CodecVP9, []byte{0x08}))
+	require.False(t, IsKeyFrameStart(CodecVP9, []byte{0x48}))
+	// VP9 with picture ID and layer indices, spatial layer 0 and 1
+	require.True(t, IsKeyFrameStart(CodecVP9, []byte{0xa8, 0x01, 0x00}))
+	require.False(t, IsKeyFrameStart(CodecVP9, []byte{0xa8, 0x01, 0x02}))
+
+	// H264 SPS, IDR, non-IDR slice
+	require.True(t, IsKeyFrameStart(CodecH264, []byte{0x67, 0x42}))
+	require.True(t, IsKeyFrameStart(CodecH264, []byte{0x65, 0x88}))
+	require.False(t, IsKeyFrameStart(CodecH264, []byte{0x41, 0x9a}))
+	// STAP-A with SPS and PPS
+	require.True(t, IsKeyFrameStart(CodecH264, []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}))
+	// FU-A start and middle of an IDR
+	require.True(t, IsKeyFrameStart(CodecH264, []byte{0x7c, 0x85, 0x88}))
+	require.False(t, IsKeyFrameStart(CodecH264, []byte{0x7c, 0x05, 0x88}))
+
+	// AV1, N set
+	require.True(t, IsKeyFrameStart(CodecAV1, []byte{0x18}))
+	require.False(t, IsKeyFrameStart(CodecAV1, []byte{0x10}))
+	require.False(t, IsKeyFrameStart(CodecAV1, []byte{0x98}))
+
+	require.False(t, IsKeyFrameStart(CodecUnknown, []byte{0x10, 0x00}))
+	require.False(t, IsKeyFrameStart(CodecVP8, nil))
+}
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	c := NewCache(CacheParams{Codec: CodecVP8, MaxAge: time.Second}, nil)
+
+	// delta frames are not cached
+	c.AddPacket(packet(1, 1000, true, 0x10, 0x01), now)
+	_, ok := c.KeyFrame(now)
+	require.False(t, ok)
+
+	c.AddPacket(packet(2, 2000, false, 0x10, 0x00), now)
+	c.AddPacket(packet(3, 2000, false, 0x00, 0xaa), now)
+	_, ok = c.KeyFrame(now)
+	require.False(t, ok)
+	c.AddPacket(packet(4, 2000, true, 0x00, 0xbb), now)
+
+	kf, ok := c.KeyFrame(now)
+	require.True(t, ok)
+	require.Equal(t, uint32(2000), kf.Timestamp)
+	require.Len(t, kf.Packets, 3)
+	var p rtp.Packet
+	require.NoError(t, p.Unmarshal(kf.Packets[2]))
+	require.Equal(t, uint16(4), p.SequenceNumber)
+	require.True(t, p.Marker)
+
+	// delta frames do not replace it
+	c.AddPacket(packet(5, 3000, true, 0x10, 0x01), now)
+	kf, ok = c.KeyFrame(now)
+	require.True(t, ok)
+	require.Equal(t, uint32(2000), kf.Timestamp)
+
+	// stale
+	_, ok = c.KeyFrame(now.Add(2 * time.Second))
+	require.False(t, ok)
+	_, ok = c.KeyFrame(now)
+	require.False(t, ok)
+	require.Equal(t, CacheStats{NumCached: 1}, c.Stats())
+}
+
+func TestCacheDiscardsIncomplete(t *testing.T) {
+	now := time.Now()
+	c := NewCache(CacheParams{Codec: CodecVP8}, nil)
+
+	// gap
+	c.AddPacket(packet(1, 1000, false, 0x10, 0x00), now)
+	c.AddPacket(packet(3, 1000, true, 0x00, 0xaa), now)
+	_, ok := c.KeyFrame(now)
+	require.False(t, ok)
+
+	// next frame starts without the marker
+	c.AddPacket(packet(4, 2000, false, 0x10, 0x00), now)
+	c.AddPacket(packet(5, 3000, true, 0x10, 0x01), now)
+	_, ok = c.KeyFrame(now)
+	require.False(t, ok)
+	require.Equal(t, 2, c.Stats().NumInvalid)
+
+	// H264 IDR without parameter sets
+	h := NewCache(CacheParams{Codec: CodecH264}, nil)
+	h.AddPacket(packet(1, 1000, true, 0x65, 0x88), now)
+	_, ok = h.KeyFrame(now)
+	require.False(t, ok)
+	require.Equal(t, 1, h.Stats().NumInvalid)
+
+	// STAP-A with SPS and PPS, then fragmented IDR
+	h.AddPacket(packet(2, 2000, false, 0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce), now)
+	h.AddPacket(packet(3, 2000, false, 0x7c, 0x85, 0x88), now)
+	h.AddPacket(packet(4, 2000, true, 0x7c, 0x45, 0x88), now)
+	kf, ok := h.KeyFrame(now)
+	require.True(t, ok)
+	require.Len(t, kf.Packets, 3)
+}
+
+func TestCacheMemoryBudget(t *testing.T) {
+	now := time.Now()
+	budget := membudget.NewBudget(membudget.BudgetParams{MaxBytes: 100})
+	c := NewCache(CacheParams{Codec: CodecAV1}, budget)
+
+	c.AddPacket(packet(1, 1000, true, append([]byte{0x18}, make([]byte, 39)...)...), now)
+	_, ok := c.KeyFrame(now)
+	require.True(t, ok)
+	require.Equal(t, 12+40, budget.Usage())
+
+	// replacing the frame releases the previous one
+	c.AddPacket(packet(2, 2000, true, append([]byte{0x18}, make([]byte, 19)...)...), now)
+	kf, ok := c.KeyFrame(now)
+	require.True(t, ok)
+	require.Equal(t, uint32(2000), kf.Timestamp)
+	require.Equal(t, 12+20, budget.Usage())
+
+	// another buffer needing the memory shrinks the cache first
+	h := budget.Register(membudget.ClassRetention, 0)
+	require.True(t, h.Reserve(80))
+	_, ok = c.KeyFrame(now)
+	require.False(t, ok)
+	require.Equal(t, 80, budget.Usage())
+
+	// does not fit in the budget
+	c.AddPacket(packet(3, 3000, true, append([]byte{0x18}, make([]byte, 19)...)...), now)
+	_, ok = c.KeyFrame(now)
+	require.False(t, ok)
+	require.Equal(t, 1, c.Stats().NumOverBudget)
+
+	c.Close()
+	h.Close()
+	require.Equal(t, 0, budget.Usage())
+}
@@ -0,0 +1,225 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyframe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+type Codec int
+
+const (
+	CodecUnknown Codec = iota
+	CodecVP8
+	CodecVP9
+	CodecH264
+	CodecAV1
+)
+
+func (c Codec) String() string {
+	switch c {
+	case CodecUnknown:
+		return "UNKNOWN"
+	case CodecVP8:
+		return "VP8"
+	case CodecVP9:
+		return "VP9"
+	case CodecH264:
+		return "H264"
+	case CodecAV1:
+		return "AV1"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
+const (
+	h264NALUTypeIDR   = 5
+	h264NALUTypeSPS   = 7
+	h264NALUTypePPS   = 8
+	h264NALUTypeSTAPA = 24
+	h264NALUTypeFUA   = 28
+)
+
+// h264NALUs is a bit set of the NAL unit types starting in a packet
+type h264NALUs uint32
+
+func (n h264NALUs) has(naluType byte) bool {
+	return n&(1<<naluType) != 0
+}
+
+func (n h264NALUs) isComplete() bool {
+	return n.has(h264NALUTypeSPS) && n.has(h264NALUTypePPS) && n.has(h264NALUTypeIDR)
+}
+
+// IsKeyFrameStart returns true if the payload is the first packet of a key frame.
+// For VP9 with spatial layers, only the base layer frame counts as a start.
+func IsKeyFrameStart(codec Codec, payload []byte) bool {
+	switch codec {
+	case CodecVP8:
+		return isVP8KeyFrameStart(payload)
+	case CodecVP9:
+		return isVP9KeyFrameStart(payload)
+	case CodecH264:
+		nalus := parseH264NALUs(payload)
+		return nalus.has(h264NALUTypeSPS) || nalus.has(h264NALUTypeIDR)
+	case CodecAV1:
+		return isAV1KeyFrameStart(payload)
+	default:
+		return false
+	}
+}
+
+// IsKeyFrame returns true if the payloads of a frame make up a decodable key frame,
+// for H264 the SPS and PPS must be in band.
+func IsKeyFrame(codec Codec, payloads [][]byte) bool {
+	if len(payloads) == 0 || !IsKeyFrameStart(codec, payloads[0]) {
+		return false
+	}
+	if codec != CodecH264 {
+		return true
+	}
+
+	var nalus h264NALUs
+	for _, payload := range payloads {
+		nalus |= parseH264NALUs(payload)
+	}
+	return nalus.isComplete()
+}
+
+// RFC 7741
+func isVP8KeyFrameStart(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+
+	// start of partition 0
+	if payload[0]&0x10 == 0 || payload[0]&0x0f != 0 {
+		return false
+	}
+
+	idx := 1
+	if payload[0]&0x80 != 0 {
+		if len(payload) < idx+1 {
+			return false
+		}
+		ext := payload[idx]
+		idx++
+		if ext&0x80 != 0 {
+			// picture ID, 7 or 15 bits
+			if len(payload) < idx+1 {
+				return false
+			}
+			if payload[idx]&0x80 != 0 {
+				idx++
+			}
+			idx++
+		}
+		if ext&0x40 != 0 {
+			// TL0PICIDX
+			idx++
+		}
+		if ext&0x30 != 0 {
+			// TID / KEYIDX
+			idx++
+		}
+	}
+	if len(payload) < idx+1 {
+		return false
+	}
+
+	// P bit of the VP8 payload header is 0 for key frames
+	return payload[idx]&0x01 == 0
+}
+
+// draft-ietf-payload-vp9
+func isVP9KeyFrameStart(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+
+	desc := payload[0]
+	// P (inter-picture predicted) clear, B (start of frame) set
+	if desc&0x40 != 0 || desc&0x08 == 0 {
+		return false
+	}
+
+	if desc&0x20 == 0 {
+		// no layer indices, non-layered stream
+		return true
+	}
+
+	idx := 1
+	if desc&0x80 != 0 {
+		// picture ID, 7 or 15 bits
+		if len(payload) < idx+1 {
+			return false
+		}
+		if payload[idx]&0x80 != 0 {
+			idx++
+		}
+		idx++
+	}
+	if len(payload) < idx+1 {
+		return false
+	}
+
+	// spatial layer 0
+	return (payload[idx]>>1)&0x07 == 0
+}
+
+// RFC 6184, the NAL unit types that start in the payload
+func parseH264NALUs(payload []byte) h264NALUs {
+	var nalus h264NALUs
+	if len(payload) < 1 {
+		return nalus
+	}
+
+	naluType := payload[0] & 0x1f
+	switch {
+	case naluType >= 1 && naluType < h264NALUTypeSTAPA:
+		nalus |= 1 << naluType
+
+	case naluType == h264NALUTypeSTAPA:
+		idx := 1
+		for idx+2 < len(payload) {
+			size := int(binary.BigEndian.Uint16(payload[idx:]))
+			idx += 2
+			if size == 0 || idx+size > len(payload) {
+				break
+			}
+			nalus |= 1 << (payload[idx] & 0x1f)
+			idx += size
+		}
+
+	case naluType == h264NALUTypeFUA:
+		// only the start fragment counts
+		if len(payload) >= 2 && payload[1]&0x80 != 0 {
+			nalus |= 1 << (payload[1] & 0x1f)
+		}
+	}
+	return nalus
+}
+
+// AV1 RTP payload format, N bit of the aggregation header marks the first packet of a coded video sequence
+func isAV1KeyFrameStart(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+
+	// Z clear (does not continue an OBU) and N set
+	return payload[0]&0x80 == 0 && payload[0]&0x08 != 0
+}
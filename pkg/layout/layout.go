@@ -0,0 +1,145 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/livekit/mediatransportutil/pkg/wire"
+)
+
+const (
+	version = 1
+
+	headerSize       = 1 + 4 + 2 + 2 + 1
+	regionFixedSize  = 1 + 2 + 2 + 2 + 2 + 1
+	maxRegions       = 255
+	maxSourceIDBytes = 255
+)
+
+var (
+	ErrInvalidLayout      = errors.New("invalid layout message")
+	ErrUnsupportedVersion = errors.New("unsupported layout message version")
+	ErrTooManyRegions     = errors.New("too many layout regions")
+	ErrSourceIDTooLong    = errors.New("layout source ID too long")
+)
+
+// Region is the rectangle a source occupies in the composited frame, in pixels of the canvas.
+type Region struct {
+	SourceID string
+	// regions with higher ZOrder are drawn on top
+	ZOrder uint8
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+}
+
+// Layout describes the composited frames starting at Timestamp, the RTP timestamp of the composited video track,
+// until the next layout.
+type Layout struct {
+	Timestamp uint32
+	Width     uint16
+	Height    uint16
+	Regions   []Region
+}
+
+// Equal returns true if the layouts place the same sources identically, ignoring the timestamp.
+func (l *Layout) Equal(other *Layout) bool {
+	if l.Width != other.Width || l.Height != other.Height || len(l.Regions) != len(other.Regions) {
+		return false
+	}
+	for i := range l.Regions {
+		if l.Regions[i] != other.Regions[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshal encodes the layout for a metadata channel (e.g. a data channel alongside the composited track).
+//
+// Format, big endian
+//
+//	version (1) | timestamp (4) | width (2) | height (2) | number of regions (1) | regions
+//	region: z order (1) | x (2) | y (2) | width (2) | height (2) | source ID length (1) | source ID
+func (l *Layout) Marshal() ([]byte, error) {
+	if len(l.Regions) > maxRegions {
+		return nil, ErrTooManyRegions
+	}
+
+	size := headerSize
+	for _, r := range l.Regions {
+		if len(r.SourceID) > maxSourceIDBytes {
+			return nil, ErrSourceIDTooLong
+		}
+		size += regionFixedSize + len(r.SourceID)
+	}
+
+	data := make([]byte, size)
+	data[0] = version
+	binary.BigEndian.PutUint32(data[1:], l.Timestamp)
+	binary.BigEndian.PutUint16(data[5:], l.Width)
+	binary.BigEndian.PutUint16(data[7:], l.Height)
+	data[9] = byte(len(l.Regions))
+
+	idx := headerSize
+	for _, r := range l.Regions {
+		data[idx] = r.ZOrder
+		binary.BigEndian.PutUint16(data[idx+1:], r.X)
+		binary.BigEndian.PutUint16(data[idx+3:], r.Y)
+		binary.BigEndian.PutUint16(data[idx+5:], r.Width)
+		binary.BigEndian.PutUint16(data[idx+7:], r.Height)
+		data[idx+9] = byte(len(r.SourceID))
+		idx += regionFixedSize
+		idx += copy(data[idx:], r.SourceID)
+	}
+	return data, nil
+}
+
+func Unmarshal(data []byte) (*Layout, error) {
+	r := wire.NewReader(data)
+	if v := r.Uint8(); r.Err() == nil && v != version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	l := &Layout{
+		Timestamp: r.Uint32(),
+		Width:     r.Uint16(),
+		Height:    r.Uint16(),
+	}
+	numRegions := int(r.Uint8())
+	if numRegions != 0 && r.Err() == nil {
+		l.Regions = make([]Region, 0, numRegions)
+	}
+
+	for i := 0; i < numRegions && r.Err() == nil; i++ {
+		region := Region{
+			ZOrder: r.Uint8(),
+			X:      r.Uint16(),
+			Y:      r.Uint16(),
+			Width:  r.Uint16(),
+			Height: r.Uint16(),
+		}
+		region.SourceID = string(r.Bytes(int(r.Uint8())))
+		l.Regions = append(l.Regions, region)
+	}
+	if err := r.Finish(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLayout, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/wire/wiretest"
+)
+
+func speakerLayout(ts uint32) *Layout {
+	return &Layout{
+		Timestamp: ts,
+		Width:     1280,
+		Height:    720,
+		Regions: []Region{
+			{SourceID: "TR_speaker", X: 0, Y: 0, Width: 1280, Height: 720},
+			{SourceID: "TR_other", ZOrder: 1, X: 960, Y: 540, Width: 320, Height: 180},
+		},
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	l := speakerLayout(0xfffffff0)
+	data, err := l.Marshal()
+	require.NoError(t, err)
+
+	decoded, err := Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, l, decoded)
+
+	empty := &Layout{Timestamp: 1, Width: 640, Height: 360}
+	data, err = empty.Marshal()
+	require.NoError(t, err)
+	decoded, err = Unmarshal(data)
+	require.NoError(t, err)
+	require.Equal(t, empty, decoded)
+
+	// truncated, trailing bytes, version
+	data, err = l.Marshal()
+	require.NoError(t, err)
+	for i := 0; i < len(data); i++ {
+		_, err = Unmarshal(data[:i])
+		require.Error(t, err)
+	}
+	_, err = Unmarshal(append(data, 0))
+	require.ErrorIs(t, err, ErrInvalidLayout)
+	data[0] = 2
+	_, err = Unmarshal(data)
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	_, err = (&Layout{Regions: []Region{{SourceID: strings.Repeat("a", 256)}}}).Marshal()
+	require.ErrorIs(t, err, ErrSourceIDTooLong)
+	_, err = (&Layout{Regions: make([]Region, 256)}).Marshal()
+	require.ErrorIs(t, err, ErrTooManyRegions)
+}
+
+func layoutCorpus(t testing.TB) [][]byte {
+	var corpus [][]byte
+	for _, l := range []*Layout{speakerLayout(1000), {Width: 640, Height: 360}} {
+		data, err := l.Marshal()
+		require.NoError(t, err)
+		corpus = append(corpus, data)
+	}
+	return corpus
+}
+
+func unmarshalLayout(data []byte) error {
+	_, err := Unmarshal(data)
+	return err
+}
+
+func TestUnmarshalCorpus(t *testing.T) {
+	wiretest.Check(t, layoutCorpus(t), unmarshalLayout)
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	wiretest.Fuzz(f, layoutCorpus(f), unmarshalLayout)
+}
+
+func TestPublisher(t *testing.T) {
+	p := NewPublisher(PublisherParams{RepeatInterval: time.Second})
+	var sent [][]byte
+	p.OnSend(func(data []byte) {
+		sent = append(sent, data)
+	})
+
+	now := time.Now()
+	require.NoError(t, p.Update(speakerLayout(1000), now))
+	require.Len(t, sent, 1)
+
+	// unchanged layout on later frames is not sent
+	require.NoError(t, p.Update(speakerLayout(4000), now.Add(33*time.Millisecond)))
+	require.Len(t, sent, 1)
+
+	p.Tick(now.Add(500 * time.Millisecond))
+	require.Len(t, sent, 1)
+	p.Tick(now.Add(time.Second))
+	require.Len(t, sent, 2)
+	require.Equal(t, sent[0], sent[1])
+
+	changed := speakerLayout(7000)
+	changed.Regions[1].X = 0
+	require.NoError(t, p.Update(changed, now.Add(1100*time.Millisecond)))
+	require.Len(t, sent, 3)
+	decoded, err := Unmarshal(sent[2])
+	require.NoError(t, err)
+	require.Equal(t, uint32(7000), decoded.Timestamp)
+}
+
+func TestTimeline(t *testing.T) {
+	tl := NewTimeline(3)
+	require.Nil(t, tl.At(100))
+
+	// out of order and across wrap around
+	a := speakerLayout(0xffffff00)
+	b := speakerLayout(0x00000100)
+	c := speakerLayout(0xffffff80)
+	tl.Add(a)
+	tl.Add(b)
+	tl.Add(c)
+
+	require.Nil(t, tl.At(0xfffffe00))
+	require.Equal(t, a, tl.At(0xffffff00))
+	require.Equal(t, a, tl.At(0xffffff7f))
+	require.Equal(t, c, tl.At(0x00000000))
+	require.Equal(t, b, tl.At(0x00001000))
+
+	// replace same timestamp
+	b2 := speakerLayout(0x00000100)
+	tl.Add(b2)
+	require.Same(t, b2, tl.At(0x00000100))
+
+	// oldest dropped
+	tl.Add(speakerLayout(0x00000200))
+	require.Nil(t, tl.At(0xffffff00))
+	require.Equal(t, c, tl.At(0xffffff80))
+}
@@ -0,0 +1,158 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layout
+
+import (
+	"sync"
+	"time"
+)
+
+type PublisherParams struct {
+	// the current layout is sent again this often, for receivers that join late or lose a message
+	RepeatInterval time.Duration
+}
+
+var PublisherParamsDefault = PublisherParams{
+	RepeatInterval: time.Second,
+}
+
+// Publisher sends layouts on the compositor side, only when the layout changes and periodically.
+type Publisher struct {
+	params PublisherParams
+
+	lock       sync.Mutex
+	current    *Layout
+	data       []byte
+	lastSentAt time.Time
+	onSend     func(data []byte)
+}
+
+func NewPublisher(params PublisherParams) *Publisher {
+	return &Publisher{
+		params: params,
+	}
+}
+
+// OnSend sets the callback writing a marshalled layout to the metadata channel.
+func (p *Publisher) OnSend(f func(data []byte)) {
+	p.lock.Lock()
+	p.onSend = f
+	p.lock.Unlock()
+}
+
+// Update is called with the layout of a composited frame, it is sent if it differs from the current layout.
+func (p *Publisher) Update(layout *Layout, at time.Time) error {
+	p.lock.Lock()
+	if p.current != nil && p.current.Equal(layout) {
+		p.lock.Unlock()
+		return nil
+	}
+
+	data, err := layout.Marshal()
+	if err != nil {
+		p.lock.Unlock()
+		return err
+	}
+	p.current = layout
+	p.data = data
+	p.lastSentAt = at
+	onSend := p.onSend
+	p.lock.Unlock()
+
+	if onSend != nil {
+		onSend(data)
+	}
+	return nil
+}
+
+// Tick sends the current layout again if RepeatInterval has elapsed since it was last sent.
+func (p *Publisher) Tick(at time.Time) {
+	p.lock.Lock()
+	if p.data == nil || p.params.RepeatInterval <= 0 || at.Sub(p.lastSentAt) < p.params.RepeatInterval {
+		p.lock.Unlock()
+		return
+	}
+	p.lastSentAt = at
+	data := p.data
+	onSend := p.onSend
+	p.lock.Unlock()
+
+	if onSend != nil {
+		onSend(data)
+	}
+}
+
+// ------------------------------------------------
+
+// Timeline holds received layouts on the consumer side (e.g. egress), so that each composited frame
+// can be matched with the layout in effect at its RTP timestamp, regardless of how metadata and media
+// are delayed relative to each other.
+type Timeline struct {
+	maxLayouts int
+
+	lock    sync.Mutex
+	layouts []*Layout
+}
+
+// NewTimeline creates a timeline holding at most maxLayouts layouts, oldest are dropped first.
+func NewTimeline(maxLayouts int) *Timeline {
+	if maxLayouts <= 0 {
+		maxLayouts = 1
+	}
+	return &Timeline{
+		maxLayouts: maxLayouts,
+	}
+}
+
+// Add inserts a layout in timestamp order, replacing a layout with the same timestamp.
+func (t *Timeline) Add(layout *Layout) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	idx := len(t.layouts)
+	for idx > 0 && isBefore(layout.Timestamp, t.layouts[idx-1].Timestamp) {
+		idx--
+	}
+	if idx > 0 && t.layouts[idx-1].Timestamp == layout.Timestamp {
+		t.layouts[idx-1] = layout
+		return
+	}
+
+	t.layouts = append(t.layouts, nil)
+	copy(t.layouts[idx+1:], t.layouts[idx:])
+	t.layouts[idx] = layout
+
+	if excess := len(t.layouts) - t.maxLayouts; excess > 0 {
+		t.layouts = append(t.layouts[:0], t.layouts[excess:]...)
+	}
+}
+
+// At returns the layout in effect at the given RTP timestamp, nil if no layout starts at or before it.
+func (t *Timeline) At(ts uint32) *Layout {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i := len(t.layouts) - 1; i >= 0; i-- {
+		if !isBefore(ts, t.layouts[i].Timestamp) {
+			return t.layouts[i]
+		}
+	}
+	return nil
+}
+
+// isBefore compares RTP timestamps, handling wrap around
+func isBefore(a uint32, b uint32) bool {
+	return int32(a-b) < 0
+}
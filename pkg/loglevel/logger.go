@@ -0,0 +1,87 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglevel
+
+import (
+	"github.com/livekit/protocol/logger"
+)
+
+// Logger is a logger.Logger that drops logs below the current level of its subsystem.
+// Loggers derived with WithValues, WithName and WithCallDepth follow the same subsystem.
+type Logger struct {
+	logger    logger.Logger
+	subsystem *subsystem
+}
+
+// Logger wraps l for a subsystem, the level is checked on each log without locking.
+func (r *Registry) Logger(name string, l logger.Logger) *Logger {
+	r.lock.Lock()
+	s := r.subsystemLocked(name)
+	r.lock.Unlock()
+
+	return &Logger{
+		logger:    l.WithCallDepth(1),
+		subsystem: s,
+	}
+}
+
+func (l *Logger) enabled(level Level) bool {
+	current := Level(l.subsystem.level.Load())
+	return current == LevelUnset || level >= current
+}
+
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LevelDebug) {
+		l.logger.Debugw(msg, keysAndValues...)
+	}
+}
+
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	if l.enabled(LevelInfo) {
+		l.logger.Infow(msg, keysAndValues...)
+	}
+}
+
+func (l *Logger) Warnw(msg string, err error, keysAndValues ...interface{}) {
+	if l.enabled(LevelWarn) {
+		l.logger.Warnw(msg, err, keysAndValues...)
+	}
+}
+
+func (l *Logger) Errorw(msg string, err error, keysAndValues ...interface{}) {
+	if l.enabled(LevelError) {
+		l.logger.Errorw(msg, err, keysAndValues...)
+	}
+}
+
+func (l *Logger) WithValues(keysAndValues ...interface{}) logger.Logger {
+	return &Logger{logger: l.logger.WithValues(keysAndValues...), subsystem: l.subsystem}
+}
+
+func (l *Logger) WithName(name string) logger.Logger {
+	return &Logger{logger: l.logger.WithName(name), subsystem: l.subsystem}
+}
+
+func (l *Logger) WithCallDepth(depth int) logger.Logger {
+	return &Logger{logger: l.logger.WithCallDepth(depth), subsystem: l.subsystem}
+}
+
+func (l *Logger) WithItemSampler() logger.Logger {
+	return &Logger{logger: l.logger.WithItemSampler(), subsystem: l.subsystem}
+}
+
+func (l *Logger) WithoutSampler() logger.Logger {
+	return &Logger{logger: l.logger.WithoutSampler(), subsystem: l.subsystem}
+}
@@ -0,0 +1,266 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglevel
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrInvalidLevel    = errors.New("invalid log level")
+	ErrInvalidDuration = errors.New("invalid override duration")
+)
+
+type Level int32
+
+const (
+	// LevelUnset does not filter, the wrapped logger's own level applies
+	LevelUnset Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelUnset:
+		return "UNSET"
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("%d", int(l))
+	}
+}
+
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return LevelUnset, fmt.Errorf("%w: %q", ErrInvalidLevel, s)
+	}
+}
+
+const (
+	SubsystemMux       = "mux"
+	SubsystemDiscovery = "discovery"
+	SubsystemPacer     = "pacer"
+	SubsystemBWE       = "bwe"
+)
+
+type RegistryParams struct {
+	// level of subsystems without an override, LevelUnset leaves filtering to the wrapped logger
+	DefaultLevel Level
+	// longest override, so that a forgotten debug level cannot flood logs for good
+	MaxDuration time.Duration
+}
+
+var RegistryParamsDefault = RegistryParams{
+	DefaultLevel: LevelUnset,
+	MaxDuration:  time.Hour,
+}
+
+var defaultRegistry = NewRegistry(RegistryParamsDefault)
+
+// Default returns the registry shared by the subsystems of this module.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+type Override struct {
+	Subsystem string    `json:"subsystem"`
+	Level     string    `json:"level"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type subsystem struct {
+	level atomic.Int32
+
+	override   Level
+	expiresAt  time.Time
+	timer      *time.Timer
+	generation uint64
+}
+
+// Registry holds the log level of each subsystem, which can be raised or lowered at runtime for a bounded
+// duration, after which the default level applies again. Loggers of a subsystem are wrapped with Logger.
+// Logs more verbose than the wrapped logger's own level are not written whatever the subsystem level,
+// to raise a subsystem to debug, configure the wrapped logger at debug and set DefaultLevel to the usual level.
+// Typical use is
+//
+//	loglevel.Default().Set(loglevel.SubsystemPacer, loglevel.LevelDebug, 10*time.Minute)
+type Registry struct {
+	params RegistryParams
+
+	lock       sync.Mutex
+	subsystems map[string]*subsystem
+	onChange   func(subsystem string, level Level)
+}
+
+func NewRegistry(params RegistryParams) *Registry {
+	if params.MaxDuration <= 0 {
+		params.MaxDuration = RegistryParamsDefault.MaxDuration
+	}
+	return &Registry{
+		params:     params,
+		subsystems: make(map[string]*subsystem),
+	}
+}
+
+// OnChange sets a callback called with the new effective level of a subsystem when an override is set,
+// reset or expires.
+func (r *Registry) OnChange(f func(subsystem string, level Level)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onChange = f
+}
+
+// Set overrides the level of a subsystem for duration, replacing a previous override.
+func (r *Registry) Set(name string, level Level, duration time.Duration) error {
+	if level < LevelDebug || level > LevelError {
+		return ErrInvalidLevel
+	}
+	if duration <= 0 || duration > r.params.MaxDuration {
+		return fmt.Errorf("%w: %s, max %s", ErrInvalidDuration, duration, r.params.MaxDuration)
+	}
+
+	r.lock.Lock()
+	s := r.subsystemLocked(name)
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.override = level
+	s.expiresAt = time.Now().Add(duration)
+	s.level.Store(int32(level))
+	s.generation++
+	generation := s.generation
+	s.timer = time.AfterFunc(duration, func() {
+		r.expire(name, generation)
+	})
+	onChange := r.onChange
+	r.lock.Unlock()
+
+	if onChange != nil {
+		onChange(name, level)
+	}
+	return nil
+}
+
+// Reset removes the override of a subsystem before it expires.
+func (r *Registry) Reset(name string) {
+	r.lock.Lock()
+	s := r.subsystems[name]
+	if s == nil || s.timer == nil {
+		r.lock.Unlock()
+		return
+	}
+	r.resetLocked(s)
+	onChange := r.onChange
+	r.lock.Unlock()
+
+	if onChange != nil {
+		onChange(name, r.params.DefaultLevel)
+	}
+}
+
+func (r *Registry) expire(name string, generation uint64) {
+	r.lock.Lock()
+	s := r.subsystems[name]
+	// replaced or reset since
+	if s == nil || s.timer == nil || s.generation != generation {
+		r.lock.Unlock()
+		return
+	}
+	r.resetLocked(s)
+	onChange := r.onChange
+	r.lock.Unlock()
+
+	if onChange != nil {
+		onChange(name, r.params.DefaultLevel)
+	}
+}
+
+func (r *Registry) resetLocked(s *subsystem) {
+	s.timer.Stop()
+	s.timer = nil
+	s.override = LevelUnset
+	s.expiresAt = time.Time{}
+	s.level.Store(int32(r.params.DefaultLevel))
+}
+
+// Level returns the effective level of a subsystem.
+func (r *Registry) Level(name string) Level {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return Level(r.subsystemLocked(name).level.Load())
+}
+
+// Overrides returns the active overrides, by subsystem name.
+func (r *Registry) Overrides() []Override {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var overrides []Override
+	for name, s := range r.subsystems {
+		if s.timer == nil {
+			continue
+		}
+		overrides = append(overrides, Override{
+			Subsystem: name,
+			Level:     s.override.String(),
+			ExpiresAt: s.expiresAt,
+		})
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Subsystem < overrides[j].Subsystem
+	})
+	return overrides
+}
+
+// Render returns the active overrides, register it with a debughttp.Handler.
+func (r *Registry) Render() interface{} {
+	return r.Overrides()
+}
+
+func (r *Registry) subsystemLocked(name string) *subsystem {
+	s := r.subsystems[name]
+	if s == nil {
+		s = &subsystem{}
+		s.level.Store(int32(r.params.DefaultLevel))
+		r.subsystems[name] = s
+	}
+	return s
+}
@@ -0,0 +1,121 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglevel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+type testLogger struct {
+	logger.Logger
+	logs []string
+}
+
+func (l *testLogger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.logs = append(l.logs, "debug:"+msg)
+}
+
+func (l *testLogger) Infow(msg string, keysAndValues ...interface{}) {
+	l.logs = append(l.logs, "info:"+msg)
+}
+
+func (l *testLogger) Warnw(msg string, err error, keysAndValues ...interface{}) {
+	l.logs = append(l.logs, "warn:"+msg)
+}
+
+func (l *testLogger) WithValues(keysAndValues ...interface{}) logger.Logger {
+	return l
+}
+
+func (l *testLogger) WithCallDepth(depth int) logger.Logger {
+	return l
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(RegistryParams{DefaultLevel: LevelInfo, MaxDuration: time.Minute})
+	var changes []Level
+	r.OnChange(func(subsystem string, level Level) {
+		require.Equal(t, SubsystemPacer, subsystem)
+		changes = append(changes, level)
+	})
+
+	tl := &testLogger{}
+	l := r.Logger(SubsystemPacer, tl)
+	other := r.Logger(SubsystemMux, tl)
+
+	l.Debugw("a")
+	l.Infow("b")
+	require.Equal(t, []string{"info:b"}, tl.logs)
+
+	require.ErrorIs(t, r.Set(SubsystemPacer, LevelDebug, time.Hour), ErrInvalidDuration)
+	require.ErrorIs(t, r.Set(SubsystemPacer, LevelUnset, time.Second), ErrInvalidLevel)
+
+	// raised for the pacer only
+	require.NoError(t, r.Set(SubsystemPacer, LevelDebug, time.Minute))
+	tl.logs = nil
+	l.WithValues("k", "v").Debugw("c")
+	other.Debugw("d")
+	require.Equal(t, []string{"debug:c"}, tl.logs)
+	require.Equal(t, LevelDebug, r.Level(SubsystemPacer))
+	require.Equal(t, LevelInfo, r.Level(SubsystemMux))
+
+	overrides := r.Overrides()
+	require.Len(t, overrides, 1)
+	require.Equal(t, SubsystemPacer, overrides[0].Subsystem)
+	require.Equal(t, "DEBUG", overrides[0].Level)
+
+	// lowered, then back to the default
+	require.NoError(t, r.Set(SubsystemPacer, LevelWarn, time.Minute))
+	tl.logs = nil
+	l.Infow("e")
+	l.Warnw("f", nil)
+	require.Equal(t, []string{"warn:f"}, tl.logs)
+
+	r.Reset(SubsystemPacer)
+	require.Equal(t, LevelInfo, r.Level(SubsystemPacer))
+	require.Empty(t, r.Overrides())
+	require.Equal(t, []Level{LevelDebug, LevelWarn, LevelInfo}, changes)
+}
+
+func TestRegistryExpiry(t *testing.T) {
+	r := NewRegistry(RegistryParamsDefault)
+
+	require.NoError(t, r.Set(SubsystemBWE, LevelDebug, 10*time.Millisecond))
+	require.Equal(t, LevelDebug, r.Level(SubsystemBWE))
+	require.Eventually(t, func() bool {
+		return r.Level(SubsystemBWE) == LevelUnset
+	}, time.Second, 5*time.Millisecond)
+	require.Empty(t, r.Overrides())
+
+	// an expired override does not reset a newer one
+	require.NoError(t, r.Set(SubsystemBWE, LevelWarn, 10*time.Millisecond))
+	require.NoError(t, r.Set(SubsystemBWE, LevelError, time.Minute))
+	time.Sleep(30 * time.Millisecond)
+	require.Equal(t, LevelError, r.Level(SubsystemBWE))
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("Debug")
+	require.NoError(t, err)
+	require.Equal(t, LevelDebug, level)
+
+	_, err = ParseLevel("verbose")
+	require.ErrorIs(t, err, ErrInvalidLevel)
+}
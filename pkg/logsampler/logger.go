@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logsampler
+
+import (
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+// Logger is a logger.Logger that rate limits logs through a Sampler, keyed by level and message.
+// Loggers derived with WithValues, WithName and WithCallDepth share the sampler.
+type Logger struct {
+	logger  logger.Logger
+	sampler *Sampler
+}
+
+// NewLogger wraps l, sampler may be shared by several loggers to account suppressed logs together.
+func NewLogger(l logger.Logger, sampler *Sampler) *Logger {
+	return &Logger{
+		logger:  l.WithCallDepth(1),
+		sampler: sampler,
+	}
+}
+
+func (l *Logger) Sampler() *Sampler {
+	return l.sampler
+}
+
+func (l *Logger) Debugw(msg string, keysAndValues ...interface{}) {
+	if ok, kv := l.allow("debug", msg, keysAndValues); ok {
+		l.logger.Debugw(msg, kv...)
+	}
+}
+
+func (l *Logger) Infow(msg string, keysAndValues ...interface{}) {
+	if ok, kv := l.allow("info", msg, keysAndValues); ok {
+		l.logger.Infow(msg, kv...)
+	}
+}
+
+func (l *Logger) Warnw(msg string, err error, keysAndValues ...interface{}) {
+	if ok, kv := l.allow("warn", msg, keysAndValues); ok {
+		l.logger.Warnw(msg, err, kv...)
+	}
+}
+
+func (l *Logger) Errorw(msg string, err error, keysAndValues ...interface{}) {
+	if ok, kv := l.allow("error", msg, keysAndValues); ok {
+		l.logger.Errorw(msg, err, kv...)
+	}
+}
+
+func (l *Logger) WithValues(keysAndValues ...interface{}) logger.Logger {
+	return &Logger{logger: l.logger.WithValues(keysAndValues...), sampler: l.sampler}
+}
+
+func (l *Logger) WithName(name string) logger.Logger {
+	return &Logger{logger: l.logger.WithName(name), sampler: l.sampler}
+}
+
+func (l *Logger) WithCallDepth(depth int) logger.Logger {
+	return &Logger{logger: l.logger.WithCallDepth(depth), sampler: l.sampler}
+}
+
+func (l *Logger) WithItemSampler() logger.Logger {
+	return &Logger{logger: l.logger.WithItemSampler(), sampler: l.sampler}
+}
+
+// WithoutSampler returns the wrapped logger, without rate limiting
+func (l *Logger) WithoutSampler() logger.Logger {
+	return l.logger.WithCallDepth(-1).WithoutSampler()
+}
+
+func (l *Logger) allow(level string, msg string, keysAndValues []interface{}) (bool, []interface{}) {
+	ok, suppressed := l.sampler.Allow(level+":"+msg, time.Now())
+	if !ok || suppressed == 0 {
+		return ok, keysAndValues
+	}
+	return true, append(keysAndValues[:len(keysAndValues):len(keysAndValues)], "suppressed", suppressed)
+}
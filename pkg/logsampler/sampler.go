@@ -0,0 +1,144 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logsampler
+
+import (
+	"sync"
+	"time"
+)
+
+type SamplerParams struct {
+	// per message, the first Burst logs of every Interval are written
+	Interval time.Duration
+	Burst    int
+	// after Burst, one in every SampleEvery logs is written, 0 drops the rest of the interval
+	SampleEvery int
+}
+
+var SamplerParamsDefault = SamplerParams{
+	Interval:    10 * time.Second,
+	Burst:       5,
+	SampleEvery: 1000,
+}
+
+var defaultSampler = NewSampler(SamplerParamsDefault)
+
+// Default returns the sampler shared by hot paths of this module, its Stats report their suppressed logs.
+func Default() *Sampler {
+	return defaultSampler
+}
+
+type SamplerStats struct {
+	// suppressed logs by message since the sampler was created
+	Suppressed      map[string]uint64
+	TotalSuppressed uint64
+}
+
+type entry struct {
+	windowStart time.Time
+	count       int
+	// suppressed since the last written log, reported with it
+	pending    int
+	suppressed uint64
+}
+
+// Sampler rate limits logs by message, so that a flood of identical errors on a per packet path
+// cannot saturate logging. The number of logs suppressed since the previous written one is
+// reported with the next written log, and in total through Stats.
+type Sampler struct {
+	params SamplerParams
+
+	lock            sync.Mutex
+	entries         map[string]*entry
+	totalSuppressed uint64
+	lastPrune       time.Time
+}
+
+func NewSampler(params SamplerParams) *Sampler {
+	if params.Interval <= 0 {
+		params.Interval = SamplerParamsDefault.Interval
+	}
+	if params.Burst <= 0 {
+		params.Burst = SamplerParamsDefault.Burst
+	}
+
+	return &Sampler{
+		params:  params,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Allow returns whether a log with the given key should be written at the given time,
+// and if so, how many logs with that key were suppressed since the last one written.
+func (s *Sampler) Allow(key string, at time.Time) (bool, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pruneLocked(at)
+
+	e := s.entries[key]
+	if e == nil {
+		e = &entry{windowStart: at}
+		s.entries[key] = e
+	}
+	if at.Sub(e.windowStart) >= s.params.Interval {
+		e.windowStart = at
+		e.count = 0
+	}
+
+	e.count++
+	if e.count <= s.params.Burst ||
+		(s.params.SampleEvery > 0 && (e.count-s.params.Burst)%s.params.SampleEvery == 0) {
+		pending := e.pending
+		e.pending = 0
+		return true, pending
+	}
+
+	e.pending++
+	e.suppressed++
+	s.totalSuppressed++
+	return false, 0
+}
+
+func (s *Sampler) Stats() SamplerStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stats := SamplerStats{
+		Suppressed:      make(map[string]uint64),
+		TotalSuppressed: s.totalSuppressed,
+	}
+	for key, e := range s.entries {
+		if e.suppressed != 0 {
+			stats.Suppressed[key] = e.suppressed
+		}
+	}
+	return stats
+}
+
+// drops keys that have been quiet for an interval and have nothing to report,
+// so that messages with varying text do not grow the map without bound
+func (s *Sampler) pruneLocked(at time.Time) {
+	if at.Sub(s.lastPrune) < s.params.Interval {
+		return
+	}
+	s.lastPrune = at
+
+	for key, e := range s.entries {
+		if e.pending == 0 && e.suppressed == 0 && at.Sub(e.windowStart) >= s.params.Interval {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logsampler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/protocol/logger"
+)
+
+func TestSampler(t *testing.T) {
+	s := NewSampler(SamplerParams{Interval: time.Second, Burst: 2, SampleEvery: 3})
+	now := time.Now()
+
+	var written []int
+	for i := 0; i < 10; i++ {
+		if ok, suppressed := s.Allow("a", now); ok {
+			written = append(written, suppressed)
+		}
+	}
+	// 2 in the burst, then every third
+	require.Equal(t, []int{0, 0, 2, 2}, written)
+
+	// other keys are not affected
+	ok, _ := s.Allow("b", now)
+	require.True(t, ok)
+
+	// new interval, pending suppressed logs are reported
+	ok, suppressed := s.Allow("a", now.Add(time.Second))
+	require.True(t, ok)
+	require.Equal(t, 2, suppressed)
+
+	stats := s.Stats()
+	require.Equal(t, uint64(6), stats.TotalSuppressed)
+	require.Equal(t, map[string]uint64{"a": 6}, stats.Suppressed)
+}
+
+type testLogger struct {
+	logger.Logger
+	logs [][]interface{}
+}
+
+func (l *testLogger) Errorw(msg string, err error, keysAndValues ...interface{}) {
+	l.logs = append(l.logs, append([]interface{}{msg}, keysAndValues...))
+}
+
+func (l *testLogger) WithCallDepth(int) logger.Logger {
+	return l
+}
+
+func TestLogger(t *testing.T) {
+	tl := &testLogger{}
+	l := NewLogger(tl, NewSampler(SamplerParams{Interval: time.Hour, Burst: 1, SampleEvery: 2}))
+
+	for i := 0; i < 5; i++ {
+		l.Errorw("write failed", nil, "i", i)
+	}
+	require.Equal(t, [][]interface{}{
+		{"write failed", "i", 0},
+		{"write failed", "i", 2, "suppressed", 1},
+		{"write failed", "i", 4, "suppressed", 1},
+	}, tl.logs)
+	require.Equal(t, uint64(2), l.Sampler().Stats().TotalSuppressed)
+}
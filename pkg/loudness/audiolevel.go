@@ -0,0 +1,55 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loudness
+
+import (
+	"errors"
+)
+
+const (
+	audioLevelVoiceBit = 0x80
+	audioLevelMask     = 0x7f
+)
+
+var (
+	ErrInvalidAudioLevel = errors.New("invalid audio level extension")
+)
+
+// AudioLevel is the client-to-mixer audio level of a packet (RFC 6464).
+type AudioLevel struct {
+	// level in -dBov, from 0 for the loudest to 127 for silence
+	Level uint8
+	Voice bool
+}
+
+// ParseAudioLevel parses the payload of an audio level header extension. Payloads longer than a byte are accepted,
+// the level is in the first byte, padded in two-byte header extensions.
+func ParseAudioLevel(payload []byte) (AudioLevel, error) {
+	if len(payload) == 0 {
+		return AudioLevel{}, ErrInvalidAudioLevel
+	}
+	return AudioLevel{
+		Level: payload[0] & audioLevelMask,
+		Voice: payload[0]&audioLevelVoiceBit != 0,
+	}, nil
+}
+
+func (a AudioLevel) Marshal() []byte {
+	b := a.Level & audioLevelMask
+	if a.Voice {
+		b |= audioLevelVoiceBit
+	}
+	return []byte{b}
+}
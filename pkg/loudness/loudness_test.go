@@ -0,0 +1,104 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loudness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudioLevel(t *testing.T) {
+	level, err := ParseAudioLevel([]byte{0x80 | 30, 0})
+	require.NoError(t, err)
+	require.Equal(t, AudioLevel{Level: 30, Voice: true}, level)
+	require.Equal(t, []byte{0x80 | 30}, level.Marshal())
+
+	_, err = ParseAudioLevel(nil)
+	require.ErrorIs(t, err, ErrInvalidAudioLevel)
+}
+
+func observe(m *Meter, ssrc uint32, start time.Time, from time.Duration, to time.Duration, level uint8) {
+	for d := from; d < to; d += 20 * time.Millisecond {
+		m.Observe(ssrc, AudioLevel{Level: level, Voice: true}, start.Add(d))
+	}
+}
+
+func TestMeter(t *testing.T) {
+	m := NewMeter(MeterParams{})
+	start := time.Unix(1700000000, 0)
+
+	observe(m, 1, start, 0, time.Second, 30)
+	_, ok := m.Metadata(1, start.Add(time.Second))
+	require.False(t, ok)
+
+	observe(m, 1, start, time.Second, 3*time.Second, 30)
+	metadata, ok := m.Metadata(1, start.Add(3*time.Second))
+	require.True(t, ok)
+	require.InDelta(t, -30, metadata.Loudness, 0.01)
+	require.InDelta(t, 6, metadata.GainDB, 0.01)
+	require.InDelta(t, 1.995, metadata.Gain, 0.001)
+	require.InDelta(t, 3*time.Second, metadata.Duration, float64(50*time.Millisecond))
+
+	// silence is not measured
+	observe(m, 1, start, 3*time.Second, 6*time.Second, 127)
+	metadata, ok = m.Metadata(1, start.Add(6*time.Second))
+	require.True(t, ok)
+	require.InDelta(t, -30, metadata.Loudness, 0.01)
+
+	// history beyond the window is forgotten
+	observe(m, 1, start, 12*time.Second, 15*time.Second, 10)
+	metadata, ok = m.Metadata(1, start.Add(15*time.Second))
+	require.True(t, ok)
+	require.InDelta(t, -10, metadata.Loudness, 0.01)
+	require.Equal(t, -12.0, metadata.GainDB)
+
+	_, ok = m.Metadata(2, start)
+	require.False(t, ok)
+	m.Remove(1)
+	_, ok = m.Metadata(1, start.Add(15*time.Second))
+	require.False(t, ok)
+}
+
+func TestMeterRelativeGate(t *testing.T) {
+	m := NewMeter(MeterParams{})
+	start := time.Unix(1700000000, 0)
+
+	// speech at -20 with quiet breaths between words at -45
+	for i := 0; i < 10; i++ {
+		level := uint8(20)
+		if i%2 == 1 {
+			level = 45
+		}
+		from := time.Duration(i) * 800 * time.Millisecond
+		observe(m, 1, start, from, from+800*time.Millisecond, level)
+	}
+	metadata, ok := m.Metadata(1, start.Add(8*time.Second))
+	require.True(t, ok)
+	require.InDelta(t, -20, metadata.Loudness, 0.01)
+	require.InDelta(t, 4*time.Second, metadata.Duration, float64(100*time.Millisecond))
+}
+
+func TestMeterVoiceOnly(t *testing.T) {
+	m := NewMeter(MeterParams{VoiceOnly: true})
+	start := time.Unix(1700000000, 0)
+
+	for d := time.Duration(0); d < 3*time.Second; d += 20 * time.Millisecond {
+		m.Observe(1, AudioLevel{Level: 30}, start.Add(d))
+	}
+	_, ok := m.Metadata(1, start.Add(3*time.Second))
+	require.False(t, ok)
+}
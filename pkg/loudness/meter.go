@@ -0,0 +1,242 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loudness
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+type MeterParams struct {
+	// loudness is integrated over this much of the most recent history
+	Window time.Duration
+	// levels are averaged in bins of this duration, the unit gated
+	BinDuration time.Duration
+	// levels at or below this in dBov are silence, not measured
+	AbsoluteGate float64
+	// bins quieter than the ungated loudness by more than this many dB are not measured, pauses between words
+	RelativeGate float64
+	// only levels of packets flagged as voice are measured, for senders that set the flag
+	VoiceOnly bool
+	// audio measured before a gain is suggested
+	MinDuration time.Duration
+	// loudness in dBov that gain suggestions normalize to
+	TargetLoudness float64
+	MaxGain        float64
+	MaxAttenuation float64
+}
+
+var MeterParamsDefault = MeterParams{
+	Window:         10 * time.Second,
+	BinDuration:    400 * time.Millisecond,
+	AbsoluteGate:   -70,
+	RelativeGate:   10,
+	MinDuration:    2 * time.Second,
+	TargetLoudness: -24,
+	MaxGain:        12,
+	MaxAttenuation: 12,
+}
+
+// StreamMetadata describes the loudness of a stream, for mixers and clients to normalize streams to a common loudness.
+type StreamMetadata struct {
+	// integrated loudness in dBov
+	Loudness float64
+	// gain towards the target loudness in dB and linear, 1 is unity
+	GainDB float64
+	Gain   float64
+	// audio measured, after gating
+	Duration time.Duration
+}
+
+type loudnessBin struct {
+	index int64
+	// sum of the power of gated levels
+	power      float64
+	numSamples int
+	duration   time.Duration
+}
+
+type meterStream struct {
+	bins        *ring.Buffer[*loudnessBin]
+	lastLevelAt time.Time
+}
+
+// Meter approximates the loudness of audio streams from the audio levels reported by their senders, without
+// decoding the audio. Loudness is integrated over a window and gated in the way of EBU R 128: silence is
+// excluded by an absolute gate and pauses by a gate relative to the ungated loudness.
+type Meter struct {
+	params  MeterParams
+	numBins int
+
+	lock    sync.Mutex
+	streams map[uint32]*meterStream
+}
+
+func NewMeter(params MeterParams) *Meter {
+	if params.Window <= 0 {
+		params.Window = MeterParamsDefault.Window
+	}
+	if params.BinDuration <= 0 {
+		params.BinDuration = MeterParamsDefault.BinDuration
+	}
+	if params.AbsoluteGate == 0 {
+		params.AbsoluteGate = MeterParamsDefault.AbsoluteGate
+	}
+	if params.RelativeGate <= 0 {
+		params.RelativeGate = MeterParamsDefault.RelativeGate
+	}
+	if params.MinDuration <= 0 {
+		params.MinDuration = MeterParamsDefault.MinDuration
+	}
+	if params.TargetLoudness == 0 {
+		params.TargetLoudness = MeterParamsDefault.TargetLoudness
+	}
+	if params.MaxGain <= 0 {
+		params.MaxGain = MeterParamsDefault.MaxGain
+	}
+	if params.MaxAttenuation <= 0 {
+		params.MaxAttenuation = MeterParamsDefault.MaxAttenuation
+	}
+
+	numBins := int((params.Window + params.BinDuration - 1) / params.BinDuration)
+	return &Meter{
+		params:  params,
+		numBins: numBins,
+		streams: make(map[uint32]*meterStream),
+	}
+}
+
+// Observe records the audio level of a packet of the SSRC received at the given time.
+func (m *Meter) Observe(ssrc uint32, level AudioLevel, at time.Time) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s, ok := m.streams[ssrc]
+	if !ok {
+		s = &meterStream{
+			bins: ring.NewBuffer[*loudnessBin](m.numBins),
+		}
+		m.streams[ssrc] = s
+	}
+
+	// the packet covers the time since the previous one, none for the first packet after a gap or a late one
+	var duration time.Duration
+	if !s.lastLevelAt.IsZero() {
+		if elapsed := at.Sub(s.lastLevelAt); elapsed > 0 && elapsed <= m.params.BinDuration {
+			duration = elapsed
+		}
+	}
+	if at.After(s.lastLevelAt) {
+		s.lastLevelAt = at
+	}
+
+	dBov := -float64(level.Level)
+	if dBov <= m.params.AbsoluteGate || (m.params.VoiceOnly && !level.Voice) {
+		return
+	}
+
+	index := at.UnixNano() / int64(m.params.BinDuration)
+	if s.bins.Len() == 0 || s.bins.Newest().index < index {
+		s.bins.Push(&loudnessBin{index: index})
+	}
+	// late packets count towards the newest bin
+	bin := s.bins.Newest()
+	bin.power += dBToPower(dBov)
+	bin.numSamples++
+	bin.duration += duration
+}
+
+// Metadata returns the loudness of the SSRC at now, false until enough audio has been measured.
+func (m *Meter) Metadata(ssrc uint32, now time.Time) (StreamMetadata, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	s, ok := m.streams[ssrc]
+	if !ok {
+		return StreamMetadata{}, false
+	}
+
+	bins := m.binsLocked(s, now)
+	if len(bins) == 0 {
+		return StreamMetadata{}, false
+	}
+
+	ungated, _ := integrate(bins, math.Inf(-1))
+	loudness, duration := integrate(bins, ungated-m.params.RelativeGate)
+	if duration < m.params.MinDuration {
+		return StreamMetadata{}, false
+	}
+
+	gainDB := math.Max(-m.params.MaxAttenuation, math.Min(m.params.MaxGain, m.params.TargetLoudness-loudness))
+	return StreamMetadata{
+		Loudness: loudness,
+		GainDB:   gainDB,
+		Gain:     math.Pow(10, gainDB/20),
+		Duration: duration,
+	}, true
+}
+
+func (m *Meter) Remove(ssrc uint32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.streams, ssrc)
+}
+
+// binsLocked returns the bins of the stream within the window ending at now
+func (m *Meter) binsLocked(s *meterStream, now time.Time) []*loudnessBin {
+	first := now.UnixNano()/int64(m.params.BinDuration) - int64(m.numBins) + 1
+
+	var bins []*loudnessBin
+	for i := 0; i < s.bins.Len(); i++ {
+		if bin := s.bins.At(i); bin.index >= first && bin.numSamples != 0 {
+			bins = append(bins, bin)
+		}
+	}
+	return bins
+}
+
+// ------------------------------------------------
+
+// integrate returns the loudness of the bins louder than gate, averaging bins by power, and their duration
+func integrate(bins []*loudnessBin, gate float64) (float64, time.Duration) {
+	power := 0.0
+	numBins := 0
+	var duration time.Duration
+	for _, bin := range bins {
+		binPower := bin.power / float64(bin.numSamples)
+		if powerToDB(binPower) <= gate {
+			continue
+		}
+		power += binPower
+		numBins++
+		duration += bin.duration
+	}
+	if numBins == 0 {
+		return math.Inf(-1), 0
+	}
+	return powerToDB(power / float64(numBins)), duration
+}
+
+func dBToPower(dB float64) float64 {
+	return math.Pow(10, dB/10)
+}
+
+func powerToDB(power float64) float64 {
+	return 10 * math.Log10(power)
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membudget
+
+import (
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+)
+
+type number interface {
+	uint16 | uint32 | uint64
+}
+
+// BudgetedBucket is a retention bucket whose memory is accounted against a budget.
+// Growth is only allowed when the budget has room, and shrink requests from the budget
+// are applied on the next packet. Like Bucket, it is not safe for concurrent use.
+type BudgetedBucket[T number] struct {
+	*bucket.Bucket[T]
+
+	handle *Handle
+}
+
+func NewBudgetedBucket[T number](budget *Budget, capacity int) *BudgetedBucket[T] {
+	b := bucket.NewBucket[T](capacity)
+	h := budget.Register(ClassRetention, b.MemoryUsage())
+	h.Add(b.MemoryUsage())
+	return &BudgetedBucket[T]{
+		Bucket: b,
+		handle: h,
+	}
+}
+
+// Grow grows the bucket if the budget allows it, returns the resulting capacity.
+func (b *BudgetedBucket[T]) Grow() int {
+	stepBytes := b.stepBytes()
+	if !b.handle.Reserve(stepBytes) {
+		return b.Capacity()
+	}
+	return b.Bucket.Grow()
+}
+
+func (b *BudgetedBucket[T]) AddPacket(pkt []byte) ([]byte, error) {
+	b.applyPendingShrink()
+	return b.Bucket.AddPacket(pkt)
+}
+
+func (b *BudgetedBucket[T]) AddPacketWithSequenceNumber(pkt []byte, sn T) ([]byte, error) {
+	b.applyPendingShrink()
+	return b.Bucket.AddPacketWithSequenceNumber(pkt, sn)
+}
+
+// Close releases the memory of the bucket from the budget.
+func (b *BudgetedBucket[T]) Close() {
+	b.handle.Close()
+}
+
+func (b *BudgetedBucket[T]) applyPendingShrink() {
+	for b.handle.PendingShrink() > 0 {
+		before := b.MemoryUsage()
+		b.Bucket.Shrink()
+		after := b.MemoryUsage()
+		if after >= before {
+			// at minimum capacity, nothing more to give back
+			b.handle.cancelShrink()
+			break
+		}
+		b.handle.Release(before - after)
+	}
+}
+
+func (b *BudgetedBucket[T]) stepBytes() int {
+	// growth step is the initial capacity, which is the minimum usage of the handle
+	return b.handle.minBytes
+}
@@ -0,0 +1,373 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membudget
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Class determines the order in which buffers are asked to shrink when the budget is exceeded,
+// lower classes are shrunk first.
+type Class int
+
+const (
+	// caches that only speed things up (e.g. key frame cache), first to go
+	ClassCache Class = iota
+	// retransmission (NACK) history, see BudgetedBucket
+	ClassRetention
+	// jitter / reordering buffers held by the embedder
+	ClassJitter
+
+	numClasses
+)
+
+func (c Class) String() string {
+	switch c {
+	case ClassCache:
+		return "CACHE"
+	case ClassRetention:
+		return "RETENTION"
+	case ClassJitter:
+		return "JITTER"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
+type BudgetParams struct {
+	// aggregate bytes allowed across all buffers, 0 means unlimited
+	MaxBytes int
+}
+
+type ShrinkEvent struct {
+	Class          Class
+	RequestedBytes int
+	UsageBytes     int
+}
+
+// Budget caps the aggregate memory used by buffers node wide.
+//
+// The budget only does accounting, it never touches a buffer. Buffer owners account memory through a Handle,
+// and when a reservation does not fit, the budget asks other handles to shrink, class by class
+// (caches first, then retention history). Owners apply shrink requests on their own goroutine and release the freed bytes,
+// so buffers do not need to be safe for concurrent use.
+type Budget struct {
+	params BudgetParams
+
+	lock     sync.Mutex
+	handles  [numClasses][]*Handle
+	onShrink func(event ShrinkEvent)
+}
+
+func NewBudget(params BudgetParams) *Budget {
+	return &Budget{
+		params: params,
+	}
+}
+
+func (b *Budget) OnShrink(f func(event ShrinkEvent)) {
+	b.lock.Lock()
+	b.onShrink = f
+	b.lock.Unlock()
+}
+
+// Register creates a handle of the given class. Shrink requests never take the handle below minBytes.
+func (b *Budget) Register(class Class, minBytes int) *Handle {
+	h := &Handle{
+		budget:   b,
+		class:    class,
+		minBytes: minBytes,
+	}
+
+	b.lock.Lock()
+	b.handles[class] = append(b.handles[class], h)
+	b.lock.Unlock()
+	return h
+}
+
+func (b *Budget) Usage() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.usageLocked()
+}
+
+// Available returns the number of bytes that can be reserved without shrinking, -1 if unlimited.
+func (b *Budget) Available() int {
+	if b.params.MaxBytes <= 0 {
+		return -1
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if available := b.params.MaxBytes - b.effectiveUsageLocked(); available > 0 {
+		return available
+	}
+	return 0
+}
+
+// Enforce requests shrinks until aggregate usage, less already requested shrinks, is within the budget.
+func (b *Budget) Enforce() {
+	if b.params.MaxBytes <= 0 {
+		return
+	}
+
+	b.lock.Lock()
+	requested := b.requestShrinkLocked(b.effectiveUsageLocked()-b.params.MaxBytes, nil)
+	onShrink := b.onShrink
+	b.lock.Unlock()
+
+	b.notify(onShrink, requested)
+}
+
+func (b *Budget) reserve(h *Handle, bytes int) bool {
+	if b.params.MaxBytes <= 0 {
+		b.lock.Lock()
+		h.usage += bytes
+		b.lock.Unlock()
+		return true
+	}
+
+	if bytes > b.params.MaxBytes {
+		return false
+	}
+
+	b.lock.Lock()
+	needed := b.effectiveUsageLocked() + bytes - b.params.MaxBytes
+	if needed > 0 && b.reclaimableLocked(h) < needed {
+		// cannot fit even if everything else shrinks, do not disturb other buffers
+		b.lock.Unlock()
+		return false
+	}
+
+	var requested []requestedShrink
+	if needed > 0 {
+		requested = b.requestShrinkLocked(needed, h)
+	}
+	h.usage += bytes
+	onShrink := b.onShrink
+	b.lock.Unlock()
+
+	b.notify(onShrink, requested)
+	return true
+}
+
+func (b *Budget) release(h *Handle, bytes int) {
+	b.lock.Lock()
+	h.usage -= bytes
+	if h.usage < 0 {
+		h.usage = 0
+	}
+	pending := h.pending - bytes
+	if pending < 0 || h.usage <= h.minBytes {
+		pending = 0
+	}
+	h.setPendingLocked(pending)
+	b.lock.Unlock()
+}
+
+func (b *Budget) unregister(h *Handle) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	handles := b.handles[h.class]
+	for i, other := range handles {
+		if other == h {
+			b.handles[h.class] = append(handles[:i], handles[i+1:]...)
+			break
+		}
+	}
+	h.usage = 0
+	h.setPendingLocked(0)
+}
+
+func (b *Budget) usageLocked() int {
+	usage := 0
+	for _, handles := range b.handles {
+		for _, h := range handles {
+			usage += h.usage
+		}
+	}
+	return usage
+}
+
+// effective usage discounts shrinks that have been requested but not yet applied
+func (b *Budget) effectiveUsageLocked() int {
+	usage := 0
+	for _, handles := range b.handles {
+		for _, h := range handles {
+			usage += h.usage - h.pending
+		}
+	}
+	return usage
+}
+
+func (b *Budget) reclaimableLocked(exclude *Handle) int {
+	reclaimable := 0
+	for _, handles := range b.handles {
+		for _, h := range handles {
+			if h != exclude {
+				reclaimable += h.reclaimableLocked()
+			}
+		}
+	}
+	return reclaimable
+}
+
+type requestedShrink struct {
+	event  ShrinkEvent
+	notify []func()
+}
+
+func (b *Budget) requestShrinkLocked(needed int, exclude *Handle) []requestedShrink {
+	if needed <= 0 {
+		return nil
+	}
+
+	var requested []requestedShrink
+	for class, handles := range b.handles {
+		r := requestedShrink{
+			event: ShrinkEvent{Class: Class(class)},
+		}
+		for _, h := range handles {
+			if h == exclude {
+				continue
+			}
+
+			bytes := h.reclaimableLocked()
+			if bytes > needed {
+				bytes = needed
+			}
+			if bytes <= 0 {
+				continue
+			}
+
+			h.setPendingLocked(h.pending + bytes)
+			if h.onShrinkRequest != nil {
+				r.notify = append(r.notify, h.onShrinkRequest)
+			}
+			r.event.RequestedBytes += bytes
+			needed -= bytes
+			if needed <= 0 {
+				break
+			}
+		}
+
+		if r.event.RequestedBytes > 0 {
+			r.event.UsageBytes = b.usageLocked()
+			requested = append(requested, r)
+		}
+		if needed <= 0 {
+			break
+		}
+	}
+	return requested
+}
+
+func (b *Budget) notify(onShrink func(event ShrinkEvent), requested []requestedShrink) {
+	for _, r := range requested {
+		for _, f := range r.notify {
+			f()
+		}
+		if onShrink != nil {
+			onShrink(r.event)
+		}
+	}
+}
+
+// ------------------------------------------------
+
+// Handle accounts the memory of one buffer against the budget.
+// Handle methods are safe for concurrent use.
+type Handle struct {
+	budget   *Budget
+	class    Class
+	minBytes int
+
+	// guarded by budget lock
+	usage           int
+	pending         int
+	onShrinkRequest func()
+
+	// mirror of pending for lock free polling from the packet path
+	pendingShrink atomic.Int64
+}
+
+// OnShrinkRequest sets a callback invoked when the budget asks this buffer to shrink.
+// It is called from the goroutine that triggered the request and should only schedule the shrink.
+func (h *Handle) OnShrinkRequest(f func()) {
+	h.budget.lock.Lock()
+	h.onShrinkRequest = f
+	h.budget.lock.Unlock()
+}
+
+// Add accounts bytes that are already allocated, for example the initial allocation of a buffer,
+// without checking the budget.
+func (h *Handle) Add(bytes int) {
+	h.budget.lock.Lock()
+	h.usage += bytes
+	h.budget.lock.Unlock()
+}
+
+// Reserve is called before the buffer grows by the given number of bytes.
+// Returns false when the growth cannot be accommodated, in which case nothing is reserved
+// and no other buffer is asked to shrink.
+func (h *Handle) Reserve(bytes int) bool {
+	return h.budget.reserve(h, bytes)
+}
+
+// Release is called after the buffer has freed the given number of bytes,
+// it counts against any pending shrink request.
+func (h *Handle) Release(bytes int) {
+	h.budget.release(h, bytes)
+}
+
+// PendingShrink returns the number of bytes the budget has asked this buffer to free.
+func (h *Handle) PendingShrink() int {
+	return int(h.pendingShrink.Load())
+}
+
+func (h *Handle) Usage() int {
+	h.budget.lock.Lock()
+	defer h.budget.lock.Unlock()
+
+	return h.usage
+}
+
+// Close releases all memory accounted by the handle and unregisters it.
+func (h *Handle) Close() {
+	h.budget.unregister(h)
+}
+
+func (h *Handle) cancelShrink() {
+	h.budget.lock.Lock()
+	h.setPendingLocked(0)
+	h.budget.lock.Unlock()
+}
+
+func (h *Handle) reclaimableLocked() int {
+	if reclaimable := h.usage - h.pending - h.minBytes; reclaimable > 0 {
+		return reclaimable
+	}
+	return 0
+}
+
+func (h *Handle) setPendingLocked(pending int) {
+	h.pending = pending
+	h.pendingShrink.Store(int64(pending))
+}
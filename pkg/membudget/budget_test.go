@@ -0,0 +1,163 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package membudget
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+)
+
+func TestBudgetShrinksRetentionFirst(t *testing.T) {
+	b := NewBudget(BudgetParams{MaxBytes: 1000})
+
+	var events []ShrinkEvent
+	b.OnShrink(func(event ShrinkEvent) {
+		events = append(events, event)
+	})
+
+	retention := b.Register(ClassRetention, 100)
+	retention.Add(400)
+	jitter := b.Register(ClassJitter, 100)
+	jitter.Add(400)
+	grower := b.Register(ClassJitter, 0)
+
+	require.Equal(t, 800, b.Usage())
+	require.Equal(t, 200, b.Available())
+
+	// fits without shrinking
+	require.True(t, grower.Reserve(200))
+	require.Empty(t, events)
+
+	// needs 200 bytes from retention
+	require.True(t, grower.Reserve(200))
+	require.Equal(t, 200, retention.PendingShrink())
+	require.Zero(t, jitter.PendingShrink())
+	require.Equal(t, []ShrinkEvent{{Class: ClassRetention, RequestedBytes: 200, UsageBytes: 1000}}, events)
+
+	// owner applies the shrink
+	retention.Release(200)
+	require.Zero(t, retention.PendingShrink())
+	require.Equal(t, 1000, b.Usage())
+
+	// retention has 100 more to give, rest comes from jitter
+	events = nil
+	require.True(t, grower.Reserve(200))
+	require.Equal(t, 100, retention.PendingShrink())
+	require.Equal(t, 100, jitter.PendingShrink())
+	require.Len(t, events, 2)
+}
+
+func TestBudgetReserveFailureDoesNotShrink(t *testing.T) {
+	b := NewBudget(BudgetParams{MaxBytes: 1000})
+
+	retention := b.Register(ClassRetention, 100)
+	retention.Add(400)
+	grower := b.Register(ClassJitter, 0)
+	grower.Add(300)
+
+	// larger than the whole budget
+	require.False(t, grower.Reserve(1001))
+	require.Zero(t, retention.PendingShrink())
+
+	// fits in the budget, but not even if retention shrinks to its minimum
+	require.False(t, grower.Reserve(700))
+	require.Zero(t, retention.PendingShrink())
+	require.Equal(t, 700, b.Usage())
+}
+
+func TestBudgetClose(t *testing.T) {
+	b := NewBudget(BudgetParams{MaxBytes: 1000})
+
+	h := b.Register(ClassRetention, 0)
+	h.Add(600)
+	require.Equal(t, 600, b.Usage())
+
+	h.Close()
+	require.Equal(t, 0, b.Usage())
+}
+
+func TestBudgetedBucket(t *testing.T) {
+	slotBytes := bucket.MaxPktSize + 2
+	b := NewBudget(BudgetParams{MaxBytes: 40 * slotBytes})
+
+	q := NewBudgetedBucket[uint16](b, 10)
+	require.Equal(t, 20, q.Grow())
+	require.Equal(t, 30, q.Grow())
+
+	// another bucket needs room, q is asked to shrink
+	other := NewBudgetedBucket[uint16](b, 10)
+	require.Equal(t, 20, other.Grow())
+	require.Equal(t, 10*slotBytes, q.handle.PendingShrink())
+
+	// shrink is applied by the owner on the next packet
+	pkt, err := (&rtp.Packet{Header: rtp.Header{SequenceNumber: 1}}).Marshal()
+	require.NoError(t, err)
+	_, err = q.AddPacket(pkt)
+	require.NoError(t, err)
+	require.Equal(t, 20, q.Capacity())
+	require.Zero(t, q.handle.PendingShrink())
+	require.Equal(t, 40*slotBytes, b.Usage())
+
+	// q can give back one more step
+	require.Equal(t, 30, other.Grow())
+	require.Equal(t, 10*slotBytes, q.handle.PendingShrink())
+
+	// q is at its minimum once the pending shrink is applied, growth is refused without asking for more
+	require.Equal(t, 30, other.Grow())
+	require.Equal(t, 10*slotBytes, q.handle.PendingShrink())
+
+	pkt, err = (&rtp.Packet{Header: rtp.Header{SequenceNumber: 2}}).Marshal()
+	require.NoError(t, err)
+	_, err = q.AddPacket(pkt)
+	require.NoError(t, err)
+	require.Equal(t, 10, q.Capacity())
+	require.Equal(t, 40*slotBytes, b.Usage())
+
+	q.Close()
+	other.Close()
+	require.Zero(t, b.Usage())
+}
+
+func TestBudgetedBucketConcurrent(t *testing.T) {
+	b := NewBudget(BudgetParams{MaxBytes: 200 * (bucket.MaxPktSize + 2)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			q := NewBudgetedBucket[uint16](b, 10)
+			defer q.Close()
+
+			for sn := uint16(0); sn < 500; sn++ {
+				pkt, err := (&rtp.Packet{Header: rtp.Header{SequenceNumber: sn}}).Marshal()
+				require.NoError(t, err)
+				_, err = q.AddPacket(pkt)
+				require.NoError(t, err)
+				if sn%50 == 0 {
+					q.Grow()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	require.Zero(t, b.Usage())
+}
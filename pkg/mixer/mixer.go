@@ -0,0 +1,341 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mixer
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// largest Opus packet is 3 * 1275 + 7 bytes
+	maxPayloadSize = 4000
+	// longest Opus packet
+	maxPacketDuration = 120 * time.Millisecond
+	silenceLevel      = -127.0
+)
+
+// Decoder decodes one encoded packet (e.g. Opus) into interleaved PCM at the mixer sample rate and channel count,
+// returning the number of samples written to pcm, all channels included.
+type Decoder interface {
+	Decode(payload []byte, pcm []int16) (int, error)
+}
+
+// Encoder encodes one frame of interleaved PCM, returning the number of bytes written to payload.
+type Encoder interface {
+	Encode(pcm []int16, payload []byte) (int, error)
+}
+
+type MixerParams struct {
+	SampleRate    int
+	Channels      int
+	FrameDuration time.Duration
+	// decoded audio buffered per source beyond this is dropped, oldest first, at least 120 ms
+	MaxLatency time.Duration
+	// a source is speaking when the level of its frame is above this, in dBov
+	VADThreshold float64
+	// a source keeps speaking for this long after its level drops
+	VADHangover time.Duration
+	// gain applied to sources that are not speaking while another source speaks, 1 disables ducking
+	DuckingGain float64
+}
+
+var MixerParamsDefault = MixerParams{
+	SampleRate:    48000,
+	Channels:      1,
+	FrameDuration: 20 * time.Millisecond,
+	MaxLatency:    200 * time.Millisecond,
+	VADThreshold:  -50,
+	VADHangover:   300 * time.Millisecond,
+	DuckingGain:   0.25,
+}
+
+// Mixer mixes the decoded audio of several sources into one encoded track, for example for recording
+// or bridging a room into a SIP call. Each source has its own gain, and sources that are not speaking
+// are ducked while another source speaks.
+//
+// Mixing is driven by the mixer when started, one frame per FrameDuration delivered through OnFrame,
+// or by calling MixFrame.
+type Mixer struct {
+	params       MixerParams
+	encoder      Encoder
+	frameSamples int
+	maxSamples   int
+
+	lock      sync.Mutex
+	sources   map[string]*Source
+	mixBuf    []float64
+	pcm       []int16
+	onFrame   func(payload []byte)
+	isStopped bool
+
+	close chan struct{}
+}
+
+func NewMixer(params MixerParams, encoder Encoder) *Mixer {
+	if params.SampleRate <= 0 {
+		params.SampleRate = MixerParamsDefault.SampleRate
+	}
+	if params.Channels <= 0 {
+		params.Channels = MixerParamsDefault.Channels
+	}
+	if params.FrameDuration <= 0 {
+		params.FrameDuration = MixerParamsDefault.FrameDuration
+	}
+	if params.MaxLatency < maxPacketDuration {
+		params.MaxLatency = maxPacketDuration
+	}
+	if params.DuckingGain <= 0 {
+		params.DuckingGain = 1
+	}
+
+	frameSamples := int(int64(params.SampleRate)*int64(params.FrameDuration)/int64(time.Second)) * params.Channels
+	return &Mixer{
+		params:       params,
+		encoder:      encoder,
+		frameSamples: frameSamples,
+		maxSamples:   int(int64(params.SampleRate)*int64(params.MaxLatency)/int64(time.Second)) * params.Channels,
+		sources:      make(map[string]*Source),
+		mixBuf:       make([]float64, frameSamples),
+		pcm:          make([]int16, frameSamples),
+		close:        make(chan struct{}),
+	}
+}
+
+func (m *Mixer) OnFrame(f func(payload []byte)) {
+	m.lock.Lock()
+	m.onFrame = f
+	m.lock.Unlock()
+}
+
+func (m *Mixer) Start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.isStopped {
+		return
+	}
+	go m.worker()
+}
+
+func (m *Mixer) Stop() {
+	m.lock.Lock()
+	if m.isStopped {
+		m.lock.Unlock()
+		return
+	}
+
+	close(m.close)
+	m.isStopped = true
+	m.lock.Unlock()
+}
+
+// AddSource adds a source with unity gain, replacing a source with the same ID.
+func (m *Mixer) AddSource(id string, decoder Decoder) *Source {
+	s := &Source{
+		mixer:     m,
+		id:        id,
+		decoder:   decoder,
+		decodeBuf: make([]int16, m.maxSamples),
+		gain:      1,
+		level:     silenceLevel,
+	}
+
+	m.lock.Lock()
+	m.sources[id] = s
+	m.lock.Unlock()
+	return s
+}
+
+func (m *Mixer) RemoveSource(id string) {
+	m.lock.Lock()
+	delete(m.sources, id)
+	m.lock.Unlock()
+}
+
+// MixFrame mixes one frame of every source and returns it encoded.
+func (m *Mixer) MixFrame(at time.Time) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := range m.mixBuf {
+		m.mixBuf[i] = 0
+	}
+
+	// take a frame from each source first, ducking depends on whether anyone speaks in this frame
+	frames := make(map[*Source][]int16, len(m.sources))
+	anySpeaking := false
+	for _, s := range m.sources {
+		frame := s.takeFrameLocked(m.frameSamples)
+		s.level = level(frame, m.frameSamples)
+		if s.level > m.params.VADThreshold {
+			s.lastSpeechAt = at
+		}
+		s.isSpeaking = !s.lastSpeechAt.IsZero() && at.Sub(s.lastSpeechAt) < m.params.VADHangover
+		if s.isSpeaking {
+			anySpeaking = true
+		}
+		frames[s] = frame
+	}
+
+	for s, frame := range frames {
+		gain := s.gain
+		if anySpeaking && !s.isSpeaking {
+			gain *= m.params.DuckingGain
+		}
+		for i, sample := range frame {
+			m.mixBuf[i] += float64(sample) * gain
+		}
+	}
+
+	for i, sample := range m.mixBuf {
+		m.pcm[i] = clip(sample)
+	}
+
+	payload := make([]byte, maxPayloadSize)
+	n, err := m.encoder.Encode(m.pcm, payload)
+	if err != nil {
+		return nil, err
+	}
+	return payload[:n], nil
+}
+
+func (m *Mixer) worker() {
+	ticker := time.NewTicker(m.params.FrameDuration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			payload, err := m.MixFrame(time.Now())
+			if err != nil {
+				continue
+			}
+
+			m.lock.Lock()
+			onFrame := m.onFrame
+			m.lock.Unlock()
+			if onFrame != nil {
+				onFrame(payload)
+			}
+
+		case <-m.close:
+			return
+		}
+	}
+}
+
+// ------------------------------------------------
+
+type Source struct {
+	mixer   *Mixer
+	id      string
+	decoder Decoder
+	// only used by Push
+	decodeBuf []int16
+
+	// guarded by mixer lock
+	gain         float64
+	samples      []int16
+	level        float64
+	lastSpeechAt time.Time
+	isSpeaking   bool
+}
+
+func (s *Source) ID() string {
+	return s.id
+}
+
+// SetGain sets the linear gain applied to the source, 1 is unity.
+func (s *Source) SetGain(gain float64) {
+	s.mixer.lock.Lock()
+	s.gain = gain
+	s.mixer.lock.Unlock()
+}
+
+// Push decodes a packet of the source and queues the audio for mixing.
+// Push must not be called concurrently for the same source.
+func (s *Source) Push(payload []byte) error {
+	n, err := s.decoder.Decode(payload, s.decodeBuf)
+	if err != nil {
+		return err
+	}
+
+	m := s.mixer
+	m.lock.Lock()
+	s.samples = append(s.samples, s.decodeBuf[:n]...)
+	if excess := len(s.samples) - m.maxSamples; excess > 0 {
+		s.samples = append(s.samples[:0], s.samples[excess:]...)
+	}
+	m.lock.Unlock()
+	return nil
+}
+
+func (s *Source) IsSpeaking() bool {
+	s.mixer.lock.Lock()
+	defer s.mixer.lock.Unlock()
+
+	return s.isSpeaking
+}
+
+// Level returns the level of the last mixed frame in dBov.
+func (s *Source) Level() float64 {
+	s.mixer.lock.Lock()
+	defer s.mixer.lock.Unlock()
+
+	return s.level
+}
+
+// takeFrameLocked returns up to one frame of queued audio, short when the source underruns
+func (s *Source) takeFrameLocked(frameSamples int) []int16 {
+	n := frameSamples
+	if n > len(s.samples) {
+		n = len(s.samples)
+	}
+	frame := make([]int16, n)
+	copy(frame, s.samples)
+	s.samples = append(s.samples[:0], s.samples[n:]...)
+	return frame
+}
+
+// ------------------------------------------------
+
+// level returns the RMS level in dBov, missing samples count as silence
+func level(frame []int16, frameSamples int) float64 {
+	if frameSamples == 0 {
+		return silenceLevel
+	}
+
+	sum := 0.0
+	for _, sample := range frame {
+		sum += float64(sample) * float64(sample)
+	}
+	rms := math.Sqrt(sum / float64(frameSamples))
+	if rms == 0 {
+		return silenceLevel
+	}
+	return math.Max(20*math.Log10(rms/math.MaxInt16), silenceLevel)
+}
+
+func clip(sample float64) int16 {
+	switch {
+	case sample > math.MaxInt16:
+		return math.MaxInt16
+	case sample < math.MinInt16:
+		return math.MinInt16
+	default:
+		return int16(sample)
+	}
+}
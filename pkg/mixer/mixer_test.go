@@ -0,0 +1,176 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mixer
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pcmCodec passes little endian 16 bit PCM through
+type pcmCodec struct{}
+
+func (pcmCodec) Decode(payload []byte, pcm []int16) (int, error) {
+	n := len(payload) / 2
+	for i := 0; i < n; i++ {
+		pcm[i] = int16(binary.LittleEndian.Uint16(payload[2*i:]))
+	}
+	return n, nil
+}
+
+func (pcmCodec) Encode(pcm []int16, payload []byte) (int, error) {
+	for i, sample := range pcm {
+		binary.LittleEndian.PutUint16(payload[2*i:], uint16(sample))
+	}
+	return 2 * len(pcm), nil
+}
+
+func constant(value int16, samples int) []byte {
+	payload := make([]byte, 2*samples)
+	for i := 0; i < samples; i++ {
+		binary.LittleEndian.PutUint16(payload[2*i:], uint16(value))
+	}
+	return payload
+}
+
+func decode(t *testing.T, payload []byte) []int16 {
+	pcm := make([]int16, len(payload)/2)
+	_, err := pcmCodec{}.Decode(payload, pcm)
+	require.NoError(t, err)
+	return pcm
+}
+
+func TestMixer(t *testing.T) {
+	params := MixerParamsDefault
+	params.SampleRate = 8000
+	params.FrameDuration = 10 * time.Millisecond
+	params.DuckingGain = 1
+	m := NewMixer(params, pcmCodec{})
+
+	a := m.AddSource("a", pcmCodec{})
+	b := m.AddSource("b", pcmCodec{})
+	require.NoError(t, a.Push(constant(1000, 80)))
+	require.NoError(t, b.Push(constant(2000, 40)))
+
+	now := time.Now()
+	pcm := decode(t, mustMix(t, m, now))
+	require.Len(t, pcm, 80)
+	require.Equal(t, int16(3000), pcm[0])
+	// b underruns
+	require.Equal(t, int16(1000), pcm[79])
+
+	// gain and clipping
+	b.SetGain(0.5)
+	require.NoError(t, a.Push(constant(math.MaxInt16, 80)))
+	require.NoError(t, b.Push(constant(2000, 80)))
+	pcm = decode(t, mustMix(t, m, now.Add(10*time.Millisecond)))
+	require.Equal(t, int16(math.MaxInt16), pcm[0])
+
+	require.NoError(t, a.Push(constant(-100, 80)))
+	require.NoError(t, b.Push(constant(2000, 80)))
+	pcm = decode(t, mustMix(t, m, now.Add(20*time.Millisecond)))
+	require.Equal(t, int16(900), pcm[0])
+
+	// nothing queued
+	m.RemoveSource("b")
+	pcm = decode(t, mustMix(t, m, now.Add(30*time.Millisecond)))
+	require.Equal(t, int16(0), pcm[0])
+}
+
+func TestMixerDucking(t *testing.T) {
+	params := MixerParamsDefault
+	params.SampleRate = 8000
+	params.FrameDuration = 10 * time.Millisecond
+	params.VADThreshold = -30
+	params.DuckingGain = 0.5
+	params.VADHangover = 25 * time.Millisecond
+	m := NewMixer(params, pcmCodec{})
+
+	speaker := m.AddSource("speaker", pcmCodec{})
+	noise := m.AddSource("noise", pcmCodec{})
+
+	// -30 dBov is about 1036
+	now := time.Now()
+	require.NoError(t, speaker.Push(constant(8000, 80)))
+	require.NoError(t, noise.Push(constant(200, 80)))
+	pcm := decode(t, mustMix(t, m, now))
+	require.Equal(t, int16(8100), pcm[0])
+	require.True(t, speaker.IsSpeaking())
+	require.False(t, noise.IsSpeaking())
+	require.InDelta(t, -12.3, speaker.Level(), 0.1)
+
+	// speaker pauses, still speaking during the hangover
+	require.NoError(t, noise.Push(constant(200, 80)))
+	pcm = decode(t, mustMix(t, m, now.Add(20*time.Millisecond)))
+	require.Equal(t, int16(100), pcm[0])
+	require.True(t, speaker.IsSpeaking())
+
+	require.NoError(t, noise.Push(constant(200, 80)))
+	pcm = decode(t, mustMix(t, m, now.Add(30*time.Millisecond)))
+	require.Equal(t, int16(200), pcm[0])
+	require.False(t, speaker.IsSpeaking())
+	require.Equal(t, silenceLevel, speaker.Level())
+}
+
+func TestMixerMaxLatency(t *testing.T) {
+	params := MixerParamsDefault
+	params.SampleRate = 8000
+	params.FrameDuration = 10 * time.Millisecond
+	params.MaxLatency = 120 * time.Millisecond
+	m := NewMixer(params, pcmCodec{})
+
+	s := m.AddSource("s", pcmCodec{})
+	require.NoError(t, s.Push(constant(1, 80)))
+	for i := 0; i < 12; i++ {
+		require.NoError(t, s.Push(constant(2, 80)))
+	}
+
+	// the oldest frame was dropped
+	pcm := decode(t, mustMix(t, m, time.Now()))
+	require.Equal(t, int16(2), pcm[0])
+}
+
+func TestMixerStart(t *testing.T) {
+	params := MixerParamsDefault
+	params.FrameDuration = 10 * time.Millisecond
+	m := NewMixer(params, pcmCodec{})
+
+	frames := make(chan []byte, 10)
+	m.OnFrame(func(payload []byte) {
+		select {
+		case frames <- payload:
+		default:
+		}
+	})
+	m.Start()
+	defer m.Stop()
+
+	select {
+	case payload := <-frames:
+		require.Len(t, payload, 2*480)
+	case <-time.After(time.Second):
+		t.Fatal("no frame")
+	}
+}
+
+func mustMix(t *testing.T, m *Mixer, at time.Time) []byte {
+	payload, err := m.MixFrame(at)
+	require.NoError(t, err)
+	return payload
+}
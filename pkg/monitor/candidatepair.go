@@ -0,0 +1,202 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type PairSwitchReason int
+
+const (
+	// first selected pair of the connection
+	PairSwitchReasonInitial PairSwitchReason = iota
+	// moved from UDP to TCP
+	PairSwitchReasonTCPFallback
+	// moved from TCP back to UDP
+	PairSwitchReasonUDPRecovered
+	// moved from a direct path to a relay
+	PairSwitchReasonRelayFallback
+	// moved from a relay to a direct path
+	PairSwitchReasonDirectRecovered
+	// moved to another pair of the same transport and path type, for example on network change
+	PairSwitchReasonRenominated
+)
+
+func (r PairSwitchReason) String() string {
+	switch r {
+	case PairSwitchReasonInitial:
+		return "INITIAL"
+	case PairSwitchReasonTCPFallback:
+		return "TCP_FALLBACK"
+	case PairSwitchReasonUDPRecovered:
+		return "UDP_RECOVERED"
+	case PairSwitchReasonRelayFallback:
+		return "RELAY_FALLBACK"
+	case PairSwitchReasonDirectRecovered:
+		return "DIRECT_RECOVERED"
+	case PairSwitchReasonRenominated:
+		return "RENOMINATED"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+// IsDegraded returns true when the new path is expected to perform worse than the previous one.
+func (r PairSwitchReason) IsDegraded() bool {
+	return r == PairSwitchReasonTCPFallback || r == PairSwitchReasonRelayFallback
+}
+
+type CandidatePairSwitch struct {
+	Reason   PairSwitchReason
+	Previous *webrtc.ICECandidatePair
+	Current  *webrtc.ICECandidatePair
+	At       time.Time
+	// time the previous pair was selected for, zero for PairSwitchReasonInitial
+	PreviousSelectedFor time.Duration
+	// true if the connection was disconnected when the switch happened
+	WhileDisconnected bool
+}
+
+// CandidatePairMonitor reports changes of the selected candidate pair of a peer connection,
+// classifying the switch so it can be used for metrics and for quality messaging to the application.
+//
+// Typical use is
+//
+//	pc.SCTP().Transport().ICETransport().OnSelectedCandidatePairChange(pairMonitor.HandleSelectedCandidatePairChange)
+//	pc.OnICEConnectionStateChange(pairMonitor.HandleICEConnectionStateChange)
+type CandidatePairMonitor struct {
+	lock         sync.Mutex
+	current      *webrtc.ICECandidatePair
+	selectedAt   time.Time
+	disconnected bool
+	numSwitches  int
+	onSwitch     func(event CandidatePairSwitch)
+}
+
+func NewCandidatePairMonitor() *CandidatePairMonitor {
+	return &CandidatePairMonitor{}
+}
+
+func (c *CandidatePairMonitor) OnSwitch(f func(event CandidatePairSwitch)) {
+	c.lock.Lock()
+	c.onSwitch = f
+	c.lock.Unlock()
+}
+
+// SelectedPair returns the currently selected pair, nil if none has been selected yet.
+func (c *CandidatePairMonitor) SelectedPair() *webrtc.ICECandidatePair {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.current
+}
+
+// NumSwitches returns the number of switches after the initial selection.
+func (c *CandidatePairMonitor) NumSwitches() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.numSwitches
+}
+
+func (c *CandidatePairMonitor) HandleSelectedCandidatePairChange(pair *webrtc.ICECandidatePair) {
+	c.handlePair(pair, time.Now())
+}
+
+func (c *CandidatePairMonitor) HandleICEConnectionStateChange(state webrtc.ICEConnectionState) {
+	c.lock.Lock()
+	switch state {
+	case webrtc.ICEConnectionStateDisconnected:
+		c.disconnected = true
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		c.disconnected = false
+	}
+	c.lock.Unlock()
+}
+
+func (c *CandidatePairMonitor) handlePair(pair *webrtc.ICECandidatePair, now time.Time) {
+	if pair == nil || pair.Local == nil || pair.Remote == nil {
+		return
+	}
+
+	c.lock.Lock()
+	if c.current != nil && isSamePair(c.current, pair) {
+		c.lock.Unlock()
+		return
+	}
+
+	event := CandidatePairSwitch{
+		Reason:            classifyPairSwitch(c.current, pair),
+		Previous:          c.current,
+		Current:           pair,
+		At:                now,
+		WhileDisconnected: c.disconnected,
+	}
+	if c.current != nil {
+		event.PreviousSelectedFor = now.Sub(c.selectedAt)
+		c.numSwitches++
+	}
+	c.current = pair
+	c.selectedAt = now
+	onSwitch := c.onSwitch
+	c.lock.Unlock()
+
+	if onSwitch != nil {
+		onSwitch(event)
+	}
+}
+
+// ------------------------------------------------
+
+func classifyPairSwitch(prev, cur *webrtc.ICECandidatePair) PairSwitchReason {
+	if prev == nil {
+		return PairSwitchReasonInitial
+	}
+
+	prevRelay, curRelay := isRelayPair(prev), isRelayPair(cur)
+	switch {
+	case !prevRelay && curRelay:
+		return PairSwitchReasonRelayFallback
+	case prevRelay && !curRelay:
+		return PairSwitchReasonDirectRecovered
+	}
+
+	prevTCP, curTCP := prev.Local.Protocol == webrtc.ICEProtocolTCP, cur.Local.Protocol == webrtc.ICEProtocolTCP
+	switch {
+	case !prevTCP && curTCP:
+		return PairSwitchReasonTCPFallback
+	case prevTCP && !curTCP:
+		return PairSwitchReasonUDPRecovered
+	}
+
+	return PairSwitchReasonRenominated
+}
+
+func isRelayPair(pair *webrtc.ICECandidatePair) bool {
+	return pair.Local.Typ == webrtc.ICECandidateTypeRelay || pair.Remote.Typ == webrtc.ICECandidateTypeRelay
+}
+
+func isSameCandidate(a, b *webrtc.ICECandidate) bool {
+	return a.Protocol == b.Protocol && a.Typ == b.Typ && a.Address == b.Address && a.Port == b.Port
+}
+
+func isSamePair(a, b *webrtc.ICECandidatePair) bool {
+	return isSameCandidate(a.Local, b.Local) && isSameCandidate(a.Remote, b.Remote)
+}
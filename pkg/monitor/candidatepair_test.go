@@ -0,0 +1,80 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPair(protocol webrtc.ICEProtocol, typ webrtc.ICECandidateType, port uint16) *webrtc.ICECandidatePair {
+	return webrtc.NewICECandidatePair(
+		&webrtc.ICECandidate{Protocol: protocol, Typ: typ, Address: "10.0.0.1", Port: port},
+		&webrtc.ICECandidate{Protocol: protocol, Typ: webrtc.ICECandidateTypeSrflx, Address: "1.2.3.4", Port: 5000},
+	)
+}
+
+func TestCandidatePairMonitor(t *testing.T) {
+	udpHost := newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeHost, 7882)
+	udpHost2 := newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeHost, 7883)
+	tcpHost := newTestPair(webrtc.ICEProtocolTCP, webrtc.ICECandidateTypeHost, 7881)
+	udpRelay := newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeRelay, 3478)
+
+	start := time.Now()
+	var events []CandidatePairSwitch
+	c := NewCandidatePairMonitor()
+	c.OnSwitch(func(event CandidatePairSwitch) {
+		events = append(events, event)
+	})
+
+	c.handlePair(udpHost, start)
+	require.Len(t, events, 1)
+	require.Equal(t, PairSwitchReasonInitial, events[0].Reason)
+	require.Nil(t, events[0].Previous)
+	require.Zero(t, c.NumSwitches())
+
+	// same pair reported again is not a switch
+	c.handlePair(newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeHost, 7882), start.Add(time.Second))
+	require.Len(t, events, 1)
+
+	c.HandleICEConnectionStateChange(webrtc.ICEConnectionStateDisconnected)
+	c.handlePair(tcpHost, start.Add(10*time.Second))
+	require.Len(t, events, 2)
+	require.Equal(t, PairSwitchReasonTCPFallback, events[1].Reason)
+	require.True(t, events[1].Reason.IsDegraded())
+	require.Equal(t, udpHost, events[1].Previous)
+	require.Equal(t, 10*time.Second, events[1].PreviousSelectedFor)
+	require.True(t, events[1].WhileDisconnected)
+
+	c.HandleICEConnectionStateChange(webrtc.ICEConnectionStateConnected)
+	c.handlePair(udpHost, start.Add(15*time.Second))
+	require.Equal(t, PairSwitchReasonUDPRecovered, events[2].Reason)
+	require.False(t, events[2].WhileDisconnected)
+
+	c.handlePair(udpRelay, start.Add(20*time.Second))
+	require.Equal(t, PairSwitchReasonRelayFallback, events[3].Reason)
+
+	c.handlePair(udpHost2, start.Add(25*time.Second))
+	require.Equal(t, PairSwitchReasonDirectRecovered, events[4].Reason)
+
+	c.handlePair(udpHost, start.Add(30*time.Second))
+	require.Equal(t, PairSwitchReasonRenominated, events[5].Reason)
+
+	require.Equal(t, 5, c.NumSwitches())
+	require.Equal(t, udpHost, c.SelectedPair())
+}
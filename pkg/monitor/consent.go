@@ -0,0 +1,152 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type ConsentEventType int
+
+const (
+	// consent checks stopped getting responses on an established connection
+	ConsentEventLost ConsentEventType = iota
+	// consent checks got responses again after being lost
+	ConsentEventRestored
+	// consent expired on an established connection, connection has failed
+	ConsentEventExpired
+	// connection failed before it was ever established, not a consent failure
+	ConsentEventICEFailed
+)
+
+func (c ConsentEventType) String() string {
+	switch c {
+	case ConsentEventLost:
+		return "LOST"
+	case ConsentEventRestored:
+		return "RESTORED"
+	case ConsentEventExpired:
+		return "EXPIRED"
+	case ConsentEventICEFailed:
+		return "ICE_FAILED"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
+type ConsentEvent struct {
+	Type ConsentEventType
+	At   time.Time
+	// time the connection had been established for, zero for ConsentEventICEFailed
+	ConnectedFor time.Duration
+	// set for ConsentEventExpired and ConsentEventICEFailed
+	FailureReason ConnectionFailureReason
+}
+
+// ConsentMonitor follows ICE connection state changes of a peer connection and reports consent freshness events,
+// allowing consent loss on an established connection to be told apart from a connection that never came up.
+//
+// Typical use is pc.OnICEConnectionStateChange(consentMonitor.HandleICEConnectionStateChange).
+type ConsentMonitor struct {
+	lock        sync.Mutex
+	connectedAt time.Time
+	lost        bool
+	done        bool
+	onEvent     func(event ConsentEvent)
+}
+
+func NewConsentMonitor() *ConsentMonitor {
+	return &ConsentMonitor{}
+}
+
+func (c *ConsentMonitor) OnEvent(f func(event ConsentEvent)) {
+	c.lock.Lock()
+	c.onEvent = f
+	c.lock.Unlock()
+}
+
+func (c *ConsentMonitor) HasConnected() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return !c.connectedAt.IsZero()
+}
+
+func (c *ConsentMonitor) HandleICEConnectionStateChange(state webrtc.ICEConnectionState) {
+	c.handleState(state, time.Now())
+}
+
+func (c *ConsentMonitor) handleState(state webrtc.ICEConnectionState, now time.Time) {
+	c.lock.Lock()
+	if c.done {
+		c.lock.Unlock()
+		return
+	}
+
+	var event *ConsentEvent
+	switch state {
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		if c.connectedAt.IsZero() {
+			c.connectedAt = now
+		} else if c.lost {
+			c.lost = false
+			event = c.newEventLocked(ConsentEventRestored, now)
+		}
+
+	case webrtc.ICEConnectionStateDisconnected:
+		if !c.connectedAt.IsZero() && !c.lost {
+			c.lost = true
+			event = c.newEventLocked(ConsentEventLost, now)
+		}
+
+	case webrtc.ICEConnectionStateFailed:
+		c.done = true
+		if c.connectedAt.IsZero() {
+			event = c.newEventLocked(ConsentEventICEFailed, now)
+		} else {
+			event = c.newEventLocked(ConsentEventExpired, now)
+		}
+
+	case webrtc.ICEConnectionStateClosed:
+		c.done = true
+	}
+	onEvent := c.onEvent
+	c.lock.Unlock()
+
+	if event != nil && onEvent != nil {
+		onEvent(*event)
+	}
+}
+
+func (c *ConsentMonitor) newEventLocked(eventType ConsentEventType, now time.Time) *ConsentEvent {
+	event := &ConsentEvent{
+		Type: eventType,
+		At:   now,
+	}
+	if !c.connectedAt.IsZero() {
+		event.ConnectedFor = now.Sub(c.connectedAt)
+	}
+	switch eventType {
+	case ConsentEventExpired:
+		event.FailureReason = ConnectionFailureReasonConsentExpired
+	case ConsentEventICEFailed:
+		event.FailureReason = ConnectionFailureReasonICETimeout
+	}
+	return event
+}
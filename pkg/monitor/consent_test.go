@@ -0,0 +1,112 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsentMonitor(t *testing.T) {
+	type step struct {
+		state webrtc.ICEConnectionState
+		after time.Duration
+	}
+	type expectedEvent struct {
+		Type         ConsentEventType
+		At           time.Duration
+		ConnectedFor time.Duration
+		Reason       ConnectionFailureReason
+	}
+
+	testCases := []struct {
+		name     string
+		steps    []step
+		expected []expectedEvent
+	}{
+		{
+			name: "never connected",
+			steps: []step{
+				{webrtc.ICEConnectionStateChecking, 0},
+				{webrtc.ICEConnectionStateFailed, 30 * time.Second},
+			},
+			expected: []expectedEvent{
+				{Type: ConsentEventICEFailed, At: 30 * time.Second, Reason: ConnectionFailureReasonICETimeout},
+			},
+		},
+		{
+			name: "lost and restored",
+			steps: []step{
+				{webrtc.ICEConnectionStateChecking, 0},
+				{webrtc.ICEConnectionStateConnected, time.Second},
+				{webrtc.ICEConnectionStateDisconnected, 11 * time.Second},
+				{webrtc.ICEConnectionStateConnected, 12 * time.Second},
+			},
+			expected: []expectedEvent{
+				{Type: ConsentEventLost, At: 11 * time.Second, ConnectedFor: 10 * time.Second},
+				{Type: ConsentEventRestored, At: 12 * time.Second, ConnectedFor: 11 * time.Second},
+			},
+		},
+		{
+			name: "lost and expired",
+			steps: []step{
+				{webrtc.ICEConnectionStateConnected, time.Second},
+				{webrtc.ICEConnectionStateDisconnected, 11 * time.Second},
+				{webrtc.ICEConnectionStateFailed, 31 * time.Second},
+				// no events after failure
+				{webrtc.ICEConnectionStateConnected, 32 * time.Second},
+			},
+			expected: []expectedEvent{
+				{Type: ConsentEventLost, At: 11 * time.Second, ConnectedFor: 10 * time.Second},
+				{Type: ConsentEventExpired, At: 31 * time.Second, ConnectedFor: 30 * time.Second, Reason: ConnectionFailureReasonConsentExpired},
+			},
+		},
+		{
+			name: "closed",
+			steps: []step{
+				{webrtc.ICEConnectionStateConnected, time.Second},
+				{webrtc.ICEConnectionStateClosed, 2 * time.Second},
+				{webrtc.ICEConnectionStateFailed, 3 * time.Second},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+
+			var events []ConsentEvent
+			c := NewConsentMonitor()
+			c.OnEvent(func(event ConsentEvent) {
+				events = append(events, event)
+			})
+
+			for _, s := range tc.steps {
+				c.handleState(s.state, start.Add(s.after))
+			}
+
+			require.Len(t, events, len(tc.expected))
+			for i, expected := range tc.expected {
+				require.Equal(t, expected.Type, events[i].Type)
+				require.True(t, start.Add(expected.At).Equal(events[i].At))
+				require.Equal(t, expected.ConnectedFor, events[i].ConnectedFor)
+				require.Equal(t, expected.Reason, events[i].FailureReason)
+			}
+		})
+	}
+}
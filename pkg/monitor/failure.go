@@ -0,0 +1,207 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+type ConnectionFailureReason int
+
+const (
+	ConnectionFailureReasonNone ConnectionFailureReason = iota
+	// ICE checks never succeeded
+	ConnectionFailureReasonICETimeout
+	// ICE connected, but the DTLS handshake or transport failed
+	ConnectionFailureReasonDTLSFailure
+	// consent checks stopped getting responses on an established connection
+	ConnectionFailureReasonConsentExpired
+	// the TCP connection of the selected pair was reset
+	ConnectionFailureReasonTCPReset
+	// ICE never connected and a TURN allocation had failed
+	ConnectionFailureReasonRelayAllocationFailed
+)
+
+func (r ConnectionFailureReason) String() string {
+	switch r {
+	case ConnectionFailureReasonNone:
+		return "NONE"
+	case ConnectionFailureReasonICETimeout:
+		return "ICE_TIMEOUT"
+	case ConnectionFailureReasonDTLSFailure:
+		return "DTLS_FAILURE"
+	case ConnectionFailureReasonConsentExpired:
+		return "CONSENT_EXPIRED"
+	case ConnectionFailureReasonTCPReset:
+		return "TCP_RESET"
+	case ConnectionFailureReasonRelayAllocationFailed:
+		return "RELAY_ALLOCATION_FAILED"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type ConnectionFailure struct {
+	Reason ConnectionFailureReason
+	At     time.Time
+	// time the connection had been established for, zero if it never was
+	ConnectedFor time.Duration
+	// error reported for the failure, if any
+	Err error
+}
+
+// FailureMonitor reports a single structured reason when a peer connection fails,
+// so that failures can be counted and analysed without parsing logs.
+//
+// Typical use is
+//
+//	pc.OnICEConnectionStateChange(failureMonitor.HandleICEConnectionStateChange)
+//	pc.SCTP().Transport().OnStateChange(failureMonitor.HandleDTLSTransportStateChange)
+//	pc.SCTP().Transport().ICETransport().OnSelectedCandidatePairChange(failureMonitor.HandleSelectedCandidatePairChange)
+//
+// with TURN allocation errors and errors of TCP connections reported through
+// HandleRelayAllocationError and HandleTransportError.
+type FailureMonitor struct {
+	lock        sync.Mutex
+	connectedAt time.Time
+	isTCP       bool
+	tcpResetErr error
+	relayErr    error
+	done        bool
+	onFailure   func(failure ConnectionFailure)
+}
+
+func NewFailureMonitor() *FailureMonitor {
+	return &FailureMonitor{}
+}
+
+func (f *FailureMonitor) OnFailure(fn func(failure ConnectionFailure)) {
+	f.lock.Lock()
+	f.onFailure = fn
+	f.lock.Unlock()
+}
+
+func (f *FailureMonitor) HandleICEConnectionStateChange(state webrtc.ICEConnectionState) {
+	f.handleICEState(state, time.Now())
+}
+
+func (f *FailureMonitor) HandleDTLSTransportStateChange(state webrtc.DTLSTransportState) {
+	f.handleDTLSState(state, time.Now())
+}
+
+func (f *FailureMonitor) HandleSelectedCandidatePairChange(pair *webrtc.ICECandidatePair) {
+	if pair == nil || pair.Local == nil {
+		return
+	}
+
+	f.lock.Lock()
+	f.isTCP = pair.Local.Protocol == webrtc.ICEProtocolTCP
+	f.tcpResetErr = nil
+	f.lock.Unlock()
+}
+
+// HandleRelayAllocationError records a failed TURN allocation, it is the failure reason
+// if ICE then fails without ever connecting.
+func (f *FailureMonitor) HandleRelayAllocationError(err error) {
+	f.lock.Lock()
+	f.relayErr = err
+	f.lock.Unlock()
+}
+
+// HandleTransportError records an error on the connection of the selected pair,
+// a connection reset on a TCP pair is the failure reason if ICE then fails.
+func (f *FailureMonitor) HandleTransportError(err error) {
+	if !errors.Is(err, syscall.ECONNRESET) && !errors.Is(err, syscall.EPIPE) {
+		return
+	}
+
+	f.lock.Lock()
+	if f.isTCP {
+		f.tcpResetErr = err
+	}
+	f.lock.Unlock()
+}
+
+func (f *FailureMonitor) handleICEState(state webrtc.ICEConnectionState, now time.Time) {
+	f.lock.Lock()
+	var failure *ConnectionFailure
+	switch state {
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		if f.connectedAt.IsZero() {
+			f.connectedAt = now
+		}
+
+	case webrtc.ICEConnectionStateFailed:
+		switch {
+		case !f.connectedAt.IsZero() && f.tcpResetErr != nil:
+			failure = f.failLocked(ConnectionFailureReasonTCPReset, f.tcpResetErr, now)
+		case !f.connectedAt.IsZero():
+			failure = f.failLocked(ConnectionFailureReasonConsentExpired, nil, now)
+		case f.relayErr != nil:
+			failure = f.failLocked(ConnectionFailureReasonRelayAllocationFailed, f.relayErr, now)
+		default:
+			failure = f.failLocked(ConnectionFailureReasonICETimeout, nil, now)
+		}
+
+	case webrtc.ICEConnectionStateClosed:
+		f.done = true
+	}
+	onFailure := f.onFailure
+	f.lock.Unlock()
+
+	notifyFailure(onFailure, failure)
+}
+
+func (f *FailureMonitor) handleDTLSState(state webrtc.DTLSTransportState, now time.Time) {
+	f.lock.Lock()
+	var failure *ConnectionFailure
+	if state == webrtc.DTLSTransportStateFailed {
+		failure = f.failLocked(ConnectionFailureReasonDTLSFailure, nil, now)
+	}
+	onFailure := f.onFailure
+	f.lock.Unlock()
+
+	notifyFailure(onFailure, failure)
+}
+
+// only the first failure of a connection is reported
+func (f *FailureMonitor) failLocked(reason ConnectionFailureReason, err error, now time.Time) *ConnectionFailure {
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	failure := &ConnectionFailure{
+		Reason: reason,
+		At:     now,
+		Err:    err,
+	}
+	if !f.connectedAt.IsZero() {
+		failure.ConnectedFor = now.Sub(f.connectedAt)
+	}
+	return failure
+}
+
+func notifyFailure(onFailure func(failure ConnectionFailure), failure *ConnectionFailure) {
+	if onFailure != nil && failure != nil {
+		onFailure(*failure)
+	}
+}
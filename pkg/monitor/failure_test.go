@@ -0,0 +1,117 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureMonitor(t *testing.T) {
+	errAllocation := errors.New("allocation mismatch")
+	errReset := fmt.Errorf("read tcp: %w", syscall.ECONNRESET)
+
+	testCases := []struct {
+		name         string
+		pair         *webrtc.ICECandidatePair
+		relayErr     error
+		transportErr error
+		dtlsFailed   bool
+		connected    bool
+		expected     ConnectionFailureReason
+		expectedErr  error
+	}{
+		{
+			name:     "ice timeout",
+			expected: ConnectionFailureReasonICETimeout,
+		},
+		{
+			name:        "relay allocation failed",
+			relayErr:    errAllocation,
+			expected:    ConnectionFailureReasonRelayAllocationFailed,
+			expectedErr: errAllocation,
+		},
+		{
+			name:       "dtls failure",
+			connected:  true,
+			dtlsFailed: true,
+			expected:   ConnectionFailureReasonDTLSFailure,
+		},
+		{
+			name:      "consent expired",
+			pair:      newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeHost, 7882),
+			connected: true,
+			// resets on UDP pairs are not TCP resets
+			transportErr: errReset,
+			relayErr:     errAllocation,
+			expected:     ConnectionFailureReasonConsentExpired,
+		},
+		{
+			name:         "tcp reset",
+			pair:         newTestPair(webrtc.ICEProtocolTCP, webrtc.ICECandidateTypeHost, 7881),
+			connected:    true,
+			transportErr: errReset,
+			expected:     ConnectionFailureReasonTCPReset,
+			expectedErr:  syscall.ECONNRESET,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start := time.Now()
+
+			var failures []ConnectionFailure
+			f := NewFailureMonitor()
+			f.OnFailure(func(failure ConnectionFailure) {
+				failures = append(failures, failure)
+			})
+
+			f.handleICEState(webrtc.ICEConnectionStateChecking, start)
+			if tc.relayErr != nil {
+				f.HandleRelayAllocationError(tc.relayErr)
+			}
+			if tc.pair != nil {
+				f.HandleSelectedCandidatePairChange(tc.pair)
+			}
+			if tc.connected {
+				f.handleICEState(webrtc.ICEConnectionStateConnected, start.Add(time.Second))
+			}
+			if tc.transportErr != nil {
+				f.HandleTransportError(tc.transportErr)
+			}
+			if tc.dtlsFailed {
+				f.handleDTLSState(webrtc.DTLSTransportStateFailed, start.Add(2*time.Second))
+			}
+			f.handleICEState(webrtc.ICEConnectionStateFailed, start.Add(31*time.Second))
+
+			require.Len(t, failures, 1)
+			require.Equal(t, tc.expected, failures[0].Reason)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, failures[0].Err, tc.expectedErr)
+			} else {
+				require.NoError(t, failures[0].Err)
+			}
+			if tc.connected && !tc.dtlsFailed {
+				require.Equal(t, 30*time.Second, failures[0].ConnectedFor)
+			}
+		})
+	}
+}
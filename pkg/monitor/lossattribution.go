@@ -0,0 +1,213 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/pion/rtcp"
+)
+
+type LossLeg int
+
+const (
+	LossLegNone LossLeg = iota
+	// loss between the publisher and the node
+	LossLegUpstream
+	// loss between the node and the subscriber
+	LossLegDownstream
+	// significant loss on both legs
+	LossLegBoth
+)
+
+func (l LossLeg) String() string {
+	switch l {
+	case LossLegNone:
+		return "NONE"
+	case LossLegUpstream:
+		return "UPSTREAM"
+	case LossLegDownstream:
+		return "DOWNSTREAM"
+	case LossLegBoth:
+		return "BOTH"
+	default:
+		return fmt.Sprintf("%d", int(l))
+	}
+}
+
+type LossSplit struct {
+	// fractions of packets lost, 0 to 1, Total as reported by the subscriber
+	Total      float64
+	Upstream   float64
+	Downstream float64
+	// leg to look at, legs with loss below SignificantLoss are not blamed
+	Leg LossLeg
+}
+
+type QualityScore struct {
+	// 1 (bad) to 5 (excellent), from the loss seen by the subscriber
+	Score float64
+	// scores the subscriber would see if only one leg lost packets
+	UpstreamScore   float64
+	DownstreamScore float64
+	Loss            LossSplit
+}
+
+type LossAttributorParams struct {
+	// loss of a leg below which it is not blamed
+	SignificantLoss float64
+	// loss at which the score drops to its minimum
+	LossForMinScore float64
+}
+
+var LossAttributorParamsDefault = LossAttributorParams{
+	SignificantLoss: 0.01,
+	LossForMinScore: 0.2,
+}
+
+// LossAttributor splits the loss reported by subscribers into the upstream leg, publisher to node, and
+// the downstream leg, node to subscriber. Packets the node does not receive, or recover with retransmissions,
+// are missing in the forwarded stream too, so the subscriber reports the combined loss of both legs and the
+// downstream share is what remains after removing the upstream loss: 1 - (1-total)/(1-upstream).
+//
+// Upstream loss comes from the node's receive stats of the published stream, after retransmissions, over a window
+// comparable to the subscriber's report interval.
+type LossAttributor struct {
+	params LossAttributorParams
+
+	lock       sync.Mutex
+	upstream   map[uint32]float64
+	downstream map[string]map[uint32]float64
+}
+
+func NewLossAttributor(params LossAttributorParams) *LossAttributor {
+	if params.SignificantLoss == 0 {
+		params.SignificantLoss = LossAttributorParamsDefault.SignificantLoss
+	}
+	if params.LossForMinScore == 0 {
+		params.LossForMinScore = LossAttributorParamsDefault.LossForMinScore
+	}
+	return &LossAttributor{
+		params:     params,
+		upstream:   make(map[uint32]float64),
+		downstream: make(map[string]map[uint32]float64),
+	}
+}
+
+// SetUpstreamLoss records the fraction, 0 to 1, of packets of the published stream ssrc the node did not get.
+func (l *LossAttributor) SetUpstreamLoss(ssrc uint32, fractionLost float64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.upstream[ssrc] = clampLoss(fractionLost)
+}
+
+// HandleReceptionReport records the report of subscriberID for its down track of the published stream ssrc.
+func (l *LossAttributor) HandleReceptionReport(subscriberID string, ssrc uint32, report rtcp.ReceptionReport) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	reports := l.downstream[subscriberID]
+	if reports == nil {
+		reports = make(map[uint32]float64)
+		l.downstream[subscriberID] = reports
+	}
+	reports[ssrc] = float64(report.FractionLost) / 256
+}
+
+func (l *LossAttributor) RemoveSubscriber(subscriberID string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.downstream, subscriberID)
+}
+
+func (l *LossAttributor) RemoveSSRC(ssrc uint32) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.upstream, ssrc)
+	for subscriberID, reports := range l.downstream {
+		delete(reports, ssrc)
+		if len(reports) == 0 {
+			delete(l.downstream, subscriberID)
+		}
+	}
+}
+
+// Attribution returns the loss split of subscriberID for ssrc, false if the subscriber has not reported on it.
+// Without upstream stats all loss is attributed downstream.
+func (l *LossAttributor) Attribution(subscriberID string, ssrc uint32) (LossSplit, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	total, ok := l.downstream[subscriberID][ssrc]
+	if !ok {
+		return LossSplit{}, false
+	}
+	return l.split(total, l.upstream[ssrc]), true
+}
+
+// Quality returns the quality score of subscriberID for ssrc with the loss split, false if the subscriber
+// has not reported on it.
+func (l *LossAttributor) Quality(subscriberID string, ssrc uint32) (QualityScore, bool) {
+	split, ok := l.Attribution(subscriberID, ssrc)
+	if !ok {
+		return QualityScore{}, false
+	}
+	return QualityScore{
+		Score:           l.score(split.Total),
+		UpstreamScore:   l.score(split.Upstream),
+		DownstreamScore: l.score(split.Downstream),
+		Loss:            split,
+	}, true
+}
+
+func (l *LossAttributor) split(total float64, upstream float64) LossSplit {
+	// the subscriber cannot see less loss than the node forwarded, unless retransmissions recovered it downstream,
+	// or the measurement windows differ
+	upstream = math.Min(upstream, total)
+	split := LossSplit{
+		Total:    total,
+		Upstream: upstream,
+	}
+	if upstream < 1 {
+		split.Downstream = clampLoss(1 - (1-total)/(1-upstream))
+	}
+
+	upstreamSignificant := split.Upstream >= l.params.SignificantLoss
+	downstreamSignificant := split.Downstream >= l.params.SignificantLoss
+	switch {
+	case upstreamSignificant && downstreamSignificant:
+		split.Leg = LossLegBoth
+	case upstreamSignificant:
+		split.Leg = LossLegUpstream
+	case downstreamSignificant:
+		split.Leg = LossLegDownstream
+	}
+	return split
+}
+
+func (l *LossAttributor) score(loss float64) float64 {
+	return math.Max(1, 5-4*loss/l.params.LossForMinScore)
+}
+
+// ------------------------------------------------
+
+func clampLoss(loss float64) float64 {
+	return math.Min(math.Max(loss, 0), 1)
+}
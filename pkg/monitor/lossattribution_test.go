@@ -0,0 +1,65 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLossAttributor(t *testing.T) {
+	l := NewLossAttributor(LossAttributorParams{})
+
+	_, ok := l.Quality("sub1", 1)
+	require.False(t, ok)
+
+	// no upstream stats, all loss is downstream
+	l.HandleReceptionReport("sub1", 1, rtcp.ReceptionReport{FractionLost: 64})
+	split, ok := l.Attribution("sub1", 1)
+	require.True(t, ok)
+	require.Equal(t, LossSplit{Total: 0.25, Downstream: 0.25, Leg: LossLegDownstream}, split)
+
+	// half of the loss upstream: 1 - 0.75/0.875 downstream
+	l.SetUpstreamLoss(1, 0.125)
+	split, _ = l.Attribution("sub1", 1)
+	require.Equal(t, LossLegBoth, split.Leg)
+	require.InDelta(t, 0.125, split.Upstream, 1e-9)
+	require.InDelta(t, 1.0/7, split.Downstream, 1e-9)
+
+	// all loss upstream
+	l.HandleReceptionReport("sub2", 1, rtcp.ReceptionReport{FractionLost: 32})
+	quality, ok := l.Quality("sub2", 1)
+	require.True(t, ok)
+	require.Equal(t, LossLegUpstream, quality.Loss.Leg)
+	require.InDelta(t, 0, quality.Loss.Downstream, 1e-9)
+	require.InDelta(t, 2.5, quality.Score, 1e-9)
+	require.InDelta(t, 2.5, quality.UpstreamScore, 1e-9)
+	require.InDelta(t, 5, quality.DownstreamScore, 1e-9)
+
+	// less loss reported than upstream, capped
+	l.HandleReceptionReport("sub3", 1, rtcp.ReceptionReport{FractionLost: 0})
+	quality, _ = l.Quality("sub3", 1)
+	require.Equal(t, QualityScore{Score: 5, UpstreamScore: 5, DownstreamScore: 5}, quality)
+
+	l.RemoveSubscriber("sub1")
+	_, ok = l.Attribution("sub1", 1)
+	require.False(t, ok)
+
+	l.RemoveSSRC(1)
+	_, ok = l.Attribution("sub2", 1)
+	require.False(t, ok)
+}
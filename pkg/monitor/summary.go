@@ -0,0 +1,269 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+type NATType int
+
+const (
+	NATTypeUnknown NATType = iota
+	// host candidate with a public address
+	NATTypeNone
+	// host candidate with a private address, reachable directly only on the same network
+	NATTypePrivate
+	// reflexive candidate, behind a NAT that the path traverses
+	NATTypeMapped
+	// relay candidate, the NAT or firewall did not allow a direct path
+	NATTypeRelayed
+)
+
+func (n NATType) String() string {
+	switch n {
+	case NATTypeUnknown:
+		return "UNKNOWN"
+	case NATTypeNone:
+		return "NONE"
+	case NATTypePrivate:
+		return "PRIVATE"
+	case NATTypeMapped:
+		return "MAPPED"
+	case NATTypeRelayed:
+		return "RELAYED"
+	default:
+		return fmt.Sprintf("%d", int(n))
+	}
+}
+
+// NATTypeOf infers what lies between a candidate and the internet from its type and address.
+func NATTypeOf(c *webrtc.ICECandidate) NATType {
+	if c == nil {
+		return NATTypeUnknown
+	}
+
+	switch c.Typ {
+	case webrtc.ICECandidateTypeHost:
+		addr, err := netip.ParseAddr(c.Address)
+		if err != nil {
+			// mDNS hostname, hiding a private address
+			return NATTypePrivate
+		}
+		if addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			return NATTypePrivate
+		}
+		return NATTypeNone
+	case webrtc.ICECandidateTypeSrflx, webrtc.ICECandidateTypePrflx:
+		return NATTypeMapped
+	case webrtc.ICECandidateTypeRelay:
+		return NATTypeRelayed
+	default:
+		return NATTypeUnknown
+	}
+}
+
+type SummaryParams struct {
+	// most recent notable events kept
+	MaxEvents int
+}
+
+var SummaryParamsDefault = SummaryParams{
+	MaxEvents: 16,
+}
+
+type SummaryEvent struct {
+	// time since the summarizer was created
+	At          time.Duration
+	Description string
+}
+
+// ConnectionSummary is a snapshot of the transport of a connection, see Summarizer.
+type ConnectionSummary struct {
+	Pair      *webrtc.ICECandidatePair
+	LocalNAT  NATType
+	RemoteNAT NATType
+	Duration  time.Duration
+	// mean of the samples reported to the summarizer
+	MeanRTT  time.Duration
+	MeanLoss float64
+	// switches to a worse path, such as TCP or a relay
+	Fallbacks   []PairSwitchReason
+	NumSwitches int
+	Failure     ConnectionFailureReason
+	Events      []SummaryEvent
+}
+
+// String returns the summary on one line, for example
+//
+//	path=udp/host 10.0.0.1:7882<->udp/srflx 1.2.3.4:5000 nat=PRIVATE/MAPPED up=2m0s rtt=45ms loss=1.2% switches=1
+//	fallbacks=[RELAY_FALLBACK] failure=NONE events=[+10s consent LOST; +12s consent RESTORED]
+func (s ConnectionSummary) String() string {
+	var sb strings.Builder
+	if s.Pair != nil && s.Pair.Local != nil && s.Pair.Remote != nil {
+		fmt.Fprintf(&sb, "path=%s<->%s", summaryCandidate(s.Pair.Local), summaryCandidate(s.Pair.Remote))
+	} else {
+		sb.WriteString("path=none")
+	}
+	fmt.Fprintf(&sb, " nat=%s/%s up=%s", s.LocalNAT, s.RemoteNAT, s.Duration.Round(time.Second))
+	fmt.Fprintf(&sb, " rtt=%s loss=%.1f%% switches=%d", s.MeanRTT.Round(time.Millisecond), s.MeanLoss*100, s.NumSwitches)
+
+	fallbacks := make([]string, 0, len(s.Fallbacks))
+	for _, f := range s.Fallbacks {
+		fallbacks = append(fallbacks, f.String())
+	}
+	fmt.Fprintf(&sb, " fallbacks=[%s] failure=%s", strings.Join(fallbacks, ","), s.Failure)
+
+	events := make([]string, 0, len(s.Events))
+	for _, e := range s.Events {
+		events = append(events, fmt.Sprintf("+%s %s", e.At.Round(time.Second), e.Description))
+	}
+	fmt.Fprintf(&sb, " events=[%s]", strings.Join(events, "; "))
+	return sb.String()
+}
+
+func summaryCandidate(c *webrtc.ICECandidate) string {
+	return fmt.Sprintf("%s/%s %s:%d", c.Protocol, c.Typ, c.Address, c.Port)
+}
+
+// Summarizer collects the events of the monitors of a connection into a summary for support tooling.
+//
+// Typical use is
+//
+//	pairMonitor.OnSwitch(summarizer.HandleCandidatePairSwitch)
+//	consentMonitor.OnEvent(summarizer.HandleConsentEvent)
+//	failureMonitor.OnFailure(summarizer.HandleFailure)
+//
+// with RTT and loss sampled periodically through AddSample, for example from RTCP receiver reports.
+type Summarizer struct {
+	params    SummaryParams
+	createdAt time.Time
+
+	lock        sync.Mutex
+	pair        *webrtc.ICECandidatePair
+	numSwitches int
+	fallbacks   []PairSwitchReason
+	failure     ConnectionFailureReason
+	events      *ring.Buffer[SummaryEvent]
+	rttSum      time.Duration
+	lossSum     float64
+	numSamples  int
+}
+
+func NewSummarizer(params SummaryParams) *Summarizer {
+	return newSummarizer(params, time.Now())
+}
+
+func newSummarizer(params SummaryParams, now time.Time) *Summarizer {
+	if params.MaxEvents <= 0 {
+		params.MaxEvents = SummaryParamsDefault.MaxEvents
+	}
+	return &Summarizer{
+		params:    params,
+		createdAt: now,
+		events:    ring.NewBuffer[SummaryEvent](params.MaxEvents),
+	}
+}
+
+func (s *Summarizer) HandleCandidatePairSwitch(event CandidatePairSwitch) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.pair = event.Current
+	if event.Reason == PairSwitchReasonInitial {
+		return
+	}
+	s.numSwitches++
+	if event.Reason.IsDegraded() {
+		s.fallbacks = append(s.fallbacks, event.Reason)
+	}
+	s.addEventLocked(event.At, fmt.Sprintf("pair %s", event.Reason))
+}
+
+func (s *Summarizer) HandleConsentEvent(event ConsentEvent) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addEventLocked(event.At, fmt.Sprintf("consent %s", event.Type))
+}
+
+func (s *Summarizer) HandleFailure(failure ConnectionFailure) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failure = failure.Reason
+	description := fmt.Sprintf("failed %s", failure.Reason)
+	if failure.Err != nil {
+		description += fmt.Sprintf(": %v", failure.Err)
+	}
+	s.addEventLocked(failure.At, description)
+}
+
+// AddEvent records another notable event, for example an ICE restart.
+func (s *Summarizer) AddEvent(description string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.addEventLocked(time.Now(), description)
+}
+
+// AddSample adds an RTT and fraction lost sample to the means of the summary.
+func (s *Summarizer) AddSample(rtt time.Duration, fractionLost float64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rttSum += rtt
+	s.lossSum += fractionLost
+	s.numSamples++
+}
+
+func (s *Summarizer) Summary() ConnectionSummary {
+	return s.summary(time.Now())
+}
+
+func (s *Summarizer) summary(now time.Time) ConnectionSummary {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	summary := ConnectionSummary{
+		Pair:        s.pair,
+		Duration:    now.Sub(s.createdAt),
+		Fallbacks:   append([]PairSwitchReason{}, s.fallbacks...),
+		NumSwitches: s.numSwitches,
+		Failure:     s.failure,
+		Events:      s.events.AppendTo(nil),
+	}
+	if s.pair != nil {
+		summary.LocalNAT = NATTypeOf(s.pair.Local)
+		summary.RemoteNAT = NATTypeOf(s.pair.Remote)
+	}
+	if s.numSamples != 0 {
+		summary.MeanRTT = s.rttSum / time.Duration(s.numSamples)
+		summary.MeanLoss = s.lossSum / float64(s.numSamples)
+	}
+	return summary
+}
+
+func (s *Summarizer) addEventLocked(at time.Time, description string) {
+	s.events.Push(SummaryEvent{At: at.Sub(s.createdAt), Description: description})
+}
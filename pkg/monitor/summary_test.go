@@ -0,0 +1,64 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATTypeOf(t *testing.T) {
+	require.Equal(t, NATTypeUnknown, NATTypeOf(nil))
+	require.Equal(t, NATTypeNone, NATTypeOf(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "203.0.113.1"}))
+	require.Equal(t, NATTypePrivate, NATTypeOf(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "192.168.1.2"}))
+	require.Equal(t, NATTypePrivate, NATTypeOf(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeHost, Address: "abcd.local"}))
+	require.Equal(t, NATTypeMapped, NATTypeOf(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypePrflx, Address: "203.0.113.1"}))
+	require.Equal(t, NATTypeRelayed, NATTypeOf(&webrtc.ICECandidate{Typ: webrtc.ICECandidateTypeRelay, Address: "203.0.113.1"}))
+}
+
+func TestSummarizer(t *testing.T) {
+	start := time.Now()
+	s := newSummarizer(SummaryParams{MaxEvents: 2}, start)
+	require.Equal(t,
+		"path=none nat=UNKNOWN/UNKNOWN up=0s rtt=0s loss=0.0% switches=0 fallbacks=[] failure=NONE events=[]",
+		s.summary(start).String(),
+	)
+
+	udpHost := newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeHost, 7882)
+	udpRelay := newTestPair(webrtc.ICEProtocolUDP, webrtc.ICECandidateTypeRelay, 3478)
+	s.HandleCandidatePairSwitch(CandidatePairSwitch{Reason: PairSwitchReasonInitial, Current: udpHost, At: start})
+	s.HandleConsentEvent(ConsentEvent{Type: ConsentEventLost, At: start.Add(10 * time.Second)})
+	s.HandleCandidatePairSwitch(CandidatePairSwitch{Reason: PairSwitchReasonRelayFallback, Previous: udpHost, Current: udpRelay, At: start.Add(12 * time.Second)})
+	s.HandleFailure(ConnectionFailure{Reason: ConnectionFailureReasonConsentExpired, At: start.Add(2 * time.Minute), Err: errors.New("no response")})
+	s.AddSample(40*time.Millisecond, 0.01)
+	s.AddSample(50*time.Millisecond, 0.014)
+
+	summary := s.summary(start.Add(2 * time.Minute))
+	require.Equal(t, NATTypeRelayed, summary.LocalNAT)
+	require.Equal(t, NATTypeMapped, summary.RemoteNAT)
+	require.Equal(t, []PairSwitchReason{PairSwitchReasonRelayFallback}, summary.Fallbacks)
+	require.Equal(t, 45*time.Millisecond, summary.MeanRTT)
+	// the oldest event is dropped
+	require.Len(t, summary.Events, 2)
+	require.Equal(t,
+		"path=udp/relay 10.0.0.1:3478<->udp/srflx 1.2.3.4:5000 nat=RELAYED/MAPPED up=2m0s rtt=45ms loss=1.2% switches=1 "+
+			"fallbacks=[RELAY_FALLBACK] failure=CONSENT_EXPIRED events=[+12s pair RELAY_FALLBACK; +2m0s failed CONSENT_EXPIRED: no response]",
+		summary.String(),
+	)
+}
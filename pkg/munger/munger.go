@@ -0,0 +1,179 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package munger
+
+import (
+	"time"
+)
+
+type MungerParams struct {
+	ClockRate uint32
+
+	// When non-zero, forward timestamp jumps longer than this (for example after a long mute)
+	// are compressed so that the outgoing stream never jumps by more than MaxTimestampJump.
+	// Pacing after the jump is preserved by re-anchoring the wall clock mapper.
+	MaxTimestampJump time.Duration
+}
+
+// Munger rewrites sequence numbers and timestamps of a forwarded stream so that
+// the outgoing stream is contiguous across source changes and gaps.
+type Munger struct {
+	params MungerParams
+
+	initialized        bool
+	resyncOnNextPacket bool
+
+	snOffset uint16
+	tsOffset uint32
+
+	// timestamp offset in effect before the last compressed jump,
+	// applied to late packets with outgoing sequence number before lastCompressedSN
+	prevTSOffset     uint32
+	lastCompressedSN uint16
+	hasCompressed    bool
+
+	lastSN uint16
+	lastTS uint32
+
+	wallclock *WallclockMapper
+
+	numCompressedJumps int
+}
+
+func NewMunger(params MungerParams) *Munger {
+	return &Munger{
+		params:    params,
+		wallclock: NewWallclockMapper(params.ClockRate),
+	}
+}
+
+func (m *Munger) SetMaxTimestampJump(maxJump time.Duration) {
+	m.params.MaxTimestampJump = maxJump
+}
+
+// ResyncOnNextPacket makes the next packet continue from the last outgoing sequence number and timestamp,
+// used when the incoming stream restarts or switches source.
+func (m *Munger) ResyncOnNextPacket() {
+	m.resyncOnNextPacket = true
+}
+
+// Inject allocates outgoing sequence numbers for numPackets packets that are not forwarded from the
+// incoming stream (for example a slate frame), sharing a timestamp that follows the wall clock.
+// The next forwarded packet continues after them. Returns false until the first packet is seen.
+func (m *Munger) Inject(numPackets int, at time.Time) (uint16, uint32, bool) {
+	if !m.initialized || numPackets <= 0 {
+		return 0, 0, false
+	}
+
+	firstSN := m.lastSN + 1
+	m.lastSN += uint16(numPackets)
+	m.lastTS += m.elapsedTicks(at)
+	m.wallclock.Reset(m.lastTS, at)
+	m.resyncOnNextPacket = true
+	return firstSN, m.lastTS, true
+}
+
+func (m *Munger) Last() (uint16, uint32) {
+	return m.lastSN, m.lastTS
+}
+
+func (m *Munger) WallclockMapper() *WallclockMapper {
+	return m.wallclock
+}
+
+func (m *Munger) NumCompressedJumps() int {
+	return m.numCompressedJumps
+}
+
+// Update returns the outgoing sequence number and timestamp for an incoming packet received at the given time.
+func (m *Munger) Update(sn uint16, ts uint32, at time.Time) (uint16, uint32) {
+	if !m.initialized {
+		m.initialized = true
+		m.lastSN = sn
+		m.lastTS = ts
+		m.wallclock.Reset(ts, at)
+		return sn, ts
+	}
+
+	if m.resyncOnNextPacket {
+		m.resyncOnNextPacket = false
+
+		outTS := m.lastTS + m.elapsedTicks(at)
+		m.snOffset = sn - m.lastSN - 1
+		m.tsOffset = ts - outTS
+		m.hasCompressed = false
+		m.lastSN = sn - m.snOffset
+		m.lastTS = outTS
+		m.wallclock.Reset(outTS, at)
+		return m.lastSN, m.lastTS
+	}
+
+	outSN := sn - m.snOffset
+	if diff := outSN - m.lastSN; diff == 0 || diff > (1<<15) {
+		// duplicate or out-of-order, apply offsets in effect when the packet was sent without touching state
+		if m.hasCompressed {
+			if before := m.lastCompressedSN - outSN; before != 0 && before < (1<<15) {
+				return outSN, ts - m.prevTSOffset
+			}
+		}
+		return outSN, ts - m.tsOffset
+	}
+
+	outTS := ts - m.tsOffset
+	if tsDiff := outTS - m.lastTS; tsDiff < (1<<31) && m.shouldCompress(tsDiff) {
+		compressedTS := m.lastTS + m.boundedTicks(at)
+		m.prevTSOffset = m.tsOffset
+		m.lastCompressedSN = outSN
+		m.hasCompressed = true
+		m.tsOffset += outTS - compressedTS
+		outTS = compressedTS
+		m.wallclock.Reset(outTS, at)
+		m.numCompressedJumps++
+	}
+
+	m.lastSN = outSN
+	m.lastTS = outTS
+	return outSN, outTS
+}
+
+func (m *Munger) shouldCompress(tsDiff uint32) bool {
+	if m.params.MaxTimestampJump <= 0 || m.params.ClockRate == 0 {
+		return false
+	}
+
+	return int64(tsDiff) > m.wallclock.DurationToTicks(m.params.MaxTimestampJump)
+}
+
+// elapsedTicks returns the wall clock time since the last outgoing packet in timestamp units,
+// at least one tick so that outgoing timestamps always advance.
+func (m *Munger) elapsedTicks(at time.Time) uint32 {
+	if m.params.ClockRate == 0 {
+		return 1
+	}
+
+	ticks := int64(m.wallclock.TimestampAt(at) - m.lastTS)
+	if int32(ticks) <= 0 {
+		return 1
+	}
+	return uint32(ticks)
+}
+
+func (m *Munger) boundedTicks(at time.Time) uint32 {
+	ticks := m.elapsedTicks(at)
+	if maxTicks := uint32(m.wallclock.DurationToTicks(m.params.MaxTimestampJump)); ticks > maxTicks {
+		ticks = maxTicks
+	}
+	return ticks
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package munger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMungerPassThrough(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 90000})
+
+	now := time.Now()
+	sn, ts := m.Update(100, 1000, now)
+	require.Equal(t, uint16(100), sn)
+	require.Equal(t, uint32(1000), ts)
+
+	// large jump is forwarded as is when compression is disabled
+	sn, ts = m.Update(101, 1000+90000*60, now.Add(60*time.Second))
+	require.Equal(t, uint16(101), sn)
+	require.Equal(t, uint32(1000+90000*60), ts)
+	require.Zero(t, m.NumCompressedJumps())
+}
+
+func TestMungerCompressesTimestampJump(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 48000, MaxTimestampJump: 500 * time.Millisecond})
+
+	now := time.Now()
+	m.Update(1, 0, now)
+	m.Update(2, 960, now.Add(20*time.Millisecond))
+
+	// unmute after 30 seconds, jump should be bounded to 500 ms
+	at := now.Add(30 * time.Second)
+	sn, ts := m.Update(3, 48000*30, at)
+	require.Equal(t, uint16(3), sn)
+	require.Equal(t, uint32(960+24000), ts)
+	require.Equal(t, 1, m.NumCompressedJumps())
+
+	// pacing after the jump is preserved
+	sn, ts = m.Update(4, 48000*30+960, at.Add(20*time.Millisecond))
+	require.Equal(t, uint16(4), sn)
+	require.Equal(t, uint32(960+24000+960), ts)
+
+	// late packet from before the jump keeps the offset in effect when it was sent
+	sn, ts = m.Update(2, 960, at.Add(25*time.Millisecond))
+	require.Equal(t, uint16(2), sn)
+	require.Equal(t, uint32(960), ts)
+
+	// late packet from after the jump uses the current offsets
+	sn, ts = m.Update(3, 48000*30, at.Add(30*time.Millisecond))
+	require.Equal(t, uint16(3), sn)
+	require.Equal(t, uint32(960+24000), ts)
+}
+
+func TestMungerShortJumpUsesWallclock(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 90000, MaxTimestampJump: time.Second})
+
+	now := time.Now()
+	m.Update(10, 90000, now)
+
+	// timestamp jumps by 10 seconds, but only 100 ms of wall clock time has elapsed
+	_, ts := m.Update(11, 90000*11, now.Add(100*time.Millisecond))
+	require.Equal(t, uint32(90000+9000), ts)
+}
+
+func TestMungerResync(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 90000})
+
+	now := time.Now()
+	m.Update(1000, 5000, now)
+	m.ResyncOnNextPacket()
+
+	sn, ts := m.Update(20, 123456, now.Add(time.Second))
+	require.Equal(t, uint16(1001), sn)
+	require.Equal(t, uint32(5000+90000), ts)
+
+	sn, ts = m.Update(21, 123456+3000, now.Add(time.Second+33*time.Millisecond))
+	require.Equal(t, uint16(1002), sn)
+	require.Equal(t, uint32(5000+90000+3000), ts)
+}
+
+func TestMungerInject(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 90000})
+
+	now := time.Now()
+	_, _, ok := m.Inject(3, now)
+	require.False(t, ok)
+
+	m.Update(1000, 5000, now)
+
+	// three packets of a slate frame half a second later
+	sn, ts, ok := m.Inject(3, now.Add(500*time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, uint16(1001), sn)
+	require.Equal(t, uint32(5000+45000), ts)
+
+	// live packets continue after the injected ones
+	sn, ts = m.Update(1010, 9000, now.Add(time.Second))
+	require.Equal(t, uint16(1004), sn)
+	require.Equal(t, uint32(5000+90000), ts)
+
+	sn, ts = m.Update(1011, 9000+3000, now.Add(time.Second+33*time.Millisecond))
+	require.Equal(t, uint16(1005), sn)
+	require.Equal(t, uint32(5000+90000+3000), ts)
+}
+
+func TestWallclockMapper(t *testing.T) {
+	w := NewWallclockMapper(90000)
+	now := time.Now()
+	refTS := uint32(0xffffff00)
+	w.Reset(refTS, now)
+
+	// mapping is linear across timestamp wrap around
+	require.Equal(t, refTS+90000, w.TimestampAt(now.Add(time.Second)))
+	require.True(t, now.Add(time.Second).Equal(w.TimeAt(refTS+90000)))
+	require.True(t, now.Add(-time.Second).Equal(w.TimeAt(refTS-90000)))
+
+	// long durations do not overflow
+	require.Equal(t, int64(72*3600*90000+45000), w.DurationToTicks(72*time.Hour+500*time.Millisecond))
+	require.Equal(t, 72*time.Hour+500*time.Millisecond, w.TicksToDuration(72*3600*90000+45000))
+}
+
+func TestMungerSnapshotRestore(t *testing.T) {
+	m := NewMunger(MungerParams{ClockRate: 48000, MaxTimestampJump: 500 * time.Millisecond})
+
+	now := time.Now()
+	m.Update(1, 0, now)
+	m.Update(2, 48000*30, now.Add(30*time.Second))
+
+	m.ResyncOnNextPacket()
+
+	s := &StreamSnapshot{
+		SSRC:        1234,
+		PayloadType: 111,
+		RTX:         &RTXMapping{SSRC: 5678, PayloadType: 112},
+		Munger:      m.Snapshot(),
+		Stats:       StreamStats{Packets: 2, Bytes: 200},
+	}
+	data, err := MarshalSnapshot(s)
+	require.NoError(t, err)
+	require.Zero(t, s.Version)
+	require.True(t, s.CapturedAt.IsZero())
+
+	snapshot, err := UnmarshalSnapshot(data)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1234), snapshot.SSRC)
+	require.Equal(t, RTXMapping{SSRC: 5678, PayloadType: 112}, *snapshot.RTX)
+	require.Equal(t, uint64(2), snapshot.Stats.Packets)
+	require.True(t, s.Munger.WallclockRefAt.Equal(snapshot.Munger.WallclockRefAt))
+	expected := s.Munger
+	expected.WallclockRefAt = snapshot.Munger.WallclockRefAt
+	require.Equal(t, expected, snapshot.Munger)
+	require.True(t, snapshot.Munger.ResyncOnNextPacket)
+	require.True(t, snapshot.Munger.HasCompressed)
+
+	restored := NewMungerFromSnapshot(snapshot.Munger)
+	// source switch pending at snapshot time is honoured after restore
+	at := now.Add(30*time.Second + 20*time.Millisecond)
+	sn, ts := m.Update(100, 90000, at)
+	rsn, rts := restored.Update(100, 90000, at)
+	require.Equal(t, sn, rsn)
+	require.Equal(t, ts, rts)
+
+	at = at.Add(20 * time.Millisecond)
+	sn, ts = m.Update(101, 90000+960, at)
+	rsn, rts = restored.Update(101, 90000+960, at)
+	require.Equal(t, sn, rsn)
+	require.Equal(t, ts, rts)
+
+	_, err = UnmarshalSnapshot([]byte(`{"version":0}`))
+	require.ErrorIs(t, err, ErrUnsupportedSnapshotVersion)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package munger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	snapshotVersion = 1
+)
+
+var (
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+)
+
+// MungerState is the serializable state of a Munger.
+type MungerState struct {
+	Initialized        bool          `json:"initialized"`
+	ResyncOnNextPacket bool          `json:"resync_on_next_packet,omitempty"`
+	SNOffset           uint16        `json:"sn_offset"`
+	TSOffset           uint32        `json:"ts_offset"`
+	PrevTSOffset       uint32        `json:"prev_ts_offset,omitempty"`
+	LastCompressedSN   uint16        `json:"last_compressed_sn,omitempty"`
+	HasCompressed      bool          `json:"has_compressed,omitempty"`
+	LastSN             uint16        `json:"last_sn"`
+	LastTS             uint32        `json:"last_ts"`
+	ClockRate          uint32        `json:"clock_rate"`
+	MaxTimestampJump   time.Duration `json:"max_timestamp_jump,omitempty"`
+	WallclockRefTS     uint32        `json:"wallclock_ref_ts"`
+	WallclockRefAt     time.Time     `json:"wallclock_ref_at"`
+	NumCompressedJumps int           `json:"num_compressed_jumps,omitempty"`
+}
+
+// RTXMapping and StreamStats are not known to the munger,
+// they are filled in by the forwarder that owns the stream before marshalling.
+type RTXMapping struct {
+	SSRC        uint32 `json:"ssrc"`
+	PayloadType uint8  `json:"payload_type"`
+}
+
+type StreamStats struct {
+	Packets     uint64 `json:"packets"`
+	Bytes       uint64 `json:"bytes"`
+	PacketsLost uint64 `json:"packets_lost"`
+	NACKs       uint64 `json:"nacks"`
+	PLIs        uint64 `json:"plis"`
+}
+
+// StreamSnapshot captures per-stream forwarding state so that forwarding can be resumed
+// on another node (or after a restart) without a visible discontinuity to the subscriber.
+type StreamSnapshot struct {
+	Version     int         `json:"version"`
+	CapturedAt  time.Time   `json:"captured_at"`
+	SSRC        uint32      `json:"ssrc"`
+	PayloadType uint8       `json:"payload_type"`
+	RTX         *RTXMapping `json:"rtx,omitempty"`
+	Munger      MungerState `json:"munger"`
+	Stats       StreamStats `json:"stats"`
+}
+
+func (m *Munger) Snapshot() MungerState {
+	refTS, refAt := m.wallclock.Reference()
+	return MungerState{
+		Initialized:        m.initialized,
+		ResyncOnNextPacket: m.resyncOnNextPacket,
+		SNOffset:           m.snOffset,
+		TSOffset:           m.tsOffset,
+		PrevTSOffset:       m.prevTSOffset,
+		LastCompressedSN:   m.lastCompressedSN,
+		HasCompressed:      m.hasCompressed,
+		LastSN:             m.lastSN,
+		LastTS:             m.lastTS,
+		ClockRate:          m.params.ClockRate,
+		MaxTimestampJump:   m.params.MaxTimestampJump,
+		WallclockRefTS:     refTS,
+		WallclockRefAt:     refAt,
+		NumCompressedJumps: m.numCompressedJumps,
+	}
+}
+
+// NewMungerFromSnapshot creates a munger that continues from the snapshotted state.
+func NewMungerFromSnapshot(state MungerState) *Munger {
+	m := NewMunger(MungerParams{
+		ClockRate:        state.ClockRate,
+		MaxTimestampJump: state.MaxTimestampJump,
+	})
+	m.initialized = state.Initialized
+	m.resyncOnNextPacket = state.ResyncOnNextPacket
+	m.snOffset = state.SNOffset
+	m.tsOffset = state.TSOffset
+	m.prevTSOffset = state.PrevTSOffset
+	m.lastCompressedSN = state.LastCompressedSN
+	m.hasCompressed = state.HasCompressed
+	m.lastSN = state.LastSN
+	m.lastTS = state.LastTS
+	m.numCompressedJumps = state.NumCompressedJumps
+	if state.Initialized {
+		m.wallclock.Reset(state.WallclockRefTS, state.WallclockRefAt)
+	}
+	return m
+}
+
+// MarshalSnapshot sets the version and, if not set, the capture time on a copy, the given snapshot is not modified.
+func MarshalSnapshot(s *StreamSnapshot) ([]byte, error) {
+	c := *s
+	c.Version = snapshotVersion
+	if c.CapturedAt.IsZero() {
+		c.CapturedAt = time.Now()
+	}
+	return json.Marshal(&c)
+}
+
+func UnmarshalSnapshot(data []byte) (*StreamSnapshot, error) {
+	s := &StreamSnapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w, version: %d", ErrUnsupportedSnapshotVersion, s.Version)
+	}
+	return s, nil
+}
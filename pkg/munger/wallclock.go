@@ -0,0 +1,80 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package munger
+
+import (
+	"time"
+)
+
+// WallclockMapper maps RTP timestamps of a stream to wall clock time and back,
+// anchored at a reference (timestamp, time) pair.
+type WallclockMapper struct {
+	clockRate uint32
+
+	initialized bool
+	refTS       uint32
+	refAt       time.Time
+}
+
+func NewWallclockMapper(clockRate uint32) *WallclockMapper {
+	return &WallclockMapper{
+		clockRate: clockRate,
+	}
+}
+
+func (w *WallclockMapper) ClockRate() uint32 {
+	return w.clockRate
+}
+
+func (w *WallclockMapper) IsInitialized() bool {
+	return w.initialized
+}
+
+// Reset anchors the mapping at the given timestamp and time.
+func (w *WallclockMapper) Reset(ts uint32, at time.Time) {
+	w.initialized = true
+	w.refTS = ts
+	w.refAt = at
+}
+
+func (w *WallclockMapper) Reference() (uint32, time.Time) {
+	return w.refTS, w.refAt
+}
+
+// TimestampAt returns the RTP timestamp expected at the given time.
+func (w *WallclockMapper) TimestampAt(at time.Time) uint32 {
+	return w.refTS + uint32(int32(w.DurationToTicks(at.Sub(w.refAt))))
+}
+
+// TimeAt returns the wall clock time of the given RTP timestamp.
+// Timestamps within half the timestamp range before the reference map to times before the reference.
+func (w *WallclockMapper) TimeAt(ts uint32) time.Time {
+	return w.refAt.Add(w.TicksToDuration(int64(int32(ts - w.refTS))))
+}
+
+// DurationToTicks and TicksToDuration split whole seconds from the remainder
+// so that long durations do not overflow the intermediate product.
+func (w *WallclockMapper) DurationToTicks(d time.Duration) int64 {
+	rate := int64(w.clockRate)
+	return int64(d/time.Second)*rate + int64(d%time.Second)*rate/1e9
+}
+
+func (w *WallclockMapper) TicksToDuration(ticks int64) time.Duration {
+	if w.clockRate == 0 {
+		return 0
+	}
+	rate := int64(w.clockRate)
+	return time.Duration(ticks/rate)*time.Second + time.Duration(ticks%rate*1e9/rate)
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nack
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type RepairAction int
+
+const (
+	RepairActionNone RepairAction = iota
+	// stop forwarding the affected spatial layers until they can be switched up again
+	RepairActionDropLayers
+	// base layer is affected, a key frame is needed
+	RepairActionRequestKeyFrame
+)
+
+func (r RepairAction) String() string {
+	switch r {
+	case RepairActionNone:
+		return "NONE"
+	case RepairActionDropLayers:
+		return "DROP_LAYERS"
+	case RepairActionRequestKeyFrame:
+		return "REQUEST_KEY_FRAME"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type LayerRepairParams struct {
+	// if the dropped layers cannot be switched up within this time, a key frame is requested
+	MaxDropDuration time.Duration
+}
+
+var LayerRepairParamsDefault = LayerRepairParams{
+	MaxDropDuration: 5 * time.Second,
+}
+
+// LayerRepair decides how to repair unrecoverable losses (see NackQueue.PopGivenUp) of an SVC stream.
+// Losses confined to upper spatial layers do not need a key frame, the affected layers are dropped
+// until the next switch-up point of the lowest affected layer, avoiding key frame storms in large rooms.
+type LayerRepair struct {
+	params LayerRepairParams
+
+	lock sync.Mutex
+	// highest spatial layer that may be forwarded, -1 when no layers are dropped
+	maxLayer    int32
+	droppedAt   time.Time
+	isDropping  bool
+	numDrops    int
+	numKeyFrame int
+}
+
+func NewLayerRepair(params LayerRepairParams) *LayerRepair {
+	return &LayerRepair{
+		params:   params,
+		maxLayer: -1,
+	}
+}
+
+// HandleLoss is called with the spatial layers of packets lost without repair.
+func (l *LayerRepair) HandleLoss(spatialLayers []int32, now time.Time) RepairAction {
+	if len(spatialLayers) == 0 {
+		return RepairActionNone
+	}
+
+	lowest := spatialLayers[0]
+	for _, layer := range spatialLayers[1:] {
+		if layer < lowest {
+			lowest = layer
+		}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if lowest <= 0 {
+		l.resetLocked()
+		l.numKeyFrame++
+		return RepairActionRequestKeyFrame
+	}
+
+	if l.isDropping && lowest > l.maxLayer {
+		// already dropped
+		return RepairActionNone
+	}
+
+	if !l.isDropping {
+		l.droppedAt = now
+	}
+	l.isDropping = true
+	l.maxLayer = lowest - 1
+	l.numDrops++
+	return RepairActionDropLayers
+}
+
+// HandleSwitchPoint is called when a frame that allows switching up to the given spatial layer
+// without a key frame is received, returns true if dropped layers were restored.
+// A key frame is a switch point for all layers.
+func (l *LayerRepair) HandleSwitchPoint(spatialLayer int32) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.isDropping || spatialLayer <= l.maxLayer {
+		return false
+	}
+
+	l.resetLocked()
+	return true
+}
+
+// Check returns RepairActionRequestKeyFrame if layers have been dropped for too long.
+func (l *LayerRepair) Check(now time.Time) RepairAction {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.isDropping || now.Sub(l.droppedAt) < l.params.MaxDropDuration {
+		return RepairActionNone
+	}
+
+	l.resetLocked()
+	l.numKeyFrame++
+	return RepairActionRequestKeyFrame
+}
+
+// MaxSpatialLayer returns the highest spatial layer that may be forwarded, false if there is no restriction.
+func (l *LayerRepair) MaxSpatialLayer() (int32, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.maxLayer, l.isDropping
+}
+
+// Stats returns the number of layer drops and key frame requests decided.
+func (l *LayerRepair) Stats() (numDrops int, numKeyFrameRequests int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.numDrops, l.numKeyFrame
+}
+
+func (l *LayerRepair) resetLocked() {
+	l.isDropping = false
+	l.maxLayer = -1
+	l.droppedAt = time.Time{}
+}
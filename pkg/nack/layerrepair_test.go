@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayerRepair(t *testing.T) {
+	l := NewLayerRepair(LayerRepairParams{MaxDropDuration: 5 * time.Second})
+	now := time.Now()
+
+	require.Equal(t, RepairActionNone, l.HandleLoss(nil, now))
+
+	// upper layer loss drops layers from the lowest affected one
+	require.Equal(t, RepairActionDropLayers, l.HandleLoss([]int32{2, 1, 2}, now))
+	maxLayer, ok := l.MaxSpatialLayer()
+	require.True(t, ok)
+	require.Equal(t, int32(0), maxLayer)
+
+	// loss in already dropped layers needs nothing more
+	require.Equal(t, RepairActionNone, l.HandleLoss([]int32{2}, now))
+
+	// switch point at or below the forwarded layer does not restore
+	require.False(t, l.HandleSwitchPoint(0))
+	require.True(t, l.HandleSwitchPoint(1))
+	_, ok = l.MaxSpatialLayer()
+	require.False(t, ok)
+
+	// drop held too long escalates to a key frame
+	require.Equal(t, RepairActionDropLayers, l.HandleLoss([]int32{2}, now))
+	require.Equal(t, RepairActionNone, l.Check(now.Add(4*time.Second)))
+	require.Equal(t, RepairActionRequestKeyFrame, l.Check(now.Add(5*time.Second)))
+	_, ok = l.MaxSpatialLayer()
+	require.False(t, ok)
+
+	// base layer loss needs a key frame
+	require.Equal(t, RepairActionRequestKeyFrame, l.HandleLoss([]int32{1, 0}, now))
+
+	numDrops, numKeyFrameRequests := l.Stats()
+	require.Equal(t, 2, numDrops)
+	require.Equal(t, 2, numKeyFrameRequests)
+}
+
+func Test_nackQueue_givenUp(t *testing.T) {
+	params := NackQueueParamsDefault
+	params.MaxNacks = 2
+	params.MaxTries = 1
+	params.MinInterval = 0
+	params.MaxGivenUp = 2
+	n := NewNACKQueue(params)
+
+	n.Push(1)
+	n.Push(2)
+	// evicts 1
+	n.Push(3)
+	require.Equal(t, []uint16{1}, n.PopGivenUp())
+	require.Empty(t, n.PopGivenUp())
+
+	n.Remove(2)
+	_, numNacked := n.Pairs()
+	require.Equal(t, 1, numNacked)
+	// out of tries
+	n.Pairs()
+	require.Equal(t, []uint16{3}, n.PopGivenUp())
+
+	// only the most recent are kept
+	for sn := uint16(4); sn < 10; sn++ {
+		n.Push(sn)
+	}
+	require.Equal(t, []uint16{6, 7}, n.PopGivenUp())
+
+	// not kept unless asked for
+	params.MaxGivenUp = 0
+	n.SetParams(params)
+	n.Push(10)
+	n.Push(11)
+	require.Empty(t, n.PopGivenUp())
+}
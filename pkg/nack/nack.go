@@ -15,10 +15,13 @@
 package nack
 
 import (
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/pion/rtcp"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
 )
 
 const (
@@ -29,8 +32,36 @@ const (
 	maxInterval   = 400 * time.Millisecond // maximum interval between NACK tries for the same sequence number
 	backoffFactor = float64(1.25)
 	maxLifetime   = 2 * time.Minute
+
+	tightenedMaxTries = 1
+	// a stricter mode is left once RTT falls below this fraction of its threshold
+	rttHysteresis = 0.8
 )
 
+// NackMode is how NACK based repair is used, depending on RTT.
+type NackMode int
+
+const (
+	NackModeEnabled NackMode = iota
+	// a lost packet is NACKed once, another round trip is unlikely to be in time
+	NackModeTightened
+	// no NACKs are sent, losses are given up as they are pushed and left to FEC and key frames
+	NackModeDisabled
+)
+
+func (m NackMode) String() string {
+	switch m {
+	case NackModeEnabled:
+		return "ENABLED"
+	case NackModeTightened:
+		return "TIGHTENED"
+	case NackModeDisabled:
+		return "DISABLED"
+	default:
+		return fmt.Sprintf("%d", int(m))
+	}
+}
+
 type NackQueueParams struct {
 	DefaultRtt    uint32
 	MaxNacks      int
@@ -39,6 +70,15 @@ type NackQueueParams struct {
 	MaxInterval   time.Duration
 	BackoffFactor float64
 	MaxLifetime   time.Duration
+	// RTT in ms at and above which NACKs are tightened, 0 to never tighten.
+	// Around 250ms, a second try is unlikely to arrive in time for interactive use
+	TightenRtt uint32
+	// RTT in ms at and above which NACKs are disabled, 0 to never disable.
+	// Around 400ms, retransmissions arrive too late for interactive use
+	DisableRtt uint32
+	// number of given up sequence numbers kept for PopGivenUp, the oldest are dropped when full,
+	// 0 to not keep them
+	MaxGivenUp int
 }
 
 var NackQueueParamsDefault = NackQueueParams{
@@ -51,16 +91,31 @@ var NackQueueParamsDefault = NackQueueParams{
 	MaxLifetime:   maxLifetime,
 }
 
+type NackQueueStats struct {
+	Mode           NackMode
+	Rtt            uint32
+	NumModeChanges int
+	// losses given up without a NACK while disabled
+	NumDisabledLosses int
+}
+
 type NackQueue struct {
 	params     NackQueueParams
 	nackParams nackParams
 
 	nacks []*nack
 	rtt   uint32
+	mode  NackMode
+
+	// sequence numbers dropped without being repaired, see PopGivenUp, nil if not kept
+	givenUp *ring.Buffer[uint16]
+
+	numModeChanges    int
+	numDisabledLosses int
 }
 
 func NewNACKQueue(params NackQueueParams) *NackQueue {
-	return &NackQueue{
+	n := &NackQueue{
 		params: params,
 		nackParams: nackParams{
 			maxTries:      params.MaxTries,
@@ -72,6 +127,65 @@ func NewNACKQueue(params NackQueueParams) *NackQueue {
 		nacks: make([]*nack, 0, params.MaxNacks),
 		rtt:   params.DefaultRtt,
 	}
+	if params.MaxGivenUp > 0 {
+		n.givenUp = ring.NewBuffer[uint16](params.MaxGivenUp)
+	}
+	n.updateMode()
+	n.numModeChanges = 0
+	return n
+}
+
+// SetParams changes the parameters of the queue, for example when the reliability profile of the track changes,
+// see ReliabilityProfiles. Pending NACKs are kept and use the new parameters, the oldest are given up
+// if they do not fit.
+func (n *NackQueue) SetParams(params NackQueueParams) {
+	if n.rtt == n.params.DefaultRtt {
+		n.rtt = params.DefaultRtt
+	}
+	n.params = params
+	// pending nacks reference nackParams, update in place
+	n.nackParams = nackParams{
+		maxTries:      params.MaxTries,
+		minInterval:   params.MinInterval,
+		maxInterval:   params.MaxInterval,
+		backoffFactor: params.BackoffFactor,
+		maxLifeTime:   params.MaxLifetime,
+	}
+	n.setMaxGivenUp(params.MaxGivenUp)
+	n.updateMode()
+
+	if params.MaxNacks == cap(n.nacks) {
+		return
+	}
+	pending := n.nacks
+	if excess := len(pending) - params.MaxNacks; excess > 0 {
+		for _, nack := range pending[:excess] {
+			n.addGivenUp(nack.seqNum)
+		}
+		pending = pending[excess:]
+	}
+	n.nacks = append(make([]*nack, 0, params.MaxNacks), pending...)
+}
+
+func (n *NackQueue) setMaxGivenUp(maxGivenUp int) {
+	switch {
+	case maxGivenUp <= 0:
+		n.givenUp = nil
+	case n.givenUp == nil:
+		n.givenUp = ring.NewBuffer[uint16](maxGivenUp)
+	case n.givenUp.Cap() != maxGivenUp:
+		givenUp := ring.NewBuffer[uint16](maxGivenUp)
+		for i := 0; i < n.givenUp.Len(); i++ {
+			givenUp.Push(n.givenUp.At(i))
+		}
+		n.givenUp = givenUp
+	}
+}
+
+func (n *NackQueue) addGivenUp(sn uint16) {
+	if n.givenUp != nil {
+		n.givenUp.Push(sn)
+	}
 }
 
 func (n *NackQueue) SetRTT(rtt uint32) {
@@ -80,6 +194,60 @@ func (n *NackQueue) SetRTT(rtt uint32) {
 	} else {
 		n.rtt = rtt
 	}
+	n.updateMode()
+}
+
+// Mode returns how NACKs are used at the current RTT. A caller relying on FEC or key frames for repair can
+// check it after SetRTT, losses given up while disabled are returned by PopGivenUp.
+func (n *NackQueue) Mode() NackMode {
+	return n.mode
+}
+
+func (n *NackQueue) Stats() NackQueueStats {
+	return NackQueueStats{
+		Mode:              n.mode,
+		Rtt:               n.rtt,
+		NumModeChanges:    n.numModeChanges,
+		NumDisabledLosses: n.numDisabledLosses,
+	}
+}
+
+// updateMode re-evaluates the mode for the current RTT and applies it to pending NACKs
+func (n *NackQueue) updateMode() {
+	exceeds := func(threshold uint32, isActive bool) bool {
+		if threshold == 0 {
+			return false
+		}
+		if isActive {
+			return float64(n.rtt) >= float64(threshold)*rttHysteresis
+		}
+		return n.rtt >= threshold
+	}
+
+	mode := NackModeEnabled
+	switch {
+	case exceeds(n.params.DisableRtt, n.mode == NackModeDisabled):
+		mode = NackModeDisabled
+	case exceeds(n.params.TightenRtt, n.mode >= NackModeTightened):
+		mode = NackModeTightened
+	}
+	if mode != n.mode {
+		n.mode = mode
+		n.numModeChanges++
+	}
+
+	// pending nacks reference nackParams, update in place
+	n.nackParams.maxTries = n.params.MaxTries
+	if n.mode == NackModeTightened && n.nackParams.maxTries > tightenedMaxTries {
+		n.nackParams.maxTries = tightenedMaxTries
+	}
+	if n.mode == NackModeDisabled {
+		for _, nack := range n.nacks {
+			n.addGivenUp(nack.seqNum)
+		}
+		n.numDisabledLosses += len(n.nacks)
+		n.nacks = n.nacks[:0]
+	}
 }
 
 func (n *NackQueue) Remove(sn uint16) {
@@ -95,8 +263,15 @@ func (n *NackQueue) Remove(sn uint16) {
 }
 
 func (n *NackQueue) Push(sn uint16) {
+	if n.mode == NackModeDisabled {
+		n.addGivenUp(sn)
+		n.numDisabledLosses++
+		return
+	}
+
 	// if at capacity, pop the first one
 	if len(n.nacks) == cap(n.nacks) {
+		n.addGivenUp(n.nacks[0].seqNum)
 		copy(n.nacks[0:], n.nacks[1:])
 		n.nacks = n.nacks[:len(n.nacks)-1]
 	}
@@ -156,11 +331,25 @@ func (n *NackQueue) Pairs() ([]rtcp.NackPair, int) {
 
 	for _, sn := range snsToPurge {
 		n.Remove(sn)
+		n.addGivenUp(sn)
 	}
 
 	return nps, numSeqNumsNacked
 }
 
+// PopGivenUp returns sequence numbers that ran out of tries or lifetime, were evicted, or were lost while
+// NACKs are disabled, without the packet arriving since the last call. These losses need repair other than
+// retransmission, see LayerRepair. Only the last NackQueueParams.MaxGivenUp are kept between calls, none if
+// it is not set.
+func (n *NackQueue) PopGivenUp() []uint16 {
+	if n.givenUp == nil || n.givenUp.Len() == 0 {
+		return nil
+	}
+	givenUp := n.givenUp.AppendTo(make([]uint16, 0, n.givenUp.Len()))
+	n.givenUp.Clear()
+	return givenUp
+}
+
 // -----------------------------------------------------------------
 
 type nackParams struct {
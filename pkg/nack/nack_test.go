@@ -162,3 +162,59 @@ func Test_nackQueue_remove(t *testing.T) {
 		})
 	}
 }
+
+func Test_nackQueue_rttMode(t *testing.T) {
+	params := NackQueueParamsDefault
+	params.MaxGivenUp = 10
+	params.TightenRtt = 250
+	params.DisableRtt = 400
+	n := NewNACKQueue(params)
+	require.Equal(t, NackModeEnabled, n.Mode())
+
+	n.Push(1)
+	n.SetRTT(300)
+	require.Equal(t, NackModeTightened, n.Mode())
+	require.Equal(t, uint8(1), n.nacks[0].params.maxTries)
+
+	// pending NACKs are given up when disabled
+	n.SetRTT(450)
+	require.Equal(t, NackModeDisabled, n.Mode())
+	require.Empty(t, n.nacks)
+	n.Push(2)
+	require.Empty(t, n.nacks)
+	pairs, numSeqNumsNacked := n.Pairs()
+	require.Empty(t, pairs)
+	require.Zero(t, numSeqNumsNacked)
+	require.Equal(t, []uint16{1, 2}, n.PopGivenUp())
+
+	// hysteresis, stays disabled just below the threshold
+	n.SetRTT(350)
+	require.Equal(t, NackModeDisabled, n.Mode())
+	n.SetRTT(300)
+	require.Equal(t, NackModeTightened, n.Mode())
+	n.SetRTT(150)
+	require.Equal(t, NackModeEnabled, n.Mode())
+	n.Push(3)
+	require.Equal(t, NackQueueParamsDefault.MaxTries, n.nacks[0].params.maxTries)
+
+	require.Equal(t, NackQueueStats{
+		Mode:              NackModeEnabled,
+		Rtt:               150,
+		NumModeChanges:    4,
+		NumDisabledLosses: 2,
+	}, n.Stats())
+
+	// recording never disables
+	n.SetParams(ReliabilityParamsRecording.NackQueue)
+	n.SetRTT(1000)
+	require.Equal(t, NackModeEnabled, n.Mode())
+}
+
+func Test_nackQueue_rttModeOff(t *testing.T) {
+	// tightening and disabling are opt in
+	n := NewNACKQueue(NackQueueParamsDefault)
+	n.Push(1)
+	n.SetRTT(1000)
+	require.Equal(t, NackModeEnabled, n.Mode())
+	require.Equal(t, NackQueueParamsDefault.MaxTries, n.nacks[0].params.maxTries)
+}
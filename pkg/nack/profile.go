@@ -0,0 +1,164 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nack
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReliabilityProfile of a subscription, profiles are ordered by how much reliability they need.
+type ReliabilityProfile int
+
+const (
+	// latency sensitive subscribers, a late packet is as good as lost
+	ReliabilityProfileInteractive ReliabilityProfile = iota
+	// recording consumers, completeness matters more than latency
+	ReliabilityProfileRecording
+)
+
+func (r ReliabilityProfile) String() string {
+	switch r {
+	case ReliabilityProfileInteractive:
+		return "INTERACTIVE"
+	case ReliabilityProfileRecording:
+		return "RECORDING"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type ReliabilityParams struct {
+	// retransmission history kept for the subscriber, see RetentionCapacity
+	RetentionDuration time.Duration
+	// NACKs sent to the publisher on behalf of the subscriber
+	NackQueue NackQueueParams
+	// packets later than this are neither retransmitted nor waited for
+	MaxLatency time.Duration
+}
+
+var ReliabilityParamsInteractive = ReliabilityParams{
+	RetentionDuration: time.Second,
+	NackQueue:         NackQueueParamsDefault,
+	MaxLatency:        500 * time.Millisecond,
+}
+
+var ReliabilityParamsRecording = ReliabilityParams{
+	RetentionDuration: 10 * time.Second,
+	NackQueue: NackQueueParams{
+		DefaultRtt:    defaultRtt,
+		MaxNacks:      1000,
+		MaxTries:      20,
+		MinInterval:   minInterval,
+		MaxInterval:   2 * time.Second,
+		BackoffFactor: 1.5,
+		MaxLifetime:   maxLifetime,
+		// late retransmissions are still useful for completeness
+		TightenRtt: 0,
+		DisableRtt: 0,
+	},
+	MaxLatency: 10 * time.Second,
+}
+
+func (r ReliabilityProfile) Params() ReliabilityParams {
+	switch r {
+	case ReliabilityProfileRecording:
+		return ReliabilityParamsRecording
+	default:
+		return ReliabilityParamsInteractive
+	}
+}
+
+// RetentionCapacity returns the number of packets a retransmission buffer needs to cover the retention duration
+// at the given packet rate.
+func (p ReliabilityParams) RetentionCapacity(packetsPerSecond int) int {
+	return int(int64(packetsPerSecond) * int64(p.RetentionDuration) / int64(time.Second))
+}
+
+// ------------------------------------------------
+
+// ReliabilityProfiles tracks the profiles of the subscriptions to a published track. Interactive and recording
+// subscribers share the publisher, so the publisher side (retransmission buffer, NACK queue towards the publisher)
+// follows the most demanding profile, while each subscription applies its own MaxLatency.
+type ReliabilityProfiles struct {
+	lock        sync.Mutex
+	subscribers map[string]ReliabilityProfile
+	effective   ReliabilityProfile
+	onChange    func(profile ReliabilityProfile)
+}
+
+func NewReliabilityProfiles() *ReliabilityProfiles {
+	return &ReliabilityProfiles{
+		subscribers: make(map[string]ReliabilityProfile),
+	}
+}
+
+// OnChange sets a callback invoked when the effective profile changes.
+func (r *ReliabilityProfiles) OnChange(f func(profile ReliabilityProfile)) {
+	r.lock.Lock()
+	r.onChange = f
+	r.lock.Unlock()
+}
+
+func (r *ReliabilityProfiles) Set(subscriberID string, profile ReliabilityProfile) {
+	r.lock.Lock()
+	r.subscribers[subscriberID] = profile
+	changed := r.updateLocked()
+	effective := r.effective
+	onChange := r.onChange
+	r.lock.Unlock()
+
+	if changed && onChange != nil {
+		onChange(effective)
+	}
+}
+
+func (r *ReliabilityProfiles) Remove(subscriberID string) {
+	r.lock.Lock()
+	delete(r.subscribers, subscriberID)
+	changed := r.updateLocked()
+	effective := r.effective
+	onChange := r.onChange
+	r.lock.Unlock()
+
+	if changed && onChange != nil {
+		onChange(effective)
+	}
+}
+
+// Effective returns the most demanding profile among subscribers, interactive when there are none.
+func (r *ReliabilityProfiles) Effective() ReliabilityProfile {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.effective
+}
+
+// updateLocked re-evaluates the effective profile, returns true if it changed
+func (r *ReliabilityProfiles) updateLocked() bool {
+	effective := ReliabilityProfileInteractive
+	for _, profile := range r.subscribers {
+		if profile > effective {
+			effective = profile
+		}
+	}
+	if effective == r.effective {
+		return false
+	}
+
+	r.effective = effective
+	return true
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReliabilityProfiles(t *testing.T) {
+	r := NewReliabilityProfiles()
+	var changes []ReliabilityProfile
+	r.OnChange(func(profile ReliabilityProfile) {
+		changes = append(changes, profile)
+	})
+	require.Equal(t, ReliabilityProfileInteractive, r.Effective())
+
+	r.Set("a", ReliabilityProfileInteractive)
+	require.Empty(t, changes)
+
+	r.Set("egress", ReliabilityProfileRecording)
+	r.Set("b", ReliabilityProfileInteractive)
+	require.Equal(t, ReliabilityProfileRecording, r.Effective())
+	require.Equal(t, []ReliabilityProfile{ReliabilityProfileRecording}, changes)
+
+	r.Remove("egress")
+	require.Equal(t, ReliabilityProfileInteractive, r.Effective())
+	require.Equal(t, []ReliabilityProfile{ReliabilityProfileRecording, ReliabilityProfileInteractive}, changes)
+
+	// 10 seconds at 500 packets per second
+	require.Equal(t, 5000, ReliabilityProfileRecording.Params().RetentionCapacity(500))
+	require.Equal(t, 500, ReliabilityProfileInteractive.Params().RetentionCapacity(500))
+}
+
+func Test_nackQueue_setParams(t *testing.T) {
+	interactive := NackQueueParamsDefault
+	interactive.MaxGivenUp = 100
+	recording := ReliabilityParamsRecording.NackQueue
+	recording.MaxGivenUp = 100
+
+	n := NewNACKQueue(interactive)
+	for sn := uint16(1); sn <= 100; sn++ {
+		n.Push(sn)
+	}
+
+	// deeper queue keeps pending nacks and holds more
+	n.SetParams(recording)
+	for sn := uint16(101); sn <= 150; sn++ {
+		n.Push(sn)
+	}
+	require.Len(t, n.nacks, 150)
+	require.Empty(t, n.PopGivenUp())
+	require.Equal(t, uint8(20), n.nacks[0].params.maxTries)
+
+	// back to interactive, the oldest are given up
+	n.SetParams(interactive)
+	require.Len(t, n.nacks, 100)
+	require.Equal(t, uint16(51), n.nacks[0].seqNum)
+	givenUp := n.PopGivenUp()
+	require.Len(t, givenUp, 50)
+	require.Equal(t, uint16(1), givenUp[0])
+
+	n.Push(151)
+	require.Len(t, n.nacks, 100)
+	require.Equal(t, []uint16{51}, n.PopGivenUp())
+	require.Equal(t, uint8(maxTries), n.nacks[0].params.maxTries)
+	require.Equal(t, maxInterval, n.nacks[0].params.maxInterval)
+}
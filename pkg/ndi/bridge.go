@@ -0,0 +1,240 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ndi exposes received audio tracks as NDI sources, so that broadcast software on the LAN can consume
+// conference audio directly. Packets are decoded with the mixer.Decoder of the track. Video is not bridged, the
+// module forwards RTP without assembling or decoding video frames.
+//
+// NDI needs the NDI SDK through cgo, build with the ndi tag and the SDK headers and library installed; other builds
+// return ErrNDINotSupported.
+package ndi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/mixer"
+)
+
+const (
+	// longest packet decoded, as for the mixer
+	maxPacketDuration = 120 * time.Millisecond
+)
+
+var (
+	ErrNDINotSupported = errors.New("NDI is not supported, build with cgo and the ndi tag against the NDI SDK")
+	ErrSourceExists    = errors.New("NDI source exists")
+	ErrSourceClosed    = errors.New("NDI source closed")
+	ErrInvalidAudio    = errors.New("invalid decoded audio")
+)
+
+// sender is an NDI source on the network.
+type sender interface {
+	// planar is the audio as NDI expects it, a float plane per channel
+	SendAudio(sampleRate int, channels int, planar []float32) error
+	Close()
+}
+
+type BridgeParams struct {
+	// prefix of the source names, e.g. "LiveKit", sources are named "<prefix> <name>"
+	NamePrefix string
+	// NDI groups the sources are in, comma separated, empty for the default group
+	Groups string
+}
+
+type SourceParams struct {
+	// sample rate and channel count of the PCM output by the decoder
+	SampleRate int
+	Channels   int
+}
+
+var SourceParamsDefault = SourceParams{
+	SampleRate: 48000,
+	Channels:   1,
+}
+
+type SourceStats struct {
+	NumPackets      uint64
+	NumDecodeErrors uint64
+	NumSendErrors   uint64
+}
+
+// Bridge exposes audio tracks as NDI sources, one per track.
+type Bridge struct {
+	params    BridgeParams
+	newSender func(name string, groups string) (sender, error)
+
+	lock    sync.Mutex
+	sources map[string]*Source
+}
+
+// NewBridge initializes the NDI SDK, it returns ErrNDINotSupported in builds without it.
+func NewBridge(params BridgeParams) (*Bridge, error) {
+	if err := initializeNDI(); err != nil {
+		return nil, err
+	}
+	return newBridge(params, newNDISender), nil
+}
+
+func newBridge(params BridgeParams, newSender func(name string, groups string) (sender, error)) *Bridge {
+	return &Bridge{
+		params:    params,
+		newSender: newSender,
+		sources:   make(map[string]*Source),
+	}
+}
+
+// AddSource announces a source named name on the LAN for the audio track trackID, its packets are decoded
+// with decoder.
+func (b *Bridge) AddSource(trackID string, name string, decoder mixer.Decoder, params SourceParams) (*Source, error) {
+	if params.SampleRate <= 0 {
+		params.SampleRate = SourceParamsDefault.SampleRate
+	}
+	if params.Channels <= 0 {
+		params.Channels = SourceParamsDefault.Channels
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.sources[trackID]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrSourceExists, trackID)
+	}
+	if b.params.NamePrefix != "" {
+		name = b.params.NamePrefix + " " + name
+	}
+	snd, err := b.newSender(name, b.params.Groups)
+	if err != nil {
+		return nil, err
+	}
+	s := &Source{
+		name:      name,
+		params:    params,
+		decoder:   decoder,
+		sender:    snd,
+		decodeBuf: make([]int16, int(int64(params.SampleRate)*int64(maxPacketDuration)/int64(time.Second))*params.Channels),
+	}
+	b.sources[trackID] = s
+	return s, nil
+}
+
+// RemoveSource withdraws the source of a track, for example when it is unpublished.
+func (b *Bridge) RemoveSource(trackID string) {
+	b.lock.Lock()
+	s := b.sources[trackID]
+	delete(b.sources, trackID)
+	b.lock.Unlock()
+
+	if s != nil {
+		s.close()
+	}
+}
+
+func (b *Bridge) Source(trackID string) *Source {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.sources[trackID]
+}
+
+// Close withdraws all sources.
+func (b *Bridge) Close() {
+	b.lock.Lock()
+	sources := b.sources
+	b.sources = make(map[string]*Source)
+	b.lock.Unlock()
+
+	for _, s := range sources {
+		s.close()
+	}
+}
+
+// ------------------------------------------------
+
+// Source is the NDI source of an audio track.
+type Source struct {
+	name    string
+	params  SourceParams
+	decoder mixer.Decoder
+
+	lock      sync.Mutex
+	sender    sender
+	decodeBuf []int16
+	planar    []float32
+	isClosed  bool
+	stats     SourceStats
+}
+
+func (s *Source) Name() string {
+	return s.name
+}
+
+// Push decodes a packet of the track and sends the audio, NDI timestamps it on arrival.
+func (s *Source) Push(payload []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.isClosed {
+		return ErrSourceClosed
+	}
+	n, err := s.decoder.Decode(payload, s.decodeBuf)
+	if err == nil && n%s.params.Channels != 0 {
+		err = fmt.Errorf("%w: %d samples for %d channels", ErrInvalidAudio, n, s.params.Channels)
+	}
+	if err != nil {
+		s.stats.NumDecodeErrors++
+		return err
+	}
+	s.planar = deinterleave(s.decodeBuf[:n], s.params.Channels, s.planar)
+	if err := s.sender.SendAudio(s.params.SampleRate, s.params.Channels, s.planar); err != nil {
+		s.stats.NumSendErrors++
+		return err
+	}
+	s.stats.NumPackets++
+	return nil
+}
+
+func (s *Source) Stats() SourceStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stats
+}
+
+func (s *Source) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.isClosed {
+		return
+	}
+	s.isClosed = true
+	s.sender.Close()
+}
+
+// deinterleave converts interleaved 16 bit PCM to a float plane per channel, reusing planar if large enough.
+func deinterleave(pcm []int16, channels int, planar []float32) []float32 {
+	if cap(planar) < len(pcm) {
+		planar = make([]float32, len(pcm))
+	}
+	planar = planar[:len(pcm)]
+
+	numSamples := len(pcm) / channels
+	for i, sample := range pcm {
+		planar[(i%channels)*numSamples+i/channels] = float32(sample) / 32768
+	}
+	return planar
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build ndi && cgo
+// +build ndi,cgo
+
+package ndi
+
+/*
+#cgo LDFLAGS: -lndi
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdlib.h>
+#include <string.h>
+#include <Processing.NDI.Lib.h>
+
+static NDIlib_send_instance_t ndi_send_create(const char* name, const char* groups) {
+	NDIlib_send_create_t create;
+	memset(&create, 0, sizeof(create));
+	create.p_ndi_name = name;
+	create.p_groups = groups;
+	// audio is sent as the decoder outputs it, not clocked by the SDK
+	create.clock_video = false;
+	create.clock_audio = false;
+	return NDIlib_send_create(&create);
+}
+
+static void ndi_send_audio(NDIlib_send_instance_t send, int sample_rate, int channels, int samples, float* data) {
+	NDIlib_audio_frame_v2_t frame;
+	memset(&frame, 0, sizeof(frame));
+	frame.sample_rate = sample_rate;
+	frame.no_channels = channels;
+	frame.no_samples = samples;
+	frame.timecode = NDIlib_send_timecode_synthesize;
+	frame.p_data = data;
+	frame.channel_stride_in_bytes = samples * sizeof(float);
+	NDIlib_send_send_audio_v2(send, &frame);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+var (
+	initializeOnce sync.Once
+	initializeErr  error
+)
+
+func initializeNDI() error {
+	initializeOnce.Do(func() {
+		if !C.NDIlib_initialize() {
+			initializeErr = errors.New("NDI SDK failed to initialize, CPU not supported")
+		}
+	})
+	return initializeErr
+}
+
+type ndiSender struct {
+	send C.NDIlib_send_instance_t
+}
+
+func newNDISender(name string, groups string) (sender, error) {
+	cName := C.CString(name)
+	defer C.free(unsafe.Pointer(cName))
+	var cGroups *C.char
+	if groups != "" {
+		cGroups = C.CString(groups)
+		defer C.free(unsafe.Pointer(cGroups))
+	}
+
+	send := C.ndi_send_create(cName, cGroups)
+	if send == nil {
+		return nil, errors.New("NDI source could not be created")
+	}
+	return &ndiSender{send: send}, nil
+}
+
+// SendAudio sends synchronously, the SDK is done with the data when it returns.
+func (s *ndiSender) SendAudio(sampleRate int, channels int, planar []float32) error {
+	if len(planar) == 0 {
+		return nil
+	}
+	C.ndi_send_audio(
+		s.send,
+		C.int(sampleRate),
+		C.int(channels),
+		C.int(len(planar)/channels),
+		(*C.float)(unsafe.Pointer(&planar[0])),
+	)
+	return nil
+}
+
+func (s *ndiSender) Close() {
+	C.NDIlib_send_destroy(s.send)
+}
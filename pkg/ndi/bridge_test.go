@@ -0,0 +1,112 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ndi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSender struct {
+	name       string
+	groups     string
+	sampleRate int
+	channels   int
+	audio      [][]float32
+	isClosed   bool
+}
+
+func (s *testSender) SendAudio(sampleRate int, channels int, planar []float32) error {
+	s.sampleRate = sampleRate
+	s.channels = channels
+	s.audio = append(s.audio, append([]float32{}, planar...))
+	return nil
+}
+
+func (s *testSender) Close() {
+	s.isClosed = true
+}
+
+// testDecoder decodes packets of PCM, a byte per sample scaled to 16 bits.
+type testDecoder struct{}
+
+func (d *testDecoder) Decode(payload []byte, pcm []int16) (int, error) {
+	if len(payload) == 0 {
+		return 0, errors.New("empty packet")
+	}
+	for i, b := range payload {
+		pcm[i] = int16(int8(b)) << 8
+	}
+	return len(payload), nil
+}
+
+func TestBridge(t *testing.T) {
+	senders := make(map[string]*testSender)
+	b := newBridge(BridgeParams{NamePrefix: "LiveKit", Groups: "studio"}, func(name string, groups string) (sender, error) {
+		s := &testSender{name: name, groups: groups}
+		senders[name] = s
+		return s, nil
+	})
+
+	s, err := b.AddSource("TR_1", "alice mic", &testDecoder{}, SourceParams{Channels: 2})
+	require.NoError(t, err)
+	require.Equal(t, "LiveKit alice mic", s.Name())
+	require.Equal(t, "studio", senders["LiveKit alice mic"].groups)
+	_, err = b.AddSource("TR_1", "alice mic", &testDecoder{}, SourceParams{})
+	require.ErrorIs(t, err, ErrSourceExists)
+	require.Equal(t, s, b.Source("TR_1"))
+
+	// decoded, deinterleaved to a plane per channel, at the default sample rate
+	require.NoError(t, s.Push([]byte{0x40, 0xc0, 0x00, 0x7f}))
+	snd := senders["LiveKit alice mic"]
+	require.Equal(t, 48000, snd.sampleRate)
+	require.Equal(t, 2, snd.channels)
+	require.Equal(t, []float32{0.5, 0, -0.5, 32512.0 / 32768}, snd.audio[0])
+	require.Error(t, s.Push(nil))
+	require.ErrorIs(t, s.Push([]byte{1, 2, 3}), ErrInvalidAudio)
+
+	stats := s.Stats()
+	require.Equal(t, uint64(1), stats.NumPackets)
+	require.Equal(t, uint64(2), stats.NumDecodeErrors)
+
+	b.RemoveSource("TR_1")
+	require.True(t, snd.isClosed)
+	require.Nil(t, b.Source("TR_1"))
+	require.ErrorIs(t, s.Push([]byte{1, 2}), ErrSourceClosed)
+
+	// sources are withdrawn on close
+	s2, err := b.AddSource("TR_2", "bob mic", &testDecoder{}, SourceParams{})
+	require.NoError(t, err)
+	b.Close()
+	require.True(t, senders["LiveKit bob mic"].isClosed)
+	require.ErrorIs(t, s2.Push([]byte{1}), ErrSourceClosed)
+
+	sendErr := errors.New("no SDK")
+	b = newBridge(BridgeParams{}, func(name string, groups string) (sender, error) {
+		return nil, sendErr
+	})
+	_, err = b.AddSource("TR_3", "carol", &testDecoder{}, SourceParams{})
+	require.ErrorIs(t, err, sendErr)
+}
+
+func TestNewBridgeWithoutSDK(t *testing.T) {
+	if initializeNDI() == nil {
+		t.Skip("built with the NDI SDK")
+	}
+	_, err := NewBridge(BridgeParams{})
+	require.ErrorIs(t, err, ErrNDINotSupported)
+}
@@ -17,21 +17,32 @@ package pacer
 import (
 	"errors"
 	"io"
+	"sync/atomic"
 	"time"
 
 	"github.com/livekit/protocol/logger"
 	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/logsampler"
 )
 
 type Base struct {
 	logger logger.Logger
 
 	packetTime *PacketTime
+
+	localCongestionDetector   atomic.Pointer[LocalCongestionDetector]
+	priorityInversionWatchdog atomic.Pointer[PriorityInversionWatchdog]
+	numSentNonAudio           atomic.Uint64
+	numSentRetransmissions    atomic.Uint64
+	// snapshot of the queue of the pacer for diagnostics, nil for pacers without a queue
+	queueSnapshot func() QueueSnapshot
 }
 
 func NewBase(logger logger.Logger) *Base {
 	return &Base{
-		logger:     logger,
+		// write errors repeat for every packet
+		logger:     logsampler.NewLogger(logger, logsampler.Default()),
 		packetTime: NewPacketTime(),
 	}
 }
@@ -42,12 +53,30 @@ func (b *Base) SetInterval(_interval time.Duration) {
 func (b *Base) SetBitrate(_bitrate int) {
 }
 
+// SetLocalCongestionDetector sets the detector of pacer queue build-up and socket send blocking, set before Start.
+func (b *Base) SetLocalCongestionDetector(d *LocalCongestionDetector) {
+	b.localCongestionDetector.Store(d)
+}
+
+// SetPriorityInversionWatchdog sets the watchdog of audio packets waiting behind video, set before Start.
+func (b *Base) SetPriorityInversionWatchdog(w *PriorityInversionWatchdog) {
+	b.priorityInversionWatchdog.Store(w)
+}
+
+// markEnqueued stamps a packet entering the pacer queue to measure its queue delay
+func (b *Base) markEnqueued(p *Packet) {
+	watchdog := b.priorityInversionWatchdog.Load()
+	if b.localCongestionDetector.Load() != nil || watchdog != nil {
+		p.enqueuedAt = time.Now()
+	}
+	if watchdog != nil && p.IsAudio {
+		p.numSentNonAudioAtEnqueue = b.numSentNonAudio.Load()
+		p.numSentRetransmissionsAtEnqueue = b.numSentRetransmissions.Load()
+	}
+}
+
 func (b *Base) SendPacket(p *Packet) (int, error) {
-	defer func() {
-		if p.Pool != nil && p.PoolEntity != nil {
-			p.Pool.Put(p.PoolEntity)
-		}
-	}()
+	defer p.release()
 
 	_, err := b.writeRTPHeaderExtensions(p)
 	if err != nil {
@@ -55,8 +84,38 @@ func (b *Base) SendPacket(p *Packet) (int, error) {
 		return 0, err
 	}
 
+	detector := b.localCongestionDetector.Load()
+	var writeStart time.Time
+	if detector != nil {
+		writeStart = time.Now()
+		if !p.enqueuedAt.IsZero() {
+			detector.OnQueueDelay(writeStart.Sub(p.enqueuedAt))
+		}
+	}
+	if watchdog := b.priorityInversionWatchdog.Load(); watchdog != nil {
+		if !p.IsAudio {
+			b.numSentNonAudio.Add(1)
+			if p.IsRetransmission {
+				b.numSentRetransmissions.Add(1)
+			}
+		} else if !p.enqueuedAt.IsZero() {
+			// non audio packets sent while the audio packet was queued, enqueued before it or, by a pacer serving
+			// another queue first, after it. Retransmissions are counted apart to tell preemption from a video burst
+			watchdog.onAudioSent(
+				p,
+				time.Since(p.enqueuedAt),
+				int(b.numSentNonAudio.Load()-p.numSentNonAudioAtEnqueue),
+				int(b.numSentRetransmissions.Load()-p.numSentRetransmissionsAtEnqueue),
+				b.queueSnapshot,
+			)
+		}
+	}
+
 	var written int
 	written, err = p.Writer(p.Header, p.Payload)
+	if detector != nil {
+		detector.OnWrite(time.Since(writeStart), err)
+	}
 	if err != nil {
 		if !errors.Is(err, io.ErrClosedPipe) {
 			b.logger.Errorw("write rtp packet failed", err)
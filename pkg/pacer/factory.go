@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/admission"
+	"github.com/livekit/mediatransportutil/pkg/loglevel"
 )
 
 type PacerType int
@@ -21,12 +24,20 @@ type pacerFactoryParams struct {
 	MaxLatency   time.Duration
 	PacerType    PacerType
 	Logger       logger.Logger
+
+	LocalCongestionDetector *LocalCongestionDetector
+	RetransmissionLimiter   RetransmissionLimiterParams
+	AdmissionController     admission.Controller
+
+	PriorityInversionWatchdog *PriorityInversionWatchdog
 }
 
 var defaultPacerParams = pacerFactoryParams{
 	SendInterval: 5 * time.Millisecond,
 	Bitrate:      5000000,
 	MaxLatency:   2 * time.Second,
+
+	RetransmissionLimiter: RetransmissionLimiterParamsDefault,
 }
 
 type PacerFactoryOpt func(params *pacerFactoryParams)
@@ -55,6 +66,36 @@ func Withlogger(logger logger.Logger) PacerFactoryOpt {
 	}
 }
 
+// WithLocalCongestionDetector sets a detector on the pacers created, shared by all of them.
+func WithLocalCongestionDetector(detector *LocalCongestionDetector) PacerFactoryOpt {
+	return func(params *pacerFactoryParams) {
+		params.LocalCongestionDetector = detector
+	}
+}
+
+// WithMaxRetransmissionShare sets the share of the bitrate of a leaky bucket pacer retransmissions may use,
+// 0 for no cap.
+func WithMaxRetransmissionShare(maxShare float64) PacerFactoryOpt {
+	return func(params *pacerFactoryParams) {
+		params.RetransmissionLimiter.MaxShare = maxShare
+	}
+}
+
+// WithPriorityInversionWatchdog sets a watchdog on the pacers created, shared by all of them.
+func WithPriorityInversionWatchdog(watchdog *PriorityInversionWatchdog) PacerFactoryOpt {
+	return func(params *pacerFactoryParams) {
+		params.PriorityInversionWatchdog = watchdog
+	}
+}
+
+// WithAdmissionController sets a controller consulted before creating a pacer, with the pacer bitrate,
+// NewPacer returns its error if the connection is rejected.
+func WithAdmissionController(controller admission.Controller) PacerFactoryOpt {
+	return func(params *pacerFactoryParams) {
+		params.AdmissionController = controller
+	}
+}
+
 type PacerFactory struct {
 	params *pacerFactoryParams
 }
@@ -62,7 +103,7 @@ type PacerFactory struct {
 func NewPacerFactory(pacerType PacerType, opts ...PacerFactoryOpt) Factory {
 	params := defaultPacerParams
 	params.PacerType = pacerType
-	params.Logger = logger.GetLogger()
+	params.Logger = loglevel.Default().Logger(loglevel.SubsystemPacer, logger.GetLogger())
 	for _, o := range opts {
 		o(&params)
 	}
@@ -72,13 +113,29 @@ func NewPacerFactory(pacerType PacerType, opts ...PacerFactoryOpt) Factory {
 }
 
 func (f *PacerFactory) NewPacer() (Pacer, error) {
+	if f.params.AdmissionController != nil {
+		if err := f.params.AdmissionController.Admit(admission.Request{Bitrate: f.params.Bitrate}); err != nil {
+			return nil, err
+		}
+	}
+
 	switch f.params.PacerType {
 	case PassThroughPacer:
-		return NewPassThrough(f.params.Logger), nil
+		p := NewPassThrough(f.params.Logger)
+		p.SetLocalCongestionDetector(f.params.LocalCongestionDetector)
+		p.SetPriorityInversionWatchdog(f.params.PriorityInversionWatchdog)
+		return p, nil
 	case NoQueuePacer:
-		return NewNoQueue(f.params.Logger), nil
+		p := NewNoQueue(f.params.Logger)
+		p.SetLocalCongestionDetector(f.params.LocalCongestionDetector)
+		p.SetPriorityInversionWatchdog(f.params.PriorityInversionWatchdog)
+		return p, nil
 	case LeakyBucketPacer:
-		return NewPacerLeakyBucket(f.params.SendInterval, f.params.Bitrate, f.params.MaxLatency, f.params.Logger), nil
+		p := NewPacerLeakyBucket(f.params.SendInterval, f.params.Bitrate, f.params.MaxLatency, f.params.Logger)
+		p.SetLocalCongestionDetector(f.params.LocalCongestionDetector)
+		p.SetPriorityInversionWatchdog(f.params.PriorityInversionWatchdog)
+		p.SetRetransmissionLimiter(f.params.RetransmissionLimiter)
+		return p, nil
 	default:
 		return nil, fmt.Errorf("unknown pacer type: %v", f.params.PacerType)
 	}
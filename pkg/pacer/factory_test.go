@@ -0,0 +1,44 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/admission"
+)
+
+func TestPacerFactoryAdmission(t *testing.T) {
+	admit := true
+	var requests []admission.Request
+	f := NewPacerFactory(NoQueuePacer, WithBitrate(2_000_000), WithAdmissionController(admission.ControllerFunc(func(req admission.Request) error {
+		requests = append(requests, req)
+		if admit {
+			return nil
+		}
+		return admission.ErrOverloaded
+	})))
+
+	p, err := f.NewPacer()
+	require.NoError(t, err)
+	p.Stop()
+	require.Equal(t, []admission.Request{{Bitrate: 2_000_000}}, requests)
+
+	admit = false
+	_, err = f.NewPacer()
+	require.ErrorIs(t, err, admission.ErrOverloaded)
+}
@@ -19,8 +19,9 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/gammazero/deque"
 	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
 )
 
 const (
@@ -35,8 +36,13 @@ type PacerLeakyBucket struct {
 	interval   time.Duration
 	maxLatency time.Duration
 
-	packets    deque.Deque[*Packet]
-	queueBytes int
+	// audio is sent first, then retransmissions, then other packets
+	audioPackets ring.Deque[*Packet]
+	packets      ring.Deque[*Packet]
+	queueBytes   int
+
+	rtxLimiter *RetransmissionLimiter
+	rtxPackets ring.Deque[*Packet]
 
 	isStopped atomic.Bool
 }
@@ -49,10 +55,62 @@ func NewPacerLeakyBucket(interval time.Duration, bitrate int, maxLatency time.Du
 		maxLatency: maxLatency,
 		logger:     logger,
 	}
-	p.packets.SetMinCapacity(9)
+	p.audioPackets.Grow(1 << 6)
+	p.packets.Grow(1 << 9)
+	p.rtxPackets.Grow(1 << 6)
+	p.Base.queueSnapshot = p.snapshotQueue
 	return p
 }
 
+// SetRetransmissionLimiter caps retransmissions to a share of the pacer bitrate, set before Start.
+func (p *PacerLeakyBucket) SetRetransmissionLimiter(params RetransmissionLimiterParams) {
+	p.lock.Lock()
+	p.rtxLimiter = NewRetransmissionLimiter(params, p.bitrate)
+	p.lock.Unlock()
+}
+
+func (p *PacerLeakyBucket) RetransmissionStats() RetransmissionLimiterStats {
+	p.lock.RLock()
+	rtxLimiter := p.rtxLimiter
+	p.lock.RUnlock()
+
+	if rtxLimiter == nil {
+		return RetransmissionLimiterStats{}
+	}
+	return rtxLimiter.Stats()
+}
+
+type LeakyBucketQueueStats struct {
+	NumPackets         int
+	NumRetransmissions int
+	NumBytes           int
+	Bitrate            int
+}
+
+// QueueStats returns a snapshot of the queued packets.
+func (p *PacerLeakyBucket) QueueStats() LeakyBucketQueueStats {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return LeakyBucketQueueStats{
+		NumPackets:         p.audioPackets.Len() + p.packets.Len(),
+		NumRetransmissions: p.rtxPackets.Len(),
+		NumBytes:           p.queueBytes,
+		Bitrate:            p.bitrate,
+	}
+}
+
+func (p *PacerLeakyBucket) snapshotQueue() QueueSnapshot {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	snapshot := snapshotQueues(time.Now(), &p.audioPackets, &p.rtxPackets, &p.packets)
+	snapshot.NumRetransmissions = p.rtxPackets.Len()
+	snapshot.NumBytes = p.queueBytes
+	snapshot.Bitrate = p.bitrate
+	return snapshot
+}
+
 func (p *PacerLeakyBucket) Start() {
 	if !p.isStopped.Load() {
 		go p.sendWorker()
@@ -65,9 +123,22 @@ func (p *PacerLeakyBucket) Enqueue(pkt *Packet) {
 	}
 
 	pktSize := pkt.getPktSize()
+	p.Base.markEnqueued(pkt)
 
 	p.lock.Lock()
-	p.packets.PushBack(pkt)
+	if pkt.IsRetransmission && p.rtxLimiter != nil && !p.rtxLimiter.Allow(pktSize) {
+		p.lock.Unlock()
+		pkt.release()
+		return
+	}
+	switch {
+	case pkt.IsAudio:
+		p.audioPackets.PushBack(pkt)
+	case pkt.IsRetransmission:
+		p.rtxPackets.PushBack(pkt)
+	default:
+		p.packets.PushBack(pkt)
+	}
 	p.queueBytes += pktSize
 	p.lock.Unlock()
 }
@@ -79,6 +150,9 @@ func (p *PacerLeakyBucket) Stop() {
 func (p *PacerLeakyBucket) SetBitrate(bitrate int) {
 	p.lock.Lock()
 	p.bitrate = bitrate
+	if p.rtxLimiter != nil {
+		p.rtxLimiter.SetBitrate(bitrate)
+	}
 	p.lock.Unlock()
 }
 
@@ -123,13 +197,13 @@ func (p *PacerLeakyBucket) sendWorker() {
 
 		for !p.isStopped.Load() {
 			p.lock.Lock()
-			if p.packets.Len() == 0 {
+			pkt := p.popLocked()
+			if pkt == nil {
 				p.lock.Unlock()
 				// allow overshoot in next interval with shortage in this interval
 				overage = -toSendBytes
 				break
 			}
-			pkt := p.packets.PopFront()
 			pktSize := pkt.getPktSize()
 			p.queueBytes -= pktSize
 			p.lock.Unlock()
@@ -146,4 +220,19 @@ func (p *PacerLeakyBucket) sendWorker() {
 	}
 }
 
+// popLocked returns the next packet to send, audio ahead of retransmissions so that a loss storm does not delay it,
+// nil if the queues are empty
+func (p *PacerLeakyBucket) popLocked() *Packet {
+	switch {
+	case p.audioPackets.Len() != 0:
+		return p.audioPackets.PopFront()
+	case p.rtxPackets.Len() != 0:
+		return p.rtxPackets.PopFront()
+	case p.packets.Len() != 0:
+		return p.packets.PopFront()
+	default:
+		return nil
+	}
+}
+
 // ------------------------------------------------
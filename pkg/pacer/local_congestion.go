@@ -0,0 +1,205 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type LocalCongestionDetectorParams struct {
+	// time packets wait in the pacer queue, above which the queue is considered built up
+	QueueDelayThreshold time.Duration
+	// a write taking this long blocked on a full socket send buffer, the Go runtime waits
+	// for the socket to become writable instead of returning EAGAIN
+	WriteDelayThreshold time.Duration
+	// blocked writes in a window for the socket to be considered blocked
+	MinBlockedWrites int
+	// evaluation window, the signal changes at most once per window
+	Window time.Duration
+}
+
+var LocalCongestionDetectorParamsDefault = LocalCongestionDetectorParams{
+	QueueDelayThreshold: 100 * time.Millisecond,
+	WriteDelayThreshold: 5 * time.Millisecond,
+	MinBlockedWrites:    3,
+	Window:              500 * time.Millisecond,
+}
+
+// LocalCongestionSignal reports congestion on the sending host, as opposed to the network.
+// Delay caused by it shows up in congestion control feedback like network queuing, a bandwidth
+// allocator seeing the signal should not attribute the resulting estimate drop to the path.
+type LocalCongestionSignal struct {
+	// packets wait in the pacer, the pacer cannot send as fast as packets are enqueued
+	IsQueueBuildUp bool
+	// writes block or fail on a full socket send buffer, CPU or NIC starvation
+	IsSocketBlocked bool
+
+	// of the last window
+	MaxQueueDelay    time.Duration
+	MaxWriteDelay    time.Duration
+	NumWrites        int
+	NumBlockedWrites int
+}
+
+func (s LocalCongestionSignal) IsCongested() bool {
+	return s.IsQueueBuildUp || s.IsSocketBlocked
+}
+
+type LocalCongestionDetectorStats struct {
+	NumWindows             int
+	NumQueueBuildUpWindows int
+	NumBlockedWindows      int
+	NumBlockedWrites       int
+}
+
+// LocalCongestionDetector watches the pacer queue delay and socket write latency of the pacers it
+// is set on. Windows are evaluated as packets are sent, without traffic the signal is not updated.
+type LocalCongestionDetector struct {
+	params LocalCongestionDetectorParams
+
+	lock        sync.Mutex
+	windowStart time.Time
+	window      LocalCongestionSignal
+	signal      LocalCongestionSignal
+	stats       LocalCongestionDetectorStats
+	onSignal    func(signal LocalCongestionSignal)
+}
+
+func NewLocalCongestionDetector(params LocalCongestionDetectorParams) *LocalCongestionDetector {
+	if params.QueueDelayThreshold == 0 {
+		params.QueueDelayThreshold = LocalCongestionDetectorParamsDefault.QueueDelayThreshold
+	}
+	if params.WriteDelayThreshold == 0 {
+		params.WriteDelayThreshold = LocalCongestionDetectorParamsDefault.WriteDelayThreshold
+	}
+	if params.MinBlockedWrites == 0 {
+		params.MinBlockedWrites = LocalCongestionDetectorParamsDefault.MinBlockedWrites
+	}
+	if params.Window == 0 {
+		params.Window = LocalCongestionDetectorParamsDefault.Window
+	}
+	return &LocalCongestionDetector{
+		params: params,
+	}
+}
+
+// OnSignal sets the callback called when local congestion starts or ends.
+func (d *LocalCongestionDetector) OnSignal(f func(signal LocalCongestionSignal)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.onSignal = f
+}
+
+func (d *LocalCongestionDetector) Signal() LocalCongestionSignal {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.signal
+}
+
+func (d *LocalCongestionDetector) Stats() LocalCongestionDetectorStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.stats
+}
+
+// OnQueueDelay records the time a packet waited in the pacer queue.
+func (d *LocalCongestionDetector) OnQueueDelay(delay time.Duration) {
+	d.onQueueDelay(delay, time.Now())
+}
+
+func (d *LocalCongestionDetector) onQueueDelay(delay time.Duration, now time.Time) {
+	d.lock.Lock()
+	onSignal, signal, changed := d.maybeEndWindowLocked(now)
+	if delay > d.window.MaxQueueDelay {
+		d.window.MaxQueueDelay = delay
+	}
+	d.lock.Unlock()
+
+	if changed && onSignal != nil {
+		onSignal(signal)
+	}
+}
+
+// OnWrite records the duration and result of a packet write.
+func (d *LocalCongestionDetector) OnWrite(duration time.Duration, err error) {
+	d.onWrite(duration, err, time.Now())
+}
+
+func (d *LocalCongestionDetector) onWrite(duration time.Duration, err error, now time.Time) {
+	d.lock.Lock()
+	onSignal, signal, changed := d.maybeEndWindowLocked(now)
+	d.window.NumWrites++
+	if duration > d.window.MaxWriteDelay {
+		d.window.MaxWriteDelay = duration
+	}
+	if duration >= d.params.WriteDelayThreshold || isSendBufferFull(err) {
+		d.window.NumBlockedWrites++
+		d.stats.NumBlockedWrites++
+	}
+	d.lock.Unlock()
+
+	if changed && onSignal != nil {
+		onSignal(signal)
+	}
+}
+
+// maybeEndWindowLocked returns the callback and the signal to call it with if the signal changed
+func (d *LocalCongestionDetector) maybeEndWindowLocked(now time.Time) (func(LocalCongestionSignal), LocalCongestionSignal, bool) {
+	if d.windowStart.IsZero() {
+		d.windowStart = now
+	}
+	if now.Sub(d.windowStart) < d.params.Window {
+		return nil, LocalCongestionSignal{}, false
+	}
+
+	signal := d.window
+	signal.IsQueueBuildUp = signal.MaxQueueDelay >= d.params.QueueDelayThreshold
+	signal.IsSocketBlocked = signal.NumBlockedWrites >= d.params.MinBlockedWrites
+
+	d.stats.NumWindows++
+	if signal.IsQueueBuildUp {
+		d.stats.NumQueueBuildUpWindows++
+	}
+	if signal.IsSocketBlocked {
+		d.stats.NumBlockedWindows++
+	}
+
+	changed := signal.IsQueueBuildUp != d.signal.IsQueueBuildUp || signal.IsSocketBlocked != d.signal.IsSocketBlocked
+	d.signal = signal
+	d.window = LocalCongestionSignal{}
+	d.windowStart = now
+	return d.onSignal, signal, changed
+}
+
+// isSendBufferFull returns true for errors of writes on a socket without send buffer space,
+// returned by non-blocking writes and writes with a deadline
+func isSendBufferFull(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOBUFS) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
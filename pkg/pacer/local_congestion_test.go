@@ -0,0 +1,87 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCongestionDetector(t *testing.T) {
+	d := NewLocalCongestionDetector(LocalCongestionDetectorParams{})
+	var signals []LocalCongestionSignal
+	d.OnSignal(func(signal LocalCongestionSignal) {
+		signals = append(signals, signal)
+	})
+
+	now := time.Now()
+	step := func(queueDelay time.Duration, writeDelay time.Duration, err error) {
+		d.onQueueDelay(queueDelay, now)
+		d.onWrite(writeDelay, err, now)
+		now = now.Add(10 * time.Millisecond)
+	}
+
+	// uncongested window
+	for i := 0; i < 50; i++ {
+		step(time.Millisecond, 100*time.Microsecond, nil)
+	}
+	require.Empty(t, signals)
+
+	// pacer queue builds up
+	for i := 0; i < 50; i++ {
+		step(time.Duration(i)*5*time.Millisecond, 100*time.Microsecond, nil)
+	}
+	step(0, 0, nil)
+	require.Len(t, signals, 1)
+	require.True(t, signals[0].IsQueueBuildUp)
+	require.False(t, signals[0].IsSocketBlocked)
+	require.Equal(t, 245*time.Millisecond, signals[0].MaxQueueDelay)
+
+	// writes block on a full socket buffer, the queue drained
+	for i := 0; i < 49; i++ {
+		switch i % 10 {
+		case 0:
+			step(time.Millisecond, 20*time.Millisecond, nil)
+		case 1:
+			step(time.Millisecond, 0, fmt.Errorf("write: %w", syscall.EAGAIN))
+		default:
+			step(time.Millisecond, 100*time.Microsecond, nil)
+		}
+	}
+	step(0, 0, nil)
+	require.Len(t, signals, 2)
+	require.False(t, signals[1].IsQueueBuildUp)
+	require.True(t, signals[1].IsSocketBlocked)
+	require.Equal(t, 10, signals[1].NumBlockedWrites)
+	require.Equal(t, 50, signals[1].NumWrites)
+	require.True(t, d.Signal().IsCongested())
+
+	// back to normal
+	for i := 0; i < 50; i++ {
+		step(time.Millisecond, 100*time.Microsecond, nil)
+	}
+	require.Len(t, signals, 3)
+	require.False(t, signals[2].IsCongested())
+
+	stats := d.Stats()
+	require.Equal(t, 4, stats.NumWindows)
+	require.Equal(t, 1, stats.NumQueueBuildUpWindows)
+	require.Equal(t, 1, stats.NumBlockedWindows)
+	require.Equal(t, 10, stats.NumBlockedWrites)
+}
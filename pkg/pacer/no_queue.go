@@ -16,9 +16,11 @@ package pacer
 
 import (
 	"sync"
+	"time"
 
-	"github.com/gammazero/deque"
 	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
 )
 
 type NoQueue struct {
@@ -27,7 +29,7 @@ type NoQueue struct {
 	logger logger.Logger
 
 	lock      sync.RWMutex
-	packets   deque.Deque[*Packet]
+	packets   ring.Deque[*Packet]
 	wake      chan struct{}
 	isStopped bool
 }
@@ -38,11 +40,19 @@ func NewNoQueue(logger logger.Logger) *NoQueue {
 		logger: logger,
 		wake:   make(chan struct{}, 1),
 	}
-	n.packets.SetMinCapacity(9)
+	n.packets.Grow(1 << 9)
+	n.Base.queueSnapshot = n.snapshotQueue
 
 	return n
 }
 
+func (n *NoQueue) snapshotQueue() QueueSnapshot {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	return snapshotQueues(time.Now(), &n.packets)
+}
+
 func (n *NoQueue) Start() {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -65,6 +75,8 @@ func (n *NoQueue) Stop() {
 }
 
 func (n *NoQueue) Enqueue(p *Packet) {
+	n.Base.markEnqueued(p)
+
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
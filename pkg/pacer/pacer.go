@@ -37,8 +37,23 @@ type Packet struct {
 	Writer             RTPWriter
 	Pool               *sync.Pool
 	PoolEntity         *[]byte
+	// retransmissions are sent ahead of video and behind audio, within the retransmission cap of the pacer
+	IsRetransmission bool
+	// audio is latency sensitive, a PriorityInversionWatchdog reports it waiting behind other packets
+	IsAudio bool
 
-	pktSize int
+	pktSize    int
+	enqueuedAt time.Time
+	// non audio packets and retransmissions sent by the pacer when the packet was enqueued
+	numSentNonAudioAtEnqueue        uint64
+	numSentRetransmissionsAtEnqueue uint64
+}
+
+// release returns the packet buffer to its pool
+func (p *Packet) release() {
+	if p.Pool != nil && p.PoolEntity != nil {
+		p.Pool.Put(p.PoolEntity)
+	}
 }
 
 // calculate approximate packet size
@@ -0,0 +1,184 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+type PriorityInversionWatchdogParams struct {
+	// time an audio packet waits behind other packets above which it is a priority inversion
+	Threshold time.Duration
+	// inversions are reported at most once per interval, all are counted in stats
+	ReportInterval time.Duration
+}
+
+var PriorityInversionWatchdogParamsDefault = PriorityInversionWatchdogParams{
+	Threshold:      20 * time.Millisecond,
+	ReportInterval: 10 * time.Second,
+}
+
+// QueueSnapshot is the content of a pacer queue at the time of a priority inversion.
+// Fields a pacer does not have are zero.
+type QueueSnapshot struct {
+	NumPackets         int
+	NumAudio           int
+	NumRetransmissions int
+	NumBytes           int
+	// wait of the oldest packet in the queue
+	OldestDelay time.Duration
+	Bitrate     int
+}
+
+type PriorityInversion struct {
+	SSRC           uint32
+	SequenceNumber uint16
+	QueueDelay     time.Duration
+	// non audio packets sent while the audio packet was queued, retransmissions included
+	NumAhead int
+	// retransmissions among NumAhead, a pacer serving retransmissions first sends them ahead of audio
+	// enqueued before them, unlike video
+	NumRetransmissionsAhead int
+	// queue of the pacer after the audio packet was sent, nil for pacers without a queue
+	Queue *QueueSnapshot
+	// inversions since the last report, including this one
+	NumInversions int
+}
+
+type PriorityInversionWatchdogStats struct {
+	NumAudioPackets int
+	NumInversions   int
+	NumReports      int
+	MaxQueueDelay   time.Duration
+}
+
+// PriorityInversionWatchdog watches the audio packets sent by the pacers it is set on and reports those
+// waiting behind video beyond a threshold. Pacers send audio in order or ahead, it should not wait behind a video burst,
+// an inversion points to a pacing regression or misconfiguration, e.g. a bitrate too low for the queue.
+//
+// Typical use is one watchdog shared by the pacers of a node, logging the reports
+//
+//	watchdog := pacer.NewPriorityInversionWatchdog(pacer.PriorityInversionWatchdogParamsDefault)
+//	watchdog.OnInversion(func(inversion pacer.PriorityInversion) { logger.Warnw("pacer priority inversion", nil, "inversion", inversion) })
+//	pacerFactory := pacer.NewPacerFactory(pacer.LeakyBucketPacer, pacer.WithPriorityInversionWatchdog(watchdog))
+type PriorityInversionWatchdog struct {
+	params PriorityInversionWatchdogParams
+
+	lock          sync.Mutex
+	lastReportAt  time.Time
+	numUnreported int
+	stats         PriorityInversionWatchdogStats
+	onInversion   func(inversion PriorityInversion)
+}
+
+func NewPriorityInversionWatchdog(params PriorityInversionWatchdogParams) *PriorityInversionWatchdog {
+	if params.Threshold <= 0 {
+		params.Threshold = PriorityInversionWatchdogParamsDefault.Threshold
+	}
+	if params.ReportInterval < 0 {
+		params.ReportInterval = PriorityInversionWatchdogParamsDefault.ReportInterval
+	}
+	return &PriorityInversionWatchdog{
+		params: params,
+	}
+}
+
+// OnInversion sets the callback called with the diagnostics of an inversion, at most once per report interval.
+func (w *PriorityInversionWatchdog) OnInversion(f func(inversion PriorityInversion)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.onInversion = f
+}
+
+func (w *PriorityInversionWatchdog) Stats() PriorityInversionWatchdogStats {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.stats
+}
+
+func (w *PriorityInversionWatchdog) onAudioSent(p *Packet, queueDelay time.Duration, numAhead int, numRetransmissionsAhead int, queueSnapshot func() QueueSnapshot) {
+	inversion, onInversion := w.handleAudioSent(p, queueDelay, numAhead, numRetransmissionsAhead, time.Now())
+	if inversion == nil || onInversion == nil {
+		return
+	}
+
+	// taken outside the lock, it is only needed when reported
+	if queueSnapshot != nil {
+		snapshot := queueSnapshot()
+		inversion.Queue = &snapshot
+	}
+	onInversion(*inversion)
+}
+
+func (w *PriorityInversionWatchdog) handleAudioSent(p *Packet, queueDelay time.Duration, numAhead int, numRetransmissionsAhead int, now time.Time) (*PriorityInversion, func(inversion PriorityInversion)) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.stats.NumAudioPackets++
+	if queueDelay > w.stats.MaxQueueDelay {
+		w.stats.MaxQueueDelay = queueDelay
+	}
+	if queueDelay < w.params.Threshold || numAhead == 0 {
+		return nil, nil
+	}
+
+	w.stats.NumInversions++
+	w.numUnreported++
+	if !w.lastReportAt.IsZero() && now.Sub(w.lastReportAt) < w.params.ReportInterval {
+		return nil, nil
+	}
+
+	w.lastReportAt = now
+	w.stats.NumReports++
+	inversion := &PriorityInversion{
+		QueueDelay:              queueDelay,
+		NumAhead:                numAhead,
+		NumRetransmissionsAhead: numRetransmissionsAhead,
+		NumInversions:           w.numUnreported,
+	}
+	if p.Header != nil {
+		inversion.SSRC = p.Header.SSRC
+		inversion.SequenceNumber = p.Header.SequenceNumber
+	}
+	w.numUnreported = 0
+	return inversion, w.onInversion
+}
+
+// ------------------------------------------------
+
+// snapshotQueues counts the packets of pacer queues, it is linear in their length and meant for diagnostics
+func snapshotQueues(now time.Time, queues ...*ring.Deque[*Packet]) QueueSnapshot {
+	var snapshot QueueSnapshot
+	for _, q := range queues {
+		for i := 0; i < q.Len(); i++ {
+			pkt := q.At(i)
+			snapshot.NumPackets++
+			if pkt.IsAudio {
+				snapshot.NumAudio++
+			}
+			if !pkt.enqueuedAt.IsZero() {
+				if delay := now.Sub(pkt.enqueuedAt); delay > snapshot.OldestDelay {
+					snapshot.OldestDelay = delay
+				}
+			}
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,86 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityInversionWatchdog(t *testing.T) {
+	w := NewPriorityInversionWatchdog(PriorityInversionWatchdogParams{Threshold: 20 * time.Millisecond, ReportInterval: time.Second})
+	audio := &Packet{Header: &rtp.Header{SSRC: 1, SequenceNumber: 10}, IsAudio: true}
+	now := time.Now()
+
+	// fast, or slow without video ahead
+	inversion, _ := w.handleAudioSent(audio, 5*time.Millisecond, 3, 0, now)
+	require.Nil(t, inversion)
+	inversion, _ = w.handleAudioSent(audio, 50*time.Millisecond, 0, 0, now)
+	require.Nil(t, inversion)
+
+	inversion, _ = w.handleAudioSent(audio, 30*time.Millisecond, 3, 1, now)
+	require.Equal(t, &PriorityInversion{SSRC: 1, SequenceNumber: 10, QueueDelay: 30 * time.Millisecond, NumAhead: 3, NumRetransmissionsAhead: 1, NumInversions: 1}, inversion)
+
+	// rate limited, counted in the next report
+	inversion, _ = w.handleAudioSent(audio, 30*time.Millisecond, 3, 0, now.Add(500*time.Millisecond))
+	require.Nil(t, inversion)
+	inversion, _ = w.handleAudioSent(audio, 40*time.Millisecond, 2, 0, now.Add(time.Second))
+	require.Equal(t, 2, inversion.NumInversions)
+
+	require.Equal(t, PriorityInversionWatchdogStats{
+		NumAudioPackets: 5,
+		NumInversions:   3,
+		NumReports:      2,
+		MaxQueueDelay:   50 * time.Millisecond,
+	}, w.Stats())
+}
+
+func TestPriorityInversionWatchdogBase(t *testing.T) {
+	w := NewPriorityInversionWatchdog(PriorityInversionWatchdogParams{Threshold: 20 * time.Millisecond})
+	var inversions []PriorityInversion
+	w.OnInversion(func(inversion PriorityInversion) {
+		inversions = append(inversions, inversion)
+	})
+
+	n := NewNoQueue(logger.GetLogger())
+	n.SetPriorityInversionWatchdog(w)
+	writer := func(header *rtp.Header, payload []byte) (int, error) {
+		return len(payload), nil
+	}
+	packet := func(isAudio bool, isRetransmission bool) *Packet {
+		return &Packet{Header: &rtp.Header{}, Payload: []byte{1}, Writer: writer, IsAudio: isAudio, IsRetransmission: isRetransmission}
+	}
+
+	// video and a retransmission ahead of the audio packet, and video behind it
+	n.Enqueue(packet(false, false))
+	n.Enqueue(packet(false, true))
+	n.Enqueue(packet(true, false))
+	n.Enqueue(packet(false, false))
+	n.packets.At(2).enqueuedAt = time.Now().Add(-50 * time.Millisecond)
+	for n.packets.Len() != 0 {
+		_, err := n.SendPacket(n.packets.PopFront())
+		require.NoError(t, err)
+	}
+
+	require.Len(t, inversions, 1)
+	require.Equal(t, 2, inversions[0].NumAhead)
+	require.Equal(t, 1, inversions[0].NumRetransmissionsAhead)
+	require.NotNil(t, inversions[0].Queue)
+	require.Equal(t, 1, inversions[0].Queue.NumPackets)
+}
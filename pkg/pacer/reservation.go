@@ -0,0 +1,212 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidReservation       = errors.New("invalid bandwidth reservation")
+	ErrReservationExists        = errors.New("session already has a bandwidth reservation")
+	ErrReservationExceedsBudget = errors.New("bandwidth reservation exceeds the shaper budget")
+)
+
+type ReservationStats struct {
+	SessionID string
+	Bitrate   int
+	Start     time.Time
+	End       time.Time
+	IsActive  bool
+	IsEnded   bool
+	// bitrate currently allocated to the members of the session out of the reservation
+	Used int
+	// bytes reserved and used so far while active, their ratio is the utilisation of the reservation
+	ReservedBytes uint64
+	UsedBytes     uint64
+}
+
+// Reservation holds a slice of the shaper budget for a session between its start and end, e.g. a scheduled webinar.
+// While active the reserved bitrate is taken out of what other members share, whether the session uses it or not,
+// so best effort traffic cannot starve the session. Members associated with the session with ShaperMember.SetSessionID
+// are served from the reservation first and share the rest of the budget as usual beyond it.
+type Reservation struct {
+	shaper    *Shaper
+	sessionID string
+	bitrate   int
+	start     time.Time
+	end       time.Time
+	timers    []*time.Timer
+
+	// guarded by shaper lock
+	isEnded      bool
+	used         int
+	accountedAt  time.Time
+	reservedBits float64
+	usedBits     float64
+}
+
+// Reserve reserves bitrate for a session from start to end, the reservation expires at end.
+// It returns ErrReservationExceedsBudget if reservations overlapping it would reserve more than the budget.
+func (s *Shaper) Reserve(sessionID string, bitrate int, start time.Time, end time.Time) (*Reservation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r, err := s.reserveLocked(sessionID, bitrate, start, end, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	update := func() {
+		s.lock.Lock()
+		s.updateLocked()
+		s.lock.Unlock()
+	}
+	if d := time.Until(start); d > 0 {
+		r.timers = append(r.timers, time.AfterFunc(d, update))
+	}
+	r.timers = append(r.timers, time.AfterFunc(time.Until(end), update))
+	return r, nil
+}
+
+func (s *Shaper) reserveLocked(sessionID string, bitrate int, start time.Time, end time.Time, now time.Time) (*Reservation, error) {
+	if sessionID == "" || bitrate <= 0 || !end.After(start) || !end.After(now) {
+		return nil, ErrInvalidReservation
+	}
+	if _, ok := s.reservations[sessionID]; ok {
+		return nil, ErrReservationExists
+	}
+	if s.maxBitrate > 0 {
+		if reserved := s.maxReservedLocked(start, end); reserved+bitrate > s.maxBitrate {
+			return nil, fmt.Errorf("%w: %d reserved, max %d", ErrReservationExceedsBudget, reserved, s.maxBitrate)
+		}
+	}
+
+	r := &Reservation{
+		shaper:    s,
+		sessionID: sessionID,
+		bitrate:   bitrate,
+		start:     start,
+		end:       end,
+	}
+	s.reservations[sessionID] = r
+	s.updateAtLocked(now)
+	return r, nil
+}
+
+// maxReservedLocked returns the most reserved at any time between start and end, which is at start or at the start
+// of a reservation within
+func (s *Shaper) maxReservedLocked(start time.Time, end time.Time) int {
+	maxReserved := 0
+	for _, r := range s.reservations {
+		at := r.start
+		if at.Before(start) {
+			at = start
+		}
+		if !at.Before(end) {
+			continue
+		}
+
+		reserved := 0
+		for _, other := range s.reservations {
+			if other.isActiveAt(at) {
+				reserved += other.bitrate
+			}
+		}
+		if reserved > maxReserved {
+			maxReserved = reserved
+		}
+	}
+	return maxReserved
+}
+
+func (r *Reservation) SessionID() string {
+	return r.sessionID
+}
+
+// Cancel ends the reservation early, the bandwidth is returned to the shared budget.
+func (r *Reservation) Cancel() {
+	for _, t := range r.timers {
+		t.Stop()
+	}
+
+	r.shaper.lock.Lock()
+	defer r.shaper.lock.Unlock()
+
+	r.cancelAtLocked(time.Now())
+}
+
+func (r *Reservation) Stats() ReservationStats {
+	r.shaper.lock.Lock()
+	defer r.shaper.lock.Unlock()
+
+	return r.statsAtLocked(time.Now())
+}
+
+func (r *Reservation) cancelAtLocked(now time.Time) {
+	if r.isEnded {
+		return
+	}
+
+	r.accountLocked(now)
+	r.isEnded = true
+	delete(r.shaper.reservations, r.sessionID)
+	r.shaper.updateAtLocked(now)
+}
+
+func (r *Reservation) statsAtLocked(now time.Time) ReservationStats {
+	r.accountLocked(now)
+	return ReservationStats{
+		SessionID:     r.sessionID,
+		Bitrate:       r.bitrate,
+		Start:         r.start,
+		End:           r.end,
+		IsActive:      r.isActiveAt(now),
+		IsEnded:       r.isEnded,
+		Used:          r.used,
+		ReservedBytes: uint64(r.reservedBits / 8),
+		UsedBytes:     uint64(r.usedBits / 8),
+	}
+}
+
+func (r *Reservation) isActiveAt(now time.Time) bool {
+	return !r.isEnded && !now.Before(r.start) && now.Before(r.end)
+}
+
+// accountLocked adds what was reserved and used since it was last accounted, within the active period
+func (r *Reservation) accountLocked(now time.Time) {
+	if r.isEnded {
+		return
+	}
+
+	from := r.accountedAt
+	if from.Before(r.start) {
+		from = r.start
+	}
+	to := now
+	if to.After(r.end) {
+		to = r.end
+	}
+	if to.After(from) {
+		elapsed := to.Sub(from).Seconds()
+		r.reservedBits += float64(r.bitrate) * elapsed
+		r.usedBits += float64(r.used) * elapsed
+	}
+	if now.After(r.accountedAt) {
+		r.accountedAt = now
+	}
+}
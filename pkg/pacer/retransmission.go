@@ -0,0 +1,124 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ratelimit"
+)
+
+const (
+	// the burst allows at least one full size packet, whatever the bitrate
+	minRetransmissionBurstBytes = 1500
+)
+
+type RetransmissionLimiterParams struct {
+	// share of the pacer bitrate retransmissions may use, 0 for no cap
+	MaxShare float64
+	// retransmissions may burst above the share by this much time at the capped rate
+	Burst time.Duration
+}
+
+var RetransmissionLimiterParamsDefault = RetransmissionLimiterParams{
+	MaxShare: 0.25,
+	Burst:    100 * time.Millisecond,
+}
+
+type RetransmissionLimiterStats struct {
+	NumPackets        int
+	NumBytes          int
+	NumDroppedPackets int
+	NumDroppedBytes   int
+}
+
+// RetransmissionLimiter caps the share of egress used by retransmissions of a connection. Retransmissions
+// above the cap are dropped rather than queued, under heavy loss repairing every packet would add to the congestion
+// causing the loss.
+type RetransmissionLimiter struct {
+	params RetransmissionLimiterParams
+
+	lock    sync.Mutex
+	bitrate int
+	bucket  ratelimit.TokenBucket
+	stats   RetransmissionLimiterStats
+}
+
+func NewRetransmissionLimiter(params RetransmissionLimiterParams, bitrate int) *RetransmissionLimiter {
+	if params.Burst == 0 {
+		params.Burst = RetransmissionLimiterParamsDefault.Burst
+	}
+	r := &RetransmissionLimiter{
+		params:  params,
+		bitrate: bitrate,
+	}
+	r.bucket = ratelimit.NewTokenBucket(r.rateLocked(), r.maxTokensLocked())
+	return r
+}
+
+func (r *RetransmissionLimiter) SetBitrate(bitrate int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.bitrate = bitrate
+	r.bucket.SetRate(r.rateLocked(), r.maxTokensLocked())
+}
+
+// Allow returns true if a retransmission of size bytes fits in the cap and accounts for it.
+func (r *RetransmissionLimiter) Allow(size int) bool {
+	return r.allow(size, time.Now())
+}
+
+func (r *RetransmissionLimiter) allow(size int, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.params.MaxShare <= 0 {
+		r.stats.NumPackets++
+		r.stats.NumBytes += size
+		return true
+	}
+
+	if !r.bucket.AllowAt(now, float64(size)) {
+		r.stats.NumDroppedPackets++
+		r.stats.NumDroppedBytes += size
+		return false
+	}
+
+	r.stats.NumPackets++
+	r.stats.NumBytes += size
+	return true
+}
+
+func (r *RetransmissionLimiter) Stats() RetransmissionLimiterStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.stats
+}
+
+// rateLocked returns the capped rate in bytes per second
+func (r *RetransmissionLimiter) rateLocked() float64 {
+	return float64(r.bitrate) * r.params.MaxShare / 8
+}
+
+func (r *RetransmissionLimiter) maxTokensLocked() float64 {
+	maxTokens := r.rateLocked() * r.params.Burst.Seconds()
+	if maxTokens < minRetransmissionBurstBytes {
+		maxTokens = minRetransmissionBurstBytes
+	}
+	return maxTokens
+}
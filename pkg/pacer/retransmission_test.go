@@ -0,0 +1,95 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetransmissionLimiter(t *testing.T) {
+	// 25% of 1 Mbps, 3125 bytes burst
+	r := NewRetransmissionLimiter(RetransmissionLimiterParamsDefault, 1_000_000)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		require.True(t, r.allow(1000, now))
+	}
+	require.False(t, r.allow(1000, now))
+
+	now = now.Add(32 * time.Millisecond)
+	require.True(t, r.allow(1000, now))
+	require.False(t, r.allow(1000, now))
+
+	// lower bitrate lowers the burst, but one full size packet always fits
+	r.SetBitrate(10_000)
+	now = now.Add(5 * time.Second)
+	require.True(t, r.allow(1500, now))
+	require.False(t, r.allow(1500, now))
+
+	require.Equal(t, RetransmissionLimiterStats{
+		NumPackets:        5,
+		NumBytes:          5500,
+		NumDroppedPackets: 3,
+		NumDroppedBytes:   3500,
+	}, r.Stats())
+
+	unlimited := NewRetransmissionLimiter(RetransmissionLimiterParams{}, 1_000_000)
+	for i := 0; i < 100; i++ {
+		require.True(t, unlimited.allow(1000, now))
+	}
+}
+
+func TestPacerLeakyBucketRetransmissionCap(t *testing.T) {
+	p := NewPacerLeakyBucket(5*time.Millisecond, 1_000_000, 0, logger.GetLogger())
+	p.SetRetransmissionLimiter(RetransmissionLimiterParamsDefault)
+
+	for i := 0; i < 5; i++ {
+		p.Enqueue(&Packet{Header: &rtp.Header{}, Payload: make([]byte, 988), IsRetransmission: true})
+		p.Enqueue(&Packet{Header: &rtp.Header{}, Payload: make([]byte, 988)})
+	}
+	require.Equal(t, 3, p.rtxPackets.Len())
+	require.Equal(t, 5, p.packets.Len())
+	require.Equal(t, 8000, p.queueBytes)
+
+	stats := p.RetransmissionStats()
+	require.Equal(t, 3, stats.NumPackets)
+	require.Equal(t, 2, stats.NumDroppedPackets)
+}
+
+func TestPacerLeakyBucketAudioAheadOfRetransmissions(t *testing.T) {
+	p := NewPacerLeakyBucket(5*time.Millisecond, 1_000_000, 0, logger.GetLogger())
+	p.SetRetransmissionLimiter(RetransmissionLimiterParamsDefault)
+
+	packet := func(sn uint16, isAudio bool, isRetransmission bool) *Packet {
+		return &Packet{Header: &rtp.Header{SequenceNumber: sn}, Payload: make([]byte, 100), IsAudio: isAudio, IsRetransmission: isRetransmission}
+	}
+	p.Enqueue(packet(1, false, false))
+	p.Enqueue(packet(2, false, true))
+	p.Enqueue(packet(3, true, false))
+	p.Enqueue(packet(4, false, true))
+	p.Enqueue(packet(5, true, false))
+
+	// audio first, within the cap retransmissions, then video
+	var sent []uint16
+	for pkt := p.popLocked(); pkt != nil; pkt = p.popLocked() {
+		sent = append(sent, pkt.Header.SequenceNumber)
+	}
+	require.Equal(t, []uint16{3, 5, 2, 4, 1}, sent)
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/allocation"
+)
+
+// QoSClass labels a peer connection for prioritization when the node is congested.
+type QoSClass int
+
+const (
+	QoSClassBestEffort QoSClass = iota
+	// latency tolerant, needs its bitrate over time (e.g. egress for recording)
+	QoSClassRecording
+	// latency sensitive (e.g. participants in a call)
+	QoSClassInteractive
+)
+
+func (q QoSClass) String() string {
+	switch q {
+	case QoSClassBestEffort:
+		return "BEST_EFFORT"
+	case QoSClassRecording:
+		return "RECORDING"
+	case QoSClassInteractive:
+		return "INTERACTIVE"
+	default:
+		return fmt.Sprintf("%d", int(q))
+	}
+}
+
+// Weight is the relative share of the class when bandwidth is contended.
+func (q QoSClass) Weight() int {
+	switch q {
+	case QoSClassRecording:
+		return 2
+	case QoSClassInteractive:
+		return 4
+	default:
+		return 1
+	}
+}
+
+// Shaper splits a node wide send budget among the pacers of peer connections.
+// When the desired bitrates fit in the budget, every pacer gets its desired bitrate,
+// otherwise the budget is shared by QoS class weight, without giving any pacer more than it desires.
+// Bandwidth reserved for a session, see Reserve, is taken out of the budget before it is shared.
+type Shaper struct {
+	lock         sync.Mutex
+	maxBitrate   int
+	members      []*ShaperMember
+	reservations map[string]*Reservation
+}
+
+func NewShaper(maxBitrate int) *Shaper {
+	return &Shaper{
+		maxBitrate:   maxBitrate,
+		reservations: make(map[string]*Reservation),
+	}
+}
+
+func (s *Shaper) SetMaxBitrate(maxBitrate int) {
+	s.lock.Lock()
+	s.maxBitrate = maxBitrate
+	s.updateLocked()
+	s.lock.Unlock()
+}
+
+// Add registers the pacer of a peer connection, desiredBitrate is the congestion controlled estimate of the connection.
+func (s *Shaper) Add(pacer Pacer, class QoSClass, desiredBitrate int) *ShaperMember {
+	m := &ShaperMember{
+		shaper:         s,
+		pacer:          pacer,
+		class:          class,
+		desiredBitrate: desiredBitrate,
+		bitrate:        -1,
+	}
+
+	s.lock.Lock()
+	s.members = append(s.members, m)
+	s.updateLocked()
+	s.lock.Unlock()
+	return m
+}
+
+func (s *Shaper) remove(m *ShaperMember) {
+	s.lock.Lock()
+	for i, other := range s.members {
+		if other == m {
+			s.members = append(s.members[:i], s.members[i+1:]...)
+			break
+		}
+	}
+	s.updateLocked()
+	s.lock.Unlock()
+}
+
+func (s *Shaper) updateLocked() {
+	s.updateAtLocked(time.Now())
+}
+
+// updateAtLocked serves the members of sessions with an active reservation from it first,
+// their demand beyond it is shared with other members out of what is not reserved
+func (s *Shaper) updateAtLocked(now time.Time) {
+	bitrates := make([]int, len(s.members))
+	bySession := make(map[*Reservation][]int)
+	var shared []int
+	var sharedDemands []allocation.Demand
+	for i, m := range s.members {
+		if r := s.reservations[m.sessionID]; r != nil && m.sessionID != "" {
+			bySession[r] = append(bySession[r], i)
+		} else {
+			shared = append(shared, i)
+			sharedDemands = append(sharedDemands, allocation.Demand{Weight: m.class.Weight(), Desired: m.desiredBitrate})
+		}
+	}
+
+	unreserved := s.maxBitrate
+	for sessionID, r := range s.reservations {
+		r.accountLocked(now)
+		if !now.Before(r.end) {
+			r.isEnded = true
+			delete(s.reservations, sessionID)
+		}
+		if !r.isActiveAt(now) {
+			// members of sessions with an upcoming or ended reservation share the budget as usual
+			for _, i := range bySession[r] {
+				m := s.members[i]
+				shared = append(shared, i)
+				sharedDemands = append(sharedDemands, allocation.Demand{Weight: m.class.Weight(), Desired: m.desiredBitrate})
+			}
+			continue
+		}
+
+		unreserved -= r.bitrate
+		demands := make([]allocation.Demand, 0, len(bySession[r]))
+		for _, i := range bySession[r] {
+			m := s.members[i]
+			demands = append(demands, allocation.Demand{Weight: m.class.Weight(), Desired: m.desiredBitrate})
+		}
+		r.used = 0
+		for j, bitrate := range allocation.MaxMinFair(r.bitrate, demands) {
+			i := bySession[r][j]
+			bitrates[i] = bitrate
+			r.used += bitrate
+			if excess := demands[j].Desired - bitrate; excess > 0 {
+				shared = append(shared, i)
+				sharedDemands = append(sharedDemands, allocation.Demand{Weight: demands[j].Weight, Desired: excess})
+			}
+		}
+	}
+
+	var allocated []int
+	if s.maxBitrate > 0 && unreserved <= 0 {
+		// all of the budget is reserved
+		allocated = make([]int, len(sharedDemands))
+	} else {
+		allocated = allocation.MaxMinFair(unreserved, sharedDemands)
+	}
+	for j, bitrate := range allocated {
+		bitrates[shared[j]] += bitrate
+	}
+
+	for i, bitrate := range bitrates {
+		m := s.members[i]
+		if m.bitrate != bitrate {
+			m.bitrate = bitrate
+			m.pacer.SetBitrate(bitrate)
+		}
+	}
+}
+
+// ------------------------------------------------
+
+type ShaperMember struct {
+	shaper *Shaper
+	pacer  Pacer
+	class  QoSClass
+
+	// guarded by shaper lock
+	sessionID      string
+	desiredBitrate int
+	bitrate        int
+}
+
+func (m *ShaperMember) Class() QoSClass {
+	return m.class
+}
+
+// SetSessionID associates the member with a session, members of a session are served from its reservation.
+func (m *ShaperMember) SetSessionID(sessionID string) {
+	m.shaper.lock.Lock()
+	m.sessionID = sessionID
+	m.shaper.updateLocked()
+	m.shaper.lock.Unlock()
+}
+
+func (m *ShaperMember) SetDesiredBitrate(desiredBitrate int) {
+	m.shaper.lock.Lock()
+	m.desiredBitrate = desiredBitrate
+	m.shaper.updateLocked()
+	m.shaper.lock.Unlock()
+}
+
+// Bitrate returns the bitrate currently allowed to the pacer.
+func (m *ShaperMember) Bitrate() int {
+	m.shaper.lock.Lock()
+	defer m.shaper.lock.Unlock()
+
+	return m.bitrate
+}
+
+func (m *ShaperMember) Remove() {
+	m.shaper.remove(m)
+}
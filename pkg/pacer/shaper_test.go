@@ -0,0 +1,97 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pacer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShaper(t *testing.T) {
+	s := NewShaper(1_000_000)
+
+	recording := NewPacerLeakyBucket(defaultPacerParams.SendInterval, 0, 0, logger.GetLogger())
+	interactive := NewPacerLeakyBucket(defaultPacerParams.SendInterval, 0, 0, logger.GetLogger())
+
+	r := s.Add(recording, QoSClassRecording, 600_000)
+	require.Equal(t, 600_000, r.Bitrate())
+
+	i := s.Add(interactive, QoSClassInteractive, 900_000)
+	require.Equal(t, 333_333, r.Bitrate())
+	require.Equal(t, 666_666, i.Bitrate())
+
+	i.SetDesiredBitrate(300_000)
+	require.Equal(t, 600_000, r.Bitrate())
+	require.Equal(t, 300_000, i.Bitrate())
+
+	i.Remove()
+	s.SetMaxBitrate(500_000)
+	require.Equal(t, 500_000, r.Bitrate())
+}
+
+func TestShaperReservation(t *testing.T) {
+	s := NewShaper(1_000_000)
+	now := time.Now()
+
+	webinar := s.Add(NewPacerLeakyBucket(defaultPacerParams.SendInterval, 0, 0, logger.GetLogger()), QoSClassInteractive, 1_000_000)
+	bestEffort := s.Add(NewPacerLeakyBucket(defaultPacerParams.SendInterval, 0, 0, logger.GetLogger()), QoSClassBestEffort, 1_000_000)
+	webinar.SetSessionID("webinar")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	start := now.Add(time.Minute)
+	end := start.Add(time.Hour)
+	r, err := s.reserveLocked("webinar", 600_000, start, end, now)
+	require.NoError(t, err)
+	_, err = s.reserveLocked("webinar", 100_000, start, end, now)
+	require.ErrorIs(t, err, ErrReservationExists)
+	_, err = s.reserveLocked("other", 500_000, end.Add(-time.Minute), end.Add(time.Minute), now)
+	require.ErrorIs(t, err, ErrReservationExceedsBudget)
+	_, err = s.reserveLocked("other", 500_000, end, end.Add(time.Minute), now)
+	require.NoError(t, err)
+
+	// upcoming, shared by weight
+	require.Equal(t, 800_000, webinar.bitrate)
+	require.Equal(t, 200_000, bestEffort.bitrate)
+
+	// active, served from the reservation, the rest shared
+	s.updateAtLocked(start)
+	require.Equal(t, 600_000+320_000, webinar.bitrate)
+	require.Equal(t, 80_000, bestEffort.bitrate)
+
+	// the reservation is held even if the session does not use it
+	webinar.desiredBitrate = 100_000
+	s.updateAtLocked(start.Add(10 * time.Second))
+	require.Equal(t, 100_000, webinar.bitrate)
+	require.Equal(t, 400_000, bestEffort.bitrate)
+
+	stats := r.statsAtLocked(start.Add(20 * time.Second))
+	require.True(t, stats.IsActive)
+	require.Equal(t, uint64(20*600_000/8), stats.ReservedBytes)
+	require.Equal(t, uint64((10*600_000+10*100_000)/8), stats.UsedBytes)
+
+	// expired
+	s.updateAtLocked(end)
+	stats = r.statsAtLocked(end)
+	require.True(t, stats.IsEnded)
+	require.Equal(t, uint64(3600*600_000/8), stats.ReservedBytes)
+	require.Equal(t, 100_000, webinar.bitrate)
+	// the following reservation is active
+	require.Equal(t, 400_000, bestEffort.bitrate)
+}
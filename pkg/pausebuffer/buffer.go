@@ -0,0 +1,356 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pausebuffer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+type ResumePolicy int
+
+const (
+	// catch up if the backlog is at most MaxCatchUp, otherwise skip to live
+	ResumePolicyAuto ResumePolicy = iota
+	// send the whole backlog, faster than real time until caught up
+	ResumePolicyCatchUp
+	// drop the backlog up to its last key frame, or all of it and wait for the next key frame
+	ResumePolicySkipToLive
+)
+
+func (r ResumePolicy) String() string {
+	switch r {
+	case ResumePolicyAuto:
+		return "AUTO"
+	case ResumePolicyCatchUp:
+		return "CATCH_UP"
+	case ResumePolicySkipToLive:
+		return "SKIP_TO_LIVE"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type State int
+
+const (
+	StateLive State = iota
+	StatePaused
+	// sending the backlog after a resume
+	StateCatchingUp
+	// live packets are dropped until a key frame
+	StateWaitingForKeyFrame
+)
+
+func (s State) String() string {
+	switch s {
+	case StateLive:
+		return "LIVE"
+	case StatePaused:
+		return "PAUSED"
+	case StateCatchingUp:
+		return "CATCHING_UP"
+	case StateWaitingForKeyFrame:
+		return "WAITING_FOR_KEY_FRAME"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type BufferParams struct {
+	// codec of the stream, CodecUnknown for audio, where the stream can be resumed from any packet
+	Codec keyframe.Codec
+	// backlog kept while paused, the oldest packets are dropped beyond either limit
+	MaxDuration time.Duration
+	MaxBytes    int
+	Policy      ResumePolicy
+	// longest backlog caught up with by ResumePolicyAuto
+	MaxCatchUp time.Duration
+	// pace of sending the backlog, as a multiple of real time
+	CatchUpSpeed float64
+}
+
+var BufferParamsDefault = BufferParams{
+	MaxDuration:  3 * time.Second,
+	MaxBytes:     1024 * 1024,
+	MaxCatchUp:   time.Second,
+	CatchUpSpeed: 2,
+}
+
+type ResumeResult struct {
+	Policy ResumePolicy
+	// time between the oldest buffered packet and the resume
+	Backlog    time.Duration
+	NumSkipped int
+	// the backlog had no key frame to skip to, the caller requests one from the publisher
+	NeedsKeyFrame bool
+}
+
+type BufferStats struct {
+	NumBuffered int
+	// dropped for the duration or size limits while paused
+	NumDropped int
+	// dropped skipping to live
+	NumSkipped     int
+	NumCatchUps    int
+	NumSkipsToLive int
+}
+
+type bufferedPacket struct {
+	pkt *rtp.Packet
+	at  time.Time
+}
+
+// Buffer holds the media of a subscriber's stream while it is temporarily paused, for example during
+// a renegotiation, and releases it on resume according to a policy: sent faster than real time until it
+// has caught up with the live stream, or skipped to the most recent key frame.
+//
+// While live, Push returns true and the packet is forwarded as usual. Otherwise the buffer keeps a copy
+// of the packet and the forwarder sends the packets returned by Pop, polling it at its pacing interval.
+// The buffer always starts at a point the subscriber can decode from, the continuation of what it
+// received before the pause or a key frame; dropping packets for the limits drops up to the next key frame.
+type Buffer struct {
+	params BufferParams
+
+	lock  sync.Mutex
+	state State
+	// packets are dropped until one the subscriber can decode from
+	needsStart bool
+	packets    ring.Deque[bufferedPacket]
+	bytes      int
+	resumedAt  time.Time
+	firstAt    time.Time
+	stats      BufferStats
+}
+
+func NewBuffer(params BufferParams) *Buffer {
+	if params.MaxDuration <= 0 {
+		params.MaxDuration = BufferParamsDefault.MaxDuration
+	}
+	if params.MaxBytes <= 0 {
+		params.MaxBytes = BufferParamsDefault.MaxBytes
+	}
+	if params.MaxCatchUp <= 0 {
+		params.MaxCatchUp = BufferParamsDefault.MaxCatchUp
+	}
+	if params.CatchUpSpeed <= 1 {
+		params.CatchUpSpeed = BufferParamsDefault.CatchUpSpeed
+	}
+	return &Buffer{
+		params: params,
+	}
+}
+
+func (b *Buffer) State() State {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.state
+}
+
+func (b *Buffer) Stats() BufferStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.stats
+}
+
+// Pause starts buffering.
+func (b *Buffer) Pause() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.state = StatePaused
+}
+
+// Push returns true if pkt is to be forwarded right away, otherwise the buffer keeps a copy of it.
+func (b *Buffer) Push(pkt *rtp.Packet, at time.Time) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == StateLive {
+		return true
+	}
+	if b.needsStart {
+		if !b.isStart(pkt) {
+			b.stats.NumSkipped++
+			return false
+		}
+		b.needsStart = false
+		if b.state == StateWaitingForKeyFrame {
+			b.state = StateLive
+			return true
+		}
+	}
+
+	if b.packets.Len() == 0 {
+		b.firstAt = at
+	}
+	b.packets.PushBack(bufferedPacket{pkt: pkt.Clone(), at: at})
+	b.bytes += pkt.MarshalSize()
+	b.stats.NumBuffered++
+
+	if b.state == StatePaused {
+		b.enforceLimitsLocked(at)
+	}
+	return false
+}
+
+// Resume applies the resume policy to the backlog, the packets to send are returned by Pop.
+func (b *Buffer) Resume(at time.Time) ResumeResult {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != StatePaused {
+		return ResumeResult{}
+	}
+
+	result := ResumeResult{Policy: b.params.Policy}
+	if b.packets.Len() != 0 {
+		result.Backlog = at.Sub(b.packets.Front().at)
+	}
+	if result.Policy == ResumePolicyAuto {
+		if result.Backlog <= b.params.MaxCatchUp {
+			result.Policy = ResumePolicyCatchUp
+		} else {
+			result.Policy = ResumePolicySkipToLive
+		}
+	}
+
+	switch result.Policy {
+	case ResumePolicyCatchUp:
+		b.stats.NumCatchUps++
+	case ResumePolicySkipToLive:
+		b.stats.NumSkipsToLive++
+		result.NumSkipped = b.skipToLastStartLocked()
+		if b.packets.Len() == 0 {
+			b.needsStart = b.params.Codec != keyframe.CodecUnknown
+		} else {
+			// the key frame and what followed it are sent right away
+			b.firstAt = at
+		}
+	}
+
+	switch {
+	case b.packets.Len() != 0:
+		b.state = StateCatchingUp
+		b.resumedAt = at
+	case b.needsStart:
+		b.state = StateWaitingForKeyFrame
+		result.NeedsKeyFrame = b.params.Codec != keyframe.CodecUnknown
+	default:
+		b.state = StateLive
+	}
+	return result
+}
+
+// Pop returns the buffered packets due at now, in order. Once the backlog is sent the stream is live again.
+func (b *Buffer) Pop(now time.Time) []*rtp.Packet {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != StateCatchingUp {
+		return nil
+	}
+
+	var pkts []*rtp.Packet
+	for b.packets.Len() != 0 && !now.Before(b.dueLocked(b.packets.Front())) {
+		p := b.packets.PopFront()
+		b.bytes -= p.pkt.MarshalSize()
+		pkts = append(pkts, p.pkt)
+	}
+	if b.packets.Len() == 0 {
+		b.state = StateLive
+	}
+	return pkts
+}
+
+// NextDue returns when the next buffered packet is due, false if none is.
+func (b *Buffer) NextDue() (time.Time, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != StateCatchingUp || b.packets.Len() == 0 {
+		return time.Time{}, false
+	}
+	return b.dueLocked(b.packets.Front()), true
+}
+
+// dueLocked spaces the backlog as it arrived, sped up by the catch up speed. Packets received after the first one
+// of the backlog, even those arriving while catching up, are due when the backlog before them has been sent.
+func (b *Buffer) dueLocked(p bufferedPacket) time.Time {
+	offset := time.Duration(float64(p.at.Sub(b.firstAt)) / b.params.CatchUpSpeed)
+	return b.resumedAt.Add(offset)
+}
+
+func (b *Buffer) enforceLimitsLocked(now time.Time) {
+	for b.packets.Len() != 0 &&
+		(b.bytes > b.params.MaxBytes || now.Sub(b.packets.Front().at) > b.params.MaxDuration) {
+		b.dropFrontLocked()
+		b.stats.NumDropped++
+
+		// what remains must start where the subscriber can decode from
+		for b.packets.Len() != 0 && !b.isStart(b.packets.Front().pkt) {
+			b.dropFrontLocked()
+			b.stats.NumDropped++
+		}
+	}
+	if b.packets.Len() == 0 {
+		b.needsStart = true
+	} else {
+		b.firstAt = b.packets.Front().at
+	}
+}
+
+// skipToLastStartLocked drops the backlog before its last key frame, or all of it if there is none
+func (b *Buffer) skipToLastStartLocked() int {
+	last := -1
+	if b.params.Codec != keyframe.CodecUnknown {
+		for i := b.packets.Len() - 1; i >= 0; i-- {
+			if b.isStart(b.packets.At(i).pkt) {
+				last = i
+				break
+			}
+		}
+	}
+
+	numSkipped := b.packets.Len()
+	if last >= 0 {
+		numSkipped = last
+	}
+	for i := 0; i < numSkipped; i++ {
+		b.dropFrontLocked()
+	}
+	b.stats.NumSkipped += numSkipped
+	return numSkipped
+}
+
+func (b *Buffer) dropFrontLocked() {
+	p := b.packets.PopFront()
+	b.bytes -= p.pkt.MarshalSize()
+}
+
+func (b *Buffer) isStart(pkt *rtp.Packet) bool {
+	if b.params.Codec == keyframe.CodecUnknown {
+		return true
+	}
+	return keyframe.IsKeyFrameStart(b.params.Codec, pkt.Payload)
+}
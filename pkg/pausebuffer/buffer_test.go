@@ -0,0 +1,137 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pausebuffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+)
+
+var (
+	vp8KeyFrame = []byte{0x10, 0x00}
+	vp8Delta    = []byte{0x10, 0x01}
+)
+
+func packet(sn uint16, payload []byte) *rtp.Packet {
+	return &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: sn}, Payload: payload}
+}
+
+func sequenceNumbers(pkts []*rtp.Packet) []uint16 {
+	var sns []uint16
+	for _, pkt := range pkts {
+		sns = append(sns, pkt.SequenceNumber)
+	}
+	return sns
+}
+
+func TestBufferCatchUp(t *testing.T) {
+	b := NewBuffer(BufferParams{Codec: keyframe.CodecVP8, Policy: ResumePolicyCatchUp})
+	start := time.Now()
+
+	require.True(t, b.Push(packet(1, vp8Delta), start))
+	b.Pause()
+	require.Equal(t, StatePaused, b.State())
+	for i := 0; i < 10; i++ {
+		require.False(t, b.Push(packet(uint16(2+i), vp8Delta), start.Add(time.Duration(i)*100*time.Millisecond)))
+	}
+	require.Nil(t, b.Pop(start.Add(time.Second)))
+
+	resumeAt := start.Add(time.Second)
+	result := b.Resume(resumeAt)
+	require.Equal(t, ResumePolicyCatchUp, result.Policy)
+	require.Equal(t, time.Second, result.Backlog)
+	require.Equal(t, StateCatchingUp, b.State())
+
+	// twice real time, live packets queue behind the backlog
+	require.Equal(t, []uint16{2}, sequenceNumbers(b.Pop(resumeAt)))
+	require.False(t, b.Push(packet(12, vp8Delta), resumeAt))
+	require.Equal(t, []uint16{3, 4}, sequenceNumbers(b.Pop(resumeAt.Add(100*time.Millisecond))))
+	due, ok := b.NextDue()
+	require.True(t, ok)
+	require.Equal(t, resumeAt.Add(150*time.Millisecond), due)
+
+	require.Len(t, b.Pop(resumeAt.Add(time.Second)), 8)
+	require.Equal(t, StateLive, b.State())
+	require.True(t, b.Push(packet(13, vp8Delta), resumeAt.Add(time.Second)))
+}
+
+func TestBufferSkipToLive(t *testing.T) {
+	b := NewBuffer(BufferParams{Codec: keyframe.CodecVP8, MaxCatchUp: 200 * time.Millisecond})
+	start := time.Now()
+
+	b.Pause()
+	b.Push(packet(1, vp8Delta), start)
+	b.Push(packet(2, vp8KeyFrame), start.Add(100*time.Millisecond))
+	b.Push(packet(3, vp8Delta), start.Add(200*time.Millisecond))
+	b.Push(packet(4, vp8KeyFrame), start.Add(300*time.Millisecond))
+	b.Push(packet(5, vp8Delta), start.Add(400*time.Millisecond))
+
+	result := b.Resume(start.Add(500 * time.Millisecond))
+	require.Equal(t, ResumePolicySkipToLive, result.Policy)
+	require.Equal(t, 3, result.NumSkipped)
+	require.False(t, result.NeedsKeyFrame)
+	require.Equal(t, []uint16{4, 5}, sequenceNumbers(b.Pop(start.Add(500*time.Millisecond))))
+	require.Equal(t, StateLive, b.State())
+
+	// no key frame in the backlog
+	b.Pause()
+	b.Push(packet(6, vp8Delta), start.Add(time.Second))
+	result = b.Resume(start.Add(2 * time.Second))
+	require.True(t, result.NeedsKeyFrame)
+	require.Equal(t, StateWaitingForKeyFrame, b.State())
+	require.False(t, b.Push(packet(7, vp8Delta), start.Add(2*time.Second)))
+	require.True(t, b.Push(packet(8, vp8KeyFrame), start.Add(2*time.Second)))
+	require.Equal(t, StateLive, b.State())
+	require.Equal(t, 5, b.Stats().NumSkipped)
+}
+
+func TestBufferLimits(t *testing.T) {
+	b := NewBuffer(BufferParams{Codec: keyframe.CodecVP8, MaxDuration: 250 * time.Millisecond, Policy: ResumePolicyCatchUp})
+	start := time.Now()
+
+	b.Pause()
+	b.Push(packet(1, vp8Delta), start)
+	b.Push(packet(2, vp8Delta), start.Add(100*time.Millisecond))
+	b.Push(packet(3, vp8KeyFrame), start.Add(200*time.Millisecond))
+	// drops 1, and 2 which cannot be decoded without it
+	b.Push(packet(4, vp8Delta), start.Add(300*time.Millisecond))
+	require.Equal(t, 2, b.Stats().NumDropped)
+
+	// drops everything, nothing to continue from until a key frame
+	b.Push(packet(5, vp8Delta), start.Add(time.Second))
+	b.Push(packet(6, vp8Delta), start.Add(time.Second))
+	b.Push(packet(7, vp8KeyFrame), start.Add(time.Second))
+	b.Push(packet(8, vp8Delta), start.Add(time.Second))
+
+	b.Resume(start.Add(time.Second))
+	require.Equal(t, []uint16{7, 8}, sequenceNumbers(b.Pop(start.Add(time.Second))))
+}
+
+func TestBufferAudio(t *testing.T) {
+	b := NewBuffer(BufferParams{Policy: ResumePolicySkipToLive})
+	start := time.Now()
+
+	b.Pause()
+	b.Push(packet(1, []byte{1}), start)
+	result := b.Resume(start.Add(time.Second))
+	require.Equal(t, 1, result.NumSkipped)
+	require.False(t, result.NeedsKeyFrame)
+	require.Equal(t, StateLive, b.State())
+}
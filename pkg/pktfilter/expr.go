@@ -0,0 +1,421 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pktfilter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxExprLen   = 1024
+	maxExprDepth = 32
+)
+
+var (
+	ErrExprTooLong = errors.New("filter expression too long")
+)
+
+// Fields are the values of a packet an expression is evaluated on.
+type Fields struct {
+	SSRC           uint32
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	Marker         bool
+	// size of the packet, header included
+	Size    int
+	Inbound bool
+}
+
+type field int
+
+const (
+	fieldSSRC field = iota
+	fieldPT
+	fieldSeq
+	fieldTS
+	fieldMarker
+	fieldSize
+	fieldInbound
+)
+
+var fieldNames = map[string]field{
+	"ssrc":    fieldSSRC,
+	"pt":      fieldPT,
+	"seq":     fieldSeq,
+	"ts":      fieldTS,
+	"marker":  fieldMarker,
+	"size":    fieldSize,
+	"inbound": fieldInbound,
+}
+
+func (f Fields) get(fd field) uint64 {
+	switch fd {
+	case fieldSSRC:
+		return uint64(f.SSRC)
+	case fieldPT:
+		return uint64(f.PayloadType)
+	case fieldSeq:
+		return uint64(f.SequenceNumber)
+	case fieldTS:
+		return uint64(f.Timestamp)
+	case fieldMarker:
+		return boolValue(f.Marker)
+	case fieldSize:
+		return uint64(f.Size)
+	case fieldInbound:
+		return boolValue(f.Inbound)
+	default:
+		return 0
+	}
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Expr is a compiled filter expression. Expressions compare packet fields with integers and combine comparisons:
+//
+//	ssrc == 0x1234 && (pt in (96, 97) || size > 1200) && !marker
+//
+// Fields are ssrc, pt, seq, ts, marker, size and inbound, a field on its own is true when not 0.
+// Comparison operators are ==, !=, <, <=, >, >= and in, logical operators are &&, || and !.
+// Evaluation has no side effects and is bounded by the length of the expression.
+type Expr struct {
+	source string
+	root   node
+}
+
+// Compile parses expr.
+func Compile(expr string) (*Expr, error) {
+	if len(expr) > maxExprLen {
+		return nil, ErrExprTooLong
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.peek().text)
+	}
+	return &Expr{source: expr, root: root}, nil
+}
+
+func (e *Expr) Match(f Fields) bool {
+	return e.root.eval(f) != 0
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+// ------------------------------------------------
+
+type node interface {
+	eval(f Fields) uint64
+}
+
+type fieldNode struct{ field field }
+
+func (n fieldNode) eval(f Fields) uint64 { return f.get(n.field) }
+
+type constNode struct{ value uint64 }
+
+func (n constNode) eval(Fields) uint64 { return n.value }
+
+type notNode struct{ operand node }
+
+func (n notNode) eval(f Fields) uint64 { return boolValue(n.operand.eval(f) == 0) }
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n binaryNode) eval(f Fields) uint64 {
+	switch n.op {
+	case "&&":
+		return boolValue(n.left.eval(f) != 0 && n.right.eval(f) != 0)
+	case "||":
+		return boolValue(n.left.eval(f) != 0 || n.right.eval(f) != 0)
+	}
+
+	l, r := n.left.eval(f), n.right.eval(f)
+	switch n.op {
+	case "==":
+		return boolValue(l == r)
+	case "!=":
+		return boolValue(l != r)
+	case "<":
+		return boolValue(l < r)
+	case "<=":
+		return boolValue(l <= r)
+	case ">":
+		return boolValue(l > r)
+	case ">=":
+		return boolValue(l >= r)
+	default:
+		return 0
+	}
+}
+
+type inNode struct {
+	operand node
+	values  []uint64
+}
+
+func (n inNode) eval(f Fields) uint64 {
+	v := n.operand.eval(f)
+	for _, value := range n.values {
+		if v == value {
+			return 1
+		}
+	}
+	return 0
+}
+
+// ------------------------------------------------
+
+type tokenType int
+
+const (
+	tokenIdent tokenType = iota
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	typ  tokenType
+	text string
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case isIdentByte(c) && !isDigit(c):
+			j := i
+			for j < len(expr) && isIdentByte(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, token{typ: tokenIdent, text: expr[i:j]})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(expr) && isIdentByte(expr[j]) {
+				j++
+			}
+			tokens = append(tokens, token{typ: tokenNumber, text: expr[i:j]})
+			i = j
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(expr[i:], op) {
+					tokens = append(tokens, token{typ: tokenOp, text: op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected %q in filter expression", c)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return isDigit(c) || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{typ: tokenOp, text: "end"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); !p.done() && t.typ == tokenOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		return fmt.Errorf("expected %q in filter expression, got %q", op, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) parseOr(depth int) (node, error) {
+	if depth > maxExprDepth {
+		return nil, errors.New("filter expression nested too deeply")
+	}
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "||", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd(depth int) (node, error) {
+	left, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op: "&&", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	if p.accept("!") {
+		if depth+1 > maxExprDepth {
+			return nil, errors.New("filter expression nested too deeply")
+		}
+		operand, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	if p.accept("(") {
+		n, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); !p.done() && t.typ == tokenIdent && t.text == "in" {
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		var values []uint64
+		for {
+			value, err := p.parseNumber()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.accept(",") {
+				break
+			}
+		}
+		return inNode{operand: left, values: values}, p.expect(")")
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return binaryNode{op: op, left: left, right: right}, nil
+		}
+	}
+	// a field on its own, true when not 0
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.peek()
+	switch {
+	case p.done():
+		return nil, errors.New("unexpected end of filter expression")
+	case t.typ == tokenIdent:
+		fd, ok := fieldNames[strings.ToLower(t.text)]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q in filter expression", t.text)
+		}
+		p.pos++
+		return fieldNode{field: fd}, nil
+	case t.typ == tokenNumber:
+		value, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return constNode{value: value}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q in filter expression", t.text)
+	}
+}
+
+func (p *parser) parseNumber() (uint64, error) {
+	t := p.peek()
+	if p.done() || t.typ != tokenNumber {
+		return 0, fmt.Errorf("expected number in filter expression, got %q", t.text)
+	}
+	p.pos++
+	value, err := strconv.ParseUint(t.text, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q in filter expression", t.text)
+	}
+	return value, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pktfilter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompile(t *testing.T) {
+	f := Fields{SSRC: 0x1234, PayloadType: 111, SequenceNumber: 7, Size: 1200, Marker: true, Inbound: true}
+
+	for expr, expected := range map[string]bool{
+		"ssrc == 0x1234":                    true,
+		"ssrc != 4660":                      false,
+		"pt in (96, 111) && size > 1000":    true,
+		"pt in (96, 97)":                    false,
+		"!(size <= 1200)":                   false,
+		"marker && inbound":                 true,
+		"!inbound || seq >= 10":             false,
+		"(seq < 5 || seq > 6) && pt == 111": true,
+	} {
+		e, err := Compile(expr)
+		require.NoError(t, err, expr)
+		require.Equal(t, expected, e.Match(f), expr)
+		require.Equal(t, expr, e.String())
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"ssrc ==",
+		"bitrate > 5",
+		"pt in (96,",
+		"(ssrc == 1",
+		"ssrc == 1 ssrc",
+		"size > 99999999999999999999999",
+		"ssrc == $",
+		strings.Repeat("(", maxExprDepth+1) + "marker" + strings.Repeat(")", maxExprDepth+1),
+		strings.Repeat("!", maxExprDepth+1) + "marker",
+	} {
+		_, err := Compile(expr)
+		require.Error(t, err, expr)
+	}
+
+	_, err := Compile(strings.Repeat(" ", maxExprLen) + "marker")
+	require.ErrorIs(t, err, ErrExprTooLong)
+}
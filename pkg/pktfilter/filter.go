@@ -0,0 +1,256 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pktfilter
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+
+	lkinterceptor "github.com/livekit/mediatransportutil/pkg/interceptor"
+)
+
+type Action int
+
+const (
+	// packets are dropped from the forwarding path
+	ActionDrop Action = iota
+	// packets are delivered to the capture tap and forwarded
+	ActionCapture
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionDrop:
+		return "DROP"
+	case ActionCapture:
+		return "CAPTURE"
+	default:
+		return fmt.Sprintf("%d", int(a))
+	}
+}
+
+type RuleStats struct {
+	Name       string
+	Expr       string
+	Action     Action
+	NumMatches uint64
+}
+
+type rule struct {
+	name       string
+	expr       *Expr
+	action     Action
+	numMatches atomic.Uint64
+}
+
+// Filter applies rules to the RTP packets of the connections it creates interceptors for. Rules are added
+// and removed at runtime, for example from an admin endpoint during an incident. Without rules the cost on
+// the forwarding path is an atomic load.
+type Filter struct {
+	capture lkinterceptor.Tap
+
+	lock  sync.Mutex
+	rules atomic.Pointer[[]*rule]
+}
+
+// NewFilter creates a filter, captured packets are copied to capture, nil if rules never capture.
+func NewFilter(capture lkinterceptor.Tap) *Filter {
+	return &Filter{
+		capture: capture,
+	}
+}
+
+// AddRule compiles expr and applies it with action, replacing a rule of the same name.
+func (f *Filter) AddRule(name string, expr string, action Action) error {
+	if action != ActionDrop && action != ActionCapture {
+		return fmt.Errorf("unknown filter action %s", action)
+	}
+	if action == ActionCapture && f.capture == nil {
+		return fmt.Errorf("filter %s captures without a capture tap", name)
+	}
+	compiled, err := Compile(expr)
+	if err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var rules []*rule
+	if current := f.rules.Load(); current != nil {
+		for _, r := range *current {
+			if r.name != name {
+				rules = append(rules, r)
+			}
+		}
+	}
+	rules = append(rules, &rule{name: name, expr: compiled, action: action})
+	f.rules.Store(&rules)
+	return nil
+}
+
+func (f *Filter) RemoveRule(name string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	current := f.rules.Load()
+	if current == nil {
+		return
+	}
+	rules := make([]*rule, 0, len(*current))
+	for _, r := range *current {
+		if r.name != name {
+			rules = append(rules, r)
+		}
+	}
+	f.rules.Store(&rules)
+}
+
+func (f *Filter) Rules() []RuleStats {
+	current := f.rules.Load()
+	if current == nil {
+		return nil
+	}
+	stats := make([]RuleStats, 0, len(*current))
+	for _, r := range *current {
+		stats = append(stats, RuleStats{
+			Name:       r.name,
+			Expr:       r.expr.String(),
+			Action:     r.action,
+			NumMatches: r.numMatches.Load(),
+		})
+	}
+	return stats
+}
+
+// evaluate returns whether the packet is dropped and whether it is captured, every matching rule counts a match
+func (f *Filter) evaluate(fields Fields) (drop bool, capture bool) {
+	current := f.rules.Load()
+	if current == nil {
+		return false, false
+	}
+	for _, r := range *current {
+		if !r.expr.Match(fields) {
+			continue
+		}
+		r.numMatches.Add(1)
+		switch r.action {
+		case ActionDrop:
+			drop = true
+		case ActionCapture:
+			capture = true
+		}
+	}
+	return
+}
+
+func (f *Filter) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	return &filterInterceptor{
+		filter: f,
+		id:     id,
+	}, nil
+}
+
+// ------------------------------------------------
+
+type filterInterceptor struct {
+	interceptor.NoOp
+
+	filter *Filter
+	id     string
+}
+
+func (i *filterInterceptor) BindRemoteStream(info *interceptor.StreamInfo, reader interceptor.RTPReader) interceptor.RTPReader {
+	return interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		for {
+			n, attr, err := reader.Read(b, a)
+			if err != nil || i.filter.rules.Load() == nil {
+				return n, attr, err
+			}
+
+			var header *rtp.Header
+			var parseErr error
+			if attr != nil {
+				header, parseErr = attr.GetRTPHeader(b[:n])
+			} else {
+				header = &rtp.Header{}
+				_, parseErr = header.Unmarshal(b[:n])
+			}
+			if parseErr != nil {
+				return n, attr, err
+			}
+
+			drop, capture := i.filter.evaluate(fieldsOf(header, n, true))
+			if capture {
+				i.capture(lkinterceptor.TapDirectionInbound, info.SSRC, header, b[header.MarshalSize():n])
+			}
+			if !drop {
+				return n, attr, err
+			}
+
+			// the header parsed from the dropped packet is cached in the attributes
+			for k := range attr {
+				delete(attr, k)
+			}
+		}
+	})
+}
+
+func (i *filterInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if i.filter.rules.Load() == nil {
+			return writer.Write(header, payload, attributes)
+		}
+
+		size := header.MarshalSize() + len(payload)
+		drop, capture := i.filter.evaluate(fieldsOf(header, size, false))
+		if capture {
+			i.capture(lkinterceptor.TapDirectionOutbound, info.SSRC, header, payload)
+		}
+		if drop {
+			return size, nil
+		}
+		return writer.Write(header, payload, attributes)
+	})
+}
+
+func (i *filterInterceptor) capture(direction lkinterceptor.TapDirection, ssrc uint32, header *rtp.Header, payload []byte) {
+	clone := header.Clone()
+	i.filter.capture.OnPacket(&lkinterceptor.TapPacket{
+		ConnectionID: i.id,
+		Direction:    direction,
+		Time:         time.Now(),
+		SSRC:         ssrc,
+		Header:       &clone,
+		Payload:      append([]byte(nil), payload...),
+	})
+}
+
+func fieldsOf(header *rtp.Header, size int, inbound bool) Fields {
+	return Fields{
+		SSRC:           header.SSRC,
+		PayloadType:    header.PayloadType,
+		SequenceNumber: header.SequenceNumber,
+		Timestamp:      header.Timestamp,
+		Marker:         header.Marker,
+		Size:           size,
+		Inbound:        inbound,
+	}
+}
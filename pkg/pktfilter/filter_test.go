@@ -0,0 +1,117 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pktfilter
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	lkinterceptor "github.com/livekit/mediatransportutil/pkg/interceptor"
+)
+
+type testTap struct {
+	lock sync.Mutex
+	pkts []*lkinterceptor.TapPacket
+}
+
+func (t *testTap) OnPacket(pkt *lkinterceptor.TapPacket) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pkts = append(t.pkts, pkt)
+}
+
+func TestFilter(t *testing.T) {
+	tap := &testTap{}
+	f := NewFilter(tap)
+	i, err := f.NewInterceptor("pc")
+	require.NoError(t, err)
+
+	raw := make([][]byte, 0, 4)
+	for seq := uint16(0); seq < 4; seq++ {
+		pkt := rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1, SequenceNumber: seq, PayloadType: 96}, Payload: []byte{byte(seq)}}
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		raw = append(raw, b)
+	}
+	next := 0
+	reader := i.BindRemoteStream(&interceptor.StreamInfo{SSRC: 1}, interceptor.RTPReaderFunc(func(b []byte, a interceptor.Attributes) (int, interceptor.Attributes, error) {
+		n := copy(b, raw[next])
+		next++
+		return n, a, nil
+	}))
+	read := func() uint16 {
+		buf := make([]byte, 1500)
+		n, _, err := reader.Read(buf, interceptor.Attributes{})
+		require.NoError(t, err)
+		pkt := rtp.Packet{}
+		require.NoError(t, pkt.Unmarshal(buf[:n]))
+		return pkt.SequenceNumber
+	}
+
+	// no rules, everything passes
+	require.Equal(t, uint16(0), read())
+
+	require.ErrorContains(t, f.AddRule("bad", "ssrc ==", ActionDrop), "filter expression")
+	require.NoError(t, f.AddRule("odd", "seq in (1, 3)", ActionDrop))
+	require.NoError(t, f.AddRule("capture", "pt == 96", ActionCapture))
+
+	// seq 1 is dropped, seq 2 is read and captured
+	require.Equal(t, uint16(2), read())
+	require.Len(t, tap.pkts, 2)
+	require.Equal(t, uint16(1), tap.pkts[0].Header.SequenceNumber)
+	require.Equal(t, []byte{2}, tap.pkts[1].Payload)
+	require.Equal(t, lkinterceptor.TapDirectionInbound, tap.pkts[1].Direction)
+
+	rules := f.Rules()
+	require.Len(t, rules, 2)
+	require.Equal(t, "odd", rules[0].Name)
+	require.Equal(t, uint64(1), rules[0].NumMatches)
+	require.Equal(t, uint64(2), rules[1].NumMatches)
+
+	// outbound
+	var written []uint16
+	writer := i.BindLocalStream(&interceptor.StreamInfo{SSRC: 2}, interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, _ interceptor.Attributes) (int, error) {
+		written = append(written, header.SequenceNumber)
+		return header.MarshalSize() + len(payload), nil
+	}))
+	for seq := uint16(0); seq < 4; seq++ {
+		n, err := writer.Write(&rtp.Header{Version: 2, SSRC: 2, SequenceNumber: seq}, []byte{1}, nil)
+		require.NoError(t, err)
+		require.Equal(t, 13, n)
+	}
+	require.Equal(t, []uint16{0, 2}, written)
+
+	// removing the rule stops dropping
+	f.RemoveRule("odd")
+	require.Equal(t, uint16(3), read())
+	require.Len(t, f.Rules(), 1)
+}
+
+func TestFilterAddRule(t *testing.T) {
+	f := NewFilter(nil)
+	require.Error(t, f.AddRule("capture", "marker", ActionCapture))
+	require.Error(t, f.AddRule("unknown", "marker", Action(5)))
+
+	require.NoError(t, f.AddRule("drop", "marker", ActionDrop))
+	require.NoError(t, f.AddRule("drop", "!marker", ActionDrop))
+	rules := f.Rules()
+	require.Len(t, rules, 1)
+	require.Equal(t, "!marker", rules[0].Expr)
+}
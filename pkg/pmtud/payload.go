@@ -0,0 +1,176 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtud
+
+import (
+	"fmt"
+)
+
+const (
+	IPv4HeaderSize = 20
+	IPv6HeaderSize = 40
+	UDPHeaderSize  = 8
+
+	// TURN overhead when relaying, ChannelData is used once a channel is bound, Send indications before that
+	TURNChannelDataOverhead    = 4
+	TURNSendIndicationOverhead = 36
+
+	rtpFixedHeaderSize     = 12
+	rtpCSRCSize            = 4
+	rtpExtensionHeaderSize = 4
+
+	oneByteExtensionMaxSize = 16
+
+	// padding length is carried in the last byte of the payload
+	MaxRTPPaddingSize = 255
+)
+
+type SRTPProfile int
+
+const (
+	SRTPProfileNone SRTPProfile = iota
+	SRTPProfileAES128CMHMACSHA1_80
+	SRTPProfileAES128CMHMACSHA1_32
+	SRTPProfileAEADAES128GCM
+	SRTPProfileAEADAES256GCM
+)
+
+func (p SRTPProfile) String() string {
+	switch p {
+	case SRTPProfileNone:
+		return "NONE"
+	case SRTPProfileAES128CMHMACSHA1_80:
+		return "SRTP_AES128_CM_HMAC_SHA1_80"
+	case SRTPProfileAES128CMHMACSHA1_32:
+		return "SRTP_AES128_CM_HMAC_SHA1_32"
+	case SRTPProfileAEADAES128GCM:
+		return "SRTP_AEAD_AES_128_GCM"
+	case SRTPProfileAEADAES256GCM:
+		return "SRTP_AEAD_AES_256_GCM"
+	default:
+		return fmt.Sprintf("%d", int(p))
+	}
+}
+
+// AuthTagSize returns the bytes SRTP adds to each RTP packet.
+func (p SRTPProfile) AuthTagSize() int {
+	switch p {
+	case SRTPProfileAES128CMHMACSHA1_80:
+		return 10
+	case SRTPProfileAES128CMHMACSHA1_32:
+		return 4
+	case SRTPProfileAEADAES128GCM, SRTPProfileAEADAES256GCM:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// UDPPayloadSize returns the largest UDP payload that fits a link MTU without IP fragmentation.
+func UDPPayloadSize(linkMTU int, isIPv6 bool) int {
+	size := linkMTU - UDPHeaderSize
+	if isIPv6 {
+		size -= IPv6HeaderSize
+	} else {
+		size -= IPv4HeaderSize
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// HeaderExtensionSize returns the size of an RTP header extension block (RFC 8285) carrying
+// extensions with the given data sizes, including its header and padding, zero without extensions.
+// The two byte form is used when requested or when an extension is too large for the one byte form.
+func HeaderExtensionSize(extensionSizes []int, twoByte bool) int {
+	if len(extensionSizes) == 0 {
+		return 0
+	}
+	if !twoByte {
+		for _, size := range extensionSizes {
+			if size > oneByteExtensionMaxSize || size == 0 {
+				twoByte = true
+				break
+			}
+		}
+	}
+
+	elementHeaderSize := 1
+	if twoByte {
+		elementHeaderSize = 2
+	}
+	n := 0
+	for _, size := range extensionSizes {
+		n += elementHeaderSize + size
+	}
+	return rtpExtensionHeaderSize + (n+3)/4*4
+}
+
+// RTPHeaderSize returns the size of an RTP header with the given CSRCs and header extensions.
+func RTPHeaderSize(csrcCount int, extensionSizes []int, twoByte bool) int {
+	return rtpFixedHeaderSize + csrcCount*rtpCSRCSize + HeaderExtensionSize(extensionSizes, twoByte)
+}
+
+type PayloadSizeParams struct {
+	// largest UDP payload on the path, e.g. Prober.PMTU
+	PathMTU int
+	// added by relaying, e.g. TURNChannelDataOverhead
+	TURNOverhead int
+
+	SRTPProfile SRTPProfile
+	// SRTP master key identifier length, if negotiated
+	MKISize int
+
+	CSRCCount int
+	// data sizes of the header extensions sent with each packet
+	HeaderExtensionSizes []int
+	TwoByteExtensions    bool
+}
+
+// Overhead returns the bytes each RTP packet needs on top of its payload.
+func (p PayloadSizeParams) Overhead() int {
+	return p.TURNOverhead +
+		RTPHeaderSize(p.CSRCCount, p.HeaderExtensionSizes, p.TwoByteExtensions) +
+		p.SRTPProfile.AuthTagSize() + p.MKISize
+}
+
+// MaxPayloadSize returns the largest RTP payload which keeps packets within PathMTU, zero if none fits.
+func (p PayloadSizeParams) MaxPayloadSize() int {
+	size := p.PathMTU - p.Overhead()
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// PacketizerMTU returns the size to pass to a packetizer which reserves room for the fixed
+// RTP header itself (like pion rtp.Packetizer), but not for CSRCs, extensions, SRTP or TURN.
+func (p PayloadSizeParams) PacketizerMTU() int {
+	size := p.MaxPayloadSize()
+	if size == 0 {
+		return 0
+	}
+	return size + rtpFixedHeaderSize
+}
+
+// MaxPaddingSize returns the largest padding only payload, limited by the one byte padding length.
+func (p PayloadSizeParams) MaxPaddingSize() int {
+	size := p.MaxPayloadSize()
+	if size > MaxRTPPaddingSize {
+		return MaxRTPPaddingSize
+	}
+	return size
+}
@@ -0,0 +1,73 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtud
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTPHeaderSize(t *testing.T) {
+	for _, tc := range []struct {
+		sizes   []int
+		twoByte bool
+		// encoded with two byte headers
+		expectTwoByte bool
+	}{
+		{nil, false, false},
+		{[]int{1}, false, false},
+		{[]int{3, 2, 8}, false, false},
+		{[]int{3, 2, 8}, true, true},
+		{[]int{3, 20}, false, true},
+	} {
+		header := rtp.Header{Version: 2, CSRC: []uint32{1, 2}}
+		if len(tc.sizes) != 0 {
+			header.Extension = true
+			header.ExtensionProfile = 0xBEDE
+			if tc.expectTwoByte {
+				header.ExtensionProfile = 0x1000
+			}
+		}
+		for i, size := range tc.sizes {
+			require.NoError(t, header.SetExtension(uint8(i+1), make([]byte, size)))
+		}
+		require.Equal(t, header.MarshalSize(), RTPHeaderSize(2, tc.sizes, tc.twoByte), "sizes %v", tc.sizes)
+	}
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	require.Equal(t, 1472, UDPPayloadSize(1500, false))
+	require.Equal(t, 1452, UDPPayloadSize(1500, true))
+
+	params := PayloadSizeParams{
+		PathMTU:              1200,
+		TURNOverhead:         TURNChannelDataOverhead,
+		SRTPProfile:          SRTPProfileAES128CMHMACSHA1_80,
+		HeaderExtensionSizes: []int{2, 3},
+	}
+	// 4 TURN + 12 RTP + 12 extensions + 10 auth tag
+	require.Equal(t, 38, params.Overhead())
+	require.Equal(t, 1162, params.MaxPayloadSize())
+	require.Equal(t, 1174, params.PacketizerMTU())
+	require.Equal(t, MaxRTPPaddingSize, params.MaxPaddingSize())
+
+	params.PathMTU = 100
+	require.Equal(t, 62, params.MaxPaddingSize())
+	params.PathMTU = 20
+	require.Zero(t, params.MaxPayloadSize())
+	require.Zero(t, params.PacketizerMTU())
+}
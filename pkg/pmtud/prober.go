@@ -0,0 +1,487 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtud
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	messageTypeProbe = 1
+	messageTypeAck   = 2
+
+	probeHeaderSize = 1 + 4
+	ackSize         = 1 + 4 + 2
+
+	tickInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid path MTU probe message")
+)
+
+type State int
+
+const (
+	// confirming the path carries BasePMTU
+	StateBase State = iota
+	StateSearch
+	StateSearchComplete
+	// path does not carry BasePMTU, retried every ConfirmInterval
+	StateError
+)
+
+func (s State) String() string {
+	switch s {
+	case StateBase:
+		return "BASE"
+	case StateSearch:
+		return "SEARCH"
+	case StateSearchComplete:
+		return "SEARCH_COMPLETE"
+	case StateError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type ChangeReason int
+
+const (
+	ChangeReasonProbed ChangeReason = iota
+	// probes at the discovered size stopped getting through
+	ChangeReasonBlackHole
+	ChangeReasonPathChanged
+)
+
+func (r ChangeReason) String() string {
+	switch r {
+	case ChangeReasonProbed:
+		return "PROBED"
+	case ChangeReasonBlackHole:
+		return "BLACK_HOLE"
+	case ChangeReasonPathChanged:
+		return "PATH_CHANGED"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type MTUChange struct {
+	Previous int
+	Current  int
+	Reason   ChangeReason
+}
+
+type ProberParams struct {
+	// sizes are of the datagram passed to send, probing starts at BasePMTU and never goes above MaxPMTU
+	BasePMTU int
+	MaxPMTU  int
+	// search stops when the gap between the largest acknowledged and smallest failed size is at most this
+	SearchGranularity int
+	// a probe without an ack for this long is lost, a size fails after MaxProbes lost probes
+	ProbeTimeout time.Duration
+	MaxProbes    int
+	// interval of probes at the discovered size to detect black holes
+	ConfirmInterval time.Duration
+	// interval of new searches for a larger size once search is complete
+	RaiseInterval time.Duration
+}
+
+var ProberParamsDefault = ProberParams{
+	BasePMTU:          1200,
+	MaxPMTU:           1472,
+	SearchGranularity: 16,
+	ProbeTimeout:      time.Second,
+	MaxProbes:         3,
+	ConfirmInterval:   30 * time.Second,
+	RaiseInterval:     10 * time.Minute,
+}
+
+type ProberStats struct {
+	State       State
+	PMTU        int
+	NumProbes   int
+	NumAcked    int
+	NumLost     int
+	NumChanges  int
+	NumSearches int
+}
+
+// Prober runs datagram packetization layer path MTU discovery (RFC 8899) over an established path,
+// for example the selected ICE candidate pair. Probes are padded to the size being tested and must
+// be sent unfragmented, acks are small. Both sides run a Prober and pass every received message
+// to HandleMessage, probes are acknowledged automatically.
+//
+// PMTU starts at BasePMTU and is raised by binary search towards MaxPMTU. Once search completes,
+// the size is confirmed every ConfirmInterval, falling back to BasePMTU when probes at the size are
+// lost, and searched again for a larger size every RaiseInterval. Call PathChanged when the path,
+// e.g. the selected candidate pair, changes to search again from BasePMTU.
+//
+// Message format, big endian
+//
+//	probe: type (1) | id (4) | padding up to the probed size
+//	ack:   type (2) | id (4) | probed size (2)
+type Prober struct {
+	params ProberParams
+	send   func(data []byte) error
+
+	lock sync.Mutex
+	// largest acknowledged and smallest failed size of the current search
+	acked  int
+	failed int
+
+	state     State
+	pmtu      int
+	stats     ProberStats
+	nextID    uint32
+	probe     *probe
+	nextProbe time.Time
+	nextRaise time.Time
+	onChange  func(change MTUChange)
+	isStopped bool
+
+	close chan struct{}
+}
+
+type probe struct {
+	id     uint32
+	size   int
+	sentAt time.Time
+	// probes sent at this size so far
+	count int
+}
+
+func NewProber(params ProberParams, send func(data []byte) error) *Prober {
+	if params.BasePMTU <= 0 {
+		params.BasePMTU = ProberParamsDefault.BasePMTU
+	}
+	if params.BasePMTU < ackSize {
+		params.BasePMTU = ackSize
+	}
+	if params.MaxPMTU <= 0 {
+		params.MaxPMTU = ProberParamsDefault.MaxPMTU
+	}
+	if params.MaxPMTU < params.BasePMTU {
+		params.MaxPMTU = params.BasePMTU
+	}
+	if params.SearchGranularity <= 0 {
+		params.SearchGranularity = ProberParamsDefault.SearchGranularity
+	}
+	if params.ProbeTimeout <= 0 {
+		params.ProbeTimeout = ProberParamsDefault.ProbeTimeout
+	}
+	if params.MaxProbes <= 0 {
+		params.MaxProbes = ProberParamsDefault.MaxProbes
+	}
+	if params.ConfirmInterval <= 0 {
+		params.ConfirmInterval = ProberParamsDefault.ConfirmInterval
+	}
+	if params.RaiseInterval <= 0 {
+		params.RaiseInterval = ProberParamsDefault.RaiseInterval
+	}
+	p := &Prober{
+		params: params,
+		send:   send,
+		pmtu:   params.BasePMTU,
+		close:  make(chan struct{}),
+	}
+	p.resetLocked()
+	return p
+}
+
+func (p *Prober) OnChange(f func(change MTUChange)) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.onChange = f
+}
+
+func (p *Prober) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isStopped {
+		return
+	}
+	go p.worker()
+}
+
+func (p *Prober) Stop() {
+	p.lock.Lock()
+	if p.isStopped {
+		p.lock.Unlock()
+		return
+	}
+
+	close(p.close)
+	p.isStopped = true
+	p.lock.Unlock()
+}
+
+// PMTU returns the largest datagram size known to get through the path, BasePMTU until a larger one is confirmed.
+func (p *Prober) PMTU() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.pmtu
+}
+
+func (p *Prober) State() State {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.state
+}
+
+func (p *Prober) Stats() ProberStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	stats := p.stats
+	stats.State = p.state
+	stats.PMTU = p.pmtu
+	return stats
+}
+
+// PathChanged restarts discovery from BasePMTU, the discovered size may not hold on the new path.
+func (p *Prober) PathChanged() {
+	p.pathChanged(time.Now())
+}
+
+func (p *Prober) HandleMessage(data []byte) error {
+	return p.handleMessage(data, time.Now())
+}
+
+func (p *Prober) pathChanged(now time.Time) {
+	p.lock.Lock()
+	p.resetLocked()
+	p.nextProbe = now
+	onChange, change := p.setPMTULocked(p.params.BasePMTU, ChangeReasonPathChanged)
+	p.lock.Unlock()
+
+	if onChange != nil {
+		onChange(change)
+	}
+}
+
+func (p *Prober) handleMessage(data []byte, now time.Time) error {
+	if len(data) == 0 {
+		return ErrInvalidMessage
+	}
+
+	switch data[0] {
+	case messageTypeProbe:
+		if len(data) < probeHeaderSize || len(data) > 0xffff {
+			return ErrInvalidMessage
+		}
+		ack := make([]byte, ackSize)
+		ack[0] = messageTypeAck
+		copy(ack[1:], data[1:probeHeaderSize])
+		binary.BigEndian.PutUint16(ack[5:], uint16(len(data)))
+		return p.send(ack)
+
+	case messageTypeAck:
+		if len(data) < ackSize {
+			return ErrInvalidMessage
+		}
+		p.handleAck(binary.BigEndian.Uint32(data[1:]), int(binary.BigEndian.Uint16(data[5:])), now)
+		return nil
+
+	default:
+		return ErrInvalidMessage
+	}
+}
+
+func (p *Prober) handleAck(id uint32, size int, now time.Time) {
+	p.lock.Lock()
+	// a late ack of an earlier probe still proves its size got through, but only the
+	// outstanding probe drives the search, so stale acks are ignored
+	if p.probe == nil || p.probe.id != id || p.probe.size != size {
+		p.lock.Unlock()
+		return
+	}
+	p.probe = nil
+	p.stats.NumAcked++
+
+	var onChange func(change MTUChange)
+	var change MTUChange
+	switch p.state {
+	case StateBase, StateError:
+		p.acked = size
+		p.state = StateSearch
+		p.stats.NumSearches++
+		onChange, change = p.setPMTULocked(size, ChangeReasonProbed)
+		p.nextProbe = now
+		p.searchDoneLocked(now)
+
+	case StateSearch:
+		p.acked = size
+		onChange, change = p.setPMTULocked(size, ChangeReasonProbed)
+		p.nextProbe = now
+		p.searchDoneLocked(now)
+
+	case StateSearchComplete:
+		// confirmed
+		p.nextProbe = now.Add(p.params.ConfirmInterval)
+	}
+	p.lock.Unlock()
+
+	if onChange != nil {
+		onChange(change)
+	}
+}
+
+// tick expires the outstanding probe and sends the next one when due.
+func (p *Prober) tick(now time.Time) error {
+	p.lock.Lock()
+	onChange, change := p.expireLocked(now)
+
+	var data []byte
+	if p.probe == nil && !now.Before(p.nextProbe) {
+		if p.state == StateSearchComplete && !now.Before(p.nextRaise) && p.pmtu < p.params.MaxPMTU {
+			p.state = StateSearch
+			p.stats.NumSearches++
+			p.acked = p.pmtu
+			p.failed = p.params.MaxPMTU + 1
+		}
+
+		size := p.pmtu
+		if p.state == StateSearch {
+			size = p.nextSearchSizeLocked()
+		}
+		data = p.newProbeLocked(size, 1, now)
+	} else if p.probe != nil && p.probe.sentAt.IsZero() {
+		// retry of a lost probe
+		data = p.newProbeLocked(p.probe.size, p.probe.count, now)
+	}
+	p.lock.Unlock()
+
+	if onChange != nil {
+		onChange(change)
+	}
+	if data != nil {
+		return p.send(data)
+	}
+	return nil
+}
+
+func (p *Prober) expireLocked(now time.Time) (func(change MTUChange), MTUChange) {
+	if p.probe == nil || p.probe.sentAt.IsZero() || now.Sub(p.probe.sentAt) < p.params.ProbeTimeout {
+		return nil, MTUChange{}
+	}
+	p.stats.NumLost++
+
+	if p.probe.count < p.params.MaxProbes {
+		p.probe.count++
+		p.probe.sentAt = time.Time{}
+		return nil, MTUChange{}
+	}
+
+	size := p.probe.size
+	p.probe = nil
+	switch p.state {
+	case StateBase:
+		p.state = StateError
+		p.nextProbe = now.Add(p.params.ConfirmInterval)
+
+	case StateError:
+		p.nextProbe = now.Add(p.params.ConfirmInterval)
+
+	case StateSearch:
+		p.failed = size
+		p.nextProbe = now
+		p.searchDoneLocked(now)
+
+	case StateSearchComplete:
+		p.resetLocked()
+		p.nextProbe = now
+		return p.setPMTULocked(p.params.BasePMTU, ChangeReasonBlackHole)
+	}
+	return nil, MTUChange{}
+}
+
+func (p *Prober) searchDoneLocked(now time.Time) {
+	if p.failed-p.acked > p.params.SearchGranularity && p.acked < p.params.MaxPMTU {
+		return
+	}
+	p.state = StateSearchComplete
+	p.nextProbe = now.Add(p.params.ConfirmInterval)
+	p.nextRaise = now.Add(p.params.RaiseInterval)
+}
+
+func (p *Prober) nextSearchSizeLocked() int {
+	// try the maximum first, most paths carry it
+	if p.failed > p.params.MaxPMTU {
+		return p.params.MaxPMTU
+	}
+	return (p.acked + p.failed) / 2
+}
+
+func (p *Prober) newProbeLocked(size int, count int, now time.Time) []byte {
+	p.nextID++
+	p.probe = &probe{
+		id:     p.nextID,
+		size:   size,
+		sentAt: now,
+		count:  count,
+	}
+	p.stats.NumProbes++
+
+	data := make([]byte, size)
+	data[0] = messageTypeProbe
+	binary.BigEndian.PutUint32(data[1:], p.nextID)
+	return data
+}
+
+func (p *Prober) resetLocked() {
+	p.state = StateBase
+	p.acked = 0
+	p.failed = p.params.MaxPMTU + 1
+	p.probe = nil
+}
+
+func (p *Prober) setPMTULocked(pmtu int, reason ChangeReason) (func(change MTUChange), MTUChange) {
+	if p.pmtu == pmtu {
+		return nil, MTUChange{}
+	}
+	change := MTUChange{
+		Previous: p.pmtu,
+		Current:  pmtu,
+		Reason:   reason,
+	}
+	p.pmtu = pmtu
+	p.stats.NumChanges++
+	return p.onChange, change
+}
+
+func (p *Prober) worker() {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	_ = p.tick(time.Now())
+	for {
+		select {
+		case <-ticker.C:
+			_ = p.tick(time.Now())
+		case <-p.close:
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pmtud
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// path delivers datagrams up to limit bytes between two probers synchronously
+type path struct {
+	limit int
+	now   time.Time
+
+	local  *Prober
+	remote *Prober
+}
+
+func newPath(t *testing.T, params ProberParams, limit int) *path {
+	p := &path{limit: limit, now: time.Now()}
+	p.local = NewProber(params, func(data []byte) error {
+		if len(data) <= p.limit {
+			require.NoError(t, p.remote.handleMessage(data, p.now))
+		}
+		return nil
+	})
+	p.remote = NewProber(params, func(data []byte) error {
+		if len(data) <= p.limit {
+			require.NoError(t, p.local.handleMessage(data, p.now))
+		}
+		return nil
+	})
+	return p
+}
+
+// run ticks the local prober for d
+func (p *path) run(t *testing.T, d time.Duration) {
+	end := p.now.Add(d)
+	for ; !p.now.After(end); p.now = p.now.Add(tickInterval) {
+		require.NoError(t, p.local.tick(p.now))
+	}
+}
+
+func TestProberSearch(t *testing.T) {
+	p := newPath(t, ProberParams{ProbeTimeout: time.Second, MaxProbes: 2}, 1400)
+	var changes []MTUChange
+	p.local.OnChange(func(change MTUChange) {
+		changes = append(changes, change)
+	})
+	require.Equal(t, 1200, p.local.PMTU())
+
+	p.run(t, time.Minute)
+	require.Equal(t, StateSearchComplete, p.local.State())
+	pmtu := p.local.PMTU()
+	require.LessOrEqual(t, pmtu, 1400)
+	require.Greater(t, pmtu, 1400-ProberParamsDefault.SearchGranularity)
+	require.NotEmpty(t, changes)
+	require.Equal(t, 1200, changes[0].Previous)
+	require.Equal(t, pmtu, changes[len(changes)-1].Current)
+	for _, change := range changes {
+		require.Equal(t, ChangeReasonProbed, change.Reason)
+	}
+
+	stats := p.local.Stats()
+	require.Equal(t, 1, stats.NumSearches)
+	require.NotZero(t, stats.NumLost)
+
+	// path MTU drops, confirmation probes are lost and discovery restarts from base
+	changes = nil
+	p.limit = 1300
+	p.run(t, 2*time.Minute)
+	require.Equal(t, StateSearchComplete, p.local.State())
+	require.Equal(t, ChangeReasonBlackHole, changes[0].Reason)
+	require.Equal(t, 1200, changes[0].Current)
+	require.LessOrEqual(t, p.local.PMTU(), 1300)
+	require.Greater(t, p.local.PMTU(), 1300-ProberParamsDefault.SearchGranularity)
+}
+
+func TestProberMax(t *testing.T) {
+	p := newPath(t, ProberParams{}, 9000)
+	p.run(t, time.Second)
+	require.Equal(t, StateSearchComplete, p.local.State())
+	require.Equal(t, ProberParamsDefault.MaxPMTU, p.local.PMTU())
+	require.Equal(t, 2, p.local.Stats().NumProbes)
+}
+
+func TestProberPathChanged(t *testing.T) {
+	p := newPath(t, ProberParams{RaiseInterval: time.Minute}, 1300)
+	p.run(t, 30*time.Second)
+	require.Equal(t, StateSearchComplete, p.local.State())
+	require.Greater(t, p.local.PMTU(), 1200)
+
+	var changes []MTUChange
+	p.local.OnChange(func(change MTUChange) {
+		changes = append(changes, change)
+	})
+
+	// new path carries more, found again after the change
+	p.limit = 9000
+	p.local.pathChanged(p.now)
+	require.Equal(t, StateBase, p.local.State())
+	require.Equal(t, 1200, p.local.PMTU())
+	p.run(t, time.Second)
+	require.Equal(t, ProberParamsDefault.MaxPMTU, p.local.PMTU())
+	require.Len(t, changes, 2)
+	require.Equal(t, ChangeReasonPathChanged, changes[0].Reason)
+
+	require.ErrorIs(t, p.local.HandleMessage([]byte{messageTypeAck, 0}), ErrInvalidMessage)
+	require.ErrorIs(t, p.local.HandleMessage([]byte{9}), ErrInvalidMessage)
+}
+
+func TestProberBaseUnreachable(t *testing.T) {
+	p := newPath(t, ProberParams{ProbeTimeout: time.Second, MaxProbes: 2, ConfirmInterval: 10 * time.Second}, 1000)
+	p.run(t, 5*time.Second)
+	require.Equal(t, StateError, p.local.State())
+	require.Equal(t, 1200, p.local.PMTU())
+
+	p.limit = 1500
+	p.run(t, 10*time.Second)
+	require.Equal(t, StateSearchComplete, p.local.State())
+	require.Equal(t, ProberParamsDefault.MaxPMTU, p.local.PMTU())
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := NewTokenBucket(2, 3)
+	require.Equal(t, 2.0, b.Rate())
+	require.Equal(t, 3.0, b.Burst())
+
+	// full burst, then empty
+	for i := 0; i < 3; i++ {
+		require.True(t, b.AllowAt(now, 1))
+	}
+	require.False(t, b.AllowAt(now, 1))
+
+	// one token every 500ms, refilled to the nanosecond
+	require.False(t, b.AllowAt(now.Add(500*time.Millisecond-time.Nanosecond), 1))
+	require.True(t, b.AllowAt(now.Add(500*time.Millisecond), 1))
+
+	// many small refills add up exactly
+	for i := 1; i <= 1000; i++ {
+		b.TokensAt(now.Add(500*time.Millisecond + time.Duration(i)*500*time.Microsecond))
+	}
+	require.Equal(t, 1.0, b.TokensAt(now.Add(time.Second)))
+
+	delay, ok := b.DelayAt(now.Add(time.Second), 2)
+	require.True(t, ok)
+	require.Equal(t, 500*time.Millisecond, delay)
+	_, ok = b.DelayAt(now.Add(time.Second), 4)
+	require.False(t, ok)
+
+	// capped at the burst
+	require.Equal(t, 3.0, b.TokensAt(now.Add(time.Hour)))
+
+	// time going backwards does not refill
+	require.True(t, b.AllowAt(now.Add(time.Hour), 3))
+	require.False(t, b.AllowAt(now, 1))
+
+	// a lower burst drops tokens
+	b.SetRate(1000, 1500)
+	require.True(t, b.AllowAt(now.Add(time.Hour+time.Second), 900))
+	b.SetRate(1000, 100)
+	require.Equal(t, 100.0, b.TokensAt(now.Add(time.Hour+time.Second)))
+}
+
+func TestSlidingWindow(t *testing.T) {
+	start := time.Now()
+	w := NewSlidingWindow(10, time.Second)
+	require.Equal(t, 10, w.Limit())
+	require.Equal(t, time.Second, w.Window())
+
+	require.True(t, w.AllowAt(start, 10))
+	require.False(t, w.AllowAt(start.Add(999*time.Millisecond), 1))
+
+	// a quarter into the next window three quarters of the previous one still count
+	require.InDelta(t, 7.5, w.CountAt(start.Add(1250*time.Millisecond)), 1e-9)
+	require.True(t, w.AllowAt(start.Add(1250*time.Millisecond), 2))
+	require.False(t, w.AllowAt(start.Add(1250*time.Millisecond), 1))
+	require.True(t, w.AllowAt(start.Add(1500*time.Millisecond), 3))
+
+	// after two idle windows nothing counts
+	require.Equal(t, 0.0, w.CountAt(start.Add(3100*time.Millisecond)))
+	require.True(t, w.AllowAt(start.Add(3100*time.Millisecond), 10))
+}
+
+func BenchmarkTokenBucket(b *testing.B) {
+	bucket := NewTokenBucket(1e9, 1e6)
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bucket.AllowAt(now.Add(time.Duration(i)), 1)
+	}
+}
+
+func BenchmarkTokenBucketPerSource(b *testing.B) {
+	buckets := make(map[uint32]*TokenBucket, 1024)
+	for i := uint32(0); i < 1024; i++ {
+		bucket := NewTokenBucket(2, 10)
+		buckets[i] = &bucket
+	}
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buckets[uint32(i)%1024].AllowAt(now.Add(time.Duration(i)), 1)
+	}
+}
+
+func BenchmarkSlidingWindow(b *testing.B) {
+	w := NewSlidingWindow(1e9, time.Second)
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.AllowAt(now.Add(time.Duration(i)), 1)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"time"
+)
+
+// SlidingWindow allows up to limit events in any window, approximated from the counts of the current and
+// the previous fixed window, weighted by how much of the previous window the sliding window still covers.
+// Unlike a TokenBucket it does not allow a burst of the full limit right after a busy window.
+//
+// A SlidingWindow is not safe for concurrent use, it is meant to be guarded by the lock of the state it limits.
+type SlidingWindow struct {
+	limit    int64
+	window   time.Duration
+	start    time.Time
+	current  int64
+	previous int64
+}
+
+func NewSlidingWindow(limit int, window time.Duration) SlidingWindow {
+	return SlidingWindow{
+		limit:  int64(limit),
+		window: window,
+	}
+}
+
+func (w *SlidingWindow) Limit() int {
+	return int(w.limit)
+}
+
+func (w *SlidingWindow) Window() time.Duration {
+	return w.window
+}
+
+// Allow counts n events if they fit in the limit.
+func (w *SlidingWindow) Allow(n int) bool {
+	return w.AllowAt(time.Now(), n)
+}
+
+// AllowAt counts n events at now if they fit in the limit.
+func (w *SlidingWindow) AllowAt(now time.Time, n int) bool {
+	w.advance(now)
+
+	if w.estimate(now)+float64(n) > float64(w.limit) {
+		return false
+	}
+	w.current += int64(n)
+	return true
+}
+
+// CountAt returns the estimated number of events in the window ending at now.
+func (w *SlidingWindow) CountAt(now time.Time) float64 {
+	w.advance(now)
+	return w.estimate(now)
+}
+
+func (w *SlidingWindow) advance(now time.Time) {
+	if w.start.IsZero() || w.window <= 0 {
+		w.start = now
+		return
+	}
+	elapsed := now.Sub(w.start)
+	if elapsed < w.window {
+		return
+	}
+
+	windows := elapsed / w.window
+	if windows == 1 {
+		w.previous = w.current
+	} else {
+		w.previous = 0
+	}
+	w.current = 0
+	w.start = w.start.Add(windows * w.window)
+}
+
+func (w *SlidingWindow) estimate(now time.Time) float64 {
+	if w.window <= 0 {
+		return float64(w.current)
+	}
+	into := now.Sub(w.start)
+	if into < 0 {
+		into = 0
+	}
+	previousShare := float64(w.window-into) / float64(w.window)
+	return float64(w.previous)*previousShare + float64(w.current)
+}
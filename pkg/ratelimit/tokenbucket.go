@@ -0,0 +1,125 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// tokens are counted in billionths, at a rate of one token per second a nanosecond refills one unit
+	tokenScale = float64(time.Second)
+)
+
+// TokenBucket allows a rate of tokens per second with bursts of up to burst tokens, for example packets,
+// bytes or handshakes. Tokens are counted in integer billionths and time in nanoseconds, so refills are exact
+// at any call rate, bursts of up to about 9e9 tokens can be represented.
+//
+// A TokenBucket is not safe for concurrent use, it is meant to be embedded in the state it limits and guarded
+// by that state's lock. It is small enough to keep one per source in a map.
+type TokenBucket struct {
+	rate   float64
+	burst  int64
+	tokens int64
+	last   time.Time
+}
+
+// NewTokenBucket returns a full bucket.
+func NewTokenBucket(rate float64, burst float64) TokenBucket {
+	b := TokenBucket{}
+	b.SetRate(rate, burst)
+	b.tokens = b.burst
+	return b
+}
+
+// SetRate changes the rate and burst, tokens above the new burst are dropped.
+func (b *TokenBucket) SetRate(rate float64, burst float64) {
+	b.rate = math.Max(rate, 0)
+	b.burst = scale(math.Max(burst, 0))
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+func (b *TokenBucket) Rate() float64 {
+	return b.rate
+}
+
+func (b *TokenBucket) Burst() float64 {
+	return float64(b.burst) / tokenScale
+}
+
+// Allow takes n tokens if they are available.
+func (b *TokenBucket) Allow(n float64) bool {
+	return b.AllowAt(time.Now(), n)
+}
+
+// AllowAt takes n tokens if they are available at now.
+func (b *TokenBucket) AllowAt(now time.Time, n float64) bool {
+	b.refill(now)
+
+	needed := scale(n)
+	if b.tokens < needed {
+		return false
+	}
+	b.tokens -= needed
+	return true
+}
+
+// TokensAt returns the tokens available at now.
+func (b *TokenBucket) TokensAt(now time.Time) float64 {
+	b.refill(now)
+	return float64(b.tokens) / tokenScale
+}
+
+// DelayAt returns how long after now n tokens are available, false if they never are because n is above the burst
+// or the rate is zero.
+func (b *TokenBucket) DelayAt(now time.Time, n float64) (time.Duration, bool) {
+	b.refill(now)
+
+	needed := scale(n)
+	if b.tokens >= needed {
+		return 0, true
+	}
+	if needed > b.burst || b.rate == 0 {
+		return 0, false
+	}
+	return time.Duration(math.Ceil(float64(needed-b.tokens) / b.rate)), true
+}
+
+func (b *TokenBucket) refill(now time.Time) {
+	if b.last.IsZero() {
+		b.last = now
+		return
+	}
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.last = now
+
+	if added := float64(elapsed) * b.rate; added >= float64(b.burst-b.tokens) {
+		b.tokens = b.burst
+	} else {
+		b.tokens += int64(added)
+	}
+}
+
+// ------------------------------------------------
+
+func scale(tokens float64) int64 {
+	return int64(math.Round(tokens * tokenScale))
+}
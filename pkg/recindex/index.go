@@ -0,0 +1,325 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	indexMagic   = "LKIX"
+	indexVersion = 1
+
+	entryFlagKeyFrame = 1 << 0
+	// the record is a gap rather than an entry
+	entryFlagGap = 1 << 1
+)
+
+var (
+	ErrInvalidIndex  = errors.New("invalid recording index")
+	ErrOffsetReverse = errors.New("index offset before previous entry")
+)
+
+// Entry maps a position in a media file to the RTP timestamp and the wall clock time of the media there.
+type Entry struct {
+	// byte offset in the media file of the packet or frame
+	Offset int64
+	// RTP timestamp, extended to 64 bit by the writer so that it keeps increasing across wrap around
+	Timestamp uint64
+	// wall clock time of the media, at microsecond precision, e.g. from the sender report mapping
+	At         time.Time
+	IsKeyFrame bool
+}
+
+// Gap is a run of packets missing from a media file, lost or not yet retransmitted when the media after them
+// was written. A repair pass inserts them once they are recovered, see Repair.
+type Gap struct {
+	// byte offset in the media file the missing packets belong at
+	Offset  int64
+	FirstSN uint16
+	Count   int
+}
+
+type WriterParams struct {
+	SSRC      uint32
+	ClockRate uint32
+	// entries other than key frames within this long of the previous written entry are skipped, so that
+	// the index stays small, 0 writes all
+	MinInterval time.Duration
+}
+
+var WriterParamsDefault = WriterParams{
+	MinInterval: 100 * time.Millisecond,
+}
+
+// Writer writes the index of a media file, alongside the file, so that tools can seek precisely and align
+// the recordings of several tracks on wall clock time. The media file writer adds an entry as it writes a
+// packet or frame, and a gap where packets are missing. Writing stops at the first error, returned by every
+// later call.
+//
+// The format is a header followed by one record per entry, with offsets, timestamps and times as varint
+// deltas to the previous entry, see ReadIndex.
+type Writer struct {
+	params WriterParams
+	w      io.Writer
+
+	buf        []byte
+	err        error
+	hasHeader  bool
+	hasEntry   bool
+	lastOffset int64
+	lastTS     uint32
+	lastExtTS  uint64
+	lastAt     time.Time
+	lastMicros int64
+}
+
+func NewWriter(w io.Writer, params WriterParams) *Writer {
+	return &Writer{
+		params: params,
+		w:      w,
+	}
+}
+
+// AddGap records count packets from firstSN missing at offset, where the media after them starts.
+// Offsets must not decrease, also between entries and gaps.
+func (w *Writer) AddGap(offset int64, firstSN uint16, count int) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.hasHeader && offset < w.lastOffset {
+		return fmt.Errorf("%w: %d < %d", ErrOffsetReverse, offset, w.lastOffset)
+	}
+	if count <= 0 {
+		return nil
+	}
+
+	b := w.appendHeader(w.buf[:0])
+	b = append(b, entryFlagGap)
+	b = binary.AppendUvarint(b, uint64(offset-w.lastOffset))
+	b = binary.AppendUvarint(b, uint64(firstSN))
+	b = binary.AppendUvarint(b, uint64(count))
+	w.buf = b
+
+	if _, w.err = w.w.Write(b); w.err != nil {
+		return w.err
+	}
+	w.hasHeader = true
+	w.lastOffset = offset
+	return nil
+}
+
+// Add indexes the media at offset with the RTP timestamp ts, at wall clock time at.
+// Offsets must not decrease.
+func (w *Writer) Add(offset int64, ts uint32, at time.Time, isKeyFrame bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.hasHeader && offset < w.lastOffset {
+		return fmt.Errorf("%w: %d < %d", ErrOffsetReverse, offset, w.lastOffset)
+	}
+
+	extTS := uint64(ts)
+	if w.hasEntry {
+		extTS = uint64(int64(w.lastExtTS) + int64(int32(ts-w.lastTS)))
+		if !isKeyFrame && at.Sub(w.lastAt) < w.params.MinInterval {
+			return nil
+		}
+	}
+
+	b := w.appendHeader(w.buf[:0])
+	var flags byte
+	if isKeyFrame {
+		flags |= entryFlagKeyFrame
+	}
+	micros := at.UnixMicro()
+	b = append(b, flags)
+	b = binary.AppendUvarint(b, uint64(offset-w.lastOffset))
+	b = binary.AppendVarint(b, int64(extTS-w.lastExtTS))
+	b = binary.AppendVarint(b, micros-w.lastMicros)
+	w.buf = b
+
+	if _, w.err = w.w.Write(b); w.err != nil {
+		return w.err
+	}
+	w.hasHeader = true
+	w.hasEntry = true
+	w.lastOffset = offset
+	w.lastTS = ts
+	w.lastExtTS = extTS
+	w.lastAt = at
+	w.lastMicros = micros
+	return nil
+}
+
+func (w *Writer) appendHeader(b []byte) []byte {
+	if w.hasHeader {
+		return b
+	}
+	b = append(b, indexMagic...)
+	b = append(b, indexVersion)
+	b = binary.AppendUvarint(b, uint64(w.params.SSRC))
+	return binary.AppendUvarint(b, uint64(w.params.ClockRate))
+}
+
+// ------------------------------------------------
+
+// Index is a recording index read with ReadIndex. Entries and gaps are in file order.
+type Index struct {
+	SSRC      uint32
+	ClockRate uint32
+	Entries   []Entry
+	Gaps      []Gap
+}
+
+// ReadIndex reads an index written by a Writer. An index truncated within an entry, e.g. by a crash
+// while recording, returns the complete entries with ErrInvalidIndex.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+
+	header := make([]byte, len(indexMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIndex, err)
+	}
+	if string(header[:len(indexMagic)]) != indexMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidIndex)
+	}
+	if header[len(indexMagic)] != indexVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidIndex, header[len(indexMagic)])
+	}
+	ssrc, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIndex, err)
+	}
+	clockRate, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIndex, err)
+	}
+
+	index := &Index{
+		SSRC:      uint32(ssrc),
+		ClockRate: uint32(clockRate),
+	}
+	var offset int64
+	var ts uint64
+	var micros int64
+	for {
+		flags, err := br.ReadByte()
+		if err == io.EOF {
+			return index, nil
+		}
+		if err != nil {
+			return index, err
+		}
+
+		offsetDelta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return index, fmt.Errorf("%w: truncated entry: %v", ErrInvalidIndex, err)
+		}
+		if flags&entryFlagGap != 0 {
+			firstSN, err := binary.ReadUvarint(br)
+			if err != nil {
+				return index, fmt.Errorf("%w: truncated gap: %v", ErrInvalidIndex, err)
+			}
+			count, err := binary.ReadUvarint(br)
+			if err != nil {
+				return index, fmt.Errorf("%w: truncated gap: %v", ErrInvalidIndex, err)
+			}
+			offset += int64(offsetDelta)
+			index.Gaps = append(index.Gaps, Gap{
+				Offset:  offset,
+				FirstSN: uint16(firstSN),
+				Count:   int(count),
+			})
+			continue
+		}
+		tsDelta, err := binary.ReadVarint(br)
+		if err != nil {
+			return index, fmt.Errorf("%w: truncated entry: %v", ErrInvalidIndex, err)
+		}
+		microsDelta, err := binary.ReadVarint(br)
+		if err != nil {
+			return index, fmt.Errorf("%w: truncated entry: %v", ErrInvalidIndex, err)
+		}
+
+		offset += int64(offsetDelta)
+		ts += uint64(tsDelta)
+		micros += microsDelta
+		index.Entries = append(index.Entries, Entry{
+			Offset:     offset,
+			Timestamp:  ts,
+			At:         time.UnixMicro(micros),
+			IsKeyFrame: flags&entryFlagKeyFrame != 0,
+		})
+	}
+}
+
+// SeekTime returns the last key frame at or before t, to start playback from at t, false if there is none.
+func (x *Index) SeekTime(t time.Time) (Entry, bool) {
+	i := sort.Search(len(x.Entries), func(i int) bool { return x.Entries[i].At.After(t) })
+	return x.lastKeyFrameBefore(i)
+}
+
+// SeekTimestamp returns the last key frame at or before the extended RTP timestamp ts, false if there is none.
+func (x *Index) SeekTimestamp(ts uint64) (Entry, bool) {
+	i := sort.Search(len(x.Entries), func(i int) bool { return x.Entries[i].Timestamp > ts })
+	return x.lastKeyFrameBefore(i)
+}
+
+// WallclockAt returns the wall clock time of the extended RTP timestamp ts, from the nearest entry at or before it,
+// or the first entry for earlier timestamps, false if the index is empty or has no clock rate.
+func (x *Index) WallclockAt(ts uint64) (time.Time, bool) {
+	if len(x.Entries) == 0 || x.ClockRate == 0 {
+		return time.Time{}, false
+	}
+
+	i := sort.Search(len(x.Entries), func(i int) bool { return x.Entries[i].Timestamp > ts })
+	if i > 0 {
+		i--
+	}
+	e := x.Entries[i]
+	ticks := int64(ts - e.Timestamp)
+	return e.At.Add(time.Duration(ticks * int64(time.Second) / int64(x.ClockRate))), true
+}
+
+// TimestampAt returns the extended RTP timestamp of wall clock time t, the inverse of WallclockAt.
+func (x *Index) TimestampAt(t time.Time) (uint64, bool) {
+	if len(x.Entries) == 0 || x.ClockRate == 0 {
+		return 0, false
+	}
+
+	i := sort.Search(len(x.Entries), func(i int) bool { return x.Entries[i].At.After(t) })
+	if i > 0 {
+		i--
+	}
+	e := x.Entries[i]
+	ticks := int64(t.Sub(e.At)) * int64(x.ClockRate) / int64(time.Second)
+	return uint64(int64(e.Timestamp) + ticks), true
+}
+
+func (x *Index) lastKeyFrameBefore(end int) (Entry, bool) {
+	for i := end - 1; i >= 0; i-- {
+		if x.Entries[i].IsKeyFrame {
+			return x.Entries[i], true
+		}
+	}
+	return Entry{}, false
+}
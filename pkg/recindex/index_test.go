@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recindex
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WriterParams{SSRC: 1234, ClockRate: 90000, MinInterval: 100 * time.Millisecond})
+	start := time.UnixMicro(1700000000_000000)
+
+	// 30 fps across the RTP timestamp wrap, a key frame every second
+	ts := uint32(0xffffffff - 45000)
+	for i := 0; i < 90; i++ {
+		require.NoError(t, w.Add(int64(i*1000), ts, start.Add(time.Duration(i)*time.Second/30), i%30 == 0))
+		ts += 3000
+	}
+	require.ErrorIs(t, w.Add(0, ts, start.Add(3*time.Second), true), ErrOffsetReverse)
+
+	index, err := ReadIndex(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, uint32(1234), index.SSRC)
+	require.Equal(t, uint32(90000), index.ClockRate)
+	// key frames and about every 100ms in between
+	require.Len(t, index.Entries, 30)
+	require.Equal(t, Entry{Offset: 0, Timestamp: 0xffffffff - 45000, At: start, IsKeyFrame: true}, index.Entries[0])
+	last := index.Entries[len(index.Entries)-1]
+	require.Equal(t, int64(87000), last.Offset)
+	require.Equal(t, uint64(0xffffffff-45000+87*3000), last.Timestamp)
+	require.Equal(t, start.Add(87*time.Second/30), last.At)
+
+	e, ok := index.SeekTime(start.Add(1500 * time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, int64(30000), e.Offset)
+	_, ok = index.SeekTime(start.Add(-time.Second))
+	require.False(t, ok)
+
+	e, ok = index.SeekTimestamp(0xffffffff - 45000 + 60*3000 + 10)
+	require.True(t, ok)
+	require.Equal(t, int64(60000), e.Offset)
+
+	at, ok := index.WallclockAt(0xffffffff - 45000 + 45*3000 + 900)
+	require.True(t, ok)
+	require.Equal(t, start.Add(45*time.Second/30+10*time.Millisecond), at)
+	extTS, ok := index.TimestampAt(at)
+	require.True(t, ok)
+	require.Equal(t, uint64(0xffffffff-45000+45*3000+900), extTS)
+
+	// truncated by a crash while recording
+	index, err = ReadIndex(bytes.NewReader(buf.Bytes()[:buf.Len()-2]))
+	require.ErrorIs(t, err, ErrInvalidIndex)
+	require.Len(t, index.Entries, 29)
+
+	_, err = ReadIndex(bytes.NewReader([]byte("LKIX\x02")))
+	require.ErrorIs(t, err, ErrInvalidIndex)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recindex
+
+import (
+	"io"
+
+	"github.com/pion/rtp"
+)
+
+type RepairStats struct {
+	NumRepaired int
+	// packets still missing, kept as gaps in the repaired index
+	NumMissing int
+	// late packets filling no gap, of another stream or never missing
+	NumUnmatched  int
+	NumBytesAdded int64
+}
+
+type insertion struct {
+	offset int64
+	data   []byte
+}
+
+// Repair copies the media file src to dst, inserting late packets, for example retransmissions recovered
+// from a deep NACK buffer after the live stream moved on, at the gaps of the index they fill, and returns
+// the index of dst. encode returns the bytes of a packet in the file format, as the recorder writes them.
+// Files are patched after recording, so that recovering packets never delays forwarding.
+func Repair(index *Index, src io.Reader, dst io.Writer, late []*rtp.Packet, encode func(pkt *rtp.Packet) ([]byte, error)) (*Index, RepairStats, error) {
+	var stats RepairStats
+	bySN := make(map[uint16]*rtp.Packet, len(late))
+	for _, pkt := range late {
+		if pkt.SSRC != index.SSRC {
+			stats.NumUnmatched++
+			continue
+		}
+		bySN[pkt.SequenceNumber] = pkt
+	}
+
+	repaired := &Index{
+		SSRC:      index.SSRC,
+		ClockRate: index.ClockRate,
+	}
+	var insertions []insertion
+	var added int64
+	for _, gap := range index.Gaps {
+		// packets are inserted in sequence number order, what is still missing stays a gap where it belongs
+		var missing *Gap
+		for i := 0; i < gap.Count; i++ {
+			sn := gap.FirstSN + uint16(i)
+			pkt, ok := bySN[sn]
+			if !ok {
+				if missing == nil {
+					repaired.Gaps = append(repaired.Gaps, Gap{Offset: gap.Offset + added, FirstSN: sn})
+					missing = &repaired.Gaps[len(repaired.Gaps)-1]
+				}
+				missing.Count++
+				stats.NumMissing++
+				continue
+			}
+
+			data, err := encode(pkt)
+			if err != nil {
+				return nil, stats, err
+			}
+			delete(bySN, sn)
+			insertions = append(insertions, insertion{offset: gap.Offset, data: data})
+			added += int64(len(data))
+			missing = nil
+			stats.NumRepaired++
+		}
+	}
+	stats.NumUnmatched += len(bySN)
+	stats.NumBytesAdded = added
+
+	// media at a gap offset follows the packets inserted there
+	shift := int64(0)
+	next := 0
+	for _, e := range index.Entries {
+		for next < len(insertions) && insertions[next].offset <= e.Offset {
+			shift += int64(len(insertions[next].data))
+			next++
+		}
+		e.Offset += shift
+		repaired.Entries = append(repaired.Entries, e)
+	}
+
+	var copied int64
+	for _, ins := range insertions {
+		n, err := io.CopyN(dst, src, ins.offset-copied)
+		copied += n
+		if err != nil {
+			return nil, stats, err
+		}
+		if _, err := dst.Write(ins.data); err != nil {
+			return nil, stats, err
+		}
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return nil, stats, err
+	}
+	return repaired, stats, nil
+}
+
+// WriteIndex writes an index, for example one returned by Repair, in the format of Writer.
+func WriteIndex(w io.Writer, index *Index) error {
+	iw := NewWriter(w, WriterParams{SSRC: index.SSRC, ClockRate: index.ClockRate})
+	gaps := index.Gaps
+	for _, e := range index.Entries {
+		for len(gaps) != 0 && gaps[0].Offset <= e.Offset {
+			if err := iw.AddGap(gaps[0].Offset, gaps[0].FirstSN, gaps[0].Count); err != nil {
+				return err
+			}
+			gaps = gaps[1:]
+		}
+		if err := iw.Add(e.Offset, uint32(e.Timestamp), e.At, e.IsKeyFrame); err != nil {
+			return err
+		}
+	}
+	for _, gap := range gaps {
+		if err := iw.AddGap(gap.Offset, gap.FirstSN, gap.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
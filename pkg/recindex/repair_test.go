@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recindex
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeTestPacket writes a packet as a length prefixed payload, as a simple recorder would
+func encodeTestPacket(pkt *rtp.Packet) ([]byte, error) {
+	return append([]byte{byte(len(pkt.Payload))}, pkt.Payload...), nil
+}
+
+func TestRepair(t *testing.T) {
+	start := time.UnixMicro(1700000000_000000)
+	var file, indexBuf bytes.Buffer
+	w := NewWriter(&indexBuf, WriterParams{SSRC: 1, ClockRate: 90000})
+
+	// packets 10 to 19 with 12 to 14 and 17 missing, one byte payloads of their sequence number
+	for sn := uint16(10); sn < 20; sn++ {
+		switch sn {
+		case 12, 13, 14, 17:
+			continue
+		case 15:
+			require.NoError(t, w.AddGap(int64(file.Len()), 12, 3))
+		case 18:
+			require.NoError(t, w.AddGap(int64(file.Len()), 17, 1))
+		}
+		require.NoError(t, w.Add(int64(file.Len()), uint32(sn)*3000, start.Add(time.Duration(sn)*time.Second/30), sn == 10))
+		b, _ := encodeTestPacket(&rtp.Packet{Payload: []byte{byte(sn)}})
+		file.Write(b)
+	}
+
+	index, err := ReadIndex(bytes.NewReader(indexBuf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, []Gap{{Offset: 4, FirstSN: 12, Count: 3}, {Offset: 8, FirstSN: 17, Count: 1}}, index.Gaps)
+	require.Len(t, index.Entries, 6)
+
+	late := []*rtp.Packet{
+		{Header: rtp.Header{SSRC: 1, SequenceNumber: 14}, Payload: []byte{14}},
+		{Header: rtp.Header{SSRC: 1, SequenceNumber: 12}, Payload: []byte{12}},
+		{Header: rtp.Header{SSRC: 1, SequenceNumber: 17}, Payload: []byte{17}},
+		// already recorded and of another stream
+		{Header: rtp.Header{SSRC: 1, SequenceNumber: 11}, Payload: []byte{11}},
+		{Header: rtp.Header{SSRC: 2, SequenceNumber: 13}, Payload: []byte{13}},
+	}
+	var repairedFile bytes.Buffer
+	repaired, stats, err := Repair(index, bytes.NewReader(file.Bytes()), &repairedFile, late, encodeTestPacket)
+	require.NoError(t, err)
+	require.Equal(t, RepairStats{NumRepaired: 3, NumMissing: 1, NumUnmatched: 2, NumBytesAdded: 6}, stats)
+	require.Equal(t, []byte{1, 10, 1, 11, 1, 12, 1, 14, 1, 15, 1, 16, 1, 17, 1, 18, 1, 19}, repairedFile.Bytes())
+
+	// 13 is still missing, between 12 and 14
+	require.Equal(t, []Gap{{Offset: 6, FirstSN: 13, Count: 1}}, repaired.Gaps)
+	var offsets []int64
+	for _, e := range repaired.Entries {
+		offsets = append(offsets, e.Offset)
+	}
+	require.Equal(t, []int64{0, 2, 8, 10, 14, 16}, offsets)
+
+	var repairedIndex bytes.Buffer
+	require.NoError(t, WriteIndex(&repairedIndex, repaired))
+	read, err := ReadIndex(bytes.NewReader(repairedIndex.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, repaired, read)
+}
@@ -0,0 +1,337 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package relay provides building blocks for node to node relay links carrying the media of several tenants,
+// such as inter-region cascades. The module has no relay link of its own, links are implemented by embedders.
+package relay
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/livekit/mediatransportutil/pkg/replay"
+)
+
+const (
+	// key ID, SSRC and sequence number, authenticated but not encrypted
+	frameHeaderSize = 10
+	nonceSize       = 12
+	tagSize         = 16
+	// bytes added to a packet by Seal
+	FrameOverhead = frameHeaderSize + nonceSize + tagSize
+
+	// frames sealed per track key. Nonces are random, NIST SP 800-38D bounds their collision probability
+	// to 2^-32 up to 2^32 invocations of a key
+	MaxFramesPerKey = 1 << 32
+
+	minSecretSize = 16
+	trackKeySize  = 16
+	trackKeyLabel = "livekit relay track key"
+)
+
+var (
+	ErrKeyInvalid       = errors.New("relay key secret too short")
+	ErrKeyInUse         = errors.New("relay key ID in use by another tenant")
+	ErrKeyUnknown       = errors.New("unknown relay key ID")
+	ErrKeyExhausted     = errors.New("relay track key exhausted, rotate the key")
+	ErrTooManyTracks    = errors.New("too many relay tracks")
+	ErrFrameShort       = errors.New("relay frame too short")
+	ErrFrameAuth        = errors.New("relay frame authentication failed")
+	ErrFrameReplayed    = errors.New("relay frame replayed")
+	ErrFrameOutOfWindow = errors.New("relay frame older than the replay window")
+)
+
+// Key is a key of a tenant on relay links. A tenant has one or more keys, for example the current and
+// the next one during a rotation, each with an ID unique across the tenants of the link.
+type Key struct {
+	ID     uint32
+	Tenant string
+	// at least 16 bytes, shared by both ends of the link out of band
+	Secret []byte
+}
+
+type KeyringParams struct {
+	// tracks per key, Seal and Open fail with ErrTooManyTracks beyond this until tracks are removed
+	MaxTracks int
+	// frames behind the highest received one accepted per track, see replay.WindowParams
+	ReplayWindowSize int
+}
+
+var KeyringParamsDefault = KeyringParams{
+	MaxTracks:        4096,
+	ReplayWindowSize: 1024,
+}
+
+type KeyringStats struct {
+	NumSealed uint64
+	NumOpened uint64
+	// frames with a key ID not in the keyring
+	NumUnknownKey uint64
+	// frames that did not authenticate, corrupted or sealed with another secret
+	NumAuthFailures uint64
+	// frames already opened, or too old to tell
+	NumReplayed uint64
+	NumKeys     int
+}
+
+type tenantKey struct {
+	key    Key
+	tracks map[uint32]*trackState
+}
+
+// trackState is the key of a track and the state of its frames, on the sending or the receiving end.
+type trackState struct {
+	aead cipher.AEAD
+
+	lock sync.Mutex
+	// sending
+	nextSN    uint16
+	numSealed uint64
+	// receiving
+	window *replay.Window
+}
+
+// Keyring separates the tenants sharing a relay link cryptographically. Each packet is sealed with a key
+// of its track, derived from a secret of its tenant, and framed with the key ID so that the receiving end picks
+// the tenant and key to open it with:
+//
+//	key ID (4) | SSRC (4) | sequence number (2) | nonce (12) | AES-128-GCM sealed packet and tag (16)
+//
+// Key ID, SSRC and sequence number are authenticated, a frame of one tenant cannot be opened, or passed off
+// as another track, with the keys of another tenant. The sequence number counts the frames of a track under
+// a key, Open rejects replayed frames with a replay.Window per key ID and SSRC.
+//
+// Nonces are random, a track key seals at most MaxFramesPerKey frames, Seal fails with ErrKeyExhausted after
+// that and the tenant key is rotated: add a key with a new ID on both ends, seal with it, then remove the old one.
+// A long lived track sending 1000 packets a second exhausts its key after about 50 days. The replay state of a
+// track is kept until RemoveTrack, both ends remove a track when it ends, frames of a removed track are accepted
+// again if replayed, so a reused SSRC should be sealed with a new key.
+type Keyring struct {
+	params KeyringParams
+
+	lock  sync.RWMutex
+	keys  map[uint32]*tenantKey
+	stats KeyringStats
+}
+
+func NewKeyring(params KeyringParams) *Keyring {
+	if params.MaxTracks <= 0 {
+		params.MaxTracks = KeyringParamsDefault.MaxTracks
+	}
+	if params.ReplayWindowSize <= 0 {
+		params.ReplayWindowSize = KeyringParamsDefault.ReplayWindowSize
+	}
+	return &Keyring{
+		params: params,
+		keys:   make(map[uint32]*tenantKey),
+	}
+}
+
+// Add adds a key. A key with the same ID is replaced only if it is of the same tenant, its track state is reset.
+func (k *Keyring) Add(key Key) error {
+	if len(key.Secret) < minSecretSize {
+		return ErrKeyInvalid
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if existing := k.keys[key.ID]; existing != nil && existing.key.Tenant != key.Tenant {
+		return fmt.Errorf("%w: %d", ErrKeyInUse, key.ID)
+	}
+	key.Secret = append([]byte{}, key.Secret...)
+	k.keys[key.ID] = &tenantKey{
+		key:    key,
+		tracks: make(map[uint32]*trackState),
+	}
+	return nil
+}
+
+// Remove forgets a key, for example once a rotation is over, frames with its ID are not opened anymore.
+func (k *Keyring) Remove(id uint32) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	delete(k.keys, id)
+}
+
+// RemoveTrack forgets the state of a track under all keys, when it ends.
+func (k *Keyring) RemoveTrack(ssrc uint32) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	for _, tk := range k.keys {
+		delete(tk.tracks, ssrc)
+	}
+}
+
+// Seal frames the RTP or RTCP packet of the track ssrc with the key keyID, for sending on the relay link.
+func (k *Keyring) Seal(keyID uint32, ssrc uint32, packet []byte) ([]byte, error) {
+	_, ts, err := k.track(keyID, ssrc)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, frameHeaderSize+nonceSize, FrameOverhead+len(packet))
+	binary.BigEndian.PutUint32(frame[0:4], keyID)
+	binary.BigEndian.PutUint32(frame[4:8], ssrc)
+	nonce := frame[frameHeaderSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ts.lock.Lock()
+	if ts.numSealed >= MaxFramesPerKey {
+		ts.lock.Unlock()
+		return nil, fmt.Errorf("%w: %d", ErrKeyExhausted, keyID)
+	}
+	ts.numSealed++
+	binary.BigEndian.PutUint16(frame[8:10], ts.nextSN)
+	ts.nextSN++
+	ts.lock.Unlock()
+
+	frame = ts.aead.Seal(frame, nonce, packet, frame[:frameHeaderSize])
+
+	k.lock.Lock()
+	k.stats.NumSealed++
+	k.lock.Unlock()
+	return frame, nil
+}
+
+// Open returns the packet of a frame received on the relay link, with the tenant and track it belongs to.
+// A frame is opened once, a replayed frame fails with ErrFrameReplayed.
+func (k *Keyring) Open(frame []byte) (tenant string, ssrc uint32, packet []byte, err error) {
+	if len(frame) < FrameOverhead {
+		return "", 0, nil, ErrFrameShort
+	}
+	keyID := binary.BigEndian.Uint32(frame[0:4])
+	ssrc = binary.BigEndian.Uint32(frame[4:8])
+	sn := binary.BigEndian.Uint16(frame[8:10])
+
+	tenant, ts, err := k.track(keyID, ssrc)
+	if err != nil {
+		if errors.Is(err, ErrKeyUnknown) {
+			k.lock.Lock()
+			k.stats.NumUnknownKey++
+			k.lock.Unlock()
+		}
+		return "", 0, nil, err
+	}
+
+	// the window is checked before and committed after authentication, so that forged frames cannot advance it
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+
+	index, result := ts.window.Check(sn)
+	if result != replay.ResultAccepted {
+		k.lock.Lock()
+		k.stats.NumReplayed++
+		k.lock.Unlock()
+		if result == replay.ResultTooOld {
+			return "", 0, nil, ErrFrameOutOfWindow
+		}
+		return "", 0, nil, ErrFrameReplayed
+	}
+
+	nonce := frame[frameHeaderSize : frameHeaderSize+nonceSize]
+	packet, err = ts.aead.Open(nil, nonce, frame[frameHeaderSize+nonceSize:], frame[:frameHeaderSize])
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if err != nil {
+		k.stats.NumAuthFailures++
+		return "", 0, nil, ErrFrameAuth
+	}
+	ts.window.Commit(index)
+	k.stats.NumOpened++
+	return tenant, ssrc, packet, nil
+}
+
+func (k *Keyring) Stats() KeyringStats {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	stats := k.stats
+	stats.NumKeys = len(k.keys)
+	return stats
+}
+
+// track returns the tenant of the key and the state of the track, created on first use.
+func (k *Keyring) track(keyID uint32, ssrc uint32) (string, *trackState, error) {
+	k.lock.RLock()
+	tk := k.keys[keyID]
+	var ts *trackState
+	if tk != nil {
+		ts = tk.tracks[ssrc]
+	}
+	k.lock.RUnlock()
+	if tk == nil {
+		return "", nil, fmt.Errorf("%w: %d", ErrKeyUnknown, keyID)
+	}
+	if ts != nil {
+		return tk.key.Tenant, ts, nil
+	}
+
+	aead, err := newTrackAEAD(tk.key, ssrc)
+	if err != nil {
+		return "", nil, err
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	if k.keys[keyID] != tk {
+		// removed or replaced meanwhile
+		return "", nil, fmt.Errorf("%w: %d", ErrKeyUnknown, keyID)
+	}
+	if ts = tk.tracks[ssrc]; ts != nil {
+		// created meanwhile
+		return tk.key.Tenant, ts, nil
+	}
+	if len(tk.tracks) >= k.params.MaxTracks {
+		return "", nil, fmt.Errorf("%w: %d", ErrTooManyTracks, len(tk.tracks))
+	}
+	ts = &trackState{
+		aead:   aead,
+		window: replay.NewWindow(replay.WindowParams{Size: k.params.ReplayWindowSize}),
+	}
+	tk.tracks[ssrc] = ts
+	return tk.key.Tenant, ts, nil
+}
+
+// newTrackAEAD derives the key of a track from the tenant secret, HMAC-SHA256 of the label, key ID and SSRC.
+func newTrackAEAD(key Key, ssrc uint32) (cipher.AEAD, error) {
+	var info [8]byte
+	binary.BigEndian.PutUint32(info[0:4], key.ID)
+	binary.BigEndian.PutUint32(info[4:8], ssrc)
+
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(trackKeyLabel))
+	mac.Write(info[:])
+	trackKey := mac.Sum(nil)[:trackKeySize]
+
+	block, err := aes.NewCipher(trackKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
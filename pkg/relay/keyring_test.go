@@ -0,0 +1,146 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	secretA := bytes.Repeat([]byte{0xa}, 32)
+	secretB := bytes.Repeat([]byte{0xb}, 32)
+
+	sender := NewKeyring(KeyringParamsDefault)
+	receiver := NewKeyring(KeyringParamsDefault)
+	for _, k := range []*Keyring{sender, receiver} {
+		require.NoError(t, k.Add(Key{ID: 1, Tenant: "a", Secret: secretA}))
+		require.NoError(t, k.Add(Key{ID: 2, Tenant: "b", Secret: secretB}))
+	}
+	require.ErrorIs(t, sender.Add(Key{ID: 1, Tenant: "b", Secret: secretB}), ErrKeyInUse)
+	require.ErrorIs(t, sender.Add(Key{ID: 3, Tenant: "b", Secret: secretB[:8]}), ErrKeyInvalid)
+
+	packet := []byte{0x80, 0x60, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x11, 0x11, 0x11, 0x11, 0xde, 0xad}
+	frame, err := sender.Seal(1, 0x11111111, packet)
+	require.NoError(t, err)
+	require.Len(t, frame, len(packet)+FrameOverhead)
+
+	tenant, ssrc, opened, err := receiver.Open(frame)
+	require.NoError(t, err)
+	require.Equal(t, "a", tenant)
+	require.Equal(t, uint32(0x11111111), ssrc)
+	require.Equal(t, packet, opened)
+
+	// replayed
+	_, _, _, err = receiver.Open(frame)
+	require.ErrorIs(t, err, ErrFrameReplayed)
+
+	// nonces and sequence numbers differ between frames of the same packet
+	frame2, err := sender.Seal(1, 0x11111111, packet)
+	require.NoError(t, err)
+	require.NotEqual(t, frame[8:], frame2[8:])
+
+	// relabelled as the other tenant, track or sequence number, the frame does not authenticate
+	// and does not advance the replay window
+	for _, i := range []int{3, 7, 9} {
+		forged := append([]byte{}, frame2...)
+		// key ID 1 becomes 2, of tenant b
+		forged[i] ^= 0x03
+		_, _, _, err = receiver.Open(forged)
+		require.ErrorIs(t, err, ErrFrameAuth)
+	}
+	_, _, _, err = receiver.Open(frame2)
+	require.NoError(t, err)
+
+	// tenant b cannot open tenant a frames with its own secret under tenant a key ID
+	other := NewKeyring(KeyringParamsDefault)
+	require.NoError(t, other.Add(Key{ID: 1, Tenant: "b", Secret: secretB}))
+	_, _, _, err = other.Open(frame)
+	require.ErrorIs(t, err, ErrFrameAuth)
+
+	_, _, _, err = receiver.Open(frame[:FrameOverhead-1])
+	require.ErrorIs(t, err, ErrFrameShort)
+
+	// rotation, frames of a removed key are not opened anymore
+	receiver.Remove(1)
+	_, _, _, err = receiver.Open(frame)
+	require.ErrorIs(t, err, ErrKeyUnknown)
+
+	stats := receiver.Stats()
+	require.Equal(t, uint64(2), stats.NumOpened)
+	require.Equal(t, uint64(3), stats.NumAuthFailures)
+	require.Equal(t, uint64(1), stats.NumReplayed)
+	require.Equal(t, uint64(1), stats.NumUnknownKey)
+	require.Equal(t, 1, stats.NumKeys)
+	require.Equal(t, uint64(2), sender.Stats().NumSealed)
+}
+
+func TestKeyringReplayWindow(t *testing.T) {
+	secret := bytes.Repeat([]byte{0xa}, 16)
+	sender := NewKeyring(KeyringParamsDefault)
+	receiver := NewKeyring(KeyringParams{ReplayWindowSize: 64})
+	require.NoError(t, sender.Add(Key{ID: 1, Tenant: "a", Secret: secret}))
+	require.NoError(t, receiver.Add(Key{ID: 1, Tenant: "a", Secret: secret}))
+
+	var frames [][]byte
+	for i := 0; i < 100; i++ {
+		frame, err := sender.Seal(1, 1, []byte{byte(i)})
+		require.NoError(t, err)
+		frames = append(frames, frame)
+	}
+
+	// reordered within the window
+	_, _, _, err := receiver.Open(frames[1])
+	require.NoError(t, err)
+	_, _, _, err = receiver.Open(frames[0])
+	require.NoError(t, err)
+
+	_, _, _, err = receiver.Open(frames[99])
+	require.NoError(t, err)
+	_, _, _, err = receiver.Open(frames[2])
+	require.ErrorIs(t, err, ErrFrameOutOfWindow)
+	_, _, _, err = receiver.Open(frames[50])
+	require.NoError(t, err)
+
+	// a removed track starts over on both ends
+	sender.RemoveTrack(1)
+	receiver.RemoveTrack(1)
+	frame, err := sender.Seal(1, 1, []byte{1})
+	require.NoError(t, err)
+	_, _, _, err = receiver.Open(frame)
+	require.NoError(t, err)
+}
+
+func TestKeyringLimits(t *testing.T) {
+	k := NewKeyring(KeyringParams{MaxTracks: 2})
+	require.NoError(t, k.Add(Key{ID: 1, Tenant: "a", Secret: bytes.Repeat([]byte{0xa}, 16)}))
+
+	for ssrc := uint32(0); ssrc < 2; ssrc++ {
+		_, err := k.Seal(1, ssrc, []byte{1})
+		require.NoError(t, err)
+	}
+	_, err := k.Seal(1, 2, []byte{1})
+	require.ErrorIs(t, err, ErrTooManyTracks)
+	k.RemoveTrack(0)
+	_, err = k.Seal(1, 2, []byte{1})
+	require.NoError(t, err)
+
+	// exhausted track key
+	k.keys[1].tracks[2].numSealed = MaxFramesPerKey
+	_, err = k.Seal(1, 2, []byte{1})
+	require.ErrorIs(t, err, ErrKeyExhausted)
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/bwe"
+	"github.com/livekit/mediatransportutil/pkg/pacer"
+	"github.com/livekit/mediatransportutil/pkg/ring"
+)
+
+// Priority orders the streams of a relay link, lower values are sent first and dropped last.
+type Priority uint8
+
+const (
+	PriorityAudio Priority = iota
+	// lowest video layer, higher layers follow, see VideoPriority
+	PriorityVideo
+
+	numPriorities = 8
+	// lowest priority, higher values are clamped to it
+	PriorityLowest Priority = numPriorities - 1
+)
+
+// VideoPriority returns the priority of a video layer, 0 being the lowest layer.
+func VideoPriority(layer int) Priority {
+	if layer < 0 {
+		layer = 0
+	}
+	if layer > int(PriorityLowest-PriorityVideo) {
+		return PriorityLowest
+	}
+	return PriorityVideo + Priority(layer)
+}
+
+type QueueParams struct {
+	// bytes waiting in the priority queues, the lowest priority packets are dropped beyond this
+	MaxBytes int
+	// bytes handed to the pacer ahead of sending, as time at the pacing bitrate, one packet at least.
+	// Packets handed to the pacer are not reordered by priority or dropped anymore
+	Horizon time.Duration
+	// send interval of the pacer
+	PacerInterval time.Duration
+	// bitrate until the controller has one
+	InitialBitrate int
+}
+
+var QueueParamsDefault = QueueParams{
+	MaxBytes:       1 << 20,
+	Horizon:        20 * time.Millisecond,
+	PacerInterval:  5 * time.Millisecond,
+	InitialBitrate: 10_000_000,
+}
+
+type QueueStats struct {
+	// packets and bytes not sent yet, in the priority queues or the pacer
+	NumPackets int
+	NumBytes   int
+	Bitrate    int
+	// packets sent and dropped per priority
+	NumSent    [numPriorities]uint64
+	NumDropped [numPriorities]uint64
+}
+
+type queuedPacket struct {
+	pkt      *pacer.Packet
+	size     int
+	priority Priority
+}
+
+// Queue sends the packets of a relay link by priority, audio first, then video from the lowest layer up, paced by
+// a leaky bucket pacer at the pacing bitrate of the congestion controller of the link. Packets wait in a queue per
+// priority and are handed to the pacer a horizon at a time, when the link cannot keep up the packets of the lowest
+// priority queued are dropped first, oldest first, so that cascaded links lose upper layers instead of dropping
+// whatever arrives when the queue is full.
+//
+// The controller is the one of the link rather than the one of a subscriber. The queue reports the packets it sends
+// with a transport wide sequence number to it, the caller feeds it with the feedback and RTTs of the link.
+type Queue struct {
+	params     QueueParams
+	controller bwe.CongestionControl
+	pacer      *pacer.PacerLeakyBucket
+
+	lock   sync.Mutex
+	queues [numPriorities]ring.Deque[*queuedPacket]
+	// bytes in the priority queues and handed to the pacer
+	numBytes        int
+	numPacerBytes   int
+	numPacerPackets int
+	bitrate         int
+	stats           QueueStats
+}
+
+func NewQueue(params QueueParams, controller bwe.CongestionControl, logger logger.Logger) *Queue {
+	if params.MaxBytes <= 0 {
+		params.MaxBytes = QueueParamsDefault.MaxBytes
+	}
+	if params.Horizon <= 0 {
+		params.Horizon = QueueParamsDefault.Horizon
+	}
+	if params.PacerInterval <= 0 {
+		params.PacerInterval = QueueParamsDefault.PacerInterval
+	}
+	if params.InitialBitrate <= 0 {
+		params.InitialBitrate = QueueParamsDefault.InitialBitrate
+	}
+
+	bitrate := controller.PacingBitrate()
+	if bitrate <= 0 {
+		bitrate = params.InitialBitrate
+	}
+	q := &Queue{
+		params:     params,
+		controller: controller,
+		// packets beyond the pacing bitrate are dropped by priority here, the pacer does not catch up on latency
+		pacer:   pacer.NewPacerLeakyBucket(params.PacerInterval, bitrate, 0, logger),
+		bitrate: bitrate,
+	}
+	for i := range q.queues {
+		q.queues[i].Grow(1 << 6)
+	}
+	controller.OnRateChange(func(_ int, pacingBitrate int) {
+		q.setBitrate(pacingBitrate)
+	})
+	return q
+}
+
+func (q *Queue) Start() {
+	q.pacer.Start()
+}
+
+// Stop stops the pacer, packets still queued are not sent.
+func (q *Queue) Stop() {
+	q.pacer.Stop()
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for i := range q.queues {
+		for q.queues[i].Len() != 0 {
+			qp := q.queues[i].PopFront()
+			q.numBytes -= qp.size
+			releasePacket(qp.pkt)
+		}
+	}
+}
+
+// Push queues a packet, returning false if it was dropped as the queue is full of packets of higher priority.
+// The packet is sent with its writer, audio priority packets are marked as audio for the pacer.
+func (q *Queue) Push(pkt *pacer.Packet, priority Priority) bool {
+	if priority > PriorityLowest {
+		priority = PriorityLowest
+	}
+	qp := &queuedPacket{
+		pkt:      pkt,
+		size:     packetSize(pkt),
+		priority: priority,
+	}
+	pkt.IsAudio = priority == PriorityAudio
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for q.numBytes-q.numPacerBytes+qp.size > q.params.MaxBytes {
+		lowest := q.lowestQueuedLocked()
+		if lowest < 0 || Priority(lowest) < priority {
+			q.stats.NumDropped[priority]++
+			releasePacket(pkt)
+			return false
+		}
+		dropped := q.queues[lowest].PopFront()
+		q.numBytes -= dropped.size
+		q.stats.NumDropped[lowest]++
+		releasePacket(dropped.pkt)
+	}
+
+	q.queues[priority].PushBack(qp)
+	q.numBytes += qp.size
+	q.feedPacerLocked()
+	return true
+}
+
+func (q *Queue) Stats() QueueStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	stats := q.stats
+	stats.NumPackets = q.numPacerPackets
+	for i := range q.queues {
+		stats.NumPackets += q.queues[i].Len()
+	}
+	stats.NumBytes = q.numBytes
+	stats.Bitrate = q.bitrate
+	return stats
+}
+
+func (q *Queue) setBitrate(bitrate int) {
+	if bitrate <= 0 {
+		return
+	}
+	q.pacer.SetBitrate(bitrate)
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.bitrate = bitrate
+	q.feedPacerLocked()
+}
+
+// feedPacerLocked hands packets to the pacer by priority until it holds the horizon.
+func (q *Queue) feedPacerLocked() {
+	horizonBytes := int(float64(q.bitrate) / 8 * q.params.Horizon.Seconds())
+	for priority := range q.queues {
+		for q.queues[priority].Len() != 0 {
+			qp := q.queues[priority].Front()
+			if q.numPacerPackets != 0 && q.numPacerBytes+qp.size > horizonBytes {
+				return
+			}
+			q.queues[priority].PopFront()
+			q.numPacerBytes += qp.size
+			q.numPacerPackets++
+			q.enqueue(qp)
+		}
+	}
+}
+
+// enqueue wraps the writer of the packet to account for it once written and report it to the controller.
+// The pacer writes without holding its lock, so the wrapper can take the lock of the queue.
+func (q *Queue) enqueue(qp *queuedPacket) {
+	writer := qp.pkt.Writer
+	twccExtID := qp.pkt.TransportWideExtID
+	qp.pkt.Writer = func(header *rtp.Header, payload []byte) (int, error) {
+		n, err := writer(header, payload)
+		if err == nil && twccExtID != 0 {
+			var ext rtp.TransportCCExtension
+			if ext.Unmarshal(header.GetExtension(twccExtID)) == nil {
+				q.controller.OnPacketSent(ext.TransportSequence, n)
+			}
+		}
+
+		q.lock.Lock()
+		q.numBytes -= qp.size
+		q.numPacerBytes -= qp.size
+		q.numPacerPackets--
+		q.stats.NumSent[qp.priority]++
+		q.feedPacerLocked()
+		q.lock.Unlock()
+		return n, err
+	}
+	q.pacer.Enqueue(qp.pkt)
+}
+
+// lowestQueuedLocked returns the lowest priority with queued packets, -1 if the priority queues are empty.
+func (q *Queue) lowestQueuedLocked() int {
+	for priority := len(q.queues) - 1; priority >= 0; priority-- {
+		if q.queues[priority].Len() != 0 {
+			return priority
+		}
+	}
+	return -1
+}
+
+func packetSize(pkt *pacer.Packet) int {
+	size := len(pkt.Payload) + pkt.Header.MarshalSize()
+	for _, ext := range pkt.Extensions {
+		size += len(ext.Payload) + 1
+	}
+	return size
+}
+
+// releasePacket returns the buffer of a dropped packet to its pool, as the pacer does for sent packets.
+func releasePacket(pkt *pacer.Packet) {
+	if pkt.Pool != nil && pkt.PoolEntity != nil {
+		pkt.Pool.Put(pkt.PoolEntity)
+	}
+}
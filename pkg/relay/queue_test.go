@@ -0,0 +1,165 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package relay
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/pacer"
+)
+
+const testTWCCExtID = 1
+
+type testController struct {
+	lock         sync.Mutex
+	bitrate      int
+	sent         []uint16
+	onRateChange func(targetBitrate int, pacingBitrate int)
+}
+
+func (c *testController) OnPacketSent(sn uint16, _ int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sent = append(c.sent, sn)
+}
+
+func (c *testController) OnFeedback(_ *rtcp.TransportLayerCC) error { return nil }
+func (c *testController) OnRTT(_ time.Duration)                     {}
+func (c *testController) TargetBitrate() int                        { return c.bitrate }
+func (c *testController) PacingBitrate() int                        { return c.bitrate }
+func (c *testController) ExportState() map[string]interface{}       { return nil }
+
+func (c *testController) OnRateChange(f func(targetBitrate int, pacingBitrate int)) {
+	c.onRateChange = f
+}
+
+func (c *testController) sentSNs() []uint16 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]uint16{}, c.sent...)
+}
+
+type testWriter struct {
+	lock sync.Mutex
+	sent []uint16
+}
+
+func (w *testWriter) write(header *rtp.Header, payload []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.sent = append(w.sent, header.SequenceNumber)
+	return header.MarshalSize() + len(payload), nil
+}
+
+func (w *testWriter) sentSNs() []uint16 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return append([]uint16{}, w.sent...)
+}
+
+// newTestPacket returns a packet of 112 bytes, with the transport wide sequence number sn
+func newTestPacket(t *testing.T, sn uint16, w *testWriter) *pacer.Packet {
+	twcc, err := (&rtp.TransportCCExtension{TransportSequence: sn}).Marshal()
+	require.NoError(t, err)
+	header := &rtp.Header{Version: 2, SequenceNumber: sn}
+	require.NoError(t, header.SetExtension(testTWCCExtID, twcc))
+	return &pacer.Packet{
+		Header:             header,
+		Payload:            make([]byte, 112-header.MarshalSize()),
+		TransportWideExtID: testTWCCExtID,
+		Writer:             w.write,
+	}
+}
+
+func TestVideoPriority(t *testing.T) {
+	require.Equal(t, PriorityVideo, VideoPriority(-1))
+	require.Equal(t, PriorityVideo, VideoPriority(0))
+	require.Equal(t, PriorityVideo+2, VideoPriority(2))
+	require.Equal(t, PriorityLowest, VideoPriority(100))
+}
+
+func TestQueuePriority(t *testing.T) {
+	// 80 kbps, the 20 ms horizon holds one packet
+	controller := &testController{bitrate: 80_000}
+	q := NewQueue(QueueParamsDefault, controller, logger.GetLogger())
+	defer q.Stop()
+
+	w := &testWriter{}
+	require.True(t, q.Push(newTestPacket(t, 1, w), VideoPriority(1)))
+	require.True(t, q.Push(newTestPacket(t, 2, w), VideoPriority(0)))
+	require.True(t, q.Push(newTestPacket(t, 3, w), 100))
+	require.True(t, q.Push(newTestPacket(t, 4, w), PriorityAudio))
+
+	q.Start()
+	require.Eventually(t, func() bool {
+		return len(w.sentSNs()) == 4
+	}, time.Second, 5*time.Millisecond)
+
+	// the first packet was with the pacer already
+	require.Equal(t, []uint16{1, 4, 2, 3}, w.sentSNs())
+	require.Equal(t, []uint16{1, 4, 2, 3}, controller.sentSNs())
+
+	stats := q.Stats()
+	require.Zero(t, stats.NumPackets)
+	require.Zero(t, stats.NumBytes)
+	require.Equal(t, uint64(1), stats.NumSent[PriorityAudio])
+	require.Equal(t, uint64(1), stats.NumSent[PriorityLowest])
+}
+
+func TestQueueDropsLowestPriority(t *testing.T) {
+	// 8 kbps, the horizon holds one packet, the priority queues three
+	controller := &testController{bitrate: 8000}
+	q := NewQueue(QueueParams{MaxBytes: 336}, controller, logger.GetLogger())
+	defer q.Stop()
+
+	w := &testWriter{}
+	require.True(t, q.Push(newTestPacket(t, 0, w), VideoPriority(1)))
+	require.True(t, q.Push(newTestPacket(t, 1, w), VideoPriority(1)))
+	require.True(t, q.Push(newTestPacket(t, 2, w), VideoPriority(1)))
+	require.True(t, q.Push(newTestPacket(t, 3, w), VideoPriority(0)))
+
+	// full, the oldest packet of the upper layer makes room for audio
+	require.True(t, q.Push(newTestPacket(t, 4, w), PriorityAudio))
+	// full of higher priority packets, the upper layer packet is dropped
+	require.True(t, q.Push(newTestPacket(t, 5, w), PriorityAudio))
+	require.False(t, q.Push(newTestPacket(t, 6, w), VideoPriority(1)))
+
+	stats := q.Stats()
+	require.Equal(t, 4, stats.NumPackets)
+	require.Equal(t, 448, stats.NumBytes)
+	require.Equal(t, 8000, stats.Bitrate)
+	require.Equal(t, uint64(3), stats.NumDropped[VideoPriority(1)])
+
+	// the controller raises the bitrate, the pacer takes the rest and sends audio first
+	controller.onRateChange(8_000_000, 8_000_000)
+	require.Equal(t, 8_000_000, q.Stats().Bitrate)
+
+	q.Start()
+	require.Eventually(t, func() bool {
+		return len(w.sentSNs()) == 4
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, []uint16{4, 5, 0, 3}, w.sentSNs())
+}
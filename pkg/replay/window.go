@@ -0,0 +1,193 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+)
+
+type Result int
+
+const (
+	ResultAccepted Result = iota
+	// already received within the window
+	ResultDuplicate
+	// older than the window, cannot tell whether it is a replay
+	ResultTooOld
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultAccepted:
+		return "ACCEPTED"
+	case ResultDuplicate:
+		return "DUPLICATE"
+	case ResultTooOld:
+		return "TOO_OLD"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type WindowParams struct {
+	// number of packets behind the highest received one that are tracked, rounded up to a multiple of 64.
+	// Packets reordered further than this are rejected as too old.
+	Size int
+}
+
+var WindowParamsDefault = WindowParams{
+	Size: 1024,
+}
+
+type WindowStats struct {
+	NumAccepted   uint64
+	NumDuplicates uint64
+	NumTooOld     uint64
+}
+
+// Window is an SRTP style (RFC 3711, section 3.3.2) replay window over RTP sequence numbers, for forwarding
+// layers that do not terminate SRTP, such as relay links. Rejecting replayed packets keeps them from
+// corrupting stats and forwarding state.
+//
+// Sequence numbers are extended to 64 bit indices, so the window keeps working across wrap around.
+// Window is not safe for concurrent use.
+type Window struct {
+	size uint64
+	bits []uint64
+
+	init    bool
+	highest uint64
+	stats   WindowStats
+}
+
+func NewWindow(params WindowParams) *Window {
+	if params.Size <= 0 {
+		params.Size = WindowParamsDefault.Size
+	}
+
+	words := (params.Size + 63) / 64
+	return &Window{
+		size: uint64(words * 64),
+		bits: make([]uint64, words),
+	}
+}
+
+// Check returns the extended index of a sequence number and whether it would be accepted, without
+// updating the window. With authentication, the window is updated with Commit only after the packet
+// has been authenticated, so that forged packets cannot advance it.
+func (w *Window) Check(sn uint16) (uint64, Result) {
+	index := w.index(sn)
+	result := w.check(index)
+	switch result {
+	case ResultDuplicate:
+		w.stats.NumDuplicates++
+	case ResultTooOld:
+		w.stats.NumTooOld++
+	}
+	return index, result
+}
+
+// Commit marks the extended index returned by an accepting Check as received.
+func (w *Window) Commit(index uint64) {
+	if w.check(index) != ResultAccepted {
+		return
+	}
+
+	if !w.init || index > w.highest {
+		w.advance(index)
+	}
+	w.set(index)
+	w.stats.NumAccepted++
+}
+
+// Accept checks a sequence number and, if accepted, marks it as received.
+func (w *Window) Accept(sn uint16) Result {
+	index, result := w.Check(sn)
+	if result == ResultAccepted {
+		w.Commit(index)
+	}
+	return result
+}
+
+// Highest returns the extended index of the highest sequence number received, false if none has been.
+func (w *Window) Highest() (uint64, bool) {
+	return w.highest, w.init
+}
+
+func (w *Window) Stats() WindowStats {
+	return w.stats
+}
+
+// Reset forgets received sequence numbers, for example on an SSRC change.
+func (w *Window) Reset() {
+	w.init = false
+	w.highest = 0
+	for i := range w.bits {
+		w.bits[i] = 0
+	}
+}
+
+func (w *Window) index(sn uint16) uint64 {
+	if !w.init {
+		// start past the first cycle, so that packets older than the first one do not underflow
+		return 1<<16 | uint64(sn)
+	}
+
+	diff := int16(sn - uint16(w.highest))
+	return uint64(int64(w.highest) + int64(diff))
+}
+
+func (w *Window) check(index uint64) Result {
+	if !w.init || index > w.highest {
+		return ResultAccepted
+	}
+	if w.highest-index >= w.size {
+		return ResultTooOld
+	}
+	if w.isSet(index) {
+		return ResultDuplicate
+	}
+	return ResultAccepted
+}
+
+// moves the highest index forward, clearing the bits that leave the window
+func (w *Window) advance(index uint64) {
+	if !w.init || index-w.highest >= w.size {
+		for i := range w.bits {
+			w.bits[i] = 0
+		}
+	} else {
+		for i := w.highest + 1; i <= index; i++ {
+			w.clear(i)
+		}
+	}
+	w.init = true
+	w.highest = index
+}
+
+func (w *Window) isSet(index uint64) bool {
+	pos := index % w.size
+	return w.bits[pos/64]&(1<<(pos%64)) != 0
+}
+
+func (w *Window) set(index uint64) {
+	pos := index % w.size
+	w.bits[pos/64] |= 1 << (pos % 64)
+}
+
+func (w *Window) clear(index uint64) {
+	pos := index % w.size
+	w.bits[pos/64] &^= 1 << (pos % 64)
+}
@@ -0,0 +1,76 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(WindowParams{Size: 100})
+
+	require.Equal(t, ResultAccepted, w.Accept(65530))
+	require.Equal(t, ResultDuplicate, w.Accept(65530))
+
+	// across wrap around
+	for sn := uint16(65531); sn != 10; sn++ {
+		require.Equal(t, ResultAccepted, w.Accept(sn))
+	}
+	highest, ok := w.Highest()
+	require.True(t, ok)
+	require.Equal(t, uint64(2<<16|9), highest)
+
+	// reordered within the window, before the first packet
+	require.Equal(t, ResultAccepted, w.Accept(65500))
+	require.Equal(t, ResultDuplicate, w.Accept(65500))
+	require.Equal(t, ResultDuplicate, w.Accept(65535))
+
+	// window is rounded up to 128
+	require.Equal(t, ResultTooOld, w.Accept(65530-128+15))
+	require.Equal(t, ResultAccepted, w.Accept(65530-128+16))
+
+	// large jump clears the window
+	require.Equal(t, ResultAccepted, w.Accept(30000))
+	require.Equal(t, ResultTooOld, w.Accept(9))
+	require.Equal(t, ResultAccepted, w.Accept(29999))
+
+	require.Equal(t, WindowStats{NumAccepted: 20, NumDuplicates: 3, NumTooOld: 2}, w.Stats())
+
+	w.Reset()
+	_, ok = w.Highest()
+	require.False(t, ok)
+	require.Equal(t, ResultAccepted, w.Accept(29999))
+}
+
+func TestWindowCheckCommit(t *testing.T) {
+	w := NewWindow(WindowParamsDefault)
+
+	index, result := w.Check(100)
+	require.Equal(t, ResultAccepted, result)
+
+	// not committed, e.g. failed authentication
+	_, result = w.Check(100)
+	require.Equal(t, ResultAccepted, result)
+
+	w.Commit(index)
+	_, result = w.Check(100)
+	require.Equal(t, ResultDuplicate, result)
+
+	// committing twice is a no-op
+	w.Commit(index)
+	require.Equal(t, uint64(1), w.Stats().NumAccepted)
+}
@@ -0,0 +1,77 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ring
+
+// Buffer keeps the last capacity elements pushed, older ones are overwritten. Storage is allocated once,
+// rounded up to a power of two.
+//
+// A Buffer is not safe for concurrent use.
+type Buffer[T any] struct {
+	deque    Deque[T]
+	capacity int
+}
+
+func NewBuffer[T any](capacity int) *Buffer[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	b := &Buffer[T]{
+		capacity: capacity,
+	}
+	b.deque.Grow(capacity)
+	return b
+}
+
+func (b *Buffer[T]) Len() int {
+	return b.deque.Len()
+}
+
+func (b *Buffer[T]) Cap() int {
+	return b.capacity
+}
+
+// Push adds v as the newest element, it returns the oldest element and true if it was overwritten to make room.
+func (b *Buffer[T]) Push(v T) (T, bool) {
+	var dropped T
+	isDropped := false
+	if b.deque.Len() == b.capacity {
+		dropped = b.deque.PopFront()
+		isDropped = true
+	}
+	b.deque.PushBack(v)
+	return dropped, isDropped
+}
+
+// At returns the element at i, 0 is the oldest, it panics if i is out of range.
+func (b *Buffer[T]) At(i int) T {
+	return b.deque.At(i)
+}
+
+// Newest returns the last element pushed, it panics if the buffer is empty.
+func (b *Buffer[T]) Newest() T {
+	return b.deque.Back()
+}
+
+// AppendTo appends the elements to dst, oldest first.
+func (b *Buffer[T]) AppendTo(dst []T) []T {
+	for i := 0; i < b.deque.Len(); i++ {
+		dst = append(dst, b.deque.At(i))
+	}
+	return dst
+}
+
+func (b *Buffer[T]) Clear() {
+	b.deque.Clear()
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ring
+
+const (
+	minDequeCapacity = 16
+)
+
+// Deque is a double ended queue in a ring of power of two size. It grows by doubling and never shrinks,
+// so a queue that has reached its working size does not allocate. The zero value is an empty deque.
+//
+// A Deque is not safe for concurrent use.
+type Deque[T any] struct {
+	buf  []T
+	head int
+	len  int
+}
+
+// NewDeque returns a deque with room for capacity elements, rounded up to a power of two.
+func NewDeque[T any](capacity int) *Deque[T] {
+	d := &Deque[T]{}
+	d.Grow(capacity)
+	return d
+}
+
+func (d *Deque[T]) Len() int {
+	return d.len
+}
+
+func (d *Deque[T]) Cap() int {
+	return len(d.buf)
+}
+
+// Grow makes room for at least n elements without further allocation.
+func (d *Deque[T]) Grow(n int) {
+	if n <= len(d.buf) {
+		return
+	}
+	size := minDequeCapacity
+	for size < n {
+		size <<= 1
+	}
+	buf := make([]T, size)
+	d.copyTo(buf)
+	d.buf = buf
+	d.head = 0
+}
+
+func (d *Deque[T]) PushBack(v T) {
+	d.growIfFull()
+	d.buf[(d.head+d.len)&(len(d.buf)-1)] = v
+	d.len++
+}
+
+func (d *Deque[T]) PushFront(v T) {
+	d.growIfFull()
+	d.head = (d.head - 1) & (len(d.buf) - 1)
+	d.buf[d.head] = v
+	d.len++
+}
+
+// PopFront removes and returns the first element, it panics if the deque is empty.
+func (d *Deque[T]) PopFront() T {
+	if d.len == 0 {
+		panic("ring: PopFront of empty Deque")
+	}
+	var zero T
+	v := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = (d.head + 1) & (len(d.buf) - 1)
+	d.len--
+	return v
+}
+
+// PopBack removes and returns the last element, it panics if the deque is empty.
+func (d *Deque[T]) PopBack() T {
+	if d.len == 0 {
+		panic("ring: PopBack of empty Deque")
+	}
+	var zero T
+	i := (d.head + d.len - 1) & (len(d.buf) - 1)
+	v := d.buf[i]
+	d.buf[i] = zero
+	d.len--
+	return v
+}
+
+// Front returns the first element, it panics if the deque is empty.
+func (d *Deque[T]) Front() T {
+	return d.At(0)
+}
+
+// Back returns the last element, it panics if the deque is empty.
+func (d *Deque[T]) Back() T {
+	return d.At(d.len - 1)
+}
+
+// At returns the element at i, counted from the front, it panics if i is out of range.
+func (d *Deque[T]) At(i int) T {
+	if i < 0 || i >= d.len {
+		panic("ring: Deque index out of range")
+	}
+	return d.buf[(d.head+i)&(len(d.buf)-1)]
+}
+
+// Clear removes all elements, keeping the storage. Elements are zeroed so they can be garbage collected.
+func (d *Deque[T]) Clear() {
+	var zero T
+	for i := 0; i < d.len; i++ {
+		d.buf[(d.head+i)&(len(d.buf)-1)] = zero
+	}
+	d.head = 0
+	d.len = 0
+}
+
+func (d *Deque[T]) growIfFull() {
+	if d.len == len(d.buf) {
+		d.Grow(d.len + 1)
+	}
+}
+
+// copyTo copies the elements, front first, to the start of buf
+func (d *Deque[T]) copyTo(buf []T) {
+	if d.len == 0 {
+		return
+	}
+	end := d.head + d.len
+	if end <= len(d.buf) {
+		copy(buf, d.buf[d.head:end])
+		return
+	}
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:end-len(d.buf)])
+}
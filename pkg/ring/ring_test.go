@@ -0,0 +1,111 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ring
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeque(t *testing.T) {
+	var d Deque[int]
+	require.Equal(t, 0, d.Len())
+
+	// wraps around and grows with elements on both sides of the end of the ring
+	for i := 0; i < 10; i++ {
+		d.PushBack(i)
+	}
+	for i := 0; i < 8; i++ {
+		require.Equal(t, i, d.PopFront())
+	}
+	for i := 10; i < 30; i++ {
+		d.PushBack(i)
+	}
+	d.PushFront(7)
+	require.Equal(t, 23, d.Len())
+	require.Equal(t, 32, d.Cap())
+	require.Equal(t, 7, d.Front())
+	require.Equal(t, 29, d.Back())
+	for i := 0; i < d.Len(); i++ {
+		require.Equal(t, i+7, d.At(i))
+	}
+	require.Equal(t, 29, d.PopBack())
+
+	d.Clear()
+	require.Equal(t, 0, d.Len())
+	require.Equal(t, 32, d.Cap())
+	require.Panics(t, func() { d.PopFront() })
+	require.Panics(t, func() { d.PopBack() })
+	require.Panics(t, func() { d.At(0) })
+
+	require.Equal(t, 64, NewDeque[int](33).Cap())
+}
+
+func TestDequeSteadyStateDoesNotAllocate(t *testing.T) {
+	d := NewDeque[*int](64)
+	v := 1
+	allocs := testing.AllocsPerRun(100, func() {
+		for i := 0; i < 50; i++ {
+			d.PushBack(&v)
+		}
+		for d.Len() != 0 {
+			d.PopFront()
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func TestBuffer(t *testing.T) {
+	b := NewBuffer[int](3)
+	require.Equal(t, 3, b.Cap())
+
+	for i := 0; i < 3; i++ {
+		_, dropped := b.Push(i)
+		require.False(t, dropped)
+	}
+	v, dropped := b.Push(3)
+	require.True(t, dropped)
+	require.Equal(t, 0, v)
+
+	require.Equal(t, 3, b.Len())
+	require.Equal(t, 1, b.At(0))
+	require.Equal(t, 3, b.Newest())
+	require.Equal(t, []int{1, 2, 3}, b.AppendTo(nil))
+
+	b.Clear()
+	require.Empty(t, b.AppendTo(nil))
+}
+
+func BenchmarkDeque(b *testing.B) {
+	d := NewDeque[int](64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.PushBack(i)
+		if d.Len() > 32 {
+			d.PopFront()
+		}
+	}
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	buf := NewBuffer[int](256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Push(i)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roc
+
+import (
+	"fmt"
+)
+
+type Result int
+
+const (
+	// in order, possibly after a gap, the highest index advanced
+	ResultInOrder Result = iota
+	// behind the highest index, indexed in the rollover cycle it was sent in
+	ResultReordered
+	// a jump beyond MaxDropout ahead or MaxMisorder behind, indexed as SRTP would, the highest index is kept until
+	// the next packet confirms a restart
+	ResultJump
+	// the packet after a jump followed it, the stream continues from the jump
+	ResultRestart
+	// before the first packet of the stream in its first rollover cycle, it cannot be indexed
+	ResultTooOld
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultInOrder:
+		return "IN_ORDER"
+	case ResultReordered:
+		return "REORDERED"
+	case ResultJump:
+		return "JUMP"
+	case ResultRestart:
+		return "RESTART"
+	case ResultTooOld:
+		return "TOO_OLD"
+	default:
+		return fmt.Sprintf("%d", int(r))
+	}
+}
+
+type TrackerParams struct {
+	// sequence numbers up to this far ahead of the highest one are in order, as in RFC 3550, appendix A.1
+	MaxDropout uint16
+	// sequence numbers up to this far behind the highest one are reordered
+	MaxMisorder uint16
+}
+
+var TrackerParamsDefault = TrackerParams{
+	MaxDropout:  3000,
+	MaxMisorder: 100,
+}
+
+// TrackerState is the serializable state of a Tracker, to continue indexing a stream on another node.
+type TrackerState struct {
+	Initialized bool   `json:"initialized"`
+	Highest     uint64 `json:"highest"`
+	HasJump     bool   `json:"has_jump,omitempty"`
+	// index the stream continues from if the jump is confirmed
+	JumpIndex uint64 `json:"jump_index,omitempty"`
+}
+
+// Tracker extends the 16 bit RTP sequence numbers of a stream to 48 bit packet indices, rollover counter (ROC)
+// and sequence number, estimated as in SRTP (RFC 3711, section 3.3.1), so that indices match those of an SRTP
+// context of the stream. The first packet starts the stream in rollover cycle 0.
+//
+// Sequence number jumps, for example when a sender restarts with the same SSRC, are confirmed by the next packet,
+// the stream then continues forward from the jump so that indices stay increasing. An SRTP context of the stream
+// is out of sync after a restart, callers reset it on ResultRestart.
+//
+// A Tracker is not safe for concurrent use.
+type Tracker struct {
+	params TrackerParams
+	state  TrackerState
+}
+
+func NewTracker(params TrackerParams) *Tracker {
+	if params.MaxDropout == 0 {
+		params.MaxDropout = TrackerParamsDefault.MaxDropout
+	}
+	if params.MaxMisorder == 0 {
+		params.MaxMisorder = TrackerParamsDefault.MaxMisorder
+	}
+	return &Tracker{
+		params: params,
+	}
+}
+
+// NewTrackerFromState creates a tracker that continues from the state of another one.
+func NewTrackerFromState(params TrackerParams, state TrackerState) *Tracker {
+	t := NewTracker(params)
+	t.state = state
+	return t
+}
+
+func (t *Tracker) State() TrackerState {
+	return t.state
+}
+
+// ROC returns the rollover counter of the highest index.
+func (t *Tracker) ROC() uint32 {
+	return uint32(t.state.Highest >> 16)
+}
+
+// Highest returns the highest index, false before the first packet.
+func (t *Tracker) Highest() (uint64, bool) {
+	return t.state.Highest, t.state.Initialized
+}
+
+// Update returns the index of a packet with sequence number sn.
+func (t *Tracker) Update(sn uint16) (uint64, Result) {
+	s := &t.state
+	if !s.Initialized {
+		s.Initialized = true
+		s.Highest = uint64(sn)
+		return s.Highest, ResultInOrder
+	}
+
+	ahead := sn - uint16(s.Highest)
+	switch {
+	case ahead < t.params.MaxDropout:
+		s.HasJump = false
+		s.Highest += uint64(ahead)
+		return s.Highest, ResultInOrder
+
+	case ahead >= -t.params.MaxMisorder:
+		behind := uint64(-ahead)
+		if behind > s.Highest {
+			return 0, ResultTooOld
+		}
+		return s.Highest - behind, ResultReordered
+	}
+
+	// a jump, continuing from the previous one confirms a restart
+	if s.HasJump && sn == uint16(s.JumpIndex+1) {
+		s.HasJump = false
+		s.Highest = s.JumpIndex + 1
+		return s.Highest, ResultRestart
+	}
+
+	s.HasJump = true
+	s.JumpIndex = s.Highest + uint64(ahead)
+	// SRTP estimate, the rollover cycle closest to the highest index
+	if int16(ahead) < 0 {
+		behind := uint64(-ahead)
+		if behind > s.Highest {
+			return 0, ResultTooOld
+		}
+		return s.Highest - behind, ResultJump
+	}
+	return s.Highest + uint64(ahead), ResultJump
+}
+
+// Reset forgets the stream, the next packet starts it again in rollover cycle 0.
+func (t *Tracker) Reset() {
+	t.state = TrackerState{}
+}
@@ -0,0 +1,105 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker(TrackerParams{})
+	_, ok := tr.Highest()
+	require.False(t, ok)
+
+	index, result := tr.Update(65530)
+	require.Equal(t, uint64(65530), index)
+	require.Equal(t, ResultInOrder, result)
+
+	index, result = tr.Update(65520)
+	require.Equal(t, uint64(65520), index)
+	require.Equal(t, ResultReordered, result)
+	_, result = tr.Update(10)
+	require.Equal(t, ResultInOrder, result)
+	require.Equal(t, uint32(1), tr.ROC())
+
+	// reordered across the rollover, indexed in the previous cycle
+	index, result = tr.Update(65535)
+	require.Equal(t, uint64(65535), index)
+	require.Equal(t, ResultReordered, result)
+
+	// gap within the dropout
+	index, result = tr.Update(2000)
+	require.Equal(t, uint64(1<<16|2000), index)
+	require.Equal(t, ResultInOrder, result)
+
+	// a lone jump does not move the highest index
+	index, result = tr.Update(40000)
+	require.Equal(t, uint64(40000), index)
+	require.Equal(t, ResultJump, result)
+	highest, _ := tr.Highest()
+	require.Equal(t, uint64(1<<16|2000), highest)
+	index, result = tr.Update(2001)
+	require.Equal(t, uint64(1<<16|2001), index)
+	require.Equal(t, ResultInOrder, result)
+
+	// a restart backwards, confirmed by the next packet, continues forward
+	_, result = tr.Update(500)
+	require.Equal(t, ResultJump, result)
+	index, result = tr.Update(501)
+	require.Equal(t, ResultRestart, result)
+	require.Equal(t, uint64(2<<16|501), index)
+	require.Equal(t, uint32(2), tr.ROC())
+}
+
+func TestTrackerTooOld(t *testing.T) {
+	tr := NewTracker(TrackerParams{})
+	tr.Update(5)
+	_, result := tr.Update(65530)
+	require.Equal(t, ResultTooOld, result)
+	_, result = tr.Update(40000)
+	require.Equal(t, ResultTooOld, result)
+}
+
+func TestTrackersSnapshot(t *testing.T) {
+	trackers := NewTrackers(TrackerParams{})
+	trackers.Update(1, 65535)
+	trackers.Update(1, 0)
+	trackers.Update(2, 100)
+	_, ok := trackers.ROC(3)
+	require.False(t, ok)
+
+	data, err := MarshalSnapshot(trackers.Snapshot())
+	require.NoError(t, err)
+	snapshot, err := UnmarshalSnapshot(data)
+	require.NoError(t, err)
+	require.Len(t, snapshot.Trackers, 2)
+
+	migrated := NewTrackersFromSnapshot(TrackerParams{}, snapshot)
+	roc, ok := migrated.ROC(1)
+	require.True(t, ok)
+	require.Equal(t, uint32(1), roc)
+	index, result := migrated.Update(1, 1)
+	require.Equal(t, uint64(1<<16|1), index)
+	require.Equal(t, ResultInOrder, result)
+
+	migrated.Remove(1)
+	_, ok = migrated.ROC(1)
+	require.False(t, ok)
+
+	_, err = UnmarshalSnapshot([]byte(`{"version":2}`))
+	require.ErrorIs(t, err, ErrUnsupportedSnapshotVersion)
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package roc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotVersion = 1
+)
+
+var (
+	ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+)
+
+// Snapshot is the serializable state of the trackers of a Trackers.
+type Snapshot struct {
+	Version    int                     `json:"version"`
+	CapturedAt time.Time               `json:"captured_at"`
+	Trackers   map[uint32]TrackerState `json:"trackers"`
+}
+
+// Trackers tracks the packet indices of several streams, by SSRC.
+type Trackers struct {
+	params TrackerParams
+
+	lock     sync.Mutex
+	trackers map[uint32]*Tracker
+}
+
+func NewTrackers(params TrackerParams) *Trackers {
+	return &Trackers{
+		params:   params,
+		trackers: make(map[uint32]*Tracker),
+	}
+}
+
+// NewTrackersFromSnapshot creates trackers that continue from a snapshot of others.
+func NewTrackersFromSnapshot(params TrackerParams, snapshot *Snapshot) *Trackers {
+	t := NewTrackers(params)
+	for ssrc, state := range snapshot.Trackers {
+		t.trackers[ssrc] = NewTrackerFromState(params, state)
+	}
+	return t
+}
+
+// Update returns the index of a packet of stream ssrc with sequence number sn.
+func (t *Trackers) Update(ssrc uint32, sn uint16) (uint64, Result) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tracker := t.trackers[ssrc]
+	if tracker == nil {
+		tracker = NewTracker(t.params)
+		t.trackers[ssrc] = tracker
+	}
+	return tracker.Update(sn)
+}
+
+// ROC returns the rollover counter of stream ssrc, false if it has no packets.
+func (t *Trackers) ROC(ssrc uint32) (uint32, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tracker := t.trackers[ssrc]
+	if tracker == nil || !tracker.state.Initialized {
+		return 0, false
+	}
+	return tracker.ROC(), true
+}
+
+func (t *Trackers) Remove(ssrc uint32) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.trackers, ssrc)
+}
+
+func (t *Trackers) Snapshot() *Snapshot {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snapshot := &Snapshot{
+		Trackers: make(map[uint32]TrackerState, len(t.trackers)),
+	}
+	for ssrc, tracker := range t.trackers {
+		snapshot.Trackers[ssrc] = tracker.State()
+	}
+	return snapshot
+}
+
+// MarshalSnapshot sets the version and, if not set, the capture time on a copy, the given snapshot is not modified.
+func MarshalSnapshot(s *Snapshot) ([]byte, error) {
+	c := *s
+	c.Version = snapshotVersion
+	if c.CapturedAt.IsZero() {
+		c.CapturedAt = time.Now()
+	}
+	return json.Marshal(&c)
+}
+
+func UnmarshalSnapshot(data []byte) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w, version: %d", ErrUnsupportedSnapshotVersion, s.Version)
+	}
+	return s, nil
+}
@@ -0,0 +1,228 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+	"golang.org/x/exp/slices"
+)
+
+// AddressSource is how an advertised address was discovered.
+type AddressSource string
+
+const (
+	// NodeIP set in the config
+	AddressSourceConfigured AddressSource = "configured"
+	// node IP read from the Kubernetes node
+	AddressSourceKubernetes AddressSource = "kubernetes"
+	// resolved with STUN
+	AddressSourceSTUN AddressSource = "stun"
+	// resolved with STUN by an earlier run, see ExternalIPCacheConfig
+	AddressSourceCache AddressSource = "cache"
+	// local address advertised because the external IP could not be resolved, see ExternalIPPolicy
+	AddressSourceFallback AddressSource = "fallback"
+	// address of a local interface
+	AddressSourceInterface AddressSource = "interface"
+	// StaticHostCandidates
+	AddressSourceStatic AddressSource = "static"
+)
+
+// AdvertisedAddress is an address advertised in candidates.
+type AdvertisedAddress struct {
+	IP string `json:"ip"`
+	// for NAT 1:1 mappings, the local IP traffic to IP arrives on
+	LocalIP string `json:"local_ip,omitempty"`
+	// 0 when advertised with the port of each mux
+	Port   int           `json:"port,omitempty"`
+	Source AddressSource `json:"source"`
+}
+
+// AddressBookEntry is a version of the set of advertised addresses.
+type AddressBookEntry struct {
+	Version   int                 `json:"version"`
+	Time      time.Time           `json:"time"`
+	Addresses []AdvertisedAddress `json:"addresses"`
+}
+
+func (e AddressBookEntry) Contains(ip string) bool {
+	for _, a := range e.Addresses {
+		if a.IP == ip || a.LocalIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+type AddressBookParams struct {
+	// versions kept, the oldest are dropped beyond this
+	MaxVersions int
+}
+
+var AddressBookParamsDefault = AddressBookParams{
+	MaxVersions: 100,
+}
+
+// AddressBook keeps the history of addresses the node advertised, recorded by NewWebRTCConfig, to tell which
+// addresses a client may have been given at a point in time, for example after a failover changed the external IP.
+type AddressBook struct {
+	params AddressBookParams
+
+	lock        sync.Mutex
+	versions    []AddressBookEntry
+	lastVersion int
+}
+
+func NewAddressBook(params AddressBookParams) *AddressBook {
+	if params.MaxVersions <= 0 {
+		params.MaxVersions = AddressBookParamsDefault.MaxVersions
+	}
+	return &AddressBook{
+		params: params,
+	}
+}
+
+// Record sets the advertised addresses, returning the new version, or the current one and false if they are unchanged.
+func (b *AddressBook) Record(addresses []AdvertisedAddress) (AddressBookEntry, bool) {
+	addresses = slices.Clone(addresses)
+	sort.Slice(addresses, func(i, j int) bool {
+		a, b := addresses[i], addresses[j]
+		if a.IP != b.IP {
+			return a.IP < b.IP
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.LocalIP != b.LocalIP {
+			return a.LocalIP < b.LocalIP
+		}
+		return a.Source < b.Source
+	})
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if n := len(b.versions); n != 0 && slices.Equal(b.versions[n-1].Addresses, addresses) {
+		return b.versions[n-1], false
+	}
+
+	b.lastVersion++
+	entry := AddressBookEntry{
+		Version:   b.lastVersion,
+		Time:      time.Now(),
+		Addresses: addresses,
+	}
+	b.versions = append(b.versions, entry)
+	if len(b.versions) > b.params.MaxVersions {
+		b.versions = slices.Delete(b.versions, 0, len(b.versions)-b.params.MaxVersions)
+	}
+	return entry, true
+}
+
+// Current returns the latest version, false if nothing was recorded.
+func (b *AddressBook) Current() (AddressBookEntry, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.versions) == 0 {
+		return AddressBookEntry{}, false
+	}
+	return b.versions[len(b.versions)-1], true
+}
+
+// History returns the versions kept, oldest first.
+func (b *AddressBook) History() []AddressBookEntry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return slices.Clone(b.versions)
+}
+
+// At returns the version in effect at t, false if t is before the oldest version kept.
+func (b *AddressBook) At(t time.Time) (AddressBookEntry, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for i := len(b.versions) - 1; i >= 0; i-- {
+		if !b.versions[i].Time.After(t) {
+			return b.versions[i], true
+		}
+	}
+	return AddressBookEntry{}, false
+}
+
+// Find returns the versions which advertised ip, as advertised or local IP, oldest first.
+func (b *AddressBook) Find(ip string) []AddressBookEntry {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var found []AddressBookEntry
+	for _, entry := range b.versions {
+		if entry.Contains(ip) {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// recordAdvertisedAddresses records the addresses a WebRTC config created from conf advertises
+func (conf *RTCConfig) recordAdvertisedAddresses(nat1to1IPs []string, ipFilter func(net.IP) bool, staticHostCandidates []StaticHostCandidate) {
+	if conf.AddressBook == nil {
+		conf.AddressBook = NewAddressBook(AddressBookParamsDefault)
+	}
+
+	var addresses []AdvertisedAddress
+	switch {
+	case len(nat1to1IPs) != 0:
+		for _, mapping := range nat1to1IPs {
+			external, local, _ := strings.Cut(mapping, "/")
+			source := conf.natMappingSource
+			if external == local {
+				// no external IP resolved for the local IP
+				source = AddressSourceInterface
+			}
+			addresses = append(addresses, AdvertisedAddress{IP: external, LocalIP: local, Source: source})
+		}
+	case conf.NodeIP != "" && (conf.UseExternalIP || !conf.NodeIPAutoGenerated):
+		source := conf.nodeIPSource
+		if conf.UseExternalIP {
+			// no local address mapped to an external IP
+			source = AddressSourceFallback
+		} else if source == "" {
+			source = AddressSourceConfigured
+		}
+		addresses = append(addresses, AdvertisedAddress{IP: conf.NodeIP, Source: source})
+	default:
+		localIPs, _ := GetLocalIPAddresses(conf.EnableLoopbackCandidate, nil)
+		for _, ip := range localIPs {
+			if ipFilter != nil && !ipFilter(net.ParseIP(ip)) {
+				continue
+			}
+			addresses = append(addresses, AdvertisedAddress{IP: ip, Source: AddressSourceInterface})
+		}
+	}
+	for _, c := range staticHostCandidates {
+		addresses = append(addresses, AdvertisedAddress{IP: c.IP.String(), Port: c.Port, Source: AddressSourceStatic})
+	}
+
+	if entry, changed := conf.AddressBook.Record(addresses); changed {
+		logger.Infow("advertised addresses changed", "version", entry.Version, "addresses", entry.Addresses)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressBook(t *testing.T) {
+	b := NewAddressBook(AddressBookParams{MaxVersions: 2})
+	_, ok := b.Current()
+	require.False(t, ok)
+
+	first, changed := b.Record([]AdvertisedAddress{
+		{IP: "203.0.113.2", LocalIP: "10.0.0.2", Source: AddressSourceSTUN},
+		{IP: "203.0.113.1", LocalIP: "10.0.0.1", Source: AddressSourceSTUN},
+	})
+	require.True(t, changed)
+	require.Equal(t, 1, first.Version)
+	require.Equal(t, "203.0.113.1", first.Addresses[0].IP)
+
+	// same addresses in another order
+	entry, changed := b.Record([]AdvertisedAddress{
+		{IP: "203.0.113.1", LocalIP: "10.0.0.1", Source: AddressSourceSTUN},
+		{IP: "203.0.113.2", LocalIP: "10.0.0.2", Source: AddressSourceSTUN},
+	})
+	require.False(t, changed)
+	require.Equal(t, first, entry)
+
+	// failover
+	time.Sleep(time.Millisecond)
+	second, changed := b.Record([]AdvertisedAddress{{IP: "198.51.100.1", LocalIP: "10.0.0.1", Source: AddressSourceSTUN}})
+	require.True(t, changed)
+	require.Equal(t, 2, second.Version)
+
+	found := b.Find("203.0.113.2")
+	require.Len(t, found, 1)
+	require.Equal(t, 1, found[0].Version)
+	require.Len(t, b.Find("10.0.0.1"), 2)
+
+	at, ok := b.At(second.Time.Add(-time.Nanosecond))
+	require.True(t, ok)
+	require.Equal(t, 1, at.Version)
+	_, ok = b.At(first.Time.Add(-time.Nanosecond))
+	require.False(t, ok)
+
+	third, _ := b.Record(nil)
+	history := b.History()
+	require.Len(t, history, 2)
+	require.Equal(t, 2, history[0].Version)
+	current, ok := b.Current()
+	require.True(t, ok)
+	require.Equal(t, third, current)
+}
+
+func TestRecordAdvertisedAddresses(t *testing.T) {
+	conf := &RTCConfig{NodeIP: "203.0.113.1"}
+	static := []StaticHostCandidate{{IP: net.ParseIP("198.51.100.1"), Port: 7881, Protocol: webrtc.ICEProtocolUDP}}
+	conf.recordAdvertisedAddresses(nil, nil, static)
+	current, ok := conf.AddressBook.Current()
+	require.True(t, ok)
+	require.Equal(t, []AdvertisedAddress{
+		{IP: "198.51.100.1", Port: 7881, Source: AddressSourceStatic},
+		{IP: "203.0.113.1", Source: AddressSourceConfigured},
+	}, current.Addresses)
+
+	conf = &RTCConfig{NodeIP: "203.0.113.1", UseExternalIP: true, NodeIPAutoGenerated: true, natMappingSource: AddressSourceCache}
+	conf.recordAdvertisedAddresses([]string{"203.0.113.1/10.0.0.1", "10.0.0.2/10.0.0.2"}, nil, nil)
+	current, _ = conf.AddressBook.Current()
+	require.Equal(t, []AdvertisedAddress{
+		{IP: "10.0.0.2", LocalIP: "10.0.0.2", Source: AddressSourceInterface},
+		{IP: "203.0.113.1", LocalIP: "10.0.0.1", Source: AddressSourceCache},
+	}, current.Addresses)
+
+	// no local address mapped
+	conf.recordAdvertisedAddresses(nil, nil, nil)
+	current, _ = conf.AddressBook.Current()
+	require.Equal(t, 2, current.Version)
+	require.Equal(t, []AdvertisedAddress{{IP: "203.0.113.1", Source: AddressSourceFallback}}, current.Addresses)
+}
@@ -0,0 +1,108 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"net"
+	"sync"
+
+	"github.com/pion/ice/v2"
+
+	"github.com/livekit/mediatransportutil/pkg/admission"
+)
+
+// admissionGate admits a connection, identified by its ICE ufrag, once across the muxes sharing the gate
+type admissionGate struct {
+	controller admission.Controller
+
+	lock     sync.Mutex
+	admitted map[string]struct{}
+}
+
+func newAdmissionGate(controller admission.Controller) *admissionGate {
+	return &admissionGate{
+		controller: controller,
+		admitted:   make(map[string]struct{}),
+	}
+}
+
+func (g *admissionGate) admit(ufrag string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.admitted[ufrag]; ok {
+		return nil
+	}
+	// the bitrate of the connection is not known to the mux
+	if err := g.controller.Admit(admission.Request{}); err != nil {
+		return err
+	}
+	g.admitted[ufrag] = struct{}{}
+	return nil
+}
+
+func (g *admissionGate) forget(ufrag string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	delete(g.admitted, ufrag)
+}
+
+// ------------------------------------------------
+
+// admissionUDPMux consults the admission controller before handing out the mux connection of a new ufrag,
+// gathering fails for the ICE agent of a rejected connection
+type admissionUDPMux struct {
+	ice.UDPMux
+
+	gate *admissionGate
+}
+
+func (m *admissionUDPMux) GetConn(ufrag string, addr net.Addr) (net.PacketConn, error) {
+	if err := m.gate.admit(ufrag); err != nil {
+		return nil, err
+	}
+	return m.UDPMux.GetConn(ufrag, addr)
+}
+
+func (m *admissionUDPMux) RemoveConnByUfrag(ufrag string) {
+	m.gate.forget(ufrag)
+	m.UDPMux.RemoveConnByUfrag(ufrag)
+}
+
+var _ ice.UDPMux = (*admissionUDPMux)(nil)
+
+// ------------------------------------------------
+
+// admissionTCPMux is admissionUDPMux for the ICE-TCP mux
+type admissionTCPMux struct {
+	ice.TCPMux
+
+	gate *admissionGate
+}
+
+func (m *admissionTCPMux) GetConnByUfrag(ufrag string, isIPv6 bool, local net.IP) (net.PacketConn, error) {
+	if err := m.gate.admit(ufrag); err != nil {
+		return nil, err
+	}
+	return m.TCPMux.GetConnByUfrag(ufrag, isIPv6, local)
+}
+
+func (m *admissionTCPMux) RemoveConnByUfrag(ufrag string) {
+	m.gate.forget(ufrag)
+	m.TCPMux.RemoveConnByUfrag(ufrag)
+}
+
+var _ ice.TCPMux = (*admissionTCPMux)(nil)
@@ -0,0 +1,72 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"net"
+	"testing"
+
+	"github.com/pion/ice/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/admission"
+)
+
+type testUDPMux struct {
+	ice.UDPMux
+
+	numConns int
+}
+
+func (m *testUDPMux) GetConn(_ string, _ net.Addr) (net.PacketConn, error) {
+	m.numConns++
+	return nil, nil
+}
+
+func (m *testUDPMux) RemoveConnByUfrag(_ string) {}
+
+func Test_AdmissionUDPMux(t *testing.T) {
+	numAdmits := 0
+	admit := true
+	gate := newAdmissionGate(admission.ControllerFunc(func(_ admission.Request) error {
+		numAdmits++
+		if admit {
+			return nil
+		}
+		return admission.ErrOverloaded
+	}))
+	inner := &testUDPMux{}
+	mux := &admissionUDPMux{UDPMux: inner, gate: gate}
+
+	// admitted once per ufrag, across listen addresses
+	_, err := mux.GetConn("ufrag1", &net.UDPAddr{Port: 7882})
+	require.NoError(t, err)
+	_, err = mux.GetConn("ufrag1", &net.UDPAddr{Port: 7883})
+	require.NoError(t, err)
+	require.Equal(t, 1, numAdmits)
+	require.Equal(t, 2, inner.numConns)
+
+	// rejected before the mux is asked for a connection
+	admit = false
+	_, err = mux.GetConn("ufrag2", &net.UDPAddr{Port: 7882})
+	require.ErrorIs(t, err, admission.ErrOverloaded)
+	require.Equal(t, 2, inner.numConns)
+
+	// a removed connection is admitted again
+	mux.RemoveConnByUfrag("ufrag1")
+	_, err = mux.GetConn("ufrag1", &net.UDPAddr{Port: 7882})
+	require.ErrorIs(t, err, admission.ErrOverloaded)
+	require.Equal(t, 3, numAdmits)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slices"
+)
+
+// StaticHostCandidate is a host candidate advertised in addition to gathered candidates,
+// for addresses not present on any local interface (e.g. anycast or DNAT frontends).
+// Traffic to it must reach the UDP or TCP mux on the same port.
+type StaticHostCandidate struct {
+	IP       net.IP
+	Port     int
+	Protocol webrtc.ICEProtocol
+}
+
+// ParseStaticHostCandidate parses "<ip>:<port>" with an optional "/udp" or "/tcp" suffix, udp by default.
+func ParseStaticHostCandidate(str string) (StaticHostCandidate, error) {
+	protocol := webrtc.ICEProtocolUDP
+	if idx := strings.LastIndex(str, "/"); idx != -1 {
+		p, err := webrtc.NewICEProtocol(str[idx+1:])
+		if err != nil {
+			return StaticHostCandidate{}, fmt.Errorf("invalid static host candidate %s: %v", str, err)
+		}
+		protocol = p
+		str = str[:idx]
+	}
+
+	host, portStr, err := net.SplitHostPort(str)
+	if err != nil {
+		return StaticHostCandidate{}, fmt.Errorf("invalid static host candidate %s: %v", str, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() {
+		return StaticHostCandidate{}, fmt.Errorf("invalid static host candidate %s, must be a unicast IP", str)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return StaticHostCandidate{}, fmt.Errorf("invalid static host candidate %s, invalid port", str)
+	}
+
+	return StaticHostCandidate{
+		IP:       ip,
+		Port:     port,
+		Protocol: protocol,
+	}, nil
+}
+
+func (c StaticHostCandidate) String() string {
+	return fmt.Sprintf("%s/%s", net.JoinHostPort(c.IP.String(), strconv.Itoa(c.Port)), c.Protocol)
+}
+
+// ICECandidateInit returns the candidate to add to the local description or to trickle.
+func (c StaticHostCandidate) ICECandidateInit(mid string, mLineIndex uint16) (webrtc.ICECandidateInit, error) {
+	network := "udp"
+	tcpType := ice.TCPTypeUnspecified
+	if c.Protocol == webrtc.ICEProtocolTCP {
+		network = "tcp"
+		tcpType = ice.TCPTypePassive
+	}
+	candidate, err := ice.NewCandidateHost(&ice.CandidateHostConfig{
+		Network:   network,
+		Address:   c.IP.String(),
+		Port:      c.Port,
+		Component: ice.ComponentRTP,
+		TCPType:   tcpType,
+	})
+	if err != nil {
+		return webrtc.ICECandidateInit{}, err
+	}
+
+	return webrtc.ICECandidateInit{
+		Candidate:     "candidate:" + candidate.Marshal(),
+		SDPMid:        &mid,
+		SDPMLineIndex: &mLineIndex,
+	}, nil
+}
+
+// ------------------------------------------------
+
+func (conf *RTCConfig) parseStaticHostCandidates() ([]StaticHostCandidate, error) {
+	candidates := make([]StaticHostCandidate, 0, len(conf.StaticHostCandidates))
+	for _, str := range conf.StaticHostCandidates {
+		c, err := ParseStaticHostCandidate(str)
+		if err != nil {
+			return nil, err
+		}
+
+		// ephemeral ports are not known up front, only a mux can receive traffic for a fixed address
+		switch c.Protocol {
+		case webrtc.ICEProtocolUDP:
+			if conf.ForceTCP || conf.ICEPortRangeStart != 0 || !slices.Contains(conf.udpMuxPorts(), c.Port) {
+				return nil, fmt.Errorf("static host candidate %s is not served by the UDP mux", c)
+			}
+		case webrtc.ICEProtocolTCP:
+			if passive, _ := conf.iceTCPModes(); !passive || int(conf.TCPPort) != c.Port {
+				return nil, fmt.Errorf("static host candidate %s is not served by the TCP mux", c)
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
+
+// udpMuxPorts returns the ports the UDP mux listens on
+func (conf *RTCConfig) udpMuxPorts() []int {
+	if !conf.UDPPort.Valid() {
+		return nil
+	}
+
+	availablePorts := conf.UDPPort.ToSlice()
+	ports := make([]int, 0, len(availablePorts))
+	for i := 0; i < runtime.NumCPU() && i < len(availablePorts); i++ {
+		ports = append(ports, availablePorts[i])
+	}
+	return ports
+}
@@ -15,13 +15,20 @@
 package rtcconfig
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/livekit/protocol/logger"
 	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/mediatransportutil/pkg/admission"
+	"github.com/livekit/mediatransportutil/pkg/bwe"
+	"github.com/livekit/mediatransportutil/pkg/transport"
 )
 
 const (
@@ -31,6 +38,11 @@ const (
 	minUDPBufferSize       = 5_000_000
 	writeBufferSizeInBytes = 4 * 1024 * 1024
 	defaultUDPBufferSize   = 16_777_216
+
+	// ICE agent defaults
+	defaultICEConsentCheckInterval       = 2 * time.Second
+	defaultICEConsentDisconnectedTimeout = 5 * time.Second
+	defaultICEConsentFailedTimeout       = 25 * time.Second
 )
 
 var DefaultStunServers = []string{
@@ -40,27 +52,112 @@ var DefaultStunServers = []string{
 }
 
 type RTCConfig struct {
-	UDPPort                 PortRange        `yaml:"udp_port,omitempty"`
-	TCPPort                 uint32           `yaml:"tcp_port,omitempty"`
-	ICEPortRangeStart       uint32           `yaml:"port_range_start,omitempty"`
-	ICEPortRangeEnd         uint32           `yaml:"port_range_end,omitempty"`
-	NodeIP                  string           `yaml:"node_ip,omitempty"`
-	NodeIPAutoGenerated     bool             `yaml:"-"`
-	STUNServers             []string         `yaml:"stun_servers,omitempty"`
+	// bundle of tuning values, fields set below override those of the profile, see Tuning
+	Profile             TuningProfile `yaml:"profile,omitempty"`
+	UDPPort             PortRange     `yaml:"udp_port,omitempty"`
+	TCPPort             uint32        `yaml:"tcp_port,omitempty"`
+	ICEPortRangeStart   uint32        `yaml:"port_range_start,omitempty"`
+	ICEPortRangeEnd     uint32        `yaml:"port_range_end,omitempty"`
+	NodeIP              string        `yaml:"node_ip,omitempty"`
+	NodeIPAutoGenerated bool          `yaml:"-"`
+	STUNServers         []string      `yaml:"stun_servers,omitempty"`
+	// TURN servers to gather relay candidates through, for nodes behind restrictive firewalls
+	TURNServers []TURNServerConfig `yaml:"turn_servers,omitempty"`
+	// gather and use relay candidates only, requires TURNServers
+	ForceRelay              bool             `yaml:"force_relay,omitempty"`
 	UseExternalIP           bool             `yaml:"use_external_ip"`
 	UseICELite              bool             `yaml:"use_ice_lite,omitempty"`
 	Interfaces              InterfacesConfig `yaml:"interfaces,omitempty"`
 	IPs                     IPsConfig        `yaml:"ips,omitempty"`
 	EnableLoopbackCandidate bool             `yaml:"enable_loopback_candidate"`
 	UseMDNS                 bool             `yaml:"use_mdns,omitempty"`
+	// what to do when Interfaces or IPs filters cannot be evaluated, fail closed when not set
+	FilterPolicy FilterPolicy `yaml:"filter_policy,omitempty"`
+	// called when Interfaces or IPs filters cannot be evaluated, with the outcome of FilterPolicy
+	OnFilterFailure func(failure transport.FilterFailure) `yaml:"-"`
 	// when UseExternalIP is true, only advertise the external IP to client
 	ExternalIPOnly bool          `yaml:"external_ip_only,omitempty"`
 	BatchIO        BatchIOConfig `yaml:"batch_io,omitempty"`
+	// receive and send buffer size of UDPPort sockets
+	UDPBufferSize int `yaml:"udp_buffer_size,omitempty"`
+	// ICE consent freshness (RFC 7675) checks
+	ICEConsent ICEConsentConfig `yaml:"ice_consent,omitempty"`
+	// ICE-TCP (RFC 6544) candidate types to use, empty means passive candidates on TCPPort
+	// and active candidates toward remote passive candidates when TCPPort is set
+	ICETCPModes []ICETCPMode `yaml:"ice_tcp_modes,omitempty"`
+	// candidate gathering limits to shorten join time
+	ICEGathering ICEGatheringConfig `yaml:"ice_gathering,omitempty"`
+	// additional host candidates to advertise, <ip>:<port>[/udp|/tcp], see StaticHostCandidate
+	StaticHostCandidates []string `yaml:"static_host_candidates,omitempty"`
+	// derive NodeIP from the Kubernetes node when NodeIP is not set, see ResolveKubernetes
+	Kubernetes KubernetesConfig `yaml:"kubernetes,omitempty"`
+	// retransmission policy and deadline of the STUN binding requests used to discover the external IP
+	STUNRequest STUNRequestConfig `yaml:"stun_request,omitempty"`
+	// keep resolved external IPs across restarts
+	ExternalIPCache ExternalIPCacheConfig `yaml:"external_ip_cache,omitempty"`
+	// what to do when UseExternalIP is set and the external IP cannot be resolved
+	ExternalIPPolicy ExternalIPPolicy `yaml:"external_ip_policy,omitempty"`
+	// called with external IP resolution events. With ExternalIPPolicyRetry, the embedder applies a later
+	// resolved IP with WebRTCConfig.Reload, it is not applied to a running WebRTC config otherwise.
+	OnExternalIPEvent func(event ExternalIPEvent) `yaml:"-"`
+	// STUN keepalives from the UDP mux ports to hold NAT mappings open
+	NATKeepalive NATKeepaliveConfig `yaml:"nat_keepalive,omitempty"`
+	// called with NAT keepalive events, for example to apply a changed external IP
+	OnNATKeepaliveEvent func(event transport.KeepaliveEvent) `yaml:"-"`
+	// traffic class and flow label of media sent over IPv6 from UDPPort
+	IPv6QoS IPv6QoSConfig `yaml:"ipv6_qos,omitempty"`
+	// classifies media for IPv6QoS, created by NewWebRTCConfig when nil. Register the SSRCs of
+	// published and subscribed tracks.
+	MediaKinds *transport.MediaKindRegistry `yaml:"-"`
+	// ECN marking and reporting on UDPPort
+	ECN ECNConfig `yaml:"ecn,omitempty"`
+	// called with the ECN codepoint of each received packet, for example to feed congestion control
+	OnECN transport.ECNObserver `yaml:"-"`
+	// protection against DTLS handshake floods
+	DTLS DTLSConfig `yaml:"dtls,omitempty"`
+	// limits handshakes on UDPPort, created by NewWebRTCConfig when nil and DTLS.HandshakeRate is set
+	DTLSHandshakeLimiter *transport.DTLSHandshakeLimiter `yaml:"-"`
+
+	// send side bandwidth estimator, pass bwe.NewEstimatorFactory(Tuning().CongestionControl) to cc.NewInterceptor
+	CongestionControl bwe.Config `yaml:"congestion_control,omitempty"`
+	// pass Tuning().Pacer.FactoryOpts() to pacer.NewPacerFactory
+	Pacer PacerConfig `yaml:"pacer,omitempty"`
+
+	// history of advertised addresses, created by NewWebRTCConfig when nil
+	AddressBook *AddressBook `yaml:"-"`
+
+	// consulted before the UDP and TCP muxes hand out resources to a new connection, once per ICE ufrag,
+	// for example an admission.LoadController. A rejected connection fails to gather mux candidates.
+	AdmissionController admission.Controller `yaml:"-"`
 
 	// for testing, disable UDP
 	ForceTCP bool `yaml:"force_tcp,omitempty"`
+
+	stopExternalIPRetry context.CancelFunc
+	// how NodeIP and NAT 1:1 mappings were discovered, for AddressBook
+	nodeIPSource     AddressSource
+	natMappingSource AddressSource
 }
 
+// TURNServerConfig is a TURN server with static credentials.
+// For time-limited credentials, see TURNCredentialParams.
+type TURNServerConfig struct {
+	// host[:port], or a turn: or turns: URL
+	URL        string `yaml:"url"`
+	Username   string `yaml:"username,omitempty"`
+	Credential string `yaml:"credential,omitempty"`
+	// udp, tcp or tls, udp when empty. Ignored for URLs with a transport
+	Transport TURNTransport `yaml:"transport,omitempty"`
+}
+
+type TURNTransport string
+
+const (
+	TURNTransportUDP TURNTransport = "udp"
+	TURNTransportTCP TURNTransport = "tcp"
+	TURNTransportTLS TURNTransport = "tls"
+)
+
 type InterfacesConfig struct {
 	Includes []string `yaml:"includes,omitempty"`
 	Excludes []string `yaml:"excludes,omitempty"`
@@ -76,6 +173,189 @@ type BatchIOConfig struct {
 	MaxFlushInterval time.Duration `yaml:"max_flush_interval,omitempty"`
 }
 
+// ICEConsentConfig controls consent freshness checks on established ICE connections.
+// Zero values use the ICE agent defaults.
+type ICEConsentConfig struct {
+	// interval between consent checks (STUN binding requests) on the selected pair
+	CheckInterval time.Duration `yaml:"check_interval,omitempty"`
+	// connection is considered disconnected after no response for this long
+	DisconnectedTimeout time.Duration `yaml:"disconnected_timeout,omitempty"`
+	// consent expires and the connection fails after no response for this long
+	FailedTimeout time.Duration `yaml:"failed_timeout,omitempty"`
+}
+
+// NATKeepaliveConfig keeps the NAT mappings of UDP mux ports open while no client is connected,
+// so that the external IP and port advertised in candidates stay valid. Zero values use
+// transport.KeepaliveParamsDefault. Only applies with UDPPort, not with an ICE port range.
+type NATKeepaliveConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// STUN servers to send keepalives to, STUNServers when empty
+	Servers []string `yaml:"servers,omitempty"`
+	// interval between keepalives, should be well below the UDP mapping timeout of the NAT
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// every ProbeEvery-th keepalive is a binding request, whose response confirms the mapping
+	ProbeEvery int `yaml:"probe_every,omitempty"`
+	// consecutive unanswered probes before the mapping is reported as failed
+	MaxMissedProbes int `yaml:"max_missed_probes,omitempty"`
+}
+
+// IPv6QoSConfig sets IPv6 traffic class and flow label per media kind, for networks which use them for
+// QoS or to keep flows on one ECMP path. Kinds without values use Default. Only applies with UDPPort.
+type IPv6QoSConfig struct {
+	Default IPv6QoSValues `yaml:"default,omitempty"`
+	Audio   IPv6QoSValues `yaml:"audio,omitempty"`
+	Video   IPv6QoSValues `yaml:"video,omitempty"`
+	Data    IPv6QoSValues `yaml:"data,omitempty"`
+}
+
+type IPv6QoSValues struct {
+	// traffic class octet, DSCP in the upper six bits, e.g. 184 for EF
+	TrafficClass int `yaml:"traffic_class,omitempty"`
+	// 20 bit flow label, 0 leaves it to the kernel
+	FlowLabel uint32 `yaml:"flow_label,omitempty"`
+}
+
+func (c IPv6QoSConfig) isSet() bool {
+	return c != IPv6QoSConfig{}
+}
+
+// ECNConfig reads ECN marks of received packets and optionally marks sent packets as ECN capable.
+// Only applies with UDPPort, on platforms which support it.
+type ECNConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// mark sent packets ECT(1) as an L4S capable sender, otherwise sent packets are not marked
+	L4S bool `yaml:"l4s,omitempty"`
+}
+
+func (c ECNConfig) params() transport.ECNParams {
+	if c.L4S {
+		return transport.ECNParams{Mark: transport.ECNECT1}
+	}
+	return transport.ECNParams{}
+}
+
+// DTLSConfig protects DTLS servers against handshake floods.
+type DTLSConfig struct {
+	// new handshakes per second allowed from a source IP on UDPPort, 0 for no limit
+	HandshakeRate float64 `yaml:"handshake_rate,omitempty"`
+	// handshakes a source may start at once above the rate, see transport.DTLSHandshakeLimitParamsDefault
+	HandshakeBurst int `yaml:"handshake_burst,omitempty"`
+	// answer ClientHellos without a HelloVerifyRequest round trip, saves a round trip on join.
+	// Not allowed on public nodes, the cookie exchange keeps spoofed sources from starting handshakes.
+	SkipHelloVerify bool `yaml:"skip_hello_verify,omitempty"`
+}
+
+func (c DTLSConfig) handshakeLimitParams() transport.DTLSHandshakeLimitParams {
+	return transport.DTLSHandshakeLimitParams{
+		Rate:  c.HandshakeRate,
+		Burst: c.HandshakeBurst,
+	}
+}
+
+// ICEGatheringConfig trades completeness of local candidates for join time.
+// Completing early may leave out relay candidates and candidates from slow STUN servers,
+// those are reported as late by icegather.Gatherer and can still be trickled.
+type ICEGatheringConfig struct {
+	// gathering is considered complete after this long, 0 waits for all candidates
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// complete as soon as a server reflexive candidate is gathered for each IP family
+	CompleteAfterFirstSrflx bool `yaml:"complete_after_first_srflx,omitempty"`
+	// minimum wait before nominating a server reflexive pair, lower values connect sooner
+	// but may settle on a server reflexive pair when a host pair would have succeeded
+	SrflxAcceptanceMinWait time.Duration `yaml:"srflx_acceptance_min_wait,omitempty"`
+}
+
+type ICETCPMode string
+
+const (
+	// offer passive candidates, accepting connections on TCPPort
+	ICETCPModePassive ICETCPMode = "passive"
+	// connect out to remote passive candidates, for example server to server
+	ICETCPModeActive ICETCPMode = "active"
+	// simultaneous-open, not supported by the ICE agent
+	ICETCPModeSimultaneousOpen ICETCPMode = "so"
+)
+
+// iceTCPModes returns whether passive and active ICE-TCP candidates are enabled
+func (conf *RTCConfig) iceTCPModes() (passive bool, active bool) {
+	if len(conf.ICETCPModes) == 0 {
+		return conf.TCPPort != 0, conf.TCPPort != 0
+	}
+
+	for _, mode := range conf.ICETCPModes {
+		switch mode {
+		case ICETCPModePassive:
+			passive = true
+		case ICETCPModeActive:
+			active = true
+		}
+	}
+	return
+}
+
+func (conf *RTCConfig) validateICETCPModes() error {
+	for _, mode := range conf.ICETCPModes {
+		switch mode {
+		case ICETCPModePassive:
+			if conf.TCPPort == 0 {
+				return errors.New("passive ICE-TCP mode requires tcp_port")
+			}
+		case ICETCPModeActive:
+		case ICETCPModeSimultaneousOpen:
+			return fmt.Errorf("ICE-TCP mode %s is not supported", mode)
+		default:
+			return fmt.Errorf("unknown ICE-TCP mode %s", mode)
+		}
+	}
+	return nil
+}
+
+func (c ICEConsentConfig) IsSet() bool {
+	return c.CheckInterval != 0 || c.DisconnectedTimeout != 0 || c.FailedTimeout != 0
+}
+
+func (c ICEConsentConfig) withDefaults() ICEConsentConfig {
+	if c.CheckInterval == 0 {
+		c.CheckInterval = defaultICEConsentCheckInterval
+	}
+	if c.DisconnectedTimeout == 0 {
+		c.DisconnectedTimeout = defaultICEConsentDisconnectedTimeout
+	}
+	if c.FailedTimeout == 0 {
+		c.FailedTimeout = defaultICEConsentFailedTimeout
+	}
+	return c
+}
+
+func (conf *RTCConfig) natKeepaliveParams() transport.KeepaliveParams {
+	servers := conf.NATKeepalive.Servers
+	if len(servers) == 0 {
+		servers = conf.stunServers()
+	}
+	return transport.KeepaliveParams{
+		Servers:         servers,
+		Interval:        conf.NATKeepalive.Interval,
+		ProbeEvery:      conf.NATKeepalive.ProbeEvery,
+		MaxMissedProbes: conf.NATKeepalive.MaxMissedProbes,
+	}
+}
+
+func (conf *RTCConfig) ipv6QoSParams() transport.IPv6QoSParams {
+	if conf.MediaKinds == nil {
+		conf.MediaKinds = transport.NewMediaKindRegistry()
+	}
+	toQoS := func(v IPv6QoSValues) transport.IPv6QoS {
+		return transport.IPv6QoS{TrafficClass: v.TrafficClass, FlowLabel: v.FlowLabel}
+	}
+	return transport.IPv6QoSParams{
+		Default: toQoS(conf.IPv6QoS.Default),
+		Audio:   toQoS(conf.IPv6QoS.Audio),
+		Video:   toQoS(conf.IPv6QoS.Video),
+		Data:    toQoS(conf.IPv6QoS.Data),
+		Kinds:   conf.MediaKinds,
+	}
+}
+
 func (conf *RTCConfig) Validate(development bool) error {
 	// set defaults for ports if none are set
 	if !conf.UDPPort.Valid() && conf.ICEPortRangeStart == 0 {
@@ -88,6 +368,46 @@ func (conf *RTCConfig) Validate(development bool) error {
 		}
 	}
 
+	if err := conf.validateTuning(); err != nil {
+		return err
+	}
+
+	if consent := conf.Tuning().ICEConsent; consent.IsSet() {
+		consent = consent.withDefaults()
+		if consent.CheckInterval >= consent.FailedTimeout || consent.DisconnectedTimeout >= consent.FailedTimeout {
+			return fmt.Errorf("invalid ICE consent config, check interval %s and disconnected timeout %s must be less than failed timeout %s",
+				consent.CheckInterval, consent.DisconnectedTimeout, consent.FailedTimeout)
+		}
+	}
+
+	if err := conf.validateICETCPModes(); err != nil {
+		return err
+	}
+
+	if conf.ICEGathering.Timeout < 0 || conf.ICEGathering.SrflxAcceptanceMinWait < 0 {
+		return errors.New("invalid ICE gathering config, durations must not be negative")
+	}
+
+	if _, err := conf.parseStaticHostCandidates(); err != nil {
+		return err
+	}
+
+	if err := conf.validateExternalIPPolicy(); err != nil {
+		return err
+	}
+
+	if err := conf.validateFilterPolicy(); err != nil {
+		return err
+	}
+
+	if err := conf.validateTURNServers(); err != nil {
+		return err
+	}
+
+	if conf.NodeIP == "" && conf.Kubernetes.Enabled {
+		return errors.New("node IP not resolved from Kubernetes, call ResolveKubernetes before Validate")
+	}
+
 	var err error
 	if conf.NodeIP == "" || conf.UseExternalIP {
 		conf.NodeIP, err = conf.determineIP()
@@ -98,9 +418,22 @@ func (conf *RTCConfig) Validate(development bool) error {
 		conf.NodeIPAutoGenerated = true
 	}
 
+	if conf.DTLS.SkipHelloVerify && conf.isPublicNode() {
+		return errors.New("DTLS hello verify cannot be skipped on a public node")
+	}
+
 	return nil
 }
 
+// isPublicNode returns true when the node is reachable from the internet, by its external IP or a public node IP
+func (conf *RTCConfig) isPublicNode() bool {
+	if conf.UseExternalIP {
+		return true
+	}
+	ip := net.ParseIP(conf.NodeIP)
+	return ip != nil && ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
 type PortRange struct {
 	Start int `yaml:"start,omitempty"`
 	End   int `yaml:"end,omitempty"`
@@ -0,0 +1,217 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	externalIPAttempts        = 3
+	externalIPMaxRetryBackoff = 5 * time.Minute
+)
+
+var (
+	ErrExternalIPUnresolved = errors.New("could not resolve external IP")
+
+	// overridden in tests
+	getExternalIP             = GetExternalIPWithConfig
+	externalIPAttemptInterval = 500 * time.Millisecond
+	externalIPRetryInterval   = 10 * time.Second
+)
+
+// ExternalIPPolicy decides what happens when UseExternalIP is set and STUN servers are unreachable.
+type ExternalIPPolicy string
+
+const (
+	// fail Validate, and NewWebRTCConfig when no local address maps to an external IP.
+	// This is also the behavior of Validate when no policy is set.
+	ExternalIPPolicyFailFast ExternalIPPolicy = "fail_fast"
+	// advertise the local node IP in place of the external IP
+	ExternalIPPolicyNodeIP ExternalIPPolicy = "node_ip"
+	// as node_ip, and keep resolving in the background, reporting ExternalIPEventResolved on success.
+	// The resolved IP is cached for the next start, peer connections keep being created with the node IP
+	// until it is applied with WebRTCConfig.Reload, for example on an ExternalIPEventResolved with a non-zero Attempt
+	ExternalIPPolicyRetry ExternalIPPolicy = "retry"
+)
+
+type ExternalIPEventType int
+
+const (
+	ExternalIPEventResolved ExternalIPEventType = iota
+	// the node IP is used in place of the external IP
+	ExternalIPEventFallback
+	ExternalIPEventFailed
+	// a cached external IP or NAT mapping used at startup has changed, IP is the newly resolved external IP,
+	// empty when NAT mappings of local addresses changed. The new IPs apply on the next start.
+	ExternalIPEventStale
+)
+
+func (t ExternalIPEventType) String() string {
+	switch t {
+	case ExternalIPEventResolved:
+		return "RESOLVED"
+	case ExternalIPEventFallback:
+		return "FALLBACK"
+	case ExternalIPEventFailed:
+		return "FAILED"
+	case ExternalIPEventStale:
+		return "STALE"
+	default:
+		return fmt.Sprintf("%d", int(t))
+	}
+}
+
+type ExternalIPEvent struct {
+	Type ExternalIPEventType
+	// resolved external IP, or the node IP used on fallback
+	IP  string
+	Err error
+	// resolution attempt of the background retry, 0 at startup
+	Attempt int
+	// resolved from the external IP cache, see ExternalIPCacheConfig
+	Cached bool
+}
+
+// ExternalIPError is returned when the external IP cannot be resolved, it matches ErrExternalIPUnresolved.
+type ExternalIPError struct {
+	STUNServers []string
+	Err         error
+}
+
+func (e *ExternalIPError) Error() string {
+	return fmt.Sprintf("could not resolve external IP using %v: %v", e.STUNServers, e.Err)
+}
+
+func (e *ExternalIPError) Is(target error) bool {
+	return target == ErrExternalIPUnresolved
+}
+
+func (e *ExternalIPError) Unwrap() error {
+	return e.Err
+}
+
+func (conf *RTCConfig) validateExternalIPPolicy() error {
+	switch conf.ExternalIPPolicy {
+	case "", ExternalIPPolicyFailFast, ExternalIPPolicyNodeIP, ExternalIPPolicyRetry:
+		return nil
+	default:
+		return fmt.Errorf("unknown external IP policy %s", conf.ExternalIPPolicy)
+	}
+}
+
+func (conf *RTCConfig) stunServers() []string {
+	if len(conf.STUNServers) == 0 {
+		return DefaultStunServers
+	}
+	return conf.STUNServers
+}
+
+// resolveExternalIP resolves the external IP at startup, applying ExternalIPPolicy on failure
+func (conf *RTCConfig) resolveExternalIP() (string, error) {
+	stunServers := conf.stunServers()
+	if ip, ok := conf.cachedNodeIP(stunServers); ok {
+		conf.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventResolved, IP: ip, Cached: true})
+		conf.validateCachedNodeIP(ip, stunServers)
+		conf.nodeIPSource = AddressSourceCache
+		return ip, nil
+	}
+
+	var err error
+	for i := 0; i < externalIPAttempts; i++ {
+		if i != 0 {
+			time.Sleep(externalIPAttemptInterval)
+		}
+
+		var ip string
+		if ip, err = getExternalIP(context.Background(), stunServers, nil, conf.STUNRequest); err == nil {
+			conf.cacheNodeIP(ip, stunServers)
+			conf.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventResolved, IP: ip})
+			conf.nodeIPSource = AddressSourceSTUN
+			return ip, nil
+		}
+	}
+
+	extErr := &ExternalIPError{STUNServers: stunServers, Err: err}
+	if conf.ExternalIPPolicy == ExternalIPPolicyNodeIP || conf.ExternalIPPolicy == ExternalIPPolicyRetry {
+		if addresses, _ := GetLocalIPAddresses(false, nil); len(addresses) > 0 {
+			logger.Warnw("could not resolve external IP, using node IP", extErr, "ip", addresses[0], "policy", conf.ExternalIPPolicy)
+			conf.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventFallback, IP: addresses[0], Err: extErr})
+			if conf.ExternalIPPolicy == ExternalIPPolicyRetry {
+				conf.startExternalIPRetry(stunServers)
+			}
+			conf.nodeIPSource = AddressSourceFallback
+			return addresses[0], nil
+		}
+	}
+
+	logger.Warnw("could not resolve external IP", extErr)
+	conf.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventFailed, Err: extErr})
+	return "", extErr
+}
+
+// StopExternalIPRetry stops resolving the external IP in the background, see ExternalIPPolicyRetry.
+func (conf *RTCConfig) StopExternalIPRetry() {
+	if conf.stopExternalIPRetry != nil {
+		conf.stopExternalIPRetry()
+	}
+}
+
+func (conf *RTCConfig) startExternalIPRetry(stunServers []string) {
+	conf.StopExternalIPRetry()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conf.stopExternalIPRetry = cancel
+	c := *conf
+	go func() {
+		defer cancel()
+
+		backoff := externalIPRetryInterval
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+
+			ip, err := getExternalIP(ctx, stunServers, nil, c.STUNRequest)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				logger.Infow("resolved external IP", "ip", ip, "attempt", attempt)
+				c.cacheNodeIP(ip, stunServers)
+				c.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventResolved, IP: ip, Attempt: attempt})
+				return
+			}
+
+			backoff *= 2
+			if backoff > externalIPMaxRetryBackoff {
+				backoff = externalIPMaxRetryBackoff
+			}
+		}
+	}()
+}
+
+func (conf *RTCConfig) emitExternalIPEvent(event ExternalIPEvent) {
+	if conf.OnExternalIPEvent != nil {
+		conf.OnExternalIPEvent(event)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setExternalIPResolver(t *testing.T, resolve func() (string, error)) {
+	prevGet, prevAttempt, prevRetry := getExternalIP, externalIPAttemptInterval, externalIPRetryInterval
+	getExternalIP = func(_ context.Context, _ []string, _ net.Addr, _ STUNRequestConfig) (string, error) {
+		return resolve()
+	}
+	externalIPAttemptInterval = time.Millisecond
+	externalIPRetryInterval = time.Millisecond
+	t.Cleanup(func() {
+		getExternalIP, externalIPAttemptInterval, externalIPRetryInterval = prevGet, prevAttempt, prevRetry
+	})
+}
+
+func Test_ExternalIPPolicy(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+	setExternalIPResolver(t, func() (string, error) {
+		return "", errUnreachable
+	})
+
+	var events []ExternalIPEvent
+	conf := &RTCConfig{
+		UseExternalIP:     true,
+		STUNServers:       []string{"stun.example.com:3478"},
+		OnExternalIPEvent: func(event ExternalIPEvent) { events = append(events, event) },
+	}
+
+	// fail fast by default
+	_, err := conf.determineIP()
+	require.ErrorIs(t, err, ErrExternalIPUnresolved)
+	require.ErrorIs(t, err, errUnreachable)
+	var extErr *ExternalIPError
+	require.ErrorAs(t, err, &extErr)
+	require.Equal(t, []string{"stun.example.com:3478"}, extErr.STUNServers)
+	require.Len(t, events, 1)
+	require.Equal(t, ExternalIPEventFailed, events[0].Type)
+
+	localIPs, _ := GetLocalIPAddresses(false, nil)
+	if len(localIPs) == 0 {
+		t.Skip("no local IP address")
+	}
+
+	events = nil
+	conf.ExternalIPPolicy = ExternalIPPolicyNodeIP
+	ip, err := conf.determineIP()
+	require.NoError(t, err)
+	require.Equal(t, localIPs[0], ip)
+	require.Len(t, events, 1)
+	require.Equal(t, ExternalIPEventFallback, events[0].Type)
+	require.ErrorIs(t, events[0].Err, ErrExternalIPUnresolved)
+
+	conf.ExternalIPPolicy = "unknown"
+	require.Error(t, conf.validateExternalIPPolicy())
+}
+
+func Test_ExternalIPPolicyRetry(t *testing.T) {
+	localIPs, _ := GetLocalIPAddresses(false, nil)
+	if len(localIPs) == 0 {
+		t.Skip("no local IP address")
+	}
+
+	attempts := make(chan struct{}, 10)
+	setExternalIPResolver(t, func() (string, error) {
+		attempts <- struct{}{}
+		if len(attempts) < 5 {
+			return "", errors.New("unreachable")
+		}
+		return "203.0.113.5", nil
+	})
+
+	resolved := make(chan ExternalIPEvent, 1)
+	conf := &RTCConfig{
+		UseExternalIP:    true,
+		ExternalIPPolicy: ExternalIPPolicyRetry,
+		OnExternalIPEvent: func(event ExternalIPEvent) {
+			if event.Type == ExternalIPEventResolved {
+				resolved <- event
+			}
+		},
+	}
+	defer conf.StopExternalIPRetry()
+
+	ip, err := conf.determineIP()
+	require.NoError(t, err)
+	require.Equal(t, localIPs[0], ip)
+
+	select {
+	case event := <-resolved:
+		require.Equal(t, "203.0.113.5", event.IP)
+		require.Equal(t, 2, event.Attempt)
+	case <-time.After(5 * time.Second):
+		t.Fatal("external IP not resolved in background")
+	}
+}
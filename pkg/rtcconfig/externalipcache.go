@@ -0,0 +1,278 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	defaultExternalIPCacheTTL = time.Hour
+)
+
+// ExternalIPCacheConfig keeps resolved external IPs across restarts, so that a restart within TTL
+// does not wait for STUN discovery. Cached IPs are used right away and validated in the background,
+// an ExternalIPEventStale event reports cached IPs that turn out to have changed.
+// Caching is disabled unless File or Store is set.
+type ExternalIPCacheConfig struct {
+	// JSON file the resolved IPs are kept in, used when Store is not set
+	File string `yaml:"file,omitempty"`
+	// cached IPs older than this are resolved again, default 1 hour
+	TTL time.Duration `yaml:"ttl,omitempty"`
+	// store provided by the embedder, for example a key value store that outlives the container
+	Store ExternalIPStore `yaml:"-"`
+}
+
+// ExternalIPStore persists the external IP cache entry.
+type ExternalIPStore interface {
+	// Load returns the stored entry, nil if there is none
+	Load() (*ExternalIPCacheEntry, error)
+	Save(entry *ExternalIPCacheEntry) error
+	Delete() error
+}
+
+type ExternalIPCacheEntry struct {
+	// local addresses and STUN servers the IPs were resolved with, the entry is invalid if they change
+	LocalIPs    []string `json:"local_ips"`
+	STUNServers []string `json:"stun_servers"`
+
+	NodeIP           string    `json:"node_ip,omitempty"`
+	NodeIPResolvedAt time.Time `json:"node_ip_resolved_at,omitempty"`
+	// external IP to local IP, as resolved for NAT 1:1 mappings
+	NATMapping           map[string]string `json:"nat_mapping,omitempty"`
+	NATMappingResolvedAt time.Time         `json:"nat_mapping_resolved_at,omitempty"`
+}
+
+// FileExternalIPStore keeps the cache entry in a JSON file.
+type FileExternalIPStore struct {
+	path string
+}
+
+func NewFileExternalIPStore(path string) *FileExternalIPStore {
+	return &FileExternalIPStore{
+		path: path,
+	}
+}
+
+func (f *FileExternalIPStore) Load() (*ExternalIPCacheEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &ExternalIPCacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// Save replaces the file atomically, so that a crash does not leave a partial entry.
+func (f *FileExternalIPStore) Save(entry *ExternalIPCacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (f *FileExternalIPStore) Delete() error {
+	if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ------------------------------------------------
+
+// serializes read-modify-write of the store by startup and background validation
+var externalIPCacheLock sync.Mutex
+
+// InvalidateExternalIPCache drops cached external IPs, for example when network interfaces change.
+func (conf *RTCConfig) InvalidateExternalIPCache() error {
+	store := conf.ExternalIPCache.store()
+	if store == nil {
+		return nil
+	}
+
+	externalIPCacheLock.Lock()
+	defer externalIPCacheLock.Unlock()
+
+	return store.Delete()
+}
+
+func (c ExternalIPCacheConfig) store() ExternalIPStore {
+	if c.Store != nil {
+		return c.Store
+	}
+	if c.File != "" {
+		return NewFileExternalIPStore(c.File)
+	}
+	return nil
+}
+
+func (c ExternalIPCacheConfig) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return defaultExternalIPCacheTTL
+	}
+	return c.TTL
+}
+
+// loadExternalIPCache returns the cache entry if it was resolved with the current local addresses and STUN servers
+func (conf *RTCConfig) loadExternalIPCache(store ExternalIPStore, stunServers []string) *ExternalIPCacheEntry {
+	entry, err := store.Load()
+	if err != nil {
+		logger.Warnw("could not load external IP cache", err)
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+
+	localIPs, _ := GetLocalIPAddresses(false, nil)
+	if !sameIPs(entry.LocalIPs, localIPs) || !sameIPs(entry.STUNServers, stunServers) {
+		// interfaces changed since the entry was stored
+		return nil
+	}
+	return entry
+}
+
+func (conf *RTCConfig) cachedNodeIP(stunServers []string) (string, bool) {
+	store := conf.ExternalIPCache.store()
+	if store == nil {
+		return "", false
+	}
+
+	externalIPCacheLock.Lock()
+	defer externalIPCacheLock.Unlock()
+
+	entry := conf.loadExternalIPCache(store, stunServers)
+	if entry == nil || entry.NodeIP == "" || time.Since(entry.NodeIPResolvedAt) > conf.ExternalIPCache.ttl() {
+		return "", false
+	}
+	return entry.NodeIP, true
+}
+
+func (conf *RTCConfig) cacheNodeIP(ip string, stunServers []string) {
+	conf.updateExternalIPCache(stunServers, func(entry *ExternalIPCacheEntry) {
+		entry.NodeIP = ip
+		entry.NodeIPResolvedAt = time.Now()
+	})
+}
+
+func (conf *RTCConfig) cachedNATMapping(stunServers []string) (map[string]string, bool) {
+	store := conf.ExternalIPCache.store()
+	if store == nil {
+		return nil, false
+	}
+
+	externalIPCacheLock.Lock()
+	defer externalIPCacheLock.Unlock()
+
+	entry := conf.loadExternalIPCache(store, stunServers)
+	if entry == nil || len(entry.NATMapping) == 0 || time.Since(entry.NATMappingResolvedAt) > conf.ExternalIPCache.ttl() {
+		return nil, false
+	}
+	return entry.NATMapping, true
+}
+
+func (conf *RTCConfig) cacheNATMapping(natMapping map[string]string, stunServers []string) {
+	conf.updateExternalIPCache(stunServers, func(entry *ExternalIPCacheEntry) {
+		entry.NATMapping = natMapping
+		entry.NATMappingResolvedAt = time.Now()
+	})
+}
+
+func (conf *RTCConfig) updateExternalIPCache(stunServers []string, update func(entry *ExternalIPCacheEntry)) {
+	store := conf.ExternalIPCache.store()
+	if store == nil {
+		return
+	}
+
+	externalIPCacheLock.Lock()
+	defer externalIPCacheLock.Unlock()
+
+	entry := conf.loadExternalIPCache(store, stunServers)
+	if entry == nil {
+		localIPs, _ := GetLocalIPAddresses(false, nil)
+		entry = &ExternalIPCacheEntry{
+			LocalIPs:    localIPs,
+			STUNServers: stunServers,
+		}
+	}
+	update(entry)
+	if err := store.Save(entry); err != nil {
+		logger.Warnw("could not save external IP cache", err)
+	}
+}
+
+// validateCachedNodeIP resolves the external IP in the background, reporting ExternalIPEventStale
+// if it is not the cached one
+func (conf *RTCConfig) validateCachedNodeIP(cached string, stunServers []string) {
+	c := *conf
+	go func() {
+		ip, err := getExternalIP(context.Background(), stunServers, nil, c.STUNRequest)
+		if err != nil {
+			logger.Infow("could not validate cached external IP", "ip", cached, "err", err)
+			return
+		}
+
+		c.cacheNodeIP(ip, stunServers)
+		if ip != cached {
+			logger.Infow("cached external IP is stale", "cached", cached, "ip", ip)
+			c.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventStale, IP: ip})
+		}
+	}()
+}
+
+func sameIPs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = slices.Clone(a), slices.Clone(b)
+	sort.Strings(a)
+	sort.Strings(b)
+	return slices.Equal(a, b)
+}
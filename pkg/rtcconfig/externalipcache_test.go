@@ -0,0 +1,122 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileExternalIPStore(t *testing.T) {
+	store := NewFileExternalIPStore(filepath.Join(t.TempDir(), "external_ip.json"))
+
+	entry, err := store.Load()
+	require.NoError(t, err)
+	require.Nil(t, entry)
+
+	saved := &ExternalIPCacheEntry{
+		LocalIPs:         []string{"10.0.0.1"},
+		STUNServers:      []string{"stun.example.com:3478"},
+		NodeIP:           "1.2.3.4",
+		NodeIPResolvedAt: time.Now().Round(0),
+		NATMapping:       map[string]string{"1.2.3.4": "10.0.0.1"},
+	}
+	require.NoError(t, store.Save(saved))
+
+	entry, err = store.Load()
+	require.NoError(t, err)
+	require.Equal(t, saved.NodeIP, entry.NodeIP)
+	require.True(t, saved.NodeIPResolvedAt.Equal(entry.NodeIPResolvedAt))
+	require.Equal(t, saved.NATMapping, entry.NATMapping)
+
+	require.NoError(t, store.Delete())
+	require.NoError(t, store.Delete())
+	entry, err = store.Load()
+	require.NoError(t, err)
+	require.Nil(t, entry)
+}
+
+func Test_ExternalIPCache(t *testing.T) {
+	var (
+		lock       sync.Mutex
+		externalIP = "1.2.3.4"
+		resolved   int
+	)
+	setExternalIPResolver(t, func() (string, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		resolved++
+		return externalIP, nil
+	})
+
+	events := make(chan ExternalIPEvent, 10)
+	conf := &RTCConfig{
+		UseExternalIP: true,
+		STUNServers:   []string{"stun.example.com:3478"},
+		ExternalIPCache: ExternalIPCacheConfig{
+			File: filepath.Join(t.TempDir(), "external_ip.json"),
+		},
+		OnExternalIPEvent: func(event ExternalIPEvent) { events <- event },
+	}
+
+	// nothing cached, resolved and stored
+	ip, err := conf.determineIP()
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", ip)
+	event := <-events
+	require.Equal(t, ExternalIPEventResolved, event.Type)
+	require.False(t, event.Cached)
+
+	// restart uses the cached IP, and finds it stale when validating
+	lock.Lock()
+	externalIP = "5.6.7.8"
+	lock.Unlock()
+	ip, err = conf.determineIP()
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", ip)
+	event = <-events
+	require.Equal(t, ExternalIPEventResolved, event.Type)
+	require.True(t, event.Cached)
+
+	select {
+	case event = <-events:
+		require.Equal(t, ExternalIPEventStale, event.Type)
+		require.Equal(t, "5.6.7.8", event.IP)
+	case <-time.After(5 * time.Second):
+		t.Fatal("cached IP not validated")
+	}
+
+	// validation updated the cache
+	ip, ok := conf.cachedNodeIP(conf.STUNServers)
+	require.True(t, ok)
+	require.Equal(t, "5.6.7.8", ip)
+
+	// entry is not used after expiry, for other STUN servers, or once invalidated
+	conf.ExternalIPCache.TTL = time.Nanosecond
+	_, ok = conf.cachedNodeIP(conf.STUNServers)
+	require.False(t, ok)
+	conf.ExternalIPCache.TTL = 0
+
+	_, ok = conf.cachedNodeIP([]string{"stun.example.org:3478"})
+	require.False(t, ok)
+
+	require.NoError(t, conf.InvalidateExternalIPCache())
+	_, ok = conf.cachedNodeIP(conf.STUNServers)
+	require.False(t, ok)
+}
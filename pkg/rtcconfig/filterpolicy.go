@@ -0,0 +1,122 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/transport"
+)
+
+// FilterPolicy decides whether an interface or address is used when the Interfaces or IPs filters cannot be
+// evaluated, for example when an address is filtered by its interface and the interface disappeared.
+type FilterPolicy string
+
+const (
+	// the interface or address is not used, also the behavior when no policy is set
+	FilterPolicyFailClosed FilterPolicy = "fail_closed"
+	// the interface or address is used as if the filters had allowed it
+	FilterPolicyFailOpen FilterPolicy = "fail_open"
+)
+
+func (p FilterPolicy) transportPolicy() transport.FilterPolicy {
+	if p == FilterPolicyFailOpen {
+		return transport.FilterPolicyFailOpen
+	}
+	return transport.FilterPolicyFailClosed
+}
+
+func (conf *RTCConfig) validateFilterPolicy() error {
+	switch conf.FilterPolicy {
+	case "", FilterPolicyFailClosed, FilterPolicyFailOpen:
+		return nil
+	default:
+		return fmt.Errorf("unknown filter policy %s", conf.FilterPolicy)
+	}
+}
+
+// filters returns the interface and IP filters of the config, nil when not configured. With interface filters,
+// addresses are also filtered by the interface they are on at the time they are evaluated, an address no
+// longer on any interface is a failure handled by FilterPolicy.
+func (conf *RTCConfig) filters() (func(string) bool, func(net.IP) bool, error) {
+	hasIfFilter := len(conf.Interfaces.Includes) != 0 || len(conf.Interfaces.Excludes) != 0
+	hasIPFilter := len(conf.IPs.Includes) != 0 || len(conf.IPs.Excludes) != 0
+	if !hasIfFilter && !hasIPFilter {
+		return nil, nil, nil
+	}
+
+	guard := transport.NewFilterGuard(conf.FilterPolicy.transportPolicy())
+	onFailure := conf.OnFilterFailure
+	guard.OnFailure(func(failure transport.FilterFailure) {
+		logger.Infow("could not evaluate filter", "interface", failure.Interface, "ip", failure.IP, "error", failure.Err, "permitted", failure.Permitted)
+		if onFailure != nil {
+			onFailure(failure)
+		}
+	})
+
+	var ifFilter func(string) bool
+	var matchInterface func(string) bool
+	if hasIfFilter {
+		matchInterface = InterfaceFilterFromConf(conf.Interfaces)
+		ifFilter = guard.InterfaceFilter(transport.InterfaceFilterNoError(matchInterface))
+	}
+
+	var matchIP func(net.IP) bool
+	if hasIPFilter {
+		var err error
+		if matchIP, err = IPFilterFromConf(conf.IPs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	ipFilter := guard.IPFilter(func(ip net.IP) (bool, error) {
+		if matchIP != nil && !matchIP(ip) {
+			return false, nil
+		}
+		if matchInterface == nil {
+			return true, nil
+		}
+		name, err := interfaceOfIP(ip)
+		if err != nil {
+			return false, err
+		}
+		return matchInterface(name), nil
+	})
+	return ifFilter, ipFilter, nil
+}
+
+// interfaceOfIP returns the name of the interface ip is on
+func interfaceOfIP(ip net.IP) (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			// the interface may have disappeared since it was listed
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return iface.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no interface has address %s", ip)
+}
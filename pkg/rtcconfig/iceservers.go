@@ -0,0 +1,139 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/stun"
+	"github.com/pion/webrtc/v3"
+)
+
+// SessionConfiguration returns the node level Configuration with ICE servers of a session layered on top,
+// for example TURN servers with per user credentials from a REST API. Session servers come first and win
+// over node level servers with the same URL. The node level Configuration is not modified.
+func (c *WebRTCConfig) SessionConfiguration(sessionICEServers []webrtc.ICEServer) webrtc.Configuration {
+	conf := c.Settings().Configuration
+	conf.ICEServers = MergeICEServers(sessionICEServers, conf.ICEServers)
+	return conf
+}
+
+// MergeICEServers concatenates ICE server lists, dropping URLs already provided by an earlier server,
+// so the first list takes precedence along with its credentials. Servers left without URLs are dropped.
+func MergeICEServers(lists ...[]webrtc.ICEServer) []webrtc.ICEServer {
+	var merged []webrtc.ICEServer
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, server := range list {
+			urls := make([]string, 0, len(server.URLs))
+			for _, url := range server.URLs {
+				key := NormalizeICEServerURL(url)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				urls = append(urls, url)
+			}
+			if len(urls) == 0 {
+				continue
+			}
+
+			server.URLs = urls
+			merged = append(merged, server)
+		}
+	}
+	return merged
+}
+
+// NormalizeICEServerURL returns a canonical form of a STUN/TURN URL for comparison, with default
+// port and transport filled in, e.g. "turn:Example.com" and "turn:example.com:3478?transport=udp"
+// are the same. URLs that cannot be parsed are only trimmed and lower cased.
+func NormalizeICEServerURL(url string) string {
+	uri, err := stun.ParseURI(strings.TrimSpace(url))
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(url))
+	}
+	uri.Host = strings.ToLower(uri.Host)
+	return uri.String()
+}
+
+// ICEServer returns the TURN server as an ICE server.
+func (c TURNServerConfig) ICEServer() (webrtc.ICEServer, error) {
+	url, err := c.url()
+	if err != nil {
+		return webrtc.ICEServer{}, err
+	}
+	return webrtc.ICEServer{
+		URLs:           []string{url},
+		Username:       c.Username,
+		Credential:     c.Credential,
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}, nil
+}
+
+func (c TURNServerConfig) url() (string, error) {
+	url := strings.TrimSpace(c.URL)
+	if url == "" {
+		return "", errors.New("TURN server without url")
+	}
+	if !strings.HasPrefix(url, "turn:") && !strings.HasPrefix(url, "turns:") {
+		switch c.Transport {
+		case "", TURNTransportUDP:
+			url = "turn:" + url + "?transport=udp"
+		case TURNTransportTCP:
+			url = "turn:" + url + "?transport=tcp"
+		case TURNTransportTLS:
+			url = "turns:" + url + "?transport=tcp"
+		default:
+			return "", fmt.Errorf("unknown TURN transport %s", c.Transport)
+		}
+	}
+
+	uri, err := stun.ParseURI(url)
+	if err != nil {
+		return "", fmt.Errorf("invalid TURN server url %s: %w", c.URL, err)
+	}
+	if uri.Scheme != stun.SchemeTypeTURN && uri.Scheme != stun.SchemeTypeTURNS {
+		return "", fmt.Errorf("invalid TURN server url %s: not a TURN url", c.URL)
+	}
+	return url, nil
+}
+
+func (conf *RTCConfig) turnICEServers() ([]webrtc.ICEServer, error) {
+	iceServers := make([]webrtc.ICEServer, 0, len(conf.TURNServers))
+	for _, server := range conf.TURNServers {
+		iceServer, err := server.ICEServer()
+		if err != nil {
+			return nil, err
+		}
+		iceServers = append(iceServers, iceServer)
+	}
+	return iceServers, nil
+}
+
+func (conf *RTCConfig) validateTURNServers() error {
+	if _, err := conf.turnICEServers(); err != nil {
+		return err
+	}
+	if len(conf.TURNServers) != 0 && conf.UseICELite {
+		return errors.New("TURN servers cannot be used with ICE lite")
+	}
+	if conf.ForceRelay && len(conf.TURNServers) == 0 {
+		return errors.New("force_relay requires turn_servers")
+	}
+	return nil
+}
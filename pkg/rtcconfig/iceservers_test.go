@@ -0,0 +1,101 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NormalizeICEServerURL(t *testing.T) {
+	require.Equal(t, NormalizeICEServerURL("turn:example.com:3478?transport=udp"), NormalizeICEServerURL("turn:Example.com"))
+	require.Equal(t, NormalizeICEServerURL("stun:example.com:3478"), NormalizeICEServerURL(" stun:example.com "))
+	require.NotEqual(t, NormalizeICEServerURL("turn:example.com"), NormalizeICEServerURL("turn:example.com?transport=tcp"))
+	require.NotEqual(t, NormalizeICEServerURL("turn:example.com"), NormalizeICEServerURL("turns:example.com"))
+	require.Equal(t, "not a url", NormalizeICEServerURL("Not a URL"))
+}
+
+func Test_SessionConfiguration(t *testing.T) {
+	nodeServers := []webrtc.ICEServer{
+		{URLs: []string{"stun:stun.example.com:3478"}},
+		{URLs: []string{"turn:turn.example.com:3478", "turns:turn.example.com:5349"}, Username: "node", Credential: "node"},
+	}
+	conf := &WebRTCConfig{
+		Configuration: webrtc.Configuration{
+			ICEServers:   nodeServers,
+			SDPSemantics: webrtc.SDPSemanticsUnifiedPlan,
+		},
+	}
+
+	sessionConf := conf.SessionConfiguration([]webrtc.ICEServer{
+		{URLs: []string{"turn:TURN.example.com?transport=udp", "turn:turn.example.com:443?transport=tcp"}, Username: "user", Credential: "user"},
+		{URLs: []string{"turn:turn.example.com:3478"}, Username: "duplicate"},
+	})
+	require.Equal(t, webrtc.SDPSemanticsUnifiedPlan, sessionConf.SDPSemantics)
+	require.Equal(t, []webrtc.ICEServer{
+		{URLs: []string{"turn:TURN.example.com?transport=udp", "turn:turn.example.com:443?transport=tcp"}, Username: "user", Credential: "user"},
+		{URLs: []string{"stun:stun.example.com:3478"}},
+		{URLs: []string{"turns:turn.example.com:5349"}, Username: "node", Credential: "node"},
+	}, sessionConf.ICEServers)
+
+	// node level servers are untouched
+	require.Equal(t, []string{"turn:turn.example.com:3478", "turns:turn.example.com:5349"}, conf.Configuration.ICEServers[1].URLs)
+
+	// no session servers
+	require.Equal(t, nodeServers, conf.SessionConfiguration(nil).ICEServers)
+}
+
+func Test_TURNServers(t *testing.T) {
+	conf := &RTCConfig{
+		UDPPort: PortRange{Start: 7882},
+		NodeIP:  "10.0.0.1",
+		TURNServers: []TURNServerConfig{
+			{URL: "turn.example.com:3478", Username: "user", Credential: "pass"},
+			{URL: "turn.example.com:443", Transport: TURNTransportTLS},
+			{URL: "turn:turn.example.com?transport=tcp", Transport: TURNTransportUDP},
+		},
+		ForceRelay: true,
+	}
+	require.NoError(t, conf.Validate(true))
+
+	iceServers, err := conf.turnICEServers()
+	require.NoError(t, err)
+	require.Equal(t, []webrtc.ICEServer{
+		{URLs: []string{"turn:turn.example.com:3478?transport=udp"}, Username: "user", Credential: "pass", CredentialType: webrtc.ICECredentialTypePassword},
+		{URLs: []string{"turns:turn.example.com:443?transport=tcp"}, Credential: "", CredentialType: webrtc.ICECredentialTypePassword},
+		{URLs: []string{"turn:turn.example.com?transport=tcp"}, Credential: "", CredentialType: webrtc.ICECredentialTypePassword},
+	}, iceServers)
+
+	for _, server := range []TURNServerConfig{
+		{},
+		{URL: "turn.example.com", Transport: "sctp"},
+		{URL: "stun:stun.example.com"},
+	} {
+		conf.TURNServers = []TURNServerConfig{server}
+		require.Error(t, conf.Validate(true))
+	}
+
+	// relay only without TURN servers
+	conf.TURNServers = nil
+	require.Error(t, conf.Validate(true))
+	conf.ForceRelay = false
+	require.NoError(t, conf.Validate(true))
+
+	conf.TURNServers = []TURNServerConfig{{URL: "turn.example.com"}}
+	conf.UseICELite = true
+	require.Error(t, conf.Validate(true))
+}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"sync"
 	"time"
 
 	"github.com/pion/stun"
@@ -29,31 +28,64 @@ import (
 )
 
 const (
-	stunPingTimeout   = 5 * time.Second
 	validationTimeout = 5 * time.Second
+
+	defaultSTUNRTO            = 300 * time.Millisecond
+	defaultSTUNMaxRetransmits = 3
+)
+
+var (
+	ErrSTUNNoResponse = errors.New("no response from STUN server")
 )
 
+// STUNRequestConfig controls the STUN binding requests used to discover the external IP.
+// Zero values use the defaults, which wait up to 4.5 seconds for a server.
+type STUNRequestConfig struct {
+	// initial retransmission timeout, doubled after every retransmission
+	RTO time.Duration `yaml:"rto,omitempty"`
+	// retransmissions of a request after the first one, negative disables retransmission
+	MaxRetransmits int `yaml:"max_retransmits,omitempty"`
+	// total deadline of discovery across all servers, external IP validation included
+	Deadline time.Duration `yaml:"deadline,omitempty"`
+}
+
+func (s STUNRequestConfig) rto() time.Duration {
+	if s.RTO <= 0 {
+		return defaultSTUNRTO
+	}
+	return s.RTO
+}
+
+func (s STUNRequestConfig) maxRetransmits() int {
+	switch {
+	case s.MaxRetransmits < 0:
+		return 0
+	case s.MaxRetransmits == 0:
+		return defaultSTUNMaxRetransmits
+	default:
+		return s.MaxRetransmits
+	}
+}
+
+// serverTimeout is the time waited for a response from a server, (2^(retransmits+1) - 1) * RTO
+func (s STUNRequestConfig) serverTimeout() time.Duration {
+	return s.rto() * time.Duration(1<<(s.maxRetransmits()+1)-1)
+}
+
+func (s STUNRequestConfig) deadline(numServers int) time.Duration {
+	if s.Deadline > 0 {
+		return s.Deadline
+	}
+	return time.Duration(numServers) * (s.serverTimeout() + validationTimeout)
+}
+
 func (conf *RTCConfig) determineIP() (string, error) {
 	if conf.UseExternalIP {
-		stunServers := conf.STUNServers
-		if len(stunServers) == 0 {
-			stunServers = DefaultStunServers
-		}
-		var err error
-		for i := 0; i < 3; i++ {
-			var ip string
-			ip, err = GetExternalIP(context.Background(), stunServers, nil)
-			if err == nil {
-				return ip, nil
-			} else {
-				time.Sleep(500 * time.Millisecond)
-			}
-		}
-		logger.Warnw("could not resolve external IP", err)
-		return "", errors.Errorf("could not resolve external IP: %v", err)
+		return conf.resolveExternalIP()
 	}
 
 	// use local ip instead
+	conf.nodeIPSource = AddressSourceInterface
 	addresses, err := GetLocalIPAddresses(false, nil)
 	if len(addresses) > 0 {
 		return addresses[0], err
@@ -111,104 +143,104 @@ func GetLocalIPAddresses(includeLoopback bool, preferredInterfaces []string) ([]
 	return nil, fmt.Errorf("could not find local IP address")
 }
 
-func findExternalIP(ctx context.Context, stunServer string, localAddr net.Addr) (string, error) {
-	ctx1, cancel1 := context.WithTimeout(ctx, stunPingTimeout)
-	defer cancel1()
-
+func findExternalIP(ctx context.Context, stunServer string, localAddr net.Addr, stunConf STUNRequestConfig) (string, error) {
 	dialer := &net.Dialer{
 		LocalAddr: localAddr,
 	}
-	conn, err := dialer.Dial("udp4", stunServer)
+	conn, err := dialer.DialContext(ctx, "udp4", stunServer)
 	if err != nil {
 		return "", err
 	}
-	c, err := stun.NewClient(conn)
+
+	ipAddr, err := stunBinding(ctx, conn, stunConf)
+	conn.Close()
 	if err != nil {
-		conn.Close()
 		return "", err
 	}
+	return ipAddr, validateExternalIP(ctx, ipAddr, localAddr)
+}
 
-	closeConns := func() {
-		c.Close()
-		conn.Close()
-	}
-
-	message, err := stun.Build(stun.TransactionID, stun.BindingRequest)
+// stunBinding sends a binding request, retransmitting it with exponential backoff (RFC 5389, section 7.2.1)
+func stunBinding(ctx context.Context, conn net.Conn, stunConf STUNRequestConfig) (string, error) {
+	request, err := stun.Build(stun.TransactionID, stun.BindingRequest)
 	if err != nil {
-		closeConns()
 		return "", err
 	}
 
-	var mu sync.Mutex
-	var stunErr error
-	var ipAddr string
-	err = c.Start(message, func(res stun.Event) {
-		mu.Lock()
-		defer mu.Unlock()
-
-		if res.Error != nil {
-			stunErr = res.Error
-			return
+	buf := make([]byte, 1500)
+	rto := stunConf.rto()
+	for attempt := 0; attempt <= stunConf.maxRetransmits(); attempt++ {
+		if _, err = conn.Write(request.Raw); err != nil {
+			return "", err
 		}
 
-		var xorAddr stun.XORMappedAddress
-		if err := xorAddr.GetFrom(res.Message); err != nil {
-			stunErr = err
-			return
-		}
-		ip := xorAddr.IP.To4()
-		if ip != nil {
-			ipAddr = ip.String()
+		deadline := time.Now().Add(rto)
+		ctxDeadline, hasDeadline := ctx.Deadline()
+		if hasDeadline && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
 		}
-	})
-	if err != nil {
-		closeConns()
-		return "", err
-	}
+		_ = conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return "", err
+			}
 
-	for {
-		if ctx1.Err() != nil {
-			closeConns()
-			return "", ctx1.Err()
-		}
+			response := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+			if response.Decode() != nil || response.TransactionID != request.TransactionID {
+				continue
+			}
+			if response.Type.Class == stun.ClassErrorResponse {
+				var errorCode stun.ErrorCodeAttribute
+				_ = errorCode.GetFrom(response)
+				return "", errors.Errorf("STUN error response: %s", errorCode)
+			}
 
-		isDone := false
-		mu.Lock()
-		if stunErr != nil || ipAddr != "" {
-			isDone = true
+			var xorAddr stun.XORMappedAddress
+			if err := xorAddr.GetFrom(response); err != nil {
+				return "", err
+			}
+			ip := xorAddr.IP.To4()
+			if ip == nil {
+				return "", errors.New("STUN response has no IPv4 mapped address")
+			}
+			return ip.String(), nil
 		}
-		mu.Unlock()
 
-		if isDone {
-			break
+		if ctx.Err() != nil {
+			return "", ctx.Err()
 		}
-
-		time.Sleep(50 * time.Millisecond)
-	}
-
-	if stunErr != nil {
-		closeConns()
-		return "", stunErr
+		if deadline.Equal(ctxDeadline) {
+			// read timed out at the context deadline, before the context noticed
+			return "", context.DeadlineExceeded
+		}
+		rto *= 2
 	}
-
-	closeConns()
-	return ipAddr, validateExternalIP(ctx, ipAddr, localAddr)
+	return "", ErrSTUNNoResponse
 }
 
 // GetExternalIP return external IP for localAddr from stun server. If localAddr is nil, a local address is chosen automatically,
 // else the address will be used to validate the external IP is accessible from the outside.
 func GetExternalIP(ctx context.Context, stunServers []string, localAddr net.Addr) (string, error) {
+	return GetExternalIPWithConfig(ctx, stunServers, localAddr, STUNRequestConfig{})
+}
+
+// GetExternalIPWithConfig is GetExternalIP with the retransmission policy and deadline of the binding requests set by stunConf.
+func GetExternalIPWithConfig(ctx context.Context, stunServers []string, localAddr net.Addr, stunConf STUNRequestConfig) (string, error) {
 	if len(stunServers) == 0 {
 		return "", errors.New("STUN servers are required but not defined")
 	}
 
-	ctx1, cancel1 := context.WithTimeout(ctx, time.Duration(len(stunServers))*(stunPingTimeout+validationTimeout))
+	ctx1, cancel1 := context.WithTimeout(ctx, stunConf.deadline(len(stunServers)))
 	defer cancel1()
 
 	var err error
 	for _, ss := range stunServers {
 		var ipAddr string
-		ipAddr, err = findExternalIP(ctx1, ss, localAddr)
+		ipAddr, err = findExternalIP(ctx1, ss, localAddr, stunConf)
 		if err == nil {
 			return ipAddr, nil
 		}
@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/require"
+)
+
+// answers binding requests after dropping the first numDrops of them
+func newTestSTUNServer(t *testing.T, numDrops int) (string, <-chan struct{}) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	requests := make(chan struct{}, 100)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			requests <- struct{}{}
+			if len(requests) <= numDrops {
+				continue
+			}
+
+			request := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+			if request.Decode() != nil {
+				continue
+			}
+			response, err := stun.Build(request, stun.BindingSuccess, &stun.XORMappedAddress{IP: addr.IP, Port: addr.Port}, stun.Fingerprint)
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteToUDP(response.Raw, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), requests
+}
+
+func Test_STUNRetransmission(t *testing.T) {
+	server, requests := newTestSTUNServer(t, 2)
+
+	ip, err := findExternalIP(context.Background(), server, nil, STUNRequestConfig{RTO: 20 * time.Millisecond, MaxRetransmits: 2})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", ip)
+	require.Len(t, requests, 3)
+
+	// not enough retransmissions
+	server, requests = newTestSTUNServer(t, 2)
+	_, err = findExternalIP(context.Background(), server, nil, STUNRequestConfig{RTO: 20 * time.Millisecond, MaxRetransmits: -1})
+	require.ErrorIs(t, err, ErrSTUNNoResponse)
+	require.Len(t, requests, 1)
+
+	// deadline
+	server, _ = newTestSTUNServer(t, 100)
+	start := time.Now()
+	_, err = GetExternalIPWithConfig(context.Background(), []string{server}, nil, STUNRequestConfig{Deadline: 100 * time.Millisecond})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.Equal(t, 4500*time.Millisecond, STUNRequestConfig{}.serverTimeout())
+}
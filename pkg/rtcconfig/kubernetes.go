@@ -0,0 +1,372 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	defaultKubernetesNodeNameEnv     = "NODE_NAME"
+	defaultKubernetesHostIPEnv       = "HOST_IP"
+	defaultKubernetesPodNameEnv      = "POD_NAME"
+	defaultKubernetesPodNamespaceEnv = "POD_NAMESPACE"
+
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesAPITimeout        = 5 * time.Second
+)
+
+// KubernetesConfig derives the node IP from the Kubernetes downward API, node status and pod spec.
+// The pod is expected to use host networking or hostPort mappings with the same container and host ports,
+// so that the mux ports are reachable on the node addresses, see CheckHostPorts.
+//
+// Downward API environment, for example
+//
+//	env:
+//	- name: NODE_NAME
+//	  valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+//	- name: HOST_IP
+//	  valueFrom: {fieldRef: {fieldPath: status.hostIP}}
+//	- name: POD_NAME
+//	  valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	- name: POD_NAMESPACE
+//	  valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+type KubernetesConfig struct {
+	Enabled bool `yaml:"enabled,omitempty"`
+	// environment variable holding spec.nodeName, defaults to NODE_NAME
+	NodeNameEnv string `yaml:"node_name_env,omitempty"`
+	// environment variable holding status.hostIP, defaults to HOST_IP
+	HostIPEnv string `yaml:"host_ip_env,omitempty"`
+	// advertise the ExternalIP of the node from the node status, requires permission to get nodes.
+	// Without an ExternalIP, the host IP is used, or the first InternalIP when the host IP is not set
+	UseNodeExternalIP bool `yaml:"use_node_external_ip,omitempty"`
+	// environment variable holding metadata.name, defaults to POD_NAME
+	PodNameEnv string `yaml:"pod_name_env,omitempty"`
+	// environment variable holding metadata.namespace, defaults to POD_NAMESPACE,
+	// falling back to the namespace of the service account
+	PodNamespaceEnv string `yaml:"pod_namespace_env,omitempty"`
+	// read the hostPort mappings from the pod spec and fail unless UDPPort and TCPPort are mapped to the same
+	// ports on the node, or the pod uses host networking. Candidates carry the container port, a mapping to a
+	// different host port would advertise an unreachable address. Requires permission to get pods
+	CheckHostPorts bool `yaml:"check_host_ports,omitempty"`
+}
+
+// NodeAddresses are the addresses of a Kubernetes node as reported in its status.
+type NodeAddresses struct {
+	InternalIPs []string
+	ExternalIPs []string
+}
+
+// PodPorts are the hostPort mappings of a Kubernetes pod as declared in its spec.
+type PodPorts struct {
+	HostNetwork bool
+	// host IP from the pod status, empty until the pod is scheduled
+	HostIP string
+	// container port to host port, by protocol
+	UDP map[uint32]uint32
+	TCP map[uint32]uint32
+}
+
+// ResolveKubernetes sets NodeIP from the Kubernetes node when Kubernetes is enabled and NodeIP is not set.
+// It calls the Kubernetes API when UseNodeExternalIP or CheckHostPorts is set, and is run before Validate,
+// with the ports set, as Validate does not resolve the node IP.
+func (conf *RTCConfig) ResolveKubernetes(ctx context.Context) error {
+	if !conf.Kubernetes.Enabled || conf.NodeIP != "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, kubernetesAPITimeout)
+	defer cancel()
+
+	nodeIP, err := conf.resolveKubernetesNodeIP(ctx)
+	if err != nil {
+		logger.Warnw("could not determine node ip from kubernetes", err)
+		return err
+	}
+	// set as if configured, so that it is advertised in place of local addresses
+	conf.NodeIP = nodeIP
+	conf.nodeIPSource = AddressSourceKubernetes
+	return nil
+}
+
+// resolveKubernetesNodeIP returns the address to advertise, the node external IP when requested and present,
+// the host IP otherwise
+func (conf *RTCConfig) resolveKubernetesNodeIP(ctx context.Context) (string, error) {
+	k := conf.Kubernetes
+	hostIPEnv := k.HostIPEnv
+	if hostIPEnv == "" {
+		hostIPEnv = defaultKubernetesHostIPEnv
+	}
+	hostIP := os.Getenv(hostIPEnv)
+
+	var client *kubernetesClient
+	if k.UseNodeExternalIP || k.CheckHostPorts {
+		var err error
+		if client, err = newInClusterKubernetesClient(); err != nil {
+			return "", err
+		}
+	}
+
+	if k.CheckHostPorts {
+		ports, err := client.getPodPorts(ctx, k.podNamespace(), k.podName())
+		if err != nil {
+			return "", err
+		}
+		if err = conf.checkKubernetesHostPorts(ports); err != nil {
+			return "", err
+		}
+		if hostIP == "" {
+			hostIP = ports.HostIP
+		}
+	}
+
+	if k.UseNodeExternalIP {
+		nodeNameEnv := k.NodeNameEnv
+		if nodeNameEnv == "" {
+			nodeNameEnv = defaultKubernetesNodeNameEnv
+		}
+		nodeName := os.Getenv(nodeNameEnv)
+		if nodeName == "" {
+			return "", fmt.Errorf("node name not set in %s", nodeNameEnv)
+		}
+
+		addresses, err := client.getNodeAddresses(ctx, nodeName)
+		if err != nil {
+			return "", err
+		}
+		if len(addresses.ExternalIPs) != 0 {
+			return addresses.ExternalIPs[0], nil
+		}
+		if hostIP == "" && len(addresses.InternalIPs) != 0 {
+			hostIP = addresses.InternalIPs[0]
+		}
+		logger.Infow("node has no external IP, using host IP", "node", nodeName, "hostIP", hostIP)
+	}
+
+	if net.ParseIP(hostIP) == nil {
+		return "", fmt.Errorf("invalid host IP %q in %s", hostIP, hostIPEnv)
+	}
+	return hostIP, nil
+}
+
+// checkKubernetesHostPorts returns an error if a mux port is not reachable on the node at the same port
+func (conf *RTCConfig) checkKubernetesHostPorts(ports PodPorts) error {
+	if ports.HostNetwork {
+		return nil
+	}
+	if !conf.UDPPort.Valid() && conf.ICEPortRangeStart != 0 {
+		return errors.New("ICE port range requires host networking, use a UDP port with a hostPort mapping")
+	}
+
+	check := func(protocol string, mappings map[uint32]uint32, port uint32) error {
+		hostPort, ok := mappings[port]
+		if !ok {
+			return fmt.Errorf("%s port %d has no hostPort mapping", protocol, port)
+		}
+		if hostPort != port {
+			return fmt.Errorf("%s port %d is mapped to hostPort %d, container and host ports must match", protocol, port, hostPort)
+		}
+		return nil
+	}
+	if conf.UDPPort.Valid() {
+		for _, port := range conf.UDPPort.ToSlice() {
+			if err := check("UDP", ports.UDP, uint32(port)); err != nil {
+				return err
+			}
+		}
+	}
+	if conf.TCPPort != 0 {
+		if err := check("TCP", ports.TCP, conf.TCPPort); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k KubernetesConfig) podName() string {
+	env := k.PodNameEnv
+	if env == "" {
+		env = defaultKubernetesPodNameEnv
+	}
+	return os.Getenv(env)
+}
+
+func (k KubernetesConfig) podNamespace() string {
+	env := k.PodNamespaceEnv
+	if env == "" {
+		env = defaultKubernetesPodNamespaceEnv
+	}
+	if namespace := os.Getenv(env); namespace != "" {
+		return namespace
+	}
+	namespace, err := os.ReadFile(kubernetesServiceAccountDir + "/namespace")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(namespace))
+}
+
+// ------------------------------------------------
+
+type kubernetesClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newInClusterKubernetesClient() (*kubernetesClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a Kubernetes cluster")
+	}
+
+	token, err := os.ReadFile(kubernetesServiceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account token: %w", err)
+	}
+	ca, err := os.ReadFile(kubernetesServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("could not read service account CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("invalid service account CA")
+	}
+
+	return &kubernetesClient{
+		baseURL: "https://" + net.JoinHostPort(host, port),
+		token:   string(token),
+		client: &http.Client{
+			Timeout: kubernetesAPITimeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+func (k *kubernetesClient) get(ctx context.Context, path, kind, name string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get %s %s: %s", kind, name, res.Status)
+	}
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("could not decode %s: %w", kind, err)
+	}
+	return nil
+}
+
+func (k *kubernetesClient) getNodeAddresses(ctx context.Context, nodeName string) (NodeAddresses, error) {
+	var node struct {
+		Status struct {
+			Addresses []struct {
+				Type    string `json:"type"`
+				Address string `json:"address"`
+			} `json:"addresses"`
+		} `json:"status"`
+	}
+	if err := k.get(ctx, "/api/v1/nodes/"+url.PathEscape(nodeName), "node", nodeName, &node); err != nil {
+		return NodeAddresses{}, err
+	}
+
+	var addresses NodeAddresses
+	for _, a := range node.Status.Addresses {
+		if net.ParseIP(a.Address) == nil {
+			continue
+		}
+		switch a.Type {
+		case "InternalIP":
+			addresses.InternalIPs = append(addresses.InternalIPs, a.Address)
+		case "ExternalIP":
+			addresses.ExternalIPs = append(addresses.ExternalIPs, a.Address)
+		}
+	}
+	return addresses, nil
+}
+
+func (k *kubernetesClient) getPodPorts(ctx context.Context, namespace string, podName string) (PodPorts, error) {
+	if namespace == "" || podName == "" {
+		return PodPorts{}, errors.New("pod name or namespace not set")
+	}
+
+	var pod struct {
+		Spec struct {
+			HostNetwork bool `json:"hostNetwork"`
+			Containers  []struct {
+				Ports []struct {
+					ContainerPort uint32 `json:"containerPort"`
+					HostPort      uint32 `json:"hostPort"`
+					Protocol      string `json:"protocol"`
+				} `json:"ports"`
+			} `json:"containers"`
+		} `json:"spec"`
+		Status struct {
+			HostIP string `json:"hostIP"`
+		} `json:"status"`
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(namespace) + "/pods/" + url.PathEscape(podName)
+	if err := k.get(ctx, path, "pod", namespace+"/"+podName, &pod); err != nil {
+		return PodPorts{}, err
+	}
+
+	ports := PodPorts{
+		HostNetwork: pod.Spec.HostNetwork,
+		HostIP:      pod.Status.HostIP,
+		UDP:         make(map[uint32]uint32),
+		TCP:         make(map[uint32]uint32),
+	}
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			switch p.Protocol {
+			case "UDP":
+				ports.UDP[p.ContainerPort] = p.HostPort
+			case "", "TCP":
+				// TCP is the default protocol
+				ports.TCP[p.ContainerPort] = p.HostPort
+			}
+		}
+	}
+	return ports, nil
+}
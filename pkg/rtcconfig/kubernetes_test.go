@@ -0,0 +1,120 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_KubernetesNodeAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes/node-1" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":{"addresses":[
+			{"type":"Hostname","address":"node-1"},
+			{"type":"InternalIP","address":"10.0.0.5"},
+			{"type":"ExternalIP","address":"203.0.113.5"}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	client := &kubernetesClient{baseURL: srv.URL, token: "token", client: srv.Client()}
+	addresses, err := client.getNodeAddresses(context.Background(), "node-1")
+	require.NoError(t, err)
+	require.Equal(t, NodeAddresses{InternalIPs: []string{"10.0.0.5"}, ExternalIPs: []string{"203.0.113.5"}}, addresses)
+
+	_, err = client.getNodeAddresses(context.Background(), "node-2")
+	require.Error(t, err)
+}
+
+func Test_KubernetesHostIP(t *testing.T) {
+	t.Setenv("TEST_HOST_IP", "10.0.0.5")
+
+	conf := &RTCConfig{
+		UDPPort:    PortRange{Start: 7882},
+		Kubernetes: KubernetesConfig{Enabled: true, HostIPEnv: "TEST_HOST_IP"},
+	}
+	require.Error(t, conf.Validate(true))
+	require.NoError(t, conf.ResolveKubernetes(context.Background()))
+	require.Equal(t, "10.0.0.5", conf.NodeIP)
+	require.NoError(t, conf.Validate(true))
+	require.Equal(t, "10.0.0.5", conf.NodeIP)
+	require.False(t, conf.NodeIPAutoGenerated)
+
+	conf = &RTCConfig{
+		UDPPort:    PortRange{Start: 7882},
+		Kubernetes: KubernetesConfig{Enabled: true, HostIPEnv: "TEST_MISSING_HOST_IP"},
+	}
+	require.Error(t, conf.ResolveKubernetes(context.Background()))
+	require.Empty(t, conf.NodeIP)
+
+	// a configured node IP is kept
+	conf = &RTCConfig{
+		NodeIP:     "192.0.2.1",
+		Kubernetes: KubernetesConfig{Enabled: true, HostIPEnv: "TEST_HOST_IP"},
+	}
+	require.NoError(t, conf.ResolveKubernetes(context.Background()))
+	require.Equal(t, "192.0.2.1", conf.NodeIP)
+}
+
+func Test_KubernetesPodPorts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/media/pods/sfu-0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"spec":{"containers":[{"ports":[
+			{"containerPort":7880},
+			{"containerPort":7881,"hostPort":7881},
+			{"containerPort":7882,"hostPort":7882,"protocol":"UDP"},
+			{"containerPort":7883,"hostPort":30883,"protocol":"UDP"}
+		]}]},"status":{"hostIP":"10.0.0.5"}}`))
+	}))
+	defer srv.Close()
+
+	client := &kubernetesClient{baseURL: srv.URL, client: srv.Client()}
+	ports, err := client.getPodPorts(context.Background(), "media", "sfu-0")
+	require.NoError(t, err)
+	require.Equal(t, PodPorts{
+		HostIP: "10.0.0.5",
+		UDP:    map[uint32]uint32{7882: 7882, 7883: 30883},
+		TCP:    map[uint32]uint32{7881: 7881},
+	}, ports)
+
+	_, err = client.getPodPorts(context.Background(), "media", "sfu-1")
+	require.Error(t, err)
+
+	conf := &RTCConfig{UDPPort: PortRange{Start: 7882}, TCPPort: 7881}
+	require.NoError(t, conf.checkKubernetesHostPorts(ports))
+
+	// mapped to a different host port
+	conf = &RTCConfig{UDPPort: PortRange{Start: 7882, End: 7883}}
+	require.Error(t, conf.checkKubernetesHostPorts(ports))
+
+	// not mapped
+	conf = &RTCConfig{UDPPort: PortRange{Start: 7882}, TCPPort: 7880}
+	require.Error(t, conf.checkKubernetesHostPorts(ports))
+
+	conf = &RTCConfig{ICEPortRangeStart: 50000, ICEPortRangeEnd: 60000}
+	require.Error(t, conf.checkKubernetesHostPorts(ports))
+	require.NoError(t, conf.checkKubernetesHostPorts(PodPorts{HostNetwork: true}))
+}
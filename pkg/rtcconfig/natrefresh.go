@@ -0,0 +1,254 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+)
+
+var (
+	errNoNATMapping = errors.New("no local address mapped to an external IP")
+)
+
+type NATRefreshParams struct {
+	// interval between checks of the external IPs
+	Interval time.Duration
+}
+
+var NATRefreshParamsDefault = NATRefreshParams{
+	Interval: 5 * time.Minute,
+}
+
+// NATDrift reports external IPs that changed since the NAT 1:1 mapping was resolved.
+type NATDrift struct {
+	Previous []string
+	Current  []string
+	// the new mapping applied to new peer connections, unset if Err is set
+	Reload ReloadEvent
+	// applying the new mapping failed, the settings are unchanged
+	Err error
+}
+
+type NATRefreshStats struct {
+	NumChecks   int
+	NumFailures int
+	NumDrifts   int
+	LastCheck   time.Time
+}
+
+// NATRefresher periodically resolves the external IPs of the node again, as they may change after startup
+// (cloud reassignment, DHCP), and when they drift from the NAT 1:1 mapping in use, applies the resolved mapping
+// as WebRTCConfig.Reload does, without resolving it again, and reports it, so that the server can recycle listeners and restart sessions.
+// Checks resolving no external IP, for example when STUN servers do not respond, are counted as failures
+// and leave the mapping in use. It does nothing unless UseExternalIP is set.
+// Typical use is
+//
+//	r := rtcconfig.NewNATRefresher(conf, rtcConf, rtcconfig.NATRefreshParamsDefault)
+//	r.OnDrift(func(drift rtcconfig.NATDrift) { ... })
+//	r.Start()
+//	defer r.Stop()
+type NATRefresher struct {
+	params  NATRefreshParams
+	conf    *WebRTCConfig
+	rtcConf *RTCConfig
+
+	resolve func() (natResolution, error)
+	reload  func(resolved natResolution) (ReloadEvent, error)
+
+	// serializes checks
+	refreshLock sync.Mutex
+
+	lock    sync.Mutex
+	stats   NATRefreshStats
+	onDrift func(drift NATDrift)
+	stop    chan struct{}
+	started bool
+	stopped bool
+}
+
+func NewNATRefresher(conf *WebRTCConfig, rtcConf *RTCConfig, params NATRefreshParams) *NATRefresher {
+	if params.Interval <= 0 {
+		params.Interval = NATRefreshParamsDefault.Interval
+	}
+	r := &NATRefresher{
+		params:  params,
+		conf:    conf,
+		rtcConf: rtcConf,
+		stop:    make(chan struct{}),
+	}
+	r.resolve = r.resolveNAT1To1IPs
+	r.reload = func(resolved natResolution) (ReloadEvent, error) {
+		return conf.reload(rtcConf, &resolved)
+	}
+	return r
+}
+
+// OnDrift sets a callback called when the external IPs drift from the NAT 1:1 mapping in use.
+func (r *NATRefresher) OnDrift(f func(drift NATDrift)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.onDrift = f
+}
+
+func (r *NATRefresher) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.started || r.stopped || !r.rtcConf.UseExternalIP {
+		return
+	}
+	r.started = true
+	go r.worker()
+}
+
+func (r *NATRefresher) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.stopped {
+		return
+	}
+	r.stopped = true
+	close(r.stop)
+}
+
+func (r *NATRefresher) Stats() NATRefreshStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.stats
+}
+
+func (r *NATRefresher) worker() {
+	ticker := time.NewTicker(r.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if _, _, err := r.Refresh(); err != nil {
+				logger.Warnw("could not refresh NAT mapping", err)
+			}
+		}
+	}
+}
+
+// Refresh checks the external IPs right away, returning the drift and true if they changed.
+func (r *NATRefresher) Refresh() (NATDrift, bool, error) {
+	if !r.rtcConf.UseExternalIP {
+		return NATDrift{}, false, nil
+	}
+
+	r.refreshLock.Lock()
+	defer r.refreshLock.Unlock()
+
+	resolved, err := r.resolve()
+	if err == nil && len(resolved.ips) == 0 {
+		err = errNoNATMapping
+	}
+	ips := resolved.ips
+	previous := r.conf.Settings().NAT1To1IPs
+
+	r.lock.Lock()
+	r.stats.NumChecks++
+	r.stats.LastCheck = time.Now()
+	if err != nil {
+		r.stats.NumFailures++
+		r.lock.Unlock()
+		return NATDrift{}, false, err
+	}
+	if sameIPs(ips, previous) {
+		r.lock.Unlock()
+		return NATDrift{}, false, nil
+	}
+	r.stats.NumDrifts++
+	r.lock.Unlock()
+
+	logger.Infow("NAT mapping drifted", "previous", previous, "current", ips)
+	drift := NATDrift{
+		Previous: previous,
+		Current:  ips,
+	}
+	drift.Reload, drift.Err = r.reload(resolved)
+
+	r.lock.Lock()
+	onDrift := r.onDrift
+	r.lock.Unlock()
+
+	if onDrift != nil {
+		onDrift(drift)
+	}
+	return drift, true, nil
+}
+
+// resolveNAT1To1IPs resolves the mapping from ephemeral ports as the configured ones are bound by the mux,
+// after invalidating the external IP cache which would otherwise return the mapping in use
+func (r *NATRefresher) resolveNAT1To1IPs() (natResolution, error) {
+	// getNAT1to1IPsForConf records the source of the mapping on the config
+	c := *r.rtcConf
+	if err := c.InvalidateExternalIPCache(); err != nil {
+		return natResolution{}, err
+	}
+	_, ipFilter, err := c.filters()
+	if err != nil {
+		return natResolution{}, err
+	}
+	ips, ipFilter, err := getNAT1to1IPsForConf(&c, ipFilter, []int{0})
+	if err != nil {
+		return natResolution{}, err
+	}
+	return natResolution{ips: ips, ipFilter: ipFilter}, nil
+}
+
+// ------------------------------------------------
+
+// natResolution is a NAT 1:1 mapping resolved ahead of applying it, see configureNAT1To1
+type natResolution struct {
+	// external/local pairs
+	ips []string
+	// IP filter to apply with the mapping, restricted to mapped addresses with ExternalIPOnly
+	ipFilter func(net.IP) bool
+}
+
+// nodeIP returns current if it is still an external IP of the mapping, the lowest external IP otherwise.
+// Local addresses mapped to themselves are not external IPs.
+func (n natResolution) nodeIP(current string) string {
+	externalIPs := make([]string, 0, len(n.ips))
+	for _, ip := range n.ips {
+		external, local, _ := strings.Cut(ip, "/")
+		if external == local {
+			continue
+		}
+		if external == current {
+			return current
+		}
+		externalIPs = append(externalIPs, external)
+	}
+	if len(externalIPs) == 0 {
+		return current
+	}
+	sort.Strings(externalIPs)
+	return externalIPs[0]
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NATRefresher(t *testing.T) {
+	c := &WebRTCConfig{NAT1To1IPs: []string{"1.1.1.1/10.0.0.1"}, nodeIP: "1.1.1.1"}
+	rtcConf := &RTCConfig{UseExternalIP: true, NodeIP: "1.1.1.1", NodeIPAutoGenerated: true}
+	r := NewNATRefresher(c, rtcConf, NATRefreshParams{Interval: 10 * time.Millisecond})
+
+	var resolved []string
+	var resolveErr error
+	numResolves := 0
+	r.resolve = func() (natResolution, error) {
+		numResolves++
+		return natResolution{ips: resolved}, resolveErr
+	}
+	drifts := make(chan NATDrift, 1)
+	r.OnDrift(func(drift NATDrift) {
+		drifts <- drift
+	})
+
+	// unchanged
+	resolved = []string{"1.1.1.1/10.0.0.1"}
+	_, drifted, err := r.Refresh()
+	require.NoError(t, err)
+	require.False(t, drifted)
+
+	// failures leave the mapping in use
+	resolved = nil
+	_, _, err = r.Refresh()
+	require.ErrorIs(t, err, errNoNATMapping)
+	resolveErr = errors.New("no network")
+	_, _, err = r.Refresh()
+	require.Error(t, err)
+	resolveErr = nil
+
+	resolved = []string{"2.2.2.2/10.0.0.1"}
+	drift, drifted, err := r.Refresh()
+	require.NoError(t, err)
+	require.True(t, drifted)
+	require.Equal(t, []string{"1.1.1.1/10.0.0.1"}, drift.Previous)
+	require.Equal(t, []string{"2.2.2.2/10.0.0.1"}, drift.Current)
+	require.True(t, drift.Reload.AddressesChanged)
+	require.NoError(t, drift.Err)
+	require.Equal(t, drift, <-drifts)
+	// the reported mapping is applied without resolving again
+	require.Equal(t, 4, numResolves)
+	require.Equal(t, resolved, c.Settings().NAT1To1IPs)
+	require.Equal(t, "2.2.2.2", rtcConf.NodeIP)
+	require.Equal(t, []string{"1.1.1.1/10.0.0.1"}, c.NAT1To1IPs)
+
+	stats := r.Stats()
+	require.Equal(t, 4, stats.NumChecks)
+	require.Equal(t, 2, stats.NumFailures)
+	require.Equal(t, 1, stats.NumDrifts)
+
+	// in the background
+	resolved = []string{"3.3.3.3/10.0.0.1"}
+	r.Start()
+	defer r.Stop()
+	select {
+	case drift = <-drifts:
+		require.Equal(t, []string{"3.3.3.3/10.0.0.1"}, drift.Current)
+	case <-time.After(time.Second):
+		t.Fatal("drift not reported")
+	}
+}
+
+func Test_NATResolutionNodeIP(t *testing.T) {
+	n := natResolution{ips: []string{"3.3.3.3/10.0.0.2", "10.0.0.3/10.0.0.3", "2.2.2.2/10.0.0.1"}}
+	require.Equal(t, "3.3.3.3", n.nodeIP("3.3.3.3"))
+	require.Equal(t, "2.2.2.2", n.nodeIP("1.1.1.1"))
+	require.Equal(t, "1.1.1.1", natResolution{ips: []string{"10.0.0.3/10.0.0.3"}}.nodeIP("1.1.1.1"))
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/fdtrack"
+	"github.com/livekit/mediatransportutil/pkg/transport"
+)
+
+const (
+	// a server holds a socket per participant, muxes and listeners
+	recommendedFDLimit = 65536
+	minFDLimit         = 1024
+	minICEPortRange    = 100
+)
+
+var (
+	// wall clock earlier than this is considered unset
+	minWallClock = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+type PreflightCheck string
+
+const (
+	PreflightCheckPorts   PreflightCheck = "ports"
+	PreflightCheckBuffers PreflightCheck = "buffers"
+	PreflightCheckSTUN    PreflightCheck = "stun"
+	PreflightCheckClock   PreflightCheck = "clock"
+	PreflightCheckFDLimit PreflightCheck = "fd_limit"
+)
+
+type PreflightSeverity int
+
+const (
+	PreflightSeverityWarning PreflightSeverity = iota
+	PreflightSeverityError
+)
+
+func (s PreflightSeverity) String() string {
+	switch s {
+	case PreflightSeverityWarning:
+		return "WARNING"
+	case PreflightSeverityError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type PreflightIssue struct {
+	Check    PreflightCheck
+	Severity PreflightSeverity
+	Message  string
+	Err      error
+}
+
+func (i PreflightIssue) String() string {
+	if i.Err != nil {
+		return fmt.Sprintf("%s %s: %s: %v", i.Severity, i.Check, i.Message, i.Err)
+	}
+	return fmt.Sprintf("%s %s: %s", i.Severity, i.Check, i.Message)
+}
+
+// PreflightReport lists the issues found by Preflight. Errors mean the service will not work as configured,
+// warnings that it will work with reduced capacity or reliability.
+type PreflightReport struct {
+	Issues   []PreflightIssue
+	Duration time.Duration
+
+	// details of the checks that ran, 0 or empty when a check was skipped or failed
+	UDPReadBuffer          int
+	FDLimit                uint64
+	FDLimitMax             uint64
+	ExternalIP             string
+	STUNServersReachable   []string
+	STUNServersUnreachable []string
+}
+
+func (r *PreflightReport) OK() bool {
+	return len(r.Errors()) == 0
+}
+
+func (r *PreflightReport) Errors() []PreflightIssue {
+	return r.issues(PreflightSeverityError)
+}
+
+func (r *PreflightReport) Warnings() []PreflightIssue {
+	return r.issues(PreflightSeverityWarning)
+}
+
+// Err returns the errors of the report as a single error, nil if there are none.
+func (r *PreflightReport) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, issue := range errs {
+		messages = append(messages, issue.String())
+	}
+	return fmt.Errorf("preflight failed: %s", strings.Join(messages, "; "))
+}
+
+func (r *PreflightReport) issues(severity PreflightSeverity) []PreflightIssue {
+	var issues []PreflightIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *PreflightReport) add(check PreflightCheck, severity PreflightSeverity, err error, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, PreflightIssue{
+		Check:    check,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+		Err:      err,
+	})
+}
+
+type PreflightParams struct {
+	// skip STUN reachability, for example in environments without internet access
+	SkipSTUN bool
+	// minimum RLIMIT_NOFILE below which a warning is reported
+	RecommendedFDLimit uint64
+}
+
+var PreflightParamsDefault = PreflightParams{
+	RecommendedFDLimit: recommendedFDLimit,
+}
+
+// Preflight checks the host environment against a validated config: ports can be bound, UDP buffers
+// and file descriptor limits are large enough, STUN servers are reachable and the wall clock is sane.
+// It is meant to run once at startup, before accepting traffic, and may take up to the STUN server timeout, see STUNRequestConfig.
+func (conf *RTCConfig) Preflight(ctx context.Context, params PreflightParams) *PreflightReport {
+	if params.RecommendedFDLimit == 0 {
+		params.RecommendedFDLimit = PreflightParamsDefault.RecommendedFDLimit
+	}
+
+	start := time.Now()
+	r := &PreflightReport{}
+	conf.preflightPorts(r)
+	conf.preflightBuffers(r)
+	preflightFDLimit(r, params.RecommendedFDLimit)
+	if !params.SkipSTUN {
+		conf.preflightSTUN(ctx, r)
+	}
+	// last, so that the monotonic clock has advanced
+	preflightClock(r, start)
+	r.Duration = time.Since(start)
+	return r
+}
+
+func (conf *RTCConfig) preflightPorts(r *PreflightReport) {
+	if !conf.ForceTCP {
+		for _, port := range conf.udpMuxPorts() {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+			if err != nil {
+				r.add(PreflightCheckPorts, PreflightSeverityError, err, "cannot bind UDP port %d", port)
+				continue
+			}
+			_ = conn.Close()
+		}
+	}
+
+	if conf.TCPPort != 0 {
+		ln, err := net.ListenTCP("tcp", &net.TCPAddr{Port: int(conf.TCPPort)})
+		if err != nil {
+			r.add(PreflightCheckPorts, PreflightSeverityError, err, "cannot bind TCP port %d", conf.TCPPort)
+		} else {
+			_ = ln.Close()
+		}
+	}
+
+	if conf.ICEPortRangeStart != 0 && conf.ICEPortRangeEnd != 0 {
+		if conf.ICEPortRangeEnd < conf.ICEPortRangeStart {
+			r.add(PreflightCheckPorts, PreflightSeverityError, nil,
+				"ICE port range end %d is less than start %d", conf.ICEPortRangeEnd, conf.ICEPortRangeStart)
+		} else if size := conf.ICEPortRangeEnd - conf.ICEPortRangeStart + 1; size < minICEPortRange {
+			r.add(PreflightCheckPorts, PreflightSeverityWarning, nil,
+				"ICE port range has %d ports, limiting concurrent connections", size)
+		}
+	}
+}
+
+func (conf *RTCConfig) preflightBuffers(r *PreflightReport) {
+	if conf.ForceTCP {
+		return
+	}
+
+	val, err := getUDPReadBuffer()
+	if errors.Is(err, transport.ErrSocketOptionNotSupported) {
+		r.add(PreflightCheckBuffers, PreflightSeverityWarning, err,
+			"cannot check UDP receive buffer size on this platform, %d is suggested for production", minUDPBufferSize)
+		return
+	}
+	if err != nil {
+		r.add(PreflightCheckBuffers, PreflightSeverityWarning, err, "cannot read UDP receive buffer size")
+		return
+	}
+	r.UDPReadBuffer = val
+	if val < minUDPBufferSize {
+		r.add(PreflightCheckBuffers, PreflightSeverityWarning, nil,
+			"UDP receive buffer is %d bytes, %d is suggested for production", val, minUDPBufferSize)
+	}
+}
+
+func preflightFDLimit(r *PreflightReport, recommended uint64) {
+	cur, hard, err := fdtrack.Limit()
+	if errors.Is(err, fdtrack.ErrNotSupported) {
+		return
+	}
+	if err != nil {
+		r.add(PreflightCheckFDLimit, PreflightSeverityWarning, err, "cannot read file descriptor limit")
+		return
+	}
+	r.FDLimit, r.FDLimitMax = cur, hard
+
+	switch {
+	case cur < minFDLimit:
+		r.add(PreflightCheckFDLimit, PreflightSeverityError, nil,
+			"file descriptor limit is %d, at least %d is required", cur, minFDLimit)
+	case cur < recommended && hard > cur:
+		r.add(PreflightCheckFDLimit, PreflightSeverityWarning, nil,
+			"file descriptor limit is %d, %d is recommended, the hard limit allows up to %d", cur, recommended, hard)
+	case cur < recommended:
+		r.add(PreflightCheckFDLimit, PreflightSeverityWarning, nil,
+			"file descriptor limit is %d, %d is recommended", cur, recommended)
+	}
+}
+
+func (conf *RTCConfig) preflightSTUN(ctx context.Context, r *PreflightReport) {
+	if !conf.UseExternalIP && len(conf.STUNServers) == 0 {
+		return
+	}
+
+	stunServers := conf.stunServers()
+	ips := make([]string, len(stunServers))
+	errs := make([]error, len(stunServers))
+	var wg sync.WaitGroup
+	for i, server := range stunServers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			ips[i], errs[i] = findExternalIP(ctx, server, nil, conf.STUNRequest)
+		}(i, server)
+	}
+	wg.Wait()
+
+	for i, server := range stunServers {
+		if errs[i] != nil {
+			r.STUNServersUnreachable = append(r.STUNServersUnreachable, server)
+			r.add(PreflightCheckSTUN, PreflightSeverityWarning, errs[i], "STUN server %s is unreachable", server)
+			continue
+		}
+		r.STUNServersReachable = append(r.STUNServersReachable, server)
+		if r.ExternalIP == "" {
+			r.ExternalIP = ips[i]
+		}
+	}
+
+	if len(r.STUNServersReachable) == 0 {
+		severity := PreflightSeverityWarning
+		if conf.UseExternalIP && (conf.ExternalIPPolicy == "" || conf.ExternalIPPolicy == ExternalIPPolicyFailFast) {
+			severity = PreflightSeverityError
+		}
+		r.add(PreflightCheckSTUN, severity, ErrExternalIPUnresolved, "no STUN server is reachable")
+	} else if conf.UseExternalIP && conf.NodeIP != "" && !conf.NodeIPAutoGenerated && conf.NodeIP != r.ExternalIP {
+		r.add(PreflightCheckSTUN, PreflightSeverityWarning, nil,
+			"node IP %s does not match external IP %s from STUN", conf.NodeIP, r.ExternalIP)
+	}
+}
+
+// RTP timing and DTLS certificates rely on the wall clock, an unset clock or one stepped
+// while checking is reported
+func preflightClock(r *PreflightReport, start time.Time) {
+	now := time.Now()
+	if now.Before(minWallClock) {
+		r.add(PreflightCheckClock, PreflightSeverityError, nil, "wall clock is unset, it reads %s", now.UTC().Format(time.RFC3339))
+		return
+	}
+
+	// Round(0) strips the monotonic reading, the difference is the wall clock step
+	wall := now.Round(0).Sub(start.Round(0))
+	if step := wall - now.Sub(start); step > time.Second || step < -time.Second {
+		r.add(PreflightCheckClock, PreflightSeverityWarning, nil, "wall clock stepped by %s during preflight", step)
+	}
+}
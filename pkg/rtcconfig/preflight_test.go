@@ -0,0 +1,56 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Preflight(t *testing.T) {
+	udp, err := net.ListenUDP("udp", &net.UDPAddr{})
+	require.NoError(t, err)
+	udpPort := udp.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, udp.Close())
+
+	conf := &RTCConfig{
+		UDPPort:           PortRange{Start: udpPort},
+		ICEPortRangeStart: 50000,
+		ICEPortRangeEnd:   50010,
+	}
+	r := conf.Preflight(context.Background(), PreflightParams{SkipSTUN: true, RecommendedFDLimit: 1})
+	require.True(t, r.OK(), r.Err())
+	require.NoError(t, r.Err())
+	warnings := r.Warnings()
+	require.NotEmpty(t, warnings)
+	require.Equal(t, PreflightCheckPorts, warnings[0].Check)
+
+	// port in use
+	ln, err := net.ListenTCP("tcp", &net.TCPAddr{})
+	require.NoError(t, err)
+	defer ln.Close()
+
+	conf.TCPPort = uint32(ln.Addr().(*net.TCPAddr).Port)
+	r = conf.Preflight(context.Background(), PreflightParams{SkipSTUN: true})
+	require.False(t, r.OK())
+	require.Error(t, r.Err())
+	errs := r.Errors()
+	require.Len(t, errs, 1)
+	require.Equal(t, PreflightCheckPorts, errs[0].Check)
+	require.Equal(t, PreflightSeverityError, errs[0].Severity)
+}
@@ -0,0 +1,168 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"reflect"
+
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slices"
+)
+
+// WebRTCSettings are the settings new peer connections are created with, those updated by Reload.
+type WebRTCSettings struct {
+	Configuration        webrtc.Configuration
+	SettingEngine        webrtc.SettingEngine
+	NAT1To1IPs           []string
+	StaticHostCandidates []StaticHostCandidate
+}
+
+// ReloadEvent reports a Reload that changed the settings of new peer connections.
+type ReloadEvent struct {
+	Settings WebRTCSettings
+	// NAT 1:1 IPs or static host candidates changed, the node is advertised at different addresses
+	AddressesChanged  bool
+	ICEServersChanged bool
+}
+
+// Settings returns the settings for a new peer connection, safe to call concurrently with Reload.
+// Until the first Reload, these are the settings of the exported fields.
+func (c *WebRTCConfig) Settings() WebRTCSettings {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.settings != nil {
+		return *c.settings
+	}
+	return WebRTCSettings{
+		Configuration:        c.Configuration,
+		SettingEngine:        c.SettingEngine,
+		NAT1To1IPs:           c.NAT1To1IPs,
+		StaticHostCandidates: c.StaticHostCandidates,
+	}
+}
+
+// Changes returns a channel receiving an event when Reload changes the settings of new peer connections.
+// Only the latest event is kept for a consumer that has not received the previous one.
+func (c *WebRTCConfig) Changes() <-chan ReloadEvent {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.changesLocked()
+}
+
+// Reload applies rtcConf to new peer connections without restarting the process: with UseExternalIP, the external
+// IP cache is invalidated and the node IP and NAT 1:1 mappings resolved again, then NAT 1:1 mappings, static host
+// candidates and ICE servers are rebuilt. Sockets are not recreated, changes to ports, interfaces or mux options
+// take effect on restart. rtcConf is validated by the caller, as for NewWebRTCConfig, and its NodeIP is updated.
+// On error, the settings are left unchanged. Concurrent calls are applied one at a time.
+//
+// The exported fields keep the settings the WebRTCConfig was created with, new settings are published
+// through Settings and Changes.
+func (c *WebRTCConfig) Reload(rtcConf *RTCConfig) (ReloadEvent, error) {
+	return c.reload(rtcConf, nil)
+}
+
+// reload is Reload, applying the NAT 1:1 mapping in resolved instead of resolving it when set
+func (c *WebRTCConfig) reload(rtcConf *RTCConfig, resolved *natResolution) (ReloadEvent, error) {
+	c.reloadLock.Lock()
+	defer c.reloadLock.Unlock()
+
+	current := c.Settings()
+	c.lock.RLock()
+	nodeIP := c.nodeIP
+	c.lock.RUnlock()
+
+	if rtcConf.UseExternalIP {
+		if resolved != nil {
+			rtcConf.NodeIP = resolved.nodeIP(rtcConf.NodeIP)
+		} else {
+			if err := rtcConf.InvalidateExternalIPCache(); err != nil {
+				return ReloadEvent{}, err
+			}
+			ip, err := rtcConf.determineIP()
+			if err != nil {
+				return ReloadEvent{}, err
+			}
+			rtcConf.NodeIP = ip
+			rtcConf.NodeIPAutoGenerated = true
+		}
+	}
+
+	s := current.SettingEngine
+	s.SetNAT1To1IPs(nil, webrtc.ICECandidateTypeHost)
+	_, ipFilter, err := rtcConf.filters()
+	if err != nil {
+		return ReloadEvent{}, err
+	}
+	s.SetIPFilter(ipFilter)
+	// the configured ports are bound by the mux, external IPs are resolved from ephemeral ones
+	nat1to1IPs, ipFilter, err := configureNAT1To1(rtcConf, &s, ipFilter, []int{0}, resolved)
+	if err != nil {
+		return ReloadEvent{}, err
+	}
+
+	conf := current.Configuration
+	conf.ICEServers = nil
+	conf.ICETransportPolicy = webrtc.ICETransportPolicyAll
+	if err := configureICEServers(rtcConf, &conf); err != nil {
+		return ReloadEvent{}, err
+	}
+
+	staticHostCandidates, err := rtcConf.parseStaticHostCandidates()
+	if err != nil {
+		return ReloadEvent{}, err
+	}
+	rtcConf.recordAdvertisedAddresses(nat1to1IPs, ipFilter, staticHostCandidates)
+
+	event := ReloadEvent{
+		Settings: WebRTCSettings{
+			Configuration:        conf,
+			SettingEngine:        s,
+			NAT1To1IPs:           nat1to1IPs,
+			StaticHostCandidates: staticHostCandidates,
+		},
+		AddressesChanged: rtcConf.NodeIP != nodeIP || !sameIPs(nat1to1IPs, current.NAT1To1IPs) ||
+			!slices.EqualFunc(staticHostCandidates, current.StaticHostCandidates, func(a, b StaticHostCandidate) bool {
+				return a.String() == b.String()
+			}),
+		ICEServersChanged: !reflect.DeepEqual(conf.ICEServers, current.Configuration.ICEServers) ||
+			conf.ICETransportPolicy != current.Configuration.ICETransportPolicy,
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	settings := event.Settings
+	c.settings = &settings
+	c.nodeIP = rtcConf.NodeIP
+	if event.AddressesChanged || event.ICEServersChanged {
+		changes := c.changesLocked()
+		// replace an event not received yet
+		select {
+		case <-changes:
+		default:
+		}
+		changes <- event
+	}
+	return event, nil
+}
+
+func (c *WebRTCConfig) changesLocked() chan ReloadEvent {
+	if c.changes == nil {
+		c.changes = make(chan ReloadEvent, 1)
+	}
+	return c.changes
+}
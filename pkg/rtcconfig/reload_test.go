@@ -0,0 +1,70 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Reload(t *testing.T) {
+	c := &WebRTCConfig{nodeIP: "10.0.0.1"}
+	changes := c.Changes()
+
+	rtcConf := &RTCConfig{
+		UDPPort:     PortRange{Start: 7882},
+		NodeIP:      "10.0.0.1",
+		TURNServers: []TURNServerConfig{{URL: "turn.example.com", Username: "user", Credential: "pass"}},
+		ForceRelay:  true,
+	}
+	event, err := c.Reload(rtcConf)
+	require.NoError(t, err)
+	require.True(t, event.ICEServersChanged)
+	require.False(t, event.AddressesChanged)
+	require.Equal(t, event, <-changes)
+
+	settings := c.Settings()
+	require.Equal(t, webrtc.ICETransportPolicyRelay, settings.Configuration.ICETransportPolicy)
+	require.Len(t, settings.Configuration.ICEServers, 1)
+	require.Len(t, c.SessionConfiguration(nil).ICEServers, 1)
+	// published through Settings, the fields keep the startup settings
+	require.Empty(t, c.Configuration.ICEServers)
+
+	// unchanged
+	event, err = c.Reload(rtcConf)
+	require.NoError(t, err)
+	require.False(t, event.ICEServersChanged || event.AddressesChanged)
+	require.Len(t, changes, 0)
+
+	// only the latest event is kept
+	rtcConf.NodeIP = "10.0.0.2"
+	_, err = c.Reload(rtcConf)
+	require.NoError(t, err)
+	rtcConf.StaticHostCandidates = []string{"10.0.0.3:7882/udp"}
+	event, err = c.Reload(rtcConf)
+	require.NoError(t, err)
+	require.True(t, event.AddressesChanged)
+	require.Len(t, changes, 1)
+	require.Equal(t, event, <-changes)
+	require.Len(t, c.Settings().StaticHostCandidates, 1)
+
+	// invalid, settings are left unchanged
+	rtcConf.TURNServers = []TURNServerConfig{{URL: "stun:stun.example.com"}}
+	_, err = c.Reload(rtcConf)
+	require.Error(t, err)
+	require.Equal(t, settings.Configuration, c.Settings().Configuration)
+}
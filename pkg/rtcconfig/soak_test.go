@@ -0,0 +1,483 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build soak && linux
+// +build soak,linux
+
+package rtcconfig
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pion/ice/v2"
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/transport"
+)
+
+// The soak test randomly kills and restarts STUN servers, exhausts the UDP mux ports and toggles a network
+// interface, checking after every action that external IP resolution, preflight and mux creation report it
+// and recover, and at the end that no goroutines or file descriptors leaked. Every action has a deadline,
+// a deadlock crashes the test with the stacks of all goroutines. Run with
+//
+//	go test -tags soak -run Test_Soak -timeout 0 ./pkg/rtcconfig -soak.duration 1h
+//
+// Toggling an interface needs root, it is done on a dummy interface in a network namespace to leave
+// the host network alone:
+//
+//	ip netns add soak
+//	ip netns exec soak ip link set lo up
+//	ip netns exec soak ip link add soak0 type dummy
+//	ip netns exec soak ip addr add 192.0.2.1/24 dev soak0
+//	ip netns exec soak go test -tags soak -run Test_Soak ./pkg/rtcconfig -soak.iface soak0
+
+var (
+	soakDuration = flag.Duration("soak.duration", time.Minute, "duration of the soak test")
+	soakInterval = flag.Duration("soak.interval", 50*time.Millisecond, "time between chaos actions")
+	soakSeed     = flag.Int64("soak.seed", 0, "seed of the chaos actions, 0 for a time based seed")
+	soakIface    = flag.String("soak.iface", "", "interface to toggle, only in a network namespace")
+)
+
+const (
+	soakNumSTUNServers = 3
+	soakMappedIP       = "203.0.113.10"
+	soakActionDeadline = 10 * time.Second
+	soakEventTimeout   = 5 * time.Second
+	// goroutines of the runtime and the test framework come and go
+	soakGoroutineSlack = 5
+	soakLeakTimeout    = 5 * time.Second
+)
+
+func Test_Soak(t *testing.T) {
+	seed := *soakSeed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	t.Logf("seed %d", seed)
+
+	prevAttempt, prevRetry := externalIPAttemptInterval, externalIPRetryInterval
+	externalIPAttemptInterval = 10 * time.Millisecond
+	externalIPRetryInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		externalIPAttemptInterval, externalIPRetryInterval = prevAttempt, prevRetry
+	})
+
+	baselineGoroutines := runtime.NumGoroutine()
+	baselineFDs := numOpenFDs(t)
+
+	s := newSoak(t, rand.New(rand.NewSource(seed)))
+	deadline := time.Now().Add(*soakDuration)
+	for numActions := 0; time.Now().Before(deadline); numActions++ {
+		s.step()
+		if numActions%100 == 0 {
+			t.Logf("%d actions, %d STUN servers running, ports exhausted %v, interface up %v",
+				numActions, s.numRunning(), s.portsHeld != nil, s.ifaceUp)
+		}
+		time.Sleep(*soakInterval)
+	}
+	s.close()
+
+	require.Eventually(t, func() bool {
+		return runtime.NumGoroutine() <= baselineGoroutines+soakGoroutineSlack
+	}, soakLeakTimeout, 100*time.Millisecond, "goroutines leaked: %d, baseline %d\n%s",
+		runtime.NumGoroutine(), baselineGoroutines, goroutineStacks())
+	require.Eventually(t, func() bool {
+		return numOpenFDs(t) <= baselineFDs
+	}, soakLeakTimeout, 100*time.Millisecond, "file descriptors leaked: %d, baseline %d", numOpenFDs(t), baselineFDs)
+}
+
+// ------------------------------------------------
+
+type soak struct {
+	t    *testing.T
+	rand *rand.Rand
+
+	stunServers []*fakeSTUNServer
+	conf        *RTCConfig
+	events      chan ExternalIPEvent
+
+	udpPort   int
+	portsHeld []*net.UDPConn
+
+	ifaceUp bool
+}
+
+func newSoak(t *testing.T, r *rand.Rand) *soak {
+	s := &soak{
+		t:       t,
+		rand:    r,
+		events:  make(chan ExternalIPEvent, 100),
+		ifaceUp: true,
+	}
+
+	var stunServers []string
+	for i := 0; i < soakNumSTUNServers; i++ {
+		server := newFakeSTUNServer(net.ParseIP(soakMappedIP))
+		require.NoError(t, server.start())
+		s.stunServers = append(s.stunServers, server)
+		stunServers = append(stunServers, server.addr.String())
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	s.udpPort = conn.LocalAddr().(*net.UDPAddr).Port
+	require.NoError(t, conn.Close())
+
+	s.conf = &RTCConfig{
+		UDPPort:          PortRange{Start: s.udpPort},
+		UseExternalIP:    true,
+		ExternalIPPolicy: ExternalIPPolicyRetry,
+		STUNServers:      stunServers,
+		STUNRequest: STUNRequestConfig{
+			RTO:            20 * time.Millisecond,
+			MaxRetransmits: 1,
+		},
+		OnExternalIPEvent: func(event ExternalIPEvent) {
+			select {
+			case s.events <- event:
+			default:
+				t.Errorf("external IP events not consumed, dropped %s", event.Type)
+			}
+		},
+	}
+
+	if *soakIface != "" {
+		iface, err := net.InterfaceByName(*soakIface)
+		require.NoError(t, err)
+		require.NotZero(t, iface.Flags&net.FlagUp, "interface %s is down", *soakIface)
+	}
+	return s
+}
+
+func (s *soak) step() {
+	actions := []struct {
+		name string
+		run  func()
+	}{
+		{"toggle STUN server", s.toggleSTUNServer},
+		{"resolve external IP", s.resolveExternalIP},
+		{"toggle port exhaustion", s.togglePortExhaustion},
+		{"create mux", s.createMux},
+	}
+	if *soakIface != "" {
+		actions = append(actions, struct {
+			name string
+			run  func()
+		}{"toggle interface", s.toggleInterface})
+	}
+
+	action := actions[s.rand.Intn(len(actions))]
+	s.withDeadline(action.name, action.run)
+}
+
+// withDeadline crashes the test with all goroutine stacks if the action does not complete, e.g. on a deadlock.
+// The action runs on the test goroutine to be able to fail the test, the watchdog cannot, it panics.
+func (s *soak) withDeadline(name string, run func()) {
+	watchdog := time.AfterFunc(soakActionDeadline, func() {
+		fmt.Fprintf(os.Stderr, "%s did not complete in %s\n%s", name, soakActionDeadline, goroutineStacks())
+		panic(fmt.Sprintf("soak: %s deadlocked", name))
+	})
+	defer watchdog.Stop()
+
+	run()
+}
+
+func (s *soak) numRunning() int {
+	n := 0
+	for _, server := range s.stunServers {
+		if server.isRunning() {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *soak) toggleSTUNServer() {
+	server := s.stunServers[s.rand.Intn(len(s.stunServers))]
+	if server.isRunning() {
+		server.stop()
+	} else if err := server.start(); err != nil {
+		// the port was taken while the server was down, keep it down
+		s.t.Logf("restarting STUN server %s: %v", server.addr, err)
+	}
+
+	r := &PreflightReport{}
+	s.conf.preflightSTUN(context.Background(), r)
+	var expectedReachable []string
+	for _, server := range s.stunServers {
+		if server.isRunning() {
+			expectedReachable = append(expectedReachable, server.addr.String())
+		}
+	}
+	require.ElementsMatch(s.t, expectedReachable, r.STUNServersReachable)
+	require.Len(s.t, r.STUNServersUnreachable, len(s.stunServers)-len(expectedReachable))
+	if len(expectedReachable) != 0 {
+		require.Equal(s.t, soakMappedIP, r.ExternalIP)
+	}
+}
+
+func (s *soak) resolveExternalIP() {
+	s.drainEvents()
+
+	ip, err := s.conf.determineIP()
+	require.NoError(s.t, err)
+	event := s.nextEvent()
+	if s.numRunning() != 0 {
+		require.Equal(s.t, ExternalIPEventResolved, event.Type)
+		require.Equal(s.t, soakMappedIP, ip)
+		require.Equal(s.t, soakMappedIP, event.IP)
+		return
+	}
+
+	// the node IP is used until a STUN server is back
+	require.Equal(s.t, ExternalIPEventFallback, event.Type, event.Err)
+	require.Equal(s.t, event.IP, ip)
+	require.ErrorIs(s.t, event.Err, ErrExternalIPUnresolved)
+
+	started := false
+	for _, i := range s.rand.Perm(len(s.stunServers)) {
+		if err := s.stunServers[i].start(); err == nil {
+			started = true
+			break
+		}
+	}
+	require.True(s.t, started, "no STUN server could be restarted")
+	event = s.nextEvent()
+	require.Equal(s.t, ExternalIPEventResolved, event.Type)
+	require.Equal(s.t, soakMappedIP, event.IP)
+	require.NotZero(s.t, event.Attempt)
+	s.conf.StopExternalIPRetry()
+}
+
+func (s *soak) togglePortExhaustion() {
+	if s.portsHeld != nil {
+		for _, conn := range s.portsHeld {
+			_ = conn.Close()
+		}
+		s.portsHeld = nil
+	} else {
+		// held on every address, as the mux listens on all interfaces
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: s.udpPort})
+		require.NoError(s.t, err)
+		s.portsHeld = append(s.portsHeld, conn)
+	}
+
+	r := &PreflightReport{}
+	s.conf.preflightPorts(r)
+	if s.portsHeld != nil {
+		require.Len(s.t, r.Errors(), 1)
+		require.Equal(s.t, PreflightCheckPorts, r.Errors()[0].Check)
+	} else {
+		require.Empty(s.t, r.Errors())
+	}
+}
+
+func (s *soak) createMux() {
+	opts := []transport.UDPMuxFromPortOption{
+		transport.UDPMuxFromPortWithNetworks(ice.NetworkTypeUDP4),
+		transport.UDPMuxFromPortWithLoopback(),
+	}
+	if *soakIface != "" {
+		opts = append(opts, transport.UDPMuxFromPortWithInterfaceFilter(func(name string) bool {
+			return name == *soakIface || name == "lo"
+		}))
+	}
+
+	muxes, err := transport.CreateUDPMuxesFromPorts([]int{s.udpPort}, opts...)
+	if s.portsHeld != nil {
+		require.Error(s.t, err)
+		require.Nil(s.t, muxes)
+		return
+	}
+	require.NoError(s.t, err)
+
+	var listening []string
+	for _, mux := range muxes {
+		for _, addr := range mux.GetListenAddresses() {
+			listening = append(listening, addr.(*net.UDPAddr).IP.String())
+		}
+	}
+	require.Contains(s.t, listening, "127.0.0.1")
+	if *soakIface != "" {
+		expected := 1
+		if s.ifaceUp {
+			expected = 2
+		}
+		require.Len(s.t, listening, expected, "listening on %v", listening)
+	}
+
+	for _, mux := range muxes {
+		require.NoError(s.t, mux.Close())
+	}
+}
+
+func (s *soak) toggleInterface() {
+	state := "down"
+	if !s.ifaceUp {
+		state = "up"
+	}
+	out, err := exec.Command("ip", "link", "set", "dev", *soakIface, state).CombinedOutput()
+	require.NoError(s.t, err, string(out))
+	s.ifaceUp = !s.ifaceUp
+
+	iface, err := net.InterfaceByName(*soakIface)
+	require.NoError(s.t, err)
+	require.Equal(s.t, s.ifaceUp, iface.Flags&net.FlagUp != 0)
+}
+
+func (s *soak) nextEvent() ExternalIPEvent {
+	select {
+	case event := <-s.events:
+		return event
+	case <-time.After(soakEventTimeout):
+		s.t.Fatalf("no external IP event in %s", soakEventTimeout)
+		return ExternalIPEvent{}
+	}
+}
+
+func (s *soak) drainEvents() {
+	for {
+		select {
+		case <-s.events:
+		default:
+			return
+		}
+	}
+}
+
+// close restores the environment
+func (s *soak) close() {
+	s.conf.StopExternalIPRetry()
+	for _, server := range s.stunServers {
+		server.stop()
+	}
+	for _, conn := range s.portsHeld {
+		_ = conn.Close()
+	}
+	s.portsHeld = nil
+	if !s.ifaceUp {
+		s.toggleInterface()
+	}
+}
+
+// ------------------------------------------------
+
+// fakeSTUNServer answers binding requests with a fixed mapped IP, restarting on the same port
+type fakeSTUNServer struct {
+	mappedIP net.IP
+	addr     *net.UDPAddr
+
+	lock sync.Mutex
+	conn *net.UDPConn
+	wg   sync.WaitGroup
+}
+
+func newFakeSTUNServer(mappedIP net.IP) *fakeSTUNServer {
+	return &fakeSTUNServer{
+		mappedIP: mappedIP,
+		addr:     &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)},
+	}
+}
+
+func (f *fakeSTUNServer) start() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.conn != nil {
+		return nil
+	}
+
+	conn, err := net.ListenUDP("udp4", f.addr)
+	if err != nil {
+		return err
+	}
+	f.addr = conn.LocalAddr().(*net.UDPAddr)
+	f.conn = conn
+
+	f.wg.Add(1)
+	go f.serve(conn)
+	return nil
+}
+
+func (f *fakeSTUNServer) stop() {
+	f.lock.Lock()
+	conn := f.conn
+	f.conn = nil
+	f.lock.Unlock()
+
+	if conn != nil {
+		_ = conn.Close()
+		f.wg.Wait()
+	}
+}
+
+func (f *fakeSTUNServer) isRunning() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.conn != nil
+}
+
+func (f *fakeSTUNServer) serve(conn *net.UDPConn) {
+	defer f.wg.Done()
+
+	buf := make([]byte, 1500)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		request := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+		if request.Decode() != nil || request.Type != stun.BindingRequest {
+			continue
+		}
+		response, err := stun.Build(
+			stun.NewTransactionIDSetter(request.TransactionID),
+			stun.BindingSuccess,
+			&stun.XORMappedAddress{IP: f.mappedIP, Port: src.Port},
+			stun.Fingerprint,
+		)
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteToUDP(response.Raw, src)
+	}
+}
+
+// ------------------------------------------------
+
+func numOpenFDs(t *testing.T) int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	require.NoError(t, err)
+	return len(entries)
+}
+
+func goroutineStacks() string {
+	var b strings.Builder
+	_ = pprof.Lookup("goroutine").WriteTo(&b, 2)
+	return b.String()
+}
@@ -0,0 +1,220 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/bwe"
+	"github.com/livekit/mediatransportutil/pkg/pacer"
+)
+
+// TuningProfile selects a coherent bundle of buffer sizes, timeouts, pacer and estimator parameters
+// for a deployment. Fields set in RTCConfig override the values of the profile.
+type TuningProfile string
+
+const (
+	// the defaults used when no profile is set
+	TuningProfileDefault TuningProfile = ""
+	// well provisioned hosts with high bandwidth, low loss paths to clients
+	TuningProfileDatacenter TuningProfile = "datacenter"
+	// constrained hosts close to clients, with smaller buffers and bitrates
+	TuningProfileEdge TuningProfile = "edge"
+	// local development, connections fail fast and system limits are left alone
+	TuningProfileDev TuningProfile = "dev"
+)
+
+// PacerConfig tunes the leaky bucket pacer, zero values use the pacer defaults.
+type PacerConfig struct {
+	// interval between sends
+	SendInterval time.Duration `yaml:"send_interval,omitempty"`
+	// packets queued for longer are dropped
+	MaxLatency time.Duration `yaml:"max_latency,omitempty"`
+	// share of the bitrate retransmissions may use, negative for no cap
+	MaxRetransmissionShare float64 `yaml:"max_retransmission_share,omitempty"`
+}
+
+// FactoryOpts returns the options to pass to pacer.NewPacerFactory.
+func (c PacerConfig) FactoryOpts() []pacer.PacerFactoryOpt {
+	var opts []pacer.PacerFactoryOpt
+	if c.SendInterval != 0 {
+		opts = append(opts, pacer.WithSendInterval(c.SendInterval))
+	}
+	if c.MaxLatency != 0 {
+		opts = append(opts, pacer.WithMaxLatency(c.MaxLatency))
+	}
+	switch {
+	case c.MaxRetransmissionShare < 0:
+		opts = append(opts, pacer.WithMaxRetransmissionShare(0))
+	case c.MaxRetransmissionShare > 0:
+		opts = append(opts, pacer.WithMaxRetransmissionShare(c.MaxRetransmissionShare))
+	}
+	return opts
+}
+
+// TuningParams are the values a TuningProfile sets, see RTCConfig.Tuning.
+type TuningParams struct {
+	// receive and send buffer size of UDP mux sockets
+	UDPBufferSize int
+	ICEConsent    ICEConsentConfig
+	BatchIO       BatchIOConfig
+	Pacer         PacerConfig
+	// bitrate bounds of the send side bandwidth estimator
+	CongestionControl bwe.Config
+}
+
+var TuningParamsDefault = TuningParams{
+	UDPBufferSize: defaultUDPBufferSize,
+}
+
+var TuningParamsDatacenter = TuningParams{
+	UDPBufferSize: 2 * defaultUDPBufferSize,
+	ICEConsent: ICEConsentConfig{
+		CheckInterval:       defaultICEConsentCheckInterval,
+		DisconnectedTimeout: defaultICEConsentDisconnectedTimeout,
+		FailedTimeout:       defaultICEConsentFailedTimeout,
+	},
+	BatchIO: BatchIOConfig{
+		BatchSize:        32,
+		MaxFlushInterval: 500 * time.Microsecond,
+	},
+	Pacer: PacerConfig{
+		SendInterval:           5 * time.Millisecond,
+		MaxLatency:             2 * time.Second,
+		MaxRetransmissionShare: 0.25,
+	},
+	CongestionControl: bwe.Config{
+		InitialBitrate: 1_000_000,
+		MinBitrate:     100_000,
+		MaxBitrate:     50_000_000,
+	},
+}
+
+var TuningParamsEdge = TuningParams{
+	UDPBufferSize: 4 * 1024 * 1024,
+	ICEConsent: ICEConsentConfig{
+		CheckInterval:       defaultICEConsentCheckInterval,
+		DisconnectedTimeout: 4 * time.Second,
+		FailedTimeout:       15 * time.Second,
+	},
+	Pacer: PacerConfig{
+		SendInterval: 10 * time.Millisecond,
+		MaxLatency:   time.Second,
+		// retransmissions compete with media on constrained uplinks
+		MaxRetransmissionShare: 0.15,
+	},
+	CongestionControl: bwe.Config{
+		InitialBitrate: 300_000,
+		MinBitrate:     30_000,
+		MaxBitrate:     5_000_000,
+	},
+}
+
+var TuningParamsDev = TuningParams{
+	UDPBufferSize: 1024 * 1024,
+	ICEConsent: ICEConsentConfig{
+		CheckInterval:       time.Second,
+		DisconnectedTimeout: 3 * time.Second,
+		FailedTimeout:       10 * time.Second,
+	},
+	Pacer: PacerConfig{
+		MaxRetransmissionShare: -1,
+	},
+}
+
+func (p TuningProfile) Params() (TuningParams, error) {
+	switch p {
+	case TuningProfileDefault:
+		return TuningParamsDefault, nil
+	case TuningProfileDatacenter:
+		return TuningParamsDatacenter, nil
+	case TuningProfileEdge:
+		return TuningParamsEdge, nil
+	case TuningProfileDev:
+		return TuningParamsDev, nil
+	default:
+		return TuningParams{}, fmt.Errorf("unknown tuning profile %s", p)
+	}
+}
+
+// Tuning returns the parameters of the tuning profile with the fields set in the config applied over them,
+// the default profile when it is unknown. Fields of the profile are overridden one by one, so setting
+// only the failed timeout of ICEConsent keeps the check interval of the profile.
+func (conf *RTCConfig) Tuning() TuningParams {
+	t, err := conf.Profile.Params()
+	if err != nil {
+		t = TuningParamsDefault
+	}
+
+	if conf.UDPBufferSize != 0 {
+		t.UDPBufferSize = conf.UDPBufferSize
+	}
+
+	if conf.ICEConsent.CheckInterval != 0 {
+		t.ICEConsent.CheckInterval = conf.ICEConsent.CheckInterval
+	}
+	if conf.ICEConsent.DisconnectedTimeout != 0 {
+		t.ICEConsent.DisconnectedTimeout = conf.ICEConsent.DisconnectedTimeout
+	}
+	if conf.ICEConsent.FailedTimeout != 0 {
+		t.ICEConsent.FailedTimeout = conf.ICEConsent.FailedTimeout
+	}
+
+	if conf.BatchIO.BatchSize != 0 {
+		t.BatchIO.BatchSize = conf.BatchIO.BatchSize
+	}
+	if conf.BatchIO.MaxFlushInterval != 0 {
+		t.BatchIO.MaxFlushInterval = conf.BatchIO.MaxFlushInterval
+	}
+
+	if conf.Pacer.SendInterval != 0 {
+		t.Pacer.SendInterval = conf.Pacer.SendInterval
+	}
+	if conf.Pacer.MaxLatency != 0 {
+		t.Pacer.MaxLatency = conf.Pacer.MaxLatency
+	}
+	if conf.Pacer.MaxRetransmissionShare != 0 {
+		t.Pacer.MaxRetransmissionShare = conf.Pacer.MaxRetransmissionShare
+	}
+
+	cc := conf.CongestionControl
+	if cc.InitialBitrate == 0 {
+		cc.InitialBitrate = t.CongestionControl.InitialBitrate
+	}
+	if cc.MinBitrate == 0 {
+		cc.MinBitrate = t.CongestionControl.MinBitrate
+	}
+	if cc.MaxBitrate == 0 {
+		cc.MaxBitrate = t.CongestionControl.MaxBitrate
+	}
+	t.CongestionControl = cc
+	return t
+}
+
+func (conf *RTCConfig) validateTuning() error {
+	if _, err := conf.Profile.Params(); err != nil {
+		return err
+	}
+
+	t := conf.Tuning()
+	if t.UDPBufferSize < 0 {
+		return fmt.Errorf("invalid UDP buffer size %d", t.UDPBufferSize)
+	}
+	if t.Pacer.SendInterval < 0 || t.Pacer.MaxLatency < 0 || t.Pacer.MaxRetransmissionShare > 1 {
+		return fmt.Errorf("invalid pacer config %+v", t.Pacer)
+	}
+	return t.CongestionControl.Validate()
+}
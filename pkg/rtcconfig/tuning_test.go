@@ -0,0 +1,107 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/livekit/mediatransportutil/pkg/bwe"
+)
+
+func Test_TuningProfile(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		conf := &RTCConfig{}
+		require.Equal(t, TuningParamsDefault, conf.Tuning())
+		require.False(t, conf.Tuning().ICEConsent.IsSet())
+	})
+
+	t.Run("profiles", func(t *testing.T) {
+		for profile, expected := range map[TuningProfile]TuningParams{
+			TuningProfileDatacenter: TuningParamsDatacenter,
+			TuningProfileEdge:       TuningParamsEdge,
+			TuningProfileDev:        TuningParamsDev,
+		} {
+			conf := &RTCConfig{
+				Profile: profile,
+				UDPPort: PortRange{Start: 7882},
+				NodeIP:  "10.0.0.1",
+			}
+			require.Equal(t, expected, conf.Tuning(), profile)
+			require.NoError(t, conf.Validate(true), profile)
+		}
+	})
+
+	t.Run("field overrides", func(t *testing.T) {
+		conf := &RTCConfig{
+			Profile:       TuningProfileEdge,
+			UDPBufferSize: 8 * 1024 * 1024,
+			ICEConsent:    ICEConsentConfig{FailedTimeout: 20 * time.Second},
+			Pacer:         PacerConfig{MaxLatency: 500 * time.Millisecond},
+			CongestionControl: bwe.Config{
+				Algorithm:  bwe.AlgorithmGCC,
+				MaxBitrate: 2_000_000,
+			},
+		}
+		tuning := conf.Tuning()
+		require.Equal(t, 8*1024*1024, tuning.UDPBufferSize)
+		require.Equal(t, ICEConsentConfig{
+			CheckInterval:       TuningParamsEdge.ICEConsent.CheckInterval,
+			DisconnectedTimeout: TuningParamsEdge.ICEConsent.DisconnectedTimeout,
+			FailedTimeout:       20 * time.Second,
+		}, tuning.ICEConsent)
+		require.Equal(t, TuningParamsEdge.Pacer.SendInterval, tuning.Pacer.SendInterval)
+		require.Equal(t, 500*time.Millisecond, tuning.Pacer.MaxLatency)
+		require.Equal(t, bwe.AlgorithmGCC, tuning.CongestionControl.Algorithm)
+		require.Equal(t, TuningParamsEdge.CongestionControl.InitialBitrate, tuning.CongestionControl.InitialBitrate)
+		require.Equal(t, 2_000_000, tuning.CongestionControl.MaxBitrate)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		conf := &RTCConfig{
+			Profile: "unknown",
+			UDPPort: PortRange{Start: 7882},
+			NodeIP:  "10.0.0.1",
+		}
+		require.Error(t, conf.Validate(true))
+		require.Equal(t, TuningParamsDefault, conf.Tuning())
+
+		// override conflicting with the profile
+		conf = &RTCConfig{
+			Profile:           TuningProfileEdge,
+			UDPPort:           PortRange{Start: 7882},
+			NodeIP:            "10.0.0.1",
+			CongestionControl: bwe.Config{MinBitrate: 10_000_000},
+		}
+		require.Error(t, conf.Validate(true))
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var conf RTCConfig
+		require.NoError(t, yaml.Unmarshal([]byte("profile: datacenter\npacer:\n  send_interval: 10ms\n"), &conf))
+		require.Equal(t, TuningProfileDatacenter, conf.Profile)
+		require.Equal(t, 10*time.Millisecond, conf.Tuning().Pacer.SendInterval)
+		require.Equal(t, TuningParamsDatacenter.BatchIO, conf.Tuning().BatchIO)
+	})
+}
+
+func Test_PacerConfigFactoryOpts(t *testing.T) {
+	require.Empty(t, PacerConfig{}.FactoryOpts())
+	require.Len(t, TuningParamsDatacenter.Pacer.FactoryOpts(), 3)
+	require.Len(t, TuningParamsDev.Pacer.FactoryOpts(), 1)
+}
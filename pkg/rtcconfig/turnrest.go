@@ -0,0 +1,303 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"github.com/livekit/protocol/logger"
+)
+
+var (
+	ErrTURNCredentialsClosed = errors.New("TURN credential client closed")
+)
+
+// TURNCredentialParams configures fetching time-limited TURN credentials from a TURN REST API endpoint
+// (draft-uberti-behave-turn-rest), GET <URL>?service=turn&username=<username>&key=<Key>.
+type TURNCredentialParams struct {
+	URL string
+	// API key of the endpoint, not sent when empty
+	Key string
+	// credentials are refreshed when they expire within this long, a fifth of their TTL when 0
+	RefreshMargin time.Duration
+	// interval between attempts when a refresh fails, until the credentials expire
+	RetryInterval time.Duration
+	// timeout of a request, used when HTTPClient is not set
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+var TURNCredentialParamsDefault = TURNCredentialParams{
+	RetryInterval: 10 * time.Second,
+	Timeout:       10 * time.Second,
+}
+
+// TURNCredentials are the credentials returned by a TURN REST API endpoint.
+type TURNCredentials struct {
+	Username string
+	Password string
+	TTL      time.Duration
+	URIs     []string
+	// when the credentials expire, TTL after they were fetched
+	Expiry time.Time
+}
+
+// ICEServer returns the credentials as an ICE server, for example for RTCConfig.SessionConfiguration.
+func (c TURNCredentials) ICEServer() webrtc.ICEServer {
+	return webrtc.ICEServer{
+		URLs:           c.URIs,
+		Username:       c.Username,
+		Credential:     c.Password,
+		CredentialType: webrtc.ICECredentialTypePassword,
+	}
+}
+
+type turnCredentialEntry struct {
+	credentials TURNCredentials
+	// closed once the first fetch is done, err is set if it failed
+	ready chan struct{}
+	err   error
+	timer *time.Timer
+}
+
+// TURNCredentialClient fetches TURN credentials per username, caches them and refreshes them before
+// they expire for as long as the username is in use. Call Remove when a session no longer needs them.
+type TURNCredentialClient struct {
+	params TURNCredentialParams
+	client *http.Client
+
+	lock        sync.Mutex
+	entries     map[string]*turnCredentialEntry
+	onRefreshed func(username string, credentials TURNCredentials)
+	closed      bool
+}
+
+func NewTURNCredentialClient(params TURNCredentialParams) *TURNCredentialClient {
+	if params.RetryInterval <= 0 {
+		params.RetryInterval = TURNCredentialParamsDefault.RetryInterval
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = TURNCredentialParamsDefault.Timeout
+	}
+	client := params.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: params.Timeout}
+	}
+	return &TURNCredentialClient{
+		params:  params,
+		client:  client,
+		entries: make(map[string]*turnCredentialEntry),
+	}
+}
+
+// OnRefreshed sets a callback invoked with credentials refreshed in the background, for example
+// to update the ICE servers of a session with a restart.
+func (c *TURNCredentialClient) OnRefreshed(f func(username string, credentials TURNCredentials)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.onRefreshed = f
+}
+
+// Get returns the cached credentials of username, fetching them when there are none or they have expired.
+// Concurrent calls for the same username share a request.
+func (c *TURNCredentialClient) Get(ctx context.Context, username string) (TURNCredentials, error) {
+	c.lock.Lock()
+	if c.closed {
+		c.lock.Unlock()
+		return TURNCredentials{}, ErrTURNCredentialsClosed
+	}
+	entry := c.entries[username]
+	if entry != nil {
+		select {
+		case <-entry.ready:
+			if entry.err != nil || !time.Now().Before(entry.credentials.Expiry) {
+				c.removeLocked(username)
+				entry = nil
+			}
+		default:
+		}
+	}
+	fetch := entry == nil
+	if fetch {
+		entry = &turnCredentialEntry{
+			ready: make(chan struct{}),
+		}
+		c.entries[username] = entry
+	}
+	c.lock.Unlock()
+
+	if fetch {
+		credentials, err := c.fetch(ctx, username)
+
+		c.lock.Lock()
+		entry.credentials, entry.err = credentials, err
+		close(entry.ready)
+		if err == nil && c.entries[username] == entry {
+			c.scheduleRefreshLocked(username, entry)
+		}
+		c.lock.Unlock()
+	} else {
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return TURNCredentials{}, ctx.Err()
+		}
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return entry.credentials, entry.err
+}
+
+// ICEServers returns the credentials of username as session ICE servers, see WebRTCConfig.SessionConfiguration.
+func (c *TURNCredentialClient) ICEServers(ctx context.Context, username string) ([]webrtc.ICEServer, error) {
+	credentials, err := c.Get(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return []webrtc.ICEServer{credentials.ICEServer()}, nil
+}
+
+// Remove drops the credentials of username and stops refreshing them.
+func (c *TURNCredentialClient) Remove(username string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.removeLocked(username)
+}
+
+func (c *TURNCredentialClient) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.closed = true
+	for username := range c.entries {
+		c.removeLocked(username)
+	}
+}
+
+func (c *TURNCredentialClient) removeLocked(username string) {
+	if entry := c.entries[username]; entry != nil && entry.timer != nil {
+		entry.timer.Stop()
+	}
+	delete(c.entries, username)
+}
+
+func (c *TURNCredentialClient) scheduleRefreshLocked(username string, entry *turnCredentialEntry) {
+	margin := c.params.RefreshMargin
+	if margin <= 0 {
+		margin = entry.credentials.TTL / 5
+	}
+	entry.timer = time.AfterFunc(time.Until(entry.credentials.Expiry.Add(-margin)), func() {
+		c.refresh(username, entry)
+	})
+}
+
+func (c *TURNCredentialClient) refresh(username string, entry *turnCredentialEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.params.Timeout)
+	credentials, err := c.fetch(ctx, username)
+	cancel()
+
+	c.lock.Lock()
+	if c.entries[username] != entry {
+		// removed while refreshing
+		c.lock.Unlock()
+		return
+	}
+	if err != nil {
+		retryAt := time.Now().Add(c.params.RetryInterval)
+		if retryAt.Before(entry.credentials.Expiry) {
+			entry.timer = time.AfterFunc(c.params.RetryInterval, func() {
+				c.refresh(username, entry)
+			})
+		} else {
+			// fetched again on the next Get
+			entry.timer = nil
+		}
+		c.lock.Unlock()
+		logger.Warnw("could not refresh TURN credentials", err, "username", username, "expiry", entry.credentials.Expiry)
+		return
+	}
+	entry.credentials = credentials
+	c.scheduleRefreshLocked(username, entry)
+	onRefreshed := c.onRefreshed
+	c.lock.Unlock()
+
+	if onRefreshed != nil {
+		onRefreshed(username, credentials)
+	}
+}
+
+func (c *TURNCredentialClient) fetch(ctx context.Context, username string) (TURNCredentials, error) {
+	u, err := url.Parse(c.params.URL)
+	if err != nil {
+		return TURNCredentials{}, err
+	}
+	query := u.Query()
+	query.Set("service", "turn")
+	query.Set("username", username)
+	if c.params.Key != "" {
+		query.Set("key", c.params.Key)
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return TURNCredentials{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return TURNCredentials{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return TURNCredentials{}, fmt.Errorf("could not get TURN credentials: %s", res.Status)
+	}
+
+	var body struct {
+		Username string   `json:"username"`
+		Password string   `json:"password"`
+		TTL      int64    `json:"ttl"`
+		URIs     []string `json:"uris"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return TURNCredentials{}, fmt.Errorf("could not decode TURN credentials: %w", err)
+	}
+	if body.Username == "" || body.Password == "" || body.TTL <= 0 || len(body.URIs) == 0 {
+		return TURNCredentials{}, errors.New("incomplete TURN credentials")
+	}
+
+	ttl := time.Duration(body.TTL) * time.Second
+	return TURNCredentials{
+		Username: body.Username,
+		Password: body.Password,
+		TTL:      ttl,
+		URIs:     body.URIs,
+		Expiry:   time.Now().Add(ttl),
+	}, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcconfig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func newTURNRESTServer(t *testing.T, status *atomic.Int32) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		require.Equal(t, "turn", r.URL.Query().Get("service"))
+		require.Equal(t, "secret", r.URL.Query().Get("key"))
+		if s := status.Load(); s != http.StatusOK {
+			w.WriteHeader(int(s))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"username": r.URL.Query().Get("username") + ":" + string(rune('0'+n)),
+			"password": "password",
+			"ttl":      1,
+			"uris":     []string{"turn:turn.example.com:3478?transport=udp"},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestTURNCredentialClient(t *testing.T) {
+	t.Run("cached and shared", func(t *testing.T) {
+		var status atomic.Int32
+		status.Store(http.StatusOK)
+		server, requests := newTURNRESTServer(t, &status)
+
+		c := NewTURNCredentialClient(TURNCredentialParams{URL: server.URL, Key: "secret"})
+		defer c.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				credentials, err := c.Get(context.Background(), "alice")
+				require.NoError(t, err)
+				require.Equal(t, "alice:1", credentials.Username)
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int32(1), requests.Load())
+
+		servers, err := c.ICEServers(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Equal(t, []webrtc.ICEServer{{
+			URLs:           []string{"turn:turn.example.com:3478?transport=udp"},
+			Username:       "alice:1",
+			Credential:     "password",
+			CredentialType: webrtc.ICECredentialTypePassword,
+		}}, servers)
+		require.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("refreshed before expiry", func(t *testing.T) {
+		var status atomic.Int32
+		status.Store(http.StatusOK)
+		server, _ := newTURNRESTServer(t, &status)
+
+		c := NewTURNCredentialClient(TURNCredentialParams{
+			URL:           server.URL,
+			Key:           "secret",
+			RefreshMargin: 900 * time.Millisecond,
+		})
+		defer c.Close()
+
+		refreshed := make(chan TURNCredentials, 1)
+		c.OnRefreshed(func(username string, credentials TURNCredentials) {
+			require.Equal(t, "alice", username)
+			select {
+			case refreshed <- credentials:
+			default:
+			}
+		})
+
+		first, err := c.Get(context.Background(), "alice")
+		require.NoError(t, err)
+
+		select {
+		case credentials := <-refreshed:
+			require.Equal(t, "alice:2", credentials.Username)
+			require.True(t, credentials.Expiry.After(first.Expiry))
+		case <-time.After(2 * time.Second):
+			t.Fatal("credentials not refreshed")
+		}
+
+		credentials, err := c.Get(context.Background(), "alice")
+		require.NoError(t, err)
+		require.NotEqual(t, first.Username, credentials.Username)
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		var status atomic.Int32
+		status.Store(http.StatusForbidden)
+		server, requests := newTURNRESTServer(t, &status)
+
+		c := NewTURNCredentialClient(TURNCredentialParams{URL: server.URL, Key: "secret"})
+		defer c.Close()
+
+		_, err := c.Get(context.Background(), "alice")
+		require.Error(t, err)
+
+		status.Store(http.StatusOK)
+		_, err = c.Get(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Equal(t, int32(2), requests.Load())
+
+		c.Remove("alice")
+		_, err = c.Get(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Equal(t, int32(3), requests.Load())
+
+		c.Close()
+		_, err = c.Get(context.Background(), "alice")
+		require.ErrorIs(t, err, ErrTURNCredentialsClosed)
+	})
+}
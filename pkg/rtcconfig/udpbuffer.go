@@ -12,43 +12,41 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//go:build !windows
-// +build !windows
-
 package rtcconfig
 
 import (
+	"errors"
 	"net"
-	"syscall"
 
 	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/transport"
 )
 
 func checkUDPReadBuffer() {
 	val, err := getUDPReadBuffer()
-	if err == nil {
-		if val < minUDPBufferSize {
-			logger.Warnw("UDP receive buffer is too small for a production set-up", nil,
-				"current", val,
-				"suggested", minUDPBufferSize)
-		} else {
-			logger.Debugw("UDP receive buffer size", "current", val)
-		}
+	switch {
+	case errors.Is(err, transport.ErrSocketOptionNotSupported):
+		logger.Infow("UDP receive buffer size cannot be checked on this platform", "suggested", minUDPBufferSize)
+	case err != nil:
+		logger.Debugw("could not read UDP receive buffer size", "error", err)
+	case val < minUDPBufferSize:
+		logger.Warnw("UDP receive buffer is too small for a production set-up", nil,
+			"current", val,
+			"suggested", minUDPBufferSize)
+	default:
+		logger.Debugw("UDP receive buffer size", "current", val)
 	}
 }
 
+// getUDPReadBuffer returns the receive buffer size a mux port gets on this host
 func getUDPReadBuffer() (int, error) {
 	conn, err := net.ListenUDP("udp4", nil)
 	if err != nil {
 		return 0, err
 	}
 	defer func() { _ = conn.Close() }()
-	_ = conn.SetReadBuffer(defaultUDPBufferSize)
-	fd, err := conn.File()
-	if err != nil {
-		return 0, nil
-	}
-	defer func() { _ = fd.Close() }()
 
-	return syscall.GetsockoptInt(int(fd.Fd()), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+	r := transport.SetSocketBuffer(conn, transport.SocketOptionReadBuffer, defaultUDPBufferSize)
+	return r.Applied, r.Err
 }
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -28,7 +27,11 @@ import (
 	"github.com/pion/ice/v2"
 	"github.com/pion/transport/v2/stdnet"
 	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/maps"
 
+	"github.com/livekit/mediatransportutil/pkg/icegather"
+	"github.com/livekit/mediatransportutil/pkg/loglevel"
+	"github.com/livekit/mediatransportutil/pkg/logsampler"
 	"github.com/livekit/mediatransportutil/pkg/transport"
 	"github.com/livekit/protocol/logger"
 	"github.com/livekit/protocol/logger/pionlogger"
@@ -41,6 +44,18 @@ type WebRTCConfig struct {
 	TCPMuxListener *net.TCPListener
 	NAT1To1IPs     []string
 	UseMDNS        bool
+	// parameters for icegather.NewGatherer, applied per peer connection
+	GathererParams icegather.GathererParams
+	// host candidates to add to each local description, the ICE agent does not gather them
+	StaticHostCandidates []StaticHostCandidate
+
+	// serializes Reload
+	reloadLock sync.Mutex
+	// guards the settings applied by Reload
+	lock     sync.RWMutex
+	settings *WebRTCSettings
+	nodeIP   string
+	changes  chan ReloadEvent
 }
 
 func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error) {
@@ -51,53 +66,36 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 		LoggerFactory: pionlogger.NewLoggerFactory(logger.GetLogger()),
 	}
 
-	var ifFilter func(string) bool
-	if len(rtcConf.Interfaces.Includes) != 0 || len(rtcConf.Interfaces.Excludes) != 0 {
-		ifFilter = InterfaceFilterFromConf(rtcConf.Interfaces)
+	ifFilter, ipFilter, err := rtcConf.filters()
+	if err != nil {
+		return nil, err
+	}
+	if ifFilter != nil {
 		s.SetInterfaceFilter(ifFilter)
 	}
-
-	var ipFilter func(net.IP) bool
-	if len(rtcConf.IPs.Includes) != 0 || len(rtcConf.IPs.Excludes) != 0 {
-		filter, err := IPFilterFromConf(rtcConf.IPs)
-		if err != nil {
-			return nil, err
-		}
-		ipFilter = filter
-		s.SetIPFilter(filter)
+	if ipFilter != nil {
+		s.SetIPFilter(ipFilter)
 	}
 
 	if !rtcConf.UseMDNS {
 		s.SetICEMulticastDNSMode(ice.MulticastDNSModeDisabled)
 	}
 
-	var nat1to1IPs []string
-	// force it to the node IPs that the user has set
-	if rtcConf.NodeIP != "" && (rtcConf.UseExternalIP || !rtcConf.NodeIPAutoGenerated) {
-		if rtcConf.UseExternalIP {
-			ips, newFilter, err := getNAT1to1IPsForConf(rtcConf, ipFilter)
-			if err != nil {
-				return nil, err
-			}
-			ipFilter = newFilter
-			s.SetIPFilter(ipFilter)
-			if len(ips) == 0 {
-				logger.Infow("no external IPs found, using node IP for NAT1To1Ips", "ip", rtcConf.NodeIP)
-				s.SetNAT1To1IPs([]string{rtcConf.NodeIP}, webrtc.ICECandidateTypeHost)
-			} else {
-				logger.Infow("using external IPs", "ips", ips)
-				s.SetNAT1To1IPs(ips, webrtc.ICECandidateTypeHost)
-			}
-			nat1to1IPs = ips
-		} else {
-			s.SetNAT1To1IPs([]string{rtcConf.NodeIP}, webrtc.ICECandidateTypeHost)
-		}
+	nat1to1IPs, ipFilter, err := configureNAT1To1(rtcConf, &s, ipFilter, natUDPPorts(rtcConf), nil)
+	if err != nil {
+		return nil, err
 	}
 
+	tuning := rtcConf.Tuning()
+
 	var udpMux ice.UDPMux
-	var err error
 	networkTypes := make([]webrtc.NetworkType, 0, 4)
 
+	var gate *admissionGate
+	if rtcConf.AdmissionController != nil {
+		gate = newAdmissionGate(rtcConf.AdmissionController)
+	}
+
 	if !rtcConf.ForceTCP {
 		networkTypes = append(networkTypes,
 			webrtc.NetworkTypeUDP4, webrtc.NetworkTypeUDP6,
@@ -108,8 +106,8 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 			}
 		} else if rtcConf.UDPPort.Valid() {
 			opts := []transport.UDPMuxFromPortOption{
-				transport.UDPMuxFromPortWithReadBufferSize(defaultUDPBufferSize),
-				transport.UDPMuxFromPortWithWriteBufferSize(defaultUDPBufferSize),
+				transport.UDPMuxFromPortWithReadBufferSize(tuning.UDPBufferSize),
+				transport.UDPMuxFromPortWithWriteBufferSize(tuning.UDPBufferSize),
 				transport.UDPMuxFromPortWithLogger(s.LoggerFactory.NewLogger("udp_mux")),
 			}
 			if rtcConf.EnableLoopbackCandidate {
@@ -121,22 +119,33 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 			if ifFilter != nil {
 				opts = append(opts, transport.UDPMuxFromPortWithInterfaceFilter(ifFilter))
 			}
-			if rtcConf.BatchIO.BatchSize > 0 {
-				opts = append(opts, transport.UDPMuxFromPortWithBatchWrite(rtcConf.BatchIO.BatchSize, rtcConf.BatchIO.MaxFlushInterval))
+			if tuning.BatchIO.BatchSize > 0 {
+				opts = append(opts, transport.UDPMuxFromPortWithBatchWrite(tuning.BatchIO.BatchSize, tuning.BatchIO.MaxFlushInterval))
 			}
-			availablePorts := rtcConf.UDPPort.ToSlice()
-
-			ports := make([]int, 0, len(availablePorts))
-			for i := 0; i < runtime.NumCPU() && i < len(availablePorts); i++ {
-				ports = append(ports, availablePorts[i])
+			if rtcConf.NATKeepalive.Enabled {
+				opts = append(opts, transport.UDPMuxFromPortWithKeepalive(rtcConf.natKeepaliveParams(), rtcConf.OnNATKeepaliveEvent))
 			}
-
-			muxes, err := transport.CreateUDPMuxesFromPorts(ports, opts...)
+			if rtcConf.IPv6QoS.isSet() {
+				opts = append(opts, transport.UDPMuxFromPortWithIPv6QoS(rtcConf.ipv6QoSParams()))
+			}
+			if rtcConf.ECN.Enabled {
+				opts = append(opts, transport.UDPMuxFromPortWithECN(rtcConf.ECN.params(), rtcConf.OnECN))
+			}
+			if rtcConf.DTLS.HandshakeRate > 0 && rtcConf.DTLSHandshakeLimiter == nil {
+				rtcConf.DTLSHandshakeLimiter = transport.NewDTLSHandshakeLimiter(rtcConf.DTLS.handshakeLimitParams())
+			}
+			if rtcConf.DTLSHandshakeLimiter != nil {
+				opts = append(opts, transport.UDPMuxFromPortWithDTLSHandshakeLimit(rtcConf.DTLSHandshakeLimiter))
+			}
+			muxes, err := transport.CreateUDPMuxesFromPorts(rtcConf.udpMuxPorts(), opts...)
 			if err != nil {
 				return nil, err
 			}
 
 			udpMux = transport.NewMultiPortsUDPMux(muxes...)
+			if gate != nil {
+				udpMux = &admissionUDPMux{UDPMux: udpMux, gate: gate}
+			}
 
 			s.SetICEUDPMux(udpMux)
 			if !development {
@@ -147,10 +156,15 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 
 	// use TCP mux when it's set
 	var tcpListener *net.TCPListener
-	if rtcConf.TCPPort != 0 {
+	tcpPassive, tcpActive := rtcConf.iceTCPModes()
+	if tcpPassive || tcpActive {
 		networkTypes = append(networkTypes,
 			webrtc.NetworkTypeTCP4, webrtc.NetworkTypeTCP6,
 		)
+	}
+	// active candidates are created by the ICE agent toward remote passive candidates, no listener needed
+	s.DisableActiveTCP(!tcpActive)
+	if rtcConf.TCPPort != 0 && tcpPassive {
 		tcpListener, err = net.ListenTCP("tcp", &net.TCPAddr{
 			Port: int(rtcConf.TCPPort),
 		})
@@ -158,12 +172,15 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 			return nil, err
 		}
 
-		tcpMux := ice.NewTCPMuxDefault(ice.TCPMuxParams{
+		var tcpMux ice.TCPMux = ice.NewTCPMuxDefault(ice.TCPMuxParams{
 			Logger:          s.LoggerFactory.NewLogger("tcp_mux"),
 			Listener:        tcpListener,
 			ReadBufferSize:  readBufferSize,
 			WriteBufferSize: writeBufferSizeInBytes,
 		})
+		if gate != nil {
+			tcpMux = &admissionTCPMux{TCPMux: tcpMux, gate: gate}
+		}
 
 		s.SetICETCPMux(tcpMux)
 	}
@@ -177,20 +194,30 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 		s.SetIncludeLoopbackCandidate(true)
 	}
 
+	s.SetDTLSInsecureSkipHelloVerify(rtcConf.DTLS.SkipHelloVerify)
+
+	if tuning.ICEConsent.IsSet() {
+		consent := tuning.ICEConsent.withDefaults()
+		s.SetICETimeouts(consent.DisconnectedTimeout, consent.FailedTimeout, consent.CheckInterval)
+	}
+
+	if rtcConf.ICEGathering.SrflxAcceptanceMinWait != 0 {
+		s.SetSrflxAcceptanceMinWait(rtcConf.ICEGathering.SrflxAcceptanceMinWait)
+	}
+
 	if rtcConf.UseICELite {
 		s.SetLite(true)
-	} else if (rtcConf.NodeIP == "" || rtcConf.NodeIPAutoGenerated) && !rtcConf.UseExternalIP {
-		// use STUN servers for server to support NAT
-		// when deployed in production, we expect UseExternalIP to be used, and ports accessible
-		// this is not compatible with ICE Lite
-		// Do not automatically add STUN servers if nodeIP is set
-		if len(rtcConf.STUNServers) > 0 {
-			c.ICEServers = []webrtc.ICEServer{iceServerForStunServers(rtcConf.STUNServers)}
-		} else {
-			c.ICEServers = []webrtc.ICEServer{iceServerForStunServers(DefaultStunServers)}
-		}
+	}
+	if err := configureICEServers(rtcConf, &c); err != nil {
+		return nil, err
 	}
 
+	staticHostCandidates, err := rtcConf.parseStaticHostCandidates()
+	if err != nil {
+		return nil, err
+	}
+	rtcConf.recordAdvertisedAddresses(nat1to1IPs, ipFilter, staticHostCandidates)
+
 	net, err := stdnet.NewNet()
 	if err != nil {
 		return nil, err
@@ -204,9 +231,88 @@ func NewWebRTCConfig(rtcConf *RTCConfig, development bool) (*WebRTCConfig, error
 		TCPMuxListener: tcpListener,
 		NAT1To1IPs:     nat1to1IPs,
 		UseMDNS:        rtcConf.UseMDNS,
+		GathererParams: icegather.GathererParams{
+			Timeout:                 rtcConf.ICEGathering.Timeout,
+			CompleteAfterFirstSrflx: rtcConf.ICEGathering.CompleteAfterFirstSrflx,
+		},
+		StaticHostCandidates: staticHostCandidates,
+		nodeIP:               rtcConf.NodeIP,
 	}, nil
 }
 
+// configureNAT1To1 sets the NAT 1:1 IPs advertised in place of local addresses, returning them along with the IP filter
+// to apply. When UseExternalIP is set, external IPs are taken from resolved, or resolved from udpPorts if it is nil.
+func configureNAT1To1(
+	rtcConf *RTCConfig,
+	s *webrtc.SettingEngine,
+	ipFilter func(net.IP) bool,
+	udpPorts []int,
+	resolved *natResolution,
+) ([]string, func(net.IP) bool, error) {
+	var nat1to1IPs []string
+	// force it to the node IPs that the user has set
+	if rtcConf.NodeIP != "" && (rtcConf.UseExternalIP || !rtcConf.NodeIPAutoGenerated) {
+		if rtcConf.UseExternalIP {
+			var ips []string
+			if resolved != nil {
+				ips, ipFilter = resolved.ips, resolved.ipFilter
+				rtcConf.natMappingSource = AddressSourceSTUN
+			} else {
+				var err error
+				if ips, ipFilter, err = getNAT1to1IPsForConf(rtcConf, ipFilter, udpPorts); err != nil {
+					return nil, ipFilter, err
+				}
+			}
+			s.SetIPFilter(ipFilter)
+			if len(ips) == 0 {
+				if rtcConf.ExternalIPPolicy == ExternalIPPolicyFailFast {
+					return nil, ipFilter, &ExternalIPError{
+						STUNServers: rtcConf.stunServers(),
+						Err:         errors.New("no local address mapped to an external IP"),
+					}
+				}
+				if rtcConf.ExternalIPPolicy != "" {
+					rtcConf.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventFallback, IP: rtcConf.NodeIP})
+				}
+				logger.Infow("no external IPs found, using node IP for NAT1To1Ips", "ip", rtcConf.NodeIP)
+				s.SetNAT1To1IPs([]string{rtcConf.NodeIP}, webrtc.ICECandidateTypeHost)
+			} else {
+				logger.Infow("using external IPs", "ips", ips)
+				s.SetNAT1To1IPs(ips, webrtc.ICECandidateTypeHost)
+			}
+			nat1to1IPs = ips
+		} else {
+			s.SetNAT1To1IPs([]string{rtcConf.NodeIP}, webrtc.ICECandidateTypeHost)
+		}
+	}
+	return nat1to1IPs, ipFilter, nil
+}
+
+// configureICEServers sets the STUN and TURN servers of the node, and the relay only policy
+func configureICEServers(rtcConf *RTCConfig, c *webrtc.Configuration) error {
+	if !rtcConf.UseICELite && (rtcConf.NodeIP == "" || rtcConf.NodeIPAutoGenerated) && !rtcConf.UseExternalIP {
+		// use STUN servers for server to support NAT
+		// when deployed in production, we expect UseExternalIP to be used, and ports accessible
+		// this is not compatible with ICE Lite
+		// Do not automatically add STUN servers if nodeIP is set
+		if len(rtcConf.STUNServers) > 0 {
+			c.ICEServers = []webrtc.ICEServer{iceServerForStunServers(rtcConf.STUNServers)}
+		} else {
+			c.ICEServers = []webrtc.ICEServer{iceServerForStunServers(DefaultStunServers)}
+		}
+	}
+
+	turnICEServers, err := rtcConf.turnICEServers()
+	if err != nil {
+		return err
+	}
+	c.ICEServers = append(c.ICEServers, turnICEServers...)
+	if rtcConf.ForceRelay {
+		c.ICETransportPolicy = webrtc.ICETransportPolicyRelay
+	}
+	return nil
+}
+
 func iceServerForStunServers(servers []string) webrtc.ICEServer {
 	iceServer := webrtc.ICEServer{}
 	for _, stunServer := range servers {
@@ -215,33 +321,99 @@ func iceServerForStunServers(servers []string) webrtc.ICEServer {
 	return iceServer
 }
 
-func getNAT1to1IPsForConf(rtcConf *RTCConfig, ipFilter func(net.IP) bool) ([]string, func(net.IP) bool, error) {
-	stunServers := rtcConf.STUNServers
-	if len(stunServers) == 0 {
-		stunServers = DefaultStunServers
-	}
+// getNAT1to1IPsForConf resolves the external IPs of local addresses from udpPorts, see natUDPPorts
+func getNAT1to1IPsForConf(rtcConf *RTCConfig, ipFilter func(net.IP) bool, udpPorts []int) ([]string, func(net.IP) bool, error) {
+	stunServers := rtcConf.stunServers()
 	localIPs, err := GetLocalIPAddresses(rtcConf.EnableLoopbackCandidate, nil)
 	if err != nil {
 		return nil, ipFilter, err
 	}
+
+	natMapping, cached := rtcConf.cachedNATMapping(stunServers)
+	rtcConf.natMappingSource = AddressSourceSTUN
+	if cached {
+		rtcConf.natMappingSource = AddressSourceCache
+		logger.Infow("using cached NAT mapping", "mapping", natMapping)
+		// validate in the background, on ephemeral ports as the configured ones are about to be bound
+		c := *rtcConf
+		go func(cached map[string]string) {
+			natMapping := resolveNATMapping(&c, stunServers, localIPs, []int{0}, ipFilter)
+			if len(natMapping) == 0 {
+				return
+			}
+			c.cacheNATMapping(natMapping, stunServers)
+			if !maps.Equal(natMapping, cached) {
+				logger.Infow("cached NAT mapping is stale", "cached", cached, "mapping", natMapping)
+				c.emitExternalIPEvent(ExternalIPEvent{Type: ExternalIPEventStale})
+			}
+		}(natMapping)
+		natMapping = maps.Clone(natMapping)
+	} else {
+		natMapping = resolveNATMapping(rtcConf, stunServers, localIPs, udpPorts, ipFilter)
+		if len(natMapping) != 0 {
+			rtcConf.cacheNATMapping(maps.Clone(natMapping), stunServers)
+		}
+	}
+
+	if len(natMapping) == 0 {
+		// no external ip resolved
+		return nil, ipFilter, nil
+	}
+
+	mappedIPs := make([]string, 0, len(natMapping))
+	for _, local := range natMapping {
+		mappedIPs = append(mappedIPs, local)
+	}
+
+	// mapping unresolved local ip to itself
+	for _, local := range localIPs {
+		var found bool
+		for _, localIPMapping := range natMapping {
+			if local == localIPMapping {
+				found = true
+				break
+			}
+		}
+		if !found {
+			natMapping[local] = local
+		}
+	}
+
+	nat1to1IPs := make([]string, 0, len(natMapping))
+	for external, local := range natMapping {
+		nat1to1IPs = append(nat1to1IPs, fmt.Sprintf("%s/%s", external, local))
+	}
+
+	if rtcConf.ExternalIPOnly {
+		originFilter := ipFilter
+		ipFilter = func(ip net.IP) bool {
+			// don't filter out ipv6 address
+			if ip.To4() == nil {
+				return originFilter == nil || originFilter(ip)
+			}
+
+			for _, mappedIP := range mappedIPs {
+				if ip.Equal(net.ParseIP(mappedIP)) {
+					return true
+				}
+			}
+			return false
+		}
+		logger.Infow("use ips(v4) mapped to external only", "ips", mappedIPs)
+	}
+	return nat1to1IPs, ipFilter, nil
+}
+
+// resolveNATMapping resolves the external IP of local addresses, returning a map of external IP to local IP
+func resolveNATMapping(rtcConf *RTCConfig, stunServers []string, localIPs []string, udpPorts []int, ipFilter func(net.IP) bool) map[string]string {
 	type ipmapping struct {
 		externalIP string
 		localIP    string
 	}
 	addrCh := make(chan ipmapping, len(localIPs))
 
-	var udpPorts []int
-	if rtcConf.ICEPortRangeStart != 0 && rtcConf.ICEPortRangeEnd != 0 {
-		portRangeStart, portRangeEnd := uint16(rtcConf.ICEPortRangeStart), uint16(rtcConf.ICEPortRangeEnd)
-		for i := 0; i < 5; i++ {
-			udpPorts = append(udpPorts, rand.Intn(int(portRangeEnd-portRangeStart))+int(portRangeStart))
-		}
-	} else if rtcConf.UDPPort.Valid() {
-		udpPorts = append(udpPorts, rtcConf.UDPPort.Start)
-	} else {
-		udpPorts = append(udpPorts, 0)
-	}
-
+	// STUN failures repeat for every local IP and port
+	log := logsampler.NewLogger(loglevel.Default().Logger(loglevel.SubsystemDiscovery, logger.GetLogger()), logsampler.Default())
 	var wg sync.WaitGroup
 	ctx, cancel := context.WithCancel(context.Background())
 	for _, ip := range localIPs {
@@ -253,24 +425,23 @@ func getNAT1to1IPsForConf(rtcConf *RTCConfig, ipFilter func(net.IP) bool) ([]str
 		go func(localIP string) {
 			defer wg.Done()
 			for _, port := range udpPorts {
-				addr, err := GetExternalIP(ctx, stunServers, &net.UDPAddr{IP: net.ParseIP(localIP), Port: port})
+				addr, err := GetExternalIPWithConfig(ctx, stunServers, &net.UDPAddr{IP: net.ParseIP(localIP), Port: port}, rtcConf.STUNRequest)
 				if err != nil {
 					if strings.Contains(err.Error(), "address already in use") {
-						logger.Infow("failed to get external ip, address already in use", "local", localIP, "port", port)
+						log.Infow("failed to get external ip, address already in use", "local", localIP, "port", port)
 						continue
 					}
-					logger.Infow("failed to get external ip", "local", localIP, "err", err)
+					log.Infow("failed to get external ip", "local", localIP, "err", err)
 					return
 				}
 				addrCh <- ipmapping{externalIP: addr, localIP: localIP}
 				return
 			}
-			logger.Infow("failed to get external ip after all ports tried", "local", localIP, "ports", udpPorts)
+			log.Infow("failed to get external ip after all ports tried", "local", localIP, "ports", udpPorts)
 		}(ip)
 	}
 
 	var firstResolved bool
-	var mappedIPs []string
 	natMapping := make(map[string]string)
 	timeout := time.NewTimer(5 * time.Second)
 	defer timeout.Stop()
@@ -290,7 +461,6 @@ done:
 					"ignore", mapping.localIP)
 			} else {
 				natMapping[mapping.externalIP] = mapping.localIP
-				mappedIPs = append(mappedIPs, mapping.localIP)
 			}
 
 		case <-timeout.C:
@@ -300,48 +470,23 @@ done:
 	cancel()
 	wg.Wait()
 
-	if len(natMapping) == 0 {
-		// no external ip resolved
-		return nil, ipFilter, nil
-	}
-
-	// mapping unresolved local ip to itself
-	for _, local := range localIPs {
-		var found bool
-		for _, localIPMapping := range natMapping {
-			if local == localIPMapping {
-				found = true
-				break
-			}
-		}
-		if !found {
-			natMapping[local] = local
-		}
-	}
-
-	nat1to1IPs := make([]string, 0, len(natMapping))
-	for external, local := range natMapping {
-		nat1to1IPs = append(nat1to1IPs, fmt.Sprintf("%s/%s", external, local))
-	}
-
-	if rtcConf.ExternalIPOnly {
-		originFilter := ipFilter
-		ipFilter = func(ip net.IP) bool {
-			// don't filter out ipv6 address
-			if ip.To4() == nil {
-				return originFilter == nil || originFilter(ip)
-			}
+	return natMapping
+}
 
-			for _, mappedIP := range mappedIPs {
-				if ip.Equal(net.ParseIP(mappedIP)) {
-					return true
-				}
-			}
-			return false
+// local ports to resolve external IPs from, the NAT mapping of a port in use by the ICE agent is most relevant
+func natUDPPorts(rtcConf *RTCConfig) []int {
+	var udpPorts []int
+	if rtcConf.ICEPortRangeStart != 0 && rtcConf.ICEPortRangeEnd != 0 {
+		portRangeStart, portRangeEnd := uint16(rtcConf.ICEPortRangeStart), uint16(rtcConf.ICEPortRangeEnd)
+		for i := 0; i < 5; i++ {
+			udpPorts = append(udpPorts, rand.Intn(int(portRangeEnd-portRangeStart))+int(portRangeStart))
 		}
-		logger.Infow("use ips(v4) mapped to external only", "ips", mappedIPs)
+	} else if rtcConf.UDPPort.Valid() {
+		udpPorts = append(udpPorts, rtcConf.UDPPort.Start)
+	} else {
+		udpPorts = append(udpPorts, 0)
 	}
-	return nat1to1IPs, ipFilter, nil
+	return udpPorts
 }
 
 func InterfaceFilterFromConf(ifs InterfacesConfig) func(string) bool {
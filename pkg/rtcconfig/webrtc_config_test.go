@@ -17,8 +17,12 @@ package rtcconfig
 import (
 	"net"
 	"testing"
+	"time"
 
+	"github.com/pion/webrtc/v3"
 	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/transport"
 )
 
 func Test_IPFilterFromConf(t *testing.T) {
@@ -86,3 +90,208 @@ func Test_InterfaceFilterFromConf(t *testing.T) {
 		}
 	}
 }
+
+func Test_ICEConsentConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		consent  ICEConsentConfig
+		expected ICEConsentConfig
+		valid    bool
+	}{
+		{
+			name:    "not set",
+			consent: ICEConsentConfig{},
+			expected: ICEConsentConfig{
+				CheckInterval:       defaultICEConsentCheckInterval,
+				DisconnectedTimeout: defaultICEConsentDisconnectedTimeout,
+				FailedTimeout:       defaultICEConsentFailedTimeout,
+			},
+			valid: true,
+		},
+		{
+			name:    "partially set",
+			consent: ICEConsentConfig{FailedTimeout: 30 * time.Second},
+			expected: ICEConsentConfig{
+				CheckInterval:       defaultICEConsentCheckInterval,
+				DisconnectedTimeout: defaultICEConsentDisconnectedTimeout,
+				FailedTimeout:       30 * time.Second,
+			},
+			valid: true,
+		},
+		{
+			name:    "check interval not less than failed timeout",
+			consent: ICEConsentConfig{CheckInterval: 10 * time.Second, FailedTimeout: 10 * time.Second},
+			expected: ICEConsentConfig{
+				CheckInterval:       10 * time.Second,
+				DisconnectedTimeout: defaultICEConsentDisconnectedTimeout,
+				FailedTimeout:       10 * time.Second,
+			},
+			valid: false,
+		},
+		{
+			name:    "disconnected timeout equal to failed timeout",
+			consent: ICEConsentConfig{DisconnectedTimeout: 5 * time.Second, FailedTimeout: 5 * time.Second},
+			expected: ICEConsentConfig{
+				CheckInterval:       defaultICEConsentCheckInterval,
+				DisconnectedTimeout: 5 * time.Second,
+				FailedTimeout:       5 * time.Second,
+			},
+			valid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.consent.withDefaults())
+
+			conf := &RTCConfig{
+				UDPPort:    PortRange{Start: 7882},
+				NodeIP:     "10.0.0.1",
+				ICEConsent: tc.consent,
+			}
+			err := conf.Validate(true)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_ICETCPModes(t *testing.T) {
+	testCases := []struct {
+		name    string
+		tcpPort uint32
+		modes   []ICETCPMode
+		passive bool
+		active  bool
+		valid   bool
+	}{
+		{name: "default without tcp port", valid: true},
+		{name: "default with tcp port", tcpPort: 7881, passive: true, active: true, valid: true},
+		{name: "passive only", tcpPort: 7881, modes: []ICETCPMode{ICETCPModePassive}, passive: true, valid: true},
+		{name: "active only", modes: []ICETCPMode{ICETCPModeActive}, active: true, valid: true},
+		{name: "passive without tcp port", modes: []ICETCPMode{ICETCPModePassive}, passive: true},
+		{name: "simultaneous open", tcpPort: 7881, modes: []ICETCPMode{ICETCPModeSimultaneousOpen}},
+		{name: "unknown", tcpPort: 7881, modes: []ICETCPMode{"other"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &RTCConfig{
+				UDPPort:     PortRange{Start: 7882},
+				TCPPort:     tc.tcpPort,
+				NodeIP:      "10.0.0.1",
+				ICETCPModes: tc.modes,
+			}
+			passive, active := conf.iceTCPModes()
+			require.Equal(t, tc.passive, passive)
+			require.Equal(t, tc.active, active)
+
+			err := conf.Validate(true)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_StaticHostCandidates(t *testing.T) {
+	c, err := ParseStaticHostCandidate("203.0.113.10:7882")
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.10:7882/udp", c.String())
+
+	c, err = ParseStaticHostCandidate("[2001:db8::1]:7881/tcp")
+	require.NoError(t, err)
+	require.Equal(t, webrtc.ICEProtocolTCP, c.Protocol)
+
+	candidateInit, err := c.ICECandidateInit("0", 0)
+	require.NoError(t, err)
+	require.Contains(t, candidateInit.Candidate, "2001:db8::1 7881 typ host tcptype passive")
+
+	for _, invalid := range []string{"203.0.113.10", "0.0.0.0:7882", "203.0.113.10:0", "203.0.113.10:7882/sctp"} {
+		_, err = ParseStaticHostCandidate(invalid)
+		require.Error(t, err, invalid)
+	}
+
+	testCases := []struct {
+		name       string
+		candidates []string
+		valid      bool
+	}{
+		{name: "udp mux port", candidates: []string{"203.0.113.10:7882"}, valid: true},
+		{name: "tcp mux port", candidates: []string{"203.0.113.10:7881/tcp"}, valid: true},
+		{name: "port not served by udp mux", candidates: []string{"203.0.113.10:7883"}},
+		{name: "port not served by tcp mux", candidates: []string{"203.0.113.10:7882/tcp"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &RTCConfig{
+				UDPPort:              PortRange{Start: 7882},
+				TCPPort:              7881,
+				NodeIP:               "10.0.0.1",
+				StaticHostCandidates: tc.candidates,
+			}
+			err := conf.Validate(true)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func Test_DTLSSkipHelloVerify(t *testing.T) {
+	conf := &RTCConfig{
+		UDPPort: PortRange{Start: 7882},
+		NodeIP:  "10.0.0.1",
+		DTLS:    DTLSConfig{SkipHelloVerify: true},
+	}
+	require.NoError(t, conf.Validate(true))
+
+	conf.NodeIP = "203.0.113.1"
+	require.Error(t, conf.Validate(true))
+
+	conf.DTLS.SkipHelloVerify = false
+	require.NoError(t, conf.Validate(true))
+}
+
+func Test_FilterPolicy(t *testing.T) {
+	// not on any interface, as if its interface disappeared
+	gone := net.ParseIP("192.0.2.123")
+
+	for _, policy := range []FilterPolicy{"", FilterPolicyFailClosed, FilterPolicyFailOpen} {
+		var failures []transport.FilterFailure
+		conf := &RTCConfig{
+			Interfaces:   InterfacesConfig{Excludes: []string{"docker0"}},
+			FilterPolicy: policy,
+			OnFilterFailure: func(failure transport.FilterFailure) {
+				failures = append(failures, failure)
+			},
+		}
+		require.NoError(t, conf.validateFilterPolicy())
+
+		ifFilter, ipFilter, err := conf.filters()
+		require.NoError(t, err)
+		require.True(t, ifFilter("eth0"))
+		require.False(t, ifFilter("docker0"))
+
+		require.True(t, ipFilter(net.ParseIP("127.0.0.1")))
+		require.Equal(t, policy == FilterPolicyFailOpen, ipFilter(gone))
+		require.Len(t, failures, 1)
+		require.True(t, failures[0].IP.Equal(gone))
+	}
+
+	conf := &RTCConfig{FilterPolicy: "allow"}
+	require.Error(t, conf.validateFilterPolicy())
+
+	ifFilter, ipFilter, err := conf.filters()
+	require.NoError(t, err)
+	require.Nil(t, ifFilter)
+	require.Nil(t, ipFilter)
+}
@@ -0,0 +1,242 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/livekit/protocol/logger"
+
+	"github.com/livekit/mediatransportutil/pkg/timingwheel"
+	"github.com/livekit/mediatransportutil/pkg/wire"
+)
+
+const (
+	heartbeatSize = 4 + 8
+)
+
+// Heartbeat is the data of a heartbeat APP packet, big endian
+//
+//	seq (4) | send time, unix nanoseconds of the sender clock (8)
+type Heartbeat struct {
+	Seq    uint32
+	SentAt time.Time
+}
+
+func (h Heartbeat) Marshal() []byte {
+	data := make([]byte, heartbeatSize)
+	binary.BigEndian.PutUint32(data, h.Seq)
+	binary.BigEndian.PutUint64(data[4:], uint64(h.SentAt.UnixNano()))
+	return data
+}
+
+func ParseHeartbeat(data []byte) (Heartbeat, error) {
+	r := wire.NewReader(data)
+	seq := r.Uint32()
+	sentAt := r.Uint64()
+	if err := r.Finish(); err != nil {
+		return Heartbeat{}, fmt.Errorf("%w: heartbeat: %v", ErrInvalidPacket, err)
+	}
+	return Heartbeat{
+		Seq:    seq,
+		SentAt: time.Unix(0, int64(sentAt)),
+	}, nil
+}
+
+// ------------------------------------------------
+
+type HeartbeatParams struct {
+	// APP subtype of heartbeats, other subtypes are free for control messages
+	SubType uint8
+	// interval between heartbeats sent
+	Interval time.Duration
+	// the remote is considered down after no heartbeat for this long
+	Timeout time.Duration
+}
+
+var HeartbeatParamsDefault = HeartbeatParams{
+	SubType:  0,
+	Interval: time.Second,
+	Timeout:  5 * time.Second,
+}
+
+type LivenessEvent struct {
+	IsAlive bool
+	// last heartbeat received, zero if none
+	LastHeartbeat Heartbeat
+	ReceivedAt    time.Time
+}
+
+// Heartbeater sends heartbeats with a Sender and tracks liveness of the remote node from
+// the heartbeats it sends back. The remote is down until the first heartbeat.
+type Heartbeater struct {
+	params HeartbeatParams
+	sender *Sender
+
+	lock          sync.Mutex
+	seq           uint32
+	lastHeartbeat Heartbeat
+	receivedAt    time.Time
+	isAlive       bool
+	onLiveness    func(event LivenessEvent)
+	isStopped     bool
+	timer         *timingwheel.Timer
+
+	close chan struct{}
+}
+
+func NewHeartbeater(params HeartbeatParams, sender *Sender) *Heartbeater {
+	if params.Interval <= 0 {
+		params.Interval = HeartbeatParamsDefault.Interval
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = HeartbeatParamsDefault.Timeout
+	}
+	return &Heartbeater{
+		params: params,
+		sender: sender,
+		close:  make(chan struct{}),
+	}
+}
+
+func (h *Heartbeater) OnLiveness(f func(event LivenessEvent)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.onLiveness = f
+}
+
+func (h *Heartbeater) Start() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.isStopped {
+		return
+	}
+	go h.worker()
+}
+
+// StartOnWheel sends heartbeats from a timer of a shared wheel instead of a goroutine of its own,
+// for nodes with many heartbeaters.
+func (h *Heartbeater) StartOnWheel(wheel *timingwheel.Wheel) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.isStopped || h.timer != nil {
+		return
+	}
+	h.timer = wheel.Every(h.params.Interval, func() {
+		h.tick(time.Now())
+	})
+}
+
+func (h *Heartbeater) Stop() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.isStopped {
+		return
+	}
+
+	close(h.close)
+	if h.timer != nil {
+		h.timer.Stop()
+	}
+	h.isStopped = true
+}
+
+func (h *Heartbeater) IsAlive() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.isAlive
+}
+
+// HandlePacket processes a received APP packet, returns false if it is not a heartbeat.
+func (h *Heartbeater) HandlePacket(pkt *Packet) bool {
+	return h.handlePacket(pkt, time.Now())
+}
+
+func (h *Heartbeater) handlePacket(pkt *Packet, now time.Time) bool {
+	if pkt.Name != h.sender.Name() || pkt.SubType != h.params.SubType {
+		return false
+	}
+
+	heartbeat, err := ParseHeartbeat(pkt.Data)
+	if err != nil {
+		logger.Debugw("invalid heartbeat", "error", err, "ssrc", pkt.SSRC)
+		return true
+	}
+
+	h.lock.Lock()
+	h.lastHeartbeat = heartbeat
+	h.receivedAt = now
+	event := h.setAliveLocked(true)
+	onLiveness := h.onLiveness
+	h.lock.Unlock()
+
+	if event != nil && onLiveness != nil {
+		onLiveness(*event)
+	}
+	return true
+}
+
+func (h *Heartbeater) worker() {
+	ticker := time.NewTicker(h.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.close:
+			return
+		case <-ticker.C:
+			h.tick(time.Now())
+		}
+	}
+}
+
+func (h *Heartbeater) tick(now time.Time) {
+	h.lock.Lock()
+	h.seq++
+	heartbeat := Heartbeat{Seq: h.seq, SentAt: now}
+
+	var event *LivenessEvent
+	if h.isAlive && now.Sub(h.receivedAt) > h.params.Timeout {
+		event = h.setAliveLocked(false)
+	}
+	onLiveness := h.onLiveness
+	h.lock.Unlock()
+
+	if event != nil && onLiveness != nil {
+		onLiveness(*event)
+	}
+
+	if err := h.sender.send(h.params.SubType, heartbeat.Marshal(), now); err != nil {
+		logger.Debugw("could not send heartbeat", "error", err)
+	}
+}
+
+func (h *Heartbeater) setAliveLocked(isAlive bool) *LivenessEvent {
+	if h.isAlive == isAlive {
+		return nil
+	}
+
+	h.isAlive = isAlive
+	return &LivenessEvent{
+		IsAlive:       isAlive,
+		LastHeartbeat: h.lastHeartbeat,
+		ReceivedAt:    h.receivedAt,
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/timingwheel"
+)
+
+func TestHeartbeatMarshal(t *testing.T) {
+	heartbeat := Heartbeat{Seq: 7, SentAt: time.Unix(1700000000, 123)}
+	parsed, err := ParseHeartbeat(heartbeat.Marshal())
+	require.NoError(t, err)
+	require.Equal(t, heartbeat.Seq, parsed.Seq)
+	require.True(t, heartbeat.SentAt.Equal(parsed.SentAt))
+
+	_, err = ParseHeartbeat([]byte{1, 2, 3})
+	require.ErrorIs(t, err, ErrInvalidPacket)
+	_, err = ParseHeartbeat(make([]byte, heartbeatSize+1))
+	require.ErrorIs(t, err, ErrInvalidPacket)
+}
+
+func TestHeartbeater(t *testing.T) {
+	var sent []*Packet
+	sender, err := NewSender(SenderParams{Name: "LKHB"}, func(pkts []rtcp.Packet) error {
+		sent = append(sent, pkts[0].(*Packet))
+		return nil
+	})
+	require.NoError(t, err)
+
+	h := NewHeartbeater(HeartbeatParams{SubType: 1, Interval: time.Second, Timeout: 3 * time.Second}, sender)
+	var events []LivenessEvent
+	h.OnLiveness(func(event LivenessEvent) {
+		events = append(events, event)
+	})
+	require.False(t, h.IsAlive())
+
+	now := time.Now()
+	h.tick(now)
+	require.Len(t, sent, 1)
+	require.Equal(t, uint8(1), sent[0].SubType)
+	heartbeat, err := ParseHeartbeat(sent[0].Data)
+	require.NoError(t, err)
+	require.Equal(t, uint32(1), heartbeat.Seq)
+
+	// other subtypes and names are not heartbeats
+	require.False(t, h.handlePacket(&Packet{Name: "LKHB", SubType: 2}, now))
+	require.False(t, h.handlePacket(&Packet{Name: "OTHR", SubType: 1}, now))
+
+	// remote alive on first heartbeat
+	require.True(t, h.handlePacket(sent[0], now))
+	require.True(t, h.IsAlive())
+	require.Len(t, events, 1)
+	require.True(t, events[0].IsAlive)
+	require.Equal(t, uint32(1), events[0].LastHeartbeat.Seq)
+
+	h.tick(now.Add(3 * time.Second))
+	require.True(t, h.IsAlive())
+
+	// down after timeout
+	h.tick(now.Add(4 * time.Second))
+	require.False(t, h.IsAlive())
+	require.Len(t, events, 2)
+	require.False(t, events[1].IsAlive)
+	require.Equal(t, now, events[1].ReceivedAt)
+}
+
+func TestHeartbeaterOnWheel(t *testing.T) {
+	var numSent atomic.Int32
+	sender, err := NewSender(SenderParams{Name: "LKHB", Rate: 1000, Burst: 1000}, func(pkts []rtcp.Packet) error {
+		numSent.Add(1)
+		return nil
+	})
+	require.NoError(t, err)
+
+	wheel := timingwheel.NewWheel(timingwheel.WheelParams{Tick: time.Millisecond})
+	wheel.Start()
+	defer wheel.Stop()
+
+	h := NewHeartbeater(HeartbeatParams{Interval: 5 * time.Millisecond}, sender)
+	h.StartOnWheel(wheel)
+	require.Eventually(t, func() bool { return numSent.Load() >= 3 }, time.Second, time.Millisecond)
+
+	h.Stop()
+	require.Equal(t, 0, wheel.NumTimers())
+}
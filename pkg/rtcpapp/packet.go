@@ -0,0 +1,205 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/mediatransportutil/pkg/wire"
+)
+
+const (
+	headerLength = 4
+	ssrcLength   = 4
+	nameLength   = 4
+
+	// APP packets share the RTCP bandwidth of the media path, larger data is rejected
+	MaxDataSize = 1024
+
+	maxSubType = 31
+)
+
+var (
+	ErrInvalidName    = errors.New("APP name must be 4 printable ASCII characters")
+	ErrInvalidSubType = errors.New("APP subtype must be at most 31")
+	ErrDataTooLarge   = errors.New("APP data too large")
+	ErrInvalidPacket  = errors.New("invalid APP packet")
+)
+
+// Packet is an RTCP APP packet (RFC 3550, 6.7). The name identifies the vendor/application and
+// the subtype a message within it. Data of any length is carried, with RTCP padding up to a
+// multiple of 32 bits.
+//
+//	 0                   1                   2                   3
+//	 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|V=2|P| subtype |   PT=APP=204  |             length            |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                           SSRC/CSRC                           |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                          name (ASCII)                         |
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//	|                   application-dependent data                ...
+//	+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type Packet struct {
+	SubType uint8
+	SSRC    uint32
+	Name    string
+	Data    []byte
+}
+
+var _ rtcp.Packet = (*Packet)(nil)
+
+func (p *Packet) DestinationSSRC() []uint32 {
+	return []uint32{p.SSRC}
+}
+
+func (p *Packet) MarshalSize() int {
+	return headerLength + ssrcLength + nameLength + len(p.Data) + paddingSize(len(p.Data))
+}
+
+func (p *Packet) Marshal() ([]byte, error) {
+	if err := validateName(p.Name); err != nil {
+		return nil, err
+	}
+	if p.SubType > maxSubType {
+		return nil, ErrInvalidSubType
+	}
+	if len(p.Data) > MaxDataSize {
+		return nil, fmt.Errorf("%w: %d bytes, max %d", ErrDataTooLarge, len(p.Data), MaxDataSize)
+	}
+
+	size := p.MarshalSize()
+	padding := paddingSize(len(p.Data))
+	header := rtcp.Header{
+		Padding: padding != 0,
+		Count:   p.SubType,
+		Type:    rtcp.TypeApplicationDefined,
+		Length:  uint16(size/4 - 1),
+	}
+	hdr, err := header.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, size)
+	copy(raw, hdr)
+	binary.BigEndian.PutUint32(raw[headerLength:], p.SSRC)
+	copy(raw[headerLength+ssrcLength:], p.Name)
+	copy(raw[headerLength+ssrcLength+nameLength:], p.Data)
+	if padding != 0 {
+		raw[size-1] = byte(padding)
+	}
+	return raw, nil
+}
+
+func (p *Packet) Unmarshal(rawPacket []byte) error {
+	var header rtcp.Header
+	if err := header.Unmarshal(rawPacket); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPacket, err)
+	}
+	if header.Type != rtcp.TypeApplicationDefined {
+		return fmt.Errorf("%w: packet type %d", ErrInvalidPacket, header.Type)
+	}
+
+	size := (int(header.Length) + 1) * 4
+	if size > len(rawPacket) || size < headerLength+ssrcLength+nameLength {
+		return fmt.Errorf("%w: length %d, %d bytes", ErrInvalidPacket, size, len(rawPacket))
+	}
+	raw := rawPacket[:size]
+
+	dataSize := size - headerLength - ssrcLength - nameLength
+	if header.Padding {
+		padding := int(raw[size-1])
+		if padding == 0 || padding > dataSize {
+			return fmt.Errorf("%w: padding %d", ErrInvalidPacket, padding)
+		}
+		dataSize -= padding
+	}
+	if dataSize > MaxDataSize {
+		return fmt.Errorf("%w: %d bytes, max %d", ErrDataTooLarge, dataSize, MaxDataSize)
+	}
+
+	r := wire.NewReader(raw[headerLength:])
+	ssrc := r.Uint32()
+	name := string(r.Bytes(nameLength))
+	data := r.Bytes(dataSize)
+	if err := r.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPacket, err)
+	}
+	if err := validateName(name); err != nil {
+		return err
+	}
+
+	p.SubType = header.Count
+	p.SSRC = ssrc
+	p.Name = name
+	p.Data = append([]byte{}, data...)
+	return nil
+}
+
+func (p *Packet) String() string {
+	return fmt.Sprintf("APP %s/%d from %d, %d bytes", p.Name, p.SubType, p.SSRC, len(p.Data))
+}
+
+// FromRTCP returns the APP packets with the given name in unmarshalled RTCP packets, pion/rtcp
+// leaves APP packets as rtcp.RawPacket. Malformed APP packets are skipped.
+func FromRTCP(pkts []rtcp.Packet, name string) []*Packet {
+	var apps []*Packet
+	for _, pkt := range pkts {
+		switch pkt := pkt.(type) {
+		case *Packet:
+			if pkt.Name == name {
+				apps = append(apps, pkt)
+			}
+
+		case *rtcp.RawPacket:
+			if pkt.Header().Type != rtcp.TypeApplicationDefined {
+				continue
+			}
+			app := &Packet{}
+			if err := app.Unmarshal(*pkt); err != nil || app.Name != name {
+				continue
+			}
+			apps = append(apps, app)
+		}
+	}
+	return apps
+}
+
+// ------------------------------------------------
+
+func validateName(name string) error {
+	if len(name) != nameLength {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 0x20 || name[i] > 0x7e {
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+	}
+	return nil
+}
+
+func paddingSize(dataSize int) int {
+	if dataSize%4 == 0 {
+		return 0
+	}
+	return 4 - dataSize%4
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacketMarshal(t *testing.T) {
+	for _, data := range [][]byte{nil, {1}, {1, 2, 3, 4}, {1, 2, 3, 4, 5, 6}} {
+		p := &Packet{SubType: 3, SSRC: 1234, Name: "LKHB", Data: data}
+		raw, err := p.Marshal()
+		require.NoError(t, err)
+		require.Len(t, raw, p.MarshalSize())
+		require.Zero(t, len(raw)%4)
+
+		var header rtcp.Header
+		require.NoError(t, header.Unmarshal(raw))
+		require.Equal(t, rtcp.TypeApplicationDefined, header.Type)
+		require.Equal(t, len(data)%4 != 0, header.Padding)
+
+		var parsed Packet
+		require.NoError(t, parsed.Unmarshal(raw))
+		require.Equal(t, uint8(3), parsed.SubType)
+		require.Equal(t, uint32(1234), parsed.SSRC)
+		require.Equal(t, "LKHB", parsed.Name)
+		require.Equal(t, len(data), len(parsed.Data))
+		if len(data) != 0 {
+			require.Equal(t, data, parsed.Data)
+		}
+	}
+}
+
+func TestPacketValidation(t *testing.T) {
+	_, err := (&Packet{Name: "LK"}).Marshal()
+	require.ErrorIs(t, err, ErrInvalidName)
+	_, err = (&Packet{Name: "LK\x00B"}).Marshal()
+	require.ErrorIs(t, err, ErrInvalidName)
+	_, err = (&Packet{Name: "LKHB", SubType: 32}).Marshal()
+	require.ErrorIs(t, err, ErrInvalidSubType)
+	_, err = (&Packet{Name: "LKHB", Data: make([]byte, MaxDataSize+1)}).Marshal()
+	require.ErrorIs(t, err, ErrDataTooLarge)
+
+	raw, err := (&Packet{Name: "LKHB", Data: []byte{1, 2}}).Marshal()
+	require.NoError(t, err)
+
+	var p Packet
+	require.ErrorIs(t, p.Unmarshal(raw[:8]), ErrInvalidPacket)
+
+	// padding longer than the data
+	bad := append([]byte{}, raw...)
+	bad[len(bad)-1] = 8
+	require.ErrorIs(t, p.Unmarshal(bad), ErrInvalidPacket)
+
+	pli, err := (&rtcp.PictureLossIndication{MediaSSRC: 1}).Marshal()
+	require.NoError(t, err)
+	require.ErrorIs(t, p.Unmarshal(pli), ErrInvalidPacket)
+}
+
+func TestFromRTCP(t *testing.T) {
+	compound, err := rtcp.Marshal([]rtcp.Packet{
+		&rtcp.ReceiverReport{SSRC: 1},
+		&Packet{SSRC: 1, Name: "LKHB", Data: []byte("ping")},
+		&Packet{SSRC: 1, Name: "OTHR", Data: []byte("other")},
+	})
+	require.NoError(t, err)
+
+	pkts, err := rtcp.Unmarshal(compound)
+	require.NoError(t, err)
+	require.Len(t, pkts, 3)
+
+	apps := FromRTCP(pkts, "LKHB")
+	require.Len(t, apps, 1)
+	require.Equal(t, []byte("ping"), apps[0].Data)
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+
+	"github.com/livekit/mediatransportutil/pkg/ratelimit"
+)
+
+var (
+	ErrRateLimited = errors.New("APP packet rate limited")
+)
+
+type SenderParams struct {
+	// APP name, 4 ASCII characters identifying the vendor/application
+	Name string
+	// SSRC the packets are sent from
+	SSRC uint32
+	// sustained packets per second
+	Rate float64
+	// packets that can be sent back to back
+	Burst int
+	// data beyond this size is rejected, at most MaxDataSize
+	MaxDataSize int
+}
+
+var SenderParamsDefault = SenderParams{
+	Rate:        5,
+	Burst:       10,
+	MaxDataSize: MaxDataSize,
+}
+
+type SenderStats struct {
+	NumSent        int
+	NumRateLimited int
+	NumTooLarge    int
+	NumFailed      int
+}
+
+// Sender sends APP packets over an existing media path, for example with
+// webrtc.PeerConnection.WriteRTCP, rate limited with a token bucket.
+type Sender struct {
+	params SenderParams
+	write  func(pkts []rtcp.Packet) error
+
+	lock   sync.Mutex
+	bucket ratelimit.TokenBucket
+	stats  SenderStats
+}
+
+func NewSender(params SenderParams, write func(pkts []rtcp.Packet) error) (*Sender, error) {
+	if err := validateName(params.Name); err != nil {
+		return nil, err
+	}
+	if params.Rate <= 0 {
+		params.Rate = SenderParamsDefault.Rate
+	}
+	if params.Burst <= 0 {
+		params.Burst = SenderParamsDefault.Burst
+	}
+	if params.MaxDataSize <= 0 || params.MaxDataSize > MaxDataSize {
+		params.MaxDataSize = SenderParamsDefault.MaxDataSize
+	}
+	return &Sender{
+		params: params,
+		write:  write,
+		bucket: ratelimit.NewTokenBucket(params.Rate, float64(params.Burst)),
+	}, nil
+}
+
+func (s *Sender) Name() string {
+	return s.params.Name
+}
+
+// Send sends data as an APP packet of the given subtype, ErrRateLimited if over the rate.
+func (s *Sender) Send(subType uint8, data []byte) error {
+	return s.send(subType, data, time.Now())
+}
+
+func (s *Sender) Stats() SenderStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stats
+}
+
+func (s *Sender) send(subType uint8, data []byte, now time.Time) error {
+	if subType > maxSubType {
+		return ErrInvalidSubType
+	}
+
+	s.lock.Lock()
+	if len(data) > s.params.MaxDataSize {
+		s.stats.NumTooLarge++
+		s.lock.Unlock()
+		return fmt.Errorf("%w: %d bytes, max %d", ErrDataTooLarge, len(data), s.params.MaxDataSize)
+	}
+
+	if !s.bucket.AllowAt(now, 1) {
+		s.stats.NumRateLimited++
+		s.lock.Unlock()
+		return ErrRateLimited
+	}
+	s.lock.Unlock()
+
+	err := s.write([]rtcp.Packet{&Packet{
+		SubType: subType,
+		SSRC:    s.params.SSRC,
+		Name:    s.params.Name,
+		Data:    data,
+	}})
+
+	s.lock.Lock()
+	if err != nil {
+		s.stats.NumFailed++
+	} else {
+		s.stats.NumSent++
+	}
+	s.lock.Unlock()
+	return err
+}
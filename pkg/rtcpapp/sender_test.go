@@ -0,0 +1,60 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpapp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSenderRateLimit(t *testing.T) {
+	_, err := NewSender(SenderParams{Name: "toolong"}, nil)
+	require.ErrorIs(t, err, ErrInvalidName)
+
+	var sent []*Packet
+	s, err := NewSender(SenderParams{Name: "LKCT", SSRC: 42, Rate: 2, Burst: 3, MaxDataSize: 8}, func(pkts []rtcp.Packet) error {
+		for _, pkt := range pkts {
+			sent = append(sent, pkt.(*Packet))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.send(1, []byte{byte(i)}, now))
+	}
+	require.ErrorIs(t, s.send(1, nil, now), ErrRateLimited)
+
+	// a token every 500 ms
+	require.ErrorIs(t, s.send(1, nil, now.Add(400*time.Millisecond)), ErrRateLimited)
+	require.NoError(t, s.send(1, nil, now.Add(500*time.Millisecond)))
+
+	require.ErrorIs(t, s.send(1, make([]byte, 9), now.Add(time.Minute)), ErrDataTooLarge)
+	require.ErrorIs(t, s.send(32, nil, now.Add(time.Minute)), ErrInvalidSubType)
+
+	require.Len(t, sent, 4)
+	require.Equal(t, "LKCT", sent[0].Name)
+	require.Equal(t, uint32(42), sent[0].SSRC)
+	require.Equal(t, uint8(1), sent[0].SubType)
+
+	stats := s.Stats()
+	require.Equal(t, 4, stats.NumSent)
+	require.Equal(t, 2, stats.NumRateLimited)
+	require.Equal(t, 1, stats.NumTooLarge)
+}
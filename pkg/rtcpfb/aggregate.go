@@ -0,0 +1,281 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+type RRAggregatorParams struct {
+	// reports older than MaxAge are left out of summaries, subscribers that stopped reporting age out
+	MaxAge time.Duration
+	// percentile, 0 to 100, of subscriber loss and jitter carried in the reception report sent upstream,
+	// 100 reports the worst subscriber
+	ReportPercentile float64
+}
+
+var RRAggregatorParamsDefault = RRAggregatorParams{
+	MaxAge:           5 * time.Second,
+	ReportPercentile: 90,
+}
+
+type Distribution struct {
+	Min  float64
+	Mean float64
+	P50  float64
+	P90  float64
+	P99  float64
+	Max  float64
+}
+
+type RRSummary struct {
+	// upstream media SSRC the subscriber reports are for
+	SSRC           uint32
+	NumSubscribers int
+	// fraction of packets lost, 0 to 1
+	FractionLost Distribution
+	// interarrival jitter, in RTP timestamp units
+	Jitter Distribution
+	// round trip time in seconds, of the subscribers it is known for
+	RTT    Distribution
+	NumRTT int
+	// highest extended sequence number received by any subscriber
+	HighestSeqNum uint32
+	// time of the latest subscriber report
+	ReportedAt time.Time
+
+	reportedLoss   float64
+	reportedJitter float64
+}
+
+// ReceptionReport returns a single reception report summarising the subscribers, with the loss and jitter
+// of the configured percentile. Cumulative loss is not meaningful across subscribers and is left zero.
+func (s RRSummary) ReceptionReport() rtcp.ReceptionReport {
+	fractionLost := s.reportedLoss * 256
+	if fractionLost > 255 {
+		fractionLost = 255
+	}
+	return rtcp.ReceptionReport{
+		SSRC:               s.SSRC,
+		FractionLost:       uint8(fractionLost),
+		LastSequenceNumber: s.HighestSeqNum,
+		Jitter:             uint32(s.reportedJitter),
+	}
+}
+
+type subscriberReport struct {
+	fractionLost float64
+	jitter       float64
+	rtt          time.Duration
+	lastSeqNum   uint32
+	at           time.Time
+}
+
+// RRAggregator combines the receiver reports of the subscribers of a publisher into summary statistics
+// per upstream SSRC, so that a publisher with thousands of subscribers receives one report per interval
+// instead of every subscriber's. Only the latest report of each subscriber counts.
+//
+// Subscriber reports carry the SSRC of the down track, callers map it to the upstream SSRC before Add.
+type RRAggregator struct {
+	params RRAggregatorParams
+
+	lock    sync.Mutex
+	reports map[uint32]map[string]subscriberReport
+}
+
+func NewRRAggregator(params RRAggregatorParams) *RRAggregator {
+	if params.MaxAge == 0 {
+		params.MaxAge = RRAggregatorParamsDefault.MaxAge
+	}
+	if params.ReportPercentile == 0 {
+		params.ReportPercentile = RRAggregatorParamsDefault.ReportPercentile
+	}
+	return &RRAggregator{
+		params:  params,
+		reports: make(map[uint32]map[string]subscriberReport),
+	}
+}
+
+// Add records the reception report of subscriberID for upstream SSRC ssrc, replacing its earlier one.
+// rtt is the round trip time to the subscriber, 0 if not known.
+func (a *RRAggregator) Add(ssrc uint32, subscriberID string, report rtcp.ReceptionReport, rtt time.Duration) {
+	a.addAt(ssrc, subscriberID, report, rtt, time.Now())
+}
+
+func (a *RRAggregator) addAt(ssrc uint32, subscriberID string, report rtcp.ReceptionReport, rtt time.Duration, at time.Time) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	subscribers := a.reports[ssrc]
+	if subscribers == nil {
+		subscribers = make(map[string]subscriberReport)
+		a.reports[ssrc] = subscribers
+	}
+	subscribers[subscriberID] = subscriberReport{
+		fractionLost: float64(report.FractionLost) / 256,
+		jitter:       float64(report.Jitter),
+		rtt:          rtt,
+		lastSeqNum:   report.LastSequenceNumber,
+		at:           at,
+	}
+}
+
+// RemoveSubscriber drops the reports of subscriberID, for example when it unsubscribes.
+func (a *RRAggregator) RemoveSubscriber(subscriberID string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for ssrc, subscribers := range a.reports {
+		delete(subscribers, subscriberID)
+		if len(subscribers) == 0 {
+			delete(a.reports, ssrc)
+		}
+	}
+}
+
+// RemoveSSRC drops the reports for ssrc, for example when the published track is removed.
+func (a *RRAggregator) RemoveSSRC(ssrc uint32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	delete(a.reports, ssrc)
+}
+
+// Summary returns the summary of the current reports for ssrc, false if there are none.
+func (a *RRAggregator) Summary(ssrc uint32) (RRSummary, bool) {
+	return a.summaryAt(ssrc, time.Now())
+}
+
+func (a *RRAggregator) summaryAt(ssrc uint32, now time.Time) (RRSummary, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.summaryLocked(ssrc, now)
+}
+
+// Summaries returns the summaries of all SSRCs with current reports, for the periodic report upstream.
+func (a *RRAggregator) Summaries() []RRSummary {
+	return a.summariesAt(time.Now())
+}
+
+func (a *RRAggregator) summariesAt(now time.Time) []RRSummary {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	summaries := make([]RRSummary, 0, len(a.reports))
+	for ssrc := range a.reports {
+		if summary, ok := a.summaryLocked(ssrc, now); ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].SSRC < summaries[j].SSRC })
+	return summaries
+}
+
+// ReceiverReport returns a receiver report from senderSSRC with a reception report per upstream SSRC,
+// nil if there are no current reports. Callers with more than 31 SSRCs split it with a Splitter.
+func (a *RRAggregator) ReceiverReport(senderSSRC uint32) *rtcp.ReceiverReport {
+	summaries := a.Summaries()
+	if len(summaries) == 0 {
+		return nil
+	}
+	rr := &rtcp.ReceiverReport{
+		SSRC:    senderSSRC,
+		Reports: make([]rtcp.ReceptionReport, 0, len(summaries)),
+	}
+	for _, summary := range summaries {
+		rr.Reports = append(rr.Reports, summary.ReceptionReport())
+	}
+	return rr
+}
+
+func (a *RRAggregator) summaryLocked(ssrc uint32, now time.Time) (RRSummary, bool) {
+	subscribers := a.reports[ssrc]
+	losses := make([]float64, 0, len(subscribers))
+	jitters := make([]float64, 0, len(subscribers))
+	var rtts []float64
+	summary := RRSummary{SSRC: ssrc}
+	for subscriberID, report := range subscribers {
+		if now.Sub(report.at) > a.params.MaxAge {
+			delete(subscribers, subscriberID)
+			continue
+		}
+		losses = append(losses, report.fractionLost)
+		jitters = append(jitters, report.jitter)
+		if report.rtt > 0 {
+			rtts = append(rtts, report.rtt.Seconds())
+		}
+		if report.lastSeqNum > summary.HighestSeqNum {
+			summary.HighestSeqNum = report.lastSeqNum
+		}
+		if report.at.After(summary.ReportedAt) {
+			summary.ReportedAt = report.at
+		}
+	}
+	if len(subscribers) == 0 {
+		delete(a.reports, ssrc)
+	}
+	if len(losses) == 0 {
+		return RRSummary{}, false
+	}
+
+	summary.NumSubscribers = len(losses)
+	summary.FractionLost = distributionOf(losses)
+	summary.Jitter = distributionOf(jitters)
+	summary.NumRTT = len(rtts)
+	summary.RTT = distributionOf(rtts)
+	summary.reportedLoss = percentile(losses, a.params.ReportPercentile)
+	summary.reportedJitter = percentile(jitters, a.params.ReportPercentile)
+	return summary, true
+}
+
+// ------------------------------------------------
+
+// distributionOf sorts values in place
+func distributionOf(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sort.Float64s(values)
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return Distribution{
+		Min:  values[0],
+		Mean: sum / float64(len(values)),
+		P50:  percentile(values, 50),
+		P90:  percentile(values, 90),
+		P99:  percentile(values, 99),
+		Max:  values[len(values)-1],
+	}
+}
+
+// percentile returns the nearest rank percentile p of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
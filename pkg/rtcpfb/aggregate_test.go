@@ -0,0 +1,94 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRRAggregator(t *testing.T) {
+	a := NewRRAggregator(RRAggregatorParams{})
+	now := time.Now()
+
+	// 100 subscribers, loss i/256 and jitter i, rtt known for even ones
+	for i := 1; i <= 100; i++ {
+		var rtt time.Duration
+		if i%2 == 0 {
+			rtt = time.Duration(i) * time.Millisecond
+		}
+		a.addAt(1, fmt.Sprintf("sub%d", i), rtcp.ReceptionReport{
+			SSRC:               uint32(1000 + i),
+			FractionLost:       uint8(i),
+			Jitter:             uint32(i),
+			LastSequenceNumber: uint32(5000 + i),
+		}, rtt, now)
+	}
+	// later report replaces earlier one
+	a.addAt(1, "sub1", rtcp.ReceptionReport{FractionLost: 1, Jitter: 1, LastSequenceNumber: 5001}, 0, now)
+
+	summary, ok := a.summaryAt(1, now)
+	require.True(t, ok)
+	require.Equal(t, 100, summary.NumSubscribers)
+	require.Equal(t, 50, summary.NumRTT)
+	require.Equal(t, uint32(5100), summary.HighestSeqNum)
+	require.Equal(t, Distribution{Min: 1, Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}, summary.Jitter)
+	require.InDelta(t, 90.0/256, summary.FractionLost.P90, 1e-9)
+	require.InDelta(t, 0.002, summary.RTT.Min, 1e-9)
+	require.InDelta(t, 0.1, summary.RTT.Max, 1e-9)
+
+	report := summary.ReceptionReport()
+	require.Equal(t, uint32(1), report.SSRC)
+	require.Equal(t, uint8(90), report.FractionLost)
+	require.Equal(t, uint32(90), report.Jitter)
+
+	_, ok = a.summaryAt(2, now)
+	require.False(t, ok)
+
+	// stale reports age out
+	a.addAt(2, "sub1", rtcp.ReceptionReport{Jitter: 7}, 0, now.Add(4*time.Second))
+	summaries := a.summariesAt(now.Add(6 * time.Second))
+	require.Len(t, summaries, 1)
+	require.Equal(t, uint32(2), summaries[0].SSRC)
+	require.Equal(t, 1, summaries[0].NumSubscribers)
+	require.Equal(t, uint32(7), summaries[0].ReceptionReport().Jitter)
+	_, ok = a.summaryAt(1, now)
+	require.False(t, ok)
+
+	a.RemoveSubscriber("sub1")
+	require.Nil(t, a.ReceiverReport(10))
+}
+
+func TestRRAggregatorReceiverReport(t *testing.T) {
+	a := NewRRAggregator(RRAggregatorParams{ReportPercentile: 100})
+	a.Add(2, "sub1", rtcp.ReceptionReport{FractionLost: 10}, 0)
+	a.Add(2, "sub2", rtcp.ReceptionReport{FractionLost: 255}, 0)
+	a.Add(1, "sub1", rtcp.ReceptionReport{FractionLost: 3}, 0)
+
+	rr := a.ReceiverReport(10)
+	require.NotNil(t, rr)
+	require.Equal(t, uint32(10), rr.SSRC)
+	require.Len(t, rr.Reports, 2)
+	require.Equal(t, uint32(1), rr.Reports[0].SSRC)
+	require.Equal(t, uint8(3), rr.Reports[0].FractionLost)
+	require.Equal(t, uint8(255), rr.Reports[1].FractionLost)
+
+	a.RemoveSSRC(2)
+	require.Len(t, a.Summaries(), 1)
+}
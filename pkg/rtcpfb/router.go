@@ -0,0 +1,250 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pion/rtcp"
+)
+
+type Kind int
+
+const (
+	KindOther Kind = iota
+	KindSenderReport
+	KindReceiverReport
+	KindSourceDescription
+	KindGoodbye
+	KindNACK
+	KindPLI
+	KindFIR
+	KindREMB
+	KindTWCC
+	KindXR
+
+	numKinds
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindOther:
+		return "OTHER"
+	case KindSenderReport:
+		return "SR"
+	case KindReceiverReport:
+		return "RR"
+	case KindSourceDescription:
+		return "SDES"
+	case KindGoodbye:
+		return "BYE"
+	case KindNACK:
+		return "NACK"
+	case KindPLI:
+		return "PLI"
+	case KindFIR:
+		return "FIR"
+	case KindREMB:
+		return "REMB"
+	case KindTWCC:
+		return "TWCC"
+	case KindXR:
+		return "XR"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+// KindOf classifies an unmarshalled RTCP packet.
+func KindOf(pkt rtcp.Packet) Kind {
+	switch pkt.(type) {
+	case *rtcp.SenderReport:
+		return KindSenderReport
+	case *rtcp.ReceiverReport:
+		return KindReceiverReport
+	case *rtcp.SourceDescription:
+		return KindSourceDescription
+	case *rtcp.Goodbye:
+		return KindGoodbye
+	case *rtcp.TransportLayerNack:
+		return KindNACK
+	case *rtcp.PictureLossIndication:
+		return KindPLI
+	case *rtcp.FullIntraRequest:
+		return KindFIR
+	case *rtcp.ReceiverEstimatedMaximumBitrate:
+		return KindREMB
+	case *rtcp.TransportLayerCC:
+		return KindTWCC
+	case *rtcp.ExtendedReport:
+		return KindXR
+	default:
+		return KindOther
+	}
+}
+
+type Handler func(pkt rtcp.Packet)
+
+type KindStats struct {
+	NumPackets uint64
+	// packets no handler was registered for
+	NumUnhandled uint64
+}
+
+type RouterStats struct {
+	ByKind map[Kind]KindStats
+}
+
+// Router dispatches incoming RTCP packets to handlers registered per kind and destination SSRC,
+// as reported by rtcp.Packet.DestinationSSRC, e.g. the media SSRC of a NACK or PLI.
+// A packet for several SSRCs reaches the handler of each, once. Handlers registered with
+// RegisterAll receive every packet of their kind, for connection level feedback like REMB and TWCC.
+//
+// Handlers are called synchronously from Route, outside of the router lock.
+type Router struct {
+	lock     sync.RWMutex
+	handlers map[uint32]*[numKinds]Handler
+	all      [numKinds]Handler
+
+	stats [numKinds]kindCounters
+}
+
+type kindCounters struct {
+	numPackets   atomic.Uint64
+	numUnhandled atomic.Uint64
+}
+
+func NewRouter() *Router {
+	return &Router{
+		handlers: make(map[uint32]*[numKinds]Handler),
+	}
+}
+
+// Register sets the handler for packets of a kind for ssrc, replacing an earlier one, nil removes it.
+func (r *Router) Register(kind Kind, ssrc uint32, h Handler) {
+	if kind < 0 || kind >= numKinds {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	handlers := r.handlers[ssrc]
+	if handlers == nil {
+		if h == nil {
+			return
+		}
+		handlers = &[numKinds]Handler{}
+		r.handlers[ssrc] = handlers
+	}
+	handlers[kind] = h
+}
+
+// RegisterAll sets the handler for all packets of a kind, nil removes it.
+func (r *Router) RegisterAll(kind Kind, h Handler) {
+	if kind < 0 || kind >= numKinds {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.all[kind] = h
+}
+
+// Unregister removes all handlers of ssrc, for example when its track is removed.
+func (r *Router) Unregister(ssrc uint32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.handlers, ssrc)
+}
+
+// RouteRaw unmarshals a (compound) RTCP packet and routes it.
+func (r *Router) RouteRaw(data []byte) error {
+	pkts, err := rtcp.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	r.Route(pkts)
+	return nil
+}
+
+func (r *Router) Route(pkts []rtcp.Packet) {
+	var handlers []Handler
+	for _, pkt := range pkts {
+		handlers = r.handlersFor(pkt, handlers[:0])
+		for _, h := range handlers {
+			h(pkt)
+		}
+	}
+}
+
+func (r *Router) Stats() RouterStats {
+	stats := RouterStats{
+		ByKind: make(map[Kind]KindStats, numKinds),
+	}
+	for kind := Kind(0); kind < numKinds; kind++ {
+		numPackets := r.stats[kind].numPackets.Load()
+		if numPackets == 0 {
+			continue
+		}
+		stats.ByKind[kind] = KindStats{
+			NumPackets:   numPackets,
+			NumUnhandled: r.stats[kind].numUnhandled.Load(),
+		}
+	}
+	return stats
+}
+
+func (r *Router) handlersFor(pkt rtcp.Packet, handlers []Handler) []Handler {
+	kind := KindOf(pkt)
+	r.stats[kind].numPackets.Add(1)
+
+	ssrcs := pkt.DestinationSSRC()
+
+	r.lock.RLock()
+	for i, ssrc := range ssrcs {
+		if isDuplicate(ssrcs[:i], ssrc) {
+			continue
+		}
+		if ssrcHandlers := r.handlers[ssrc]; ssrcHandlers != nil && ssrcHandlers[kind] != nil {
+			handlers = append(handlers, ssrcHandlers[kind])
+		}
+	}
+	if r.all[kind] != nil {
+		handlers = append(handlers, r.all[kind])
+	}
+	r.lock.RUnlock()
+
+	if len(handlers) == 0 {
+		r.stats[kind].numUnhandled.Add(1)
+	}
+	return handlers
+}
+
+// ------------------------------------------------
+
+// destination SSRC lists are short, a scan beats allocating a set
+func isDuplicate(ssrcs []uint32, ssrc uint32) bool {
+	for _, s := range ssrcs {
+		if s == ssrc {
+			return true
+		}
+	}
+	return false
+}
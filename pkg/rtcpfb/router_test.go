@@ -0,0 +1,90 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+
+	var nacks, plis, firs []uint32
+	var rembs int
+	r.Register(KindNACK, 1, func(pkt rtcp.Packet) {
+		nacks = append(nacks, pkt.(*rtcp.TransportLayerNack).MediaSSRC)
+	})
+	r.Register(KindPLI, 2, func(pkt rtcp.Packet) {
+		plis = append(plis, pkt.(*rtcp.PictureLossIndication).MediaSSRC)
+	})
+	for _, ssrc := range []uint32{1, 2} {
+		ssrc := ssrc
+		r.Register(KindFIR, ssrc, func(pkt rtcp.Packet) {
+			firs = append(firs, ssrc)
+		})
+	}
+	r.RegisterAll(KindREMB, func(pkt rtcp.Packet) {
+		rembs++
+	})
+
+	compound, err := rtcp.Marshal([]rtcp.Packet{
+		&rtcp.ReceiverReport{SSRC: 100},
+		&rtcp.TransportLayerNack{MediaSSRC: 1, Nacks: []rtcp.NackPair{{PacketID: 10}}},
+		&rtcp.TransportLayerNack{MediaSSRC: 3, Nacks: []rtcp.NackPair{{PacketID: 10}}},
+		&rtcp.PictureLossIndication{MediaSSRC: 2},
+		&rtcp.PictureLossIndication{MediaSSRC: 1},
+		// two entries for the same SSRC reach its handler once
+		&rtcp.FullIntraRequest{MediaSSRC: 1, FIR: []rtcp.FIREntry{{SSRC: 1}, {SSRC: 2}, {SSRC: 1}}},
+		&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 1e6, SSRCs: []uint32{1, 2}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, r.RouteRaw(compound))
+
+	require.Equal(t, []uint32{1}, nacks)
+	require.Equal(t, []uint32{2}, plis)
+	require.Equal(t, []uint32{1, 2}, firs)
+	require.Equal(t, 1, rembs)
+
+	stats := r.Stats()
+	require.Equal(t, KindStats{NumPackets: 1, NumUnhandled: 1}, stats.ByKind[KindReceiverReport])
+	require.Equal(t, KindStats{NumPackets: 2, NumUnhandled: 1}, stats.ByKind[KindNACK])
+	require.Equal(t, KindStats{NumPackets: 2, NumUnhandled: 1}, stats.ByKind[KindPLI])
+	require.Equal(t, KindStats{NumPackets: 1}, stats.ByKind[KindFIR])
+	require.Equal(t, KindStats{NumPackets: 1}, stats.ByKind[KindREMB])
+	_, ok := stats.ByKind[KindTWCC]
+	require.False(t, ok)
+
+	// removed handlers
+	r.Unregister(1)
+	r.Register(KindPLI, 2, nil)
+	r.Route([]rtcp.Packet{
+		&rtcp.TransportLayerNack{MediaSSRC: 1},
+		&rtcp.PictureLossIndication{MediaSSRC: 2},
+	})
+	require.Equal(t, []uint32{1}, nacks)
+	require.Equal(t, []uint32{2}, plis)
+
+	require.Error(t, r.RouteRaw([]byte{1, 2}))
+}
+
+func TestKindOf(t *testing.T) {
+	require.Equal(t, KindTWCC, KindOf(&rtcp.TransportLayerCC{}))
+	require.Equal(t, KindXR, KindOf(&rtcp.ExtendedReport{}))
+	require.Equal(t, KindOther, KindOf(&rtcp.SliceLossIndication{}))
+	require.Equal(t, "NACK", KindNACK.String())
+}
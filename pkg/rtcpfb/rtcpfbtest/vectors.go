@@ -0,0 +1,235 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rtcpfbtest provides a corpus of RTCP feedback packets (TWCC, REMB, NACK, XR) with golden parsed forms,
+// for validating parser and writer changes in this module and in downstream packages against known bytes.
+//
+// Most vectors are synthetic, written with pion rtcp, and only guard against regressions of the parser and
+// writer; they do not catch interoperability bugs with browsers. The only captures are a REMB from Chrome and
+// a compound packet dump, both taken from the pion rtcp tests. There are no browser captures of TWCC, NACK or XR yet.
+//
+// Vectors are the vectors/<format>_<name>.hex files, hex with optional whitespace, preceded by # comment lines
+// describing the packet and a "# source:" line naming where the bytes come from, "synthetic" for written
+// packets, or the browser and version for captures, e.g. "# source: Chrome 120, screen share over lossy Wi-Fi".
+// The golden parsed form is in vectors/<format>_<name>.golden, regenerated with go test -update. Captures that
+// a conforming writer does not reproduce byte for byte carry a "# parse only:" line giving the reason and are
+// skipped by CheckWrite.
+package rtcpfbtest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pion/rtcp"
+)
+
+type Format string
+
+const (
+	FormatTWCC     Format = "twcc"
+	FormatREMB     Format = "remb"
+	FormatNACK     Format = "nack"
+	FormatXR       Format = "xr"
+	FormatCompound Format = "compound"
+)
+
+const (
+	vectorsDir      = "vectors"
+	vectorExt       = ".hex"
+	goldenExt       = ".golden"
+	sourcePrefix    = "# source:"
+	parseOnlyPrefix = "# parse only:"
+	commentPrefix   = "#"
+)
+
+//go:embed vectors
+var vectorsFS embed.FS
+
+type Vector struct {
+	Name        string
+	Format      Format
+	Description string
+	Source      string
+	// reason the bytes are not reproduced by writers, empty if they are
+	ParseOnly string
+	Data      []byte
+	// parsed form, empty for a vector without golden file
+	Golden []byte
+}
+
+// Vectors returns the corpus sorted by name, of the given formats or all of them if none are given.
+func Vectors(formats ...Format) ([]Vector, error) {
+	entries, err := vectorsFS.ReadDir(vectorsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var vectors []Vector
+	for _, entry := range entries {
+		if path.Ext(entry.Name()) != vectorExt {
+			continue
+		}
+		v, err := readVector(strings.TrimSuffix(entry.Name(), vectorExt))
+		if err != nil {
+			return nil, err
+		}
+		if len(formats) != 0 && !hasFormat(formats, v.Format) {
+			continue
+		}
+		vectors = append(vectors, v)
+	}
+	sort.Slice(vectors, func(i, j int) bool {
+		return vectors[i].Name < vectors[j].Name
+	})
+	return vectors, nil
+}
+
+// Load returns the vectors of the given formats, failing the test if the corpus cannot be read.
+func Load(t testing.TB, formats ...Format) []Vector {
+	t.Helper()
+
+	vectors, err := Vectors(formats...)
+	if err != nil {
+		t.Fatalf("loading vectors: %v", err)
+	}
+	if len(vectors) == 0 {
+		t.Fatalf("no vectors of formats %v", formats)
+	}
+	return vectors
+}
+
+// Golden returns the parsed form of packets compared to golden files, indented JSON of the packets and their types.
+func Golden(pkts []rtcp.Packet) ([]byte, error) {
+	type goldenPacket struct {
+		Type   string      `json:"type"`
+		Packet interface{} `json:"packet"`
+	}
+
+	golden := make([]goldenPacket, 0, len(pkts))
+	for _, pkt := range pkts {
+		golden = append(golden, goldenPacket{Type: fmt.Sprintf("%T", pkt), Packet: pkt})
+	}
+	b, err := json.MarshalIndent(golden, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// Check parses every vector with parse and fails the test if the result differs from the golden form.
+// Use rtcp.Unmarshal to validate the parser this module relies on.
+func Check(t *testing.T, vectors []Vector, parse func(data []byte) ([]rtcp.Packet, error)) {
+	t.Helper()
+
+	for _, v := range vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			pkts, err := parse(append([]byte{}, v.Data...))
+			if err != nil {
+				t.Fatalf("parse (source: %s): %v", v.Source, err)
+			}
+			got, err := Golden(pkts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(v.Golden) == 0 {
+				t.Fatalf("no golden file, run go test -update in rtcpfbtest")
+			}
+			if !bytes.Equal(got, v.Golden) {
+				t.Fatalf("parsed form differs from golden (source: %s)\ngot:\n%s\nwant:\n%s", v.Source, got, v.Golden)
+			}
+		})
+	}
+}
+
+// CheckWrite parses every vector with rtcp.Unmarshal, writes the packets with marshal and fails the test
+// if the bytes differ from the vector. Parse only vectors are skipped.
+func CheckWrite(t *testing.T, vectors []Vector, marshal func(pkts []rtcp.Packet) ([]byte, error)) {
+	t.Helper()
+
+	for _, v := range vectors {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			if v.ParseOnly != "" {
+				t.Skipf("parse only: %s", v.ParseOnly)
+			}
+			pkts, err := rtcp.Unmarshal(v.Data)
+			if err != nil {
+				t.Fatalf("unmarshal (source: %s): %v", v.Source, err)
+			}
+			got, err := marshal(pkts)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if !bytes.Equal(got, v.Data) {
+				t.Fatalf("written bytes differ (source: %s)\ngot:  %x\nwant: %x", v.Source, got, v.Data)
+			}
+		})
+	}
+}
+
+func readVector(name string) (Vector, error) {
+	raw, err := vectorsFS.ReadFile(path.Join(vectorsDir, name+vectorExt))
+	if err != nil {
+		return Vector{}, err
+	}
+
+	v := Vector{Name: name}
+	if i := strings.IndexByte(name, '_'); i > 0 {
+		v.Format = Format(name[:i])
+	}
+
+	var description []string
+	var data strings.Builder
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, sourcePrefix):
+			v.Source = strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix))
+		case strings.HasPrefix(line, parseOnlyPrefix):
+			v.ParseOnly = strings.TrimSpace(strings.TrimPrefix(line, parseOnlyPrefix))
+		case strings.HasPrefix(line, commentPrefix):
+			description = append(description, strings.TrimSpace(strings.TrimPrefix(line, commentPrefix)))
+		default:
+			data.WriteString(strings.Join(strings.Fields(line), ""))
+		}
+	}
+	v.Description = strings.Join(description, " ")
+	if v.Data, err = hex.DecodeString(data.String()); err != nil {
+		return Vector{}, fmt.Errorf("vector %s: %w", name, err)
+	}
+	if v.Source == "" {
+		return Vector{}, fmt.Errorf("vector %s: no source", name)
+	}
+
+	// golden files are optional until generated
+	v.Golden, _ = vectorsFS.ReadFile(path.Join(vectorsDir, name+goldenExt))
+	return v, nil
+}
+
+func hasFormat(formats []Format, format Format) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
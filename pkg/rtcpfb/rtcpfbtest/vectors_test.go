@@ -0,0 +1,50 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfbtest
+
+import (
+	"flag"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "regenerate golden files")
+
+func TestVectors(t *testing.T) {
+	vectors := Load(t)
+	for _, v := range vectors {
+		require.NotEmpty(t, v.Format, v.Name)
+		require.NotEmpty(t, v.Description, v.Name)
+	}
+	require.Len(t, Load(t, FormatREMB, FormatNACK), 3)
+
+	if *update {
+		for _, v := range vectors {
+			pkts, err := rtcp.Unmarshal(v.Data)
+			require.NoError(t, err, v.Name)
+			golden, err := Golden(pkts)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path.Join(vectorsDir, v.Name+goldenExt), golden, 0o644))
+		}
+		t.Skip("golden files regenerated, run again to check")
+	}
+
+	Check(t, vectors, rtcp.Unmarshal)
+	CheckWrite(t, vectors, rtcp.Marshal)
+}
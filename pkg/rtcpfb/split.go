@@ -0,0 +1,398 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+)
+
+const (
+	rtcpHeaderLength = 4
+	nackFixedLength  = 12
+	nackPairLength   = 4
+
+	twccReferenceTimeUnitUs = 64000
+	twccMaxRunLength        = 1<<13 - 1
+	twccOneBitSymbols       = 14
+	twccTwoBitSymbols       = 7
+)
+
+var (
+	ErrInvalidTWCC = errors.New("invalid transport wide congestion control feedback")
+)
+
+type SplitterParams struct {
+	// MaxSize is the largest marshalled compound packet produced, leave room for SRTCP and IP/UDP overhead
+	MaxSize int
+}
+
+var SplitterParamsDefault = SplitterParams{
+	MaxSize: 1200,
+}
+
+type SplitterStats struct {
+	NumCompounds int
+	// feedback packets that had to be split and the fragments they were split into
+	NumSplit     int
+	NumFragments int
+	// packets larger than MaxSize which could not be split, sent in a compound of their own
+	NumOversize int
+}
+
+// Splitter packs outgoing RTCP packets into compound packets of at most MaxSize bytes,
+// splitting NACK and TWCC feedback too large for a single compound into several packets
+// of the same kind. Compounds are reduced size (RFC 5506) so only the first one carries
+// a leading sender or receiver report.
+type Splitter struct {
+	params SplitterParams
+
+	lock  sync.Mutex
+	stats SplitterStats
+}
+
+func NewSplitter(params SplitterParams) *Splitter {
+	if params.MaxSize == 0 {
+		params.MaxSize = SplitterParamsDefault.MaxSize
+	}
+	return &Splitter{
+		params: params,
+	}
+}
+
+// Split groups pkts, in order, into compounds that each marshal to at most MaxSize bytes.
+func (s *Splitter) Split(pkts []rtcp.Packet) [][]rtcp.Packet {
+	var stats SplitterStats
+
+	var compounds [][]rtcp.Packet
+	var current []rtcp.Packet
+	currentSize := 0
+	add := func(pkt rtcp.Packet) {
+		size := pkt.MarshalSize()
+		if len(current) != 0 && currentSize+size > s.params.MaxSize {
+			compounds = append(compounds, current)
+			current = nil
+			currentSize = 0
+		}
+		if size > s.params.MaxSize {
+			stats.NumOversize++
+		}
+		current = append(current, pkt)
+		currentSize += size
+	}
+
+	for _, pkt := range pkts {
+		if pkt.MarshalSize() <= s.params.MaxSize {
+			add(pkt)
+			continue
+		}
+
+		var fragments []rtcp.Packet
+		switch p := pkt.(type) {
+		case *rtcp.TransportLayerNack:
+			fragments = splitNACK(p, s.params.MaxSize)
+		case *rtcp.TransportLayerCC:
+			fragments = splitTWCC(p, s.params.MaxSize)
+		}
+		if len(fragments) > 1 {
+			stats.NumSplit++
+			stats.NumFragments += len(fragments)
+		} else {
+			fragments = []rtcp.Packet{pkt}
+		}
+		for _, fragment := range fragments {
+			add(fragment)
+		}
+	}
+	if len(current) != 0 {
+		compounds = append(compounds, current)
+	}
+	stats.NumCompounds = len(compounds)
+
+	s.lock.Lock()
+	s.stats.NumCompounds += stats.NumCompounds
+	s.stats.NumSplit += stats.NumSplit
+	s.stats.NumFragments += stats.NumFragments
+	s.stats.NumOversize += stats.NumOversize
+	s.lock.Unlock()
+
+	return compounds
+}
+
+// SplitMarshal splits pkts and marshals each compound.
+func (s *Splitter) SplitMarshal(pkts []rtcp.Packet) ([][]byte, error) {
+	compounds := s.Split(pkts)
+	raw := make([][]byte, 0, len(compounds))
+	for _, compound := range compounds {
+		b, err := rtcp.Marshal(compound)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, b)
+	}
+	return raw, nil
+}
+
+func (s *Splitter) Stats() SplitterStats {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.stats
+}
+
+// ------------------------------------------------
+
+func splitNACK(nack *rtcp.TransportLayerNack, maxSize int) []rtcp.Packet {
+	pairsPerPacket := (maxSize - nackFixedLength) / nackPairLength
+	if pairsPerPacket <= 0 {
+		return nil
+	}
+
+	var fragments []rtcp.Packet
+	for start := 0; start < len(nack.Nacks); start += pairsPerPacket {
+		end := start + pairsPerPacket
+		if end > len(nack.Nacks) {
+			end = len(nack.Nacks)
+		}
+		fragments = append(fragments, &rtcp.TransportLayerNack{
+			SenderSSRC: nack.SenderSSRC,
+			MediaSSRC:  nack.MediaSSRC,
+			Nacks:      nack.Nacks[start:end],
+		})
+	}
+	return fragments
+}
+
+// ------------------------------------------------
+
+type twccStatus struct {
+	received bool
+	// arrival time in microseconds, relative to reference time zero
+	arrivalUs int64
+}
+
+// splitTWCC re-encodes transport wide congestion control feedback into packets covering
+// consecutive ranges of the original sequence numbers. Every fragment gets its own reference
+// time and keeps the feedback packet count of the original.
+func splitTWCC(twcc *rtcp.TransportLayerCC, maxSize int) []rtcp.Packet {
+	statuses, ok := decodeTWCC(twcc)
+	if !ok || len(statuses) == 0 {
+		return nil
+	}
+
+	var fragments []rtcp.Packet
+	for start := 0; start < len(statuses); {
+		// largest fragment which fits, size grows with the number of packets covered
+		lo, hi := start+1, len(statuses)
+		var best *rtcp.TransportLayerCC
+		for lo <= hi {
+			mid := (lo + hi) / 2
+			fragment := encodeTWCC(twcc, uint16(start), statuses[start:mid])
+			if fragment != nil && fragment.MarshalSize() <= maxSize {
+				best = fragment
+				lo = mid + 1
+			} else {
+				hi = mid - 1
+			}
+		}
+		if best == nil {
+			return nil
+		}
+		fragments = append(fragments, best)
+		start += int(best.PacketStatusCount)
+	}
+	return fragments
+}
+
+// TWCCPacketStatus is the reported status of one transport wide sequence number.
+type TWCCPacketStatus struct {
+	SequenceNumber uint16
+	Received       bool
+	// remote arrival time relative to reference time zero, set for received packets
+	Arrival time.Duration
+}
+
+// ParseTWCC expands the status chunks and receive deltas of transport wide congestion control feedback.
+func ParseTWCC(twcc *rtcp.TransportLayerCC) ([]TWCCPacketStatus, error) {
+	statuses, ok := decodeTWCC(twcc)
+	if !ok {
+		return nil, ErrInvalidTWCC
+	}
+
+	packets := make([]TWCCPacketStatus, 0, len(statuses))
+	for i, status := range statuses {
+		packets = append(packets, TWCCPacketStatus{
+			SequenceNumber: twcc.BaseSequenceNumber + uint16(i),
+			Received:       status.received,
+			Arrival:        time.Duration(status.arrivalUs) * time.Microsecond,
+		})
+	}
+	return packets, nil
+}
+
+func decodeTWCC(twcc *rtcp.TransportLayerCC) ([]twccStatus, bool) {
+	statuses := make([]twccStatus, 0, twcc.PacketStatusCount)
+	addSymbol := func(symbol uint16) {
+		if len(statuses) < int(twcc.PacketStatusCount) {
+			statuses = append(statuses, twccStatus{
+				received: symbol == rtcp.TypeTCCPacketReceivedSmallDelta || symbol == rtcp.TypeTCCPacketReceivedLargeDelta,
+			})
+		}
+	}
+	for _, chunk := range twcc.PacketChunks {
+		switch c := chunk.(type) {
+		case *rtcp.RunLengthChunk:
+			for i := uint16(0); i < c.RunLength; i++ {
+				addSymbol(c.PacketStatusSymbol)
+			}
+		case *rtcp.StatusVectorChunk:
+			for _, symbol := range c.SymbolList {
+				addSymbol(symbol)
+			}
+		default:
+			return nil, false
+		}
+	}
+	if len(statuses) != int(twcc.PacketStatusCount) {
+		return nil, false
+	}
+
+	arrivalUs := int64(twcc.ReferenceTime) * twccReferenceTimeUnitUs
+	deltaIdx := 0
+	for i := range statuses {
+		if !statuses[i].received {
+			continue
+		}
+		if deltaIdx >= len(twcc.RecvDeltas) {
+			return nil, false
+		}
+		arrivalUs += twcc.RecvDeltas[deltaIdx].Delta
+		statuses[i].arrivalUs = arrivalUs
+		deltaIdx++
+	}
+	return statuses, true
+}
+
+// encodeTWCC returns nil if a delta between consecutive received packets does not fit the wire format.
+func encodeTWCC(twcc *rtcp.TransportLayerCC, offset uint16, statuses []twccStatus) *rtcp.TransportLayerCC {
+	fragment := &rtcp.TransportLayerCC{
+		SenderSSRC:         twcc.SenderSSRC,
+		MediaSSRC:          twcc.MediaSSRC,
+		BaseSequenceNumber: twcc.BaseSequenceNumber + offset,
+		PacketStatusCount:  uint16(len(statuses)),
+		FbPktCount:         twcc.FbPktCount,
+	}
+
+	symbols := make([]uint16, len(statuses))
+	var prevUs int64
+	first := true
+	for i, status := range statuses {
+		if !status.received {
+			symbols[i] = rtcp.TypeTCCPacketNotReceived
+			continue
+		}
+		if first {
+			referenceTime := status.arrivalUs / twccReferenceTimeUnitUs
+			if status.arrivalUs < 0 && status.arrivalUs%twccReferenceTimeUnitUs != 0 {
+				referenceTime--
+			}
+			fragment.ReferenceTime = uint32(referenceTime) & 0xffffff
+			prevUs = referenceTime * twccReferenceTimeUnitUs
+			first = false
+		}
+
+		delta := (status.arrivalUs - prevUs) / rtcp.TypeTCCDeltaScaleFactor
+		switch {
+		case delta >= 0 && delta <= math.MaxUint8:
+			symbols[i] = rtcp.TypeTCCPacketReceivedSmallDelta
+		case delta >= math.MinInt16 && delta <= math.MaxInt16:
+			symbols[i] = rtcp.TypeTCCPacketReceivedLargeDelta
+		default:
+			return nil
+		}
+		fragment.RecvDeltas = append(fragment.RecvDeltas, &rtcp.RecvDelta{
+			Type:  symbols[i],
+			Delta: status.arrivalUs - prevUs,
+		})
+		prevUs = status.arrivalUs
+	}
+	fragment.PacketChunks = encodeTWCCChunks(symbols)
+
+	size := fragment.MarshalSize()
+	fragment.Header = rtcp.Header{
+		Padding: hasTWCCPadding(fragment),
+		Count:   rtcp.FormatTCC,
+		Type:    rtcp.TypeTransportSpecificFeedback,
+		Length:  uint16(size/4 - 1),
+	}
+	return fragment
+}
+
+func hasTWCCPadding(twcc *rtcp.TransportLayerCC) bool {
+	n := rtcpHeaderLength + 16 + 2*len(twcc.PacketChunks)
+	for _, delta := range twcc.RecvDeltas {
+		if delta.Type == rtcp.TypeTCCPacketReceivedSmallDelta {
+			n++
+		} else {
+			n += 2
+		}
+	}
+	return n%4 != 0
+}
+
+// encodeTWCCChunks uses run length chunks for runs of equal symbols and status vectors otherwise,
+// one bit vectors when there are no large deltas in the next symbols.
+func encodeTWCCChunks(symbols []uint16) []rtcp.PacketStatusChunk {
+	var chunks []rtcp.PacketStatusChunk
+	for i := 0; i < len(symbols); {
+		run := 1
+		for i+run < len(symbols) && symbols[i+run] == symbols[i] && run < twccMaxRunLength {
+			run++
+		}
+		if run >= twccTwoBitSymbols || i+run == len(symbols) {
+			chunks = append(chunks, &rtcp.RunLengthChunk{
+				Type:               rtcp.TypeTCCRunLengthChunk,
+				PacketStatusSymbol: symbols[i],
+				RunLength:          uint16(run),
+			})
+			i += run
+			continue
+		}
+
+		n := twccOneBitSymbols
+		symbolSize := uint16(rtcp.TypeTCCSymbolSizeOneBit)
+		for j := i; j < i+twccOneBitSymbols && j < len(symbols); j++ {
+			if symbols[j] == rtcp.TypeTCCPacketReceivedLargeDelta {
+				n = twccTwoBitSymbols
+				symbolSize = rtcp.TypeTCCSymbolSizeTwoBit
+				break
+			}
+		}
+		if i+n > len(symbols) {
+			n = len(symbols) - i
+		}
+		chunks = append(chunks, &rtcp.StatusVectorChunk{
+			Type:       rtcp.TypeTCCStatusVectorChunk,
+			SymbolSize: symbolSize,
+			SymbolList: append([]uint16{}, symbols[i:i+n]...),
+		})
+		i += n
+	}
+	return chunks
+}
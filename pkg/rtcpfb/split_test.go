@@ -0,0 +1,214 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rtcpfb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/rtcpfb/rtcpfbtest"
+	"github.com/livekit/mediatransportutil/pkg/wire/wiretest"
+)
+
+func TestSplitNACK(t *testing.T) {
+	nack := &rtcp.TransportLayerNack{SenderSSRC: 1, MediaSSRC: 2}
+	for i := 0; i < 100; i++ {
+		nack.Nacks = append(nack.Nacks, rtcp.NackPair{PacketID: uint16(i * 20)})
+	}
+
+	s := NewSplitter(SplitterParams{MaxSize: 100})
+	raw, err := s.SplitMarshal([]rtcp.Packet{&rtcp.ReceiverReport{SSRC: 1}, nack, &rtcp.PictureLossIndication{MediaSSRC: 2}})
+	require.NoError(t, err)
+
+	var nacks []rtcp.NackPair
+	numPLI := 0
+	for _, b := range raw {
+		require.LessOrEqual(t, len(b), 100)
+		pkts, err := rtcp.Unmarshal(b)
+		require.NoError(t, err)
+		for _, pkt := range pkts {
+			switch p := pkt.(type) {
+			case *rtcp.TransportLayerNack:
+				require.Equal(t, uint32(2), p.MediaSSRC)
+				nacks = append(nacks, p.Nacks...)
+			case *rtcp.PictureLossIndication:
+				numPLI++
+			}
+		}
+	}
+	require.Equal(t, nack.Nacks, nacks)
+	require.Equal(t, 1, numPLI)
+
+	stats := s.Stats()
+	require.Equal(t, len(raw), stats.NumCompounds)
+	require.Equal(t, 1, stats.NumSplit)
+	// 22 pairs per packet
+	require.Equal(t, 5, stats.NumFragments)
+	require.Zero(t, stats.NumOversize)
+}
+
+func TestSplitTWCC(t *testing.T) {
+	// received packets with lost runs and a few large and negative deltas
+	var statuses []twccStatus
+	arrivalUs := int64(1000) * twccReferenceTimeUnitUs
+	for i := 0; i < 2000; i++ {
+		switch {
+		case i%50 >= 40:
+			statuses = append(statuses, twccStatus{})
+			continue
+		case i%97 == 0:
+			arrivalUs += 100000
+		case i%89 == 0:
+			arrivalUs -= 2000
+		default:
+			arrivalUs += 1000
+		}
+		statuses = append(statuses, twccStatus{received: true, arrivalUs: arrivalUs})
+	}
+	twcc := encodeTWCC(&rtcp.TransportLayerCC{SenderSSRC: 1, MediaSSRC: 2, BaseSequenceNumber: 65000, FbPktCount: 9}, 0, statuses)
+	require.NotNil(t, twcc)
+	require.Greater(t, twcc.MarshalSize(), 1200)
+
+	// marshalled packet unmarshals to the same statuses
+	b, err := twcc.Marshal()
+	require.NoError(t, err)
+	var parsed rtcp.TransportLayerCC
+	require.NoError(t, parsed.Unmarshal(b))
+	decoded, ok := decodeTWCC(&parsed)
+	require.True(t, ok)
+	require.Equal(t, statuses, decoded)
+
+	s := NewSplitter(SplitterParams{})
+	raw, err := s.SplitMarshal([]rtcp.Packet{twcc})
+	require.NoError(t, err)
+	require.Greater(t, len(raw), 1)
+
+	var joined []twccStatus
+	expectedSN := uint16(65000)
+	for _, b := range raw {
+		require.LessOrEqual(t, len(b), 1200)
+		pkts, err := rtcp.Unmarshal(b)
+		require.NoError(t, err)
+		require.Len(t, pkts, 1)
+
+		fragment := pkts[0].(*rtcp.TransportLayerCC)
+		require.Equal(t, expectedSN, fragment.BaseSequenceNumber)
+		require.Equal(t, uint8(9), fragment.FbPktCount)
+		expectedSN += fragment.PacketStatusCount
+
+		decoded, ok := decodeTWCC(fragment)
+		require.True(t, ok)
+		joined = append(joined, decoded...)
+	}
+	require.Equal(t, statuses, joined)
+
+	stats := s.Stats()
+	require.Equal(t, 1, stats.NumSplit)
+	require.Equal(t, len(raw), stats.NumFragments)
+}
+
+func TestParseTWCC(t *testing.T) {
+	referenceUs := int64(10) * twccReferenceTimeUnitUs
+	twcc := encodeTWCC(&rtcp.TransportLayerCC{BaseSequenceNumber: 65535}, 0, []twccStatus{
+		{received: true, arrivalUs: referenceUs + 1000},
+		{},
+		{received: true, arrivalUs: referenceUs + 500},
+	})
+	require.NotNil(t, twcc)
+
+	packets, err := ParseTWCC(twcc)
+	require.NoError(t, err)
+	require.Equal(t, []TWCCPacketStatus{
+		{SequenceNumber: 65535, Received: true, Arrival: 641 * time.Millisecond},
+		{SequenceNumber: 0},
+		{SequenceNumber: 1, Received: true, Arrival: 640500 * time.Microsecond},
+	}, packets)
+
+	twcc.PacketStatusCount++
+	_, err = ParseTWCC(twcc)
+	require.ErrorIs(t, err, ErrInvalidTWCC)
+}
+
+func TestParseTWCCVectors(t *testing.T) {
+	var corpus [][]byte
+	for _, v := range rtcpfbtest.Load(t, rtcpfbtest.FormatTWCC) {
+		corpus = append(corpus, v.Data)
+
+		pkts, err := rtcp.Unmarshal(v.Data)
+		require.NoError(t, err)
+		twcc := pkts[0].(*rtcp.TransportLayerCC)
+		packets, err := ParseTWCC(twcc)
+		require.NoError(t, err, v.Name)
+		require.Len(t, packets, int(twcc.PacketStatusCount), v.Name)
+
+		numReceived := 0
+		for i, p := range packets {
+			require.Equal(t, twcc.BaseSequenceNumber+uint16(i), p.SequenceNumber)
+			if p.Received {
+				numReceived++
+			}
+		}
+		require.Equal(t, len(twcc.RecvDeltas), numReceived, v.Name)
+
+		// re-encoding keeps the statuses, chunks may be encoded differently
+		fragments := splitTWCC(twcc, 1500)
+		require.Len(t, fragments, 1, v.Name)
+		reencoded, err := ParseTWCC(fragments[0].(*rtcp.TransportLayerCC))
+		require.NoError(t, err)
+		require.Equal(t, packets, reencoded, v.Name)
+	}
+
+	wiretest.Check(t, corpus, func(data []byte) error {
+		pkts, err := rtcp.Unmarshal(data)
+		if err != nil {
+			return err
+		}
+		for _, pkt := range pkts {
+			if twcc, ok := pkt.(*rtcp.TransportLayerCC); ok {
+				if _, err := ParseTWCC(twcc); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func TestSplitGrouping(t *testing.T) {
+	s := NewSplitter(SplitterParams{MaxSize: 40})
+
+	// 12 bytes each
+	var pkts []rtcp.Packet
+	for i := 0; i < 7; i++ {
+		pkts = append(pkts, &rtcp.PictureLossIndication{MediaSSRC: uint32(i)})
+	}
+	// cannot be split
+	pkts = append(pkts, &rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 1e6, SSRCs: make([]uint32, 10)})
+
+	compounds := s.Split(pkts)
+	require.Len(t, compounds, 4)
+	require.Len(t, compounds[0], 3)
+	require.Len(t, compounds[1], 3)
+	require.Len(t, compounds[2], 1)
+	require.Len(t, compounds[3], 1)
+
+	stats := s.Stats()
+	require.Equal(t, 4, stats.NumCompounds)
+	require.Zero(t, stats.NumSplit)
+	require.Equal(t, 1, stats.NumOversize)
+}
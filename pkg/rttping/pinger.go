@@ -0,0 +1,252 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rttping
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	messageTypePing = 1
+	messageTypePong = 2
+
+	pingSize = 1 + 4 + 8
+	pongSize = 1 + 4 + 8 + 8 + 8
+
+	// RFC 6298 smoothing factors
+	rttAlpha = 0.125
+	rttBeta  = 0.25
+)
+
+var (
+	ErrInvalidMessage = errors.New("invalid ping message")
+)
+
+type PingerParams struct {
+	// interval between pings when started
+	Interval time.Duration
+	// a ping without a pong for this long is considered lost
+	Timeout time.Duration
+}
+
+var PingerParamsDefault = PingerParams{
+	Interval: 2 * time.Second,
+	Timeout:  10 * time.Second,
+}
+
+type PingStats struct {
+	NumSamples int
+	NumLost    int
+	LastRTT    time.Duration
+	MinRTT     time.Duration
+	// smoothed RTT and RTT variation (jitter) as in RFC 6298
+	SmoothedRTT time.Duration
+	RTTVar      time.Duration
+	// estimated offset of the remote clock relative to the local clock, smoothed
+	ClockOffset time.Duration
+}
+
+// Pinger measures application level RTT and clock offset with timestamped ping/pong messages,
+// for example over an unordered, unreliable data channel. Both sides run a Pinger and
+// pass every received message to HandleMessage, pings are answered automatically.
+//
+// Message format, big endian, times are unix nanoseconds of the sender clock
+//
+//	ping: type (1) | seq (4) | send time (8)
+//	pong: type (2) | seq (4) | ping send time (8) | ping receive time (8) | pong send time (8)
+type Pinger struct {
+	params PingerParams
+	send   func(data []byte) error
+
+	lock      sync.Mutex
+	seq       uint32
+	pending   map[uint32]time.Time
+	stats     PingStats
+	isStopped bool
+
+	close chan struct{}
+}
+
+func NewPinger(params PingerParams, send func(data []byte) error) *Pinger {
+	if params.Interval <= 0 {
+		params.Interval = PingerParamsDefault.Interval
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = PingerParamsDefault.Timeout
+	}
+	return &Pinger{
+		params:  params,
+		send:    send,
+		pending: make(map[uint32]time.Time),
+		close:   make(chan struct{}),
+	}
+}
+
+func (p *Pinger) Start() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.isStopped {
+		return
+	}
+	go p.worker()
+}
+
+func (p *Pinger) Stop() {
+	p.lock.Lock()
+	if p.isStopped {
+		p.lock.Unlock()
+		return
+	}
+
+	close(p.close)
+	p.isStopped = true
+	p.lock.Unlock()
+}
+
+func (p *Pinger) Stats() PingStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.stats
+}
+
+// Ping sends a ping right away.
+func (p *Pinger) Ping() error {
+	return p.ping(time.Now())
+}
+
+func (p *Pinger) HandleMessage(data []byte) error {
+	return p.handleMessage(data, time.Now())
+}
+
+func (p *Pinger) ping(now time.Time) error {
+	p.lock.Lock()
+	p.expireLocked(now)
+	p.seq++
+	seq := p.seq
+	p.pending[seq] = now
+	p.lock.Unlock()
+
+	data := make([]byte, pingSize)
+	data[0] = messageTypePing
+	binary.BigEndian.PutUint32(data[1:], seq)
+	binary.BigEndian.PutUint64(data[5:], uint64(now.UnixNano()))
+	return p.send(data)
+}
+
+func (p *Pinger) handleMessage(data []byte, now time.Time) error {
+	if len(data) == 0 {
+		return ErrInvalidMessage
+	}
+
+	switch data[0] {
+	case messageTypePing:
+		if len(data) < pingSize {
+			return ErrInvalidMessage
+		}
+		pong := make([]byte, pongSize)
+		pong[0] = messageTypePong
+		copy(pong[1:], data[1:pingSize])
+		// answered in place, receive and send times are the same
+		binary.BigEndian.PutUint64(pong[13:], uint64(now.UnixNano()))
+		binary.BigEndian.PutUint64(pong[21:], uint64(now.UnixNano()))
+		return p.send(pong)
+
+	case messageTypePong:
+		if len(data) < pongSize {
+			return ErrInvalidMessage
+		}
+		p.handlePong(
+			binary.BigEndian.Uint32(data[1:]),
+			time.Unix(0, int64(binary.BigEndian.Uint64(data[13:]))),
+			time.Unix(0, int64(binary.BigEndian.Uint64(data[21:]))),
+			now,
+		)
+		return nil
+
+	default:
+		return ErrInvalidMessage
+	}
+}
+
+func (p *Pinger) handlePong(seq uint32, remoteReceivedAt time.Time, remoteSentAt time.Time, now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// the local send time is taken from pending state, not from the echo, so a peer cannot skew it
+	sentAt, ok := p.pending[seq]
+	if !ok {
+		return
+	}
+	delete(p.pending, seq)
+
+	processing := remoteSentAt.Sub(remoteReceivedAt)
+	if processing < 0 {
+		processing = 0
+	}
+	rtt := now.Sub(sentAt) - processing
+	if rtt < 0 {
+		rtt = 0
+	}
+	// NTP style offset, ((t2 - t1) + (t3 - t4)) / 2
+	offset := (remoteReceivedAt.Sub(sentAt) + remoteSentAt.Sub(now)) / 2
+
+	s := &p.stats
+	if s.NumSamples == 0 {
+		s.SmoothedRTT = rtt
+		s.RTTVar = rtt / 2
+		s.MinRTT = rtt
+		s.ClockOffset = offset
+	} else {
+		diff := s.SmoothedRTT - rtt
+		if diff < 0 {
+			diff = -diff
+		}
+		s.RTTVar = time.Duration((1-rttBeta)*float64(s.RTTVar) + rttBeta*float64(diff))
+		s.SmoothedRTT = time.Duration((1-rttAlpha)*float64(s.SmoothedRTT) + rttAlpha*float64(rtt))
+		s.ClockOffset = time.Duration((1-rttAlpha)*float64(s.ClockOffset) + rttAlpha*float64(offset))
+		if rtt < s.MinRTT {
+			s.MinRTT = rtt
+		}
+	}
+	s.LastRTT = rtt
+	s.NumSamples++
+}
+
+func (p *Pinger) expireLocked(now time.Time) {
+	for seq, sentAt := range p.pending {
+		if now.Sub(sentAt) >= p.params.Timeout {
+			delete(p.pending, seq)
+			p.stats.NumLost++
+		}
+	}
+}
+
+func (p *Pinger) worker() {
+	ticker := time.NewTicker(p.params.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = p.Ping()
+		case <-p.close:
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rttping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinger(t *testing.T) {
+	// remote clock is 3s ahead, one way delay 20ms then 40ms
+	const remoteOffset = 3 * time.Second
+
+	var toRemote, toLocal [][]byte
+	local := NewPinger(PingerParams{Timeout: time.Second}, func(data []byte) error {
+		toRemote = append(toRemote, data)
+		return nil
+	})
+	remote := NewPinger(PingerParams{}, func(data []byte) error {
+		toLocal = append(toLocal, data)
+		return nil
+	})
+
+	start := time.Now()
+	exchange := func(at time.Time, oneWay time.Duration) {
+		require.NoError(t, local.ping(at))
+		require.NoError(t, remote.handleMessage(toRemote[len(toRemote)-1], at.Add(oneWay+remoteOffset)))
+		require.NoError(t, local.handleMessage(toLocal[len(toLocal)-1], at.Add(2*oneWay)))
+	}
+
+	exchange(start, 20*time.Millisecond)
+	stats := local.Stats()
+	require.Equal(t, 1, stats.NumSamples)
+	require.Equal(t, 40*time.Millisecond, stats.LastRTT)
+	require.Equal(t, 40*time.Millisecond, stats.SmoothedRTT)
+	require.Equal(t, remoteOffset, stats.ClockOffset)
+
+	exchange(start.Add(time.Second), 40*time.Millisecond)
+	stats = local.Stats()
+	require.Equal(t, 2, stats.NumSamples)
+	require.Equal(t, 80*time.Millisecond, stats.LastRTT)
+	require.Equal(t, 40*time.Millisecond, stats.MinRTT)
+	require.Equal(t, 45*time.Millisecond, stats.SmoothedRTT)
+	require.Equal(t, 25*time.Millisecond, stats.RTTVar)
+	require.Equal(t, remoteOffset, stats.ClockOffset)
+
+	// unanswered ping expires as lost, duplicate pong ignored
+	require.NoError(t, local.ping(start.Add(2*time.Second)))
+	require.NoError(t, local.ping(start.Add(4*time.Second)))
+	require.Equal(t, 1, local.Stats().NumLost)
+	require.NoError(t, local.handleMessage(toLocal[len(toLocal)-1], start.Add(5*time.Second)))
+	require.Equal(t, 2, local.Stats().NumSamples)
+
+	require.ErrorIs(t, local.HandleMessage([]byte{messageTypePong, 0}), ErrInvalidMessage)
+	require.ErrorIs(t, local.HandleMessage([]byte{9}), ErrInvalidMessage)
+}
+
+func TestPingerStartStop(t *testing.T) {
+	received := make(chan []byte, 10)
+	p := NewPinger(PingerParams{Interval: 10 * time.Millisecond}, func(data []byte) error {
+		received <- data
+		return nil
+	})
+	p.Start()
+
+	select {
+	case data := <-received:
+		require.Equal(t, byte(messageTypePing), data[0])
+	case <-time.After(time.Second):
+		t.Fatal("no ping sent")
+	}
+
+	p.Stop()
+	p.Stop()
+}
@@ -0,0 +1,245 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessionrecord
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/livekit/mediatransportutil/pkg/monitor"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative sessionrecord.proto
+
+type RecorderParams struct {
+	NodeID string
+	// quality samples kept, when full every other sample is dropped so the timeline covers
+	// the whole session at a coarser resolution
+	MaxQualitySamples int
+}
+
+var RecorderParamsDefault = RecorderParams{
+	MaxQualitySamples: 720,
+}
+
+// Recorder collects the transport summary of a session and emits it as a SessionRecord when the session ends.
+//
+// Typical use is
+//
+//	pc.SCTP().Transport().ICETransport().OnSelectedCandidatePairChange(recorder.HandleSelectedCandidatePairChange)
+//
+// with quality and byte counts reported periodically through AddQualitySample and SetByteCounts.
+type Recorder struct {
+	params RecorderParams
+
+	lock       sync.Mutex
+	record     *SessionRecord
+	selectedAt time.Time
+	sampleSkip int
+	numSamples int
+	ended      bool
+	onRecord   func(record *SessionRecord)
+}
+
+func NewRecorder(sessionID string, params RecorderParams) *Recorder {
+	return newRecorderAt(sessionID, params, time.Now())
+}
+
+func newRecorderAt(sessionID string, params RecorderParams, now time.Time) *Recorder {
+	if params.MaxQualitySamples == 0 {
+		params.MaxQualitySamples = RecorderParamsDefault.MaxQualitySamples
+	}
+	return &Recorder{
+		params: params,
+		record: &SessionRecord{
+			SessionId: sessionID,
+			NodeId:    params.NodeID,
+			StartedAt: timestamppb.New(now),
+			Bytes:     &ByteCounts{},
+		},
+		sampleSkip: 1,
+	}
+}
+
+// OnRecord sets the callback receiving the record when the session ends, it must not modify the record.
+func (r *Recorder) OnRecord(fn func(record *SessionRecord)) {
+	r.lock.Lock()
+	r.onRecord = fn
+	r.lock.Unlock()
+}
+
+func (r *Recorder) HandleSelectedCandidatePairChange(pair *webrtc.ICECandidatePair) {
+	r.handleSelectedCandidatePairChange(pair, time.Now())
+}
+
+func (r *Recorder) handleSelectedCandidatePairChange(pair *webrtc.ICECandidatePair, now time.Time) {
+	if pair == nil || pair.Local == nil || pair.Remote == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.ended {
+		return
+	}
+	r.closeTransportLocked(now)
+
+	local := addressOf(pair.Local)
+	remote := addressOf(pair.Remote)
+	r.record.LocalAddresses = appendAddress(r.record.LocalAddresses, local)
+	r.record.RemoteAddresses = appendAddress(r.record.RemoteAddresses, remote)
+	r.record.Transports = append(r.record.Transports, &TransportUsage{
+		Protocol:   protocolOf(pair.Local.Protocol),
+		Local:      local,
+		Remote:     remote,
+		SelectedAt: timestamppb.New(now),
+	})
+	r.selectedAt = now
+}
+
+// AddQualitySample appends a point to the quality timeline.
+func (r *Recorder) AddQualitySample(quality monitor.QualityScore, rtt time.Duration) {
+	r.addQualitySample(quality, rtt, time.Now())
+}
+
+func (r *Recorder) addQualitySample(quality monitor.QualityScore, rtt time.Duration, now time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.ended {
+		return
+	}
+	r.numSamples++
+	if (r.numSamples-1)%r.sampleSkip != 0 {
+		return
+	}
+
+	if len(r.record.Quality) == r.params.MaxQualitySamples {
+		kept := r.record.Quality[:0]
+		for i := 0; i < len(r.record.Quality); i += 2 {
+			kept = append(kept, r.record.Quality[i])
+		}
+		r.record.Quality = kept
+		r.sampleSkip *= 2
+		if (r.numSamples-1)%r.sampleSkip != 0 {
+			return
+		}
+	}
+	r.record.Quality = append(r.record.Quality, &QualitySample{
+		Time:           timestamppb.New(now),
+		Score:          float32(quality.Score),
+		Loss:           float32(quality.Loss.Total),
+		UpstreamLoss:   float32(quality.Loss.Upstream),
+		DownstreamLoss: float32(quality.Loss.Downstream),
+		RttMs:          uint32(rtt.Milliseconds()),
+	})
+}
+
+// SetByteCounts sets the cumulative counts of the session.
+func (r *Recorder) SetByteCounts(counts *ByteCounts) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.ended || counts == nil {
+		return
+	}
+	r.record.Bytes = &ByteCounts{
+		BytesSent:       counts.BytesSent,
+		BytesReceived:   counts.BytesReceived,
+		PacketsSent:     counts.PacketsSent,
+		PacketsReceived: counts.PacketsReceived,
+	}
+}
+
+// End finishes the record and emits it, only the first call has an effect. It returns the record.
+func (r *Recorder) End(reason string) *SessionRecord {
+	return r.endAt(reason, time.Now())
+}
+
+func (r *Recorder) endAt(reason string, now time.Time) *SessionRecord {
+	r.lock.Lock()
+	if r.ended {
+		r.lock.Unlock()
+		return r.record
+	}
+	r.ended = true
+	r.closeTransportLocked(now)
+	r.record.EndedAt = timestamppb.New(now)
+	r.record.EndReason = reason
+	record := r.record
+	onRecord := r.onRecord
+	r.lock.Unlock()
+
+	if onRecord != nil {
+		onRecord(record)
+	}
+	return record
+}
+
+func (r *Recorder) closeTransportLocked(now time.Time) {
+	if len(r.record.Transports) == 0 {
+		return
+	}
+	r.record.Transports[len(r.record.Transports)-1].DurationMs = uint64(now.Sub(r.selectedAt).Milliseconds())
+}
+
+// ------------------------------------------------
+
+func addressOf(candidate *webrtc.ICECandidate) *TransportAddress {
+	return &TransportAddress{
+		Ip:            candidate.Address,
+		Port:          uint32(candidate.Port),
+		CandidateType: candidateTypeOf(candidate.Typ),
+	}
+}
+
+func appendAddress(addresses []*TransportAddress, address *TransportAddress) []*TransportAddress {
+	for _, a := range addresses {
+		if a.Ip == address.Ip && a.Port == address.Port {
+			return addresses
+		}
+	}
+	return append(addresses, address)
+}
+
+func protocolOf(protocol webrtc.ICEProtocol) TransportProtocol {
+	switch protocol {
+	case webrtc.ICEProtocolUDP:
+		return TransportProtocol_TRANSPORT_PROTOCOL_UDP
+	case webrtc.ICEProtocolTCP:
+		return TransportProtocol_TRANSPORT_PROTOCOL_TCP
+	default:
+		return TransportProtocol_TRANSPORT_PROTOCOL_UNKNOWN
+	}
+}
+
+func candidateTypeOf(typ webrtc.ICECandidateType) CandidateType {
+	switch typ {
+	case webrtc.ICECandidateTypeHost:
+		return CandidateType_CANDIDATE_TYPE_HOST
+	case webrtc.ICECandidateTypeSrflx:
+		return CandidateType_CANDIDATE_TYPE_SRFLX
+	case webrtc.ICECandidateTypePrflx:
+		return CandidateType_CANDIDATE_TYPE_PRFLX
+	case webrtc.ICECandidateTypeRelay:
+		return CandidateType_CANDIDATE_TYPE_RELAY
+	default:
+		return CandidateType_CANDIDATE_TYPE_UNKNOWN
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sessionrecord
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/livekit/mediatransportutil/pkg/monitor"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Now()
+	r := newRecorderAt("session", RecorderParams{NodeID: "node", MaxQualitySamples: 4}, start)
+
+	var emitted []*SessionRecord
+	r.OnRecord(func(record *SessionRecord) {
+		emitted = append(emitted, record)
+	})
+
+	local := &webrtc.ICECandidate{Address: "10.0.0.1", Port: 7882, Typ: webrtc.ICECandidateTypeHost, Protocol: webrtc.ICEProtocolUDP}
+	r.handleSelectedCandidatePairChange(&webrtc.ICECandidatePair{
+		Local:  local,
+		Remote: &webrtc.ICECandidate{Address: "1.2.3.4", Port: 5000, Typ: webrtc.ICECandidateTypeSrflx, Protocol: webrtc.ICEProtocolUDP},
+	}, start)
+	r.handleSelectedCandidatePairChange(&webrtc.ICECandidatePair{
+		Local:  local,
+		Remote: &webrtc.ICECandidate{Address: "5.6.7.8", Port: 3478, Typ: webrtc.ICECandidateTypeRelay, Protocol: webrtc.ICEProtocolUDP},
+	}, start.Add(2*time.Second))
+
+	// 10 samples into 4 slots keep samples 0 and 4 at a 4 sample resolution, then 8
+	for i := 0; i < 10; i++ {
+		r.addQualitySample(monitor.QualityScore{Score: float64(i)}, 50*time.Millisecond, start.Add(time.Duration(i)*time.Second))
+	}
+	r.SetByteCounts(&ByteCounts{BytesSent: 1000, PacketsSent: 10})
+
+	record := r.endAt("client left", start.Add(10*time.Second))
+	require.Len(t, emitted, 1)
+	require.Same(t, record, emitted[0])
+	require.Same(t, record, r.End("again"))
+	require.Len(t, emitted, 1)
+
+	require.Equal(t, "session", record.SessionId)
+	require.Equal(t, "node", record.NodeId)
+	require.Equal(t, "client left", record.EndReason)
+	require.Len(t, record.LocalAddresses, 1)
+	require.Len(t, record.RemoteAddresses, 2)
+	require.Len(t, record.Transports, 2)
+	require.Equal(t, uint64(2000), record.Transports[0].DurationMs)
+	require.Equal(t, uint64(8000), record.Transports[1].DurationMs)
+	require.Equal(t, CandidateType_CANDIDATE_TYPE_RELAY, record.Transports[1].Remote.CandidateType)
+	require.Equal(t, TransportProtocol_TRANSPORT_PROTOCOL_UDP, record.Transports[1].Protocol)
+
+	var scores []float32
+	for _, sample := range record.Quality {
+		scores = append(scores, sample.Score)
+		require.Equal(t, uint32(50), sample.RttMs)
+	}
+	require.Equal(t, []float32{0, 4, 8}, scores)
+	require.Equal(t, uint64(1000), record.Bytes.BytesSent)
+
+	// the record round trips through the wire format
+	b, err := proto.Marshal(record)
+	require.NoError(t, err)
+	decoded := &SessionRecord{}
+	require.NoError(t, proto.Unmarshal(b, decoded))
+	require.True(t, proto.Equal(record, decoded))
+
+	// nothing changes after the end
+	r.addQualitySample(monitor.QualityScore{}, 0, start.Add(11*time.Second))
+	require.Len(t, record.Quality, 3)
+}
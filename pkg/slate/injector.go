@@ -0,0 +1,84 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slate
+
+import (
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/munger"
+)
+
+type InjectorParams struct {
+	PayloadType uint8
+	SSRC        uint32
+	MTU         uint16
+}
+
+var InjectorParamsDefault = InjectorParams{
+	MTU: 1200,
+}
+
+// Injector sends a slate frame in place of a paused or unavailable video track, so that subscribers
+// show the slate instead of freezing on the last frame. The frame is packetized once, and every injection
+// continues the outgoing sequence numbers and timestamps of the track's munger.
+//
+// Inject once when the track pauses, then periodically (for example every second) so that subscribers
+// joining meanwhile can decode. The slate replaces the decoder state, so forwarding must resume on a key frame.
+type Injector struct {
+	params   InjectorParams
+	payloads [][]byte
+}
+
+func NewInjector(params InjectorParams, frame *Frame) (*Injector, error) {
+	if params.MTU == 0 {
+		params.MTU = InjectorParamsDefault.MTU
+	}
+
+	payloads, err := packetize(frame, params.MTU)
+	if err != nil {
+		return nil, err
+	}
+	return &Injector{
+		params:   params,
+		payloads: payloads,
+	}, nil
+}
+
+// Packets returns the packets of one slate frame, nil before the munger has seen a packet.
+// The munger is not safe for concurrent use, call from the goroutine forwarding the track.
+func (i *Injector) Packets(m *munger.Munger, at time.Time) []*rtp.Packet {
+	sn, ts, ok := m.Inject(len(i.payloads), at)
+	if !ok {
+		return nil
+	}
+
+	pkts := make([]*rtp.Packet, 0, len(i.payloads))
+	for idx, payload := range i.payloads {
+		pkts = append(pkts, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         idx == len(i.payloads)-1,
+				PayloadType:    i.params.PayloadType,
+				SequenceNumber: sn + uint16(idx),
+				Timestamp:      ts,
+				SSRC:           i.params.SSRC,
+			},
+			Payload: payload,
+		})
+	}
+	return pkts
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slate
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/pion/rtp/codecs"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+)
+
+var (
+	ErrUnsupportedCodec = errors.New("unsupported slate codec")
+	ErrNotKeyFrame      = errors.New("slate frame is not a key frame")
+)
+
+// Frame is a pre-encoded key frame, typically a black frame or a static image,
+// encoded offline at the resolution it is registered for.
+type Frame struct {
+	Codec  keyframe.Codec
+	Width  int
+	Height int
+	// a VP8 or VP9 frame, or H264 in Annex B format including SPS and PPS
+	Data []byte
+}
+
+// Library holds the slate frames available to a node, per codec and resolution.
+type Library struct {
+	lock   sync.Mutex
+	frames []*Frame
+}
+
+func NewLibrary() *Library {
+	return &Library{}
+}
+
+// Add validates and registers a frame, replacing a frame of the same codec and resolution.
+func (l *Library) Add(frame *Frame) error {
+	if _, err := packetize(frame, InjectorParamsDefault.MTU); err != nil {
+		return err
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i, f := range l.frames {
+		if f.Codec == frame.Codec && f.Width == frame.Width && f.Height == frame.Height {
+			l.frames[i] = frame
+			return nil
+		}
+	}
+	l.frames = append(l.frames, frame)
+	return nil
+}
+
+// Find returns the smallest frame of the codec that covers the given resolution,
+// or the largest frame of the codec if none does, nil if there is no frame for the codec.
+func (l *Library) Find(codec keyframe.Codec, width int, height int) *Frame {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var covering, largest *Frame
+	for _, f := range l.frames {
+		if f.Codec != codec {
+			continue
+		}
+		if f.Width >= width && f.Height >= height && (covering == nil || f.Width*f.Height < covering.Width*covering.Height) {
+			covering = f
+		}
+		if largest == nil || f.Width*f.Height > largest.Width*largest.Height {
+			largest = f
+		}
+	}
+	if covering != nil {
+		return covering
+	}
+	return largest
+}
+
+// ------------------------------------------------
+
+func packetize(frame *Frame, mtu uint16) ([][]byte, error) {
+	var payloader rtpPayloader
+	switch frame.Codec {
+	case keyframe.CodecVP8:
+		payloader = &codecs.VP8Payloader{}
+	case keyframe.CodecVP9:
+		// the VP9 payloader does not look at the bitstream, check the frame type here
+		if !isVP9KeyFrame(frame.Data) {
+			return nil, ErrNotKeyFrame
+		}
+		payloader = &codecs.VP9Payloader{InitialPictureIDFn: func() uint16 { return 0 }}
+	case keyframe.CodecH264:
+		payloader = &codecs.H264Payloader{}
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+
+	payloads := payloader.Payload(mtu, frame.Data)
+	if !keyframe.IsKeyFrame(frame.Codec, payloads) {
+		return nil, ErrNotKeyFrame
+	}
+	return payloads, nil
+}
+
+type rtpPayloader interface {
+	Payload(mtu uint16, payload []byte) [][]byte
+}
+
+// VP9 uncompressed header: frame_marker (2), profile (2), [reserved_zero (1) for profile 3],
+// show_existing_frame (1), frame_type (1, 0 is KEY_FRAME)
+func isVP9KeyFrame(data []byte) bool {
+	if len(data) < 1 || data[0]>>6 != 0x02 {
+		return false
+	}
+
+	profile := (data[0]>>5)&0x01 | (data[0]>>3)&0x02
+	bit := 4
+	if profile == 3 {
+		bit++
+	}
+	// show_existing_frame
+	if (data[0]>>(7-bit))&0x01 != 0 {
+		return false
+	}
+	bit++
+	return (data[0]>>(7-bit))&0x01 == 0
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+	"github.com/livekit/mediatransportutil/pkg/munger"
+)
+
+// VP8 key frame tag, start code and 16x16 dimensions, followed by filler
+var vp8KeyFrame = append([]byte{0x50, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0x10, 0x00, 0x10, 0x00}, make([]byte, 2000)...)
+
+func TestLibrary(t *testing.T) {
+	l := NewLibrary()
+
+	// delta frame
+	require.ErrorIs(t, l.Add(&Frame{Codec: keyframe.CodecVP8, Width: 16, Height: 16, Data: []byte{0x51, 0x02, 0x00}}), ErrNotKeyFrame)
+	// IDR without SPS / PPS
+	require.ErrorIs(t, l.Add(&Frame{Codec: keyframe.CodecH264, Width: 16, Height: 16, Data: []byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}}), ErrNotKeyFrame)
+	// VP9 inter frame, profile 0
+	require.ErrorIs(t, l.Add(&Frame{Codec: keyframe.CodecVP9, Width: 16, Height: 16, Data: []byte{0x84, 0x00}}), ErrNotKeyFrame)
+	require.ErrorIs(t, l.Add(&Frame{Codec: keyframe.CodecAV1, Width: 16, Height: 16, Data: []byte{0x0a}}), ErrUnsupportedCodec)
+
+	small := &Frame{Codec: keyframe.CodecVP8, Width: 320, Height: 180, Data: vp8KeyFrame}
+	large := &Frame{Codec: keyframe.CodecVP8, Width: 1280, Height: 720, Data: vp8KeyFrame}
+	require.NoError(t, l.Add(small))
+	require.NoError(t, l.Add(large))
+	require.NoError(t, l.Add(&Frame{
+		Codec:  keyframe.CodecH264,
+		Width:  640,
+		Height: 360,
+		Data:   []byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x00, 0x00, 0x01, 0x68, 0xce, 0x00, 0x00, 0x00, 0x01, 0x65, 0x88},
+	}))
+	require.NoError(t, l.Add(&Frame{Codec: keyframe.CodecVP9, Width: 640, Height: 360, Data: []byte{0x80, 0x49, 0x83, 0x42}}))
+
+	require.Equal(t, small, l.Find(keyframe.CodecVP8, 320, 180))
+	require.Equal(t, large, l.Find(keyframe.CodecVP8, 640, 360))
+	require.Equal(t, large, l.Find(keyframe.CodecVP8, 1920, 1080))
+	require.Equal(t, 640, l.Find(keyframe.CodecH264, 320, 180).Width)
+	require.Nil(t, l.Find(keyframe.CodecAV1, 320, 180))
+}
+
+func TestInjector(t *testing.T) {
+	i, err := NewInjector(InjectorParams{PayloadType: 96, SSRC: 1234}, &Frame{Codec: keyframe.CodecVP8, Data: vp8KeyFrame})
+	require.NoError(t, err)
+
+	m := munger.NewMunger(munger.MungerParams{ClockRate: 90000})
+	now := time.Now()
+	require.Nil(t, i.Packets(m, now))
+
+	m.Update(100, 1000, now)
+	pkts := i.Packets(m, now.Add(time.Second))
+	require.Len(t, pkts, 2)
+	for idx, pkt := range pkts {
+		require.Equal(t, uint16(101+idx), pkt.SequenceNumber)
+		require.Equal(t, uint32(1000+90000), pkt.Timestamp)
+		require.Equal(t, uint8(96), pkt.PayloadType)
+		require.Equal(t, uint32(1234), pkt.SSRC)
+		require.Equal(t, idx == 1, pkt.Marker)
+	}
+	require.True(t, keyframe.IsKeyFrameStart(keyframe.CodecVP8, pkts[0].Payload))
+
+	// repeated
+	pkts = i.Packets(m, now.Add(2*time.Second))
+	require.Equal(t, uint16(103), pkts[0].SequenceNumber)
+	require.Equal(t, uint32(1000+2*90000), pkts[0].Timestamp)
+
+	// live stream continues after the slate
+	sn, _ := m.Update(101, 1000+3000, now.Add(3*time.Second))
+	require.Equal(t, uint16(105), sn)
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ring"
+	"github.com/livekit/mediatransportutil/pkg/statsstream"
+)
+
+type TrackerParams struct {
+	// windows reported on, e.g. the last hour and the last day, at the resolution of BucketDuration
+	Windows []time.Duration
+	// resolution of the history
+	BucketDuration time.Duration
+	// score from which a connection is of good quality, 1 (bad) to 5 (excellent)
+	QualityThreshold float32
+	// share of session time available and of good quality the error budgets are computed for
+	AvailabilityTarget float64
+	QualityTarget      float64
+	// longest time between snapshots of a connection counted as session time, longer gaps are not counted
+	MaxSnapshotGap time.Duration
+}
+
+var TrackerParamsDefault = TrackerParams{
+	Windows:            []time.Duration{time.Hour, 24 * time.Hour},
+	BucketDuration:     time.Minute,
+	QualityThreshold:   3.5,
+	AvailabilityTarget: 0.999,
+	QualityTarget:      0.95,
+	MaxSnapshotGap:     10 * time.Second,
+}
+
+// Report is the SLO compliance of a window. Budgets are the share of the error budget left, 1 when no error
+// occurred, 0 when it is exhausted and negative when overspent.
+type Report struct {
+	Window         time.Duration
+	SessionMinutes float64
+	// share of session time with media flowing
+	Availability       float64
+	AvailabilityBudget float64
+	// share of session time with media flowing at a score of at least QualityThreshold
+	Quality       float64
+	QualityBudget float64
+}
+
+type sloBucket struct {
+	index     int64
+	session   time.Duration
+	available time.Duration
+	good      time.Duration
+}
+
+type connectionState struct {
+	at              time.Time
+	packetsSent     uint64
+	packetsReceived uint64
+}
+
+// Tracker computes availability and quality SLOs, such as the share of session minutes above a quality
+// threshold, from the snapshots of the stats stream of a node. Reports are handed to OnReport after every
+// snapshot, for a metrics exporter to publish.
+type Tracker struct {
+	params TrackerParams
+
+	lock        sync.Mutex
+	connections map[string]*connectionState
+	buckets     *ring.Buffer[*sloBucket]
+	onReport    func(reports []Report)
+}
+
+func NewTracker(params TrackerParams) *Tracker {
+	if len(params.Windows) == 0 {
+		params.Windows = TrackerParamsDefault.Windows
+	}
+	if params.BucketDuration <= 0 {
+		params.BucketDuration = TrackerParamsDefault.BucketDuration
+	}
+	if params.QualityThreshold <= 0 {
+		params.QualityThreshold = TrackerParamsDefault.QualityThreshold
+	}
+	if params.AvailabilityTarget <= 0 || params.AvailabilityTarget >= 1 {
+		params.AvailabilityTarget = TrackerParamsDefault.AvailabilityTarget
+	}
+	if params.QualityTarget <= 0 || params.QualityTarget >= 1 {
+		params.QualityTarget = TrackerParamsDefault.QualityTarget
+	}
+	if params.MaxSnapshotGap <= 0 {
+		params.MaxSnapshotGap = TrackerParamsDefault.MaxSnapshotGap
+	}
+
+	maxWindow := time.Duration(0)
+	for _, w := range params.Windows {
+		if w > maxWindow {
+			maxWindow = w
+		}
+	}
+	numBuckets := int(maxWindow/params.BucketDuration) + 1
+	return &Tracker{
+		params:      params,
+		connections: make(map[string]*connectionState),
+		buckets:     ring.NewBuffer[*sloBucket](numBuckets),
+	}
+}
+
+// OnReport sets a callback invoked with the reports of all windows after each snapshot.
+func (t *Tracker) OnReport(f func(reports []Report)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.onReport = f
+}
+
+// Observe accounts for the time since the previous snapshot of each connection of a snapshot with all
+// connections of the node. Connections missing from it have ended.
+func (t *Tracker) Observe(snapshot *statsstream.StatsSnapshot) {
+	at := snapshot.GetTime().AsTime()
+
+	t.lock.Lock()
+	seen := make(map[string]struct{}, len(snapshot.GetConnections()))
+	for _, c := range snapshot.GetConnections() {
+		id := c.GetConnectionId()
+		seen[id] = struct{}{}
+
+		state, ok := t.connections[id]
+		if !ok {
+			state = &connectionState{}
+			t.connections[id] = state
+		}
+		if ok {
+			if elapsed := at.Sub(state.at); elapsed > 0 && elapsed <= t.params.MaxSnapshotGap {
+				isAvailable := c.GetPacketsSent() > state.packetsSent || c.GetPacketsReceived() > state.packetsReceived
+				t.addLocked(at, elapsed, isAvailable, isAvailable && c.GetScore() >= t.params.QualityThreshold)
+			}
+		}
+		state.at = at
+		state.packetsSent = c.GetPacketsSent()
+		state.packetsReceived = c.GetPacketsReceived()
+	}
+	for id := range t.connections {
+		if _, ok := seen[id]; !ok {
+			delete(t.connections, id)
+		}
+	}
+
+	reports := t.reportsLocked(at)
+	onReport := t.onReport
+	t.lock.Unlock()
+
+	if onReport != nil {
+		onReport(reports)
+	}
+}
+
+// Reports returns the reports of all windows ending at now.
+func (t *Tracker) Reports(now time.Time) []Report {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.reportsLocked(now)
+}
+
+func (t *Tracker) addLocked(at time.Time, elapsed time.Duration, isAvailable bool, isGood bool) {
+	index := at.UnixNano() / int64(t.params.BucketDuration)
+	if t.buckets.Len() == 0 || t.buckets.Newest().index < index {
+		t.buckets.Push(&sloBucket{index: index})
+	}
+	// late snapshots count towards the newest bucket
+	b := t.buckets.Newest()
+	b.session += elapsed
+	if isAvailable {
+		b.available += elapsed
+	}
+	if isGood {
+		b.good += elapsed
+	}
+}
+
+func (t *Tracker) reportsLocked(now time.Time) []Report {
+	nowIndex := now.UnixNano() / int64(t.params.BucketDuration)
+
+	reports := make([]Report, 0, len(t.params.Windows))
+	for _, window := range t.params.Windows {
+		// the complete buckets of the window and the current one
+		first := nowIndex - int64(window/t.params.BucketDuration)
+
+		var session, available, good time.Duration
+		for i := 0; i < t.buckets.Len(); i++ {
+			if b := t.buckets.At(i); b.index >= first && b.index <= nowIndex {
+				session += b.session
+				available += b.available
+				good += b.good
+			}
+		}
+
+		report := Report{
+			Window:             window,
+			SessionMinutes:     session.Minutes(),
+			Availability:       1,
+			AvailabilityBudget: 1,
+			Quality:            1,
+			QualityBudget:      1,
+		}
+		if session > 0 {
+			report.Availability = float64(available) / float64(session)
+			report.AvailabilityBudget = budget(report.Availability, t.params.AvailabilityTarget)
+			report.Quality = float64(good) / float64(session)
+			report.QualityBudget = budget(report.Quality, t.params.QualityTarget)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+// budget returns the share of the error budget of target left at the given compliance
+func budget(compliance float64, target float64) float64 {
+	return 1 - (1-compliance)/(1-target)
+}
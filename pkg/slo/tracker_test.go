@@ -0,0 +1,93 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/livekit/mediatransportutil/pkg/statsstream"
+)
+
+func snapshot(at time.Time, connections ...*statsstream.ConnectionStats) *statsstream.StatsSnapshot {
+	return &statsstream.StatsSnapshot{Time: timestamppb.New(at), Connections: connections}
+}
+
+func TestTracker(t *testing.T) {
+	tr := NewTracker(TrackerParams{
+		Windows:            []time.Duration{10 * time.Minute, time.Hour},
+		AvailabilityTarget: 0.9,
+		QualityTarget:      0.8,
+	})
+	var reports []Report
+	tr.OnReport(func(r []Report) {
+		reports = r
+	})
+	start := time.Unix(1700000000, 0).Truncate(time.Minute)
+
+	// a: media flowing, good for 30 minutes then poor for 10
+	// b: connected for 10 minutes, no media for the last 5
+	var packetsA, packetsB uint64
+	for s := 0; s <= 40*60; s += 5 {
+		at := start.Add(time.Duration(s) * time.Second)
+		scoreA := float32(4.5)
+		if s > 30*60 {
+			scoreA = 2
+		}
+		packetsA += 100
+		connections := []*statsstream.ConnectionStats{{ConnectionId: "a", PacketsReceived: packetsA, Score: scoreA}}
+		if s <= 10*60 {
+			if s <= 5*60 {
+				packetsB += 100
+			}
+			connections = append(connections, &statsstream.ConnectionStats{ConnectionId: "b", PacketsSent: packetsB, Score: 4})
+		}
+		tr.Observe(snapshot(at, connections...))
+	}
+
+	require.Len(t, reports, 2)
+	// last 10 minutes, a only and poor
+	require.Equal(t, 10*time.Minute, reports[0].Window)
+	require.InDelta(t, 10, reports[0].SessionMinutes, 0.1)
+	require.InDelta(t, 1, reports[0].Availability, 0.01)
+	require.InDelta(t, 0, reports[0].Quality, 0.01)
+	require.InDelta(t, -4, reports[0].QualityBudget, 0.05)
+
+	// last hour, 40 minutes of a and 10 of b
+	require.InDelta(t, 50, reports[1].SessionMinutes, 0.1)
+	require.InDelta(t, 0.9, reports[1].Availability, 0.01)
+	require.InDelta(t, 0, reports[1].AvailabilityBudget, 0.05)
+	require.InDelta(t, 35.0/50, reports[1].Quality, 0.01)
+
+	// history beyond the longest window is forgotten
+	later := tr.Reports(start.Add(2 * time.Hour))
+	require.Equal(t, 0.0, later[1].SessionMinutes)
+	require.Equal(t, 1.0, later[1].QualityBudget)
+}
+
+func TestTrackerSnapshotGap(t *testing.T) {
+	tr := NewTracker(TrackerParams{MaxSnapshotGap: 10 * time.Second})
+	start := time.Unix(1700000000, 0)
+
+	tr.Observe(snapshot(start, &statsstream.ConnectionStats{ConnectionId: "a", PacketsSent: 1, Score: 5}))
+	tr.Observe(snapshot(start.Add(time.Minute), &statsstream.ConnectionStats{ConnectionId: "a", PacketsSent: 2, Score: 5}))
+	tr.Observe(snapshot(start.Add(time.Minute+5*time.Second), &statsstream.ConnectionStats{ConnectionId: "a", PacketsSent: 3, Score: 5}))
+
+	reports := tr.Reports(start.Add(2 * time.Minute))
+	require.InDelta(t, 5.0/60, reports[0].SessionMinutes, 0.001)
+}
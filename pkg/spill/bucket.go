@@ -0,0 +1,136 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spill
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+	"github.com/livekit/mediatransportutil/pkg/membudget"
+)
+
+const (
+	seqNumOffset = 2
+)
+
+type number interface {
+	uint16 | uint32 | uint64
+}
+
+type SpillingBucketParams struct {
+	// initial in-memory capacity, also the growth step
+	Capacity int
+	// capacity the retention should reach, for example from the recording reliability profile
+	TargetCapacity int
+	// where the overflow goes when the budget does not allow the memory bucket to reach the target capacity
+	Dir string
+}
+
+// SpillingBucket is a retention bucket for recording subscribers. It grows in memory towards the target capacity
+// as long as the memory budget allows, and when the budget does not, packets are also written to a disk ring
+// of the target capacity, so that older packets can still be retransmitted, at the cost of disk latency.
+// Slots of the ring are indexed by sequence number modulo the target capacity, use extended (uint64)
+// sequence numbers so that wrap around does not shorten the history.
+// Like Bucket, it is not safe for concurrent use.
+type SpillingBucket[T number] struct {
+	*membudget.BudgetedBucket[T]
+
+	params       SpillingBucketParams
+	ring         *DiskRing
+	numAdded     int
+	peakCapacity int
+	isGrowDenied bool
+	spillErr     error
+}
+
+func NewSpillingBucket[T number](budget *membudget.Budget, params SpillingBucketParams) *SpillingBucket[T] {
+	if params.Capacity <= 0 {
+		params.Capacity = 1
+	}
+	if params.TargetCapacity < params.Capacity {
+		params.TargetCapacity = params.Capacity
+	}
+	return &SpillingBucket[T]{
+		BudgetedBucket: membudget.NewBudgetedBucket[T](budget, params.Capacity),
+		params:         params,
+	}
+}
+
+func (s *SpillingBucket[T]) AddPacket(pkt []byte) ([]byte, error) {
+	if len(pkt) < seqNumOffset+2 {
+		return nil, bucket.ErrPacketSizeInvalid
+	}
+	return s.AddPacketWithSequenceNumber(pkt, T(binary.BigEndian.Uint16(pkt[seqNumOffset:])))
+}
+
+func (s *SpillingBucket[T]) AddPacketWithSequenceNumber(pkt []byte, sn T) ([]byte, error) {
+	storedPkt, err := s.BudgetedBucket.AddPacketWithSequenceNumber(pkt, sn)
+	if err != nil {
+		return nil, err
+	}
+
+	s.numAdded++
+	if s.numAdded%s.params.Capacity == 0 && s.Capacity() < s.params.TargetCapacity {
+		before := s.Capacity()
+		s.isGrowDenied = s.BudgetedBucket.Grow() == before
+	}
+	if s.Capacity() > s.peakCapacity {
+		s.peakCapacity = s.Capacity()
+	}
+
+	if s.IsSpilling() && s.spillErr == nil {
+		if s.ring == nil {
+			s.ring, s.spillErr = NewDiskRing(DiskRingParams{Dir: s.params.Dir, Capacity: s.params.TargetCapacity})
+		}
+		if s.spillErr == nil {
+			s.spillErr = s.ring.Write(uint64(sn), storedPkt)
+		}
+	}
+	return storedPkt, nil
+}
+
+// GetPacket returns a packet from memory, or from the disk ring when it is too old for memory.
+func (s *SpillingBucket[T]) GetPacket(buf []byte, sn T) (int, error) {
+	n, err := s.BudgetedBucket.GetPacket(buf, sn)
+	if err == nil || s.ring == nil || !errors.Is(err, bucket.ErrPacketTooOld) {
+		return n, err
+	}
+
+	// sequence numbers beyond the target capacity have been overwritten in the ring
+	if diff := s.HeadSequenceNumber() - sn; int64(diff) >= int64(s.params.TargetCapacity) {
+		return 0, err
+	}
+	return s.ring.Read(buf, uint64(sn))
+}
+
+// IsSpilling returns true when the memory budget keeps the bucket below its target capacity,
+// because it denied growth or made the bucket shrink.
+func (s *SpillingBucket[T]) IsSpilling() bool {
+	return s.Capacity() < s.params.TargetCapacity && (s.isGrowDenied || s.Capacity() < s.peakCapacity)
+}
+
+// SpillError returns the error that stopped spilling, for example a full disk, nil if none.
+func (s *SpillingBucket[T]) SpillError() error {
+	return s.spillErr
+}
+
+func (s *SpillingBucket[T]) Close() {
+	s.BudgetedBucket.Close()
+	if s.ring != nil {
+		_ = s.ring.Close()
+		s.ring = nil
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spill
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+)
+
+const (
+	slotSNSize     = 8
+	slotLengthSize = 2
+	slotCRCSize    = 4
+	slotHeaderSize = slotSNSize + slotLengthSize + slotCRCSize
+	slotSize       = slotHeaderSize + bucket.MaxPktSize
+)
+
+var (
+	ErrPacketNotFound = errors.New("packet not in spill ring")
+	ErrCorruptPacket  = errors.New("spilled packet failed integrity check")
+	ErrInvalidPacket  = errors.New("packet cannot be spilled")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+type DiskRingParams struct {
+	// directory of the ring file, the system temporary directory if empty
+	Dir string
+	// number of packet slots
+	Capacity int
+}
+
+// DiskRing is a fixed size ring of packet slots in a memory-mapped file, indexed by sequence number.
+// Each slot carries the sequence number, length and a CRC of the packet, so that a slot that was overwritten,
+// torn or corrupted on disk is never replayed as the requested packet.
+//
+// The file is removed on Close. Like Bucket, it is not safe for concurrent use.
+type DiskRing struct {
+	capacity int
+	file     *os.File
+	storage  ringStorage
+}
+
+func NewDiskRing(params DiskRingParams) (*DiskRing, error) {
+	if params.Capacity <= 0 {
+		return nil, errors.New("spill ring capacity must be positive")
+	}
+
+	file, err := os.CreateTemp(params.Dir, "spill-*.ring")
+	if err != nil {
+		return nil, err
+	}
+	storage, err := openRingStorage(file, params.Capacity*slotSize)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &DiskRing{
+		capacity: params.Capacity,
+		file:     file,
+		storage:  storage,
+	}, nil
+}
+
+func (r *DiskRing) Capacity() int {
+	return r.capacity
+}
+
+// Path returns the path of the ring file.
+func (r *DiskRing) Path() string {
+	return r.file.Name()
+}
+
+// Write stores a packet, overwriting whatever was in its slot.
+func (r *DiskRing) Write(sn uint64, pkt []byte) error {
+	if len(pkt) == 0 || len(pkt) > bucket.MaxPktSize {
+		return ErrInvalidPacket
+	}
+
+	slot := make([]byte, slotHeaderSize+len(pkt))
+	binary.BigEndian.PutUint64(slot, sn)
+	binary.BigEndian.PutUint16(slot[slotSNSize:], uint16(len(pkt)))
+	copy(slot[slotHeaderSize:], pkt)
+	binary.BigEndian.PutUint32(slot[slotSNSize+slotLengthSize:], checksum(slot[:slotSNSize+slotLengthSize], pkt))
+
+	_, err := r.storage.WriteAt(slot, r.offset(sn))
+	return err
+}
+
+// Read copies the packet with the given sequence number into buf, returning its length.
+func (r *DiskRing) Read(buf []byte, sn uint64) (int, error) {
+	header := make([]byte, slotHeaderSize)
+	if _, err := r.storage.ReadAt(header, r.offset(sn)); err != nil {
+		return 0, err
+	}
+
+	size := int(binary.BigEndian.Uint16(header[slotSNSize:]))
+	if size == 0 || binary.BigEndian.Uint64(header) != sn {
+		return 0, ErrPacketNotFound
+	}
+	if size > bucket.MaxPktSize {
+		return 0, ErrCorruptPacket
+	}
+	if cap(buf) < size {
+		return 0, bucket.ErrBufferTooSmall
+	}
+	buf = buf[:size]
+	if _, err := r.storage.ReadAt(buf, r.offset(sn)+slotHeaderSize); err != nil {
+		return 0, err
+	}
+
+	if checksum(header[:slotSNSize+slotLengthSize], buf) != binary.BigEndian.Uint32(header[slotSNSize+slotLengthSize:]) {
+		return 0, ErrCorruptPacket
+	}
+	return size, nil
+}
+
+// Close releases the mapping and removes the ring file.
+func (r *DiskRing) Close() error {
+	err := r.storage.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(r.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+func (r *DiskRing) offset(sn uint64) int64 {
+	return int64(sn%uint64(r.capacity)) * slotSize
+}
+
+func checksum(header []byte, pkt []byte) uint32 {
+	return crc32.Update(crc32.Checksum(header, crcTable), crcTable, pkt)
+}
+
+type ringStorage interface {
+	ReadAt(p []byte, off int64) (int, error)
+	WriteAt(p []byte, off int64) (int, error)
+	Close() error
+}
@@ -0,0 +1,39 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package spill
+
+import (
+	"os"
+)
+
+// fileStorage reads and writes the ring file directly, there is no memory mapping on windows
+type fileStorage struct {
+	*os.File
+}
+
+func openRingStorage(file *os.File, size int) (ringStorage, error) {
+	if err := file.Truncate(int64(size)); err != nil {
+		return nil, err
+	}
+	return &fileStorage{File: file}, nil
+}
+
+func (f *fileStorage) Close() error {
+	// the file is closed by the ring
+	return nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spill
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/bucket"
+	"github.com/livekit/mediatransportutil/pkg/membudget"
+)
+
+func rtpPacket(sn uint16, size int) []byte {
+	pkt := make([]byte, size)
+	pkt[0] = 0x80
+	binary.BigEndian.PutUint16(pkt[seqNumOffset:], sn)
+	for i := 12; i < size; i++ {
+		pkt[i] = byte(sn)
+	}
+	return pkt
+}
+
+func TestDiskRing(t *testing.T) {
+	r, err := NewDiskRing(DiskRingParams{Dir: t.TempDir(), Capacity: 4})
+	require.NoError(t, err)
+
+	buf := make([]byte, bucket.MaxPktSize)
+	_, err = r.Read(buf, 1)
+	require.ErrorIs(t, err, ErrPacketNotFound)
+
+	require.NoError(t, r.Write(1, rtpPacket(1, 100)))
+	n, err := r.Read(buf, 1)
+	require.NoError(t, err)
+	require.Equal(t, rtpPacket(1, 100), buf[:n])
+
+	// overwritten by a packet in the same slot
+	require.NoError(t, r.Write(5, rtpPacket(5, 200)))
+	_, err = r.Read(buf, 1)
+	require.ErrorIs(t, err, ErrPacketNotFound)
+
+	_, err = r.Read(make([]byte, 10), 5)
+	require.ErrorIs(t, err, bucket.ErrBufferTooSmall)
+
+	require.ErrorIs(t, r.Write(2, nil), ErrInvalidPacket)
+	require.ErrorIs(t, r.Write(2, make([]byte, bucket.MaxPktSize+1)), ErrInvalidPacket)
+
+	// corruption on disk is detected
+	f, err := os.OpenFile(r.Path(), os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt([]byte{0xff}, int64(1*slotSize+slotHeaderSize+50))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	_, err = r.Read(buf, 5)
+	require.ErrorIs(t, err, ErrCorruptPacket)
+
+	path := r.Path()
+	require.NoError(t, r.Close())
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSpillingBucket(t *testing.T) {
+	slotBytes := bucket.MaxPktSize + 2
+
+	// room for the initial capacity and one growth step
+	budget := membudget.NewBudget(membudget.BudgetParams{MaxBytes: 20 * slotBytes})
+	b := NewSpillingBucket[uint64](budget, SpillingBucketParams{
+		Capacity:       10,
+		TargetCapacity: 50,
+		Dir:            t.TempDir(),
+	})
+	defer b.Close()
+
+	buf := make([]byte, bucket.MaxPktSize)
+	for sn := uint64(1); sn <= 60; sn++ {
+		_, err := b.AddPacketWithSequenceNumber(rtpPacket(uint16(sn), 100), sn)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 20, b.Capacity())
+	require.True(t, b.IsSpilling())
+	require.NoError(t, b.SpillError())
+
+	// in memory
+	n, err := b.GetPacket(buf, 55)
+	require.NoError(t, err)
+	require.Equal(t, rtpPacket(55, 100), buf[:n])
+
+	// spilling started once the bucket could not grow past 20, at packet 20
+	n, err = b.GetPacket(buf, 25)
+	require.NoError(t, err)
+	require.Equal(t, rtpPacket(25, 100), buf[:n])
+	_, err = b.GetPacket(buf, 12)
+	require.ErrorIs(t, err, ErrPacketNotFound)
+
+	// beyond the target capacity
+	_, err = b.GetPacket(buf, 10)
+	require.ErrorIs(t, err, bucket.ErrPacketTooOld)
+}
+
+func TestSpillingBucketWithinBudget(t *testing.T) {
+	budget := membudget.NewBudget(membudget.BudgetParams{})
+	b := NewSpillingBucket[uint16](budget, SpillingBucketParams{
+		Capacity:       10,
+		TargetCapacity: 30,
+		Dir:            t.TempDir(),
+	})
+	defer b.Close()
+
+	for sn := uint16(1); sn <= 40; sn++ {
+		_, err := b.AddPacket(rtpPacket(sn, 100))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 30, b.Capacity())
+	require.False(t, b.IsSpilling())
+	require.Nil(t, b.ring)
+
+	buf := make([]byte, bucket.MaxPktSize)
+	_, err := b.GetPacket(buf, 11)
+	require.NoError(t, err)
+}
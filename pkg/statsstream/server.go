@@ -0,0 +1,160 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsstream
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative statsstream.proto
+
+// Source provides the stats of a node, it is called once per snapshot of each subscriber
+// and must be safe for concurrent use.
+type Source interface {
+	Snapshot() (*NodeStats, []*ConnectionStats)
+}
+
+type SourceFunc func() (*NodeStats, []*ConnectionStats)
+
+func (f SourceFunc) Snapshot() (*NodeStats, []*ConnectionStats) {
+	return f()
+}
+
+type ServerParams struct {
+	NodeID string
+	// interval of subscribers that do not request one
+	DefaultInterval time.Duration
+	// shortest interval a subscriber can request
+	MinInterval time.Duration
+	// concurrent subscribers, further ones are rejected with ResourceExhausted
+	MaxSubscribers int
+}
+
+var ServerParamsDefault = ServerParams{
+	DefaultInterval: time.Second,
+	MinInterval:     100 * time.Millisecond,
+	MaxSubscribers:  16,
+}
+
+// Server implements the TransportStats service, register it with
+//
+//	statsstream.RegisterTransportStatsServer(grpcServer, statsstream.NewServer(source, params))
+type Server struct {
+	UnimplementedTransportStatsServer
+
+	params ServerParams
+	source Source
+
+	lock           sync.Mutex
+	numSubscribers int
+}
+
+func NewServer(source Source, params ServerParams) *Server {
+	if params.DefaultInterval == 0 {
+		params.DefaultInterval = ServerParamsDefault.DefaultInterval
+	}
+	if params.MinInterval == 0 {
+		params.MinInterval = ServerParamsDefault.MinInterval
+	}
+	if params.MaxSubscribers == 0 {
+		params.MaxSubscribers = ServerParamsDefault.MaxSubscribers
+	}
+	return &Server{
+		params: params,
+		source: source,
+	}
+}
+
+func (s *Server) NumSubscribers() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.numSubscribers
+}
+
+// Subscribe sends a snapshot right away and then every interval, until the subscriber goes away.
+func (s *Server) Subscribe(req *SubscribeRequest, stream TransportStats_SubscribeServer) error {
+	s.lock.Lock()
+	if s.numSubscribers >= s.params.MaxSubscribers {
+		s.lock.Unlock()
+		return status.Errorf(codes.ResourceExhausted, "too many stats subscribers, max %d", s.params.MaxSubscribers)
+	}
+	s.numSubscribers++
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.numSubscribers--
+		s.lock.Unlock()
+	}()
+
+	interval := time.Duration(req.IntervalMs) * time.Millisecond
+	if interval == 0 {
+		interval = s.params.DefaultInterval
+	}
+	if interval < s.params.MinInterval {
+		interval = s.params.MinInterval
+	}
+
+	var connectionIDs map[string]bool
+	if len(req.ConnectionIds) != 0 {
+		connectionIDs = make(map[string]bool, len(req.ConnectionIds))
+		for _, id := range req.ConnectionIds {
+			connectionIDs[id] = true
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := stream.Send(s.snapshot(req.NodeOnly, connectionIDs)); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Server) snapshot(nodeOnly bool, connectionIDs map[string]bool) *StatsSnapshot {
+	node, connections := s.source.Snapshot()
+	snapshot := &StatsSnapshot{
+		NodeId: s.params.NodeID,
+		Time:   timestamppb.Now(),
+		Node:   node,
+	}
+	if nodeOnly {
+		return snapshot
+	}
+	if connectionIDs == nil {
+		snapshot.Connections = connections
+		return snapshot
+	}
+	for _, connection := range connections {
+		if connectionIDs[connection.ConnectionId] {
+			snapshot.Connections = append(snapshot.Connections, connection)
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statsstream
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, s *Server) TransportStatsClient {
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	RegisterTransportStatsServer(grpcServer, s)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewTransportStatsClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	source := SourceFunc(func() (*NodeStats, []*ConnectionStats) {
+		return &NodeStats{NumConnections: 2, BytesSent: 100}, []*ConnectionStats{
+			{ConnectionId: "pc1", Transport: "udp"},
+			{ConnectionId: "pc2", Transport: "tcp"},
+		}
+	})
+	s := NewServer(source, ServerParams{NodeID: "node", MinInterval: 10 * time.Millisecond, MaxSubscribers: 1})
+	client := newTestClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Subscribe(ctx, &SubscribeRequest{IntervalMs: 1, ConnectionIds: []string{"pc2"}})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		snapshot, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, "node", snapshot.NodeId)
+		require.Equal(t, uint32(2), snapshot.Node.NumConnections)
+		require.Len(t, snapshot.Connections, 1)
+		require.Equal(t, "tcp", snapshot.Connections[0].Transport)
+	}
+	require.Equal(t, 1, s.NumSubscribers())
+
+	// over the limit
+	rejected, err := client.Subscribe(context.Background(), &SubscribeRequest{})
+	require.NoError(t, err)
+	_, err = rejected.Recv()
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	cancel()
+	require.Eventually(t, func() bool { return s.NumSubscribers() == 0 }, time.Second, 10*time.Millisecond)
+
+	stream, err = client.Subscribe(context.Background(), &SubscribeRequest{NodeOnly: true})
+	require.NoError(t, err)
+	snapshot, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(100), snapshot.Node.BytesSent)
+	require.Empty(t, snapshot.Connections)
+}
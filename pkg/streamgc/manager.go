@@ -0,0 +1,193 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamgc
+
+import (
+	"sync"
+	"time"
+)
+
+type ManagerParams struct {
+	// per-SSRC state is reclaimed after no activity for this long
+	InactivityTimeout time.Duration
+	// interval at which inactive streams are collected when started
+	CheckInterval time.Duration
+}
+
+var ManagerParamsDefault = ManagerParams{
+	InactivityTimeout: 30 * time.Second,
+	CheckInterval:     5 * time.Second,
+}
+
+type stream struct {
+	lastActivityAt time.Time
+	cleanups       []func()
+}
+
+// Manager tears down per-SSRC state (buckets, stats, mungers, ...) registered with it
+// once the SSRC has been inactive for the configured time, so that long lived sessions
+// with many joining and leaving streams do not grow without bound.
+type Manager struct {
+	params ManagerParams
+
+	lock      sync.Mutex
+	streams   map[uint32]*stream
+	onReclaim func(ssrc uint32, inactiveFor time.Duration)
+	isStopped bool
+
+	close chan struct{}
+}
+
+func NewManager(params ManagerParams) *Manager {
+	if params.CheckInterval <= 0 {
+		params.CheckInterval = ManagerParamsDefault.CheckInterval
+	}
+	return &Manager{
+		params:  params,
+		streams: make(map[uint32]*stream),
+		close:   make(chan struct{}),
+	}
+}
+
+// OnReclaim sets the callback invoked after the state of an inactive SSRC is torn down.
+func (m *Manager) OnReclaim(f func(ssrc uint32, inactiveFor time.Duration)) {
+	m.lock.Lock()
+	m.onReclaim = f
+	m.lock.Unlock()
+}
+
+func (m *Manager) Start() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.isStopped {
+		return
+	}
+	go m.worker()
+}
+
+func (m *Manager) Stop() {
+	m.lock.Lock()
+	if m.isStopped {
+		m.lock.Unlock()
+		return
+	}
+
+	close(m.close)
+	m.isStopped = true
+	m.lock.Unlock()
+}
+
+// Register adds a teardown function for state held for the SSRC.
+// An SSRC not yet known is considered active at the given time.
+// Teardown functions are run in reverse order of registration.
+func (m *Manager) Register(ssrc uint32, cleanup func(), at time.Time) {
+	m.lock.Lock()
+	s := m.getOrCreateLocked(ssrc, at)
+	s.cleanups = append(s.cleanups, cleanup)
+	m.lock.Unlock()
+}
+
+// Touch marks the SSRC as active at the given time.
+func (m *Manager) Touch(ssrc uint32, at time.Time) {
+	m.lock.Lock()
+	s := m.getOrCreateLocked(ssrc, at)
+	if at.After(s.lastActivityAt) {
+		s.lastActivityAt = at
+	}
+	m.lock.Unlock()
+}
+
+// Remove tears down the state of the SSRC immediately.
+func (m *Manager) Remove(ssrc uint32) {
+	m.lock.Lock()
+	s, ok := m.streams[ssrc]
+	delete(m.streams, ssrc)
+	m.lock.Unlock()
+
+	if ok {
+		s.teardown()
+	}
+}
+
+func (m *Manager) NumStreams() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.streams)
+}
+
+// Collect tears down the state of all SSRCs inactive at the given time and returns them.
+func (m *Manager) Collect(now time.Time) []uint32 {
+	type reclaimed struct {
+		ssrc        uint32
+		inactiveFor time.Duration
+		s           *stream
+	}
+	var toReclaim []reclaimed
+
+	m.lock.Lock()
+	for ssrc, s := range m.streams {
+		if inactiveFor := now.Sub(s.lastActivityAt); inactiveFor >= m.params.InactivityTimeout {
+			toReclaim = append(toReclaim, reclaimed{ssrc, inactiveFor, s})
+			delete(m.streams, ssrc)
+		}
+	}
+	onReclaim := m.onReclaim
+	m.lock.Unlock()
+
+	ssrcs := make([]uint32, 0, len(toReclaim))
+	for _, r := range toReclaim {
+		r.s.teardown()
+		if onReclaim != nil {
+			onReclaim(r.ssrc, r.inactiveFor)
+		}
+		ssrcs = append(ssrcs, r.ssrc)
+	}
+	return ssrcs
+}
+
+func (m *Manager) getOrCreateLocked(ssrc uint32, at time.Time) *stream {
+	s, ok := m.streams[ssrc]
+	if !ok {
+		s = &stream{
+			lastActivityAt: at,
+		}
+		m.streams[ssrc] = s
+	}
+	return s
+}
+
+func (m *Manager) worker() {
+	ticker := time.NewTicker(m.params.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Collect(time.Now())
+		case <-m.close:
+			return
+		}
+	}
+}
+
+// ------------------------------------------------
+
+func (s *stream) teardown() {
+	for i := len(s.cleanups) - 1; i >= 0; i-- {
+		s.cleanups[i]()
+	}
+	s.cleanups = nil
+}
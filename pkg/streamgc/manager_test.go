@@ -0,0 +1,82 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streamgc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerCollect(t *testing.T) {
+	m := NewManager(ManagerParams{InactivityTimeout: 10 * time.Second})
+
+	type reclaimed struct {
+		ssrc        uint32
+		inactiveFor time.Duration
+	}
+	var reclaims []reclaimed
+	m.OnReclaim(func(ssrc uint32, inactiveFor time.Duration) {
+		reclaims = append(reclaims, reclaimed{ssrc, inactiveFor})
+	})
+
+	var teardowns []string
+	now := time.Now()
+	m.Register(1, func() { teardowns = append(teardowns, "bucket") }, now)
+	m.Register(1, func() { teardowns = append(teardowns, "munger") }, now)
+	m.Register(2, func() { teardowns = append(teardowns, "stats") }, now)
+	require.Equal(t, 2, m.NumStreams())
+
+	// keep 2 alive
+	m.Touch(2, now.Add(5*time.Second))
+
+	// not inactive long enough
+	require.Empty(t, m.Collect(now.Add(9*time.Second)))
+	require.Empty(t, teardowns)
+
+	require.Equal(t, []uint32{1}, m.Collect(now.Add(10*time.Second)))
+	require.Equal(t, []string{"munger", "bucket"}, teardowns)
+	require.Equal(t, []reclaimed{{1, 10 * time.Second}}, reclaims)
+	require.Equal(t, 1, m.NumStreams())
+
+	// touching with an older time does not move activity back
+	m.Touch(2, now)
+	require.Equal(t, []uint32{2}, m.Collect(now.Add(16*time.Second)))
+	require.Equal(t, []string{"munger", "bucket", "stats"}, teardowns)
+	require.Equal(t, reclaimed{2, 11 * time.Second}, reclaims[1])
+	require.Zero(t, m.NumStreams())
+}
+
+func TestManagerRemove(t *testing.T) {
+	m := NewManager(ManagerParamsDefault)
+
+	tornDown := false
+	m.Register(1, func() { tornDown = true }, time.Now())
+	m.Remove(1)
+	require.True(t, tornDown)
+	require.Zero(t, m.NumStreams())
+
+	// removing unknown SSRC is a no-op
+	m.Remove(2)
+}
+
+func TestManagerStartStop(t *testing.T) {
+	m := NewManager(ManagerParams{InactivityTimeout: time.Second})
+	m.Start()
+	m.Stop()
+	require.NotPanics(t, m.Stop)
+	m.Start()
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/munger"
+)
+
+// Position is a point on the media timeline of a stream.
+type Position struct {
+	// RTP timestamp of the stream at the point
+	Timestamp uint32
+	// time from the origin of the timeline, in media time
+	Offset time.Duration
+}
+
+// Cue is the span of an external event on the media timeline, for example a caption.
+type Cue struct {
+	Start Position
+	End   Position
+}
+
+func (c Cue) Duration() time.Duration {
+	return c.End.Offset - c.Start.Offset
+}
+
+// Aligner converts the wall clock times of external events, such as the times of captions from a speech
+// recognizer, to positions on the media timeline of a stream, so that they can be aligned with the media
+// downstream, e.g. burned in or muxed as a subtitle track.
+//
+// The mapping follows the wall clock mapper of the stream, which is owned by the goroutine forwarding it;
+// that goroutine calls Sync after the mapper is re-anchored, Aligner itself is safe for concurrent use.
+type Aligner struct {
+	lock        sync.Mutex
+	mapper      *munger.WallclockMapper
+	originTS    uint32
+	hasOrigin   bool
+	clockOffset time.Duration
+}
+
+func NewAligner(clockRate uint32) *Aligner {
+	return &Aligner{
+		mapper: munger.NewWallclockMapper(clockRate),
+	}
+}
+
+// Sync copies the mapping of m.
+func (a *Aligner) Sync(m *munger.WallclockMapper) {
+	if !m.IsInitialized() {
+		return
+	}
+	ts, at := m.Reference()
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.mapper.Reset(ts, at)
+}
+
+// SetOrigin sets the RTP timestamp offsets are measured from, for example that of the first recorded packet.
+// Without an origin, offsets are from the reference of the mapping.
+func (a *Aligner) SetOrigin(ts uint32) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.originTS = ts
+	a.hasOrigin = true
+}
+
+// SetClockOffset sets the offset of the clock of the event source from the local clock, as measured
+// by rttping.Pinger, so that times of the event source can be passed unchanged.
+func (a *Aligner) SetClockOffset(offset time.Duration) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.clockOffset = offset
+}
+
+// Position returns the position of an event at the wall clock time at of the event source, false before
+// the mapping is synced. Offsets are within half the timestamp range of the origin, as for WallclockMapper.TimeAt.
+func (a *Aligner) Position(at time.Time) (Position, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.positionLocked(at)
+}
+
+// Cue returns the span of an event from start to end at the wall clock of the event source.
+func (a *Aligner) Cue(start time.Time, end time.Time) (Cue, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	startPos, ok := a.positionLocked(start)
+	if !ok {
+		return Cue{}, false
+	}
+	endPos, _ := a.positionLocked(end)
+	return Cue{Start: startPos, End: endPos}, true
+}
+
+// TimeAt returns the wall clock time of the event source at a position, the inverse of Position.
+func (a *Aligner) TimeAt(offset time.Duration) (time.Time, bool) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if !a.mapper.IsInitialized() {
+		return time.Time{}, false
+	}
+	ts := a.originLocked() + uint32(a.mapper.DurationToTicks(offset))
+	return a.mapper.TimeAt(ts).Add(a.clockOffset), true
+}
+
+func (a *Aligner) positionLocked(at time.Time) (Position, bool) {
+	if !a.mapper.IsInitialized() {
+		return Position{}, false
+	}
+
+	ts := a.mapper.TimestampAt(at.Add(-a.clockOffset))
+	return Position{
+		Timestamp: ts,
+		Offset:    a.mapper.TicksToDuration(int64(int32(ts - a.originLocked()))),
+	}, true
+}
+
+func (a *Aligner) originLocked() uint32 {
+	if a.hasOrigin {
+		return a.originTS
+	}
+	ts, _ := a.mapper.Reference()
+	return ts
+}
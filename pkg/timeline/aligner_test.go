@@ -0,0 +1,60 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/munger"
+)
+
+func TestAligner(t *testing.T) {
+	a := NewAligner(90000)
+	start := time.Unix(1700000000, 0)
+
+	_, ok := a.Position(start)
+	require.False(t, ok)
+
+	m := munger.NewWallclockMapper(90000)
+	m.Reset(0xffffffff-9000, start)
+	a.Sync(m)
+	a.SetOrigin(0xffffffff - 9000)
+
+	pos, ok := a.Position(start.Add(2 * time.Second))
+	require.True(t, ok)
+	require.Equal(t, Position{Timestamp: 180000 - 9000 - 1, Offset: 2 * time.Second}, pos)
+
+	// event source clock 500ms ahead
+	a.SetClockOffset(500 * time.Millisecond)
+	cue, ok := a.Cue(start.Add(1500*time.Millisecond), start.Add(3*time.Second))
+	require.True(t, ok)
+	require.Equal(t, time.Second, cue.Start.Offset)
+	require.Equal(t, 2500*time.Millisecond, cue.End.Offset)
+	require.Equal(t, 1500*time.Millisecond, cue.Duration())
+
+	at, ok := a.TimeAt(time.Second)
+	require.True(t, ok)
+	require.Equal(t, start.Add(1500*time.Millisecond), at)
+
+	// the mapping is re-anchored after a compressed jump, the origin stays
+	m.Reset(90000*10-9000-1, start.Add(20*time.Second))
+	a.Sync(m)
+	pos, ok = a.Position(start.Add(21500 * time.Millisecond))
+	require.True(t, ok)
+	require.Equal(t, 11*time.Second, pos.Offset)
+}
@@ -0,0 +1,311 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timingwheel
+
+import (
+	"math/bits"
+	"sync"
+	"time"
+)
+
+type WheelParams struct {
+	// resolution of timers, they fire up to one tick late and never early
+	Tick time.Duration
+	// slots per level, rounded up to a power of two
+	SlotsPerLevel int
+	// levels of the hierarchy, the wheel spans SlotsPerLevel^NumLevels ticks, later timers wait in an overflow list
+	NumLevels int
+}
+
+var WheelParamsDefault = WheelParams{
+	Tick:          5 * time.Millisecond,
+	SlotsPerLevel: 256,
+	NumLevels:     4,
+}
+
+// Timer is a timer of a Wheel.
+type Timer struct {
+	wheel    *Wheel
+	f        func()
+	expiry   uint64
+	interval uint64
+
+	// intrusive list of the slot the timer is in, nil slot if not scheduled
+	slot       *slot
+	prev, next *Timer
+}
+
+// Stop prevents the timer from firing, returns false if it already fired or was stopped.
+// A periodic timer does not fire again after Stop returns, a call in progress may still be running.
+func (t *Timer) Stop() bool {
+	w := t.wheel
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	t.interval = 0
+	if t.slot == nil {
+		return false
+	}
+	t.slot.remove(t)
+	w.numTimers--
+	return true
+}
+
+type slot struct {
+	head *Timer
+}
+
+func (s *slot) push(t *Timer) {
+	t.slot = s
+	t.prev = nil
+	t.next = s.head
+	if s.head != nil {
+		s.head.prev = t
+	}
+	s.head = t
+}
+
+func (s *slot) remove(t *Timer) {
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		s.head = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	}
+	t.slot, t.prev, t.next = nil, nil, nil
+}
+
+// take empties the slot and returns its timers, unlinked from it
+func (s *slot) take() *Timer {
+	head := s.head
+	s.head = nil
+	for t := head; t != nil; t = t.next {
+		t.slot = nil
+	}
+	return head
+}
+
+// Wheel is a hierarchical timing wheel, many timers share one goroutine and one runtime timer instead of one
+// each, for example per stream RTCP timers of thousands of streams. Timers are kept in slots of a tick, slots
+// of higher levels span a whole lower level and are cascaded down as the wheel turns, so adding, stopping and
+// firing a timer is O(1).
+//
+// Callbacks run on the wheel goroutine, one at a time, they must not block.
+type Wheel struct {
+	params    WheelParams
+	slotBits  uint
+	slotMask  uint64
+	levels    [][]slot
+	overflow  slot
+	start     time.Time
+	lock      sync.Mutex
+	tick      uint64
+	numTimers int
+	fired     []*Timer
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewWheel(params WheelParams) *Wheel {
+	if params.Tick <= 0 {
+		params.Tick = WheelParamsDefault.Tick
+	}
+	if params.SlotsPerLevel <= 1 {
+		params.SlotsPerLevel = WheelParamsDefault.SlotsPerLevel
+	}
+	if params.NumLevels <= 0 {
+		params.NumLevels = WheelParamsDefault.NumLevels
+	}
+	slotBits := uint(bits.Len(uint(params.SlotsPerLevel - 1)))
+	if int(slotBits)*params.NumLevels > 63 {
+		params.NumLevels = 63 / int(slotBits)
+	}
+	params.SlotsPerLevel = 1 << slotBits
+
+	w := &Wheel{
+		params:   params,
+		slotBits: slotBits,
+		slotMask: uint64(params.SlotsPerLevel - 1),
+		levels:   make([][]slot, params.NumLevels),
+		start:    time.Now(),
+	}
+	for i := range w.levels {
+		w.levels[i] = make([]slot, params.SlotsPerLevel)
+	}
+	return w
+}
+
+// Start turns the wheel on a goroutine of its own until Stop.
+func (w *Wheel) Start() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.worker(w.stop, w.done)
+}
+
+// Stop stops turning the wheel, pending timers do not fire until it is started again.
+// It waits for a running callback and must not be called from one.
+func (w *Wheel) Stop() {
+	w.lock.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// AfterFunc calls f once after d.
+func (w *Wheel) AfterFunc(d time.Duration, f func()) *Timer {
+	t := &Timer{wheel: w, f: f}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	t.expiry = w.tick + w.ticksOf(d)
+	w.addLocked(t)
+	return t
+}
+
+// Every calls f every interval, the first time after interval, until the timer is stopped.
+// Calls do not drift, a late call does not delay the next ones.
+func (w *Wheel) Every(interval time.Duration, f func()) *Timer {
+	t := &Timer{wheel: w, f: f}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	t.interval = w.ticksOf(interval)
+	t.expiry = w.tick + t.interval
+	w.addLocked(t)
+	return t
+}
+
+// NumTimers returns the number of scheduled timers.
+func (w *Wheel) NumTimers() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.numTimers
+}
+
+func (w *Wheel) worker(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.params.Tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			w.advance(uint64(now.Sub(w.start) / w.params.Tick))
+		}
+	}
+}
+
+// advance turns the wheel to tick, firing the timers due on the way
+func (w *Wheel) advance(tick uint64) {
+	for {
+		w.lock.Lock()
+		if w.tick >= tick {
+			w.lock.Unlock()
+			return
+		}
+		w.tick++
+		w.cascadeLocked()
+
+		fired := w.fired[:0]
+		for t := w.levels[0][w.tick&w.slotMask].take(); t != nil; {
+			next := t.next
+			t.prev, t.next = nil, nil
+			w.numTimers--
+			fired = append(fired, t)
+			if t.interval != 0 {
+				t.expiry += t.interval
+				w.addLocked(t)
+			}
+			t = next
+		}
+		w.fired = fired
+		w.lock.Unlock()
+
+		for i, t := range fired {
+			t.f()
+			fired[i] = nil
+		}
+	}
+}
+
+// cascadeLocked moves the timers of the higher level slots that start at the current tick down
+func (w *Wheel) cascadeLocked() {
+	for level := 1; level < len(w.levels); level++ {
+		shift := w.slotBits * uint(level)
+		if w.tick&(1<<shift-1) != 0 {
+			return
+		}
+		w.readdLocked(w.levels[level][(w.tick>>shift)&w.slotMask].take())
+	}
+	if w.tick&(1<<(w.slotBits*uint(len(w.levels)))-1) == 0 {
+		w.readdLocked(w.overflow.take())
+	}
+}
+
+func (w *Wheel) readdLocked(head *Timer) {
+	for t := head; t != nil; {
+		next := t.next
+		w.numTimers--
+		w.addLocked(t)
+		t = next
+	}
+}
+
+// addLocked puts t in the lowest level where its expiry shares the digits of the higher levels with the current
+// tick. Its digit of that level is then ahead of the current one, so the slot is cascaded or fired before it is due.
+// Timers cascaded at the tick they are due land in the level 0 slot fired right after the cascade.
+func (w *Wheel) addLocked(t *Timer) {
+	w.numTimers++
+	if t.expiry < w.tick {
+		t.expiry = w.tick
+	}
+	for level := range w.levels {
+		shift := w.slotBits * uint(level+1)
+		if t.expiry>>shift == w.tick>>shift {
+			w.levels[level][(t.expiry>>(w.slotBits*uint(level)))&w.slotMask].push(t)
+			return
+		}
+	}
+	w.overflow.push(t)
+}
+
+// ticksOf returns the ticks to wait for d, at least one
+func (w *Wheel) ticksOf(d time.Duration) uint64 {
+	ticks := uint64((d + w.params.Tick - 1) / w.params.Tick)
+	if ticks == 0 {
+		ticks = 1
+	}
+	return ticks
+}
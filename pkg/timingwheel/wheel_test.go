@@ -0,0 +1,113 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timingwheel
+
+import (
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWheelFiresOnTime(t *testing.T) {
+	w := NewWheel(WheelParams{Tick: time.Millisecond, SlotsPerLevel: 4, NumLevels: 2})
+
+	// across levels and into the overflow list, in random order
+	fired := make(map[int]uint64)
+	delays := rand.Perm(100)
+	for _, d := range delays {
+		d := d + 1
+		w.AfterFunc(time.Duration(d)*time.Millisecond, func() {
+			fired[d] = w.tick
+		})
+	}
+	require.Equal(t, 100, w.NumTimers())
+
+	w.advance(100)
+	require.Len(t, fired, 100)
+	for d, tick := range fired {
+		require.Equal(t, uint64(d), tick, "delay %d", d)
+	}
+	require.Equal(t, 0, w.NumTimers())
+
+	// rounded up to whole ticks, at least one
+	var at uint64
+	w.AfterFunc(1500*time.Microsecond, func() { at = w.tick })
+	w.AfterFunc(0, func() {})
+	w.advance(101)
+	require.Equal(t, 1, w.NumTimers())
+	w.advance(102)
+	require.Equal(t, uint64(102), at)
+}
+
+func TestWheelStop(t *testing.T) {
+	w := NewWheel(WheelParams{Tick: time.Millisecond, SlotsPerLevel: 4, NumLevels: 2})
+
+	var numFired int
+	t1 := w.AfterFunc(10*time.Millisecond, func() { numFired++ })
+	t2 := w.AfterFunc(10*time.Millisecond, func() { numFired++ })
+	require.True(t, t1.Stop())
+	require.False(t, t1.Stop())
+
+	w.advance(20)
+	require.Equal(t, 1, numFired)
+	require.False(t, t2.Stop())
+}
+
+func TestWheelEvery(t *testing.T) {
+	w := NewWheel(WheelParams{Tick: time.Millisecond, SlotsPerLevel: 4, NumLevels: 2})
+
+	var ticks []uint64
+	var timer *Timer
+	timer = w.Every(7*time.Millisecond, func() {
+		ticks = append(ticks, w.tick)
+		if len(ticks) == 5 {
+			timer.Stop()
+		}
+	})
+	w.advance(100)
+	require.Equal(t, []uint64{7, 14, 21, 28, 35}, ticks)
+	require.Equal(t, 0, w.NumTimers())
+}
+
+func TestWheelStart(t *testing.T) {
+	w := NewWheel(WheelParams{Tick: time.Millisecond})
+	w.Start()
+	defer w.Stop()
+
+	var numFired atomic.Int32
+	for i := 0; i < 10; i++ {
+		w.AfterFunc(time.Duration(i)*time.Millisecond, func() { numFired.Add(1) })
+	}
+	require.Eventually(t, func() bool { return numFired.Load() == 10 }, time.Second, time.Millisecond)
+}
+
+func BenchmarkWheelAfterFunc(b *testing.B) {
+	w := NewWheel(WheelParamsDefault)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.AfterFunc(time.Duration(i%1000)*time.Millisecond, func() {}).Stop()
+	}
+}
+
+func BenchmarkTimeAfterFunc(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		time.AfterFunc(time.Duration(i%1000)*time.Millisecond, func() {}).Stop()
+	}
+}
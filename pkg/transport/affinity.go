@@ -0,0 +1,161 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	ErrAffinityNotSupported = errors.New("CPU affinity is not supported on this platform")
+)
+
+type AffinityParams struct {
+	// CPUs the threads of mux read goroutines are pinned to
+	CPUs []int
+	// pins each mux socket to a single CPU of CPUs, assigned round robin, instead of all of them
+	Spread bool
+	// the CPU a read completed on is sampled every SampleEvery reads, 0 disables sampling
+	SampleEvery uint64
+}
+
+var AffinityParamsDefault = AffinityParams{
+	SampleEvery: 1024,
+}
+
+// cpusFor returns the CPUs of the socket with the given index
+func (a AffinityParams) cpusFor(index int) []int {
+	if !a.Spread || len(a.CPUs) == 0 {
+		return a.CPUs
+	}
+	return []int{a.CPUs[index%len(a.CPUs)]}
+}
+
+// AffinityObserver is called once the read goroutine of a socket is pinned, or pinning failed.
+type AffinityObserver func(local net.Addr, cpus []int, err error)
+
+type AffinityStats struct {
+	CPUs     []int
+	IsPinned bool
+	NumReads uint64
+	// sampled reads and those that completed on a CPU outside of CPUs, for example when a cpuset restricts the process
+	NumSampledReads uint64
+	NumOffCPUReads  uint64
+	// CPU of the latest sampled read, -1 if none
+	LastCPU int
+}
+
+// ------------------------------------------------
+
+// AffinityConn pins the OS thread of the goroutine reading from it to CPUs on its first read, for cache
+// locality of the read loop on large hosts. The goroutine stays locked to the thread, conns read from
+// more than one goroutine only pin the first one.
+type AffinityConn struct {
+	net.PacketConn
+
+	params AffinityParams
+	cpus   []int
+
+	lock       sync.Mutex
+	onPinned   AffinityObserver
+	pinOnce    sync.Once
+	isPinned   atomic.Bool
+	cpuSet     map[int]bool
+	numReads   atomic.Uint64
+	numSampled atomic.Uint64
+	numOffCPU  atomic.Uint64
+	lastCPU    atomic.Int32
+}
+
+func NewAffinityConn(conn net.PacketConn, cpus []int, params AffinityParams) *AffinityConn {
+	if params.SampleEvery == 0 {
+		params.SampleEvery = AffinityParamsDefault.SampleEvery
+	}
+	a := &AffinityConn{
+		PacketConn: conn,
+		params:     params,
+		cpus:       cpus,
+		cpuSet:     make(map[int]bool, len(cpus)),
+	}
+	for _, cpu := range cpus {
+		a.cpuSet[cpu] = true
+	}
+	a.lastCPU.Store(-1)
+	return a
+}
+
+func (a *AffinityConn) OnPinned(f AffinityObserver) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.onPinned = f
+}
+
+func (a *AffinityConn) Stats() AffinityStats {
+	return AffinityStats{
+		CPUs:            a.cpus,
+		IsPinned:        a.isPinned.Load(),
+		NumReads:        a.numReads.Load(),
+		NumSampledReads: a.numSampled.Load(),
+		NumOffCPUReads:  a.numOffCPU.Load(),
+		LastCPU:         int(a.lastCPU.Load()),
+	}
+}
+
+func (a *AffinityConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	a.pinOnce.Do(a.pin)
+
+	n, addr, err := a.PacketConn.ReadFrom(p)
+	if a.numReads.Add(1)%a.params.SampleEvery == 0 && a.isPinned.Load() {
+		a.sample()
+	}
+	return n, addr, err
+}
+
+func (a *AffinityConn) pin() {
+	var err error
+	if len(a.cpus) != 0 {
+		runtime.LockOSThread()
+		if err = setThreadAffinity(a.cpus); err != nil {
+			runtime.UnlockOSThread()
+		} else {
+			a.isPinned.Store(true)
+		}
+	}
+
+	a.lock.Lock()
+	onPinned := a.onPinned
+	a.lock.Unlock()
+
+	if onPinned != nil {
+		onPinned(a.LocalAddr(), a.cpus, err)
+	}
+}
+
+func (a *AffinityConn) sample() {
+	cpu, err := currentCPU()
+	if err != nil {
+		return
+	}
+	a.lastCPU.Store(int32(cpu))
+	a.numSampled.Add(1)
+	if !a.cpuSet[cpu] {
+		a.numOffCPU.Add(1)
+	}
+}
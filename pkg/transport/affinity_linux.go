@@ -0,0 +1,65 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// CPUs in the affinity mask
+const maxAffinityCPUs = 1024
+
+// setThreadAffinity restricts the calling thread to cpus, the goroutine has to be locked to the thread
+func setThreadAffinity(cpus []int) error {
+	var mask [maxAffinityCPUs / 64]uint64
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= maxAffinityCPUs {
+			return fmt.Errorf("invalid CPU %d", cpu)
+		}
+		mask[cpu/64] |= 1 << (cpu % 64)
+	}
+	// pid 0 is the calling thread
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func currentCPU() (int, error) {
+	var nr uintptr
+	switch runtime.GOARCH {
+	case "amd64":
+		nr = 309
+	case "arm64", "riscv64", "loong64":
+		nr = 168
+	case "386":
+		nr = 318
+	case "arm":
+		nr = 345
+	default:
+		return -1, ErrAffinityNotSupported
+	}
+	var cpu uint32
+	if _, _, errno := syscall.RawSyscall(nr, uintptr(unsafe.Pointer(&cpu)), 0, 0); errno != 0 {
+		return -1, errno
+	}
+	return int(cpu), nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAffinityParamsCPUsFor(t *testing.T) {
+	params := AffinityParams{CPUs: []int{2, 3, 5}}
+	require.Equal(t, []int{2, 3, 5}, params.cpusFor(4))
+
+	params.Spread = true
+	require.Equal(t, []int{2}, params.cpusFor(0))
+	require.Equal(t, []int{5}, params.cpusFor(2))
+	require.Equal(t, []int{3}, params.cpusFor(4))
+
+	require.Empty(t, AffinityParams{Spread: true}.cpusFor(1))
+}
+
+func TestAffinityConn(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("affinity is only supported on linux")
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	ac := NewAffinityConn(conn, []int{0}, AffinityParams{SampleEvery: 1})
+	defer ac.Close()
+
+	pinned := make(chan error, 1)
+	ac.OnPinned(func(local net.Addr, cpus []int, err error) {
+		require.Equal(t, conn.LocalAddr(), local)
+		require.Equal(t, []int{0}, cpus)
+		pinned <- err
+	})
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	read := make(chan error)
+	go func() {
+		buf := make([]byte, 1500)
+		for i := 0; i < 3; i++ {
+			if _, _, err := ac.ReadFrom(buf); err != nil {
+				read <- err
+				return
+			}
+		}
+		read <- nil
+	}()
+	for i := 0; i < 3; i++ {
+		_, err = client.Write([]byte{1, 2, 3})
+		require.NoError(t, err)
+	}
+	require.NoError(t, <-pinned)
+	require.NoError(t, <-read)
+
+	stats := ac.Stats()
+	require.True(t, stats.IsPinned)
+	require.EqualValues(t, 3, stats.NumReads)
+	require.EqualValues(t, 3, stats.NumSampledReads)
+	require.Zero(t, stats.NumOffCPUReads)
+	require.Equal(t, 0, stats.LastCPU)
+}
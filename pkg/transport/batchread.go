@@ -0,0 +1,162 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	ErrBusyPollNotSupported = errors.New("busy polling is not supported on this platform")
+)
+
+type BatchReadParams struct {
+	// packets read with a single system call, where supported (recvmmsg on Linux), one elsewhere
+	MaxBatchSize int
+	// largest packet read, larger ones are truncated
+	MaxPacketSize int
+	// the kernel busy polls the device queue for up to this long when no packet is queued on the socket,
+	// trading CPU for fewer wakeups, 0 disables it (SO_BUSY_POLL, Linux only)
+	BusyPoll time.Duration
+}
+
+var BatchReadParamsDefault = BatchReadParams{
+	MaxBatchSize: 32,
+	// receive MTU of the ICE mux
+	MaxPacketSize: 8192,
+}
+
+type ReadLoopStats struct {
+	NumPackets uint64
+	NumBatches uint64
+	// time blocked waiting for packets and time spent by the reader handling them
+	WaitTime time.Duration
+	BusyTime time.Duration
+}
+
+// Utilization is the share of time the read loop spent handling packets rather than waiting for them.
+func (s ReadLoopStats) Utilization() float64 {
+	if s.WaitTime+s.BusyTime == 0 {
+		return 0
+	}
+	return float64(s.BusyTime) / float64(s.WaitTime+s.BusyTime)
+}
+
+// MeanBatchSize is the mean number of packets read per system call.
+func (s ReadLoopStats) MeanBatchSize() float64 {
+	if s.NumBatches == 0 {
+		return 0
+	}
+	return float64(s.NumPackets) / float64(s.NumBatches)
+}
+
+// ------------------------------------------------
+
+type batchReader interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// BatchReadConn reads packets from a UDP socket in batches and hands them to the reader one at a time,
+// reducing system calls and wakeups per packet under load. It measures the utilization of the read loop,
+// the time between handing out a packet and the next read is counted as time spent handling the packet.
+type BatchReadConn struct {
+	*net.UDPConn
+
+	params BatchReadParams
+	reader batchReader
+
+	lock       sync.Mutex
+	msgs       []ipv4.Message
+	numMsgs    int
+	next       int
+	lastReturn time.Time
+	stats      ReadLoopStats
+}
+
+func NewBatchReadConn(conn *net.UDPConn, params BatchReadParams) *BatchReadConn {
+	if params.MaxBatchSize <= 0 {
+		params.MaxBatchSize = BatchReadParamsDefault.MaxBatchSize
+	}
+	if params.MaxPacketSize <= 0 {
+		params.MaxPacketSize = BatchReadParamsDefault.MaxPacketSize
+	}
+
+	b := &BatchReadConn{
+		UDPConn: conn,
+		params:  params,
+		msgs:    make([]ipv4.Message, params.MaxBatchSize),
+	}
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() == nil {
+		b.reader = ipv6.NewPacketConn(conn)
+	} else {
+		b.reader = ipv4.NewPacketConn(conn)
+	}
+	for i := range b.msgs {
+		b.msgs[i].Buffers = [][]byte{make([]byte, params.MaxPacketSize)}
+	}
+	return b
+}
+
+// SetBusyPoll enables busy polling of the socket, see BatchReadParams.BusyPoll.
+func (b *BatchReadConn) SetBusyPoll(d time.Duration) error {
+	return setBusyPoll(b.UDPConn, d)
+}
+
+func (b *BatchReadConn) Stats() ReadLoopStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.stats
+}
+
+func (b *BatchReadConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	now := time.Now()
+	if !b.lastReturn.IsZero() {
+		b.stats.BusyTime += now.Sub(b.lastReturn)
+		b.lastReturn = time.Time{}
+	}
+
+	if b.next == b.numMsgs {
+		// no lock is needed while blocked, there is a single reader
+		b.lock.Unlock()
+		numMsgs, err := b.reader.ReadBatch(b.msgs, 0)
+		b.lock.Lock()
+
+		readAt := time.Now()
+		b.stats.WaitTime += readAt.Sub(now)
+		now = readAt
+		if err != nil {
+			return 0, nil, err
+		}
+		b.numMsgs = numMsgs
+		b.next = 0
+		b.stats.NumBatches++
+	}
+
+	msg := &b.msgs[b.next]
+	b.next++
+	b.stats.NumPackets++
+	b.lastReturn = now
+	return copy(p, msg.Buffers[0][:msg.N]), msg.Addr, nil
+}
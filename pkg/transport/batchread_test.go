@@ -0,0 +1,88 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchReadConn(t *testing.T) {
+	for _, network := range []string{"udp4", "udp6"} {
+		t.Run(network, func(t *testing.T) {
+			ip := net.IPv4(127, 0, 0, 1)
+			if network == "udp6" {
+				ip = net.IPv6loopback
+			}
+			conn, err := net.ListenUDP(network, &net.UDPAddr{IP: ip})
+			if err != nil {
+				t.Skipf("cannot listen on %s: %v", network, err)
+			}
+			bc := NewBatchReadConn(conn, BatchReadParams{MaxBatchSize: 8})
+			defer bc.Close()
+
+			client, err := net.DialUDP(network, nil, conn.LocalAddr().(*net.UDPAddr))
+			require.NoError(t, err)
+			defer client.Close()
+
+			const numPackets = 20
+			for i := 0; i < numPackets; i++ {
+				_, err = client.Write([]byte{byte(i), 1, 2})
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, bc.SetReadDeadline(time.Now().Add(time.Second)))
+			buf := make([]byte, 1500)
+			for i := 0; i < numPackets; i++ {
+				n, addr, err := bc.ReadFrom(buf)
+				require.NoError(t, err)
+				require.Equal(t, []byte{byte(i), 1, 2}, buf[:n])
+				require.Equal(t, client.LocalAddr().String(), addr.String())
+				time.Sleep(time.Millisecond)
+			}
+
+			stats := bc.Stats()
+			require.EqualValues(t, numPackets, stats.NumPackets)
+			require.LessOrEqual(t, stats.NumBatches, uint64(numPackets))
+			require.GreaterOrEqual(t, stats.MeanBatchSize(), 1.0)
+			require.Greater(t, stats.BusyTime, time.Duration(0))
+			require.Greater(t, stats.Utilization(), 0.0)
+			require.LessOrEqual(t, stats.Utilization(), 1.0)
+
+			require.NoError(t, bc.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+			_, _, err = bc.ReadFrom(buf)
+			var netErr net.Error
+			require.ErrorAs(t, err, &netErr)
+			require.True(t, netErr.Timeout())
+		})
+	}
+}
+
+func TestReadLoopStats(t *testing.T) {
+	require.Zero(t, ReadLoopStats{}.Utilization())
+	require.Zero(t, ReadLoopStats{}.MeanBatchSize())
+
+	stats := ReadLoopStats{
+		NumPackets: 30,
+		NumBatches: 10,
+		WaitTime:   3 * time.Second,
+		BusyTime:   time.Second,
+	}
+	require.Equal(t, 0.25, stats.Utilization())
+	require.Equal(t, 3.0, stats.MeanBatchSize())
+}
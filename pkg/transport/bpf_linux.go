@@ -0,0 +1,345 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (ebpf || afxdp)
+// +build linux
+// +build ebpf afxdp
+
+package transport
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	bpfCmdMapCreate     = 0
+	bpfCmdMapUpdateElem = 2
+	bpfCmdMapDeleteElem = 3
+	bpfCmdProgLoad      = 5
+	bpfCmdLinkCreate    = 28
+
+	bpfMapTypeHash    = 1
+	bpfMapTypeLRUHash = 9
+	bpfMapTypeXSKMap  = 17
+
+	bpfProgTypeSocketFilter = 1
+	bpfProgTypeXDP          = 6
+
+	bpfPseudoMapFD = 1
+
+	bpfFuncMapLookupElem = 1
+	bpfFuncMapUpdateElem = 2
+)
+
+func sysBPF() (uintptr, error) {
+	switch runtime.GOARCH {
+	case "amd64":
+		return 321, nil
+	case "arm64", "riscv64", "loong64":
+		return 280, nil
+	case "386":
+		return 357, nil
+	case "arm":
+		return 386, nil
+	case "ppc64", "ppc64le":
+		return 361, nil
+	case "s390x":
+		return 351, nil
+	default:
+		return 0, ErrReusePortNotSupported
+	}
+}
+
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (int, error) {
+	nr, err := sysBPF()
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(nr, cmd, uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func bpfMapCreate(mapType uint32, keySize uint32, valueSize uint32, maxEntries int) (int, error) {
+	attr := struct {
+		mapType    uint32
+		keySize    uint32
+		valueSize  uint32
+		maxEntries uint32
+		mapFlags   uint32
+	}{
+		mapType:    mapType,
+		keySize:    keySize,
+		valueSize:  valueSize,
+		maxEntries: uint32(maxEntries),
+	}
+	return bpf(bpfCmdMapCreate, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+func bpfMapUpdate(mapFD int, key unsafe.Pointer, value unsafe.Pointer) error {
+	attr := struct {
+		mapFD uint32
+		_     uint32
+		key   uint64
+		value uint64
+		flags uint64
+	}{
+		mapFD: uint32(mapFD),
+		key:   uint64(uintptr(key)),
+		value: uint64(uintptr(value)),
+	}
+	_, err := bpf(bpfCmdMapUpdateElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(key)
+	runtime.KeepAlive(value)
+	return err
+}
+
+func bpfMapDelete(mapFD int, key unsafe.Pointer) error {
+	attr := struct {
+		mapFD uint32
+		_     uint32
+		key   uint64
+	}{
+		mapFD: uint32(mapFD),
+		key:   uint64(uintptr(key)),
+	}
+	_, err := bpf(bpfCmdMapDeleteElem, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(key)
+	return err
+}
+
+// bpfLinkCreate attaches a program to an interface, it is detached when the returned link is closed
+func bpfLinkCreate(progFD int, ifindex int, attachType uint32, flags uint32) (int, error) {
+	attr := struct {
+		progFD     uint32
+		ifindex    uint32
+		attachType uint32
+		flags      uint32
+	}{
+		progFD:     uint32(progFD),
+		ifindex:    uint32(ifindex),
+		attachType: attachType,
+		flags:      flags,
+	}
+	return bpf(bpfCmdLinkCreate, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+func bpfProgLoad(progType uint32, expectedAttachType uint32, insns []bpfInsn) (int, error) {
+	license := []byte("Apache-2.0\x00")
+	attr := struct {
+		progType    uint32
+		insnCnt     uint32
+		insns       uint64
+		license     uint64
+		logLevel    uint32
+		logSize     uint32
+		logBuf      uint64
+		kernVersion uint32
+		progFlags   uint32
+		progName    [16]byte
+		progIfindex uint32
+		// needed by link based attachment
+		expectedAttachType uint32
+	}{
+		progType:           progType,
+		expectedAttachType: expectedAttachType,
+		insnCnt:            uint32(len(insns)),
+		insns:              uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:            uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+	fd, err := bpf(bpfCmdProgLoad, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	if err == syscall.EACCES || err == syscall.EINVAL {
+		// load again with the verifier log to report why the program was rejected
+		log := make([]byte, 1<<16)
+		attr.logLevel = 1
+		attr.logSize = uint32(len(log))
+		attr.logBuf = uint64(uintptr(unsafe.Pointer(&log[0])))
+		if _, logErr := bpf(bpfCmdProgLoad, unsafe.Pointer(&attr), unsafe.Sizeof(attr)); logErr != nil {
+			err = fmt.Errorf("%w: %s", err, trimLog(log))
+		}
+		runtime.KeepAlive(log)
+	}
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+	return fd, err
+}
+
+func trimLog(log []byte) string {
+	for i, b := range log {
+		if b == 0 {
+			return string(log[:i])
+		}
+	}
+	return string(log)
+}
+
+// ------------------------------------------------
+
+const (
+	bpfLDX   = 0x01
+	bpfST    = 0x02
+	bpfSTX   = 0x03
+	bpfALU   = 0x04
+	bpfJMP   = 0x05
+	bpfALU64 = 0x07
+
+	bpfW  = 0x00
+	bpfH  = 0x08
+	bpfB  = 0x10
+	bpfDW = 0x18
+
+	bpfIMM = 0x00
+	bpfMEM = 0x60
+
+	bpfK = 0x00
+	bpfX = 0x08
+
+	bpfADD = 0x00
+	bpfSUB = 0x10
+	bpfMUL = 0x20
+	bpfAND = 0x50
+	bpfLSH = 0x60
+	bpfMOD = 0x90
+	bpfMOV = 0xb0
+	bpfEND = 0xd0
+
+	bpfToBE = 0x08
+
+	bpfJA   = 0x00
+	bpfJEQ  = 0x10
+	bpfJGT  = 0x20
+	bpfJNE  = 0x50
+	bpfCALL = 0x80
+	bpfEXIT = 0x90
+	bpfJLE  = 0xb0
+	bpfJSLE = 0xd0
+)
+
+type bpfInsn struct {
+	op   uint8
+	regs uint8
+	off  int16
+	imm  int32
+}
+
+type bpfFixup struct {
+	idx   int
+	label string
+}
+
+// bpfAsm assembles eBPF instructions, jumps refer to labels resolved by assemble
+type bpfAsm struct {
+	insns  []bpfInsn
+	labels map[string]int
+	fixups []bpfFixup
+}
+
+func (a *bpfAsm) emit(op uint8, dst uint8, src uint8, off int16, imm int32) {
+	a.insns = append(a.insns, bpfInsn{op: op, regs: src<<4 | dst, off: off, imm: imm})
+}
+
+func (a *bpfAsm) label(name string) {
+	if a.labels == nil {
+		a.labels = make(map[string]int)
+	}
+	a.labels[name] = len(a.insns)
+}
+
+func (a *bpfAsm) mov64Imm(dst uint8, imm int32) {
+	a.emit(bpfALU64|bpfMOV|bpfK, dst, 0, 0, imm)
+}
+
+func (a *bpfAsm) mov64Reg(dst uint8, src uint8) {
+	a.emit(bpfALU64|bpfMOV|bpfX, dst, src, 0, 0)
+}
+
+func (a *bpfAsm) alu64Imm(op uint8, dst uint8, imm int32) {
+	a.emit(bpfALU64|op|bpfK, dst, 0, 0, imm)
+}
+
+func (a *bpfAsm) alu64Reg(op uint8, dst uint8, src uint8) {
+	a.emit(bpfALU64|op|bpfX, dst, src, 0, 0)
+}
+
+func (a *bpfAsm) alu32Imm(op uint8, dst uint8, imm int32) {
+	a.emit(bpfALU|op|bpfK, dst, 0, 0, imm)
+}
+
+func (a *bpfAsm) alu32Reg(op uint8, dst uint8, src uint8) {
+	a.emit(bpfALU|op|bpfX, dst, src, 0, 0)
+}
+
+func (a *bpfAsm) toBE(dst uint8, bits int32) {
+	a.emit(bpfALU|bpfEND|bpfToBE, dst, 0, 0, bits)
+}
+
+func (a *bpfAsm) ldxMem(size uint8, dst uint8, src uint8, off int16) {
+	a.emit(bpfLDX|bpfMEM|size, dst, src, off, 0)
+}
+
+func (a *bpfAsm) stMem(size uint8, dst uint8, off int16, imm int32) {
+	a.emit(bpfST|bpfMEM|size, dst, 0, off, imm)
+}
+
+func (a *bpfAsm) stxMem(size uint8, dst uint8, src uint8, off int16) {
+	a.emit(bpfSTX|bpfMEM|size, dst, src, off, 0)
+}
+
+func (a *bpfAsm) ldMapFD(dst uint8, fd int) {
+	a.emit(bpfDW|bpfIMM, dst, bpfPseudoMapFD, 0, int32(fd))
+	a.emit(0, 0, 0, 0, 0)
+}
+
+func (a *bpfAsm) stackPtr(dst uint8, off int32) {
+	a.mov64Reg(dst, 10)
+	a.alu64Imm(bpfADD, dst, off)
+}
+
+func (a *bpfAsm) call(fn int32) {
+	a.emit(bpfJMP|bpfCALL, 0, 0, 0, fn)
+}
+
+func (a *bpfAsm) exit() {
+	a.emit(bpfJMP|bpfEXIT, 0, 0, 0, 0)
+}
+
+func (a *bpfAsm) jmpImm(op uint8, dst uint8, imm int32, label string) {
+	a.fixups = append(a.fixups, bpfFixup{idx: len(a.insns), label: label})
+	a.emit(bpfJMP|op|bpfK, dst, 0, 0, imm)
+}
+
+func (a *bpfAsm) jmpReg(op uint8, dst uint8, src uint8, label string) {
+	a.fixups = append(a.fixups, bpfFixup{idx: len(a.insns), label: label})
+	a.emit(bpfJMP|op|bpfX, dst, src, 0, 0)
+}
+
+func (a *bpfAsm) ja(label string) {
+	a.jmpImm(bpfJA, 0, 0, label)
+}
+
+func (a *bpfAsm) assemble() ([]bpfInsn, error) {
+	for _, f := range a.fixups {
+		target, ok := a.labels[f.label]
+		if !ok {
+			return nil, fmt.Errorf("undefined label %s", f.label)
+		}
+		a.insns[f.idx].off = int16(target - f.idx - 1)
+	}
+	return a.insns, nil
+}
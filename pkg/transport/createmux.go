@@ -24,6 +24,8 @@ import (
 	"github.com/pion/transport/v2"
 	"github.com/pion/transport/v2/stdnet"
 	tudp "github.com/pion/transport/v2/udp"
+
+	"github.com/livekit/protocol/logger"
 )
 
 // Functions to create UDPMuxes from ports, most code are copied from pion/ice package as the PR
@@ -52,24 +54,60 @@ func CreateUDPMuxesFromPorts(ports []int, opts ...UDPMuxFromPortOption) ([]ice.U
 		return nil, err
 	}
 
-	conns := make([]net.PacketConn, 0, len(ports)*len(ips))
+	// sockets of a port, more than one with SO_REUSEPORT steering
+	groups := make([][]net.PacketConn, 0, len(ports)*len(ips))
+	closeAll := func() {
+		for _, group := range groups {
+			for _, conn := range group {
+				_ = conn.Close()
+			}
+		}
+	}
+	numSockets := 0
 	for _, ip := range ips {
 		for _, port := range ports {
-			conn, listenErr := params.net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port})
-			if listenErr != nil {
-				err = listenErr
-				break
-			}
-			if params.readBufferSize > 0 {
-				_ = conn.SetReadBuffer(params.readBufferSize)
+			var sockets []transport.UDPConn
+			if params.reusePort != nil {
+				udpConns, listenErr := listenReusePort(ip, port, *params.reusePort)
+				if listenErr != nil {
+					err = listenErr
+					break
+				}
+				for _, udpConn := range udpConns {
+					sockets = append(sockets, udpConn)
+				}
+			} else {
+				conn, listenErr := params.net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port})
+				if listenErr != nil {
+					err = listenErr
+					break
+				}
+				sockets = append(sockets, conn)
 			}
-			if params.writeBufferSize > 0 {
-				_ = conn.SetWriteBuffer(params.writeBufferSize)
+
+			group := make([]net.PacketConn, 0, len(sockets))
+			for i, conn := range sockets {
+				// keepalive responses are steered to the first socket
+				pc, wrapErr := wrapMuxConn(conn, ip, &params, i == 0)
+				if wrapErr != nil {
+					err = wrapErr
+					for _, c := range sockets[i+1:] {
+						_ = c.Close()
+					}
+					break
+				}
+				if params.affinity != nil {
+					// outermost, it is read by the mux read goroutine
+					ac := NewAffinityConn(pc, params.affinity.cpusFor(numSockets), *params.affinity)
+					ac.OnPinned(params.onPinned)
+					pc = ac
+				}
+				numSockets++
+				group = append(group, pc)
 			}
-			if params.batchWriteSize > 0 {
-				conns = append(conns, tudp.NewBatchConn(conn, params.batchWriteSize, params.batchWriteInterval))
-			} else {
-				conns = append(conns, conn)
+			groups = append(groups, group)
+			if err != nil {
+				break
 			}
 		}
 		if err != nil {
@@ -78,25 +116,114 @@ func CreateUDPMuxesFromPorts(ports []int, opts ...UDPMuxFromPortOption) ([]ice.U
 	}
 
 	if err != nil {
-		for _, conn := range conns {
-			_ = conn.Close()
-		}
+		closeAll()
 		return nil, err
 	}
 
-	muxes := make([]ice.UDPMux, 0, len(conns))
-	for _, conn := range conns {
-		mux := ice.NewUDPMuxDefault(ice.UDPMuxParams{
-			Logger:  params.logger,
-			UDPConn: conn,
-			Net:     params.net,
-		})
-		muxes = append(muxes, mux)
+	muxes := make([]ice.UDPMux, 0, len(groups))
+	for _, group := range groups {
+		groupMuxes := make([]ice.UDPMux, 0, len(group))
+		for _, conn := range group {
+			groupMuxes = append(groupMuxes, ice.NewUDPMuxDefault(ice.UDPMuxParams{
+				Logger:  params.logger,
+				UDPConn: conn,
+				Net:     params.net,
+			}))
+		}
+		if len(groupMuxes) == 1 {
+			muxes = append(muxes, groupMuxes[0])
+		} else {
+			muxes = append(muxes, NewReusePortUDPMux(groupMuxes...))
+		}
 	}
 
 	return muxes, nil
 }
 
+// wrapMuxConn applies the socket options and wrappers of a mux port to conn, closing it on failure
+func wrapMuxConn(conn transport.UDPConn, ip net.IP, params *multiUDPMuxFromPortParam, withKeepalive bool) (net.PacketConn, error) {
+	var pc net.PacketConn = conn
+	udpConn, isUDPConn := conn.(*net.UDPConn)
+	if isUDPConn {
+		setSocketBuffers(udpConn, params.readBufferSize, params.writeBufferSize)
+	} else {
+		// virtual networks, nothing to tune
+		if params.readBufferSize > 0 {
+			_ = conn.SetReadBuffer(params.readBufferSize)
+		}
+		if params.writeBufferSize > 0 {
+			_ = conn.SetWriteBuffer(params.writeBufferSize)
+		}
+	}
+	// ECN, batch writes and IPv6 QoS read from the socket or wrap it, the read path cannot be replaced
+	isReadPathFree := params.ecn == nil && params.batchWriteSize == 0 && (params.ipv6QoS == nil || ip.To4() != nil)
+	if isUDPConn && params.xdp != nil {
+		if !isReadPathFree {
+			logger.Infow("AF_XDP receive path not used with ECN, batch writes or IPv6 QoS", "local", conn.LocalAddr())
+		} else if xc, err := NewXDPConn(udpConn, *params.xdp); err != nil {
+			logger.Infow("AF_XDP receive path not available, using socket", "local", conn.LocalAddr(), "error", err)
+		} else {
+			pc = xc
+		}
+	}
+	if isUDPConn && params.batchRead != nil && pc == conn {
+		if !isReadPathFree {
+			logger.Infow("batched reads not used with ECN, batch writes or IPv6 QoS", "local", conn.LocalAddr())
+		} else {
+			bc := NewBatchReadConn(udpConn, *params.batchRead)
+			if params.batchRead.BusyPoll > 0 {
+				if err := bc.SetBusyPoll(params.batchRead.BusyPoll); err != nil {
+					logger.Infow("could not enable busy polling", "local", conn.LocalAddr(), "error", err)
+				}
+			}
+			if params.onBatchReadConn != nil {
+				params.onBatchReadConn(bc)
+			}
+			pc = bc
+		}
+	}
+	if isUDPConn && params.ipv6QoS != nil && ip.To4() == nil {
+		qosParams := *params.ipv6QoS
+		if params.ecn != nil {
+			qosParams.ECN = params.ecn.Mark
+		}
+		qc, err := NewIPv6QoSConn(udpConn, qosParams)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		// batched writes bypass the per kind classes, only the default set on the socket applies
+		if params.batchWriteSize == 0 {
+			pc = qc
+		}
+	}
+	if params.batchWriteSize > 0 {
+		pc = tudp.NewBatchConn(conn, params.batchWriteSize, params.batchWriteInterval)
+	}
+	if isUDPConn && params.ecn != nil {
+		// reads from the socket directly, writes still go through pc, e.g. batching
+		ec, err := NewECNConn(pc, udpConn, *params.ecn)
+		if err != nil {
+			_ = pc.Close()
+			return nil, err
+		}
+		ec.OnECN(params.onECN)
+		pc = ec
+	}
+	// loopback and link local ports are not behind a NAT
+	if withKeepalive && params.keepalive != nil && !ip.IsLoopback() && !ip.IsLinkLocalUnicast() {
+		kc := NewKeepaliveConn(pc, *params.keepalive)
+		kc.resolve = params.net.ResolveUDPAddr
+		kc.OnEvent(params.onKeepaliveEvent)
+		kc.Start()
+		pc = kc
+	}
+	if params.dtlsLimiter != nil {
+		pc = NewDTLSLimitConn(pc, params.dtlsLimiter)
+	}
+	return pc, nil
+}
+
 // UDPMuxFromPortOption provide options for NewMultiUDPMuxFromPort
 type UDPMuxFromPortOption interface {
 	apply(*multiUDPMuxFromPortParam)
@@ -113,6 +240,18 @@ type multiUDPMuxFromPortParam struct {
 	net                transport.Net
 	batchWriteSize     int
 	batchWriteInterval time.Duration
+	keepalive          *KeepaliveParams
+	onKeepaliveEvent   func(event KeepaliveEvent)
+	ipv6QoS            *IPv6QoSParams
+	ecn                *ECNParams
+	onECN              ECNObserver
+	reusePort          *ReusePortParams
+	xdp                *XDPParams
+	affinity           *AffinityParams
+	onPinned           AffinityObserver
+	batchRead          *BatchReadParams
+	onBatchReadConn    func(conn *BatchReadConn)
+	dtlsLimiter        *DTLSHandshakeLimiter
 }
 
 type udpMuxFromPortOption struct {
@@ -204,3 +343,90 @@ func UDPMuxFromPortWithBatchWrite(batchWriteSize int, batchWriteInterval time.Du
 		},
 	}
 }
+
+// UDPMuxFromPortWithKeepalive keeps NAT mappings of the mux ports open with STUN keepalives
+func UDPMuxFromPortWithKeepalive(params KeepaliveParams, onEvent func(event KeepaliveEvent)) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.keepalive = &params
+			p.onKeepaliveEvent = onEvent
+		},
+	}
+}
+
+// UDPMuxFromPortWithIPv6QoS sets the traffic class and flow label of media sent from IPv6 mux ports
+func UDPMuxFromPortWithIPv6QoS(params IPv6QoSParams) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.ipv6QoS = &params
+		},
+	}
+}
+
+// UDPMuxFromPortWithECN marks packets sent from the mux ports and reports the ECN codepoint of received ones
+func UDPMuxFromPortWithECN(params ECNParams, onECN ECNObserver) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.ecn = &params
+			p.onECN = onECN
+		},
+	}
+}
+
+// UDPMuxFromPortWithReusePort listens with several sockets on each port of the host network, steering the packets of
+// a connection to the same socket, see ReusePortParams. Needs a Linux build with the ebpf tag, see ReusePortSupported.
+func UDPMuxFromPortWithReusePort(params ReusePortParams) UDPMuxFromPortOption {
+	if params.NumSockets <= 0 {
+		params.NumSockets = ReusePortParamsDefault.NumSockets
+	}
+	if params.MaxRemoteAddrs <= 0 {
+		params.MaxRemoteAddrs = ReusePortParamsDefault.MaxRemoteAddrs
+	}
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.reusePort = &params
+		},
+	}
+}
+
+// UDPMuxFromPortWithXDP receives packets to the mux ports through the experimental AF_XDP receive path,
+// see XDPParams. Ports fall back to the socket when it is not available, see XDPSupported.
+func UDPMuxFromPortWithXDP(params XDPParams) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.xdp = &params
+		},
+	}
+}
+
+// UDPMuxFromPortWithAffinity pins the threads of the mux read goroutines to CPUs, see AffinityParams.
+// onPinned reports the outcome for each socket, pinning is best effort and the mux works without it.
+func UDPMuxFromPortWithAffinity(params AffinityParams, onPinned AffinityObserver) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.affinity = &params
+			p.onPinned = onPinned
+		},
+	}
+}
+
+// UDPMuxFromPortWithBatchRead reads packets of the mux ports in batches, see BatchReadParams.
+// onConn is called with each conn created, for example to collect its read loop stats.
+func UDPMuxFromPortWithBatchRead(params BatchReadParams, onConn func(conn *BatchReadConn)) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.batchRead = &params
+			p.onBatchReadConn = onConn
+		},
+	}
+}
+
+// UDPMuxFromPortWithDTLSHandshakeLimit drops new DTLS handshakes above the rate of their source,
+// the limiter is shared by all mux ports.
+func UDPMuxFromPortWithDTLSHandshakeLimit(limiter *DTLSHandshakeLimiter) UDPMuxFromPortOption {
+	return &udpMuxFromPortOption{
+		f: func(p *multiUDPMuxFromPortParam) {
+			p.dtlsLimiter = limiter
+		},
+	}
+}
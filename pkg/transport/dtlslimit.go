@@ -0,0 +1,223 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/livekit/mediatransportutil/pkg/ratelimit"
+)
+
+const (
+	dtlsContentTypeHandshake     = 22
+	dtlsHandshakeTypeClientHello = 1
+
+	// record header, handshake header, client version and random
+	dtlsRecordHeaderSize     = 13
+	dtlsHandshakeHeaderSize  = 12
+	dtlsClientHelloFixedSize = 2 + 32
+)
+
+type DTLSHandshakeLimitParams struct {
+	// new handshakes per second allowed from a source IP
+	Rate float64
+	// handshakes a source may start at once above the rate
+	Burst int
+	// sources tracked, the least recently seen is forgotten beyond this
+	MaxSources int
+}
+
+var DTLSHandshakeLimitParamsDefault = DTLSHandshakeLimitParams{
+	Rate:       2,
+	Burst:      10,
+	MaxSources: 65536,
+}
+
+type DTLSHandshakeLimitStats struct {
+	NumHandshakes        uint64
+	NumDroppedHandshakes uint64
+	NumSources           int
+}
+
+type dtlsSource struct {
+	bucket   ratelimit.TokenBucket
+	lastSeen time.Time
+}
+
+// DTLSHandshakeLimiter limits the rate of new DTLS handshakes per source IP on mux ports. A new handshake is a
+// ClientHello without a cookie, the one answered with a HelloVerifyRequest. ClientHellos echoing a cookie come from
+// a source that received the request, they are not limited, the DTLS server verifies the cookie.
+// One limiter is shared by all mux ports so that a source cannot spread a flood over them.
+type DTLSHandshakeLimiter struct {
+	params DTLSHandshakeLimitParams
+
+	lock      sync.Mutex
+	sources   map[netip.Addr]*dtlsSource
+	lastSweep time.Time
+	stats     DTLSHandshakeLimitStats
+	onLimited func(addr net.Addr)
+}
+
+func NewDTLSHandshakeLimiter(params DTLSHandshakeLimitParams) *DTLSHandshakeLimiter {
+	if params.Rate <= 0 {
+		params.Rate = DTLSHandshakeLimitParamsDefault.Rate
+	}
+	if params.Burst <= 0 {
+		params.Burst = DTLSHandshakeLimitParamsDefault.Burst
+	}
+	if params.MaxSources <= 0 {
+		params.MaxSources = DTLSHandshakeLimitParamsDefault.MaxSources
+	}
+	return &DTLSHandshakeLimiter{
+		params:  params,
+		sources: make(map[netip.Addr]*dtlsSource),
+	}
+}
+
+// OnLimited sets a callback invoked with the source of each dropped handshake, it is called on the read path
+// and must not block.
+func (l *DTLSHandshakeLimiter) OnLimited(f func(addr net.Addr)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.onLimited = f
+}
+
+// Allow returns false if the packet from addr is a new handshake above the rate of its source.
+// Other packets are always allowed.
+func (l *DTLSHandshakeLimiter) Allow(b []byte, addr net.Addr) bool {
+	if !isDTLSNewHandshake(b) {
+		return true
+	}
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return true
+	}
+	ip, _ := netip.AddrFromSlice(udpAddr.IP)
+
+	allowed, onLimited := l.allow(ip.Unmap(), time.Now())
+	if !allowed && onLimited != nil {
+		onLimited(addr)
+	}
+	return allowed
+}
+
+func (l *DTLSHandshakeLimiter) allow(ip netip.Addr, now time.Time) (bool, func(addr net.Addr)) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	source := l.sources[ip]
+	if source == nil {
+		if len(l.sources) >= l.params.MaxSources {
+			l.evictLocked(now)
+		}
+		source = &dtlsSource{
+			bucket: ratelimit.NewTokenBucket(l.params.Rate, float64(l.params.Burst)),
+		}
+		l.sources[ip] = source
+	}
+	source.lastSeen = now
+
+	if !source.bucket.AllowAt(now, 1) {
+		l.stats.NumDroppedHandshakes++
+		return false, l.onLimited
+	}
+	l.stats.NumHandshakes++
+	return true, nil
+}
+
+// evictLocked makes room for a source. Sources idle long enough to be back at the full burst are forgotten
+// without loss, swept at most once per refill period, otherwise an arbitrary source is forgotten.
+func (l *DTLSHandshakeLimiter) evictLocked(now time.Time) {
+	refill := time.Duration(float64(l.params.Burst) / l.params.Rate * float64(time.Second))
+	if now.Sub(l.lastSweep) >= refill {
+		l.lastSweep = now
+		for ip, source := range l.sources {
+			if now.Sub(source.lastSeen) >= refill {
+				delete(l.sources, ip)
+			}
+		}
+		if len(l.sources) < l.params.MaxSources {
+			return
+		}
+	}
+	for ip := range l.sources {
+		delete(l.sources, ip)
+		return
+	}
+}
+
+func (l *DTLSHandshakeLimiter) Stats() DTLSHandshakeLimitStats {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	stats := l.stats
+	stats.NumSources = len(l.sources)
+	return stats
+}
+
+// isDTLSNewHandshake returns true for the first fragment of a ClientHello with an empty cookie
+func isDTLSNewHandshake(b []byte) bool {
+	if len(b) < dtlsRecordHeaderSize+dtlsHandshakeHeaderSize || b[0] != dtlsContentTypeHandshake {
+		return false
+	}
+	hs := b[dtlsRecordHeaderSize:]
+	if hs[0] != dtlsHandshakeTypeClientHello {
+		return false
+	}
+	// fragment offset
+	if hs[6] != 0 || hs[7] != 0 || hs[8] != 0 {
+		return false
+	}
+	body := hs[dtlsHandshakeHeaderSize:]
+	if len(body) < dtlsClientHelloFixedSize+1 {
+		// truncated, treated as new
+		return true
+	}
+	sessionIDLen := int(body[dtlsClientHelloFixedSize])
+	cookieLenOffset := dtlsClientHelloFixedSize + 1 + sessionIDLen
+	if len(body) <= cookieLenOffset {
+		return true
+	}
+	return body[cookieLenOffset] == 0
+}
+
+// ------------------------------------------------
+
+// DTLSLimitConn drops new DTLS handshakes above the rate of their source before they reach the mux.
+type DTLSLimitConn struct {
+	net.PacketConn
+
+	limiter *DTLSHandshakeLimiter
+}
+
+func NewDTLSLimitConn(pc net.PacketConn, limiter *DTLSHandshakeLimiter) *DTLSLimitConn {
+	return &DTLSLimitConn{
+		PacketConn: pc,
+		limiter:    limiter,
+	}
+}
+
+func (c *DTLSLimitConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil || c.limiter.Allow(b[:n], addr) {
+			return n, addr, err
+		}
+	}
+}
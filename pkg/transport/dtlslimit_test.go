@@ -0,0 +1,122 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func dtlsClientHello(cookie []byte) []byte {
+	body := make([]byte, 0, 64)
+	body = append(body, 0xfe, 0xfd)
+	body = append(body, make([]byte, 32)...)
+	// empty session ID
+	body = append(body, 0)
+	body = append(body, byte(len(cookie)))
+	body = append(body, cookie...)
+	// cipher suites and compression methods
+	body = append(body, 0, 2, 0xc0, 0x2b, 1, 0)
+
+	b := []byte{dtlsContentTypeHandshake, 0xfe, 0xff, 0, 0, 0, 0, 0, 0, 0, 0, 0, byte(dtlsHandshakeHeaderSize + len(body))}
+	b = append(b, dtlsHandshakeTypeClientHello, 0, 0, byte(len(body)), 0, 0, 0, 0, 0, 0, 0, byte(len(body)))
+	return append(b, body...)
+}
+
+func TestIsDTLSNewHandshake(t *testing.T) {
+	require.True(t, isDTLSNewHandshake(dtlsClientHello(nil)))
+	require.False(t, isDTLSNewHandshake(dtlsClientHello([]byte{1, 2, 3, 4})))
+
+	// later fragment
+	fragment := dtlsClientHello(nil)
+	fragment[dtlsRecordHeaderSize+8] = 10
+	require.False(t, isDTLSNewHandshake(fragment))
+
+	// application data and STUN
+	require.False(t, isDTLSNewHandshake(append([]byte{23}, make([]byte, 40)...)))
+	require.False(t, isDTLSNewHandshake(make([]byte, 40)))
+}
+
+func TestDTLSHandshakeLimiter(t *testing.T) {
+	l := NewDTLSHandshakeLimiter(DTLSHandshakeLimitParams{Rate: 1, Burst: 2, MaxSources: 2})
+	ip1 := netip.MustParseAddr("192.0.2.1")
+	ip2 := netip.MustParseAddr("192.0.2.2")
+	ip3 := netip.MustParseAddr("192.0.2.3")
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		allowed, _ := l.allow(ip1, now)
+		require.True(t, allowed)
+	}
+	allowed, _ := l.allow(ip1, now)
+	require.False(t, allowed)
+	// other sources are not affected
+	allowed, _ = l.allow(ip2, now)
+	require.True(t, allowed)
+
+	// refilled at the rate
+	allowed, _ = l.allow(ip1, now.Add(time.Second))
+	require.True(t, allowed)
+	allowed, _ = l.allow(ip1, now.Add(time.Second))
+	require.False(t, allowed)
+
+	// both sources are idle for a refill period and forgotten to make room
+	allowed, _ = l.allow(ip3, now.Add(3*time.Second))
+	require.True(t, allowed)
+	require.Equal(t, DTLSHandshakeLimitStats{NumHandshakes: 5, NumDroppedHandshakes: 2, NumSources: 1}, l.Stats())
+}
+
+func TestDTLSLimitConn(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	l := NewDTLSHandshakeLimiter(DTLSHandshakeLimitParams{Rate: 0.001, Burst: 1})
+	limited := make(chan net.Addr, 4)
+	l.OnLimited(func(addr net.Addr) {
+		limited <- addr
+	})
+	lc := NewDTLSLimitConn(conn, l)
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	for _, b := range [][]byte{
+		dtlsClientHello(nil),
+		// dropped
+		dtlsClientHello(nil),
+		dtlsClientHello([]byte{1, 2, 3, 4}),
+	} {
+		_, err = client.Write(b)
+		require.NoError(t, err)
+	}
+
+	buf := make([]byte, 1500)
+	_ = lc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := lc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.True(t, isDTLSNewHandshake(buf[:n]))
+
+	n, _, err = lc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.False(t, isDTLSNewHandshake(buf[:n]))
+
+	require.Equal(t, client.LocalAddr().String(), (<-limited).String())
+}
@@ -0,0 +1,230 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	ecnMask = 0x03
+
+	// enough for a traffic class and a TOS control message
+	ecnOOBSize = 64
+)
+
+var (
+	ErrECNNotSupported = errors.New("ECN is not supported on this platform")
+)
+
+// ECN is the ECN codepoint of a packet (RFC 3168), the two low bits of the TOS / traffic class octet.
+type ECN uint8
+
+const (
+	ECNNotECT ECN = 0
+	// L4S capable senders mark ECT(1) (RFC 9331)
+	ECNECT1 ECN = 1
+	ECNECT0 ECN = 2
+	// congestion experienced, set by the network on ECN capable packets
+	ECNCE ECN = 3
+)
+
+func (e ECN) String() string {
+	switch e {
+	case ECNNotECT:
+		return "Not-ECT"
+	case ECNECT1:
+		return "ECT(1)"
+	case ECNECT0:
+		return "ECT(0)"
+	case ECNCE:
+		return "CE"
+	default:
+		return fmt.Sprintf("%d", int(e))
+	}
+}
+
+type ECNParams struct {
+	// codepoint of sent packets, ECNNotECT only reads marks
+	Mark ECN
+}
+
+// ECNObserver is called with the ECN codepoint of each received datagram, for example to record
+// CE marks with the transport wide sequence number of an RTP packet for congestion control feedback.
+// It is called on the read path and must not block.
+type ECNObserver func(mark ECN, b []byte, from net.Addr)
+
+type ECNStats struct {
+	NumNotECT uint64
+	NumECT0   uint64
+	NumECT1   uint64
+	NumCE     uint64
+}
+
+// ECNConn reads the ECN codepoint of received datagrams and marks sent ones with ECNParams.Mark.
+// Writes go through the wrapped connection, so it can be layered over other wrappers of the same socket.
+type ECNConn struct {
+	net.PacketConn
+
+	udpConn *net.UDPConn
+	params  ECNParams
+
+	lock     sync.Mutex
+	observer ECNObserver
+
+	counts  [4]atomic.Uint64
+	oobPool sync.Pool
+}
+
+// NewECNConn wraps pc, udpConn is the socket underneath it. The mark is set on the socket,
+// keeping the DSCP bits of the traffic class.
+func NewECNConn(pc net.PacketConn, udpConn *net.UDPConn, params ECNParams) (*ECNConn, error) {
+	if params.Mark > ECNCE {
+		return nil, fmt.Errorf("invalid ECN mark %d", params.Mark)
+	}
+	if err := enableECNReceive(udpConn); err != nil {
+		return nil, err
+	}
+	if params.Mark != ECNNotECT {
+		if err := setSocketECN(udpConn, params.Mark); err != nil {
+			return nil, err
+		}
+	}
+	return &ECNConn{
+		PacketConn: pc,
+		udpConn:    udpConn,
+		params:     params,
+		oobPool: sync.Pool{
+			New: func() interface{} {
+				b := make([]byte, ecnOOBSize)
+				return &b
+			},
+		},
+	}, nil
+}
+
+func (c *ECNConn) OnECN(f ECNObserver) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.observer = f
+}
+
+func (c *ECNConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, _, err := c.ReadFromWithECN(b)
+	return n, addr, err
+}
+
+// ReadFromWithECN reads a datagram and its ECN codepoint, ECNNotECT when the platform does not report it.
+func (c *ECNConn) ReadFromWithECN(b []byte) (int, net.Addr, ECN, error) {
+	oob := c.oobPool.Get().(*[]byte)
+	defer c.oobPool.Put(oob)
+
+	n, oobn, _, addr, err := c.udpConn.ReadMsgUDP(b, *oob)
+	if err != nil {
+		return n, nil, ECNNotECT, err
+	}
+	mark := parseECN((*oob)[:oobn])
+	c.counts[mark].Add(1)
+
+	c.lock.Lock()
+	observer := c.observer
+	c.lock.Unlock()
+	if observer != nil {
+		observer(mark, b[:n], addr)
+	}
+	return n, addr, mark, nil
+}
+
+func (c *ECNConn) Stats() ECNStats {
+	return ECNStats{
+		NumNotECT: c.counts[ECNNotECT].Load(),
+		NumECT0:   c.counts[ECNECT0].Load(),
+		NumECT1:   c.counts[ECNECT1].Load(),
+		NumCE:     c.counts[ECNCE].Load(),
+	}
+}
+
+// ------------------------------------------------
+
+type CEEstimatorParams struct {
+	// weight of the newest window in the smoothed CE fraction
+	Gain float64
+}
+
+var CEEstimatorParamsDefault = CEEstimatorParams{
+	// as DCTCP (RFC 8257)
+	Gain: 1.0 / 16,
+}
+
+// CEEstimator smooths the fraction of CE marked packets over observation windows, typically one
+// round trip each, the congestion signal an L4S sender scales its rate reduction by.
+type CEEstimator struct {
+	params CEEstimatorParams
+
+	lock     sync.Mutex
+	numECT   int
+	numCE    int
+	fraction float64
+}
+
+func NewCEEstimator(params CEEstimatorParams) *CEEstimator {
+	if params.Gain <= 0 || params.Gain > 1 {
+		params.Gain = CEEstimatorParamsDefault.Gain
+	}
+	return &CEEstimator{
+		params: params,
+	}
+}
+
+// Observe counts a packet sent as ECN capable with its reported codepoint, others are ignored.
+func (e *CEEstimator) Observe(mark ECN) {
+	if mark == ECNNotECT {
+		return
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.numECT++
+	if mark == ECNCE {
+		e.numCE++
+	}
+}
+
+// Update ends the observation window and returns the smoothed CE fraction, a window without packets leaves it unchanged.
+func (e *CEEstimator) Update() float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.numECT != 0 {
+		windowFraction := float64(e.numCE) / float64(e.numECT)
+		e.fraction = (1-e.params.Gain)*e.fraction + e.params.Gain*windowFraction
+	}
+	e.numECT = 0
+	e.numCE = 0
+	return e.fraction
+}
+
+func (e *CEEstimator) Fraction() float64 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.fraction
+}
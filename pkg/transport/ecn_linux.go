@@ -0,0 +1,75 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+func isIPv6Socket(conn *net.UDPConn) bool {
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	return ok && addr.IP.To4() == nil
+}
+
+func enableECNReceive(conn *net.UDPConn) error {
+	isIPv6 := isIPv6Socket(conn)
+	return controlSocket(conn, func(fd int) error {
+		if isIPv6 {
+			if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVTCLASS, 1); err != nil {
+				return err
+			}
+			// IPv4 packets on a dual stack socket, fails on IPv6 only sockets
+			_ = syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+			return nil
+		}
+		return syscall.SetsockoptInt(fd, syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+	})
+}
+
+func setSocketECN(conn *net.UDPConn, mark ECN) error {
+	level, opt := syscall.IPPROTO_IP, syscall.IP_TOS
+	if isIPv6Socket(conn) {
+		level, opt = syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS
+	}
+	return controlSocket(conn, func(fd int) error {
+		tos, err := syscall.GetsockoptInt(fd, level, opt)
+		if err != nil {
+			return err
+		}
+		return syscall.SetsockoptInt(fd, level, opt, tos&^ecnMask|int(mark))
+	})
+}
+
+func parseECN(oob []byte) ECN {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return ECNNotECT
+	}
+	for _, msg := range msgs {
+		switch {
+		case msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_TOS && len(msg.Data) >= 1:
+			return ECN(msg.Data[0] & ecnMask)
+		case msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_TCLASS && len(msg.Data) >= 4:
+			// traffic class is a native int
+			return ECN(*(*int32)(unsafe.Pointer(&msg.Data[0])) & ecnMask)
+		}
+	}
+	return ECNNotECT
+}
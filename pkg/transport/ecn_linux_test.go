@@ -0,0 +1,104 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestECNConn(t *testing.T) {
+	for _, network := range []string{"udp4", "udp6"} {
+		t.Run(network, func(t *testing.T) {
+			ip := net.IPv4(127, 0, 0, 1)
+			if network == "udp6" {
+				ip = net.IPv6loopback
+			}
+			receiverConn, err := net.ListenUDP(network, &net.UDPAddr{IP: ip})
+			if err != nil {
+				t.Skipf("%s loopback not available", network)
+			}
+			defer receiverConn.Close()
+			receiver, err := NewECNConn(receiverConn, receiverConn, ECNParams{})
+			require.NoError(t, err)
+
+			var observed []ECN
+			receiver.OnECN(func(mark ECN, b []byte, from net.Addr) {
+				observed = append(observed, mark)
+				require.Equal(t, []byte("media"), b)
+			})
+
+			senderConn, err := net.ListenUDP(network, &net.UDPAddr{IP: ip})
+			require.NoError(t, err)
+			defer senderConn.Close()
+
+			receive := func() ECN {
+				require.NoError(t, receiverConn.SetReadDeadline(time.Now().Add(time.Second)))
+				b := make([]byte, 1500)
+				n, from, mark, err := receiver.ReadFromWithECN(b)
+				require.NoError(t, err)
+				require.Equal(t, senderConn.LocalAddr().String(), from.String())
+				require.Equal(t, "media", string(b[:n]))
+				return mark
+			}
+
+			_, err = senderConn.WriteTo([]byte("media"), receiverConn.LocalAddr())
+			require.NoError(t, err)
+			require.Equal(t, ECNNotECT, receive())
+
+			// marked, DSCP bits set earlier are kept
+			if network == "udp6" {
+				_, err = NewIPv6QoSConn(senderConn, IPv6QoSParams{Default: IPv6QoS{TrafficClass: 0xb8}})
+				require.NoError(t, err)
+			}
+			sender, err := NewECNConn(senderConn, senderConn, ECNParams{Mark: ECNECT1})
+			require.NoError(t, err)
+			_, err = sender.WriteTo([]byte("media"), receiverConn.LocalAddr())
+			require.NoError(t, err)
+			require.Equal(t, ECNECT1, receive())
+
+			sender, err = NewECNConn(senderConn, senderConn, ECNParams{Mark: ECNCE})
+			require.NoError(t, err)
+			_, err = sender.WriteTo([]byte("media"), receiverConn.LocalAddr())
+			require.NoError(t, err)
+			require.Equal(t, ECNCE, receive())
+
+			require.Equal(t, []ECN{ECNNotECT, ECNECT1, ECNCE}, observed)
+			require.Equal(t, ECNStats{NumNotECT: 1, NumECT1: 1, NumCE: 1}, receiver.Stats())
+			if network == "udp6" {
+				tclass, err := getSocketTrafficClass(senderConn)
+				require.NoError(t, err)
+				require.Equal(t, 0xb8|int(ECNCE), tclass)
+			}
+		})
+	}
+}
+
+func getSocketTrafficClass(conn *net.UDPConn) (int, error) {
+	var tclass int
+	err := controlSocket(conn, func(fd int) error {
+		var err error
+		tclass, err = syscall.GetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS)
+		return err
+	})
+	return tclass, err
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCEEstimator(t *testing.T) {
+	e := NewCEEstimator(CEEstimatorParams{Gain: 0.5})
+
+	// 1 in 4 ECN capable packets marked, not ECN capable ones do not count
+	for i := 0; i < 4; i++ {
+		e.Observe(ECNECT1)
+		e.Observe(ECNNotECT)
+	}
+	e.Observe(ECNCE)
+	e.Observe(ECNCE)
+	e.Observe(ECNECT1)
+	e.Observe(ECNECT1)
+	require.InDelta(t, 0.125, e.Update(), 1e-9)
+
+	// empty window keeps the estimate
+	require.InDelta(t, 0.125, e.Update(), 1e-9)
+
+	e.Observe(ECNCE)
+	require.InDelta(t, 0.5625, e.Update(), 1e-9)
+	require.InDelta(t, 0.5625, e.Fraction(), 1e-9)
+
+	require.Equal(t, "CE", ECNCE.String())
+	require.Equal(t, "ECT(1)", ECNECT1.String())
+}
+
+func TestIPv6QoSWithECN(t *testing.T) {
+	params := IPv6QoSParams{
+		Default: IPv6QoS{TrafficClass: 0x22},
+		Video:   IPv6QoS{FlowLabel: 5},
+		ECN:     ECNECT1,
+	}
+	require.Equal(t, 0x21, params.forKind(MediaKindAudio).TrafficClass)
+	// a kind with only a flow label leaves the class to the socket
+	require.Equal(t, IPv6QoS{FlowLabel: 5}, params.forKind(MediaKindVideo))
+}
@@ -0,0 +1,148 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// FilterPolicy decides whether an interface or address is used when its filter cannot be evaluated,
+// for example when a filter fails because an interface disappeared while it was running.
+type FilterPolicy int
+
+const (
+	// the interface or address is not used, the default
+	FilterPolicyFailClosed FilterPolicy = iota
+	// the interface or address is used as if the filter had allowed it
+	FilterPolicyFailOpen
+)
+
+func (f FilterPolicy) String() string {
+	switch f {
+	case FilterPolicyFailClosed:
+		return "FAIL_CLOSED"
+	case FilterPolicyFailOpen:
+		return "FAIL_OPEN"
+	default:
+		return fmt.Sprintf("%d", int(f))
+	}
+}
+
+// FilterFailure reports a filter that could not be evaluated, Interface or IP is set depending on the filter.
+type FilterFailure struct {
+	Interface string
+	IP        net.IP
+	Err       error
+	// outcome of the policy
+	Permitted bool
+}
+
+// FilterGuard applies a FilterPolicy to interface and IP filters which return errors or panic,
+// reporting each failure.
+type FilterGuard struct {
+	policy FilterPolicy
+
+	lock      sync.Mutex
+	onFailure func(failure FilterFailure)
+
+	numFailures atomic.Uint64
+}
+
+func NewFilterGuard(policy FilterPolicy) *FilterGuard {
+	return &FilterGuard{
+		policy: policy,
+	}
+}
+
+// OnFailure sets a callback invoked with each failure, it may be called during candidate gathering and must not block.
+func (g *FilterGuard) OnFailure(f func(failure FilterFailure)) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.onFailure = f
+}
+
+func (g *FilterGuard) Policy() FilterPolicy {
+	return g.policy
+}
+
+func (g *FilterGuard) NumFailures() uint64 {
+	return g.numFailures.Load()
+}
+
+// InterfaceFilter returns f as an interface filter, applying the policy when it returns an error or panics.
+func (g *FilterGuard) InterfaceFilter(f func(name string) (bool, error)) func(string) bool {
+	return func(name string) (allowed bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				allowed = g.fail(FilterFailure{Interface: name, Err: fmt.Errorf("interface filter panic: %v", r)})
+			}
+		}()
+
+		allowed, err := f(name)
+		if err != nil {
+			return g.fail(FilterFailure{Interface: name, Err: err})
+		}
+		return allowed
+	}
+}
+
+// IPFilter returns f as an IP filter, applying the policy when it returns an error or panics.
+func (g *FilterGuard) IPFilter(f func(ip net.IP) (bool, error)) func(net.IP) bool {
+	return func(ip net.IP) (allowed bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				allowed = g.fail(FilterFailure{IP: ip, Err: fmt.Errorf("IP filter panic: %v", r)})
+			}
+		}()
+
+		allowed, err := f(ip)
+		if err != nil {
+			return g.fail(FilterFailure{IP: ip, Err: err})
+		}
+		return allowed
+	}
+}
+
+// fail applies the policy to failure and reports it, returns whether the interface or address is used
+func (g *FilterGuard) fail(failure FilterFailure) bool {
+	failure.Permitted = g.policy == FilterPolicyFailOpen
+	g.numFailures.Add(1)
+
+	g.lock.Lock()
+	onFailure := g.onFailure
+	g.lock.Unlock()
+	if onFailure != nil {
+		onFailure(failure)
+	}
+	return failure.Permitted
+}
+
+// InterfaceFilterNoError adapts an interface filter which cannot fail for FilterGuard.InterfaceFilter.
+func InterfaceFilterNoError(f func(name string) bool) func(name string) (bool, error) {
+	return func(name string) (bool, error) {
+		return f(name), nil
+	}
+}
+
+// IPFilterNoError adapts an IP filter which cannot fail for FilterGuard.IPFilter.
+func IPFilterNoError(f func(ip net.IP) bool) func(ip net.IP) (bool, error) {
+	return func(ip net.IP) (bool, error) {
+		return f(ip), nil
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterGuard(t *testing.T) {
+	for _, policy := range []FilterPolicy{FilterPolicyFailClosed, FilterPolicyFailOpen} {
+		t.Run(policy.String(), func(t *testing.T) {
+			g := NewFilterGuard(policy)
+			var failures []FilterFailure
+			g.OnFailure(func(failure FilterFailure) {
+				failures = append(failures, failure)
+			})
+
+			ifFilter := g.InterfaceFilter(func(name string) (bool, error) {
+				switch name {
+				case "eth0":
+					return true, nil
+				case "gone0":
+					return false, errors.New("interface not found")
+				case "panic0":
+					panic("filter bug")
+				default:
+					return false, nil
+				}
+			})
+			require.True(t, ifFilter("eth0"))
+			require.False(t, ifFilter("eth1"))
+			require.Equal(t, policy == FilterPolicyFailOpen, ifFilter("gone0"))
+			require.Equal(t, policy == FilterPolicyFailOpen, ifFilter("panic0"))
+
+			ipFilter := g.IPFilter(func(ip net.IP) (bool, error) {
+				return false, errors.New("no interface")
+			})
+			require.Equal(t, policy == FilterPolicyFailOpen, ipFilter(net.IPv4(10, 0, 0, 1)))
+
+			require.Equal(t, uint64(3), g.NumFailures())
+			require.Len(t, failures, 3)
+			require.Equal(t, "gone0", failures[0].Interface)
+			require.Equal(t, "panic0", failures[1].Interface)
+			require.True(t, failures[2].IP.Equal(net.IPv4(10, 0, 0, 1)))
+			for _, failure := range failures {
+				require.Error(t, failure.Err)
+				require.Equal(t, policy == FilterPolicyFailOpen, failure.Permitted)
+			}
+		})
+	}
+
+	g := NewFilterGuard(FilterPolicyFailClosed)
+	require.True(t, g.IPFilter(IPFilterNoError(func(ip net.IP) bool { return true }))(net.IPv4(10, 0, 0, 1)))
+	require.False(t, g.InterfaceFilter(InterfaceFilterNoError(func(name string) bool { return false }))("eth0"))
+	require.Zero(t, g.NumFailures())
+}
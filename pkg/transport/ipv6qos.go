@@ -0,0 +1,230 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+)
+
+const (
+	maxTrafficClass = 0xff
+	maxFlowLabel    = 0xfffff
+)
+
+var (
+	ErrIPv6QoSNotSupported = errors.New("IPv6 traffic class and flow label are not supported on this platform")
+	ErrInvalidIPv6QoS      = errors.New("invalid IPv6 traffic class or flow label")
+)
+
+type MediaKind int
+
+const (
+	MediaKindOther MediaKind = iota
+	MediaKindAudio
+	MediaKindVideo
+	MediaKindData
+
+	numMediaKinds
+)
+
+func (k MediaKind) String() string {
+	switch k {
+	case MediaKindOther:
+		return "OTHER"
+	case MediaKindAudio:
+		return "AUDIO"
+	case MediaKindVideo:
+		return "VIDEO"
+	case MediaKindData:
+		return "DATA"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+// MediaKindRegistry maps SSRCs to media kinds, so packets can be classified on the socket.
+// Register SSRCs as tracks are published and subscribed, unknown SSRCs are MediaKindOther.
+type MediaKindRegistry struct {
+	lock  sync.RWMutex
+	ssrcs map[uint32]MediaKind
+}
+
+func NewMediaKindRegistry() *MediaKindRegistry {
+	return &MediaKindRegistry{
+		ssrcs: make(map[uint32]MediaKind),
+	}
+}
+
+func (r *MediaKindRegistry) Set(ssrc uint32, kind MediaKind) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.ssrcs[ssrc] = kind
+}
+
+func (r *MediaKindRegistry) Remove(ssrc uint32) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.ssrcs, ssrc)
+}
+
+func (r *MediaKindRegistry) KindOf(ssrc uint32) MediaKind {
+	if r == nil {
+		return MediaKindOther
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.ssrcs[ssrc]
+}
+
+// ClassifyPacket returns the media kind of an outgoing datagram, RTP and RTCP by SSRC as registered,
+// DTLS application data (data channels) as MediaKindData and everything else, like STUN, as MediaKindOther.
+// RTP and RTCP headers are not encrypted by SRTP, so this works on protected packets.
+func ClassifyPacket(b []byte, kinds *MediaKindRegistry) MediaKind {
+	if len(b) == 0 {
+		return MediaKindOther
+	}
+
+	// demultiplexing as in RFC 7983
+	switch {
+	case b[0] == 23:
+		// DTLS application data
+		return MediaKindData
+
+	case b[0] >= 128 && b[0] <= 191:
+		if len(b) >= 8 && b[1] >= 192 && b[1] <= 223 {
+			// RTCP, sender SSRC
+			return kinds.KindOf(binary.BigEndian.Uint32(b[4:8]))
+		}
+		if len(b) >= 12 {
+			return kinds.KindOf(binary.BigEndian.Uint32(b[8:12]))
+		}
+	}
+	return MediaKindOther
+}
+
+type IPv6QoS struct {
+	// traffic class octet, DSCP in the upper six bits, the ECN bits are taken from IPv6QoSParams.ECN
+	TrafficClass int
+	// zero leaves the flow label to the kernel. Kernels which only send leased labels get a lease
+	// from the flow label manager per destination, the label must then be below 0x80000 while
+	// net.ipv6.flowlabel_state_ranges is enabled.
+	FlowLabel uint32
+}
+
+func (q IPv6QoS) isSet() bool {
+	return q.TrafficClass != 0 || q.FlowLabel != 0
+}
+
+func (q IPv6QoS) validate() error {
+	if q.TrafficClass < 0 || q.TrafficClass > maxTrafficClass || q.FlowLabel > maxFlowLabel {
+		return ErrInvalidIPv6QoS
+	}
+	return nil
+}
+
+// IPv6QoSParams sets traffic class and flow label per media kind, kinds without values use Default.
+type IPv6QoSParams struct {
+	Default IPv6QoS
+	Audio   IPv6QoS
+	Video   IPv6QoS
+	Data    IPv6QoS
+
+	// classifies RTP and RTCP, nil sends all media with Default
+	Kinds *MediaKindRegistry
+	// ECN codepoint kept in the low bits of the traffic classes when the socket marks packets, see ECNConn
+	ECN ECN
+}
+
+func (p IPv6QoSParams) forKind(kind MediaKind) IPv6QoS {
+	var q IPv6QoS
+	switch kind {
+	case MediaKindAudio:
+		q = p.Audio
+	case MediaKindVideo:
+		q = p.Video
+	case MediaKindData:
+		q = p.Data
+	}
+	if !q.isSet() {
+		q = p.Default
+	}
+	if q.TrafficClass != 0 {
+		q.TrafficClass = q.TrafficClass&^ecnMask | int(p.ECN)
+	}
+	return q
+}
+
+// IPv6QoSConn sets the traffic class and flow label of each datagram sent to an IPv6 address by the media
+// kind of the datagram. The Default traffic class is also set on the socket, so it applies to writes which
+// bypass WriteTo, like batched writes. Datagrams to IPv4 addresses are sent unchanged.
+type IPv6QoSConn struct {
+	*net.UDPConn
+
+	params IPv6QoSParams
+	// control messages per media kind
+	oob [numMediaKinds][]byte
+}
+
+func NewIPv6QoSConn(conn *net.UDPConn, params IPv6QoSParams) (*IPv6QoSConn, error) {
+	for kind := MediaKind(0); kind < numMediaKinds; kind++ {
+		if err := params.forKind(kind).validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &IPv6QoSConn{
+		UDPConn: conn,
+		params:  params,
+	}
+	if err := setSocketTrafficClass(conn, params.forKind(MediaKindOther).TrafficClass); err != nil {
+		return nil, err
+	}
+
+	for kind := MediaKind(0); kind < numMediaKinds; kind++ {
+		oob, err := ipv6QoSControlMessage(params.forKind(kind))
+		if err != nil {
+			return nil, err
+		}
+		c.oob[kind] = oob
+	}
+	return c, nil
+}
+
+func (c *IPv6QoSConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok || udpAddr.IP.To4() != nil {
+		return c.UDPConn.WriteTo(b, addr)
+	}
+
+	kind := ClassifyPacket(b, c.params.Kinds)
+	n, _, err := c.UDPConn.WriteMsgUDP(b, c.oob[kind], udpAddr)
+	if flowLabel := c.params.forKind(kind).FlowLabel; flowLabel != 0 && errors.Is(err, syscall.EINVAL) {
+		// label not leased for the destination yet, leases are held until the socket is closed
+		if leaseErr := leaseFlowLabel(c.UDPConn, flowLabel, udpAddr.IP); leaseErr != nil {
+			return 0, leaseErr
+		}
+		n, _, err = c.UDPConn.WriteMsgUDP(b, c.oob[kind], udpAddr)
+	}
+	return n, err
+}
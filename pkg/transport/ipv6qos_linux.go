@@ -0,0 +1,98 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// from linux/in6.h
+const (
+	ipv6FlowInfo        = 11
+	ipv6FlowLabelMgr    = 32
+	ipv6FlowLabelGet    = 0
+	ipv6FlowLabelAny    = 255
+	ipv6FlowLabelCreate = 1
+
+	// struct in6_flowlabel_req
+	flowLabelReqSize = 32
+)
+
+func setSocketTrafficClass(conn *net.UDPConn, trafficClass int) error {
+	if trafficClass == 0 {
+		return nil
+	}
+	return controlSocket(conn, func(fd int) error {
+		return syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, trafficClass)
+	})
+}
+
+// leaseFlowLabel gets a flow label for dst from the flow label manager, some kernels
+// only send labels leased by the socket
+func leaseFlowLabel(conn *net.UDPConn, label uint32, dst net.IP) error {
+	req := make([]byte, flowLabelReqSize)
+	copy(req, dst.To16())
+	binary.BigEndian.PutUint32(req[16:], label)
+	req[20] = ipv6FlowLabelGet
+	req[21] = ipv6FlowLabelAny
+	*(*uint16)(unsafe.Pointer(&req[22])) = ipv6FlowLabelCreate
+	return controlSocket(conn, func(fd int) error {
+		return syscall.SetsockoptString(fd, syscall.IPPROTO_IPV6, ipv6FlowLabelMgr, string(req))
+	})
+}
+
+func ipv6QoSControlMessage(q IPv6QoS) ([]byte, error) {
+	var oob []byte
+	if q.TrafficClass != 0 {
+		b := make([]byte, syscall.CmsgSpace(4))
+		putCmsgHeader(b, syscall.IPV6_TCLASS, 4)
+		*(*int32)(unsafe.Pointer(&b[syscall.CmsgLen(0)])) = int32(q.TrafficClass)
+		oob = append(oob, b...)
+	}
+	if q.FlowLabel != 0 {
+		b := make([]byte, syscall.CmsgSpace(4))
+		putCmsgHeader(b, ipv6FlowInfo, 4)
+		binary.BigEndian.PutUint32(b[syscall.CmsgLen(0):], q.FlowLabel)
+		oob = append(oob, b...)
+	}
+	return oob, nil
+}
+
+func putCmsgHeader(b []byte, typ int32, dataLen int) {
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[0]))
+	h.Level = syscall.IPPROTO_IPV6
+	h.Type = typ
+	h.SetLen(syscall.CmsgLen(dataLen))
+}
+
+func controlSocket(conn *net.UDPConn, f func(fd int) error) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		opErr = f(int(fd))
+	}); err != nil {
+		return err
+	}
+	return opErr
+}
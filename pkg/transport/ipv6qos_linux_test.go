@@ -0,0 +1,99 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv6QoSConn(t *testing.T) {
+	receiver, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skip("IPv6 loopback not available")
+	}
+	defer receiver.Close()
+	require.NoError(t, controlSocket(receiver, func(fd int) error {
+		if err := syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_RECVTCLASS, 1); err != nil {
+			return err
+		}
+		return syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, ipv6FlowInfo, 1)
+	}))
+
+	sender, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	require.NoError(t, err)
+	defer sender.Close()
+
+	kinds := NewMediaKindRegistry()
+	kinds.Set(1, MediaKindAudio)
+	conn, err := NewIPv6QoSConn(sender, IPv6QoSParams{
+		Default: IPv6QoS{TrafficClass: 0x20},
+		Audio:   IPv6QoS{TrafficClass: 0xb8, FlowLabel: 0x1234},
+		Kinds:   kinds,
+	})
+	require.NoError(t, err)
+	require.NoError(t, leaseFlowLabel(sender, 0x1234, net.IPv6loopback))
+
+	receive := func() (int, uint32) {
+		require.NoError(t, receiver.SetReadDeadline(time.Now().Add(time.Second)))
+		b := make([]byte, 1500)
+		oob := make([]byte, 128)
+		_, oobn, _, _, err := receiver.ReadMsgUDP(b, oob)
+		require.NoError(t, err)
+
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		require.NoError(t, err)
+		trafficClass, flowLabel := -1, uint32(0)
+		for _, msg := range msgs {
+			switch msg.Header.Type {
+			case syscall.IPV6_TCLASS:
+				trafficClass = int(msg.Data[0])
+			case ipv6FlowInfo:
+				flowLabel = binary.BigEndian.Uint32(msg.Data) & maxFlowLabel
+			}
+		}
+		return trafficClass, flowLabel
+	}
+
+	audio, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1}}).Marshal()
+	require.NoError(t, err)
+	_, err = conn.WriteTo(audio, receiver.LocalAddr())
+	require.NoError(t, err)
+	trafficClass, flowLabel := receive()
+	require.Equal(t, 0xb8, trafficClass)
+	require.Equal(t, uint32(0x1234), flowLabel)
+
+	// unknown SSRC and batched writes bypassing WriteTo get the default class set on the socket
+	video, err := (&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 2}}).Marshal()
+	require.NoError(t, err)
+	_, err = conn.WriteTo(video, receiver.LocalAddr())
+	require.NoError(t, err)
+	trafficClass, _ = receive()
+	require.Equal(t, 0x20, trafficClass)
+
+	_, err = sender.WriteTo(audio, receiver.LocalAddr())
+	require.NoError(t, err)
+	trafficClass, _ = receive()
+	require.Equal(t, 0x20, trafficClass)
+}
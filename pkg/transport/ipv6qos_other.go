@@ -0,0 +1,40 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package transport
+
+import (
+	"net"
+)
+
+func setSocketTrafficClass(conn *net.UDPConn, trafficClass int) error {
+	if trafficClass == 0 {
+		return nil
+	}
+	return ErrIPv6QoSNotSupported
+}
+
+func leaseFlowLabel(conn *net.UDPConn, label uint32, dst net.IP) error {
+	return ErrIPv6QoSNotSupported
+}
+
+func ipv6QoSControlMessage(q IPv6QoS) ([]byte, error) {
+	if q.isSet() {
+		return nil, ErrIPv6QoSNotSupported
+	}
+	return nil, nil
+}
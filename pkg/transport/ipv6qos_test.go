@@ -0,0 +1,68 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyPacket(t *testing.T) {
+	kinds := NewMediaKindRegistry()
+	kinds.Set(1, MediaKindAudio)
+	kinds.Set(2, MediaKindVideo)
+
+	marshalRTP := func(ssrc uint32) []byte {
+		b, err := (&rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 111, SSRC: ssrc}, Payload: []byte{1}}).Marshal()
+		require.NoError(t, err)
+		return b
+	}
+	marshalRTCP := func(pkt rtcp.Packet) []byte {
+		b, err := pkt.Marshal()
+		require.NoError(t, err)
+		return b
+	}
+
+	require.Equal(t, MediaKindAudio, ClassifyPacket(marshalRTP(1), kinds))
+	require.Equal(t, MediaKindVideo, ClassifyPacket(marshalRTP(2), kinds))
+	require.Equal(t, MediaKindOther, ClassifyPacket(marshalRTP(3), kinds))
+	require.Equal(t, MediaKindOther, ClassifyPacket(marshalRTP(1), nil))
+	require.Equal(t, MediaKindVideo, ClassifyPacket(marshalRTCP(&rtcp.SenderReport{SSRC: 2}), kinds))
+	require.Equal(t, MediaKindAudio, ClassifyPacket(marshalRTCP(&rtcp.PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2}), kinds))
+	// DTLS application data and handshake
+	require.Equal(t, MediaKindData, ClassifyPacket([]byte{23, 0xfe, 0xfd}, kinds))
+	require.Equal(t, MediaKindOther, ClassifyPacket([]byte{22, 0xfe, 0xfd}, kinds))
+	// STUN
+	require.Equal(t, MediaKindOther, ClassifyPacket([]byte{0, 1, 0, 0}, kinds))
+
+	kinds.Remove(1)
+	require.Equal(t, MediaKindOther, ClassifyPacket(marshalRTP(1), kinds))
+}
+
+func TestIPv6QoSParams(t *testing.T) {
+	params := IPv6QoSParams{
+		Default: IPv6QoS{TrafficClass: 0x20},
+		Audio:   IPv6QoS{TrafficClass: 0xb8, FlowLabel: 1},
+	}
+	require.Equal(t, params.Audio, params.forKind(MediaKindAudio))
+	require.Equal(t, params.Default, params.forKind(MediaKindVideo))
+	require.Equal(t, params.Default, params.forKind(MediaKindOther))
+
+	require.ErrorIs(t, IPv6QoS{TrafficClass: 256}.validate(), ErrInvalidIPv6QoS)
+	require.ErrorIs(t, IPv6QoS{FlowLabel: 1 << 20}.validate(), ErrInvalidIPv6QoS)
+}
@@ -0,0 +1,335 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pion/stun"
+
+	"github.com/livekit/protocol/logger"
+)
+
+var (
+	ErrKeepaliveUnanswered = errors.New("keepalive probes unanswered")
+)
+
+type KeepaliveParams struct {
+	// STUN servers as host:port
+	Servers []string
+	// interval between keepalives, should be well below the NAT UDP mapping timeout, often 30 seconds
+	Interval time.Duration
+	// every ProbeEvery-th keepalive is a binding request instead of an indication, its response confirms the mapping
+	ProbeEvery int
+	// consecutive unanswered probes before the mapping is reported as failed
+	MaxMissedProbes int
+}
+
+var KeepaliveParamsDefault = KeepaliveParams{
+	Interval:        15 * time.Second,
+	ProbeEvery:      2,
+	MaxMissedProbes: 2,
+}
+
+type KeepaliveEventType int
+
+const (
+	// probes to all servers went unanswered, the mapping may have been lost
+	KeepaliveEventFailed KeepaliveEventType = iota
+	// probes are answered again after a failure
+	KeepaliveEventRecovered
+	// the external address of the port changed, candidates gathered earlier are no longer valid
+	KeepaliveEventMappingChanged
+)
+
+func (k KeepaliveEventType) String() string {
+	switch k {
+	case KeepaliveEventFailed:
+		return "FAILED"
+	case KeepaliveEventRecovered:
+		return "RECOVERED"
+	case KeepaliveEventMappingChanged:
+		return "MAPPING_CHANGED"
+	default:
+		return fmt.Sprintf("%d", int(k))
+	}
+}
+
+type KeepaliveEvent struct {
+	Type      KeepaliveEventType
+	LocalAddr net.Addr
+	// external address of the port as last reported by a server, nil if never resolved
+	MappedAddr *net.UDPAddr
+	Err        error
+}
+
+// ------------------------------------------------
+
+// KeepaliveConn keeps the NAT mapping of a UDP mux port open by periodically sending
+// STUN binding indications from it. Indications are not answered, so every ProbeEvery-th
+// keepalive is a binding request, responses to which are consumed in ReadFrom and never
+// reach the reader. A round of probes without any response counts as missed.
+type KeepaliveConn struct {
+	net.PacketConn
+
+	params  KeepaliveParams
+	resolve func(network, address string) (*net.UDPAddr, error)
+
+	lock          sync.Mutex
+	servers       []*net.UDPAddr
+	round         int
+	pending       map[[stun.TransactionIDSize]byte]struct{}
+	probeAnswered bool
+	missedProbes  int
+	isFailed      bool
+	mappedAddr    *net.UDPAddr
+	onEvent       func(event KeepaliveEvent)
+	isStopped     bool
+
+	close chan struct{}
+}
+
+func NewKeepaliveConn(conn net.PacketConn, params KeepaliveParams) *KeepaliveConn {
+	if params.Interval <= 0 {
+		params.Interval = KeepaliveParamsDefault.Interval
+	}
+	if params.ProbeEvery <= 0 {
+		params.ProbeEvery = KeepaliveParamsDefault.ProbeEvery
+	}
+	if params.MaxMissedProbes <= 0 {
+		params.MaxMissedProbes = KeepaliveParamsDefault.MaxMissedProbes
+	}
+	return &KeepaliveConn{
+		PacketConn: conn,
+		params:     params,
+		resolve:    net.ResolveUDPAddr,
+		pending:    make(map[[stun.TransactionIDSize]byte]struct{}),
+		close:      make(chan struct{}),
+	}
+}
+
+func (k *KeepaliveConn) OnEvent(f func(event KeepaliveEvent)) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	k.onEvent = f
+}
+
+func (k *KeepaliveConn) Start() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.isStopped {
+		return
+	}
+	go k.worker()
+}
+
+// MappedAddr returns the external address of the port as last reported by a server.
+func (k *KeepaliveConn) MappedAddr() *net.UDPAddr {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+
+	return k.mappedAddr
+}
+
+func (k *KeepaliveConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := k.PacketConn.ReadFrom(p)
+		if err != nil || !stun.IsMessage(p[:n]) || !k.handleResponse(p[:n]) {
+			return n, addr, err
+		}
+	}
+}
+
+func (k *KeepaliveConn) Close() error {
+	k.stop()
+	return k.PacketConn.Close()
+}
+
+func (k *KeepaliveConn) stop() {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	if k.isStopped {
+		return
+	}
+
+	close(k.close)
+	k.isStopped = true
+}
+
+func (k *KeepaliveConn) worker() {
+	ticker := time.NewTicker(k.params.Interval)
+	defer ticker.Stop()
+
+	// probe right away to learn the mapped address
+	k.keepalive()
+	for {
+		select {
+		case <-k.close:
+			return
+		case <-ticker.C:
+			k.keepalive()
+		}
+	}
+}
+
+func (k *KeepaliveConn) keepalive() {
+	k.lock.Lock()
+	isProbe := k.round%k.params.ProbeEvery == 0
+	k.round++
+	k.lock.Unlock()
+
+	if isProbe {
+		k.probe()
+		return
+	}
+
+	k.lock.Lock()
+	servers := k.servers
+	k.lock.Unlock()
+	for _, server := range servers {
+		msg, err := stun.Build(stun.TransactionID, stun.NewType(stun.MethodBinding, stun.ClassIndication), stun.Fingerprint)
+		if err != nil {
+			return
+		}
+		if _, err = k.PacketConn.WriteTo(msg.Raw, server); err != nil {
+			logger.Debugw("could not send keepalive", "error", err, "local", k.LocalAddr(), "server", server)
+		}
+	}
+}
+
+func (k *KeepaliveConn) probe() {
+	// servers are resolved again with every probe, following DNS changes
+	servers := k.resolveServers()
+
+	k.lock.Lock()
+	k.servers = servers
+	var event *KeepaliveEvent
+	if len(k.pending) != 0 && !k.probeAnswered {
+		k.missedProbes++
+		if k.missedProbes >= k.params.MaxMissedProbes && !k.isFailed {
+			k.isFailed = true
+			event = k.newEventLocked(KeepaliveEventFailed)
+			event.Err = fmt.Errorf("%w: %d in a row", ErrKeepaliveUnanswered, k.missedProbes)
+		}
+	}
+	k.probeAnswered = false
+	k.pending = make(map[[stun.TransactionIDSize]byte]struct{}, len(servers))
+
+	msgs := make([]*stun.Message, 0, len(servers))
+	for range servers {
+		msg, err := stun.Build(stun.TransactionID, stun.BindingRequest, stun.Fingerprint)
+		if err != nil {
+			break
+		}
+		k.pending[msg.TransactionID] = struct{}{}
+		msgs = append(msgs, msg)
+	}
+	onEvent := k.onEvent
+	k.lock.Unlock()
+
+	if event != nil {
+		logger.Infow("NAT keepalive failed", "local", event.LocalAddr, "error", event.Err)
+		if onEvent != nil {
+			onEvent(*event)
+		}
+	}
+
+	for i, msg := range msgs {
+		if _, err := k.PacketConn.WriteTo(msg.Raw, servers[i]); err != nil {
+			logger.Debugw("could not send keepalive probe", "error", err, "local", k.LocalAddr(), "server", servers[i])
+		}
+	}
+}
+
+// resolveServers returns the servers reachable from the address family of the port
+func (k *KeepaliveConn) resolveServers() []*net.UDPAddr {
+	network := "udp4"
+	if local, ok := k.LocalAddr().(*net.UDPAddr); ok && local.IP.To4() == nil && !local.IP.IsUnspecified() {
+		network = "udp6"
+	}
+
+	servers := make([]*net.UDPAddr, 0, len(k.params.Servers))
+	for _, server := range k.params.Servers {
+		addr, err := k.resolve(network, server)
+		if err != nil {
+			logger.Debugw("could not resolve keepalive server", "error", err, "server", server, "network", network)
+			continue
+		}
+		servers = append(servers, addr)
+	}
+	return servers
+}
+
+// handleResponse consumes responses to probes, returns false for other messages
+func (k *KeepaliveConn) handleResponse(data []byte) bool {
+	msg := &stun.Message{
+		Raw: append([]byte{}, data...),
+	}
+	if err := msg.Decode(); err != nil || msg.Type.Method != stun.MethodBinding {
+		return false
+	}
+	if msg.Type.Class != stun.ClassSuccessResponse && msg.Type.Class != stun.ClassErrorResponse {
+		return false
+	}
+
+	k.lock.Lock()
+	if _, ok := k.pending[msg.TransactionID]; !ok {
+		k.lock.Unlock()
+		return false
+	}
+	delete(k.pending, msg.TransactionID)
+
+	// any response shows the path through the NAT is open, even an error
+	var events []KeepaliveEvent
+	k.probeAnswered = true
+	k.missedProbes = 0
+	if k.isFailed {
+		k.isFailed = false
+		events = append(events, *k.newEventLocked(KeepaliveEventRecovered))
+	}
+
+	var xorAddr stun.XORMappedAddress
+	if msg.Type.Class == stun.ClassSuccessResponse && xorAddr.GetFrom(msg) == nil {
+		mappedAddr := &net.UDPAddr{IP: xorAddr.IP, Port: xorAddr.Port}
+		if k.mappedAddr != nil && (!k.mappedAddr.IP.Equal(mappedAddr.IP) || k.mappedAddr.Port != mappedAddr.Port) {
+			events = append(events, *k.newEventLocked(KeepaliveEventMappingChanged))
+			events[len(events)-1].MappedAddr = mappedAddr
+		}
+		k.mappedAddr = mappedAddr
+	}
+	onEvent := k.onEvent
+	k.lock.Unlock()
+
+	for _, event := range events {
+		logger.Infow("NAT keepalive event", "event", event.Type, "local", event.LocalAddr, "mapped", event.MappedAddr)
+		if onEvent != nil {
+			onEvent(event)
+		}
+	}
+	return true
+}
+
+func (k *KeepaliveConn) newEventLocked(eventType KeepaliveEventType) *KeepaliveEvent {
+	return &KeepaliveEvent{
+		Type:       eventType,
+		LocalAddr:  k.LocalAddr(),
+		MappedAddr: k.mappedAddr,
+	}
+}
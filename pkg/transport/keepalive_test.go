@@ -0,0 +1,148 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSTUNServer struct {
+	conn *net.UDPConn
+
+	respond     atomic.Bool
+	portOffset  atomic.Int32
+	requests    atomic.Int32
+	indications atomic.Int32
+}
+
+func newFakeSTUNServer(t *testing.T) *fakeSTUNServer {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	s := &fakeSTUNServer{conn: conn}
+	s.respond.Store(true)
+	go s.serve()
+	return s
+}
+
+func (s *fakeSTUNServer) serve() {
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+
+		msg := &stun.Message{Raw: append([]byte{}, buf[:n]...)}
+		if msg.Decode() != nil {
+			continue
+		}
+		if msg.Type.Class == stun.ClassIndication {
+			s.indications.Add(1)
+			continue
+		}
+		s.requests.Add(1)
+		if !s.respond.Load() {
+			continue
+		}
+
+		res, err := stun.Build(msg, stun.BindingSuccess, &stun.XORMappedAddress{
+			IP:   from.IP,
+			Port: from.Port + int(s.portOffset.Load()),
+		}, stun.Fingerprint)
+		if err == nil {
+			_, _ = s.conn.WriteToUDP(res.Raw, from)
+		}
+	}
+}
+
+func TestKeepaliveConn(t *testing.T) {
+	server := newFakeSTUNServer(t)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	kc := NewKeepaliveConn(conn, KeepaliveParams{
+		Servers:         []string{server.conn.LocalAddr().String()},
+		Interval:        20 * time.Millisecond,
+		ProbeEvery:      2,
+		MaxMissedProbes: 2,
+	})
+	events := make(chan KeepaliveEvent, 10)
+	kc.OnEvent(func(event KeepaliveEvent) { events <- event })
+
+	// reader stands in for the UDP mux, only non keepalive traffic reaches it
+	received := make(chan string, 10)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := kc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			received <- string(buf[:n])
+		}
+	}()
+
+	kc.Start()
+	defer kc.Close()
+
+	require.Eventually(t, func() bool { return kc.MappedAddr() != nil }, 5*time.Second, 5*time.Millisecond)
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port, kc.MappedAddr().Port)
+	require.Eventually(t, func() bool { return server.indications.Load() > 0 }, 5*time.Second, 5*time.Millisecond)
+
+	_, err = server.conn.WriteTo([]byte("media"), conn.LocalAddr())
+	require.NoError(t, err)
+	select {
+	case data := <-received:
+		require.Equal(t, "media", data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("packet not passed through")
+	}
+
+	// unanswered probes fail the mapping
+	server.respond.Store(false)
+	event := waitKeepaliveEvent(t, events)
+	require.Equal(t, KeepaliveEventFailed, event.Type)
+	require.ErrorIs(t, event.Err, ErrKeepaliveUnanswered)
+
+	// answered again, from a different external port
+	server.portOffset.Store(1)
+	server.respond.Store(true)
+	event = waitKeepaliveEvent(t, events)
+	require.Equal(t, KeepaliveEventRecovered, event.Type)
+	event = waitKeepaliveEvent(t, events)
+	require.Equal(t, KeepaliveEventMappingChanged, event.Type)
+	require.Equal(t, conn.LocalAddr().(*net.UDPAddr).Port+1, event.MappedAddr.Port)
+
+	require.Empty(t, received)
+}
+
+func waitKeepaliveEvent(t *testing.T, events chan KeepaliveEvent) KeepaliveEvent {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no keepalive event")
+		return KeepaliveEvent{}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/pion/rtp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+var (
+	ErrNotMulticastGroup = errors.New("not a multicast group address")
+)
+
+type MulticastEgressParams struct {
+	// multicast group and port the streams are sent to
+	Group *net.UDPAddr
+	// name of the interface the streams are sent on, the system default if empty
+	Interface string
+	// TTL, or hop limit for IPv6, of the packets, 1 keeps them on the local network
+	TTL int
+	// packets are also delivered to receivers on this host
+	Loopback bool
+}
+
+var MulticastEgressParamsDefault = MulticastEgressParams{
+	TTL: 1,
+}
+
+type MulticastEgressStats struct {
+	NumPackets uint64
+	NumBytes   uint64
+	NumErrors  uint64
+}
+
+// MulticastEgress re-emits selected streams as plain RTP to a multicast group, for distribution on a LAN,
+// for example to the screens of an overflow room. Packets are sent as forwarded, decrypted and with
+// their SSRCs, receivers select streams by SSRC.
+type MulticastEgress struct {
+	params MulticastEgressParams
+	conn   *net.UDPConn
+
+	lock     sync.Mutex
+	selected map[uint32]struct{}
+	stats    MulticastEgressStats
+}
+
+func NewMulticastEgress(params MulticastEgressParams) (*MulticastEgress, error) {
+	if params.Group == nil || !params.Group.IP.IsMulticast() {
+		return nil, ErrNotMulticastGroup
+	}
+	if params.TTL <= 0 {
+		params.TTL = MulticastEgressParamsDefault.TTL
+	}
+
+	var ifi *net.Interface
+	if params.Interface != "" {
+		var err error
+		if ifi, err = net.InterfaceByName(params.Interface); err != nil {
+			return nil, err
+		}
+	}
+
+	isIPv4 := params.Group.IP.To4() != nil
+	network := "udp6"
+	if isIPv4 {
+		network = "udp4"
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if isIPv4 {
+		err = setIPv4MulticastOptions(ipv4.NewPacketConn(conn), ifi, params)
+	} else {
+		err = setIPv6MulticastOptions(ipv6.NewPacketConn(conn), ifi, params)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("setting multicast options: %w", err)
+	}
+
+	return &MulticastEgress{
+		params:   params,
+		conn:     conn,
+		selected: make(map[uint32]struct{}),
+	}, nil
+}
+
+// Select adds a stream to those sent to the group.
+func (m *MulticastEgress) Select(ssrc uint32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.selected[ssrc] = struct{}{}
+}
+
+func (m *MulticastEgress) Deselect(ssrc uint32) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.selected, ssrc)
+}
+
+func (m *MulticastEgress) IsSelected(ssrc uint32) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, ok := m.selected[ssrc]
+	return ok
+}
+
+// WriteRTP sends pkt to the group if its stream is selected, packets of other streams are ignored.
+func (m *MulticastEgress) WriteRTP(pkt *rtp.Packet) error {
+	if !m.IsSelected(pkt.SSRC) {
+		return nil
+	}
+
+	b, err := pkt.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = m.conn.WriteTo(b, m.params.Group)
+
+	m.lock.Lock()
+	if err != nil {
+		m.stats.NumErrors++
+	} else {
+		m.stats.NumPackets++
+		m.stats.NumBytes += uint64(len(b))
+	}
+	m.lock.Unlock()
+	return err
+}
+
+func (m *MulticastEgress) Stats() MulticastEgressStats {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.stats
+}
+
+func (m *MulticastEgress) LocalAddr() net.Addr {
+	return m.conn.LocalAddr()
+}
+
+func (m *MulticastEgress) Close() error {
+	return m.conn.Close()
+}
+
+func setIPv4MulticastOptions(pc *ipv4.PacketConn, ifi *net.Interface, params MulticastEgressParams) error {
+	if ifi != nil {
+		if err := pc.SetMulticastInterface(ifi); err != nil {
+			return err
+		}
+	}
+	if err := pc.SetMulticastTTL(params.TTL); err != nil {
+		return err
+	}
+	return pc.SetMulticastLoopback(params.Loopback)
+}
+
+func setIPv6MulticastOptions(pc *ipv6.PacketConn, ifi *net.Interface, params MulticastEgressParams) error {
+	if ifi != nil {
+		if err := pc.SetMulticastInterface(ifi); err != nil {
+			return err
+		}
+	}
+	if err := pc.SetMulticastHopLimit(params.TTL); err != nil {
+		return err
+	}
+	return pc.SetMulticastLoopback(params.Loopback)
+}
@@ -0,0 +1,85 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/ipv4"
+)
+
+func multicastInterface(t *testing.T) *net.Interface {
+	ifis, err := net.Interfaces()
+	require.NoError(t, err)
+	for i := range ifis {
+		if ifis[i].Flags&net.FlagUp != 0 && ifis[i].Flags&net.FlagMulticast != 0 {
+			return &ifis[i]
+		}
+	}
+	t.Skip("no multicast interface")
+	return nil
+}
+
+func TestMulticastEgressParams(t *testing.T) {
+	_, err := NewMulticastEgress(MulticastEgressParams{})
+	require.ErrorIs(t, err, ErrNotMulticastGroup)
+	_, err = NewMulticastEgress(MulticastEgressParams{Group: &net.UDPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 5004}})
+	require.ErrorIs(t, err, ErrNotMulticastGroup)
+	_, err = NewMulticastEgress(MulticastEgressParams{Group: &net.UDPAddr{IP: net.IPv4(239, 0, 0, 1), Port: 5004}, Interface: "no-such-interface"})
+	require.Error(t, err)
+}
+
+func TestMulticastEgress(t *testing.T) {
+	ifi := multicastInterface(t)
+	group := net.IPv4(239, 255, 42, 99)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: group})
+	require.NoError(t, err)
+	defer conn.Close()
+	if err := ipv4.NewPacketConn(conn).JoinGroup(ifi, &net.UDPAddr{IP: group}); err != nil {
+		t.Skipf("cannot join multicast group: %v", err)
+	}
+
+	m, err := NewMulticastEgress(MulticastEgressParams{
+		Group:     &net.UDPAddr{IP: group, Port: conn.LocalAddr().(*net.UDPAddr).Port},
+		Interface: ifi.Name,
+		Loopback:  true,
+	})
+	require.NoError(t, err)
+	defer m.Close()
+
+	m.Select(1)
+	require.NoError(t, m.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 2, SequenceNumber: 1}}))
+	require.NoError(t, m.WriteRTP(&rtp.Packet{Header: rtp.Header{Version: 2, SSRC: 1, SequenceNumber: 2}, Payload: []byte{1, 2}}))
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Skipf("multicast not delivered: %v", err)
+	}
+	var pkt rtp.Packet
+	require.NoError(t, pkt.Unmarshal(buf[:n]))
+	require.Equal(t, uint32(1), pkt.SSRC)
+	require.Equal(t, []byte{1, 2}, pkt.Payload)
+
+	m.Deselect(1)
+	require.False(t, m.IsSelected(1))
+	require.Equal(t, MulticastEgressStats{NumPackets: 1, NumBytes: uint64(n)}, m.Stats())
+}
@@ -0,0 +1,273 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/pion/rtp"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+	"github.com/livekit/mediatransportutil/pkg/replay"
+)
+
+const (
+	rtpHeaderSize = 12
+)
+
+// RedundantPath is one path of a redundant link, for example a socket bound to one of two interfaces.
+type RedundantPath struct {
+	Conn net.PacketConn
+	Addr net.Addr
+}
+
+type RedundantWriterStats struct {
+	NumPackets uint64
+	// critical packets sent on both paths
+	NumDuplicated uint64
+	// packets sent on the secondary path after failing on the primary one
+	NumFailovers       uint64
+	NumSecondaryErrors uint64
+}
+
+// RedundantWriter sends the packets of a link between nodes, such as an inter-region cascade, on a primary path
+// and duplicates critical ones, for example audio and key frames, on a secondary path, so that they get through
+// a loss or outage of one path. The receiver drops duplicates with a Deduplicator.
+type RedundantWriter struct {
+	primary    RedundantPath
+	secondary  RedundantPath
+	isCritical func(b []byte) bool
+
+	lock  sync.Mutex
+	stats RedundantWriterStats
+}
+
+// NewRedundantWriter creates a writer sending the packets for which isCritical returns true on both paths,
+// isCritical is called for every packet in order and may keep state, for example a CriticalPacketClassifier.
+func NewRedundantWriter(primary RedundantPath, secondary RedundantPath, isCritical func(b []byte) bool) *RedundantWriter {
+	return &RedundantWriter{
+		primary:    primary,
+		secondary:  secondary,
+		isCritical: isCritical,
+	}
+}
+
+// Write sends b, it fails only if no path could send it.
+func (w *RedundantWriter) Write(b []byte) (int, error) {
+	isCritical := w.isCritical != nil && w.isCritical(b)
+
+	n, err := w.primary.Conn.WriteTo(b, w.primary.Addr)
+	isFailover := err != nil && !isCritical
+	var secondaryErr error
+	if isCritical || isFailover {
+		var secondaryN int
+		secondaryN, secondaryErr = w.secondary.Conn.WriteTo(b, w.secondary.Addr)
+		if err != nil && secondaryErr == nil {
+			n, err = secondaryN, nil
+		}
+	}
+
+	w.lock.Lock()
+	w.stats.NumPackets++
+	if isCritical {
+		w.stats.NumDuplicated++
+	}
+	if isFailover {
+		w.stats.NumFailovers++
+	}
+	if secondaryErr != nil {
+		w.stats.NumSecondaryErrors++
+	}
+	w.lock.Unlock()
+	return n, err
+}
+
+func (w *RedundantWriter) Stats() RedundantWriterStats {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.stats
+}
+
+// ------------------------------------------------
+
+type CriticalPacketClassifierParams struct {
+	AudioPayloadTypes []uint8
+	// codecs of video payload types, whose key frames are critical
+	VideoCodecs map[uint8]keyframe.Codec
+}
+
+// CriticalPacketClassifier classifies RTP packets of audio payload types and all packets of video key frames
+// as critical. A key frame is recognized by its first packet, its other packets share its timestamp.
+type CriticalPacketClassifier struct {
+	params CriticalPacketClassifierParams
+
+	lock sync.Mutex
+	// timestamp of the key frame in progress per SSRC
+	keyFrames map[uint32]uint32
+}
+
+func NewCriticalPacketClassifier(params CriticalPacketClassifierParams) *CriticalPacketClassifier {
+	return &CriticalPacketClassifier{
+		params:    params,
+		keyFrames: make(map[uint32]uint32),
+	}
+}
+
+func (c *CriticalPacketClassifier) IsCritical(b []byte) bool {
+	var hdr rtp.Header
+	if !isRTP(b) {
+		return false
+	}
+	n, err := hdr.Unmarshal(b)
+	if err != nil {
+		return false
+	}
+
+	for _, pt := range c.params.AudioPayloadTypes {
+		if hdr.PayloadType == pt {
+			return true
+		}
+	}
+	codec, ok := c.params.VideoCodecs[hdr.PayloadType]
+	if !ok {
+		return false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if keyframe.IsKeyFrameStart(codec, b[n:]) {
+		c.keyFrames[hdr.SSRC] = hdr.Timestamp
+		return true
+	}
+	ts, ok := c.keyFrames[hdr.SSRC]
+	if !ok {
+		return false
+	}
+	if ts != hdr.Timestamp {
+		delete(c.keyFrames, hdr.SSRC)
+		return false
+	}
+	return true
+}
+
+// ------------------------------------------------
+
+type DeduplicatorParams struct {
+	// sequence numbers tracked per stream, see replay.WindowParams
+	WindowSize int
+	// streams tracked, an arbitrary one is forgotten beyond this
+	MaxStreams int
+}
+
+var DeduplicatorParamsDefault = DeduplicatorParams{
+	WindowSize: 1024,
+	MaxStreams: 4096,
+}
+
+type DeduplicatorStats struct {
+	NumAccepted   uint64
+	NumDuplicates uint64
+	// too far behind the stream to tell, dropped
+	NumTooOld  uint64
+	NumStreams int
+}
+
+// Deduplicator drops the copies of RTP packets received on both paths of a redundant link. Packets of both
+// paths are passed to Accept, which keeps the first copy. Packets other than RTP are always accepted.
+type Deduplicator struct {
+	params DeduplicatorParams
+
+	lock    sync.Mutex
+	windows map[uint32]*replay.Window
+	stats   DeduplicatorStats
+}
+
+func NewDeduplicator(params DeduplicatorParams) *Deduplicator {
+	if params.WindowSize <= 0 {
+		params.WindowSize = DeduplicatorParamsDefault.WindowSize
+	}
+	if params.MaxStreams <= 0 {
+		params.MaxStreams = DeduplicatorParamsDefault.MaxStreams
+	}
+	return &Deduplicator{
+		params:  params,
+		windows: make(map[uint32]*replay.Window),
+	}
+}
+
+// Accept returns false if b is a copy of a packet already accepted.
+func (d *Deduplicator) Accept(b []byte) bool {
+	if !isRTP(b) {
+		return true
+	}
+	sn := binary.BigEndian.Uint16(b[2:4])
+	ssrc := binary.BigEndian.Uint32(b[8:12])
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	w := d.windows[ssrc]
+	if w == nil {
+		if len(d.windows) >= d.params.MaxStreams {
+			for s := range d.windows {
+				delete(d.windows, s)
+				break
+			}
+		}
+		w = replay.NewWindow(replay.WindowParams{Size: d.params.WindowSize})
+		d.windows[ssrc] = w
+	}
+
+	switch w.Accept(sn) {
+	case replay.ResultDuplicate:
+		d.stats.NumDuplicates++
+		return false
+	case replay.ResultTooOld:
+		d.stats.NumTooOld++
+		return false
+	default:
+		d.stats.NumAccepted++
+		return true
+	}
+}
+
+// Remove forgets a stream, for example when it ends.
+func (d *Deduplicator) Remove(ssrc uint32) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	delete(d.windows, ssrc)
+}
+
+func (d *Deduplicator) Stats() DeduplicatorStats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := d.stats
+	stats.NumStreams = len(d.windows)
+	return stats
+}
+
+// isRTP returns true for RTP version 2 packets that are not RTCP (RFC 5761, section 4)
+func isRTP(b []byte) bool {
+	if len(b) < rtpHeaderSize || b[0]>>6 != 2 {
+		return false
+	}
+	return b[1] < 192 || b[1] > 223
+}
@@ -0,0 +1,163 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livekit/mediatransportutil/pkg/keyframe"
+)
+
+const (
+	testAudioPT = 111
+	testVideoPT = 96
+)
+
+func rtpPacket(t *testing.T, pt uint8, ssrc uint32, sn uint16, ts uint32, payload []byte) []byte {
+	b, err := (&rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: pt, SSRC: ssrc, SequenceNumber: sn, Timestamp: ts},
+		Payload: payload,
+	}).Marshal()
+	require.NoError(t, err)
+	return b
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func readPackets(t *testing.T, conn *net.UDPConn, d *Deduplicator, into chan<- []byte) {
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if d.Accept(buf[:n]) {
+				into <- append([]byte{}, buf[:n]...)
+			}
+		}
+	}()
+}
+
+func TestCriticalPacketClassifier(t *testing.T) {
+	c := NewCriticalPacketClassifier(CriticalPacketClassifierParams{
+		AudioPayloadTypes: []uint8{testAudioPT},
+		VideoCodecs:       map[uint8]keyframe.Codec{testVideoPT: keyframe.CodecVP8},
+	})
+	vp8KeyFrame := []byte{0x10, 0x00}
+	vp8Continuation := []byte{0x00, 0x00}
+	vp8Delta := []byte{0x10, 0x01}
+
+	require.True(t, c.IsCritical(rtpPacket(t, testAudioPT, 1, 1, 960, []byte{1})))
+	require.False(t, c.IsCritical(rtpPacket(t, testVideoPT, 2, 1, 3000, vp8Delta)))
+
+	// every packet of the key frame
+	require.True(t, c.IsCritical(rtpPacket(t, testVideoPT, 2, 2, 6000, vp8KeyFrame)))
+	require.True(t, c.IsCritical(rtpPacket(t, testVideoPT, 2, 3, 6000, vp8Continuation)))
+	require.False(t, c.IsCritical(rtpPacket(t, testVideoPT, 2, 4, 9000, vp8Delta)))
+	require.False(t, c.IsCritical(rtpPacket(t, testVideoPT, 2, 5, 9000, vp8Continuation)))
+
+	// other payload types and RTCP
+	require.False(t, c.IsCritical(rtpPacket(t, 100, 3, 1, 0, []byte{1})))
+	require.False(t, c.IsCritical([]byte{0x80, 200, 0, 6, 0, 0, 0, 1, 0, 0, 0, 0}))
+}
+
+func TestDeduplicator(t *testing.T) {
+	d := NewDeduplicator(DeduplicatorParams{WindowSize: 64, MaxStreams: 2})
+
+	require.True(t, d.Accept(rtpPacket(t, testAudioPT, 1, 10, 0, nil)))
+	require.False(t, d.Accept(rtpPacket(t, testAudioPT, 1, 10, 0, nil)))
+	require.True(t, d.Accept(rtpPacket(t, testAudioPT, 2, 10, 0, nil)))
+	require.True(t, d.Accept(rtpPacket(t, testAudioPT, 1, 9, 0, nil)))
+	require.True(t, d.Accept(rtpPacket(t, testAudioPT, 1, 200, 0, nil)))
+	require.False(t, d.Accept(rtpPacket(t, testAudioPT, 1, 11, 0, nil)))
+
+	// RTCP and other packets are not deduplicated
+	rtcp := []byte{0x80, 200, 0, 6, 0, 0, 0, 1, 0, 0, 0, 0}
+	require.True(t, d.Accept(rtcp))
+	require.True(t, d.Accept(rtcp))
+
+	// an arbitrary stream is forgotten beyond the limit
+	require.True(t, d.Accept(rtpPacket(t, testAudioPT, 3, 10, 0, nil)))
+	stats := d.Stats()
+	require.Equal(t, 2, stats.NumStreams)
+	require.Equal(t, uint64(5), stats.NumAccepted)
+	require.Equal(t, uint64(1), stats.NumDuplicates)
+	require.Equal(t, uint64(1), stats.NumTooOld)
+
+	d.Remove(3)
+	require.Equal(t, 1, d.Stats().NumStreams)
+}
+
+func TestRedundantWriter(t *testing.T) {
+	primaryRecv, secondaryRecv := listenUDP(t), listenUDP(t)
+	primarySend, secondarySend := listenUDP(t), listenUDP(t)
+
+	d := NewDeduplicator(DeduplicatorParams{})
+	received := make(chan []byte, 16)
+	readPackets(t, primaryRecv, d, received)
+	readPackets(t, secondaryRecv, d, received)
+
+	c := NewCriticalPacketClassifier(CriticalPacketClassifierParams{AudioPayloadTypes: []uint8{testAudioPT}})
+	w := NewRedundantWriter(
+		RedundantPath{Conn: primarySend, Addr: primaryRecv.LocalAddr()},
+		RedundantPath{Conn: secondarySend, Addr: secondaryRecv.LocalAddr()},
+		c.IsCritical,
+	)
+
+	audio := rtpPacket(t, testAudioPT, 1, 1, 960, []byte{1})
+	video := rtpPacket(t, testVideoPT, 2, 1, 3000, []byte{1})
+	_, err := w.Write(audio)
+	require.NoError(t, err)
+	_, err = w.Write(video)
+	require.NoError(t, err)
+
+	// the audio packet arrives once, from whichever path is first
+	var packets [][]byte
+	for i := 0; i < 2; i++ {
+		select {
+		case b := <-received:
+			packets = append(packets, b)
+		case <-time.After(time.Second):
+			t.Fatal("packet not received")
+		}
+	}
+	require.ElementsMatch(t, [][]byte{audio, video}, packets)
+	require.Eventually(t, func() bool { return d.Stats().NumDuplicates == 1 }, time.Second, time.Millisecond)
+
+	// a failed primary path fails over to the secondary one
+	require.NoError(t, primarySend.Close())
+	video = rtpPacket(t, testVideoPT, 2, 2, 6000, []byte{1})
+	_, err = w.Write(video)
+	require.NoError(t, err)
+	select {
+	case b := <-received:
+		require.Equal(t, video, b)
+	case <-time.After(time.Second):
+		t.Fatal("packet not received")
+	}
+
+	require.Equal(t, RedundantWriterStats{NumPackets: 3, NumDuplicated: 1, NumFailovers: 1}, w.Stats())
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+
+	"github.com/pion/ice/v2"
+)
+
+const (
+	// ufrag bytes hashed to pick a socket, mirrored by the steering program
+	reusePortMaxUfragLen = 32
+)
+
+var (
+	ErrReusePortNotSupported = errors.New("SO_REUSEPORT steering is not supported, build on Linux with the ebpf tag")
+)
+
+// ReusePortParams listens with several sockets on each mux port, sharing the port with SO_REUSEPORT.
+// A steering program hashes the local ICE ufrag of binding requests to pick a socket and remembers
+// the remote address, so that all packets of a connection land on the same socket and its read goroutine.
+// Packets from addresses that have not sent a binding request, for example keepalive responses, land on the first socket.
+type ReusePortParams struct {
+	// sockets per mux port
+	NumSockets int
+	// remote addresses remembered per port, the least recently used ones are evicted
+	MaxRemoteAddrs int
+}
+
+var ReusePortParamsDefault = ReusePortParams{
+	NumSockets:     4,
+	MaxRemoteAddrs: 65536,
+}
+
+// ReusePortSupported returns nil if SO_REUSEPORT steering can be used on this host.
+func ReusePortSupported() error {
+	return reusePortSupported()
+}
+
+// reusePortIndex returns the socket the steering program picks for binding requests to ufrag
+func reusePortIndex(ufrag string, numSockets int) int {
+	var h uint32
+	for i := 0; i < len(ufrag) && i < reusePortMaxUfragLen; i++ {
+		h = h*31 + uint32(ufrag[i])
+	}
+	return int(h % uint32(numSockets))
+}
+
+// ------------------------------------------------
+
+// ReusePortUDPMux is a UDPMux over the sockets of one port, connections are handled by the mux of the socket
+// their packets are steered to.
+type ReusePortUDPMux struct {
+	muxes []ice.UDPMux
+}
+
+func NewReusePortUDPMux(muxes ...ice.UDPMux) *ReusePortUDPMux {
+	return &ReusePortUDPMux{
+		muxes: muxes,
+	}
+}
+
+func (r *ReusePortUDPMux) GetConn(ufrag string, addr net.Addr) (net.PacketConn, error) {
+	return r.muxes[reusePortIndex(ufrag, len(r.muxes))].GetConn(ufrag, addr)
+}
+
+func (r *ReusePortUDPMux) RemoveConnByUfrag(ufrag string) {
+	r.muxes[reusePortIndex(ufrag, len(r.muxes))].RemoveConnByUfrag(ufrag)
+}
+
+// GetListenAddresses returns the address of the port, it is shared by all sockets.
+func (r *ReusePortUDPMux) GetListenAddresses() []net.Addr {
+	return r.muxes[0].GetListenAddresses()
+}
+
+func (r *ReusePortUDPMux) Close() error {
+	var err error
+	for _, mux := range r.muxes {
+		if closeErr := mux.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+var _ ice.UDPMux = (*ReusePortUDPMux)(nil)
@@ -0,0 +1,257 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && ebpf
+// +build linux,ebpf
+
+package transport
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+const (
+	soReusePort           = 0xf
+	soAttachReusePortEBPF = 52
+
+	bpfFuncSkbLoadBytes         = 26
+	bpfFuncSkbLoadBytesRelative = 68
+	bpfHdrStartNet              = 1
+
+	// remote IP, port and padding
+	reusePortKeySize = 24
+	stunMagicCookie  = 0x2112A442
+	stunAttrUsername = 0x0006
+	// attributes looked at for USERNAME, browsers send it first
+	reusePortMaxAttrs = 4
+)
+
+func listenReusePort(ip net.IP, port int, params ReusePortParams) ([]*net.UDPConn, error) {
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var opErr error
+			if err := c.Control(func(fd uintptr) {
+				opErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+			}); err != nil {
+				return err
+			}
+			return opErr
+		},
+	}
+
+	conns := make([]*net.UDPConn, 0, params.NumSockets)
+	closeAll := func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}
+	// sockets join the group in order, the steering program returns an index into it
+	for i := 0; i < params.NumSockets; i++ {
+		pc, err := lc.ListenPacket(context.Background(), "udp", (&net.UDPAddr{IP: ip, Port: port}).String())
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		conn := pc.(*net.UDPConn)
+		conns = append(conns, conn)
+		// ephemeral port of the first socket is shared by the others
+		port = conn.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	progFD, err := loadReusePortProgram(ip.To4() != nil, params)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	// the group holds a reference to the program and the program to the map
+	defer func() { _ = syscall.Close(progFD) }()
+
+	if err := controlSocket(conns[0], func(fd int) error {
+		return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, soAttachReusePortEBPF, progFD)
+	}); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("could not attach steering program: %w", err)
+	}
+	return conns, nil
+}
+
+func reusePortSupported() error {
+	fd, err := loadReusePortProgram(true, ReusePortParams{NumSockets: 2, MaxRemoteAddrs: 1})
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+func loadReusePortProgram(isIPv4 bool, params ReusePortParams) (int, error) {
+	mapFD, err := bpfMapCreate(bpfMapTypeLRUHash, reusePortKeySize, 4, params.MaxRemoteAddrs)
+	if err != nil {
+		return -1, fmt.Errorf("could not create steering map: %w", err)
+	}
+	defer func() { _ = syscall.Close(mapFD) }()
+
+	insns, err := reusePortProgram(isIPv4, params.NumSockets, mapFD)
+	if err != nil {
+		return -1, err
+	}
+	progFD, err := bpfProgLoad(bpfProgTypeSocketFilter, 0, insns)
+	if err != nil {
+		return -1, fmt.Errorf("could not load steering program: %w", err)
+	}
+	return progFD, nil
+}
+
+// reusePortProgram returns the steering program, it returns the socket index for a packet, data starts at the UDP payload.
+// Binding requests are steered by the hash of the local ufrag, see reusePortIndex, and their remote address
+// is stored in the map. Other packets are steered by the stored index of their remote address, or to the first socket.
+func reusePortProgram(isIPv4 bool, numSockets int, mapFD int) ([]bpfInsn, error) {
+	const (
+		keyOff   = -24
+		portOff  = keyOff + 16
+		bufOff   = -64
+		valueOff = -72
+	)
+
+	a := &bpfAsm{}
+	a.mov64Reg(6, 1)
+	for off := int16(bufOff); off < 0; off += 8 {
+		a.stMem(bpfDW, 10, off, 0)
+	}
+
+	// remote address
+	if isIPv4 {
+		a.loadBytesRelative(12, keyOff, 4)
+	} else {
+		a.loadBytesRelative(8, keyOff, 16)
+	}
+	a.jmpImm(bpfJNE, 0, 0, "first")
+	if isIPv4 {
+		a.loadBytesRelative(0, valueOff, 1)
+		a.jmpImm(bpfJNE, 0, 0, "first")
+		a.ldxMem(bpfB, 2, 10, valueOff)
+		a.alu64Imm(bpfAND, 2, 0x0f)
+		a.alu64Imm(bpfLSH, 2, 2)
+	} else {
+		a.mov64Imm(2, 40)
+	}
+	a.mov64Reg(1, 6)
+	a.stackPtr(3, portOff)
+	a.mov64Imm(4, 2)
+	a.mov64Imm(5, bpfHdrStartNet)
+	a.call(bpfFuncSkbLoadBytesRelative)
+	a.jmpImm(bpfJNE, 0, 0, "first")
+
+	// STUN binding request
+	a.mov64Imm(2, 0)
+	a.loadBytes(bufOff, 20)
+	a.jmpImm(bpfJNE, 0, 0, "lookup")
+	a.ldxMem(bpfB, 2, 10, bufOff)
+	a.jmpImm(bpfJNE, 2, 0, "lookup")
+	a.ldxMem(bpfB, 2, 10, bufOff+1)
+	a.jmpImm(bpfJNE, 2, 1, "lookup")
+	a.ldxMem(bpfW, 2, 10, bufOff+4)
+	a.toBE(2, 32)
+	a.jmpImm(bpfJNE, 2, stunMagicCookie, "lookup")
+
+	// USERNAME attribute, r7 is its offset
+	a.mov64Imm(7, 20)
+	for i := 0; i < reusePortMaxAttrs; i++ {
+		a.mov64Reg(2, 7)
+		a.loadBytes(bufOff, 4)
+		a.jmpImm(bpfJNE, 0, 0, "lookup")
+		a.ldxMem(bpfH, 2, 10, bufOff)
+		a.toBE(2, 16)
+		a.ldxMem(bpfH, 9, 10, bufOff+2)
+		a.toBE(9, 16)
+		a.jmpImm(bpfJEQ, 2, stunAttrUsername, "username")
+		a.alu64Imm(bpfADD, 9, 3)
+		a.alu64Imm(bpfAND, 9, -4)
+		a.alu64Imm(bpfADD, 7, 4)
+		a.alu64Reg(bpfADD, 7, 9)
+	}
+	a.ja("lookup")
+
+	// hash of the ufrag, the part of the username up to the colon, r9 is the length of the username
+	a.label("username")
+	a.alu64Imm(bpfADD, 7, 4)
+	a.ldxMem(bpfW, 4, 6, 0)
+	a.alu64Reg(bpfSUB, 4, 7)
+	a.jmpImm(bpfJSLE, 4, 0, "lookup")
+	a.jmpImm(bpfJLE, 4, reusePortMaxUfragLen, "load")
+	a.mov64Imm(4, reusePortMaxUfragLen)
+	a.label("load")
+	a.jmpReg(bpfJLE, 9, 4, "loaded")
+	a.mov64Reg(9, 4)
+	a.label("loaded")
+	a.mov64Reg(1, 6)
+	a.mov64Reg(2, 7)
+	a.stackPtr(3, bufOff)
+	a.call(bpfFuncSkbLoadBytes)
+	a.jmpImm(bpfJNE, 0, 0, "lookup")
+
+	a.mov64Imm(8, 0)
+	for i := 0; i < reusePortMaxUfragLen; i++ {
+		a.jmpImm(bpfJLE, 9, int32(i), "hashed")
+		a.ldxMem(bpfB, 2, 10, int16(bufOff+i))
+		a.jmpImm(bpfJEQ, 2, ':', "hashed")
+		a.alu32Imm(bpfMUL, 8, 31)
+		a.alu32Reg(bpfADD, 8, 2)
+	}
+	a.label("hashed")
+	a.alu32Imm(bpfMOD, 8, int32(numSockets))
+	a.stxMem(bpfW, 10, 8, valueOff)
+	a.ldMapFD(1, mapFD)
+	a.stackPtr(2, keyOff)
+	a.stackPtr(3, valueOff)
+	a.mov64Imm(4, 0)
+	a.call(bpfFuncMapUpdateElem)
+	a.mov64Reg(0, 8)
+	a.exit()
+
+	// index stored for the remote address
+	a.label("lookup")
+	a.ldMapFD(1, mapFD)
+	a.stackPtr(2, keyOff)
+	a.call(bpfFuncMapLookupElem)
+	a.jmpImm(bpfJEQ, 0, 0, "first")
+	a.ldxMem(bpfW, 0, 0, 0)
+	a.exit()
+
+	a.label("first")
+	a.mov64Imm(0, 0)
+	a.exit()
+
+	return a.assemble()
+}
+
+// loadBytes loads size bytes of the payload at the offset in r2 to the stack, r0 is 0 on success
+func (a *bpfAsm) loadBytes(stackOff int32, size int32) {
+	a.mov64Reg(1, 6)
+	a.stackPtr(3, stackOff)
+	a.mov64Imm(4, size)
+	a.call(bpfFuncSkbLoadBytes)
+}
+
+// loadBytesRelative loads size bytes of the network header at off to the stack, r0 is 0 on success
+func (a *bpfAsm) loadBytesRelative(off int32, stackOff int32, size int32) {
+	a.mov64Reg(1, 6)
+	a.mov64Imm(2, off)
+	a.stackPtr(3, stackOff)
+	a.mov64Imm(4, size)
+	a.mov64Imm(5, bpfHdrStartNet)
+	a.call(bpfFuncSkbLoadBytesRelative)
+}
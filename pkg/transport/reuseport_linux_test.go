@@ -0,0 +1,112 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && ebpf
+// +build linux,ebpf
+
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pion/stun"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReusePortSteering(t *testing.T) {
+	if err := ReusePortSupported(); err != nil {
+		t.Skipf("steering not supported: %v", err)
+	}
+
+	t.Run("IPv4", func(t *testing.T) {
+		testReusePortSteering(t, net.IPv4(127, 0, 0, 1))
+	})
+	t.Run("IPv6", func(t *testing.T) {
+		testReusePortSteering(t, net.IPv6loopback)
+	})
+}
+
+func testReusePortSteering(t *testing.T, ip net.IP) {
+	const numSockets = 4
+	conns, err := listenReusePort(ip, 0, ReusePortParams{
+		NumSockets:     numSockets,
+		MaxRemoteAddrs: 16,
+	})
+	require.NoError(t, err)
+	require.Len(t, conns, numSockets)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	addr := conns[0].LocalAddr().(*net.UDPAddr)
+	for _, conn := range conns[1:] {
+		require.Equal(t, addr.Port, conn.LocalAddr().(*net.UDPAddr).Port)
+	}
+
+	received := make(chan int, 16)
+	for i, conn := range conns {
+		go func(i int, conn *net.UDPConn) {
+			buf := make([]byte, 1500)
+			for {
+				if _, _, err := conn.ReadFrom(buf); err != nil {
+					return
+				}
+				received <- i
+			}
+		}(i, conn)
+	}
+	expectSocket := func(t *testing.T, expected int) {
+		select {
+		case i := <-received:
+			require.Equal(t, expected, i)
+		case <-time.After(time.Second):
+			require.Fail(t, "packet not received")
+		}
+	}
+
+	// one ufrag per socket, the last one is longer than the hashed part
+	for _, ufrag := range []string{"aBcDeFgHiJkLmNoP", "aBcDeFgHiJkLmNoQ", "aBcDeFgHiJkLmNoR", "aBcDeFgHiJkLmNoS", "1123456789abcdef0123456789abcdefXYZ"} {
+		t.Run(ufrag, func(t *testing.T) {
+			client, err := net.DialUDP("udp", nil, addr)
+			require.NoError(t, err)
+			defer client.Close()
+
+			expected := reusePortIndex(ufrag, numSockets)
+
+			// packets before a binding request land on the first socket
+			_, err = client.Write([]byte{0x80, 0x60, 0x00, 0x01})
+			require.NoError(t, err)
+			expectSocket(t, 0)
+
+			msg, err := stun.Build(stun.TransactionID, stun.BindingRequest,
+				stun.NewUsername(ufrag+":remote"),
+				stun.NewShortTermIntegrity("password"),
+				stun.Fingerprint,
+			)
+			require.NoError(t, err)
+			_, err = client.Write(msg.Raw)
+			require.NoError(t, err)
+			expectSocket(t, expected)
+
+			// media from the same address follows the binding request
+			_, err = client.Write([]byte{0x80, 0x60, 0x00, 0x02})
+			require.NoError(t, err)
+			expectSocket(t, expected)
+		})
+	}
+}
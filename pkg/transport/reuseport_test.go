@@ -0,0 +1,32 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReusePortIndex(t *testing.T) {
+	indexes := make(map[int]bool)
+	for _, ufrag := range []string{"aBcDeFgHiJkLmNoP", "aBcDeFgHiJkLmNoQ", "aBcDeFgHiJkLmNoR", "aBcDeFgHiJkLmNoS"} {
+		indexes[reusePortIndex(ufrag, 4)] = true
+	}
+	require.Len(t, indexes, 4)
+
+	// only the first bytes are hashed
+	require.Equal(t, reusePortIndex("1123456789abcdef0123456789abcdef", 4), reusePortIndex("1123456789abcdef0123456789abcdefXYZ", 4))
+}
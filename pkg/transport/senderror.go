@@ -0,0 +1,249 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type SendErrorClass int
+
+const (
+	SendErrorClassNone SendErrorClass = iota
+	// no socket buffer space (ENOBUFS, EAGAIN), transient, retried after a backoff
+	SendErrorClassBufferFull
+	// denied locally (EPERM, EACCES), e.g. a full conntrack table or a firewall rule, packets are dropped
+	SendErrorClassDenied
+	// the remote is not reachable (ECONNREFUSED, ECONNRESET, EHOSTUNREACH, ENETUNREACH), packets are dropped
+	SendErrorClassUnreachable
+	// the connection is closed, nothing to recover
+	SendErrorClassClosed
+	SendErrorClassUnknown
+
+	numSendErrorClasses
+)
+
+func (c SendErrorClass) String() string {
+	switch c {
+	case SendErrorClassNone:
+		return "NONE"
+	case SendErrorClassBufferFull:
+		return "BUFFER_FULL"
+	case SendErrorClassDenied:
+		return "DENIED"
+	case SendErrorClassUnreachable:
+		return "UNREACHABLE"
+	case SendErrorClassClosed:
+		return "CLOSED"
+	case SendErrorClassUnknown:
+		return "UNKNOWN"
+	default:
+		return fmt.Sprintf("%d", int(c))
+	}
+}
+
+// ClassifySendError returns the class of an error of a write to a socket.
+func ClassifySendError(err error) SendErrorClass {
+	switch {
+	case err == nil:
+		return SendErrorClassNone
+	case errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.ENOMEM):
+		return SendErrorClassBufferFull
+	case errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EACCES):
+		return SendErrorClassDenied
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return SendErrorClassUnreachable
+	case errors.Is(err, net.ErrClosed) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, syscall.EPIPE):
+		return SendErrorClassClosed
+	default:
+		return SendErrorClassUnknown
+	}
+}
+
+type SendErrorParams struct {
+	// retries of a write failing with SendErrorClassBufferFull, the backoff doubles from InitialBackoff up to MaxBackoff
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// writes failing as denied or unreachable for this long, without a successful write, suggest an ICE restart
+	// to find another path
+	ICERestartAfter time.Duration
+}
+
+var SendErrorParamsDefault = SendErrorParams{
+	MaxRetries:      2,
+	InitialBackoff:  500 * time.Microsecond,
+	MaxBackoff:      5 * time.Millisecond,
+	ICERestartAfter: 2 * time.Second,
+}
+
+type SendErrorStats struct {
+	NumWrites int
+	// failed writes, after retries, indexed by SendErrorClass
+	NumErrors          [numSendErrorClasses]int
+	NumRetries         int
+	NumRecoveredWrites int
+	NumICERestarts     int
+}
+
+// ICERestartSuggestion is emitted when writes of a connection persistently fail in a way an ICE restart,
+// finding another candidate pair, may recover from.
+type ICERestartSuggestion struct {
+	Class SendErrorClass
+	// failed writes since the last successful one
+	NumErrors int
+	Since     time.Time
+	LastError error
+}
+
+// SendErrorTracker classifies the write errors of a connection and applies a recovery: writes failing for lack of
+// buffer space are retried after a short backoff, writes denied or to an unreachable remote are dropped and,
+// if that persists, an ICE restart is suggested. Errors are counted by class, rather than only logged.
+type SendErrorTracker struct {
+	params SendErrorParams
+	sleep  func(d time.Duration)
+
+	lock           sync.Mutex
+	stats          SendErrorStats
+	numFailures    int
+	firstFailureAt time.Time
+	suggested      bool
+	onICERestart   func(suggestion ICERestartSuggestion)
+}
+
+func NewSendErrorTracker(params SendErrorParams) *SendErrorTracker {
+	if params.MaxRetries < 0 {
+		params.MaxRetries = SendErrorParamsDefault.MaxRetries
+	}
+	if params.InitialBackoff <= 0 {
+		params.InitialBackoff = SendErrorParamsDefault.InitialBackoff
+	}
+	if params.MaxBackoff < params.InitialBackoff {
+		params.MaxBackoff = params.InitialBackoff
+	}
+	if params.ICERestartAfter <= 0 {
+		params.ICERestartAfter = SendErrorParamsDefault.ICERestartAfter
+	}
+	return &SendErrorTracker{
+		params: params,
+		sleep:  time.Sleep,
+	}
+}
+
+// OnICERestart sets the callback called when an ICE restart is suggested, once until a write succeeds again.
+func (t *SendErrorTracker) OnICERestart(f func(suggestion ICERestartSuggestion)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.onICERestart = f
+}
+
+func (t *SendErrorTracker) Stats() SendErrorStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.stats
+}
+
+// Write calls write, retrying it on transient errors, and records the outcome.
+func (t *SendErrorTracker) Write(write func() (int, error)) (int, error) {
+	n, err := write()
+	backoff := t.params.InitialBackoff
+	numRetries := 0
+	for ; err != nil && ClassifySendError(err) == SendErrorClassBufferFull && numRetries < t.params.MaxRetries; numRetries++ {
+		t.sleep(backoff)
+		if backoff *= 2; backoff > t.params.MaxBackoff {
+			backoff = t.params.MaxBackoff
+		}
+		n, err = write()
+	}
+
+	suggestion, onICERestart := t.record(err, numRetries, time.Now())
+	if suggestion != nil && onICERestart != nil {
+		onICERestart(*suggestion)
+	}
+	return n, err
+}
+
+func (t *SendErrorTracker) record(err error, numRetries int, now time.Time) (*ICERestartSuggestion, func(suggestion ICERestartSuggestion)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.stats.NumWrites++
+	t.stats.NumRetries += numRetries
+	class := ClassifySendError(err)
+	if class == SendErrorClassNone {
+		if numRetries != 0 {
+			t.stats.NumRecoveredWrites++
+		}
+		t.numFailures = 0
+		t.suggested = false
+		return nil, nil
+	}
+
+	t.stats.NumErrors[class]++
+	if class != SendErrorClassDenied && class != SendErrorClassUnreachable {
+		return nil, nil
+	}
+	if t.numFailures == 0 {
+		t.firstFailureAt = now
+	}
+	t.numFailures++
+	if t.suggested || now.Sub(t.firstFailureAt) < t.params.ICERestartAfter {
+		return nil, nil
+	}
+
+	t.suggested = true
+	t.stats.NumICERestarts++
+	return &ICERestartSuggestion{
+		Class:     class,
+		NumErrors: t.numFailures,
+		Since:     t.firstFailureAt,
+		LastError: err,
+	}, t.onICERestart
+}
+
+// ------------------------------------------------
+
+// SendErrorConn applies a SendErrorTracker to the writes of a connection.
+type SendErrorConn struct {
+	net.PacketConn
+
+	tracker *SendErrorTracker
+}
+
+func NewSendErrorConn(pc net.PacketConn, tracker *SendErrorTracker) *SendErrorConn {
+	return &SendErrorConn{
+		PacketConn: pc,
+		tracker:    tracker,
+	}
+}
+
+func (c *SendErrorConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.tracker.Write(func() (int, error) {
+		return c.PacketConn.WriteTo(b, addr)
+	})
+}
+
+func (c *SendErrorConn) Tracker() *SendErrorTracker {
+	return c.tracker
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifySendError(t *testing.T) {
+	wrap := func(errno syscall.Errno) error {
+		return &net.OpError{Op: "write", Net: "udp", Err: os.NewSyscallError("sendto", errno)}
+	}
+
+	require.Equal(t, SendErrorClassNone, ClassifySendError(nil))
+	require.Equal(t, SendErrorClassBufferFull, ClassifySendError(wrap(syscall.ENOBUFS)))
+	require.Equal(t, SendErrorClassDenied, ClassifySendError(wrap(syscall.EPERM)))
+	require.Equal(t, SendErrorClassUnreachable, ClassifySendError(wrap(syscall.ECONNREFUSED)))
+	require.Equal(t, SendErrorClassClosed, ClassifySendError(fmt.Errorf("write: %w", net.ErrClosed)))
+	require.Equal(t, SendErrorClassUnknown, ClassifySendError(fmt.Errorf("something else")))
+}
+
+func TestSendErrorTracker(t *testing.T) {
+	t.Run("retries buffer full", func(t *testing.T) {
+		tracker := NewSendErrorTracker(SendErrorParamsDefault)
+		var backoffs []time.Duration
+		tracker.sleep = func(d time.Duration) { backoffs = append(backoffs, d) }
+
+		numCalls := 0
+		n, err := tracker.Write(func() (int, error) {
+			numCalls++
+			if numCalls < 3 {
+				return 0, syscall.ENOBUFS
+			}
+			return 10, nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 10, n)
+		require.Equal(t, []time.Duration{500 * time.Microsecond, time.Millisecond}, backoffs)
+
+		// gives up after MaxRetries
+		_, err = tracker.Write(func() (int, error) { return 0, syscall.ENOBUFS })
+		require.ErrorIs(t, err, syscall.ENOBUFS)
+
+		stats := tracker.Stats()
+		require.Equal(t, 2, stats.NumWrites)
+		require.Equal(t, 4, stats.NumRetries)
+		require.Equal(t, 1, stats.NumRecoveredWrites)
+		require.Equal(t, 1, stats.NumErrors[SendErrorClassBufferFull])
+	})
+
+	t.Run("suggests ICE restart", func(t *testing.T) {
+		tracker := NewSendErrorTracker(SendErrorParamsDefault)
+		var suggestions []ICERestartSuggestion
+		tracker.OnICERestart(func(suggestion ICERestartSuggestion) {
+			suggestions = append(suggestions, suggestion)
+		})
+
+		now := time.Now()
+		record := func(err error, at time.Duration) {
+			suggestion, onICERestart := tracker.record(err, 0, now.Add(at))
+			if suggestion != nil {
+				onICERestart(*suggestion)
+			}
+		}
+
+		record(syscall.EPERM, 0)
+		record(syscall.EPERM, time.Second)
+		require.Empty(t, suggestions)
+
+		record(syscall.EPERM, 2*time.Second)
+		require.Len(t, suggestions, 1)
+		require.Equal(t, SendErrorClassDenied, suggestions[0].Class)
+		require.Equal(t, 3, suggestions[0].NumErrors)
+		require.Equal(t, now, suggestions[0].Since)
+
+		// once until a write succeeds
+		record(syscall.EPERM, 3*time.Second)
+		require.Len(t, suggestions, 1)
+
+		record(nil, 4*time.Second)
+		record(syscall.ECONNREFUSED, 5*time.Second)
+		record(syscall.ECONNREFUSED, 8*time.Second)
+		require.Len(t, suggestions, 2)
+		require.Equal(t, SendErrorClassUnreachable, suggestions[1].Class)
+
+		stats := tracker.Stats()
+		require.Equal(t, 2, stats.NumICERestarts)
+		require.Equal(t, 4, stats.NumErrors[SendErrorClassDenied])
+		require.Equal(t, 2, stats.NumErrors[SendErrorClassUnreachable])
+	})
+}
+
+func TestSendErrorConn(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer peer.Close()
+
+	conn := NewSendErrorConn(pc, NewSendErrorTracker(SendErrorParamsDefault))
+	_, err = conn.WriteTo([]byte("hello"), peer.LocalAddr())
+	require.NoError(t, err)
+
+	conn.Close()
+	_, err = conn.WriteTo([]byte("hello"), peer.LocalAddr())
+	require.Error(t, err)
+
+	stats := conn.Tracker().Stats()
+	require.Equal(t, 2, stats.NumWrites)
+	require.Equal(t, 1, stats.NumErrors[SendErrorClassClosed])
+}
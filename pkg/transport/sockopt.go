@@ -0,0 +1,118 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/livekit/protocol/logger"
+)
+
+var (
+	ErrSocketOptionNotSupported = errors.New("socket option is not supported on this platform")
+)
+
+// SocketOption is a socket option tuned on UDP mux sockets, applied with the closest equivalent of the platform.
+type SocketOption int
+
+const (
+	SocketOptionReadBuffer SocketOption = iota
+	SocketOptionWriteBuffer
+)
+
+func (s SocketOption) String() string {
+	switch s {
+	case SocketOptionReadBuffer:
+		return "READ_BUFFER"
+	case SocketOptionWriteBuffer:
+		return "WRITE_BUFFER"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type SocketOptionResult struct {
+	Option    SocketOption
+	Requested int
+	// value in effect, less than requested when limited by the system, 0 when it cannot be read back
+	Applied int
+	// setting or reading back failed, matches ErrSocketOptionNotSupported when the platform has no equivalent
+	Err error
+}
+
+func (r SocketOptionResult) IsSupported() bool {
+	return !errors.Is(r.Err, ErrSocketOptionNotSupported)
+}
+
+// SetSocketBuffer sets the receive or send buffer size of conn and reads back the size in effect.
+// Sizes are comparable across platforms, on Linux the doubled size reported by the kernel is halved.
+// Where the system limits buffers, the closest size allowed is applied:
+//   - Linux clamps to net.core.rmem_max / wmem_max, the limit is bypassed with CAP_NET_ADMIN
+//   - macOS and BSD reject sizes above kern.ipc.maxsockbuf, the size is halved until accepted
+func SetSocketBuffer(conn *net.UDPConn, option SocketOption, size int) SocketOptionResult {
+	r := SocketOptionResult{
+		Option:    option,
+		Requested: size,
+	}
+	if option != SocketOptionReadBuffer && option != SocketOptionWriteBuffer {
+		r.Err = fmt.Errorf("%w: %s", ErrSocketOptionNotSupported, option)
+		return r
+	}
+
+	if err := setSocketBuffer(conn, option, size); err != nil {
+		r.Err = err
+		return r
+	}
+	r.Applied, r.Err = SocketBuffer(conn, option)
+	return r
+}
+
+// SocketBuffer returns the receive or send buffer size of conn, see SetSocketBuffer.
+func SocketBuffer(conn *net.UDPConn, option SocketOption) (int, error) {
+	if option != SocketOptionReadBuffer && option != SocketOptionWriteBuffer {
+		return 0, fmt.Errorf("%w: %s", ErrSocketOptionNotSupported, option)
+	}
+	return getSocketBuffer(conn, option)
+}
+
+// setSocketBuffers applies the buffer sizes of a mux port, sizes of 0 are left at the system default
+func setSocketBuffers(conn *net.UDPConn, readBufferSize int, writeBufferSize int) {
+	for option, size := range map[SocketOption]int{
+		SocketOptionReadBuffer:  readBufferSize,
+		SocketOptionWriteBuffer: writeBufferSize,
+	} {
+		if size <= 0 {
+			continue
+		}
+		r := SetSocketBuffer(conn, option, size)
+		switch {
+		case !r.IsSupported():
+			logger.Infow("socket option not supported", "option", option, "local", conn.LocalAddr(), "error", r.Err)
+		case r.Err != nil:
+			logger.Warnw("could not set socket option", r.Err, "option", option, "local", conn.LocalAddr(), "requested", size)
+		case r.Applied < size:
+			logger.Debugw("socket option limited by system", "option", option, "local", conn.LocalAddr(), "requested", size, "applied", r.Applied)
+		}
+	}
+}
+
+func setBuffer(conn *net.UDPConn, option SocketOption, size int) error {
+	if option == SocketOptionReadBuffer {
+		return conn.SetReadBuffer(size)
+	}
+	return conn.SetWriteBuffer(size)
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+const (
+	// smallest size tried when the system rejects larger buffers
+	minSocketBufferSize = 64 * 1024
+)
+
+func setSocketBuffer(conn *net.UDPConn, option SocketOption, size int) error {
+	// sizes above kern.ipc.maxsockbuf fail with ENOBUFS instead of being clamped
+	var err error
+	for ; size >= minSocketBufferSize; size /= 2 {
+		if err = setBuffer(conn, option, size); !errors.Is(err, syscall.ENOBUFS) {
+			return err
+		}
+	}
+	return err
+}
+
+func getSocketBuffer(conn *net.UDPConn, option SocketOption) (int, error) {
+	opt := syscall.SO_RCVBUF
+	if option == SocketOptionWriteBuffer {
+		opt = syscall.SO_SNDBUF
+	}
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var size int
+	var opErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		size, opErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, opt)
+	}); err != nil {
+		return 0, err
+	}
+	return size, opErr
+}
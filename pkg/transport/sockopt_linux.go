@@ -0,0 +1,56 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package transport
+
+import (
+	"net"
+	"syscall"
+)
+
+func setSocketBuffer(conn *net.UDPConn, option SocketOption, size int) error {
+	if err := setBuffer(conn, option, size); err != nil {
+		return err
+	}
+
+	if applied, err := getSocketBuffer(conn, option); err == nil && applied < size {
+		// clamped to rmem_max / wmem_max, forcing needs CAP_NET_ADMIN, keep the clamped size otherwise
+		opt := syscall.SO_RCVBUFFORCE
+		if option == SocketOptionWriteBuffer {
+			opt = syscall.SO_SNDBUFFORCE
+		}
+		_ = controlSocket(conn, func(fd int) error {
+			return syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, opt, size)
+		})
+	}
+	return nil
+}
+
+func getSocketBuffer(conn *net.UDPConn, option SocketOption) (int, error) {
+	opt := syscall.SO_RCVBUF
+	if option == SocketOptionWriteBuffer {
+		opt = syscall.SO_SNDBUF
+	}
+	var size int
+	err := controlSocket(conn, func(fd int) error {
+		var err error
+		size, err = syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, opt)
+		return err
+	})
+	// the kernel doubles the size set to account for bookkeeping overhead, see socket(7)
+	return size / 2, err
+}
@@ -0,0 +1,31 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!windows,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package transport
+
+import (
+	"fmt"
+	"net"
+)
+
+func setSocketBuffer(conn *net.UDPConn, option SocketOption, size int) error {
+	return setBuffer(conn, option, size)
+}
+
+func getSocketBuffer(conn *net.UDPConn, option SocketOption) (int, error) {
+	return 0, fmt.Errorf("%w: reading %s", ErrSocketOptionNotSupported, option)
+}
@@ -0,0 +1,48 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSocketBuffer(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	for _, option := range []SocketOption{SocketOptionReadBuffer, SocketOptionWriteBuffer} {
+		r := SetSocketBuffer(conn, option, 256*1024)
+		require.Equal(t, option, r.Option)
+		require.Equal(t, 256*1024, r.Requested)
+		if !r.IsSupported() {
+			t.Skipf("%s not supported", option)
+		}
+		require.NoError(t, r.Err)
+		require.Greater(t, r.Applied, 0)
+		require.LessOrEqual(t, r.Applied, 2*r.Requested)
+
+		size, err := SocketBuffer(conn, option)
+		require.NoError(t, err)
+		require.Equal(t, r.Applied, size)
+	}
+
+	r := SetSocketBuffer(conn, SocketOption(100), 1024)
+	require.False(t, r.IsSupported())
+	require.ErrorIs(t, r.Err, ErrSocketOptionNotSupported)
+}
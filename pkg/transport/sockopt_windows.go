@@ -0,0 +1,48 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+// +build windows
+
+package transport
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+func setSocketBuffer(conn *net.UDPConn, option SocketOption, size int) error {
+	return setBuffer(conn, option, size)
+}
+
+func getSocketBuffer(conn *net.UDPConn, option SocketOption) (int, error) {
+	opt := int32(syscall.SO_RCVBUF)
+	if option == SocketOptionWriteBuffer {
+		opt = syscall.SO_SNDBUF
+	}
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var size int32
+	var opErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		length := int32(unsafe.Sizeof(size))
+		opErr = syscall.Getsockopt(syscall.Handle(fd), syscall.SOL_SOCKET, opt, (*byte)(unsafe.Pointer(&size)), &length)
+	}); err != nil {
+		return 0, err
+	}
+	return int(size), opErr
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/ice/v2"
+)
+
+var (
+	ErrUfragInUse = errors.New("ufrag is registered to another session")
+)
+
+// UfragSession is a snapshot of a registered local ufrag, for debugging.
+type UfragSession struct {
+	Ufrag     string
+	SessionID string
+	// remote addresses packets to the ufrag were received from, in order of first use
+	RemoteAddrs  []net.Addr
+	RegisteredAt time.Time
+	// when the last new remote address was seen, zero if none yet
+	LastRemoteAddrAt time.Time
+}
+
+type ufragEntry struct {
+	sessionID        string
+	remoteAddrs      []net.Addr
+	registeredAt     time.Time
+	lastRemoteAddrAt time.Time
+}
+
+// UfragRegistry maps the local ICE ufrags of peer connections to application sessions, so that traffic on a mux
+// shared by many peer connections can be attributed to a session. Remote addresses are learnt from the
+// connections handed out by a SessionUDPMux.
+type UfragRegistry struct {
+	lock    sync.RWMutex
+	ufrags  map[string]*ufragEntry
+	remotes map[netip.AddrPort]string
+}
+
+func NewUfragRegistry() *UfragRegistry {
+	return &UfragRegistry{
+		ufrags:  make(map[string]*ufragEntry),
+		remotes: make(map[netip.AddrPort]string),
+	}
+}
+
+// Register maps ufrag to sessionID, registering it again for the same session is a no-op.
+func (r *UfragRegistry) Register(ufrag string, sessionID string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if entry, ok := r.ufrags[ufrag]; ok {
+		if entry.sessionID != sessionID {
+			return ErrUfragInUse
+		}
+		return nil
+	}
+	r.ufrags[ufrag] = &ufragEntry{
+		sessionID:    sessionID,
+		registeredAt: time.Now(),
+	}
+	return nil
+}
+
+// Unregister removes ufrag along with the remote addresses mapped to it.
+func (r *UfragRegistry) Unregister(ufrag string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.ufrags[ufrag]
+	if !ok {
+		return
+	}
+	r.removeRemoteAddrsLocked(ufrag, entry)
+	delete(r.ufrags, ufrag)
+}
+
+// UnregisterSession removes all ufrags of sessionID, for example those of previous ICE restarts.
+func (r *UfragRegistry) UnregisterSession(sessionID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for ufrag, entry := range r.ufrags {
+		if entry.sessionID == sessionID {
+			r.removeRemoteAddrsLocked(ufrag, entry)
+			delete(r.ufrags, ufrag)
+		}
+	}
+}
+
+func (r *UfragRegistry) SessionByUfrag(ufrag string) (string, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	entry, ok := r.ufrags[ufrag]
+	if !ok {
+		return "", false
+	}
+	return entry.sessionID, true
+}
+
+// SessionByRemoteAddr returns the session and ufrag packets from addr were last received for.
+func (r *UfragRegistry) SessionByRemoteAddr(addr net.Addr) (sessionID string, ufrag string, ok bool) {
+	key, valid := addrPortOf(addr)
+	if !valid {
+		return "", "", false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	ufrag, ok = r.remotes[key]
+	if !ok {
+		return "", "", false
+	}
+	return r.ufrags[ufrag].sessionID, ufrag, true
+}
+
+// Sessions returns the registered ufrags ordered by ufrag, only those of sessionID when it is not empty.
+func (r *UfragRegistry) Sessions(sessionID string) []UfragSession {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	sessions := make([]UfragSession, 0, len(r.ufrags))
+	for ufrag, entry := range r.ufrags {
+		if sessionID != "" && entry.sessionID != sessionID {
+			continue
+		}
+		sessions = append(sessions, UfragSession{
+			Ufrag:            ufrag,
+			SessionID:        entry.sessionID,
+			RemoteAddrs:      append([]net.Addr(nil), entry.remoteAddrs...),
+			RegisteredAt:     entry.registeredAt,
+			LastRemoteAddrAt: entry.lastRemoteAddrAt,
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Ufrag < sessions[j].Ufrag
+	})
+	return sessions
+}
+
+// addRemoteAddr maps addr to ufrag, returns false for unregistered ufrags. An address moving to another ufrag,
+// for example after an ICE restart, is remapped.
+func (r *UfragRegistry) addRemoteAddr(ufrag string, key netip.AddrPort, addr net.Addr) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	entry, ok := r.ufrags[ufrag]
+	if !ok {
+		return false
+	}
+	if previous, ok := r.remotes[key]; ok {
+		if previous == ufrag {
+			return true
+		}
+		if previousEntry := r.ufrags[previous]; previousEntry != nil {
+			previousEntry.remoteAddrs = removeAddr(previousEntry.remoteAddrs, key)
+		}
+	}
+	r.remotes[key] = ufrag
+	entry.remoteAddrs = append(entry.remoteAddrs, addr)
+	entry.lastRemoteAddrAt = time.Now()
+	return true
+}
+
+func (r *UfragRegistry) removeRemoteAddrsLocked(ufrag string, entry *ufragEntry) {
+	for _, addr := range entry.remoteAddrs {
+		if key, ok := addrPortOf(addr); ok && r.remotes[key] == ufrag {
+			delete(r.remotes, key)
+		}
+	}
+	entry.remoteAddrs = nil
+}
+
+func removeAddr(addrs []net.Addr, key netip.AddrPort) []net.Addr {
+	for i, addr := range addrs {
+		if k, ok := addrPortOf(addr); ok && k == key {
+			return append(addrs[:i], addrs[i+1:]...)
+		}
+	}
+	return addrs
+}
+
+func addrPortOf(addr net.Addr) (netip.AddrPort, bool) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+	ap := udpAddr.AddrPort()
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port()), true
+}
+
+// ------------------------------------------------
+
+// SessionUDPMux is a UDPMux recording the remote addresses of the connections it hands out in a UfragRegistry.
+type SessionUDPMux struct {
+	ice.UDPMux
+
+	registry *UfragRegistry
+}
+
+func NewSessionUDPMux(mux ice.UDPMux, registry *UfragRegistry) *SessionUDPMux {
+	return &SessionUDPMux{
+		UDPMux:   mux,
+		registry: registry,
+	}
+}
+
+func (s *SessionUDPMux) GetConn(ufrag string, addr net.Addr) (net.PacketConn, error) {
+	conn, err := s.UDPMux.GetConn(ufrag, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionConn{
+		PacketConn: conn,
+		ufrag:      ufrag,
+		registry:   s.registry,
+		seen:       make(map[netip.AddrPort]struct{}),
+	}, nil
+}
+
+func (s *SessionUDPMux) Registry() *UfragRegistry {
+	return s.registry
+}
+
+var _ ice.UDPMux = (*SessionUDPMux)(nil)
+
+type sessionConn struct {
+	net.PacketConn
+
+	ufrag    string
+	registry *UfragRegistry
+
+	lock sync.Mutex
+	seen map[netip.AddrPort]struct{}
+}
+
+func (c *sessionConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if err == nil {
+		if key, ok := addrPortOf(addr); ok {
+			c.lock.Lock()
+			_, seen := c.seen[key]
+			c.lock.Unlock()
+
+			// addresses are recorded once the ufrag is registered
+			if !seen && c.registry.addRemoteAddr(c.ufrag, key, addr) {
+				c.lock.Lock()
+				c.seen[key] = struct{}{}
+				c.lock.Unlock()
+			}
+		}
+	}
+	return n, addr, err
+}
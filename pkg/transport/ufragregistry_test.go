@@ -0,0 +1,132 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testUDPMux hands out one socket per ufrag
+type testUDPMux struct {
+	conns map[string]net.PacketConn
+}
+
+func (m *testUDPMux) GetConn(ufrag string, _ net.Addr) (net.PacketConn, error) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	m.conns[ufrag] = conn
+	return conn, nil
+}
+
+func (m *testUDPMux) RemoveConnByUfrag(ufrag string) {
+	if conn := m.conns[ufrag]; conn != nil {
+		_ = conn.Close()
+		delete(m.conns, ufrag)
+	}
+}
+
+func (m *testUDPMux) GetListenAddresses() []net.Addr { return nil }
+
+func (m *testUDPMux) Close() error { return nil }
+
+func TestUfragRegistry(t *testing.T) {
+	r := NewUfragRegistry()
+	require.NoError(t, r.Register("ufragA", "session1"))
+	require.NoError(t, r.Register("ufragA", "session1"))
+	require.ErrorIs(t, r.Register("ufragA", "session2"), ErrUfragInUse)
+	require.NoError(t, r.Register("ufragB", "session2"))
+
+	mux := NewSessionUDPMux(&testUDPMux{conns: make(map[string]net.PacketConn)}, r)
+	require.Equal(t, r, mux.Registry())
+
+	connA, err := mux.GetConn("ufragA", nil)
+	require.NoError(t, err)
+	connB, err := mux.GetConn("ufragB", nil)
+	require.NoError(t, err)
+	// not registered, remote addresses are not recorded until it is
+	connC, err := mux.GetConn("ufragC", nil)
+	require.NoError(t, err)
+
+	remote1, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer remote1.Close()
+	remote2, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer remote2.Close()
+
+	receive := func(conn net.PacketConn, from *net.UDPConn) {
+		_, err := from.WriteTo([]byte("ping"), conn.LocalAddr())
+		require.NoError(t, err)
+		buf := make([]byte, 16)
+		_, _, err = conn.ReadFrom(buf)
+		require.NoError(t, err)
+	}
+	receive(connA, remote1)
+	receive(connA, remote1)
+	receive(connB, remote2)
+	receive(connC, remote2)
+
+	sessionID, ufrag, ok := r.SessionByRemoteAddr(remote1.LocalAddr())
+	require.True(t, ok)
+	require.Equal(t, "session1", sessionID)
+	require.Equal(t, "ufragA", ufrag)
+
+	sessionID, ufrag, ok = r.SessionByRemoteAddr(remote2.LocalAddr())
+	require.True(t, ok)
+	require.Equal(t, "session2", sessionID)
+	require.Equal(t, "ufragB", ufrag)
+
+	sessions := r.Sessions("")
+	require.Len(t, sessions, 2)
+	require.Equal(t, "ufragA", sessions[0].Ufrag)
+	require.Len(t, sessions[0].RemoteAddrs, 1)
+	require.Equal(t, remote1.LocalAddr().String(), sessions[0].RemoteAddrs[0].String())
+	require.False(t, sessions[0].LastRemoteAddrAt.IsZero())
+
+	// an ICE restart of session2 moves the remote address to the new ufrag
+	require.NoError(t, r.Register("ufragC", "session2"))
+	receive(connC, remote2)
+	_, ufrag, ok = r.SessionByRemoteAddr(remote2.LocalAddr())
+	require.True(t, ok)
+	require.Equal(t, "ufragC", ufrag)
+	sessions = r.Sessions("session2")
+	require.Len(t, sessions, 2)
+	require.Empty(t, sessions[0].RemoteAddrs)
+	require.Len(t, sessions[1].RemoteAddrs, 1)
+
+	sessionID, ok = r.SessionByUfrag("ufragC")
+	require.True(t, ok)
+	require.Equal(t, "session2", sessionID)
+
+	r.UnregisterSession("session2")
+	_, ok = r.SessionByUfrag("ufragC")
+	require.False(t, ok)
+	_, _, ok = r.SessionByRemoteAddr(remote2.LocalAddr())
+	require.False(t, ok)
+
+	r.Unregister("ufragA")
+	_, _, ok = r.SessionByRemoteAddr(remote1.LocalAddr())
+	require.False(t, ok)
+	require.Empty(t, r.Sessions(""))
+
+	mux.RemoveConnByUfrag("ufragA")
+	mux.RemoveConnByUfrag("ufragB")
+	mux.RemoveConnByUfrag("ufragC")
+}
@@ -0,0 +1,218 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrXDPNotSupported = errors.New("AF_XDP is not supported, build on Linux amd64 or arm64 with the afxdp tag")
+	ErrXDPNoInterface  = errors.New("no interface with the local address")
+)
+
+// XDPParams configures the experimental AF_XDP receive path. Packets to the port are taken off the interface
+// before the network stack, UDP checksums are not verified and netfilter rules do not apply.
+type XDPParams struct {
+	// frames of the packet buffer of each receive queue of the interface, a power of two
+	NumFrames int
+	// size of a frame, the largest packet received, 2048 or 4096
+	FrameSize int
+	// received packets queued for the reader, packets are dropped when it is full
+	QueueSize int
+}
+
+var XDPParamsDefault = XDPParams{
+	NumFrames: 4096,
+	FrameSize: 2048,
+	QueueSize: 1024,
+}
+
+type XDPStats struct {
+	// packets received through AF_XDP
+	NumXDPPackets uint64
+	// packets received through the socket, for example from other interfaces
+	NumSocketPackets uint64
+	// packets dropped as the reader was not keeping up
+	NumDropped uint64
+}
+
+// XDPSupported returns nil if the AF_XDP receive path can be used on the interface.
+func XDPSupported(ifName string) error {
+	return xdpSupported(ifName)
+}
+
+// ------------------------------------------------
+
+type xdpPacket struct {
+	buf  *[]byte
+	n    int
+	addr net.Addr
+}
+
+// XDPConn receives packets to the port of a UDP socket through AF_XDP sockets on the receive queues
+// of the interface, packets still reaching the socket are received as well. Packets are sent through the socket.
+type XDPConn struct {
+	*net.UDPConn
+
+	params    XDPParams
+	localAddr netip.AddrPort
+	detach    func()
+
+	packets  chan xdpPacket
+	pool     sync.Pool
+	deadline atomic.Value
+
+	numXDPPackets    atomic.Uint64
+	numSocketPackets atomic.Uint64
+	numDropped       atomic.Uint64
+
+	closeOnce sync.Once
+	close     chan struct{}
+}
+
+// NewXDPConn attaches the AF_XDP receive path to the interface of the local address of conn,
+// callers fall back to conn on error.
+func NewXDPConn(conn *net.UDPConn, params XDPParams) (*XDPConn, error) {
+	if params.NumFrames <= 0 {
+		params.NumFrames = XDPParamsDefault.NumFrames
+	}
+	if params.FrameSize <= 0 {
+		params.FrameSize = XDPParamsDefault.FrameSize
+	}
+	if params.QueueSize <= 0 {
+		params.QueueSize = XDPParamsDefault.QueueSize
+	}
+
+	localAddr := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+	x := &XDPConn{
+		UDPConn:   conn,
+		params:    params,
+		localAddr: netip.AddrPortFrom(localAddr.Addr().Unmap(), localAddr.Port()),
+		packets:   make(chan xdpPacket, params.QueueSize),
+		close:     make(chan struct{}),
+	}
+	x.pool.New = func() interface{} {
+		buf := make([]byte, params.FrameSize)
+		return &buf
+	}
+	x.deadline.Store(time.Time{})
+
+	detach, err := attachXDP(x)
+	if err != nil {
+		return nil, err
+	}
+	x.detach = detach
+
+	go x.readSocket()
+	return x, nil
+}
+
+func (x *XDPConn) Stats() XDPStats {
+	return XDPStats{
+		NumXDPPackets:    x.numXDPPackets.Load(),
+		NumSocketPackets: x.numSocketPackets.Load(),
+		NumDropped:       x.numDropped.Load(),
+	}
+}
+
+func (x *XDPConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	var timeout <-chan time.Time
+	if deadline := x.deadline.Load().(time.Time); !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case pkt := <-x.packets:
+		n := copy(p, (*pkt.buf)[:pkt.n])
+		x.pool.Put(pkt.buf)
+		return n, pkt.addr, nil
+	case <-timeout:
+		return 0, nil, &net.OpError{Op: "read", Net: "udp", Source: x.LocalAddr(), Err: errXDPTimeout{}}
+	case <-x.close:
+		return 0, nil, net.ErrClosed
+	}
+}
+
+func (x *XDPConn) SetDeadline(t time.Time) error {
+	x.deadline.Store(t)
+	return x.UDPConn.SetWriteDeadline(t)
+}
+
+func (x *XDPConn) SetReadDeadline(t time.Time) error {
+	x.deadline.Store(t)
+	return nil
+}
+
+func (x *XDPConn) Close() error {
+	var err error
+	x.closeOnce.Do(func() {
+		close(x.close)
+		x.detach()
+		err = x.UDPConn.Close()
+	})
+	return err
+}
+
+func (x *XDPConn) readSocket() {
+	for {
+		buf := x.pool.Get().(*[]byte)
+		n, addr, err := x.UDPConn.ReadFrom(*buf)
+		if err != nil {
+			select {
+			case <-x.close:
+				return
+			default:
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return
+		}
+		x.numSocketPackets.Add(1)
+		x.enqueue(xdpPacket{buf: buf, n: n, addr: addr})
+	}
+}
+
+// deliver queues a packet received through AF_XDP, payload is only valid during the call
+func (x *XDPConn) deliver(payload []byte, from netip.AddrPort) {
+	buf := x.pool.Get().(*[]byte)
+	n := copy(*buf, payload)
+	x.numXDPPackets.Add(1)
+	x.enqueue(xdpPacket{buf: buf, n: n, addr: net.UDPAddrFromAddrPort(from)})
+}
+
+func (x *XDPConn) enqueue(pkt xdpPacket) {
+	select {
+	case x.packets <- pkt:
+	default:
+		x.numDropped.Add(1)
+		x.pool.Put(pkt.buf)
+	}
+}
+
+type errXDPTimeout struct{}
+
+func (errXDPTimeout) Error() string   { return "i/o timeout" }
+func (errXDPTimeout) Timeout() bool   { return true }
+func (errXDPTimeout) Temporary() bool { return true }
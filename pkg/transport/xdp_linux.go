@@ -0,0 +1,611 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && afxdp && (amd64 || arm64)
+// +build linux
+// +build afxdp
+// +build amd64 arm64
+
+package transport
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/livekit/protocol/logger"
+)
+
+const (
+	afXDP  = 44
+	solXDP = 283
+
+	xdpMmapOffsets        = 1
+	xdpRxRing             = 2
+	xdpUmemReg            = 4
+	xdpUmemFillRing       = 5
+	xdpUmemCompletionRing = 6
+
+	xdpPgoffRxRing       = 0
+	xdpUmemPgoffFillRing = 0x100000000
+
+	xdpFlagsSkbMode = 1 << 1
+	xdpFlagsDrvMode = 1 << 2
+
+	bpfAttachTypeXDP   = 37
+	bpfFuncRedirectMap = 51
+	xdpActionPass      = 2
+
+	// unused, as nothing is sent through AF_XDP, but required by bind
+	xdpCompletionRingSize = 64
+	// ports of mux sockets on an interface
+	xdpMaxPorts = 1024
+	// wakes up readers to notice detaching
+	xdpPollTimeoutMs = 100
+)
+
+var (
+	xdpLock       sync.Mutex
+	xdpInterfaces = make(map[int]*xdpInterface)
+)
+
+func xdpSupported(ifName string) error {
+	iface, err := net.InterfaceByName(ifName)
+	if err != nil {
+		return err
+	}
+	if _, err := xdpNumQueues(iface.Name); err != nil {
+		return err
+	}
+
+	fd, err := syscall.Socket(afXDP, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrXDPNotSupported, err)
+	}
+	_ = syscall.Close(fd)
+
+	x := &xdpInterface{linkFD: -1, progFD: -1, xskMapFD: -1, portsMapFD: -1}
+	defer x.closeFDs()
+	return x.load(1)
+}
+
+func attachXDP(c *XDPConn) (func(), error) {
+	iface, err := xdpInterfaceByAddr(c.localAddr.Addr())
+	if err != nil {
+		return nil, err
+	}
+
+	xdpLock.Lock()
+	defer xdpLock.Unlock()
+
+	x := xdpInterfaces[iface.Index]
+	if x == nil {
+		if x, err = newXDPInterface(iface, c.params); err != nil {
+			return nil, err
+		}
+		xdpInterfaces[iface.Index] = x
+	}
+	if err := x.addConnLocked(c); err != nil {
+		if len(x.conns()) == 0 {
+			x.close()
+			delete(xdpInterfaces, iface.Index)
+		}
+		return nil, err
+	}
+
+	return func() {
+		xdpLock.Lock()
+		defer xdpLock.Unlock()
+
+		x.removeConnLocked(c)
+		if len(x.conns()) == 0 {
+			x.close()
+			delete(xdpInterfaces, iface.Index)
+		}
+	}, nil
+}
+
+func xdpInterfaceByAddr(addr netip.Addr) (*net.Interface, error) {
+	if addr.IsUnspecified() {
+		return nil, fmt.Errorf("%w: %s is unspecified", ErrXDPNoInterface, addr)
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok {
+				if ip, ok := netip.AddrFromSlice(ipNet.IP); ok && ip.Unmap() == addr {
+					return &ifaces[i], nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrXDPNoInterface, addr)
+}
+
+func xdpNumQueues(ifName string) (int, error) {
+	queues, err := filepath.Glob(filepath.Join("/sys/class/net", ifName, "queues", "rx-*"))
+	if err != nil {
+		return 0, err
+	}
+	if len(queues) == 0 {
+		return 0, fmt.Errorf("%w: no receive queues on %s", ErrXDPNotSupported, ifName)
+	}
+	return len(queues), nil
+}
+
+// ------------------------------------------------
+
+// xdpInterface is the XDP program of an interface and the AF_XDP sockets of its receive queues,
+// shared by the mux sockets with a local address on the interface
+type xdpInterface struct {
+	name       string
+	linkFD     int
+	progFD     int
+	xskMapFD   int
+	portsMapFD int
+
+	queues []*xdpQueue
+	// local address to conn, replaced on change, read by the queue readers
+	connsByAddr atomic.Value
+	portRefs    map[uint16]int
+}
+
+func newXDPInterface(iface *net.Interface, params XDPParams) (*xdpInterface, error) {
+	numQueues, err := xdpNumQueues(iface.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xdpInterface{
+		name:       iface.Name,
+		linkFD:     -1,
+		progFD:     -1,
+		xskMapFD:   -1,
+		portsMapFD: -1,
+		portRefs:   make(map[uint16]int),
+	}
+	x.connsByAddr.Store(map[netip.AddrPort]*XDPConn{})
+	if err := x.load(numQueues); err != nil {
+		x.closeFDs()
+		return nil, err
+	}
+
+	for queueID := 0; queueID < numQueues; queueID++ {
+		q, err := newXDPQueue(x, iface.Index, queueID, params)
+		if err != nil {
+			x.close()
+			return nil, fmt.Errorf("could not open AF_XDP socket on %s queue %d: %w", iface.Name, queueID, err)
+		}
+		x.queues = append(x.queues, q)
+	}
+
+	// native mode needs driver support, generic mode works on any interface
+	for _, flags := range []uint32{xdpFlagsDrvMode, xdpFlagsSkbMode} {
+		if x.linkFD, err = bpfLinkCreate(x.progFD, iface.Index, bpfAttachTypeXDP, flags); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		x.close()
+		return nil, fmt.Errorf("could not attach XDP program to %s: %w", iface.Name, err)
+	}
+	return x, nil
+}
+
+func (x *xdpInterface) load(numQueues int) error {
+	var err error
+	if x.xskMapFD, err = bpfMapCreate(bpfMapTypeXSKMap, 4, 4, numQueues); err != nil {
+		return fmt.Errorf("%w: could not create XSK map: %v", ErrXDPNotSupported, err)
+	}
+	if x.portsMapFD, err = bpfMapCreate(bpfMapTypeHash, 4, 4, xdpMaxPorts); err != nil {
+		return fmt.Errorf("could not create ports map: %w", err)
+	}
+	insns, err := xdpProgram(x.portsMapFD, x.xskMapFD)
+	if err != nil {
+		return err
+	}
+	if x.progFD, err = bpfProgLoad(bpfProgTypeXDP, bpfAttachTypeXDP, insns); err != nil {
+		return fmt.Errorf("%w: could not load XDP program: %v", ErrXDPNotSupported, err)
+	}
+	return nil
+}
+
+func (x *xdpInterface) conns() map[netip.AddrPort]*XDPConn {
+	return x.connsByAddr.Load().(map[netip.AddrPort]*XDPConn)
+}
+
+func (x *xdpInterface) addConnLocked(c *XDPConn) error {
+	conns := x.conns()
+	if _, ok := conns[c.localAddr]; ok {
+		return fmt.Errorf("AF_XDP receive path already attached to %s", c.localAddr)
+	}
+
+	port := c.localAddr.Port()
+	if x.portRefs[port] == 0 {
+		key, value := uint32(port), uint32(1)
+		if err := bpfMapUpdate(x.portsMapFD, unsafe.Pointer(&key), unsafe.Pointer(&value)); err != nil {
+			return fmt.Errorf("could not add port %d: %w", port, err)
+		}
+	}
+	x.portRefs[port]++
+
+	updated := make(map[netip.AddrPort]*XDPConn, len(conns)+1)
+	for addr, conn := range conns {
+		updated[addr] = conn
+	}
+	updated[c.localAddr] = c
+	x.connsByAddr.Store(updated)
+	return nil
+}
+
+func (x *xdpInterface) removeConnLocked(c *XDPConn) {
+	conns := x.conns()
+	if conns[c.localAddr] != c {
+		return
+	}
+
+	port := c.localAddr.Port()
+	x.portRefs[port]--
+	if x.portRefs[port] == 0 {
+		delete(x.portRefs, port)
+		key := uint32(port)
+		_ = bpfMapDelete(x.portsMapFD, unsafe.Pointer(&key))
+	}
+
+	updated := make(map[netip.AddrPort]*XDPConn, len(conns))
+	for addr, conn := range conns {
+		if conn != c {
+			updated[addr] = conn
+		}
+	}
+	x.connsByAddr.Store(updated)
+}
+
+// dispatch delivers the UDP payload of an Ethernet frame to the conn of its destination address
+func (x *xdpInterface) dispatch(frame []byte) {
+	if len(frame) < 14 {
+		return
+	}
+	var src, dst netip.Addr
+	var udp []byte
+	switch uint16(frame[12])<<8 | uint16(frame[13]) {
+	case 0x0800:
+		if len(frame) < 42 {
+			return
+		}
+		src = netip.AddrFrom4(*(*[4]byte)(frame[26:30]))
+		dst = netip.AddrFrom4(*(*[4]byte)(frame[30:34]))
+		udp = frame[34:]
+	case 0x86dd:
+		if len(frame) < 62 {
+			return
+		}
+		src = netip.AddrFrom16(*(*[16]byte)(frame[22:38]))
+		dst = netip.AddrFrom16(*(*[16]byte)(frame[38:54]))
+		udp = frame[54:]
+	default:
+		return
+	}
+
+	udpLen := int(udp[4])<<8 | int(udp[5])
+	if udpLen < 8 || udpLen > len(udp) {
+		return
+	}
+	srcPort := uint16(udp[0])<<8 | uint16(udp[1])
+	dstPort := uint16(udp[2])<<8 | uint16(udp[3])
+	if c := x.conns()[netip.AddrPortFrom(dst, dstPort)]; c != nil {
+		c.deliver(udp[8:udpLen], netip.AddrPortFrom(src, srcPort))
+	}
+}
+
+func (x *xdpInterface) close() {
+	if x.linkFD >= 0 {
+		// detach first, so that packets go to the sockets again
+		_ = syscall.Close(x.linkFD)
+		x.linkFD = -1
+	}
+	for _, q := range x.queues {
+		q.close()
+	}
+	x.queues = nil
+	x.closeFDs()
+}
+
+func (x *xdpInterface) closeFDs() {
+	for _, fd := range []*int{&x.linkFD, &x.progFD, &x.xskMapFD, &x.portsMapFD} {
+		if *fd >= 0 {
+			_ = syscall.Close(*fd)
+			*fd = -1
+		}
+	}
+}
+
+// xdpProgram redirects UDP packets to the ports in the ports map to the AF_XDP socket of the receive queue.
+// IP options, extension headers and fragments are left to the network stack.
+func xdpProgram(portsMapFD int, xskMapFD int) ([]bpfInsn, error) {
+	a := &bpfAsm{}
+	a.mov64Reg(6, 1)
+	a.ldxMem(bpfW, 2, 6, 0)
+	a.ldxMem(bpfW, 3, 6, 4)
+	a.mov64Reg(4, 2)
+	a.alu64Imm(bpfADD, 4, 42)
+	a.jmpReg(bpfJGT, 4, 3, "pass")
+	a.ldxMem(bpfH, 5, 2, 12)
+	a.toBE(5, 16)
+	a.jmpImm(bpfJEQ, 5, 0x0800, "ipv4")
+	a.jmpImm(bpfJNE, 5, 0x86dd, "pass")
+
+	a.mov64Reg(4, 2)
+	a.alu64Imm(bpfADD, 4, 62)
+	a.jmpReg(bpfJGT, 4, 3, "pass")
+	a.ldxMem(bpfB, 5, 2, 20)
+	a.jmpImm(bpfJNE, 5, syscall.IPPROTO_UDP, "pass")
+	a.ldxMem(bpfH, 5, 2, 56)
+	a.ja("port")
+
+	a.label("ipv4")
+	a.ldxMem(bpfB, 5, 2, 14)
+	a.jmpImm(bpfJNE, 5, 0x45, "pass")
+	a.ldxMem(bpfB, 5, 2, 23)
+	a.jmpImm(bpfJNE, 5, syscall.IPPROTO_UDP, "pass")
+	a.ldxMem(bpfH, 5, 2, 20)
+	a.toBE(5, 16)
+	a.alu64Imm(bpfAND, 5, 0x3fff)
+	a.jmpImm(bpfJNE, 5, 0, "pass")
+	a.ldxMem(bpfH, 5, 2, 36)
+
+	a.label("port")
+	a.toBE(5, 16)
+	a.stxMem(bpfW, 10, 5, -4)
+	a.ldMapFD(1, portsMapFD)
+	a.stackPtr(2, -4)
+	a.call(bpfFuncMapLookupElem)
+	a.jmpImm(bpfJEQ, 0, 0, "pass")
+	a.ldMapFD(1, xskMapFD)
+	a.ldxMem(bpfW, 2, 6, 16)
+	a.mov64Imm(3, xdpActionPass)
+	a.call(bpfFuncRedirectMap)
+	a.exit()
+
+	a.label("pass")
+	a.mov64Imm(0, xdpActionPass)
+	a.exit()
+
+	return a.assemble()
+}
+
+// ------------------------------------------------
+
+type xdpRing struct {
+	mem      []byte
+	producer *uint32
+	consumer *uint32
+	descs    unsafe.Pointer
+	mask     uint32
+}
+
+type xdpDesc struct {
+	addr    uint64
+	len     uint32
+	options uint32
+}
+
+type xdpRingOffset struct {
+	producer uint64
+	consumer uint64
+	desc     uint64
+	flags    uint64
+}
+
+type xdpMmapOffsetsV2 struct {
+	rx xdpRingOffset
+	tx xdpRingOffset
+	fr xdpRingOffset
+	cr xdpRingOffset
+}
+
+// xdpQueue is the AF_XDP socket of a receive queue, packets are copied out of its frames and handed back right away
+type xdpQueue struct {
+	iface     *xdpInterface
+	fd        int
+	umem      []byte
+	frameSize uint64
+	rx        xdpRing
+	fill      xdpRing
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newXDPQueue(iface *xdpInterface, ifindex int, queueID int, params XDPParams) (*xdpQueue, error) {
+	if params.NumFrames&(params.NumFrames-1) != 0 {
+		return nil, fmt.Errorf("number of frames %d is not a power of two", params.NumFrames)
+	}
+
+	fd, err := syscall.Socket(afXDP, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	q := &xdpQueue{
+		iface:     iface,
+		fd:        fd,
+		frameSize: uint64(params.FrameSize),
+		done:      make(chan struct{}),
+	}
+	if err := q.setup(ifindex, queueID, params); err != nil {
+		q.release()
+		return nil, err
+	}
+
+	q.wg.Add(1)
+	go q.read()
+	return q, nil
+}
+
+func (q *xdpQueue) setup(ifindex int, queueID int, params XDPParams) error {
+	var err error
+	q.umem, err = syscall.Mmap(-1, 0, params.NumFrames*params.FrameSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS)
+	if err != nil {
+		return err
+	}
+	umemReg := struct {
+		addr      uint64
+		len       uint64
+		chunkSize uint32
+		headroom  uint32
+	}{
+		addr:      uint64(uintptr(unsafe.Pointer(&q.umem[0]))),
+		len:       uint64(len(q.umem)),
+		chunkSize: uint32(params.FrameSize),
+	}
+	if err := setsockopt(q.fd, xdpUmemReg, unsafe.Pointer(&umemReg), unsafe.Sizeof(umemReg)); err != nil {
+		return fmt.Errorf("could not register frames: %w", err)
+	}
+	for opt, size := range map[int]int{
+		xdpUmemFillRing:       params.NumFrames,
+		xdpUmemCompletionRing: xdpCompletionRingSize,
+		xdpRxRing:             params.NumFrames,
+	} {
+		if err := syscall.SetsockoptInt(q.fd, solXDP, opt, size); err != nil {
+			return fmt.Errorf("could not size ring %d: %w", opt, err)
+		}
+	}
+
+	var offsets xdpMmapOffsetsV2
+	size := uint32(unsafe.Sizeof(offsets))
+	if _, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, uintptr(q.fd), solXDP, xdpMmapOffsets, uintptr(unsafe.Pointer(&offsets)), uintptr(unsafe.Pointer(&size)), 0); errno != 0 {
+		return fmt.Errorf("could not get ring offsets: %w", errno)
+	}
+	if q.rx, err = mmapXDPRing(q.fd, xdpPgoffRxRing, offsets.rx, params.NumFrames, unsafe.Sizeof(xdpDesc{})); err != nil {
+		return err
+	}
+	if q.fill, err = mmapXDPRing(q.fd, xdpUmemPgoffFillRing, offsets.fr, params.NumFrames, 8); err != nil {
+		return err
+	}
+
+	// all frames are available for receiving
+	for i := 0; i < params.NumFrames; i++ {
+		*(*uint64)(unsafe.Add(q.fill.descs, i*8)) = uint64(i) * q.frameSize
+	}
+	atomic.StoreUint32(q.fill.producer, uint32(params.NumFrames))
+
+	sa := struct {
+		family       uint16
+		flags        uint16
+		ifindex      uint32
+		queueID      uint32
+		sharedUmemFD uint32
+	}{
+		family:  afXDP,
+		ifindex: uint32(ifindex),
+		queueID: uint32(queueID),
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(q.fd), uintptr(unsafe.Pointer(&sa)), unsafe.Sizeof(sa)); errno != 0 {
+		return fmt.Errorf("could not bind: %w", errno)
+	}
+
+	key, value := uint32(queueID), uint32(q.fd)
+	if err := bpfMapUpdate(q.iface.xskMapFD, unsafe.Pointer(&key), unsafe.Pointer(&value)); err != nil {
+		return fmt.Errorf("could not add to XSK map: %w", err)
+	}
+	return nil
+}
+
+func mmapXDPRing(fd int, pgoff int64, off xdpRingOffset, n int, descSize uintptr) (xdpRing, error) {
+	mem, err := syscall.Mmap(fd, pgoff, int(off.desc)+n*int(descSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return xdpRing{}, fmt.Errorf("could not map ring: %w", err)
+	}
+	return xdpRing{
+		mem:      mem,
+		producer: (*uint32)(unsafe.Pointer(&mem[off.producer])),
+		consumer: (*uint32)(unsafe.Pointer(&mem[off.consumer])),
+		descs:    unsafe.Pointer(&mem[off.desc]),
+		mask:     uint32(n - 1),
+	}, nil
+}
+
+func (q *xdpQueue) read() {
+	defer q.wg.Done()
+
+	fds := []struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{{fd: int32(q.fd), events: 0x1}}
+	timeout := syscall.NsecToTimespec(xdpPollTimeoutMs * 1_000_000)
+	for {
+		select {
+		case <-q.done:
+			return
+		default:
+		}
+
+		_, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&fds[0])), 1, uintptr(unsafe.Pointer(&timeout)), 0, 0, 0)
+		if errno != 0 && errno != syscall.EINTR {
+			logger.Warnw("AF_XDP reader stopped", errno, "interface", q.iface.name)
+			return
+		}
+
+		consumer := *q.rx.consumer
+		producer := atomic.LoadUint32(q.rx.producer)
+		if consumer == producer {
+			continue
+		}
+		fillProducer := *q.fill.producer
+		for ; consumer != producer; consumer++ {
+			desc := (*xdpDesc)(unsafe.Add(q.rx.descs, uintptr(consumer&q.rx.mask)*unsafe.Sizeof(xdpDesc{})))
+			q.iface.dispatch(q.umem[desc.addr : desc.addr+uint64(desc.len)])
+
+			*(*uint64)(unsafe.Add(q.fill.descs, uintptr(fillProducer&q.fill.mask)*8)) = desc.addr &^ (q.frameSize - 1)
+			fillProducer++
+		}
+		atomic.StoreUint32(q.rx.consumer, consumer)
+		atomic.StoreUint32(q.fill.producer, fillProducer)
+	}
+}
+
+func (q *xdpQueue) close() {
+	close(q.done)
+	q.wg.Wait()
+	q.release()
+}
+
+func (q *xdpQueue) release() {
+	for _, mem := range [][]byte{q.rx.mem, q.fill.mem, q.umem} {
+		if mem != nil {
+			_ = syscall.Munmap(mem)
+		}
+	}
+	_ = syscall.Close(q.fd)
+}
+
+func setsockopt(fd int, opt int, val unsafe.Pointer, size uintptr) error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), solXDP, uintptr(opt), uintptr(val), size, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
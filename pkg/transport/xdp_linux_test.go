@@ -0,0 +1,74 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && afxdp && (amd64 || arm64)
+// +build linux
+// +build afxdp
+// +build amd64 arm64
+
+package transport
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestXDPConn(t *testing.T) {
+	if err := XDPSupported("lo"); err != nil {
+		t.Skipf("AF_XDP not supported: %v", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	xc, err := NewXDPConn(conn, XDPParams{NumFrames: 256})
+	require.NoError(t, err)
+	defer xc.Close()
+
+	client, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer client.Close()
+
+	buf := make([]byte, 1500)
+	for i := 0; i < 10; i++ {
+		payload := []byte{byte(i), 1, 2, 3}
+		_, err = client.Write(payload)
+		require.NoError(t, err)
+
+		require.NoError(t, xc.SetReadDeadline(time.Now().Add(time.Second)))
+		n, addr, err := xc.ReadFrom(buf)
+		require.NoError(t, err)
+		require.Equal(t, payload, buf[:n])
+		require.Equal(t, client.LocalAddr().String(), addr.String())
+	}
+	stats := xc.Stats()
+	require.EqualValues(t, 10, stats.NumXDPPackets+stats.NumSocketPackets)
+	require.NotZero(t, stats.NumXDPPackets)
+
+	// replies go out through the socket
+	_, err = xc.WriteTo([]byte{4, 5, 6}, client.LocalAddr())
+	require.NoError(t, err)
+	require.NoError(t, client.SetReadDeadline(time.Now().Add(time.Second)))
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{4, 5, 6}, buf[:n])
+
+	require.NoError(t, xc.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	_, _, err = xc.ReadFrom(buf)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+}
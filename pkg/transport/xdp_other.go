@@ -0,0 +1,26 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux || !afxdp || !(amd64 || arm64)
+// +build !linux !afxdp !amd64,!arm64
+
+package transport
+
+func xdpSupported(ifName string) error {
+	return ErrXDPNotSupported
+}
+
+func attachXDP(c *XDPConn) (func(), error) {
+	return nil, ErrXDPNotSupported
+}
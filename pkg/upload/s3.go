@@ -0,0 +1,240 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	s3Service = "s3"
+	// bound on response bodies read, responses are small XML documents
+	maxResponseSize = 64 * 1024
+)
+
+var (
+	ErrInvalidResponse = errors.New("invalid object storage response")
+)
+
+type S3Params struct {
+	// e.g. https://s3.us-east-1.amazonaws.com, or the endpoint of an S3 compatible store
+	Endpoint     string
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// address the bucket in the path rather than the host name, usually needed by S3 compatible stores
+	ForcePathStyle bool
+	// default http.DefaultClient
+	Client *http.Client
+}
+
+// S3Sink uploads to S3, or a store implementing the S3 multipart upload API (GCS XML API with HMAC keys, MinIO, ...),
+// signing requests with AWS signature version 4.
+type S3Sink struct {
+	params   S3Params
+	endpoint *url.URL
+	creds    credentials
+}
+
+func NewS3Sink(params S3Params) (*S3Sink, error) {
+	endpoint, err := url.Parse(params.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q", params.Endpoint)
+	}
+	if params.Bucket == "" {
+		return nil, errors.New("missing bucket")
+	}
+	if params.Client == nil {
+		params.Client = http.DefaultClient
+	}
+
+	return &S3Sink{
+		params:   params,
+		endpoint: endpoint,
+		creds: credentials{
+			accessKey:    params.AccessKey,
+			secretKey:    params.SecretKey,
+			sessionToken: params.SessionToken,
+		},
+	}, nil
+}
+
+func (s *S3Sink) Create(ctx context.Context, key string) (Upload, error) {
+	body, _, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &result); err != nil || result.UploadID == "" {
+		return nil, ErrInvalidResponse
+	}
+	return &s3Upload{
+		sink:     s,
+		key:      key,
+		uploadID: result.UploadID,
+		etags:    make(map[int]string),
+	}, nil
+}
+
+func (s *S3Sink) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	escapedKey := strings.Join(segments, "/")
+
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if s.params.ForcePathStyle {
+		u.Path = basePath + "/" + s.params.Bucket + "/" + key
+		u.RawPath = basePath + "/" + uriEncode(s.params.Bucket) + "/" + escapedKey
+	} else {
+		u.Host = s.params.Bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+		u.RawPath = basePath + "/" + escapedKey
+	}
+	u.RawQuery = canonicalQuery(query)
+	return &u
+}
+
+func (s *S3Sink) do(ctx context.Context, method string, key string, query url.Values, body []byte) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.ContentLength = int64(len(body))
+	payloadHash := hashHex(body)
+	req.Header.Set(headerAmzContentSHA256, payloadHash)
+	signV4(req, s.creds, s.params.Region, s3Service, payloadHash, time.Now())
+
+	resp, err := s.params.Client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, &StatusError{StatusCode: resp.StatusCode, Message: errorMessage(respBody)}
+	}
+	return respBody, resp.Header, nil
+}
+
+// ------------------------------------------------
+
+type s3Upload struct {
+	sink     *S3Sink
+	key      string
+	uploadID string
+
+	lock  sync.Mutex
+	etags map[int]string
+}
+
+func (u *s3Upload) UploadPart(ctx context.Context, partNumber int, data []byte) error {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {u.uploadID},
+	}
+	_, header, err := u.sink.do(ctx, http.MethodPut, u.key, query, data)
+	if err != nil {
+		return err
+	}
+	etag := header.Get("ETag")
+	if etag == "" {
+		return ErrInvalidResponse
+	}
+
+	u.lock.Lock()
+	u.etags[partNumber] = etag
+	u.lock.Unlock()
+	return nil
+}
+
+func (u *s3Upload) Complete(ctx context.Context) error {
+	type part struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	var request struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+
+	u.lock.Lock()
+	for partNumber, etag := range u.etags {
+		request.Parts = append(request.Parts, part{PartNumber: partNumber, ETag: etag})
+	}
+	u.lock.Unlock()
+	sort.Slice(request.Parts, func(i, j int) bool {
+		return request.Parts[i].PartNumber < request.Parts[j].PartNumber
+	})
+
+	body, err := xml.Marshal(&request)
+	if err != nil {
+		return err
+	}
+	respBody, _, err := u.sink.do(ctx, http.MethodPost, u.key, url.Values{"uploadId": {u.uploadID}}, body)
+	if err != nil {
+		return err
+	}
+
+	// S3 can report a failure of complete with a 200 status
+	if bytes.Contains(respBody, []byte("<Error>")) {
+		return &StatusError{StatusCode: http.StatusInternalServerError, Message: errorMessage(respBody)}
+	}
+	return nil
+}
+
+func (u *s3Upload) Abort(ctx context.Context) error {
+	_, _, err := u.sink.do(ctx, http.MethodDelete, u.key, url.Values{"uploadId": {u.uploadID}}, nil)
+	return err
+}
+
+// ------------------------------------------------
+
+func errorMessage(body []byte) string {
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &s3Err); err != nil || s3Err.Code == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return s3Err.Code + ": " + s3Err.Message
+}
@@ -0,0 +1,236 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// S3 minimum size of every part but the last
+	MinPartSize = 5 * 1024 * 1024
+	// S3 maximum number of parts of an object
+	MaxParts = 10000
+)
+
+var (
+	ErrWriterClosed = errors.New("upload writer closed")
+	ErrTooManyParts = errors.New("too many upload parts")
+)
+
+// Sink is object storage that accepts objects in parts, with multipart upload semantics:
+// parts are uploaded individually, possibly retried, and the object only appears on Complete.
+type Sink interface {
+	Create(ctx context.Context, key string) (Upload, error)
+}
+
+type Upload interface {
+	// UploadPart uploads or replaces part partNumber, starting at 1. data must not be retained after it returns.
+	UploadPart(ctx context.Context, partNumber int, data []byte) error
+	// Complete assembles the uploaded parts into the object.
+	Complete(ctx context.Context) error
+	// Abort discards the uploaded parts.
+	Abort(ctx context.Context) error
+}
+
+// StatusError is returned by sinks for an unsuccessful HTTP response.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("upload failed, status %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable returns true for throttling, timeouts and server errors.
+func (e *StatusError) Retryable() bool {
+	return e.StatusCode == 408 || e.StatusCode == 429 || e.StatusCode >= 500
+}
+
+type RetryParams struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var RetryParamsDefault = RetryParams{
+	MaxAttempts:    5,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+}
+
+// retry runs f until it succeeds, fails with a non retryable error, or attempts are exhausted,
+// backing off exponentially between attempts
+func retry(ctx context.Context, params RetryParams, f func() error) error {
+	backoff := params.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil {
+			return nil
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.Retryable() {
+			return err
+		}
+		if attempt >= params.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+		backoff *= 2
+		if backoff > params.MaxBackoff {
+			backoff = params.MaxBackoff
+		}
+	}
+}
+
+// ------------------------------------------------
+
+type ChunkedWriterParams struct {
+	// size of each part but the last, at least MinPartSize for S3
+	PartSize int
+	Retry    RetryParams
+}
+
+var ChunkedWriterParamsDefault = ChunkedWriterParams{
+	PartSize: 8 * 1024 * 1024,
+	Retry:    RetryParamsDefault,
+}
+
+// ChunkedWriter streams an object, for example a recording segment produced by a file writer,
+// to a sink as it is written: data is buffered into parts, each part is uploaded with retries once full,
+// and Close uploads the last part and completes the object. On failure the upload is aborted,
+// and every later call returns the error.
+type ChunkedWriter struct {
+	ctx    context.Context
+	params ChunkedWriterParams
+	upload Upload
+
+	buf        []byte
+	partNumber int
+	err        error
+}
+
+var _ io.WriteCloser = (*ChunkedWriter)(nil)
+
+func NewChunkedWriter(ctx context.Context, sink Sink, key string, params ChunkedWriterParams) (*ChunkedWriter, error) {
+	if params.PartSize <= 0 {
+		params.PartSize = ChunkedWriterParamsDefault.PartSize
+	}
+	if params.Retry.MaxAttempts <= 0 {
+		params.Retry = RetryParamsDefault
+	}
+
+	var upload Upload
+	err := retry(ctx, params.Retry, func() error {
+		var err error
+		upload, err = sink.Create(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChunkedWriter{
+		ctx:    ctx,
+		params: params,
+		upload: upload,
+		buf:    make([]byte, 0, params.PartSize),
+	}, nil
+}
+
+func (w *ChunkedWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n := w.params.PartSize - len(w.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+
+		if len(w.buf) == w.params.PartSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close uploads the remaining data and completes the object.
+func (w *ChunkedWriter) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	// an empty object still needs one part
+	if len(w.buf) != 0 || w.partNumber == 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	if err := retry(w.ctx, w.params.Retry, func() error { return w.upload.Complete(w.ctx) }); err != nil {
+		return w.fail(err)
+	}
+	w.err = ErrWriterClosed
+	return nil
+}
+
+// Abort discards the object.
+func (w *ChunkedWriter) Abort() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = ErrWriterClosed
+	return w.upload.Abort(w.ctx)
+}
+
+func (w *ChunkedWriter) flush() error {
+	if w.partNumber >= MaxParts {
+		return w.fail(ErrTooManyParts)
+	}
+
+	w.partNumber++
+	partNumber := w.partNumber
+	data := w.buf
+	if err := retry(w.ctx, w.params.Retry, func() error { return w.upload.UploadPart(w.ctx, partNumber, data) }); err != nil {
+		return w.fail(err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+func (w *ChunkedWriter) fail(err error) error {
+	w.err = err
+	_ = w.upload.Abort(w.ctx)
+	return err
+}
@@ -0,0 +1,268 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// get-vanilla from the AWS signature version 4 test suite
+func TestSignV4(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	require.NoError(t, err)
+
+	signV4(
+		req,
+		credentials{accessKey: "AKIDEXAMPLE", secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		"us-east-1",
+		"service",
+		hashHex(nil),
+		time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC),
+	)
+	require.Equal(t, "20150830T123600Z", req.Header.Get(headerAmzDate))
+	require.Equal(t,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"),
+	)
+}
+
+type memorySink struct {
+	lock       sync.Mutex
+	parts      map[int][]byte
+	failures   int
+	completed  []byte
+	isAborted  bool
+	permanent  bool
+	numUploads int
+}
+
+func (m *memorySink) Create(_ context.Context, _ string) (Upload, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.parts = make(map[int][]byte)
+	return m, nil
+}
+
+func (m *memorySink) UploadPart(_ context.Context, partNumber int, data []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.numUploads++
+	if m.failures > 0 {
+		m.failures--
+		if m.permanent {
+			return &StatusError{StatusCode: http.StatusForbidden}
+		}
+		return &StatusError{StatusCode: http.StatusServiceUnavailable}
+	}
+	m.parts[partNumber] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memorySink) Complete(_ context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for i := 1; i <= len(m.parts); i++ {
+		m.completed = append(m.completed, m.parts[i]...)
+	}
+	return nil
+}
+
+func (m *memorySink) Abort(_ context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.isAborted = true
+	return nil
+}
+
+func testWriterParams() ChunkedWriterParams {
+	return ChunkedWriterParams{
+		PartSize: 10,
+		Retry: RetryParams{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+		},
+	}
+}
+
+func TestChunkedWriter(t *testing.T) {
+	sink := &memorySink{}
+	w, err := NewChunkedWriter(context.Background(), sink, "segment.ts", testWriterParams())
+	require.NoError(t, err)
+
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	n, err := w.Write(data[:7])
+	require.NoError(t, err)
+	require.Equal(t, 7, n)
+	require.Empty(t, sink.parts)
+
+	// retried after a server error
+	sink.failures = 2
+	_, err = w.Write(data[7:])
+	require.NoError(t, err)
+	require.Len(t, sink.parts, 3)
+
+	require.NoError(t, w.Close())
+	require.Equal(t, data, sink.completed)
+	require.False(t, sink.isAborted)
+
+	_, err = w.Write(data)
+	require.ErrorIs(t, err, ErrWriterClosed)
+}
+
+func TestChunkedWriterFailure(t *testing.T) {
+	sink := &memorySink{failures: 1, permanent: true}
+	w, err := NewChunkedWriter(context.Background(), sink, "segment.ts", testWriterParams())
+	require.NoError(t, err)
+
+	// permanent errors are not retried, the upload is aborted
+	_, err = w.Write(make([]byte, 10))
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusForbidden, statusErr.StatusCode)
+	require.Equal(t, 1, sink.numUploads)
+	require.True(t, sink.isAborted)
+	require.Equal(t, err, w.Close())
+
+	// retries exhausted
+	sink = &memorySink{failures: 3}
+	w, err = NewChunkedWriter(context.Background(), sink, "segment.ts", testWriterParams())
+	require.NoError(t, err)
+	_, err = w.Write(make([]byte, 10))
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, 3, sink.numUploads)
+	require.True(t, sink.isAborted)
+}
+
+// fakeS3 implements the multipart upload API, path style
+type fakeS3 struct {
+	t *testing.T
+
+	lock         sync.Mutex
+	parts        map[string][]byte
+	objects      map[string][]byte
+	failNextPart bool
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get(headerAmzContentSHA256) != hashHex(body) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>b</Bucket><UploadId>up1</UploadId></InitiateMultipartUploadResult>`)
+
+	case r.Method == http.MethodPut && query.Get("uploadId") == "up1":
+		if f.failNextPart {
+			f.failNextPart = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `<Error><Code>SlowDown</Code><Message>slow down</Message></Error>`)
+			return
+		}
+		f.parts[query.Get("partNumber")] = body
+		w.Header().Set("ETag", `"etag`+query.Get("partNumber")+`"`)
+
+	case r.Method == http.MethodPost && query.Get("uploadId") == "up1":
+		var request struct {
+			Parts []struct {
+				PartNumber string `xml:"PartNumber"`
+				ETag       string `xml:"ETag"`
+			} `xml:"Part"`
+		}
+		require.NoError(f.t, xml.Unmarshal(body, &request))
+		var object []byte
+		for _, p := range request.Parts {
+			require.Equal(f.t, `"etag`+p.PartNumber+`"`, p.ETag)
+			object = append(object, f.parts[p.PartNumber]...)
+		}
+		f.objects[r.URL.Path] = object
+		fmt.Fprint(w, `<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`)
+
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestS3Sink(t *testing.T) {
+	fake := &fakeS3{t: t, parts: make(map[string][]byte), objects: make(map[string][]byte), failNextPart: true}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	sink, err := NewS3Sink(S3Params{
+		Endpoint:       server.URL,
+		Region:         "us-east-1",
+		Bucket:         "recordings",
+		AccessKey:      "key",
+		SecretKey:      "secret",
+		ForcePathStyle: true,
+	})
+	require.NoError(t, err)
+
+	w, err := NewChunkedWriter(context.Background(), sink, "room/track 1.ts", testWriterParams())
+	require.NoError(t, err)
+
+	data := bytes.Repeat([]byte("0123456789abc"), 3)
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.Equal(t, data, fake.objects["/recordings/room/track 1.ts"])
+
+	// upload with a bad credential is not retried
+	sink.creds.accessKey = "other"
+	_, err = sink.Create(context.Background(), "x")
+	var statusErr *StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.False(t, statusErr.Retryable())
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	sink, err := NewS3Sink(S3Params{Endpoint: "https://s3.us-east-1.amazonaws.com", Bucket: "b"})
+	require.NoError(t, err)
+	require.Equal(t,
+		"https://b.s3.us-east-1.amazonaws.com/a/b%20c%2Bd.ts?partNumber=1&uploadId=x%2By",
+		sink.objectURL("a/b c+d.ts", map[string][]string{"uploadId": {"x+y"}, "partNumber": {"1"}}).String(),
+	)
+
+	_, err = NewS3Sink(S3Params{Endpoint: "s3.amazonaws.com", Bucket: "b"})
+	require.Error(t, err)
+}
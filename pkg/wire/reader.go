@@ -0,0 +1,148 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrShortBuffer  = errors.New("short buffer")
+	ErrTrailingData = errors.New("trailing data")
+)
+
+// Reader reads big endian fields from untrusted input, such as RTP header extensions and RTCP feedback.
+//
+// Reads never go out of bounds: the first read past the end of the input, or the first error set with Fail,
+// is kept, and every read after it returns zero values. A parser reads all fields it needs
+// and checks Err once, instead of checking the length before every field.
+type Reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func NewReader(data []byte) *Reader {
+	return &Reader{
+		data: data,
+	}
+}
+
+// Err returns the first error of the reader.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Fail records a parse error, for example an invalid field value, if there is none yet.
+func (r *Reader) Fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Finish returns the first error of the reader, or ErrTrailingData if input is left unread.
+func (r *Reader) Finish() error {
+	if r.err == nil && r.off != len(r.data) {
+		r.err = fmt.Errorf("%w: %d bytes at offset %d", ErrTrailingData, len(r.data)-r.off, r.off)
+	}
+	return r.err
+}
+
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// Remaining returns the number of bytes left, 0 after an error.
+func (r *Reader) Remaining() int {
+	if r.err != nil {
+		return 0
+	}
+	return len(r.data) - r.off
+}
+
+func (r *Reader) Uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *Reader) Uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *Reader) Uint24() uint32 {
+	b := r.next(3)
+	if b == nil {
+		return 0
+	}
+	return uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2])
+}
+
+func (r *Reader) Uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *Reader) Uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// Bytes returns the next n bytes, aliasing the input, nil on error.
+func (r *Reader) Bytes(n int) []byte {
+	return r.next(n)
+}
+
+func (r *Reader) Skip(n int) {
+	r.next(n)
+}
+
+// Sub returns a reader over the next n bytes, for length prefixed fields. Its errors are not
+// propagated, a parser checks them with Finish on the sub reader.
+func (r *Reader) Sub(n int) *Reader {
+	b := r.next(n)
+	if b == nil {
+		return &Reader{err: r.err}
+	}
+	return NewReader(b)
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data)-r.off {
+		r.err = fmt.Errorf("%w: need %d bytes at offset %d, have %d", ErrShortBuffer, n, r.off, len(r.data)-r.off)
+		return nil
+	}
+
+	b := r.data[r.off : r.off+n : r.off+n]
+	r.off += n
+	return b
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wire
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	r := NewReader([]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10, 0x11, 0x12})
+	require.Equal(t, uint8(0x01), r.Uint8())
+	require.Equal(t, uint16(0x0203), r.Uint16())
+	require.Equal(t, uint32(0x040506), r.Uint24())
+	require.Equal(t, uint32(0x0708090a), r.Uint32())
+	require.Equal(t, uint64(0x0b0c0d0e0f101112), r.Uint64())
+	require.Zero(t, r.Remaining())
+	require.NoError(t, r.Finish())
+
+	// reads past the end return zero values and keep the first error
+	r = NewReader([]byte{0x01, 0x02, 0x03})
+	require.Equal(t, uint16(0x0102), r.Uint16())
+	require.Zero(t, r.Uint32())
+	require.Equal(t, 2, r.Offset())
+	require.Zero(t, r.Uint8())
+	require.Nil(t, r.Bytes(1))
+	require.ErrorIs(t, r.Err(), ErrShortBuffer)
+	require.Zero(t, r.Remaining())
+
+	r = NewReader([]byte{0x01, 0x02})
+	r.Skip(1)
+	require.Nil(t, r.Bytes(-1))
+	require.ErrorIs(t, r.Finish(), ErrShortBuffer)
+
+	// trailing data
+	r = NewReader([]byte{0x01, 0x02})
+	r.Uint8()
+	require.ErrorIs(t, r.Finish(), ErrTrailingData)
+
+	// first error is kept
+	errInvalid := errors.New("invalid")
+	r = NewReader([]byte{0x01})
+	r.Fail(errInvalid)
+	r.Uint16()
+	require.ErrorIs(t, r.Err(), errInvalid)
+}
+
+func TestReaderSub(t *testing.T) {
+	r := NewReader([]byte{0x02, 0xaa, 0xbb, 0x01})
+	sub := r.Sub(int(r.Uint8()))
+	require.Equal(t, uint8(0xaa), sub.Uint8())
+	require.ErrorIs(t, sub.Finish(), ErrTrailingData)
+	require.Equal(t, uint8(0x01), r.Uint8())
+	require.NoError(t, r.Finish())
+
+	// slices alias the input, but cannot be appended to over it
+	data := []byte{0x01, 0x02, 0x03}
+	r = NewReader(data)
+	b := r.Bytes(2)
+	_ = append(b, 0xff)
+	require.Equal(t, byte(0x03), data[2])
+
+	r = NewReader([]byte{0x05, 0x01})
+	sub = r.Sub(int(r.Uint8()))
+	require.ErrorIs(t, sub.Err(), ErrShortBuffer)
+	require.ErrorIs(t, r.Err(), ErrShortBuffer)
+}
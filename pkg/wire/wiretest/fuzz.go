@@ -0,0 +1,105 @@
+// Copyright 2023 LiveKit, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package wiretest provides fuzz targets and corpus mutations for parsers of untrusted input,
+// for use in tests of this module and of forks adding their own parsers.
+package wiretest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Mutations returns variants of seed that exercise bounds checks: every truncation,
+// each byte set to 0x00 and 0xff (e.g. length and count fields), and trailing data.
+func Mutations(seed []byte) [][]byte {
+	var mutations [][]byte
+	for i := 0; i < len(seed); i++ {
+		mutations = append(mutations, append([]byte{}, seed[:i]...))
+	}
+	for i := range seed {
+		for _, b := range []byte{0x00, 0xff} {
+			if seed[i] == b {
+				continue
+			}
+			m := append([]byte{}, seed...)
+			m[i] = b
+			mutations = append(mutations, m)
+		}
+	}
+	mutations = append(mutations, append(append([]byte{}, seed...), 0x00))
+	return mutations
+}
+
+// Check runs parse on every seed of the corpus and on their mutations. It fails the test if parse panics,
+// modifies its input, or rejects one of the seeds, which are expected to be valid.
+func Check(t testing.TB, corpus [][]byte, parse func(data []byte) error) {
+	t.Helper()
+
+	for i, seed := range corpus {
+		parseErr, err := run(seed, parse)
+		if err != nil {
+			t.Fatalf("seed %d: %v", i, err)
+		}
+		if parseErr != nil {
+			t.Fatalf("seed %d rejected: %v", i, parseErr)
+		}
+		for _, m := range Mutations(seed) {
+			if _, err := run(m, parse); err != nil {
+				t.Fatalf("seed %d, mutation %x: %v", i, m, err)
+			}
+		}
+	}
+}
+
+// Fuzz adds the corpus and its mutations as seeds of a fuzz target that fails if parse panics or
+// modifies its input. Typical use is
+//
+//	func FuzzUnmarshal(f *testing.F) {
+//		wiretest.Fuzz(f, corpus, func(data []byte) error {
+//			_, err := Unmarshal(data)
+//			return err
+//		})
+//	}
+func Fuzz(f *testing.F, corpus [][]byte, parse func(data []byte) error) {
+	for _, seed := range corpus {
+		f.Add(seed)
+		for _, m := range Mutations(seed) {
+			f.Add(m)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if _, err := run(data, parse); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// returns the error of parse, and an error if it panicked or modified its input
+func run(data []byte, parse func(data []byte) error) (parseErr error, err error) {
+	input := append([]byte{}, data...)
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		} else if !bytes.Equal(input, data) {
+			err = errors.New("input modified")
+		}
+	}()
+
+	parseErr = parse(input)
+	return
+}